@@ -0,0 +1,328 @@
+// Package archive reads and writes the files the archiver moves expired and deleted rows into. Archives are written
+// in one of several formats (see Lookup) and Read tells them apart.
+//
+// Bundles are tar files holding a manifest.json and a file per section, each encoded by an Encoder.
+//
+// Text archives are the original format: the archive's name on the first line, then a section per table made of a
+// "== Name(column|~|column...)" header and a line per row with the values in the order of the header. Lines starting
+// with "--" are comments; older archives note the sections the archiver failed to write with them
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"go-api/model"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Separator separates the columns of a header and the values of a row
+const Separator = "|~|"
+
+const (
+	OfferingsSection   = "Offerings"
+	BookingsSection    = "Bookings"
+	AssignmentsSection = "Assignments"
+	WorkspacesSection  = "Workspaces"
+	FloorsSection      = "Floors"
+)
+
+// columns are the columns of each section, in the order the text and CSV formats write them. Columns added later go
+// at the end, and are empty when older archives are read
+var columns = map[string][]string{
+	OfferingsSection:   {"id", "workspace_id", "user_id", "created_by", "start_date", "end_date", "cancelled", "series_id"},
+	BookingsSection:    {"id", "workspace_id", "user_id", "created_by", "start_date", "end_date", "cancelled", "series_id", "checked_in_at", "no_show"},
+	AssignmentsSection: {"id", "workspace_id", "user_id", "start_time", "end_time"},
+	WorkspacesSection:  {"id", "name", "floor_id", "details", "props"},
+	FloorsSection:      {"id", "name", "address", "download_url"},
+}
+
+// Sections are the sections of an archive, children before the rows they refer to
+var Sections = []string{OfferingsSection, BookingsSection, AssignmentsSection, WorkspacesSection, FloorsSection}
+
+var (
+	OfferingsHeader   = header(OfferingsSection)
+	BookingsHeader    = header(BookingsSection)
+	AssignmentsHeader = header(AssignmentsSection)
+	WorkspacesHeader  = header(WorkspacesSection)
+	FloorsHeader      = header(FloorsSection)
+)
+
+// Archive is the content of an archive file
+type Archive struct {
+	Name string
+	// Manifest describes a bundle; text archives have none
+	Manifest *Manifest
+	model.ArchivedRows
+}
+
+func header(section string) string {
+	return fmt.Sprintf("== %s(%s)", section, strings.Join(columns[section], Separator))
+}
+
+func FormatOffering(o *model.Offering) string {
+	return strings.Join(values(o), Separator)
+}
+
+func FormatBooking(b *model.Booking) string {
+	return strings.Join(values(b), Separator)
+}
+
+func FormatAssignment(a *model.Assignment) string {
+	return strings.Join(values(a), Separator)
+}
+
+func FormatWorkspace(w *model.Workspace) string {
+	return strings.Join(values(w), Separator)
+}
+
+func FormatFloor(f *model.Floor) string {
+	return strings.Join(values(f), Separator)
+}
+
+// values returns the values of item, a row of any section, in the order of its section's columns
+func values(item interface{}) []string {
+	switch v := item.(type) {
+	case *model.Offering:
+		return []string{
+			v.ID, v.WorkspaceID, v.UserID, v.CreatedBy,
+			v.StartDate.Format(time.RFC3339), v.EndDate.Format(time.RFC3339), strconv.FormatBool(v.Cancelled),
+			v.SeriesID,
+		}
+	case *model.Booking:
+		checkedInAt := ""
+		if v.CheckedInAt != nil {
+			checkedInAt = v.CheckedInAt.Format(time.RFC3339Nano)
+		}
+		return []string{
+			v.ID, v.WorkspaceID, v.UserID, v.CreatedBy,
+			v.StartDate.Format(time.RFC3339), v.EndDate.Format(time.RFC3339), strconv.FormatBool(v.Cancelled),
+			v.SeriesID, checkedInAt, strconv.FormatBool(v.NoShow),
+		}
+	case *model.Assignment:
+		return []string{v.ID, v.WorkspaceID, v.UserID, v.StartDate.Format(time.RFC3339), v.EndDate.Format(time.RFC3339)}
+	case *model.Workspace:
+		props, _ := json.Marshal(v.Props)
+		return []string{v.ID, v.Name, v.Floor, v.Details, string(props)}
+	case *model.Floor:
+		return []string{v.ID, v.Name, v.Address, v.DownloadURL}
+	}
+	return nil
+}
+
+// items returns the rows of one section
+func items(section string, rows *model.ArchivedRows) []interface{} {
+	items := make([]interface{}, 0)
+	switch section {
+	case OfferingsSection:
+		for _, o := range rows.Offerings {
+			items = append(items, o)
+		}
+	case BookingsSection:
+		for _, b := range rows.Bookings {
+			items = append(items, b)
+		}
+	case AssignmentsSection:
+		for _, a := range rows.Assignments {
+			items = append(items, a)
+		}
+	case WorkspacesSection:
+		for _, w := range rows.Workspaces {
+			items = append(items, w)
+		}
+	case FloorsSection:
+		for _, f := range rows.Floors {
+			items = append(items, f)
+		}
+	}
+	return items
+}
+
+// text is the original archive format. It can't hold values containing newlines or the separator, so it is only
+// kept to read old archives and for tools that still expect it
+type text struct{}
+
+func (text) Extension() string {
+	return ".archive"
+}
+
+func (text) ContentType() string {
+	return "text/plain"
+}
+
+func (text) Write(w io.Writer, name string, rows *model.ArchivedRows) error {
+	buf := bufio.NewWriter(w)
+	// bufio.Writer keeps the first error and Flush returns it
+	line := func(text string) {
+		buf.WriteString(text)
+		buf.WriteByte('\n')
+	}
+	line(name)
+	for _, section := range Sections {
+		line(header(section))
+		for _, item := range items(section, rows) {
+			line(strings.Join(values(item), Separator))
+		}
+	}
+	return buf.Flush()
+}
+
+// ParseError is a line of an archive that could not be parsed
+type ParseError struct {
+	Line    int
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("archive: line %d: %s", e.Line, e.Message)
+}
+
+// row is a line of a section, by column
+type row map[string]string
+
+func (r row) time(column string) (time.Time, error) {
+	return time.Parse(time.RFC3339, r[column])
+}
+
+func (r row) bool(column string) (bool, error) {
+	return strconv.ParseBool(r[column])
+}
+
+// optionalTime is nil when column is empty or missing
+func (r row) optionalTime(column string) (*time.Time, error) {
+	if r[column] == "" {
+		return nil, nil
+	}
+	t, err := r.time(column)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// optionalBool is false when column is empty or missing
+func (r row) optionalBool(column string) (bool, error) {
+	if r[column] == "" {
+		return false, nil
+	}
+	return r.bool(column)
+}
+
+// Parse reads a text archive. Columns are matched by the names in the section headers, so archives written before a
+// column was added can still be read; rows of unknown sections are skipped
+func Parse(r io.Reader) (*Archive, error) {
+	archive := &Archive{ArchivedRows: newRows()}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var section string
+	var columns []string
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if line == 1 {
+			archive.Name = text
+			continue
+		}
+		if text == "" || strings.HasPrefix(text, "--") {
+			continue
+		}
+		if strings.HasPrefix(text, "== ") {
+			open, close := strings.Index(text, "("), strings.LastIndex(text, ")")
+			if open < 0 || close < open {
+				return nil, &ParseError{Line: line, Message: "malformed section header"}
+			}
+			section = text[3:open]
+			columns = strings.Split(text[open+1:close], Separator)
+			continue
+		}
+		if section == "" {
+			return nil, &ParseError{Line: line, Message: "row outside of a section"}
+		}
+		values := strings.Split(text, Separator)
+		if len(values) != len(columns) {
+			return nil, &ParseError{Line: line, Message: fmt.Sprintf("expected %d values, got %d", len(columns), len(values))}
+		}
+		r := make(row, len(columns))
+		for i, column := range columns {
+			r[column] = values[i]
+		}
+		if err := add(&archive.ArchivedRows, section, r); err != nil {
+			return nil, &ParseError{Line: line, Message: err.Error()}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+func newRows() model.ArchivedRows {
+	return model.ArchivedRows{
+		Offerings:   make([]*model.Offering, 0),
+		Bookings:    make([]*model.Booking, 0),
+		Assignments: make([]*model.Assignment, 0),
+		Workspaces:  make([]*model.Workspace, 0),
+		Floors:      make([]*model.Floor, 0),
+	}
+}
+
+// add decodes r as a row of section and adds it to a
+func add(a *model.ArchivedRows, section string, r row) error {
+	var err error
+	switch section {
+	case OfferingsSection:
+		o := &model.Offering{ID: r["id"], WorkspaceID: r["workspace_id"], UserID: r["user_id"], CreatedBy: r["created_by"], SeriesID: r["series_id"]}
+		if o.StartDate, err = r.time("start_date"); err != nil {
+			return err
+		}
+		if o.EndDate, err = r.time("end_date"); err != nil {
+			return err
+		}
+		if o.Cancelled, err = r.bool("cancelled"); err != nil {
+			return err
+		}
+		a.Offerings = append(a.Offerings, o)
+	case BookingsSection:
+		b := &model.Booking{ID: r["id"], WorkspaceID: r["workspace_id"], UserID: r["user_id"], CreatedBy: r["created_by"], SeriesID: r["series_id"]}
+		if b.StartDate, err = r.time("start_date"); err != nil {
+			return err
+		}
+		if b.EndDate, err = r.time("end_date"); err != nil {
+			return err
+		}
+		if b.Cancelled, err = r.bool("cancelled"); err != nil {
+			return err
+		}
+		if b.CheckedInAt, err = r.optionalTime("checked_in_at"); err != nil {
+			return err
+		}
+		if b.NoShow, err = r.optionalBool("no_show"); err != nil {
+			return err
+		}
+		a.Bookings = append(a.Bookings, b)
+	case AssignmentsSection:
+		as := &model.Assignment{ID: r["id"], WorkspaceID: r["workspace_id"], UserID: r["user_id"]}
+		if as.StartDate, err = r.time("start_time"); err != nil {
+			return err
+		}
+		if as.EndDate, err = r.time("end_time"); err != nil {
+			return err
+		}
+		a.Assignments = append(a.Assignments, as)
+	case WorkspacesSection:
+		w := &model.Workspace{ID: r["id"], Name: r["name"], Floor: r["floor_id"], Details: r["details"], Props: model.Attrs{}}
+		if props := r["props"]; props != "" && props != "null" {
+			if err = json.Unmarshal([]byte(props), &w.Props); err != nil {
+				return err
+			}
+		}
+		a.Workspaces = append(a.Workspaces, w)
+	case FloorsSection:
+		a.Floors = append(a.Floors, &model.Floor{ID: r["id"], Name: r["name"], Address: r["address"], DownloadURL: r["download_url"]})
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package archive
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-api/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	monday  = time.Date(2020, time.March, 2, 9, 0, 0, 0, time.UTC)
+	tuesday = monday.AddDate(0, 0, 1)
+)
+
+// legacy is an archive as the archiver wrote them before this package existed
+const legacy = `Test_1583139600.archive
+== Offerings(id|~|workspace_id|~|user_id|~|created_by|~|start_date|~|end_date|~|cancelled)
+o1|~|w1|~|alice|~|alice|~|2020-03-02T09:00:00Z|~|2020-03-06T17:00:00Z|~|false
+== Bookings(id|~|workspace_id|~|user_id|~|created_by|~|start_date|~|end_date|~|cancelled)
+b1|~|w1|~|bob|~|bob|~|2020-03-02T09:00:00Z|~|2020-03-02T17:00:00Z|~|false
+b2|~|w2|~|carol|~|carol|~|2020-03-03T09:00:00Z|~|2020-03-03T17:00:00Z|~|true
+-- error writing assignments
+== Workspaces(id|~|name|~|floor_id|~|details|~|props)
+w2|~|Desk 2|~|f1|~|by the window|~|{"monitor":true}
+== Floors(id|~|name|~|address|~|download_url)
+f1|~|Old floor|~|1 Main St|~|
+`
+
+func TestParse(t *testing.T) {
+	a, err := Parse(strings.NewReader(legacy))
+	require.NoError(t, err)
+	assert.Equal(t, "Test_1583139600.archive", a.Name)
+	assert.Len(t, a.Offerings, 1)
+	assert.Empty(t, a.Assignments)
+	if assert.Len(t, a.Bookings, 2) {
+		assert.Equal(t, "carol", a.Bookings[1].UserID)
+		assert.True(t, a.Bookings[1].Cancelled)
+		assert.True(t, tuesday.Equal(a.Bookings[1].StartDate))
+		assert.Empty(t, a.Bookings[1].SeriesID, "archives from before series have none")
+		assert.Nil(t, a.Bookings[1].CheckedInAt)
+		assert.False(t, a.Bookings[1].NoShow)
+	}
+	if assert.Len(t, a.Workspaces, 1) {
+		assert.Equal(t, model.Attrs{"monitor": true}, a.Workspaces[0].Props)
+		assert.Equal(t, "by the window", a.Workspaces[0].Details)
+	}
+	if assert.Len(t, a.Floors, 1) {
+		assert.Equal(t, "1 Main St", a.Floors[0].Address)
+		assert.Empty(t, a.Floors[0].DownloadURL)
+	}
+
+	for _, bad := range []string{
+		"name\nb1|~|w1\n",
+		"name\n== Bookings(id|~|workspace_id)\nb1\n",
+		"name\n== Bookings(id|~|start_date)\nb1|~|yesterday\n",
+		"name\n== Bookings id\n",
+	} {
+		_, err = Parse(strings.NewReader(bad))
+		_, isParseError := err.(*ParseError)
+		assert.True(t, isParseError, bad)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	booking := &model.Booking{ID: "b1", WorkspaceID: "w1", UserID: "bob", CreatedBy: "alice", StartDate: monday, EndDate: tuesday}
+	offering := &model.Offering{ID: "o1", WorkspaceID: "w1", UserID: "alice", CreatedBy: "alice", StartDate: monday, EndDate: tuesday, Cancelled: true}
+	assignment := &model.Assignment{ID: "a1", WorkspaceID: "w1", UserID: "alice", StartDate: monday, EndDate: tuesday}
+	workspace := &model.Workspace{ID: "w1", Name: "Desk 1", Floor: "f1", Props: model.Attrs{"standing": "yes"}}
+	floor := &model.Floor{ID: "f1", Name: "Floor 1", Address: "1 Main St", DownloadURL: "/floor-plans/1.png"}
+	text := strings.Join([]string{
+		"archive.archive",
+		OfferingsHeader, FormatOffering(offering),
+		BookingsHeader, FormatBooking(booking),
+		AssignmentsHeader, FormatAssignment(assignment),
+		WorkspacesHeader, FormatWorkspace(workspace),
+		FloorsHeader, FormatFloor(floor),
+	}, "\n")
+
+	a, err := Parse(strings.NewReader(text))
+	require.NoError(t, err)
+	assert.Equal(t, []*model.Booking{booking}, a.Bookings)
+	assert.Equal(t, []*model.Offering{offering}, a.Offerings)
+	assert.Equal(t, []*model.Assignment{assignment}, a.Assignments)
+	assert.Equal(t, []*model.Workspace{workspace}, a.Workspaces)
+	assert.Equal(t, []*model.Floor{floor}, a.Floors)
+}
+
+func TestSelect(t *testing.T) {
+	a, err := Parse(strings.NewReader(legacy))
+	require.NoError(t, err)
+	a = Merge(a, a)
+
+	rows := a.Select(&Filter{UserID: "carol"})
+	assert.Len(t, rows.Bookings, 2, "merged archives keep both copies")
+	assert.Empty(t, rows.Offerings)
+	assert.Len(t, rows.Workspaces, 1, "the archived workspace of the booking, once")
+	assert.Len(t, rows.Floors, 1, "the archived floor of the workspace, once")
+
+	rows = a.Select(&Filter{Start: tuesday, End: tuesday.Add(time.Hour)})
+	assert.Len(t, rows.Bookings, 2)
+	assert.Len(t, rows.Offerings, 2, "the offering runs all week")
+
+	rows = a.Select(&Filter{IDs: []string{"b1", "f1"}})
+	assert.Len(t, rows.Bookings, 2)
+	assert.Empty(t, rows.Workspaces, "w1 was not archived")
+	assert.Len(t, rows.Floors, 1, "picked by id")
+
+	rows = a.Select(&Filter{UserID: "bob", IDs: []string{"b2"}})
+	assert.Empty(t, rows.Bookings)
+}
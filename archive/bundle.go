@@ -0,0 +1,254 @@
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go-api/model"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SchemaVersion is the version of the manifest and section files bundles are written with. Bump it when a change
+// would stop older code from reading new bundles; Read refuses bundles newer than it understands
+const SchemaVersion = 1
+
+const (
+	TextFormat      = "text"
+	JSONLinesFormat = "jsonl"
+	CSVFormat       = "csv"
+	// DefaultFormat is the format archives are written in unless another one is asked for
+	DefaultFormat = JSONLinesFormat
+
+	manifestFile = "manifest.json"
+)
+
+// Format writes archives of one kind
+type Format interface {
+	// Extension is the extension of the archive's file name
+	Extension() string
+	ContentType() string
+	Write(w io.Writer, name string, rows *model.ArchivedRows) error
+}
+
+// Encoder writes and reads the file of one section of a bundle
+type Encoder interface {
+	// Extension is the extension of the section files, e.g. ".csv"
+	Extension() string
+	Encode(w io.Writer, section string, rows *model.ArchivedRows) error
+	// Decode adds the rows of the section in r to rows
+	Decode(r io.Reader, section string, rows *model.ArchivedRows) error
+}
+
+var (
+	formats  = map[string]Format{TextFormat: text{}}
+	encoders = map[string]Encoder{}
+)
+
+func init() {
+	RegisterEncoder(JSONLinesFormat, jsonLines{})
+	RegisterEncoder(CSVFormat, csvEncoder{})
+}
+
+// RegisterEncoder makes bundles with a file per section encoded by encoder available as format
+func RegisterEncoder(format string, encoder Encoder) {
+	encoders[format] = encoder
+	formats[format] = bundle{format: format, encoder: encoder}
+}
+
+// Lookup returns the format called name
+func Lookup(name string) (Format, error) {
+	format, ok := formats[name]
+	if !ok {
+		return nil, fmt.Errorf("archive: unknown format %q, expected one of %s", name, strings.Join(Formats(), ", "))
+	}
+	return format, nil
+}
+
+// Formats returns the names of the formats archives can be written in
+func Formats() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Manifest describes the content of a bundle
+type Manifest struct {
+	SchemaVersion int       `json:"schema_version"`
+	Name          string    `json:"name"`
+	Format        string    `json:"format"`
+	CreatedAt     time.Time `json:"created_at"`
+	// Start and End are the earliest start and latest end of the archived bookings, offerings and assignments
+	Start    *time.Time         `json:"start,omitempty"`
+	End      *time.Time         `json:"end,omitempty"`
+	Sections []*ManifestSection `json:"sections"`
+}
+
+type ManifestSection struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+	Rows int    `json:"rows"`
+}
+
+func (m *Manifest) section(file string) *ManifestSection {
+	for _, section := range m.Sections {
+		if section.File == file {
+			return section
+		}
+	}
+	return nil
+}
+
+// bundle is a tar file holding the manifest followed by a file per section
+type bundle struct {
+	format  string
+	encoder Encoder
+}
+
+func (b bundle) Extension() string {
+	return ".tar"
+}
+
+func (b bundle) ContentType() string {
+	return "application/x-tar"
+}
+
+func (b bundle) Write(w io.Writer, name string, rows *model.ArchivedRows) error {
+	now := time.Now().UTC()
+	manifest := &Manifest{
+		SchemaVersion: SchemaVersion,
+		Name:          name,
+		Format:        b.format,
+		CreatedAt:     now,
+		Sections:      make([]*ManifestSection, 0, len(Sections)),
+	}
+	manifest.Start, manifest.End = timeRange(rows)
+	files := make([][]byte, 0, len(Sections))
+	for _, section := range Sections {
+		content := new(bytes.Buffer)
+		if err := b.encoder.Encode(content, section, rows); err != nil {
+			return fmt.Errorf("archive: encoding %s: %w", section, err)
+		}
+		files = append(files, content.Bytes())
+		manifest.Sections = append(manifest.Sections, &ManifestSection{
+			Name: section,
+			File: strings.ToLower(section) + b.encoder.Extension(),
+			Rows: len(items(section, rows)),
+		})
+	}
+	manifestContent, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	writeFile := func(name string, content []byte) error {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), ModTime: now, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(content)
+		return err
+	}
+	// the manifest goes first so that readers know how to decode the files that follow
+	if err = writeFile(manifestFile, manifestContent); err != nil {
+		return err
+	}
+	for i, section := range manifest.Sections {
+		if err = writeFile(section.File, files[i]); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// timeRange returns the earliest start and the latest end of the rows that have them, or nils if there are none
+func timeRange(rows *model.ArchivedRows) (*time.Time, *time.Time) {
+	var start, end *time.Time
+	add := func(s, e time.Time) {
+		if start == nil || s.Before(*start) {
+			start = &s
+		}
+		if end == nil || e.After(*end) {
+			end = &e
+		}
+	}
+	for _, o := range rows.Offerings {
+		add(o.StartDate, o.EndDate)
+	}
+	for _, b := range rows.Bookings {
+		add(b.StartDate, b.EndDate)
+	}
+	for _, a := range rows.Assignments {
+		add(a.StartDate, a.EndDate)
+	}
+	return start, end
+}
+
+// Read reads an archive in any format
+func Read(r io.Reader) (*Archive, error) {
+	buf := bufio.NewReader(r)
+	// tar headers have "ustar" at offset 257, which no text archive has
+	magic, _ := buf.Peek(262)
+	if len(magic) < 262 || string(magic[257:262]) != "ustar" {
+		return Parse(buf)
+	}
+	return readBundle(buf)
+}
+
+func readBundle(r io.Reader) (*Archive, error) {
+	tr := tar.NewReader(r)
+	header, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("archive: reading bundle: %w", err)
+	}
+	if header.Name != manifestFile {
+		return nil, fmt.Errorf("archive: bundle starts with %s instead of %s", header.Name, manifestFile)
+	}
+	content, err := ioutil.ReadAll(tr)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{}
+	if err = json.Unmarshal(content, manifest); err != nil {
+		return nil, fmt.Errorf("archive: reading manifest: %w", err)
+	}
+	if manifest.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("archive: schema version %d is newer than the supported %d", manifest.SchemaVersion, SchemaVersion)
+	}
+	encoder, ok := encoders[manifest.Format]
+	if !ok {
+		return nil, fmt.Errorf("archive: no encoder for format %q", manifest.Format)
+	}
+
+	archive := &Archive{Name: manifest.Name, Manifest: manifest, ArchivedRows: newRows()}
+	for {
+		header, err = tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("archive: reading bundle: %w", err)
+		}
+		section := manifest.section(header.Name)
+		if section == nil {
+			// files of sections this version doesn't know about
+			continue
+		}
+		if err = encoder.Decode(tr, section.Name, &archive.ArchivedRows); err != nil {
+			return nil, fmt.Errorf("archive: decoding %s: %w", section.File, err)
+		}
+		if rows := len(items(section.Name, &archive.ArchivedRows)); rows != section.Rows {
+			return nil, fmt.Errorf("archive: %s has %d rows, the manifest says %d", section.File, rows, section.Rows)
+		}
+	}
+	return archive, nil
+}
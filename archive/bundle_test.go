@@ -0,0 +1,85 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-api/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBundle(t *testing.T) {
+	checkedIn := monday.Add(5*time.Minute + 123456*time.Microsecond)
+	rows := &model.ArchivedRows{
+		Offerings: []*model.Offering{{ID: "o1", WorkspaceID: "w1", UserID: "alice", CreatedBy: "alice", StartDate: monday, EndDate: tuesday, Cancelled: true, SeriesID: "os1"}},
+		Bookings: []*model.Booking{
+			{ID: "b1", WorkspaceID: "w1", UserID: "bob", CreatedBy: "alice", StartDate: monday, EndDate: monday.Add(8 * time.Hour), SeriesID: "bs1", CheckedInAt: &checkedIn},
+			{ID: "b2", WorkspaceID: "w1", UserID: "bob", CreatedBy: "bob", StartDate: tuesday, EndDate: tuesday.Add(8 * time.Hour), Cancelled: true, NoShow: true},
+		},
+		Assignments: []*model.Assignment{{ID: "a1", WorkspaceID: "w1", UserID: "alice", StartDate: monday, EndDate: tuesday}},
+		// values the text format can't hold
+		Workspaces: []*model.Workspace{{ID: "w1", Name: "Desk, 1", Floor: "f1", Details: "by the window\n|~| \"quiet\"", Props: model.Attrs{"note": "a\nb"}}},
+		Floors:     []*model.Floor{{ID: "f1", Name: "Floor 1", Address: "1 Main St\nTown", DownloadURL: "/floor-plans/1.png"}},
+	}
+
+	for _, name := range []string{JSONLinesFormat, CSVFormat} {
+		t.Run(name, func(t *testing.T) {
+			format, err := Lookup(name)
+			require.NoError(t, err)
+			content := new(bytes.Buffer)
+			require.NoError(t, format.Write(content, "archive-1"+format.Extension(), rows))
+
+			a, err := Read(bytes.NewReader(content.Bytes()))
+			require.NoError(t, err)
+			assert.Equal(t, "archive-1.tar", a.Name)
+			if assert.NotNil(t, a.Manifest) {
+				assert.Equal(t, SchemaVersion, a.Manifest.SchemaVersion)
+				assert.Equal(t, name, a.Manifest.Format)
+				assert.True(t, monday.Equal(*a.Manifest.Start), "earliest start")
+				assert.True(t, tuesday.Add(8*time.Hour).Equal(*a.Manifest.End), "latest end")
+				if assert.Len(t, a.Manifest.Sections, len(Sections)) {
+					assert.Equal(t, BookingsSection, a.Manifest.Sections[1].Name)
+					assert.Equal(t, 2, a.Manifest.Sections[1].Rows)
+				}
+			}
+			assert.Equal(t, rows.Offerings, a.Offerings)
+			assert.Equal(t, rows.Bookings, a.Bookings)
+			assert.Equal(t, rows.Assignments, a.Assignments)
+			assert.Equal(t, rows.Workspaces, a.Workspaces)
+			assert.Equal(t, rows.Floors, a.Floors)
+		})
+	}
+
+	_, err := Lookup("parquet")
+	assert.Error(t, err)
+
+	format, err := Lookup(TextFormat)
+	require.NoError(t, err)
+	content := new(bytes.Buffer)
+	require.NoError(t, format.Write(content, "archive-1.archive", &model.ArchivedRows{Bookings: rows.Bookings}))
+	a, err := Read(content)
+	require.NoError(t, err, "text archives are read too")
+	assert.Nil(t, a.Manifest)
+	assert.Equal(t, rows.Bookings, a.Bookings)
+
+	a, err = Read(strings.NewReader(legacy))
+	require.NoError(t, err)
+	assert.Len(t, a.Bookings, 2)
+}
+
+func TestBundleSchemaVersion(t *testing.T) {
+	manifest, _ := json.Marshal(&Manifest{SchemaVersion: SchemaVersion + 1, Format: CSVFormat})
+	content := new(bytes.Buffer)
+	tw := tar.NewWriter(content)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: manifestFile, Mode: 0644, Size: int64(len(manifest))}))
+	_, err := tw.Write(manifest)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	_, err = Read(content)
+	assert.Error(t, err, "bundles from a newer version are refused")
+}
@@ -0,0 +1,136 @@
+package archive
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go-api/model"
+	"io"
+)
+
+// jsonLines writes a section as gzipped JSON Lines: a JSON object per row, as the API returns it
+type jsonLines struct{}
+
+func (jsonLines) Extension() string {
+	return ".jsonl.gz"
+}
+
+func (jsonLines) Encode(w io.Writer, section string, rows *model.ArchivedRows) error {
+	gz := gzip.NewWriter(w)
+	encoder := json.NewEncoder(gz)
+	for _, item := range items(section, rows) {
+		if err := encoder.Encode(item); err != nil {
+			return err
+		}
+	}
+	return gz.Close()
+}
+
+func (jsonLines) Decode(r io.Reader, section string, rows *model.ArchivedRows) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	decoder := json.NewDecoder(gz)
+	for n := 1; ; n++ {
+		item := newItem(section)
+		if item == nil {
+			return fmt.Errorf("unknown section %s", section)
+		}
+		if err = decoder.Decode(item); err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("row %d: %w", n, err)
+		}
+		appendItem(rows, item)
+	}
+}
+
+// csvEncoder writes a section as RFC 4180 CSV with a header record naming the columns
+type csvEncoder struct{}
+
+func (csvEncoder) Extension() string {
+	return ".csv"
+}
+
+func (csvEncoder) Encode(w io.Writer, section string, rows *model.ArchivedRows) error {
+	writer := csv.NewWriter(w)
+	writer.UseCRLF = true
+	if err := writer.Write(columns[section]); err != nil {
+		return err
+	}
+	for _, item := range items(section, rows) {
+		if err := writer.Write(values(item)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (csvEncoder) Decode(r io.Reader, section string, rows *model.ArchivedRows) error {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for n := 1; ; n++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// like text archives, columns are matched by name
+		r := make(row, len(header))
+		for i, column := range header {
+			r[column] = record[i]
+		}
+		if err = add(rows, section, r); err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+	}
+}
+
+// newItem returns an empty row of section to decode into, or nil for an unknown section
+func newItem(section string) interface{} {
+	switch section {
+	case OfferingsSection:
+		return &model.Offering{}
+	case BookingsSection:
+		return &model.Booking{}
+	case AssignmentsSection:
+		return &model.Assignment{}
+	case WorkspacesSection:
+		return &model.Workspace{}
+	case FloorsSection:
+		return &model.Floor{}
+	}
+	return nil
+}
+
+// appendItem adds item, a row of any section, to rows
+func appendItem(rows *model.ArchivedRows, item interface{}) {
+	switch v := item.(type) {
+	case *model.Offering:
+		rows.Offerings = append(rows.Offerings, v)
+	case *model.Booking:
+		rows.Bookings = append(rows.Bookings, v)
+	case *model.Assignment:
+		rows.Assignments = append(rows.Assignments, v)
+	case *model.Workspace:
+		if v.Props == nil {
+			v.Props = model.Attrs{}
+		}
+		rows.Workspaces = append(rows.Workspaces, v)
+	case *model.Floor:
+		rows.Floors = append(rows.Floors, v)
+	}
+}
@@ -0,0 +1,108 @@
+package archive
+
+import (
+	"go-api/model"
+	"time"
+)
+
+// Filter picks archived rows; zero fields match every row
+type Filter struct {
+	// IDs matches rows of any kind by id
+	IDs    []string
+	UserID string
+	// Start and End match the bookings, offerings and assignments overlapping them
+	Start time.Time
+	End   time.Time
+}
+
+func (f *Filter) matchID(id string) bool {
+	if len(f.IDs) == 0 {
+		return true
+	}
+	for _, match := range f.IDs {
+		if match == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Filter) match(id, userId string, start, end time.Time) bool {
+	if !f.matchID(id) || (f.UserID != "" && f.UserID != userId) {
+		return false
+	}
+	if !f.Start.IsZero() && end.Before(f.Start) {
+		return false
+	}
+	if !f.End.IsZero() && start.After(f.End) {
+		return false
+	}
+	return true
+}
+
+// onlyIDs is true if the filter picks rows by id alone. Workspaces and floors have no user or time, so other
+// filters only pick them as the parents of picked rows
+func (f *Filter) onlyIDs() bool {
+	return f.UserID == "" && f.Start.IsZero() && f.End.IsZero()
+}
+
+// Merge puts the rows of archives together, e.g. to search every archive at once
+func Merge(archives ...*Archive) *Archive {
+	merged := &Archive{}
+	for _, a := range archives {
+		merged.Offerings = append(merged.Offerings, a.Offerings...)
+		merged.Bookings = append(merged.Bookings, a.Bookings...)
+		merged.Assignments = append(merged.Assignments, a.Assignments...)
+		merged.Workspaces = append(merged.Workspaces, a.Workspaces...)
+		merged.Floors = append(merged.Floors, a.Floors...)
+	}
+	return merged
+}
+
+// Select returns the rows of a matching filter, along with the archived workspaces and floors they belong to so
+// that they can be restored together
+func (a *Archive) Select(filter *Filter) *model.ArchivedRows {
+	selected := &model.ArchivedRows{
+		Offerings:   make([]*model.Offering, 0),
+		Bookings:    make([]*model.Booking, 0),
+		Assignments: make([]*model.Assignment, 0),
+		Workspaces:  make([]*model.Workspace, 0),
+		Floors:      make([]*model.Floor, 0),
+	}
+	workspaceIds := make(map[string]bool)
+	for _, o := range a.Offerings {
+		if filter.match(o.ID, o.UserID, o.StartDate, o.EndDate) {
+			selected.Offerings = append(selected.Offerings, o)
+			workspaceIds[o.WorkspaceID] = true
+		}
+	}
+	for _, b := range a.Bookings {
+		if filter.match(b.ID, b.UserID, b.StartDate, b.EndDate) {
+			selected.Bookings = append(selected.Bookings, b)
+			workspaceIds[b.WorkspaceID] = true
+		}
+	}
+	for _, as := range a.Assignments {
+		if filter.match(as.ID, as.UserID, as.StartDate, as.EndDate) {
+			selected.Assignments = append(selected.Assignments, as)
+			workspaceIds[as.WorkspaceID] = true
+		}
+	}
+	// a row can be in more than one archive if it was restored and archived again; restore it once
+	picked := make(map[string]bool)
+	floorIds := make(map[string]bool)
+	for _, w := range a.Workspaces {
+		if !picked[w.ID] && (workspaceIds[w.ID] || (filter.onlyIDs() && filter.matchID(w.ID))) {
+			selected.Workspaces = append(selected.Workspaces, w)
+			floorIds[w.Floor] = true
+			picked[w.ID] = true
+		}
+	}
+	for _, f := range a.Floors {
+		if !picked[f.ID] && (floorIds[f.ID] || (filter.onlyIDs() && filter.matchID(f.ID))) {
+			selected.Floors = append(selected.Floors, f)
+			picked[f.ID] = true
+		}
+	}
+	return selected
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"go-api/model"
+	"net/http"
+)
+
+var NoCredentialsError = errors.New("no credentials")
+var InvalidCredentialsError = errors.New("invalid credentials")
+
+// UserLookup resolves the id carried by a token or session to a user
+type UserLookup func(id string) (*model.User, error)
+
+type Authenticator interface {
+	Authenticate(r *http.Request) (*model.User, error)
+}
+
+// Chain tries each authenticator in order; the first one that finds credentials on the request decides the outcome
+type Chain []Authenticator
+
+func (c Chain) Authenticate(r *http.Request) (*model.User, error) {
+	for _, a := range c {
+		user, err := a.Authenticate(r)
+		if err == NoCredentialsError {
+			continue
+		}
+		return user, err
+	}
+	return nil, NoCredentialsError
+}
+
+type contextKey int
+
+const userContextKey contextKey = iota
+
+func WithUser(ctx context.Context, user *model.User) context.Context {
+	return context.WithValue(ctx, userContextKey, user)
+}
+
+func UserFromContext(ctx context.Context) (*model.User, bool) {
+	user, ok := ctx.Value(userContextKey).(*model.User)
+	return user, ok && user != nil
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"go-api/model"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// FeedSuffix ends the path of every calendar feed, e.g. /users/{id}/calendar.ics
+const FeedSuffix = "/calendar.ics"
+
+// FeedAuthenticator accepts the token in the URL of a user's calendar feed, since calendar apps subscribing to a
+// feed can't log in. A token is only good for the feed of its user, /users/{id}/calendar.ics
+type FeedAuthenticator struct {
+	secret []byte
+	users  UserLookup
+}
+
+func NewFeedAuthenticator(secret string, users UserLookup) *FeedAuthenticator {
+	return &FeedAuthenticator{
+		secret: []byte(secret),
+		users:  users,
+	}
+}
+
+// Token is the token of the feed of the user with id. It stays the same until the secret changes
+func (a *FeedAuthenticator) Token(userId string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte("calendar:" + userId))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (a *FeedAuthenticator) Authenticate(r *http.Request) (*model.User, error) {
+	token := r.URL.Query().Get("token")
+	if token == "" || !strings.HasPrefix(r.URL.Path, "/users/") || !strings.HasSuffix(r.URL.Path, FeedSuffix) {
+		return nil, NoCredentialsError
+	}
+	userId := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/users/"), FeedSuffix)
+	if !hmac.Equal([]byte(token), []byte(a.Token(userId))) {
+		return nil, InvalidCredentialsError
+	}
+	user, err := a.users(userId)
+	if err != nil {
+		log.Printf("FeedAuthenticator.Authenticate: unknown user %s, %v\n", userId, err)
+		return nil, InvalidCredentialsError
+	}
+	return user, nil
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go-api/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFeedAuthenticator(t *testing.T) {
+	users := func(id string) (*model.User, error) {
+		if id == barryUser.ID {
+			return barryUser, nil
+		}
+		return nil, errors.New("not found")
+	}
+	feeds := NewFeedAuthenticator("secret", users)
+	token := feeds.Token(barryUser.ID)
+	assert.NotEqual(t, token, NewFeedAuthenticator("other secret", users).Token(barryUser.ID))
+
+	user, err := feeds.Authenticate(httptest.NewRequest(http.MethodGet, "/users/barry/calendar.ics?token="+token, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, barryUser, user)
+
+	_, err = feeds.Authenticate(httptest.NewRequest(http.MethodGet, "/users/clark/calendar.ics?token="+token, nil))
+	assert.Equal(t, InvalidCredentialsError, err, "only the feed of the token's user")
+	_, err = feeds.Authenticate(httptest.NewRequest(http.MethodGet, "/bookings/users/barry?token="+token, nil))
+	assert.Equal(t, NoCredentialsError, err, "only feeds")
+	_, err = feeds.Authenticate(httptest.NewRequest(http.MethodGet, "/users/barry/calendar.ics", nil))
+	assert.Equal(t, NoCredentialsError, err)
+	_, err = feeds.Authenticate(httptest.NewRequest(http.MethodGet, "/users/clark/calendar.ics?token="+feeds.Token("clark"), nil))
+	assert.Equal(t, InvalidCredentialsError, err, "unknown user")
+}
@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-api/model"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const azureADAuthority = "https://login.microsoftonline.com"
+const clockSkew = 2 * time.Minute
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt int64           `json:"exp"`
+	NotBefore int64           `json:"nbf"`
+	ObjectId  string          `json:"oid"`
+}
+
+func (c *jwtClaims) hasAudience(audiences []string) bool {
+	var tokenAudiences []string
+	var single string
+	if err := json.Unmarshal(c.Audience, &single); err == nil {
+		tokenAudiences = []string{single}
+	} else if err := json.Unmarshal(c.Audience, &tokenAudiences); err != nil {
+		return false
+	}
+	for _, expected := range audiences {
+		for _, aud := range tokenAudiences {
+			if aud == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// JWTAuthenticator validates RS256 bearer tokens and resolves the user from the oid claim
+type JWTAuthenticator struct {
+	keys      KeySet
+	issuer    string
+	audiences []string
+	users     UserLookup
+	now       func() time.Time
+}
+
+func NewJWTAuthenticator(keys KeySet, issuer string, audiences []string, users UserLookup) *JWTAuthenticator {
+	return &JWTAuthenticator{
+		keys:      keys,
+		issuer:    issuer,
+		audiences: audiences,
+		users:     users,
+		now:       time.Now,
+	}
+}
+
+// NewAzureADAuthenticator validates v2.0 access tokens issued by the given tenant for the given app registration
+func NewAzureADAuthenticator(tenantId, clientId string, users UserLookup) *JWTAuthenticator {
+	keys := NewRemoteKeySet(fmt.Sprintf("%s/%s/discovery/v2.0/keys", azureADAuthority, tenantId))
+	issuer := fmt.Sprintf("%s/%s/v2.0", azureADAuthority, tenantId)
+	return NewJWTAuthenticator(keys, issuer, []string{clientId, "api://" + clientId}, users)
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*model.User, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return nil, NoCredentialsError
+	}
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return nil, NoCredentialsError
+	}
+	claims, err := a.verify(strings.TrimSpace(parts[1]))
+	if err != nil {
+		log.Printf("JWTAuthenticator.Authenticate: %v\n", err)
+		return nil, InvalidCredentialsError
+	}
+	if claims.ObjectId == "" {
+		log.Println("JWTAuthenticator.Authenticate: token has no oid claim")
+		return nil, InvalidCredentialsError
+	}
+	user, err := a.users(claims.ObjectId)
+	if err != nil {
+		log.Printf("JWTAuthenticator.Authenticate: unknown user %s, %v\n", claims.ObjectId, err)
+		return nil, InvalidCredentialsError
+	}
+	return user, nil
+}
+
+func (a *JWTAuthenticator) verify(token string) (*jwtClaims, error) {
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header jwtHeader
+	if err := decodeSegment(segments[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", header.Alg)
+	}
+	key, err := a.keys.Key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(segments[2])
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(segments[0] + "." + segments[1]))
+	if err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, err
+	}
+
+	var claims jwtClaims
+	if err = decodeSegment(segments[1], &claims); err != nil {
+		return nil, err
+	}
+	now := a.now()
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)) {
+		return nil, errors.New("token expired")
+	}
+	if claims.NotBefore != 0 && now.Add(clockSkew).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, errors.New("token not valid yet")
+	}
+	if claims.Issuer != a.issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if !claims.hasAudience(a.audiences) {
+		return nil, errors.New("unexpected audience")
+	}
+	return &claims, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go-api/model"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "https://login.microsoftonline.com/tenant/v2.0"
+	testAudience = "client-id"
+	testKid      = "test-key"
+	testUserId   = "e99a988a-1d41-3997-8d59-959a48ac24a0"
+)
+
+var testUser = &model.User{ID: testUserId, Name: "Barry Allen", Department: "R&D"}
+
+func lookupTestUser(id string) (*model.User, error) {
+	if id == testUserId {
+		return testUser, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(raw)
+	}
+	signingInput := encode(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss": testIssuer,
+		"aud": testAudience,
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"nbf": time.Now().Add(-time.Minute).Unix(),
+		"oid": testUserId,
+	}
+}
+
+func bearerRequest(token string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/bookings", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func newTestKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	key := newTestKey(t)
+	otherKey := newTestKey(t)
+	authenticator := NewJWTAuthenticator(
+		StaticKeySet{testKid: &key.PublicKey},
+		testIssuer,
+		[]string{testAudience},
+		lookupTestUser,
+	)
+
+	user, err := authenticator.Authenticate(bearerRequest(signToken(t, key, testKid, validClaims())))
+	assert.NoError(t, err)
+	assert.Equal(t, testUser, user)
+
+	claims := validClaims()
+	claims["aud"] = []string{"someone-else", testAudience}
+	_, err = authenticator.Authenticate(bearerRequest(signToken(t, key, testKid, claims)))
+	assert.NoError(t, err, "audience arrays should be accepted")
+
+	cases := map[string]func(claims map[string]interface{}){
+		"expired":       func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"not yet valid": func(c map[string]interface{}) { c["nbf"] = time.Now().Add(time.Hour).Unix() },
+		"wrong issuer":  func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" },
+		"wrong aud":     func(c map[string]interface{}) { c["aud"] = "someone-else" },
+		"unknown user":  func(c map[string]interface{}) { c["oid"] = "00000000-0000-0000-0000-000000000000" },
+		"no oid":        func(c map[string]interface{}) { delete(c, "oid") },
+	}
+	for name, mutate := range cases {
+		claims := validClaims()
+		mutate(claims)
+		_, err := authenticator.Authenticate(bearerRequest(signToken(t, key, testKid, claims)))
+		assert.Equal(t, InvalidCredentialsError, err, name)
+	}
+
+	_, err = authenticator.Authenticate(bearerRequest(signToken(t, otherKey, testKid, validClaims())))
+	assert.Equal(t, InvalidCredentialsError, err, "bad signature")
+
+	_, err = authenticator.Authenticate(bearerRequest(signToken(t, key, "other-kid", validClaims())))
+	assert.Equal(t, InvalidCredentialsError, err, "unknown kid")
+
+	_, err = authenticator.Authenticate(bearerRequest("not-a-token"))
+	assert.Equal(t, InvalidCredentialsError, err, "malformed")
+
+	_, err = authenticator.Authenticate(httptest.NewRequest(http.MethodGet, "/bookings", nil))
+	assert.Equal(t, NoCredentialsError, err, "missing header")
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	key := newTestKey(t)
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"use": "sig",
+				"kid": testKid,
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	defer server.Close()
+
+	keys := NewRemoteKeySet(server.URL)
+	authenticator := NewJWTAuthenticator(keys, testIssuer, []string{testAudience}, lookupTestUser)
+	for i := 0; i < 3; i++ {
+		_, err := authenticator.Authenticate(bearerRequest(signToken(t, key, testKid, validClaims())))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, fetches, "keys should be cached")
+
+	_, err := keys.Key("rotated")
+	assert.Equal(t, UnknownKeyError, err)
+	assert.Equal(t, 1, fetches, "unknown kids should not hammer the key endpoint")
+}
+
+type stubAuthenticator struct {
+	user *model.User
+	err  error
+}
+
+func (s stubAuthenticator) Authenticate(r *http.Request) (*model.User, error) {
+	return s.user, s.err
+}
+
+func TestChain(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	user, err := Chain{stubAuthenticator{err: NoCredentialsError}, stubAuthenticator{user: testUser}}.Authenticate(req)
+	assert.NoError(t, err)
+	assert.Equal(t, testUser, user)
+
+	_, err = Chain{stubAuthenticator{err: InvalidCredentialsError}, stubAuthenticator{user: testUser}}.Authenticate(req)
+	assert.Equal(t, InvalidCredentialsError, err, "a rejected token must not fall through")
+
+	_, err = Chain{stubAuthenticator{err: NoCredentialsError}}.Authenticate(req)
+	assert.Equal(t, NoCredentialsError, err)
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var UnknownKeyError = errors.New("unknown signing key")
+
+const keyRefreshInterval = 5 * time.Minute
+
+type KeySet interface {
+	Key(kid string) (*rsa.PublicKey, error)
+}
+
+// StaticKeySet is a fixed set of signing keys, useful for tests and local development
+type StaticKeySet map[string]*rsa.PublicKey
+
+func (s StaticKeySet) Key(kid string) (*rsa.PublicKey, error) {
+	key, ok := s[kid]
+	if !ok {
+		return nil, UnknownKeyError
+	}
+	return key, nil
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// RemoteKeySet fetches a JWKS document and caches the keys, re-fetching when an unknown kid shows up
+type RemoteKeySet struct {
+	url         string
+	client      *http.Client
+	mu          sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	lastFetched time.Time
+}
+
+func NewRemoteKeySet(url string) *RemoteKeySet {
+	return &RemoteKeySet{
+		url: url,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+func (s *RemoteKeySet) Key(kid string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+	// Keys rotate; only go back to the network if we haven't just done so
+	if time.Since(s.lastFetched) < keyRefreshInterval && s.keys != nil {
+		return nil, UnknownKeyError
+	}
+	keys, err := s.fetch()
+	if err != nil {
+		return nil, err
+	}
+	s.keys = keys
+	s.lastFetched = time.Now()
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, UnknownKeyError
+}
+
+func (s *RemoteKeySet) fetch() (map[string]*rsa.PublicKey, error) {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("incorrect response code while fetching signing keys, received %d", resp.StatusCode)
+	}
+	var set jsonWebKeySet
+	if err = json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		key, err := parseRSAKey(k.N, k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func parseRSAKey(n, e string) (*rsa.PublicKey, error) {
+	modulus, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, err
+	}
+	exponent, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(modulus),
+		E: int(new(big.Int).SetBytes(exponent).Int64()),
+	}, nil
+}
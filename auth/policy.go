@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"go-api/model"
+	"io/ioutil"
+	"log"
+	"net/http"
+)
+
+var ForbiddenError = errors.New("forbidden")
+
+// maxBodySize caps the request bodies BodyOwnerOrAdmin reads before the handler does
+const maxBodySize = 1 << 20
+
+// Policy decides whether an authenticated user may perform a request; it returns ForbiddenError when they may not
+type Policy func(user *model.User, r *http.Request) error
+
+// OwnerLookup returns the ids of the users who own the resource with the given id
+type OwnerLookup func(id string) ([]string, error)
+
+func Authorize(user *model.User, r *http.Request, policies ...Policy) error {
+	if user == nil {
+		return ForbiddenError
+	}
+	for _, policy := range policies {
+		if err := policy(user, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func AdminOnly(user *model.User, r *http.Request) error {
+	if !user.IsAdmin {
+		return ForbiddenError
+	}
+	return nil
+}
+
+// SelfOrAdmin lets admins through and otherwise only lets users act on the path variable that holds their own id
+func SelfOrAdmin(param string) Policy {
+	return func(user *model.User, r *http.Request) error {
+		if !user.IsAdmin && mux.Vars(r)[param] != user.ID {
+			return ForbiddenError
+		}
+		return nil
+	}
+}
+
+// OwnerOrAdmin lets admins through and otherwise requires the user to own the resource named by the path variable
+func OwnerOrAdmin(param string, owners OwnerLookup) Policy {
+	return func(user *model.User, r *http.Request) error {
+		if user.IsAdmin {
+			return nil
+		}
+		ownerIds, err := owners(mux.Vars(r)[param])
+		if err != nil {
+			log.Printf("auth.OwnerOrAdmin: error looking up owners, %+v\n", err)
+			return ForbiddenError
+		}
+		for _, id := range ownerIds {
+			if id == user.ID {
+				return nil
+			}
+		}
+		return ForbiddenError
+	}
+}
+
+// BodyOwnerOrAdmin lets admins through and otherwise requires the JSON body field to hold the user's own id; bodies
+// over maxBodySize are refused
+func BodyOwnerOrAdmin(field string) Policy {
+	return func(user *model.User, r *http.Request) error {
+		if user.IsAdmin {
+			return nil
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodySize))
+		if err != nil {
+			return ForbiddenError
+		}
+		// Put the body back for the handler
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		var fields map[string]interface{}
+		if err = json.Unmarshal(body, &fields); err != nil {
+			return ForbiddenError
+		}
+		if id, ok := fields[field].(string); !ok || id != user.ID {
+			return ForbiddenError
+		}
+		return nil
+	}
+}
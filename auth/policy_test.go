@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go-api/model"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var (
+	adminUser = &model.User{ID: "bruce", IsAdmin: true}
+	barryUser = &model.User{ID: "barry"}
+	clarkUser = &model.User{ID: "clark"}
+)
+
+func bookingOwners(id string) ([]string, error) {
+	owners := map[string][]string{
+		"booking-1": {"barry", "barry"},
+		"booking-2": {"clark", "bruce"},
+	}
+	if o, ok := owners[id]; ok {
+		return o, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func requestWithVars(vars map[string]string) *http.Request {
+	return mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/", nil), vars)
+}
+
+func TestAdminOnly(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/users", nil)
+	assert.NoError(t, Authorize(adminUser, req, AdminOnly))
+	assert.Equal(t, ForbiddenError, Authorize(barryUser, req, AdminOnly))
+	assert.Equal(t, ForbiddenError, Authorize(nil, req, AdminOnly))
+}
+
+func TestSelfOrAdmin(t *testing.T) {
+	policy := SelfOrAdmin("user_id")
+	req := requestWithVars(map[string]string{"user_id": "barry"})
+	assert.NoError(t, Authorize(barryUser, req, policy))
+	assert.Equal(t, ForbiddenError, Authorize(clarkUser, req, policy))
+	assert.NoError(t, Authorize(adminUser, req, policy))
+}
+
+func TestOwnerOrAdmin(t *testing.T) {
+	policy := OwnerOrAdmin("id", bookingOwners)
+
+	req := requestWithVars(map[string]string{"id": "booking-1"})
+	assert.NoError(t, Authorize(barryUser, req, policy), "booker")
+	assert.Equal(t, ForbiddenError, Authorize(clarkUser, req, policy), "someone else")
+	assert.NoError(t, Authorize(adminUser, req, policy), "admin")
+
+	req = requestWithVars(map[string]string{"id": "booking-2"})
+	assert.NoError(t, Authorize(clarkUser, req, policy), "booked for")
+	assert.Equal(t, ForbiddenError, Authorize(barryUser, req, policy))
+
+	req = requestWithVars(map[string]string{"id": "missing"})
+	assert.Equal(t, ForbiddenError, Authorize(barryUser, req, policy), "lookup errors deny")
+}
+
+func TestBodyOwnerOrAdmin(t *testing.T) {
+	policy := BodyOwnerOrAdmin("user_id")
+	body := `{"user_id":"barry","workspace_id":"w1"}`
+
+	req := httptest.NewRequest(http.MethodPost, "/bookings", bytes.NewBufferString(body))
+	assert.NoError(t, Authorize(barryUser, req, policy))
+	remaining, _ := ioutil.ReadAll(req.Body)
+	assert.Equal(t, body, string(remaining), "body should still be readable by the handler")
+
+	req = httptest.NewRequest(http.MethodPost, "/bookings", bytes.NewBufferString(body))
+	assert.Equal(t, ForbiddenError, Authorize(clarkUser, req, policy))
+
+	req = httptest.NewRequest(http.MethodPost, "/bookings", bytes.NewBufferString(body))
+	assert.NoError(t, Authorize(adminUser, req, policy))
+
+	req = httptest.NewRequest(http.MethodPost, "/bookings", bytes.NewBufferString("not json"))
+	assert.Equal(t, ForbiddenError, Authorize(barryUser, req, policy))
+
+	large := `{"user_id":"barry","notes":"` + strings.Repeat("x", maxBodySize) + `"}`
+	req = httptest.NewRequest(http.MethodPost, "/bookings", bytes.NewBufferString(large))
+	assert.Equal(t, ForbiddenError, Authorize(barryUser, req, policy), "too large")
+}
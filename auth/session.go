@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"github.com/gomodule/redigo/redis"
+	"go-api/model"
+	"log"
+	"net/http"
+)
+
+const SessionTimeout = 86400 // 1 day in seconds
+const CookieSessionToken = "session_token"
+
+// SessionAuthenticator accepts the redis backed session cookie handed out by POST /login
+type SessionAuthenticator struct {
+	cache *redis.Pool
+	users UserLookup
+}
+
+func NewSessionAuthenticator(cache *redis.Pool, users UserLookup) *SessionAuthenticator {
+	return &SessionAuthenticator{
+		cache: cache,
+		users: users,
+	}
+}
+
+func (a *SessionAuthenticator) Authenticate(r *http.Request) (*model.User, error) {
+	c, err := r.Cookie(CookieSessionToken)
+	if err != nil {
+		return nil, NoCredentialsError
+	}
+	conn := a.cache.Get()
+	defer conn.Close()
+	userId, err := redis.String(conn.Do("GET", c.Value))
+	if err == redis.ErrNil {
+		return nil, InvalidCredentialsError
+	}
+	if err != nil {
+		log.Printf("SessionAuthenticator.Authenticate: couldnt get session-token, %+v\n", err)
+		return nil, err
+	}
+	// TODO logic for auto-refresh (security concern???)
+	// If TTL is < 3600s then create new sessions token and attach cookie
+	user, err := a.users(userId)
+	if err != nil {
+		log.Printf("SessionAuthenticator.Authenticate: unknown user %s, %v\n", userId, err)
+		return nil, InvalidCredentialsError
+	}
+	return user, nil
+}
@@ -0,0 +1,105 @@
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Store keeps blobs, e.g. floor plans and archives, under slash-separated keys such as "floor-plans/3.png"
+type Store interface {
+	Put(key string, contentType string, content io.Reader) error
+	// Get returns the blob stored under key, or NotFoundError; the caller closes it
+	Get(key string) (*Object, error)
+	// List returns the blobs whose key starts with prefix, ordered by key
+	List(prefix string) ([]*Info, error)
+	// Delete removes the blob stored under key; deleting a missing blob is not an error
+	Delete(key string) error
+}
+
+type Info struct {
+	Key         string
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+}
+
+type Object struct {
+	Info
+	io.ReadCloser
+}
+
+const (
+	FloorPlanPrefix = "floor-plans/"
+	ArchivePrefix   = "archive/"
+)
+
+// Backends Config.Backend selects from
+const (
+	LocalBackend  = "local"
+	S3Backend     = "s3"
+	GDriveBackend = "gdrive"
+)
+
+var NotFoundError = errors.New("blob not found")
+var InvalidKeyError = errors.New("invalid blob key")
+
+type Config struct {
+	// Backend is one of the backends above. Left empty it is GDriveBackend when GDriveCredentials are set,
+	// for deployments that predate the other backends, and LocalBackend otherwise
+	Backend string
+
+	// LocalDir is the directory the local backend keeps blobs in
+	LocalDir string
+
+	// S3Endpoint is the base URL of any S3-compatible service, e.g. https://s3.us-west-2.amazonaws.com or a MinIO server
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+
+	GDriveCredentials string
+}
+
+const DefaultLocalDir = "blobs"
+
+// New returns the Store config selects
+func New(config *Config) (Store, error) {
+	backend := config.Backend
+	if backend == "" {
+		backend = LocalBackend
+		if config.GDriveCredentials != "" {
+			backend = GDriveBackend
+		}
+	}
+	switch backend {
+	case LocalBackend:
+		dir := config.LocalDir
+		if dir == "" {
+			dir = DefaultLocalDir
+		}
+		return NewLocalStore(dir)
+	case S3Backend:
+		return NewS3Store(config.S3Endpoint, config.S3Region, config.S3Bucket, config.S3AccessKey, config.S3SecretKey)
+	case GDriveBackend:
+		return NewDriveClient(config.GDriveCredentials)
+	default:
+		return nil, fmt.Errorf("unknown blob store %q", backend)
+	}
+}
+
+// checkKey rejects keys that could escape the store, e.g. through ".." or a leading slash
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return InvalidKeyError
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return InvalidKeyError
+		}
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/net/context"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GDrive keeps blobs in Google Drive: the directory of a key is a folder in the drive's root and the rest
+// its file name, so "floor-plans/3.png" is the file 3.png in the folder floor-plans
+type GDrive struct {
+	srv *drive.Service
+}
+
+const (
+	RootFolderName = "root"
+	folderMimeType = "application/vnd.google-apps.folder"
+)
+
+var DirNotFound = errors.New("directory not found")
+
+func NewDriveClient(driveConfigJSON string) (*GDrive, error) {
+	service, err := drive.NewService(
+		context.Background(),
+		option.WithCredentialsJSON([]byte(driveConfigJSON)),
+	)
+
+	if err != nil {
+		log.Printf("Cannot create the Google Drive service: %v\n", err)
+		return nil, err
+	}
+
+	return &GDrive{
+		srv: service,
+	}, err
+}
+
+func (d GDrive) createDir(name string, parentId string) (*drive.File, error) {
+	directory, err := d.getDirectory(name)
+	if err != nil && err != DirNotFound {
+		return nil, err
+	}
+	if directory != nil {
+		return directory, nil
+	}
+	log.Println("folder doesnt exist; creating new one", directory, parentId)
+	dir := &drive.File{
+		Name:     name,
+		MimeType: folderMimeType,
+		Parents:  []string{parentId},
+	}
+
+	file, err := d.srv.Files.Create(dir).Do()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func (d GDrive) createFile(name string, mimeType string, content io.Reader, parentId string) (*drive.File, error) {
+	f := &drive.File{
+		MimeType: mimeType,
+		Name:     name,
+		Parents:  []string{parentId},
+	}
+	file, err := d.srv.Files.Create(f).Media(content).Do()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func (d GDrive) getDirectory(name string) (*drive.File, error) {
+	list, err := d.srv.Files.List().
+		Q(fmt.Sprintf("name='%s' and mimeType='%s' and trashed=false", escapeQuery(name), folderMimeType)).
+		Do()
+	if err != nil {
+		return nil, err
+	}
+	if len(list.Files) > 0 {
+		return list.Files[0], nil
+	}
+	return nil, DirNotFound
+}
+
+// getFile finds the file stored under key
+func (d GDrive) getFile(key string) (*drive.File, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	dir, err := d.getDirectory(path.Dir(key))
+	if err == DirNotFound {
+		return nil, NotFoundError
+	}
+	if err != nil {
+		return nil, err
+	}
+	list, err := d.srv.Files.List().
+		Q(fmt.Sprintf("name='%s' and '%s' in parents and trashed=false", escapeQuery(path.Base(key)), dir.Id)).
+		Fields("files(id,name,mimeType,size,modifiedTime)").
+		Do()
+	if err != nil {
+		return nil, err
+	}
+	if len(list.Files) == 0 {
+		return nil, NotFoundError
+	}
+	return list.Files[0], nil
+}
+
+func (d GDrive) Put(key string, contentType string, content io.Reader) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	if strings.Count(key, "/") != 1 {
+		return InvalidKeyError // folders are only one level deep
+	}
+	dir, err := d.createDir(path.Dir(key), RootFolderName)
+	if err != nil {
+		log.Println("Failed to create folder: " + err.Error())
+		return err
+	}
+	if _, err = d.createFile(path.Base(key), contentType, content, dir.Id); err != nil {
+		log.Printf("Could not create file: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+func (d GDrive) Get(key string) (*Object, error) {
+	file, err := d.getFile(key)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.srv.Files.Get(file.Id).Download()
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("gdrive: downloading %s: %s", key, resp.Status)
+	}
+	return &Object{Info: driveInfo(key, file), ReadCloser: resp.Body}, nil
+}
+
+func (d GDrive) List(prefix string) ([]*Info, error) {
+	list, err := d.srv.Files.List().
+		Q(fmt.Sprintf("mimeType='%s' and trashed=false", folderMimeType)).
+		Do()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]*Info, 0)
+	for _, dir := range list.Files {
+		if !strings.HasPrefix(dir.Name+"/", prefix) && !strings.HasPrefix(prefix, dir.Name+"/") {
+			continue
+		}
+		err = d.srv.Files.List().
+			Q(fmt.Sprintf("'%s' in parents and trashed=false", dir.Id)).
+			Fields("nextPageToken, files(id,name,mimeType,size,modifiedTime)").
+			Pages(context.Background(), func(files *drive.FileList) error {
+				for _, file := range files.Files {
+					if key := dir.Name + "/" + file.Name; strings.HasPrefix(key, prefix) {
+						info := driveInfo(key, file)
+						infos = append(infos, &info)
+					}
+				}
+				return nil
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+func (d GDrive) Delete(key string) error {
+	file, err := d.getFile(key)
+	if err == NotFoundError {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return d.srv.Files.Delete(file.Id).Do()
+}
+
+func driveInfo(key string, file *drive.File) Info {
+	modTime, _ := time.Parse(time.RFC3339, file.ModifiedTime)
+	return Info{Key: key, ContentType: file.MimeType, Size: file.Size, ModTime: modTime}
+}
+
+// escapeQuery escapes a value for a single-quoted string of a Drive search query
+func escapeQuery(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+}
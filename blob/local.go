@@ -0,0 +1,126 @@
+package blob
+
+import (
+	"io"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LocalStore keeps blobs as files under a directory, for development and single-server deployments
+type LocalStore struct {
+	dir string
+}
+
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+func (l *LocalStore) path(key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes the blob to a temporary file first, so that a failed upload never replaces an existing blob
+func (l *LocalStore) Put(key string, contentType string, content io.Reader) error {
+	name, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(name), ".upload-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = io.Copy(tmp, content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (l *LocalStore) Get(key string) (*Object, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, NotFoundError
+	}
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if stat.IsDir() {
+		file.Close()
+		return nil, NotFoundError
+	}
+	return &Object{Info: localInfo(key, stat), ReadCloser: file}, nil
+}
+
+func (l *LocalStore) List(prefix string) ([]*Info, error) {
+	infos := make([]*Info, 0)
+	err := filepath.Walk(l.dir, func(name string, stat os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if stat.IsDir() || strings.HasPrefix(stat.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(l.dir, name)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			info := localInfo(key, stat)
+			infos = append(infos, &info)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+func (l *LocalStore) Delete(key string) error {
+	name, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// localInfo describes a stored file; files don't keep a content type, so it is guessed from the extension
+func localInfo(key string, stat os.FileInfo) Info {
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return Info{Key: key, ContentType: contentType, Size: stat.Size(), ModTime: stat.ModTime()}
+}
@@ -0,0 +1,75 @@
+package blob
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testStore runs the behaviour every Store shares against store
+func testStore(t *testing.T, store Store) {
+	assert.NoError(t, store.Put("floor-plans/1.png", "image/png", strings.NewReader("first plan")))
+	assert.NoError(t, store.Put("floor-plans/2.png", "image/png", strings.NewReader("second plan")))
+	assert.NoError(t, store.Put("archive/1.archive", "text/plain", strings.NewReader("archive")))
+	// putting a key again replaces the blob
+	assert.NoError(t, store.Put("floor-plans/1.png", "image/png", strings.NewReader("new plan")))
+
+	object, err := store.Get("floor-plans/1.png")
+	if assert.NoError(t, err) {
+		content, _ := ioutil.ReadAll(object)
+		object.Close()
+		assert.Equal(t, "new plan", string(content))
+		assert.Equal(t, "image/png", object.ContentType)
+		assert.Equal(t, int64(len("new plan")), object.Size)
+	}
+
+	infos, err := store.List(FloorPlanPrefix)
+	if assert.NoError(t, err) && assert.Len(t, infos, 2) {
+		assert.Equal(t, "floor-plans/1.png", infos[0].Key)
+		assert.Equal(t, "floor-plans/2.png", infos[1].Key)
+	}
+
+	assert.NoError(t, store.Delete("floor-plans/1.png"))
+	assert.NoError(t, store.Delete("floor-plans/1.png"))
+	_, err = store.Get("floor-plans/1.png")
+	assert.Equal(t, NotFoundError, err)
+
+	for _, key := range []string{"", "/etc/passwd", "../outside", "floor-plans/../../outside", "floor-plans//1.png"} {
+		assert.Equal(t, InvalidKeyError, store.Put(key, "text/plain", strings.NewReader("x")), key)
+		_, err = store.Get(key)
+		assert.Equal(t, InvalidKeyError, err, key)
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, store)
+}
+
+func TestNew(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := New(&Config{LocalDir: dir})
+	assert.NoError(t, err)
+	assert.IsType(t, &LocalStore{}, store)
+
+	_, err = New(&Config{Backend: S3Backend})
+	assert.Error(t, err, "s3 needs a bucket and credentials")
+
+	_, err = New(&Config{Backend: "ftp"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,284 @@
+package blob
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Store keeps blobs in a bucket of Amazon S3 or any S3-compatible service such as MinIO. Requests use
+// path-style URLs and are signed with AWS Signature Version 4
+type S3Store struct {
+	endpoint  string
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+	now       func() time.Time
+}
+
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func NewS3Store(endpoint, region, bucket, accessKey, secretKey string) (*S3Store, error) {
+	if endpoint == "" || bucket == "" || accessKey == "" || secretKey == "" {
+		return nil, errors.New("s3: endpoint, bucket and credentials are required")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &S3Store{
+		endpoint:  strings.TrimRight(endpoint, "/"),
+		region:    region,
+		bucket:    bucket,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    &http.Client{Timeout: time.Minute},
+		now:       time.Now,
+	}, nil
+}
+
+func (s *S3Store) Put(key string, contentType string, content io.Reader) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	body, err := ioutil.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{"Content-Type": contentType}
+	resp, err := s.do(http.MethodPut, key, nil, headers, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp, http.MethodPut, key)
+	}
+	return nil
+}
+
+func (s *S3Store) Get(key string) (*Object, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	resp, err := s.do(http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, NotFoundError
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, s3Error(resp, http.MethodGet, key)
+	}
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	info := Info{
+		Key:         key,
+		ContentType: resp.Header.Get("Content-Type"),
+		Size:        resp.ContentLength,
+		ModTime:     modTime,
+	}
+	return &Object{Info: info, ReadCloser: resp.Body}, nil
+}
+
+type listBucketResult struct {
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []struct {
+		Key          string
+		LastModified time.Time
+		Size         int64
+	}
+}
+
+func (s *S3Store) List(prefix string) ([]*Info, error) {
+	infos := make([]*Info, 0)
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err = s3Error(resp, http.MethodGet, prefix)
+			resp.Body.Close()
+			return nil, err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range result.Contents {
+			infos = append(infos, &Info{Key: object.Key, Size: object.Size, ModTime: object.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+func (s *S3Store) Delete(key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	resp, err := s.do(http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return s3Error(resp, http.MethodDelete, key)
+	}
+	return nil
+}
+
+// do sends a signed request for key in the bucket, or for the bucket itself when key is empty
+func (s *S3Store) do(method, key string, query url.Values, headers map[string]string, body []byte) (*http.Response, error) {
+	path := "/" + awsEscape(s.bucket, true)
+	if key != "" {
+		path += "/" + awsEscape(key, false)
+	}
+	rawUrl := s.endpoint + path
+	if len(query) > 0 {
+		rawUrl += "?" + canonicalQuery(query)
+	}
+	req, err := http.NewRequest(method, rawUrl, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	signRequest(req, payloadHash, s.accessKey, s.secretKey, s.region, s.now())
+	return s.client.Do(req)
+}
+
+// signRequest adds the AWS Signature Version 4 Authorization header to req, signing the host and every
+// header already set
+func signRequest(req *http.Request, payloadHash, accessKey, secretKey, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+	for name := range req.Header {
+		values[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + values[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+secretKey), day)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery is the query string sorted by name with names and values escaped as AWS expects
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(query))
+	for _, name := range names {
+		values := query[name]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, awsEscape(name, true)+"="+awsEscape(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape percent-encodes everything but the unreserved characters of RFC 3986, and slashes unless encodeSlash
+func awsEscape(s string, encodeSlash bool) string {
+	var escaped strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			escaped.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			escaped.WriteByte(c)
+		default:
+			escaped.WriteString("%" + strings.ToUpper(strconv.FormatInt(int64(c)|0x100, 16)[1:]))
+		}
+	}
+	return escaped.String()
+}
+
+// s3Error reads the error document S3 responds with
+func s3Error(resp *http.Response, method, key string) error {
+	var body struct {
+		Code    string
+		Message string
+	}
+	_ = xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	return fmt.Errorf("s3: %s %s: %s %s %s", method, key, resp.Status, body.Code, body.Message)
+}
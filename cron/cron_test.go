@@ -0,0 +1,49 @@
+package cron
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8",
+		"*/0 * * * *", "5-1 * * * *", "a * * * *", "* * * * * *"} {
+		_, err := Parse(expr)
+		assert.Error(t, err, expr)
+	}
+	for _, expr := range []string{"* * * * *", "0 2 * * *", "*/15 9-17 * * mon-fri", "0 0 1,15 * *", "0 0 * jan-mar sun",
+		"5/10 * * * *", "0 0 * * 7", "@daily", "@HOURLY"} {
+		_, err := Parse(expr)
+		assert.NoError(t, err, expr)
+	}
+}
+
+func TestNext(t *testing.T) {
+	// a thursday
+	from := time.Date(2020, time.October, 15, 10, 7, 30, 0, time.UTC)
+	cases := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2020, time.October, 15, 10, 8, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2020, time.October, 16, 2, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2020, time.October, 15, 10, 15, 0, 0, time.UTC)},
+		{"5/10 * * * *", time.Date(2020, time.October, 15, 10, 15, 0, 0, time.UTC)},
+		{"30 9 * * mon", time.Date(2020, time.October, 19, 9, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2020, time.October, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2020, time.November, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		// either day field matches when both are restricted
+		{"0 0 1 * fri", time.Date(2020, time.October, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, c := range cases {
+		schedule, err := Parse(c.expr)
+		if !assert.NoError(t, err, c.expr) {
+			continue
+		}
+		assert.Equal(t, c.next, schedule.Next(from), c.expr)
+	}
+}
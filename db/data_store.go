@@ -1,14 +1,10 @@
 package db
 
 import (
-	"errors"
 	"go-api/model"
 	"time"
 )
 
-var NotFoundError = errors.New("not found")
-var EmptyError = errors.New("empty")
-
 type DataStore struct {
 	Closable
 	WorkspaceProvider workspaceProvider
@@ -17,6 +13,15 @@ type DataStore struct {
 	FloorProvider     floorProvider
 	OfferingProvider  offeringProvider
 	AssigneeProvider  assigneeProvider
+	WaitlistProvider  waitlistProvider
+	PolicyProvider    policyProvider
+	LockProvider      lockProvider
+	ArchiveProvider   archiveProvider
+	OutboxProvider    outboxProvider
+	TemplateProvider  templateProvider
+	ReminderProvider  reminderProvider
+	ChannelProvider   channelProvider
+	WebhookProvider   webhookProvider
 }
 
 type Closable interface {
@@ -51,11 +56,20 @@ type bookingProvider interface {
 	GetExpandedBookingsByUserID(id string) ([]*model.ExpandedBooking, error)
 	GetBookingsByDateRange(start time.Time, end time.Time) ([]*model.Booking, error)
 	GetExpandedBookingsByDateRange(start time.Time, end time.Time) ([]*model.ExpandedBooking, error)
+	// CreateBooking and RemoveBooking queue a confirmation or cancellation for the booker in the outbox, and copies for
+	// whoever created the booking on the booker's behalf and the owner of the offered workspace
 	CreateBooking(booking *model.Booking) (string, error)
 	UpdateBooking(id string, booking *model.Booking) error
 	RemoveBooking(id string) error
 	GetExpiredBookings(since time.Time) ([]*model.Booking, error)
 	DeleteBookings(ids []string) error
+	CreateBookingSeries(series *model.BookingSeries, occurrences []*model.Booking) ([]*model.Booking, []*model.SeriesConflict, error)
+	GetBookingSeries(id string) (*model.BookingSeries, error)
+	GetBookingsBySeriesID(id string) ([]*model.Booking, error)
+	CancelBookingSeries(id string, from time.Time) ([]*model.Booking, error)
+	CheckInBooking(id string, now time.Time, grace time.Duration) (*model.Booking, error)
+	ReleaseNoShows(now time.Time, grace time.Duration) ([]*model.Booking, error)
+	GetNoShowCounts(since time.Time, min int) ([]*model.NoShowCount, error)
 }
 
 type userProvider interface {
@@ -64,6 +78,9 @@ type userProvider interface {
 	CreateUser(user *model.User) error
 	GetAssignedUsers(start, end time.Time) ([]*model.UserAssignment, error)
 	GetAssignedUsersByTime(timestamp time.Time) ([]*model.UserAssignment, error)
+	// UpdateUserPreferences sets the locale and time zone the user's emails are written in and which reminders
+	// they get
+	UpdateUserPreferences(id string, preferences *model.UserPreferences) error
 	//UpdateUser(id string, user *model.User) error
 	//RemoveUser(id string) error
 }
@@ -91,12 +108,17 @@ type offeringProvider interface {
 	GetOfferingsByDateRange(start time.Time, end time.Time) ([]*model.Offering, error)
 	GetExpandedOfferingsByDateRange(start time.Time, end time.Time) ([]*model.ExpandedOffering, error)
 	GetOfferingsByWorkspaceIDAndDateRange(id string, start time.Time, end time.Time) (*model.Offering, error)
+	// CreateOffering and RemoveOffering queue a confirmation or cancellation for the offerer in the outbox
 	CreateOffering(booking *model.Offering) (string, error)
 	CreateDefaultOffering(booking *model.Offering) (string, error)
 	UpdateOffering(id string, booking *model.Offering) error
 	RemoveOffering(id string) error
 	GetExpiredOfferings(since time.Time) ([]*model.Offering, error)
 	DeleteOfferings(ids []string) error
+	CreateOfferingSeries(series *model.OfferingSeries, occurrences []*model.Offering) ([]*model.Offering, []*model.SeriesConflict, error)
+	GetOfferingSeries(id string) (*model.OfferingSeries, error)
+	GetOfferingsBySeriesID(id string) ([]*model.Offering, error)
+	CancelOfferingSeries(id string, from time.Time) ([]*model.Offering, []*model.SeriesConflict, error)
 }
 
 type assigneeProvider interface {
@@ -105,3 +127,149 @@ type assigneeProvider interface {
 	GetExpiredAssignments(since time.Time) ([]*model.Assignment, error)
 	DeleteAssignments(ids []string) error
 }
+
+type waitlistProvider interface {
+	CreateWaitlistEntry(entry *model.WaitlistEntry) (string, error)
+	GetOneWaitlistEntry(id string) (*model.WaitlistEntry, error)
+	GetWaitlistByUserID(id string) ([]*model.WaitlistEntry, error)
+	CancelWaitlistEntry(id string) (*model.WaitlistEntry, error)
+	ClaimWaitlistHold(id string) (string, error)
+	ProcessWaitlist(workspaceId string, start, end time.Time, holdUntil time.Time) (*model.WaitlistEntry, error)
+	ExpireWaitlistHolds(now time.Time) ([]*model.WaitlistEntry, error)
+}
+
+type policyProvider interface {
+	GetBookingPolicy() (*model.BookingPolicy, error)
+	UpdateBookingPolicy(policy *model.BookingPolicy) error
+	GetFloorBlackouts(floorId string) ([]*model.FloorBlackout, error)
+	CreateFloorBlackout(blackout *model.FloorBlackout) (string, error)
+	RemoveFloorBlackout(id string) error
+	// SetDefaultZone is the zone the department cap counts days in for users without a time zone; UTC if never set
+	SetDefaultZone(zone *time.Location)
+}
+
+// UserDays are the starts of the days, in the user's time zone or else defaultZone, that the range from start to
+// end touches. Like series expansion, the department cap counts bookings per day of the user booking
+func UserDays(timeZone string, defaultZone *time.Location, start, end time.Time) []time.Time {
+	zone := defaultZone
+	if zone == nil {
+		zone = time.UTC
+	}
+	if timeZone != "" {
+		if userZone, err := time.LoadLocation(timeZone); err == nil {
+			zone = userZone
+		}
+	}
+	local := start.In(zone)
+	days := make([]time.Time, 0)
+	for day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, zone); day.Before(end); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	return days
+}
+
+// lockProvider elects a leader among the instances sharing the store for jobs only one of them should run
+type lockProvider interface {
+	// TryLock takes the lock called name if no one holds it. release gives it back and is nil if acquired is false
+	TryLock(name string) (release func(), acquired bool, err error)
+}
+
+type archiveProvider interface {
+	// GetArchivableWorkspaces returns the deleted workspaces nothing references once the rows that ended before
+	// cutoff are archived
+	GetArchivableWorkspaces(cutoff time.Time) ([]*model.Workspace, error)
+	// GetArchivableFloors returns the deleted floors whose workspaces are all archivable
+	GetArchivableFloors(cutoff time.Time) ([]*model.Floor, error)
+	// DeleteArchivedRows deletes the rows in one transaction. If any of them is gone, no longer ends before cutoff
+	// or is still referenced, nothing is deleted
+	DeleteArchivedRows(rows *model.ArchivedRows, cutoff time.Time) error
+	// RestoreArchivedRows puts archived rows back, skipping those that are already in the store or that would
+	// overlap a row that is. Restored workspaces and floors stay deleted
+	RestoreArchivedRows(rows *model.ArchivedRows) (*model.RestoreReport, error)
+}
+
+// outboxProvider hands the notifications queued by other providers to the delivery worker
+type outboxProvider interface {
+	// ClaimOutboxMessages returns up to limit pending messages due at now and pushes their next attempt lease past
+	// now, so that other instances don't claim them while they are being sent
+	ClaimOutboxMessages(now time.Time, limit int, lease time.Duration) ([]*model.OutboxMessage, error)
+	MarkOutboxDelivered(id string, at time.Time) error
+	// MarkOutboxFailed records a failed attempt; the message is retried at next, or dead-lettered if next is nil
+	MarkOutboxFailed(id string, reason string, next *time.Time) error
+	// GetOutboxMessages returns the messages with status, or every message if status is empty, newest first
+	GetOutboxMessages(status string) ([]*model.OutboxMessage, error)
+	GetOneOutboxMessage(id string) (*model.OutboxMessage, error)
+	// ReplayOutboxMessage makes a dead message pending again, with its attempts reset, due at now
+	ReplayOutboxMessage(id string, now time.Time) (*model.OutboxMessage, error)
+}
+
+// templateProvider keeps the email templates admins have written to override the built in ones
+type templateProvider interface {
+	GetEmailTemplates() ([]*model.EmailTemplate, error)
+	// GetEmailTemplate returns the template for event in exactly locale; it is NotFound if there is none
+	GetEmailTemplate(event, locale string) (*model.EmailTemplate, error)
+	// UpsertEmailTemplate creates the template for its event and locale or replaces the existing one
+	UpsertEmailTemplate(template *model.EmailTemplate) error
+	RemoveEmailTemplate(event, locale string) error
+}
+
+// reminderProvider queues the reminders and digests of the scheduler in the outbox, each once
+type reminderProvider interface {
+	// QueueReminder queues n, a reminder or digest, unless one was already queued with key, filling in the user's
+	// details. Nothing is queued for users who opted out of its kind, the default user or a user that doesn't exist.
+	// It reports whether n was queued
+	QueueReminder(key string, n *model.Notification) (bool, error)
+	// DeleteRemindersBefore forgets the keys of the reminders queued before t
+	DeleteRemindersBefore(t time.Time) error
+}
+
+type channelProvider interface {
+	// GetChannels returns the channels of the user with userId and of the floor with floorId, oldest first. An empty
+	// id matches no channels
+	GetChannels(userId, floorId string) ([]*model.Channel, error)
+	GetOneChannel(id string) (*model.Channel, error)
+	CreateChannel(channel *model.Channel) (string, error)
+	// RemoveChannel removes the channel with id and its deliveries
+	RemoveChannel(id string) error
+	// QueueChannelDelivery queues body, what is posted to the channel with channelId about an event of eventType. It
+	// does nothing if a delivery with the same non-empty key is already queued for the channel
+	QueueChannelDelivery(channelId, eventType, key string, body []byte) error
+	// ClaimChannelDeliveries returns up to limit pending deliveries due at now and pushes their next attempt back by
+	// lease, so that no other instance sends them meanwhile
+	ClaimChannelDeliveries(now time.Time, limit int, lease time.Duration) ([]*model.ChannelDelivery, error)
+	MarkChannelDelivered(id string, attempt *model.DeliveryAttempt) error
+	// MarkChannelFailed records the failed attempt; the delivery is retried at attempt.NextAttemptAt, or
+	// dead-lettered if it is nil
+	MarkChannelFailed(id string, attempt *model.DeliveryAttempt) error
+	// DeleteChannelDeliveriesBefore forgets the delivered and dead deliveries created before t
+	DeleteChannelDeliveriesBefore(t time.Time) error
+}
+
+// webhookProvider keeps the webhook subscriptions and hands the deliveries queued for them to the delivery worker
+type webhookProvider interface {
+	// GetWebhooks returns every webhook, oldest first
+	GetWebhooks() ([]*model.Webhook, error)
+	GetOneWebhook(id string) (*model.Webhook, error)
+	CreateWebhook(webhook *model.Webhook) (string, error)
+	// UpdateWebhook replaces the url, secret, events, floor and state of the webhook with id
+	UpdateWebhook(id string, webhook *model.Webhook) error
+	// RemoveWebhook removes the webhook with id and its deliveries
+	RemoveWebhook(id string) error
+	// QueueWebhookDeliveries queues payload, an event of eventType about the floor with floorId, for every active
+	// webhook that wants it and returns how many deliveries it queued
+	QueueWebhookDeliveries(eventType, floorId string, payload []byte) (int, error)
+	// ClaimWebhookDeliveries returns up to limit pending deliveries of active webhooks due at now and pushes their
+	// next attempt lease past now, so that other instances don't claim them while they are being sent
+	ClaimWebhookDeliveries(now time.Time, limit int, lease time.Duration) ([]*model.WebhookDelivery, error)
+	// MarkWebhookDelivered records the successful attempt and that the webhook is no longer failing
+	MarkWebhookDelivered(id string, attempt *model.DeliveryAttempt) error
+	// MarkWebhookFailed records the failed attempt; the delivery is retried at attempt.NextAttemptAt, or
+	// dead-lettered if it is nil. The webhook is failing from then on, and if it has been failing since before
+	// disableBefore it is disabled. It reports whether the webhook was disabled
+	MarkWebhookFailed(id string, attempt *model.DeliveryAttempt, disableBefore time.Time) (bool, error)
+	// GetWebhookDeliveries returns the deliveries of the webhook with webhookId with status, or all of them if status
+	// is empty, newest first
+	GetWebhookDeliveries(webhookId, status string) ([]*model.WebhookDelivery, error)
+	// DeleteWebhookDeliveriesBefore forgets the delivered and dead deliveries created before t
+	DeleteWebhookDeliveriesBefore(t time.Time) error
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Kinds of domain errors the providers return; the routes report each kind with its own status code
+const (
+	NotFound   = "not_found"
+	Conflict   = "conflict"
+	Validation = "validation"
+	Forbidden  = "forbidden"
+)
+
+// Booking policy rules, reported to clients as the code of a validation error
+const (
+	PolicyAdvanceWindow   = "advance_window"
+	PolicyMaxDuration     = "max_duration"
+	PolicyFutureBookings  = "max_future_bookings"
+	PolicyDepartmentDaily = "max_department_daily"
+	PolicyFloorBlackout   = "floor_blackout"
+)
+
+// Error is a domain error. Kind is what went wrong, Code a more specific machine-readable reason that
+// defaults to the kind, and Details anything a client needs to show the error, e.g. the conflicting occurrences of a series
+type Error struct {
+	Kind    string
+	Code    string
+	Message string
+	Details interface{}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newError(kind string, format string, a ...interface{}) *Error {
+	return &Error{Kind: kind, Code: kind, Message: fmt.Sprintf(format, a...)}
+}
+
+func NewNotFoundError(format string, a ...interface{}) *Error {
+	return newError(NotFound, format, a...)
+}
+
+func NewConflictError(format string, a ...interface{}) *Error {
+	return newError(Conflict, format, a...)
+}
+
+func NewValidationError(format string, a ...interface{}) *Error {
+	return newError(Validation, format, a...)
+}
+
+func NewForbiddenError(format string, a ...interface{}) *Error {
+	return newError(Forbidden, format, a...)
+}
+
+// NewPolicyError is returned when a booking breaks the booking policy rule code
+func NewPolicyError(code string, format string, a ...interface{}) *Error {
+	err := newError(Validation, format, a...)
+	err.Code = code
+	return err
+}
+
+var NotFoundError = NewNotFoundError("not found")
+var EmptyError = errors.New("empty")
+
+// HoldExpiredError is returned when a waitlist hold is claimed too late
+var HoldExpiredError = &Error{Kind: Conflict, Code: "hold_expired", Message: "hold has expired"}
+
+// AsError returns err as a domain error, or nil if it isn't one. A missing row is a not found error
+func AsError(err error) *Error {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return domainErr
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return NotFoundError
+	}
+	return nil
+}
+
+// IsKind reports whether err is a domain error of the given kind
+func IsKind(err error, kind string) bool {
+	domainErr := AsError(err)
+	return domainErr != nil && domainErr.Kind == kind
+}
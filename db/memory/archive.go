@@ -0,0 +1,309 @@
+package memory
+
+import (
+	"go-api/db"
+	"go-api/model"
+	"time"
+)
+
+var missingReferenceError = db.NewValidationError("archived rows reference rows that no longer exist")
+
+func (m *MemoryDBStore) GetArchivableWorkspaces(cutoff time.Time) ([]*model.Workspace, error) {
+	return m.queryWorkspaces(func(row *workspaceRow) bool { return m.archivableWorkspace(row, cutoff) }), nil
+}
+
+func (m *MemoryDBStore) GetArchivableFloors(cutoff time.Time) ([]*model.Floor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	floors := make([]*model.Floor, 0)
+	for _, row := range m.floors {
+		if m.archivableFloor(row, cutoff) {
+			floor := row.Floor
+			floors = append(floors, &floor)
+		}
+	}
+	return floors, nil
+}
+
+// archivableWorkspace is true if row is deleted and nothing but rows that ended before cutoff references it
+func (t *tables) archivableWorkspace(row *workspaceRow, cutoff time.Time) bool {
+	if !row.deleted {
+		return false
+	}
+	for _, booking := range t.bookings {
+		if booking.WorkspaceID == row.ID && !booking.EndDate.Before(cutoff) {
+			return false
+		}
+	}
+	for _, offering := range t.offerings {
+		if offering.WorkspaceID == row.ID && (offering.open || !offering.EndDate.Before(cutoff)) {
+			return false
+		}
+	}
+	for _, assignment := range t.assignments {
+		if assignment.WorkspaceID == row.ID && (assignment.open || !assignment.EndDate.Before(cutoff)) {
+			return false
+		}
+	}
+	for _, series := range t.bookingSeries {
+		if series.WorkspaceID == row.ID {
+			return false
+		}
+	}
+	for _, series := range t.offeringSeries {
+		if series.WorkspaceID == row.ID {
+			return false
+		}
+	}
+	return true
+}
+
+// archivableFloor is true if row is deleted and all its workspaces are archivable
+func (t *tables) archivableFloor(row *floorRow, cutoff time.Time) bool {
+	if !row.deleted {
+		return false
+	}
+	for _, workspace := range t.workspaces {
+		if workspace.Floor == row.ID && !t.archivableWorkspace(workspace, cutoff) {
+			return false
+		}
+	}
+	return true
+}
+
+// DeleteArchivedRows deletes children before parents, checking that each row can still be archived
+func (m *MemoryDBStore) DeleteArchivedRows(rows *model.ArchivedRows, cutoff time.Time) error {
+	changed := db.NewConflictError("rows changed while they were being archived")
+	return m.update(func() error {
+		ids := make(map[string]bool)
+		for _, b := range rows.Bookings {
+			if row := m.booking(b.ID); row == nil || !row.EndDate.Before(cutoff) {
+				return changed
+			}
+			ids[b.ID] = true
+		}
+		bookings := m.bookings[:0]
+		for _, row := range m.bookings {
+			if !ids[row.ID] {
+				bookings = append(bookings, row)
+			}
+		}
+		m.bookings = bookings
+		// waitlist.booking_id is ON DELETE SET NULL
+		for _, entry := range m.waitlist {
+			if ids[entry.BookingID] {
+				entry.BookingID = ""
+			}
+		}
+
+		ids = make(map[string]bool)
+		for _, o := range rows.Offerings {
+			if row := m.offering(o.ID); row == nil || row.open || !row.EndDate.Before(cutoff) {
+				return changed
+			}
+			ids[o.ID] = true
+		}
+		offerings := m.offerings[:0]
+		for _, row := range m.offerings {
+			if !ids[row.ID] {
+				offerings = append(offerings, row)
+			}
+		}
+		m.offerings = offerings
+
+		ids = make(map[string]bool)
+		for _, a := range rows.Assignments {
+			if row := m.assignment(a.ID); row == nil || row.open || !row.EndDate.Before(cutoff) {
+				return changed
+			}
+			ids[a.ID] = true
+		}
+		assignments := m.assignments[:0]
+		for _, row := range m.assignments {
+			if !ids[row.ID] {
+				assignments = append(assignments, row)
+			}
+		}
+		m.assignments = assignments
+
+		ids = make(map[string]bool)
+		for _, w := range rows.Workspaces {
+			if row := m.workspace(w.ID); row == nil || !m.archivableWorkspace(row, cutoff) {
+				return changed
+			}
+			ids[w.ID] = true
+		}
+		workspaces := m.workspaces[:0]
+		for _, row := range m.workspaces {
+			if !ids[row.ID] {
+				workspaces = append(workspaces, row)
+			}
+		}
+		m.workspaces = workspaces
+		// waitlist.hold_workspace_id is ON DELETE SET NULL
+		for _, entry := range m.waitlist {
+			if ids[entry.HoldWorkspaceID] {
+				entry.HoldWorkspaceID = ""
+			}
+		}
+
+		ids = make(map[string]bool)
+		for _, f := range rows.Floors {
+			row := m.floor(f.ID)
+			if row == nil || !row.deleted {
+				return changed
+			}
+			for _, workspace := range m.workspaces {
+				if workspace.Floor == f.ID {
+					return db.NewConflictError("archived rows are still referenced")
+				}
+			}
+			ids[f.ID] = true
+		}
+		floors := m.floors[:0]
+		for _, row := range m.floors {
+			if !ids[row.ID] {
+				floors = append(floors, row)
+			}
+		}
+		m.floors = floors
+		// waitlist entries, blackouts, channels and webhooks go with their floor (ON DELETE CASCADE)
+		waitlist := m.waitlist[:0]
+		for _, entry := range m.waitlist {
+			if !ids[entry.FloorID] {
+				waitlist = append(waitlist, entry)
+			}
+		}
+		m.waitlist = waitlist
+		blackouts := m.blackouts[:0]
+		for _, blackout := range m.blackouts {
+			if !ids[blackout.FloorID] {
+				blackouts = append(blackouts, blackout)
+			}
+		}
+		m.blackouts = blackouts
+		channels := make(map[string]bool)
+		for _, channel := range m.channels {
+			if ids[channel.FloorID] {
+				channels[channel.ID] = true
+			}
+		}
+		m.removeChannels(channels)
+		webhooks := make(map[string]bool)
+		for _, webhook := range m.webhooks {
+			if ids[webhook.FloorID] {
+				webhooks[webhook.ID] = true
+			}
+		}
+		m.removeWebhooks(webhooks)
+		return nil
+	})
+}
+
+// RestoreArchivedRows inserts the rows parents first, skipping those already in the store and those that would
+// overlap a row that is, like the ON CONFLICT DO NOTHING inserts of the postgres store
+func (m *MemoryDBStore) RestoreArchivedRows(rows *model.ArchivedRows) (*model.RestoreReport, error) {
+	report := &model.RestoreReport{}
+	err := m.update(func() error {
+		for _, f := range rows.Floors {
+			if m.floor(f.ID) != nil {
+				report.Skipped.Floors++
+				continue
+			}
+			m.floors = append(m.floors, &floorRow{Floor: *f, deleted: true})
+			report.Restored.Floors++
+		}
+		for _, w := range rows.Workspaces {
+			if m.workspace(w.ID) != nil {
+				report.Skipped.Workspaces++
+				continue
+			}
+			if m.floor(w.Floor) == nil {
+				return missingReferenceError
+			}
+			m.workspaces = append(m.workspaces, &workspaceRow{Workspace: *w, deleted: true})
+			report.Restored.Workspaces++
+		}
+		for _, a := range rows.Assignments {
+			if m.user(a.UserID) == nil || m.workspace(a.WorkspaceID) == nil {
+				return missingReferenceError
+			}
+			if m.assignmentExists(a) {
+				report.Skipped.Assignments++
+				continue
+			}
+			m.assignments = append(m.assignments, &assignmentRow{Assignment: *a})
+			report.Restored.Assignments++
+		}
+		for _, o := range rows.Offerings {
+			if m.user(o.UserID) == nil || m.user(o.CreatedBy) == nil || m.workspace(o.WorkspaceID) == nil ||
+				!m.hasOfferingSeries(o.SeriesID) {
+				return missingReferenceError
+			}
+			row := &offeringRow{Offering: *o}
+			err := m.checkOfferingRow(row)
+			if m.offering(o.ID) != nil || db.IsKind(err, db.Conflict) {
+				report.Skipped.Offerings++
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			m.offerings = append(m.offerings, row)
+			report.Restored.Offerings++
+		}
+		for _, b := range rows.Bookings {
+			if m.user(b.UserID) == nil || m.user(b.CreatedBy) == nil || m.workspace(b.WorkspaceID) == nil ||
+				!m.hasBookingSeries(b.SeriesID) {
+				return missingReferenceError
+			}
+			row := *b
+			err := m.checkBookingRow(&row)
+			if m.booking(b.ID) != nil || db.IsKind(err, db.Conflict) {
+				report.Skipped.Bookings++
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			m.bookings = append(m.bookings, &row)
+			report.Restored.Bookings++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// hasBookingSeries is true if id is empty or the id of a booking series in the store
+func (t *tables) hasBookingSeries(id string) bool {
+	for _, series := range t.bookingSeries {
+		if series.ID == id {
+			return true
+		}
+	}
+	return id == ""
+}
+
+// hasOfferingSeries is true if id is empty or the id of an offering series in the store
+func (t *tables) hasOfferingSeries(id string) bool {
+	for _, series := range t.offeringSeries {
+		if series.ID == id {
+			return true
+		}
+	}
+	return id == ""
+}
+
+// assignmentExists is true if a is in the store or overlaps an assignment of its workspace that is
+func (t *tables) assignmentExists(a *model.Assignment) bool {
+	for _, row := range t.assignments {
+		if row.ID == a.ID || (row.WorkspaceID == a.WorkspaceID &&
+			rangesOverlap(row.StartDate, row.EndDate, row.open, a.StartDate, a.EndDate, false)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"go-api/model"
+	"time"
+)
+
+// FindAvailability returns the workspaces of the floor that can be booked from start to end: the offered and
+// the unassigned ones that aren't booked or held for a waitlisted user; see PostgresDBStore.FindAvailability
+func (m *MemoryDBStore) FindAvailability(floorId string, start time.Time, end time.Time) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	onFloor := func(workspaceId string) bool {
+		workspace := m.workspace(workspaceId)
+		return workspace != nil && workspace.Floor == floorId && !workspace.deleted
+	}
+
+	// Workspaces held for a waitlisted user count as booked
+	now := time.Now()
+	bookedWorkspaces := make(map[string]bool)
+	for _, booking := range m.bookings {
+		if onFloor(booking.WorkspaceID) && !booking.Cancelled && overlaps(booking.StartDate, booking.EndDate, start, end) {
+			bookedWorkspaces[booking.WorkspaceID] = true
+		}
+	}
+	for _, entry := range m.waitlist {
+		workspace := m.workspace(entry.HoldWorkspaceID)
+		if workspace != nil && workspace.Floor == floorId && entry.Status == model.WaitlistHeld && entry.HoldExpiresAt != nil &&
+			entry.HoldExpiresAt.After(now) && overlaps(entry.StartDate, entry.EndDate, start, end) {
+			bookedWorkspaces[entry.HoldWorkspaceID] = true
+		}
+	}
+
+	offeredWorkspaces := make(map[string]bool)
+	for _, offering := range m.offerings {
+		if onFloor(offering.WorkspaceID) && !offering.Cancelled && covers(offering, start, end) {
+			offeredWorkspaces[offering.WorkspaceID] = true
+		}
+	}
+
+	assignedWorkspaces := make(map[string]bool)
+	for _, assignment := range m.assignments {
+		if !onFloor(assignment.WorkspaceID) {
+			continue
+		}
+		if assignment.open {
+			if assignment.StartDate.Before(start) || (assignment.StartDate.After(start) && assignment.StartDate.Before(end)) {
+				assignedWorkspaces[assignment.WorkspaceID] = true
+			}
+		} else if overlaps(assignment.StartDate, assignment.EndDate, start, end) {
+			assignedWorkspaces[assignment.WorkspaceID] = true
+		}
+	}
+
+	availableWorkspaces := make([]string, 0)
+	for _, workspace := range m.workspaces {
+		if workspace.Floor != floorId {
+			continue
+		}
+		id := workspace.ID
+		offered := offeredWorkspaces[id]
+		booked := bookedWorkspaces[id]
+		assigned := assignedWorkspaces[id]
+		if assigned && offered && !booked {
+			availableWorkspaces = append(availableWorkspaces, id)
+		} else if !assigned && !booked {
+			availableWorkspaces = append(availableWorkspaces, id)
+		}
+	}
+	return availableWorkspaces, nil
+}
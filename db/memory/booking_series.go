@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"go-api/db"
+	"go-api/model"
+	"time"
+)
+
+// CreateBookingSeries stores the series and books each occurrence that is available; occurrences that are not
+// offered or already booked, also when the overlap check of the insert catches them, are returned as conflicts
+// instead of failing the whole series
+func (m *MemoryDBStore) CreateBookingSeries(series *model.BookingSeries, occurrences []*model.Booking) ([]*model.Booking, []*model.SeriesConflict, error) {
+	bookings := make([]*model.Booking, 0)
+	conflicts := make([]*model.SeriesConflict, 0)
+	var unavailable *db.Error
+	err := m.update(func() error {
+		if m.user(series.UserID) == nil || m.user(series.CreatedBy) == nil {
+			return foreignKeyError("booking_series", "user_id")
+		}
+		if m.workspace(series.WorkspaceID) == nil {
+			return foreignKeyError("booking_series", "workspace_id")
+		}
+		row := *series
+		row.ID = newID()
+		row.Cancelled = false
+		m.bookingSeries = append(m.bookingSeries, &row)
+		series.ID = row.ID
+
+		for _, occurrence := range occurrences {
+			if err := m.checkBookingAvailable(occurrence); err != nil {
+				domainErr := db.AsError(err)
+				if domainErr == nil {
+					return err
+				}
+				conflicts = append(conflicts, &model.SeriesConflict{
+					StartDate: occurrence.StartDate,
+					EndDate:   occurrence.EndDate,
+					Reason:    domainErr.Message,
+					Code:      domainErr.Code,
+				})
+				continue
+			}
+			booking := &model.Booking{
+				UserID:      series.UserID,
+				WorkspaceID: series.WorkspaceID,
+				StartDate:   occurrence.StartDate,
+				EndDate:     occurrence.EndDate,
+				CreatedBy:   series.CreatedBy,
+				SeriesID:    series.ID,
+			}
+			if err := m.insertBooking(booking); err != nil {
+				domainErr := db.AsError(err)
+				if domainErr == nil {
+					return err
+				}
+				conflicts = append(conflicts, &model.SeriesConflict{
+					StartDate: occurrence.StartDate,
+					EndDate:   occurrence.EndDate,
+					Reason:    domainErr.Message,
+					Code:      domainErr.Code,
+				})
+				continue
+			}
+			occurrence.ID = booking.ID
+			occurrence.UserID = series.UserID
+			occurrence.WorkspaceID = series.WorkspaceID
+			occurrence.CreatedBy = series.CreatedBy
+			occurrence.SeriesID = series.ID
+			bookings = append(bookings, occurrence)
+		}
+		if len(bookings) == 0 {
+			unavailable = db.NewConflictError("no occurrence of the series is available")
+			unavailable.Details = conflicts
+			return unavailable
+		}
+		m.queueBookingNotifications(model.BookingSeriesNotification(model.NotificationConfirmation, series.ID, bookings), series.WorkspaceID, series.CreatedBy)
+		return nil
+	})
+	if unavailable != nil {
+		// nothing could be booked: the series is rolled back but the conflicts are still reported
+		return nil, conflicts, unavailable
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return bookings, conflicts, nil
+}
+
+func (m *MemoryDBStore) GetBookingSeries(id string) (*model.BookingSeries, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, row := range m.bookingSeries {
+		if row.ID == id {
+			series := *row
+			return &series, nil
+		}
+	}
+	return nil, db.NotFoundError
+}
+
+func (m *MemoryDBStore) GetBookingsBySeriesID(id string) ([]*model.Booking, error) {
+	bookings := m.queryBookings(func(row *model.Booking) bool { return row.SeriesID == id })
+	sortBookings(bookings)
+	return bookings, nil
+}
+
+// CancelBookingSeries cancels the occurrences of a series starting at or after from and returns them;
+// a zero from cancels every remaining occurrence and marks the series itself as cancelled
+func (m *MemoryDBStore) CancelBookingSeries(id string, from time.Time) ([]*model.Booking, error) {
+	bookings := make([]*model.Booking, 0)
+	err := m.update(func() error {
+		var series *model.BookingSeries
+		for _, row := range m.bookingSeries {
+			if row.ID == id {
+				series = row
+			}
+		}
+		if series == nil {
+			return db.NotFoundError
+		}
+		if series.Cancelled {
+			return db.NewConflictError("series is already cancelled")
+		}
+		if from.IsZero() {
+			series.Cancelled = true
+		}
+		for _, row := range m.bookings {
+			if row.SeriesID == id && !row.Cancelled && !row.StartDate.Before(from) {
+				row.Cancelled = true
+				booking := *row
+				bookings = append(bookings, &booking)
+			}
+		}
+		if len(bookings) > 0 {
+			first := bookings[0]
+			m.queueBookingNotifications(model.BookingSeriesNotification(model.NotificationCancellation, id, bookings), first.WorkspaceID, first.CreatedBy)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bookings, nil
+}
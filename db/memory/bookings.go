@@ -0,0 +1,406 @@
+package memory
+
+import (
+	"go-api/db"
+	"go-api/model"
+	"sort"
+	"time"
+)
+
+func (m *MemoryDBStore) GetOneBooking(id string) (*model.Booking, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	row := m.booking(id)
+	if row == nil {
+		return nil, db.NotFoundError
+	}
+	booking := *row
+	return &booking, nil
+}
+
+func (m *MemoryDBStore) GetOneExpandedBooking(id string) (*model.ExpandedBooking, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	row := m.booking(id)
+	if row == nil {
+		return nil, db.NotFoundError
+	}
+	expanded := m.expandBooking(row)
+	if expanded == nil {
+		return nil, db.NotFoundError
+	}
+	return expanded, nil
+}
+
+func (m *MemoryDBStore) GetAllBookings() ([]*model.Booking, error) {
+	return m.queryBookings(func(row *model.Booking) bool { return true }), nil
+}
+
+func (m *MemoryDBStore) GetAllExpandedBookings() ([]*model.ExpandedBooking, error) {
+	return m.queryExpandedBookings(func(row *model.Booking) bool { return true }), nil
+}
+
+func (m *MemoryDBStore) GetBookingsByWorkspaceID(id string) ([]*model.Booking, error) {
+	return m.queryBookings(func(row *model.Booking) bool { return row.WorkspaceID == id }), nil
+}
+
+func (m *MemoryDBStore) GetExpandedBookingsByWorkspaceID(id string) ([]*model.ExpandedBooking, error) {
+	return m.queryExpandedBookings(func(row *model.Booking) bool { return row.WorkspaceID == id }), nil
+}
+
+func (m *MemoryDBStore) GetBookingsByUserID(id string) ([]*model.Booking, error) {
+	return m.queryBookings(func(row *model.Booking) bool { return row.UserID == id }), nil
+}
+
+func (m *MemoryDBStore) GetExpandedBookingsByUserID(id string) ([]*model.ExpandedBooking, error) {
+	return m.queryExpandedBookings(func(row *model.Booking) bool { return row.UserID == id }), nil
+}
+
+func (m *MemoryDBStore) GetBookingsByDateRange(start time.Time, end time.Time) ([]*model.Booking, error) {
+	return m.queryBookings(func(row *model.Booking) bool {
+		return overlaps(row.StartDate, row.EndDate, start, end)
+	}), nil
+}
+
+func (m *MemoryDBStore) GetExpandedBookingsByDateRange(start time.Time, end time.Time) ([]*model.ExpandedBooking, error) {
+	return m.queryExpandedBookings(func(row *model.Booking) bool {
+		return overlaps(row.StartDate, row.EndDate, start, end)
+	}), nil
+}
+
+func (m *MemoryDBStore) CreateBooking(booking *model.Booking) (string, error) {
+	var id string
+	err := m.update(func() error {
+		if err := m.checkBookingAvailable(booking); err != nil {
+			return err
+		}
+		row := &model.Booking{
+			UserID:      booking.UserID,
+			WorkspaceID: booking.WorkspaceID,
+			StartDate:   booking.StartDate,
+			EndDate:     booking.EndDate,
+			CreatedBy:   booking.CreatedBy,
+		}
+		if err := m.insertBooking(row); err != nil {
+			return err
+		}
+		id = row.ID
+		m.queueBookingNotifications(&model.Notification{
+			Kind:      model.NotificationConfirmation,
+			Subject:   model.NotificationBooking,
+			SubjectID: row.ID,
+			UserID:    row.UserID,
+			StartDate: row.StartDate,
+			EndDate:   row.EndDate,
+		}, row.WorkspaceID, row.CreatedBy)
+		return nil
+	})
+	return id, err
+}
+
+// checkBookingAvailable makes sure the workspace is offered for the whole booking, that no other booking
+// overlaps it, that it isn't held for another user and that the booking policy allows it; booking.ID is
+// ignored when looking for overlaps so a booking can be moved
+func (t *tables) checkBookingAvailable(booking *model.Booking) error {
+	offered := false
+	for _, offering := range t.offerings {
+		if offering.WorkspaceID == booking.WorkspaceID && !offering.Cancelled &&
+			!offering.StartDate.After(booking.StartDate) && (offering.open || !offering.EndDate.Before(booking.EndDate)) {
+			offered = true
+			break
+		}
+	}
+	if !offered {
+		return db.NewValidationError("workspace is not offered")
+	}
+
+	for _, other := range t.bookings {
+		if other.WorkspaceID == booking.WorkspaceID && !other.Cancelled && other.ID != booking.ID &&
+			overlaps(other.StartDate, other.EndDate, booking.StartDate, booking.EndDate) {
+			return db.NewConflictError("workspace already booked for this duration")
+		}
+	}
+
+	now := time.Now()
+	for _, entry := range t.waitlist {
+		if entry.HoldWorkspaceID == booking.WorkspaceID && entry.Status == model.WaitlistHeld &&
+			entry.HoldExpiresAt != nil && entry.HoldExpiresAt.After(now) && entry.UserID != booking.UserID &&
+			overlaps(entry.StartDate, entry.EndDate, booking.StartDate, booking.EndDate) {
+			return db.NewConflictError("workspace is held for a waitlisted user")
+		}
+	}
+	return t.checkBookingPolicy(booking)
+}
+
+// insertBooking adds row, giving it an id, as long as it doesn't overlap another active booking of the workspace
+func (t *tables) insertBooking(row *model.Booking) error {
+	if err := t.checkBookingRow(row); err != nil {
+		return err
+	}
+	row.ID = newID()
+	t.bookings = append(t.bookings, row)
+	return nil
+}
+
+// checkBookingRow enforces the foreign keys and the bookings_no_overlap constraint for row
+func (t *tables) checkBookingRow(row *model.Booking) error {
+	if t.user(row.UserID) == nil || t.user(row.CreatedBy) == nil {
+		return foreignKeyError("bookings", "user_id")
+	}
+	if t.workspace(row.WorkspaceID) == nil {
+		return foreignKeyError("bookings", "workspace_id")
+	}
+	if row.EndDate.Before(row.StartDate) {
+		return rangeError
+	}
+	if row.Cancelled {
+		return nil
+	}
+	for _, other := range t.bookings {
+		if other.ID != row.ID && other.WorkspaceID == row.WorkspaceID && !other.Cancelled &&
+			rangesOverlap(other.StartDate, other.EndDate, false, row.StartDate, row.EndDate, false) {
+			return db.NewConflictError("workspace already booked for this duration")
+		}
+	}
+	return nil
+}
+
+// UpdateBooking moves a booking to a new workspace and/or time; the same availability rules as CreateBooking apply
+func (m *MemoryDBStore) UpdateBooking(id string, booking *model.Booking) error {
+	return m.update(func() error {
+		existing := m.booking(id)
+		if existing == nil {
+			return db.NotFoundError
+		}
+		if existing.Cancelled {
+			return db.NewConflictError("cancelled bookings cannot be modified")
+		}
+		booking.ID = id
+		if err := m.checkBookingAvailable(booking); err != nil {
+			return err
+		}
+		row := *existing
+		row.WorkspaceID = booking.WorkspaceID
+		row.StartDate = booking.StartDate
+		row.EndDate = booking.EndDate
+		if err := m.checkBookingRow(&row); err != nil {
+			return err
+		}
+		*existing = row
+		m.queueNotification(&model.Notification{
+			Kind:      model.NotificationUpdate,
+			Subject:   model.NotificationBooking,
+			SubjectID: row.ID,
+			UserID:    row.UserID,
+			StartDate: row.StartDate,
+			EndDate:   row.EndDate,
+		}, row.WorkspaceID)
+		return nil
+	})
+}
+
+func (m *MemoryDBStore) RemoveBooking(id string) error {
+	return m.update(func() error {
+		row := m.booking(id)
+		if row == nil {
+			return db.NotFoundError
+		}
+		if row.Cancelled {
+			return db.NewConflictError("booking is already cancelled")
+		}
+		row.Cancelled = true
+		m.queueBookingNotifications(&model.Notification{
+			Kind:      model.NotificationCancellation,
+			Subject:   model.NotificationBooking,
+			SubjectID: row.ID,
+			UserID:    row.UserID,
+			StartDate: row.StartDate,
+			EndDate:   row.EndDate,
+		}, row.WorkspaceID, row.CreatedBy)
+		return nil
+	})
+}
+
+// CheckInBooking records that the user arrived for booking id. Check-in opens grace before each day of the
+// booking starts and closes grace after; later than that the rest of the booking is released as a no-show
+func (m *MemoryDBStore) CheckInBooking(id string, now time.Time, grace time.Duration) (*model.Booking, error) {
+	var booking model.Booking
+	err := m.update(func() error {
+		row := m.booking(id)
+		if row == nil {
+			return db.NotFoundError
+		}
+		if row.Cancelled {
+			return db.NewConflictError("booking is cancelled")
+		}
+		day := row.DayStart(now.Add(grace))
+		if row.CheckedInAt != nil && !row.CheckedInAt.Before(day.Add(-grace)) {
+			return db.NewConflictError("already checked in")
+		}
+		if now.Before(day.Add(-grace)) {
+			return db.NewValidationError("check-in is not open yet")
+		}
+		if now.After(day.Add(grace)) || !day.Before(row.EndDate) {
+			return db.NewValidationError("check-in window has passed")
+		}
+		checkedInAt := now
+		row.CheckedInAt = &checkedInAt
+		booking = *row
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
+// ReleaseNoShows releases the bookings nobody checked in to on the day of the booking now falls on, once grace
+// has passed since it started, and marks them as no-shows. A booking missed on its first day is cancelled, one missed
+// on a later day ends when that day starts. It returns the released part of each booking, as a cancelled booking
+func (m *MemoryDBStore) ReleaseNoShows(now time.Time, grace time.Duration) ([]*model.Booking, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	released := make([]*model.Booking, 0)
+	for _, row := range m.bookings {
+		if row.Cancelled || !row.EndDate.After(now) {
+			continue
+		}
+		day := row.DayStart(now)
+		if !day.Add(grace).Before(now) || (row.CheckedInAt != nil && !row.CheckedInAt.Before(day.Add(-grace))) {
+			continue
+		}
+		booking := *row
+		booking.StartDate, booking.Cancelled, booking.NoShow = day, true, true
+		released = append(released, &booking)
+		row.NoShow = true
+		if day.Equal(row.StartDate) {
+			row.Cancelled = true
+		} else {
+			row.EndDate = day
+		}
+		m.queueBookingNotifications(&model.Notification{
+			Kind:      model.NotificationCancellation,
+			Subject:   model.NotificationBooking,
+			SubjectID: booking.ID,
+			UserID:    booking.UserID,
+			StartDate: booking.StartDate,
+			EndDate:   booking.EndDate,
+		}, booking.WorkspaceID, booking.CreatedBy)
+	}
+	return released, nil
+}
+
+// GetNoShowCounts counts the no-shows of every user with at least min of them since the given time, worst first
+func (m *MemoryDBStore) GetNoShowCounts(since time.Time, min int) ([]*model.NoShowCount, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	byUser := make(map[string]*model.NoShowCount)
+	for _, row := range m.bookings {
+		if !row.NoShow || row.StartDate.Before(since) {
+			continue
+		}
+		user := m.user(row.UserID)
+		if user == nil {
+			continue
+		}
+		count, ok := byUser[user.ID]
+		if !ok {
+			count = &model.NoShowCount{UserID: user.ID, UserName: user.Name, Department: user.Department}
+			byUser[user.ID] = count
+		}
+		count.NoShows++
+	}
+	counts := make([]*model.NoShowCount, 0)
+	for _, count := range byUser {
+		if count.NoShows >= min {
+			counts = append(counts, count)
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].NoShows != counts[j].NoShows {
+			return counts[i].NoShows > counts[j].NoShows
+		}
+		return counts[i].UserName < counts[j].UserName
+	})
+	return counts, nil
+}
+
+func (m *MemoryDBStore) GetExpiredBookings(since time.Time) ([]*model.Booking, error) {
+	return m.queryBookings(func(row *model.Booking) bool { return row.EndDate.Before(since) }), nil
+}
+
+func (m *MemoryDBStore) DeleteBookings(ids []string) error {
+	return m.update(func() error {
+		for _, id := range ids {
+			bookings := m.bookings[:0]
+			for _, row := range m.bookings {
+				if row.ID != id {
+					bookings = append(bookings, row)
+				}
+			}
+			m.bookings = bookings
+			// waitlist.booking_id is ON DELETE SET NULL
+			for _, entry := range m.waitlist {
+				if entry.BookingID == id {
+					entry.BookingID = ""
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (m *MemoryDBStore) queryBookings(where func(row *model.Booking) bool) []*model.Booking {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	bookings := make([]*model.Booking, 0)
+	for _, row := range m.bookings {
+		if where(row) {
+			booking := *row
+			bookings = append(bookings, &booking)
+		}
+	}
+	return bookings
+}
+
+func (m *MemoryDBStore) queryExpandedBookings(where func(row *model.Booking) bool) []*model.ExpandedBooking {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	bookings := make([]*model.ExpandedBooking, 0)
+	for _, row := range m.bookings {
+		if !where(row) {
+			continue
+		}
+		if expanded := m.expandBooking(row); expanded != nil {
+			bookings = append(bookings, expanded)
+		}
+	}
+	return bookings
+}
+
+// expandBooking joins the booking with its user, workspace and floor; nil if any of them is missing
+func (t *tables) expandBooking(row *model.Booking) *model.ExpandedBooking {
+	user := t.user(row.UserID)
+	workspace := t.workspace(row.WorkspaceID)
+	if user == nil || workspace == nil {
+		return nil
+	}
+	floor := t.floor(workspace.Floor)
+	if floor == nil {
+		return nil
+	}
+	return &model.ExpandedBooking{
+		Booking:       *row,
+		WorkspaceName: workspace.Name,
+		UserName:      user.Name,
+		FloorID:       floor.ID,
+		FloorName:     floor.Name,
+	}
+}
+
+// sortBookings orders bookings by start time, as the series queries do
+func sortBookings(bookings []*model.Booking) {
+	sort.SliceStable(bookings, func(i, j int) bool {
+		return bookings[i].StartDate.Before(bookings[j].StartDate)
+	})
+}
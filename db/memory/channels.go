@@ -0,0 +1,177 @@
+package memory
+
+import (
+	"go-api/db"
+	"go-api/model"
+	"time"
+)
+
+func (t *tables) channelDelivery(id string) *model.ChannelDelivery {
+	for _, row := range t.channelDeliveries {
+		if row.ID == id {
+			return row
+		}
+	}
+	return nil
+}
+
+// removeChannels removes the channels with ids and their deliveries
+func (t *tables) removeChannels(ids map[string]bool) {
+	channels := t.channels[:0]
+	for _, row := range t.channels {
+		if !ids[row.ID] {
+			channels = append(channels, row)
+		}
+	}
+	t.channels = channels
+	deliveries := t.channelDeliveries[:0]
+	for _, row := range t.channelDeliveries {
+		if !ids[row.ChannelID] {
+			deliveries = append(deliveries, row)
+		}
+	}
+	t.channelDeliveries = deliveries
+}
+
+func (m *MemoryDBStore) GetChannels(userId, floorId string) ([]*model.Channel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	channels := make([]*model.Channel, 0)
+	for _, row := range m.channels {
+		if (userId != "" && row.UserID == userId) || (floorId != "" && row.FloorID == floorId) {
+			channel := *row
+			channels = append(channels, &channel)
+		}
+	}
+	return channels, nil
+}
+
+func (m *MemoryDBStore) GetOneChannel(id string) (*model.Channel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, row := range m.channels {
+		if row.ID == id {
+			channel := *row
+			return &channel, nil
+		}
+	}
+	return nil, db.NotFoundError
+}
+
+func (m *MemoryDBStore) CreateChannel(channel *model.Channel) (string, error) {
+	err := m.update(func() error {
+		if (channel.UserID == "") == (channel.FloorID == "") {
+			return db.NewValidationError("a channel belongs to either a user or a floor")
+		}
+		if channel.UserID != "" && m.user(channel.UserID) == nil {
+			return db.NotFoundError
+		}
+		if channel.FloorID != "" && m.floor(channel.FloorID) == nil {
+			return db.NotFoundError
+		}
+		if channel.Events == nil {
+			channel.Events = []string{}
+		}
+		channel.ID, channel.CreatedAt = newID(), time.Now()
+		row := *channel
+		m.channels = append(m.channels, &row)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return channel.ID, nil
+}
+
+func (m *MemoryDBStore) RemoveChannel(id string) error {
+	return m.update(func() error {
+		for _, row := range m.channels {
+			if row.ID == id {
+				m.removeChannels(map[string]bool{id: true})
+				return nil
+			}
+		}
+		return db.NotFoundError
+	})
+}
+
+func (m *MemoryDBStore) QueueChannelDelivery(channelId, eventType, key string, body []byte) error {
+	return m.update(func() error {
+		found := false
+		for _, row := range m.channels {
+			found = found || row.ID == channelId
+		}
+		if !found {
+			return db.NotFoundError
+		}
+		for _, row := range m.channelDeliveries {
+			if key != "" && row.ChannelID == channelId && row.Key == key {
+				return nil
+			}
+		}
+		now := time.Now()
+		m.channelDeliveries = append(m.channelDeliveries, &model.ChannelDelivery{
+			Delivery: model.Delivery{
+				ID:            newID(),
+				Event:         eventType,
+				Status:        model.OutboxPending,
+				NextAttemptAt: now,
+				CreatedAt:     now,
+			},
+			ChannelID: channelId,
+			Key:       key,
+			Body:      append([]byte(nil), body...),
+		})
+		return nil
+	})
+}
+
+func (m *MemoryDBStore) ClaimChannelDeliveries(now time.Time, limit int, lease time.Duration) ([]*model.ChannelDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	states := make([]*model.Delivery, len(m.channelDeliveries))
+	for i, row := range m.channelDeliveries {
+		states[i] = &row.Delivery
+	}
+	deliveries := make([]*model.ChannelDelivery, 0)
+	for _, i := range claimDeliveries(states, now, limit, lease) {
+		delivery := *m.channelDeliveries[i]
+		deliveries = append(deliveries, &delivery)
+	}
+	return deliveries, nil
+}
+
+func (m *MemoryDBStore) MarkChannelDelivered(id string, attempt *model.DeliveryAttempt) error {
+	return m.update(func() error {
+		row := m.channelDelivery(id)
+		if row == nil || row.Status != model.OutboxPending {
+			return db.NotFoundError
+		}
+		row.Delivered(attempt)
+		return nil
+	})
+}
+
+func (m *MemoryDBStore) MarkChannelFailed(id string, attempt *model.DeliveryAttempt) error {
+	return m.update(func() error {
+		row := m.channelDelivery(id)
+		if row == nil || row.Status != model.OutboxPending {
+			return db.NotFoundError
+		}
+		row.Failed(attempt)
+		return nil
+	})
+}
+
+func (m *MemoryDBStore) DeleteChannelDeliveriesBefore(t time.Time) error {
+	return m.update(func() error {
+		deliveries := m.channelDeliveries[:0]
+		for _, row := range m.channelDeliveries {
+			if !expiredDelivery(&row.Delivery, t) {
+				deliveries = append(deliveries, row)
+			}
+		}
+		m.channelDeliveries = deliveries
+		return nil
+	})
+}
@@ -0,0 +1,34 @@
+package memory
+
+import (
+	"go-api/model"
+	"sort"
+	"time"
+)
+
+// claimDeliveries picks up to limit of the pending deliveries due at now, the earliest first, and pushes their next
+// attempt lease past now. Channel and webhook deliveries are claimed alike; nil ones are left out. It returns the
+// indexes of the claimed deliveries
+func claimDeliveries(deliveries []*model.Delivery, now time.Time, limit int, lease time.Duration) []int {
+	due := make([]int, 0)
+	for i, row := range deliveries {
+		if row != nil && row.Status == model.OutboxPending && !row.NextAttemptAt.After(now) {
+			due = append(due, i)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return deliveries[due[i]].NextAttemptAt.Before(deliveries[due[j]].NextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for _, i := range due {
+		deliveries[i].NextAttemptAt = now.Add(lease)
+	}
+	return due
+}
+
+// expiredDelivery tells whether a delivery is done with, delivered or dead, since before t
+func expiredDelivery(row *model.Delivery, t time.Time) bool {
+	return row.Status != model.OutboxPending && row.CreatedAt.Before(t)
+}
@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"go-api/db"
+	"go-api/model"
+	"time"
+)
+
+func (m *MemoryDBStore) GetOneFloor(id string) (*model.Floor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	row := m.floor(id)
+	if row == nil {
+		return nil, db.NotFoundError
+	}
+	floor := row.Floor
+	return &floor, nil
+}
+
+func (m *MemoryDBStore) GetAllFloors() ([]*model.Floor, error) {
+	return m.queryFloors(false), nil
+}
+
+func (m *MemoryDBStore) GetAllFloorIDs() ([]string, error) {
+	floorIDs := make([]string, 0)
+	for _, floor := range m.queryFloors(false) {
+		floorIDs = append(floorIDs, floor.ID)
+	}
+	return floorIDs, nil
+}
+
+func (m *MemoryDBStore) CreateFloor(floor *model.Floor) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	row := &floorRow{Floor: *floor}
+	row.ID = newID()
+	m.floors = append(m.floors, row)
+	return row.ID, nil
+}
+
+func (m *MemoryDBStore) RemoveFloor(id string, force bool) error {
+	return m.update(func() error {
+		now := time.Now()
+		count := 0
+		for _, booking := range m.bookings {
+			workspace := m.workspace(booking.WorkspaceID)
+			if workspace != nil && workspace.Floor == id && (!booking.StartDate.Before(now) || !booking.EndDate.Before(now)) {
+				count++
+			}
+		}
+		if count > 0 && !force {
+			return db.NewConflictError("there are existing bookings for workspaces on this floor")
+		}
+		for _, workspace := range m.workspaces {
+			if workspace.Floor == id {
+				workspace.deleted = true
+			}
+		}
+		if floor := m.floor(id); floor != nil {
+			floor.deleted = true
+		}
+		return nil
+	})
+}
+
+func (m *MemoryDBStore) GetDeletedFloors() ([]*model.Floor, error) {
+	return m.queryFloors(true), nil
+}
+
+func (m *MemoryDBStore) queryFloors(deleted bool) []*model.Floor {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	floors := make([]*model.Floor, 0)
+	for _, row := range m.floors {
+		if row.deleted == deleted {
+			floor := row.Floor
+			floors = append(floors, &floor)
+		}
+	}
+	return floors
+}
+
+func (m *MemoryDBStore) DeleteFloors(ids []string) error {
+	return m.update(func() error {
+		for _, id := range ids {
+			for _, workspace := range m.workspaces {
+				if workspace.Floor == id {
+					return foreignKeyError("workspaces", "floor_id")
+				}
+			}
+			floors := m.floors[:0]
+			for _, row := range m.floors {
+				if row.ID != id {
+					floors = append(floors, row)
+				}
+			}
+			m.floors = floors
+			// waitlist entries and blackouts go with their floor (ON DELETE CASCADE)
+			waitlist := m.waitlist[:0]
+			for _, entry := range m.waitlist {
+				if entry.FloorID != id {
+					waitlist = append(waitlist, entry)
+				}
+			}
+			m.waitlist = waitlist
+			blackouts := m.blackouts[:0]
+			for _, blackout := range m.blackouts {
+				if blackout.FloorID != id {
+					blackouts = append(blackouts, blackout)
+				}
+			}
+			m.blackouts = blackouts
+		}
+		return nil
+	})
+}
@@ -0,0 +1,20 @@
+package memory
+
+// TryLock takes the lock called name if no one holds it. The locks only exclude users of the same store
+func (m *MemoryDBStore) TryLock(name string) (func(), bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.locks[name] {
+		return nil, false, nil
+	}
+	if m.locks == nil {
+		m.locks = make(map[string]bool)
+	}
+	m.locks[name] = true
+	release := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.locks, name)
+	}
+	return release, true, nil
+}
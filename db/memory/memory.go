@@ -0,0 +1,308 @@
+package memory
+
+import (
+	"crypto/rand"
+	"fmt"
+	"go-api/db"
+	"go-api/model"
+	"go-api/utils"
+	"sync"
+	"time"
+)
+
+// MemoryDBStore keeps everything in memory, for tests and local development. It mirrors PostgresDBStore query
+// for query, including the overlap constraints of the schema, and the conformance suite in db/storetest keeps
+// the two in line
+type MemoryDBStore struct {
+	mu sync.Mutex
+	tables
+	// locks are the names of the locks held with TryLock; they aren't rows, so update never rolls them back
+	locks map[string]bool
+}
+
+// tables are the rows of the store. Pointer fields of rows (e.g. Booking.CheckedInAt) are always replaced,
+// never written through, so copying every row is enough to roll a failed change back
+type tables struct {
+	floors            []*floorRow
+	users             []*userRow
+	workspaces        []*workspaceRow
+	assignments       []*assignmentRow
+	offerings         []*offeringRow
+	offeringSeries    []*model.OfferingSeries
+	bookings          []*model.Booking
+	bookingSeries     []*model.BookingSeries
+	waitlist          []*model.WaitlistEntry
+	policy            model.BookingPolicy
+	defaultZone       *time.Location
+	blackouts         []*model.FloorBlackout
+	outbox            []*model.OutboxMessage
+	templates         []*model.EmailTemplate
+	reminders         []*reminderRow
+	channels          []*model.Channel
+	webhooks          []*model.Webhook
+	webhookDeliveries []*model.WebhookDelivery
+	channelDeliveries []*model.ChannelDelivery
+}
+
+type floorRow struct {
+	model.Floor
+	deleted bool
+}
+
+type userRow struct {
+	model.User
+	deleted bool
+}
+
+type workspaceRow struct {
+	model.Workspace
+	deleted bool
+}
+
+// assignmentRow is an assignment; open is a NULL end_time
+type assignmentRow struct {
+	model.Assignment
+	open bool
+}
+
+// offeringRow is an offering; open is a NULL end_time, which only default offerings have
+type offeringRow struct {
+	model.Offering
+	open bool
+}
+
+// reminderRow is the key of a queued reminder or digest
+type reminderRow struct {
+	key      string
+	queuedAt time.Time
+}
+
+// Scheme is the prefix of the DATABASE_URL that selects the in-memory store, e.g. "memory:"
+const Scheme = "memory:"
+
+// rangeError is what postgres reports, once translated, for a row whose range ends before it starts
+var rangeError = db.NewValidationError("end time is before start time")
+
+func NewMemoryDataStore() *db.DataStore {
+	store := &MemoryDBStore{}
+	store.users = append(store.users, &userRow{User: model.User{
+		ID:         utils.EmptyUserUUID,
+		Name:       "Default User",
+		Department: "N/A",
+		Email:      "N/A",
+	}})
+	return &db.DataStore{
+		Closable:          store,
+		WorkspaceProvider: store,
+		BookingProvider:   store,
+		OfferingProvider:  store,
+		UserProvider:      store,
+		FloorProvider:     store,
+		AssigneeProvider:  store,
+		WaitlistProvider:  store,
+		PolicyProvider:    store,
+		LockProvider:      store,
+		ArchiveProvider:   store,
+		OutboxProvider:    store,
+		TemplateProvider:  store,
+		ReminderProvider:  store,
+		ChannelProvider:   store,
+		WebhookProvider:   store,
+	}
+}
+
+func (m *MemoryDBStore) Close() {}
+
+// update runs fn with the store locked and undoes everything fn changed if it returns an error, like a transaction
+func (m *MemoryDBStore) update(fn func() error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	saved := m.tables.clone()
+	if err := fn(); err != nil {
+		m.tables = saved
+		return err
+	}
+	return nil
+}
+
+func (t tables) clone() tables {
+	c := t
+	c.floors = make([]*floorRow, len(t.floors))
+	for i, row := range t.floors {
+		copied := *row
+		c.floors[i] = &copied
+	}
+	c.users = make([]*userRow, len(t.users))
+	for i, row := range t.users {
+		copied := *row
+		c.users[i] = &copied
+	}
+	c.workspaces = make([]*workspaceRow, len(t.workspaces))
+	for i, row := range t.workspaces {
+		copied := *row
+		c.workspaces[i] = &copied
+	}
+	c.assignments = make([]*assignmentRow, len(t.assignments))
+	for i, row := range t.assignments {
+		copied := *row
+		c.assignments[i] = &copied
+	}
+	c.offerings = make([]*offeringRow, len(t.offerings))
+	for i, row := range t.offerings {
+		copied := *row
+		c.offerings[i] = &copied
+	}
+	c.offeringSeries = make([]*model.OfferingSeries, len(t.offeringSeries))
+	for i, row := range t.offeringSeries {
+		copied := *row
+		c.offeringSeries[i] = &copied
+	}
+	c.bookings = make([]*model.Booking, len(t.bookings))
+	for i, row := range t.bookings {
+		copied := *row
+		c.bookings[i] = &copied
+	}
+	c.bookingSeries = make([]*model.BookingSeries, len(t.bookingSeries))
+	for i, row := range t.bookingSeries {
+		copied := *row
+		c.bookingSeries[i] = &copied
+	}
+	c.waitlist = make([]*model.WaitlistEntry, len(t.waitlist))
+	for i, row := range t.waitlist {
+		copied := *row
+		c.waitlist[i] = &copied
+	}
+	c.blackouts = make([]*model.FloorBlackout, len(t.blackouts))
+	for i, row := range t.blackouts {
+		copied := *row
+		c.blackouts[i] = &copied
+	}
+	c.outbox = make([]*model.OutboxMessage, len(t.outbox))
+	for i, row := range t.outbox {
+		copied := *row
+		c.outbox[i] = &copied
+	}
+	c.templates = make([]*model.EmailTemplate, len(t.templates))
+	for i, row := range t.templates {
+		copied := *row
+		c.templates[i] = &copied
+	}
+	c.reminders = make([]*reminderRow, len(t.reminders))
+	for i, row := range t.reminders {
+		copied := *row
+		c.reminders[i] = &copied
+	}
+	c.channels = make([]*model.Channel, len(t.channels))
+	for i, row := range t.channels {
+		copied := *row
+		c.channels[i] = &copied
+	}
+	c.webhooks = make([]*model.Webhook, len(t.webhooks))
+	for i, row := range t.webhooks {
+		copied := *row
+		c.webhooks[i] = &copied
+	}
+	c.webhookDeliveries = make([]*model.WebhookDelivery, len(t.webhookDeliveries))
+	for i, row := range t.webhookDeliveries {
+		copied := *row
+		c.webhookDeliveries[i] = &copied
+	}
+	c.channelDeliveries = make([]*model.ChannelDelivery, len(t.channelDeliveries))
+	for i, row := range t.channelDeliveries {
+		copied := *row
+		c.channelDeliveries[i] = &copied
+	}
+	return c
+}
+
+// newID returns a random (version 4) UUID like uuid_generate_v4()
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// overlaps is the inclusive overlap the postgres queries spell out as OR'ed start/end comparisons
+func overlaps(aStart, aEnd, bStart, bEnd time.Time) bool {
+	return !aStart.After(bEnd) && !aEnd.Before(bStart)
+}
+
+// rangesOverlap is tstzrange(start, end, '[)') && as used by the exclusion constraints; open ranges are unbounded
+func rangesOverlap(aStart, aEnd time.Time, aOpen bool, bStart, bEnd time.Time, bOpen bool) bool {
+	if (!aOpen && !aStart.Before(aEnd)) || (!bOpen && !bStart.Before(bEnd)) {
+		return false // empty ranges overlap nothing
+	}
+	return (bOpen || aStart.Before(bEnd)) && (aOpen || bStart.Before(aEnd))
+}
+
+func (t *tables) floor(id string) *floorRow {
+	for _, row := range t.floors {
+		if row.ID == id {
+			return row
+		}
+	}
+	return nil
+}
+
+func (t *tables) user(id string) *userRow {
+	for _, row := range t.users {
+		if row.ID == id {
+			return row
+		}
+	}
+	return nil
+}
+
+func (t *tables) workspace(id string) *workspaceRow {
+	for _, row := range t.workspaces {
+		if row.ID == id {
+			return row
+		}
+	}
+	return nil
+}
+
+func (t *tables) booking(id string) *model.Booking {
+	for _, row := range t.bookings {
+		if row.ID == id {
+			return row
+		}
+	}
+	return nil
+}
+
+func (t *tables) assignment(id string) *assignmentRow {
+	for _, row := range t.assignments {
+		if row.ID == id {
+			return row
+		}
+	}
+	return nil
+}
+
+func (t *tables) offering(id string) *offeringRow {
+	for _, row := range t.offerings {
+		if row.ID == id {
+			return row
+		}
+	}
+	return nil
+}
+
+func (t *tables) waitlistEntry(id string) *model.WaitlistEntry {
+	for _, row := range t.waitlist {
+		if row.ID == id {
+			return row
+		}
+	}
+	return nil
+}
+
+// foreignKeyError is what inserting a row that references a missing row fails with
+func foreignKeyError(table, column string) error {
+	return fmt.Errorf("insert or update on table %q violates foreign key constraint on %q", table, column)
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"github.com/stretchr/testify/suite"
+	"go-api/db"
+	"go-api/db/storetest"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	suite.Run(t, &storetest.Suite{NewStore: func() (*db.DataStore, error) {
+		return NewMemoryDataStore(), nil
+	}})
+}
@@ -0,0 +1,153 @@
+package memory
+
+import (
+	"go-api/db"
+	"go-api/model"
+	"time"
+)
+
+// CreateOfferingSeries stores the series and offers each occurrence the workspace is assigned for and not already
+// offered; the other occurrences are returned as conflicts instead of failing the whole series
+func (m *MemoryDBStore) CreateOfferingSeries(series *model.OfferingSeries, occurrences []*model.Offering) ([]*model.Offering, []*model.SeriesConflict, error) {
+	offerings := make([]*model.Offering, 0)
+	conflicts := make([]*model.SeriesConflict, 0)
+	var unavailable *db.Error
+	err := m.update(func() error {
+		if m.user(series.UserID) == nil || m.user(series.CreatedBy) == nil {
+			return foreignKeyError("offering_series", "user_id")
+		}
+		if m.workspace(series.WorkspaceID) == nil {
+			return foreignKeyError("offering_series", "workspace_id")
+		}
+		row := *series
+		row.ID = newID()
+		row.Cancelled = false
+		m.offeringSeries = append(m.offeringSeries, &row)
+		series.ID = row.ID
+
+		for _, occurrence := range occurrences {
+			if err := m.checkOfferingAvailable(occurrence); err != nil {
+				domainErr := db.AsError(err)
+				if domainErr == nil {
+					return err
+				}
+				conflicts = append(conflicts, &model.SeriesConflict{
+					StartDate: occurrence.StartDate,
+					EndDate:   occurrence.EndDate,
+					Reason:    domainErr.Message,
+					Code:      domainErr.Code,
+				})
+				continue
+			}
+			offering := &offeringRow{Offering: model.Offering{
+				UserID:      series.UserID,
+				WorkspaceID: series.WorkspaceID,
+				StartDate:   occurrence.StartDate,
+				EndDate:     occurrence.EndDate,
+				CreatedBy:   series.CreatedBy,
+				SeriesID:    series.ID,
+			}}
+			if err := m.insertOffering(offering); err != nil {
+				return err
+			}
+			occurrence.ID = offering.ID
+			occurrence.UserID = series.UserID
+			occurrence.WorkspaceID = series.WorkspaceID
+			occurrence.CreatedBy = series.CreatedBy
+			occurrence.SeriesID = series.ID
+			offerings = append(offerings, occurrence)
+		}
+		if len(offerings) == 0 {
+			unavailable = db.NewConflictError("no occurrence of the series can be offered")
+			unavailable.Details = conflicts
+			return unavailable
+		}
+		m.queueNotification(model.OfferingSeriesNotification(model.NotificationConfirmation, series.ID, offerings), series.WorkspaceID)
+		return nil
+	})
+	if unavailable != nil {
+		// nothing could be offered: the series is rolled back but the conflicts are still reported
+		return nil, conflicts, unavailable
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return offerings, conflicts, nil
+}
+
+func (m *MemoryDBStore) GetOfferingSeries(id string) (*model.OfferingSeries, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, row := range m.offeringSeries {
+		if row.ID == id {
+			series := *row
+			return &series, nil
+		}
+	}
+	return nil, db.NotFoundError
+}
+
+func (m *MemoryDBStore) GetOfferingsBySeriesID(id string) ([]*model.Offering, error) {
+	offerings := m.queryOfferings(func(row *offeringRow) bool { return row.SeriesID == id })
+	sortOfferings(offerings)
+	return offerings, nil
+}
+
+// CancelOfferingSeries withdraws the occurrences of a series starting at or after from. As with RemoveOffering,
+// occurrences that already have bookings inside them stay offered and are returned as skipped. A zero from
+// covers every occurrence, and the series itself is marked cancelled once none of them are left
+func (m *MemoryDBStore) CancelOfferingSeries(id string, from time.Time) ([]*model.Offering, []*model.SeriesConflict, error) {
+	withdrawn := make([]*model.Offering, 0)
+	skipped := make([]*model.SeriesConflict, 0)
+	err := m.update(func() error {
+		var series *model.OfferingSeries
+		for _, row := range m.offeringSeries {
+			if row.ID == id {
+				series = row
+			}
+		}
+		if series == nil {
+			return db.NotFoundError
+		}
+		if series.Cancelled {
+			return db.NewConflictError("series is already cancelled")
+		}
+
+		occurrences := make([]*offeringRow, 0)
+		for _, row := range m.offerings {
+			if row.SeriesID == id && !row.Cancelled && !row.StartDate.Before(from) {
+				occurrences = append(occurrences, row)
+			}
+		}
+		for _, row := range occurrences {
+			if err := m.checkOfferingWithdrawable(row.WorkspaceID, row.StartDate, row.EndDate); err != nil {
+				domainErr := db.AsError(err)
+				if domainErr == nil {
+					return err
+				}
+				skipped = append(skipped, &model.SeriesConflict{
+					StartDate: row.StartDate,
+					EndDate:   row.EndDate,
+					Reason:    domainErr.Message,
+					Code:      domainErr.Code,
+				})
+				continue
+			}
+			row.Cancelled = true
+			offering := row.Offering
+			withdrawn = append(withdrawn, &offering)
+		}
+		if from.IsZero() && len(skipped) == 0 {
+			series.Cancelled = true
+		}
+		if len(withdrawn) > 0 {
+			sortOfferings(withdrawn)
+			m.queueNotification(model.OfferingSeriesNotification(model.NotificationCancellation, id, withdrawn), withdrawn[0].WorkspaceID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return withdrawn, skipped, nil
+}
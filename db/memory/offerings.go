@@ -0,0 +1,332 @@
+package memory
+
+import (
+	"go-api/db"
+	"go-api/model"
+	"go-api/utils"
+	"sort"
+	"time"
+)
+
+func (m *MemoryDBStore) GetOneOffering(id string) (*model.Offering, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	row := m.offering(id)
+	if row == nil {
+		return nil, db.NotFoundError
+	}
+	offering := row.Offering
+	return &offering, nil
+}
+
+func (m *MemoryDBStore) GetOneExpandedOffering(id string) (*model.ExpandedOffering, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	row := m.offering(id)
+	if row == nil {
+		return nil, db.NotFoundError
+	}
+	expanded := m.expandOffering(row)
+	if expanded == nil {
+		return nil, db.NotFoundError
+	}
+	return expanded, nil
+}
+
+func (m *MemoryDBStore) GetAllOfferings() ([]*model.Offering, error) {
+	return m.queryOfferings(func(row *offeringRow) bool { return true }), nil
+}
+
+func (m *MemoryDBStore) GetAllExpandedOfferings() ([]*model.ExpandedOffering, error) {
+	return m.queryExpandedOfferings(func(row *offeringRow) bool { return true }), nil
+}
+
+func (m *MemoryDBStore) GetOfferingsByWorkspaceID(id string) ([]*model.Offering, error) {
+	return m.queryOfferings(func(row *offeringRow) bool { return row.WorkspaceID == id }), nil
+}
+
+func (m *MemoryDBStore) GetExpandedOfferingsByWorkspaceID(id string) ([]*model.ExpandedOffering, error) {
+	return m.queryExpandedOfferings(func(row *offeringRow) bool { return row.WorkspaceID == id }), nil
+}
+
+func (m *MemoryDBStore) GetOfferingsByUserID(id string) ([]*model.Offering, error) {
+	return m.queryOfferings(func(row *offeringRow) bool { return row.UserID == id }), nil
+}
+
+func (m *MemoryDBStore) GetExpandedOfferingsByUserID(id string) ([]*model.ExpandedOffering, error) {
+	return m.queryExpandedOfferings(func(row *offeringRow) bool { return row.UserID == id }), nil
+}
+
+func (m *MemoryDBStore) GetOfferingsByDateRange(start time.Time, end time.Time) ([]*model.Offering, error) {
+	return m.queryOfferings(func(row *offeringRow) bool { return covers(row, start, end) }), nil
+}
+
+func (m *MemoryDBStore) GetExpandedOfferingsByDateRange(start time.Time, end time.Time) ([]*model.ExpandedOffering, error) {
+	return m.queryExpandedOfferings(func(row *offeringRow) bool { return covers(row, start, end) }), nil
+}
+
+func (m *MemoryDBStore) GetOfferingsByWorkspaceIDAndDateRange(id string, start time.Time, end time.Time) (*model.Offering, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, row := range m.offerings {
+		if row.WorkspaceID == id && covers(row, start, end) {
+			offering := row.Offering
+			return &offering, nil
+		}
+	}
+	return nil, db.NotFoundError
+}
+
+// covers reports whether the offering, cancelled or not, spans start to end; open offerings never match, as
+// comparisons with a NULL end_time are never true
+func covers(row *offeringRow, start, end time.Time) bool {
+	return !row.open && !row.StartDate.After(start) && !row.EndDate.Before(end)
+}
+
+func (m *MemoryDBStore) CreateOffering(offering *model.Offering) (string, error) {
+	row := &offeringRow{Offering: model.Offering{
+		UserID:      offering.UserID,
+		WorkspaceID: offering.WorkspaceID,
+		StartDate:   offering.StartDate,
+		EndDate:     offering.EndDate,
+		CreatedBy:   offering.CreatedBy,
+	}}
+	err := m.update(func() error {
+		if err := m.checkOfferingAvailable(offering); err != nil {
+			return err
+		}
+		if err := m.insertOffering(row); err != nil {
+			return err
+		}
+		m.queueNotification(&model.Notification{
+			Kind:      model.NotificationConfirmation,
+			Subject:   model.NotificationOffering,
+			SubjectID: row.ID,
+			UserID:    row.UserID,
+			StartDate: row.StartDate,
+			EndDate:   row.EndDate,
+		}, row.WorkspaceID)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return row.ID, nil
+}
+
+// checkOfferingAvailable makes sure the workspace is assigned for the whole offering and that no other
+// offering overlaps it
+func (t *tables) checkOfferingAvailable(offering *model.Offering) error {
+	assigned := false
+	for _, assignment := range t.assignments {
+		if assignment.WorkspaceID == offering.WorkspaceID && !assignment.StartDate.After(offering.StartDate) &&
+			(assignment.open || !assignment.EndDate.Before(offering.EndDate)) {
+			assigned = true
+			break
+		}
+	}
+	if !assigned {
+		return db.NewValidationError("unassigned workspace cannot be offered")
+	}
+	for _, row := range t.offerings {
+		if row.WorkspaceID == offering.WorkspaceID && !row.Cancelled && !row.open &&
+			overlaps(row.StartDate, row.EndDate, offering.StartDate, offering.EndDate) {
+			return db.NewConflictError("workspace already offered for this duration")
+		}
+	}
+	return nil
+}
+
+// insertOffering adds row, giving it an id, as long as it doesn't overlap another active offering of the workspace
+func (t *tables) insertOffering(row *offeringRow) error {
+	if err := t.checkOfferingRow(row); err != nil {
+		return err
+	}
+	row.ID = newID()
+	t.offerings = append(t.offerings, row)
+	return nil
+}
+
+// checkOfferingRow enforces the foreign keys and the offerings_no_overlap constraint for row
+func (t *tables) checkOfferingRow(row *offeringRow) error {
+	if t.user(row.UserID) == nil || t.user(row.CreatedBy) == nil {
+		return foreignKeyError("offerings", "user_id")
+	}
+	if t.workspace(row.WorkspaceID) == nil {
+		return foreignKeyError("offerings", "workspace_id")
+	}
+	if !row.open && row.EndDate.Before(row.StartDate) {
+		return rangeError
+	}
+	if row.Cancelled {
+		return nil
+	}
+	for _, other := range t.offerings {
+		if other.ID != row.ID && other.WorkspaceID == row.WorkspaceID && !other.Cancelled &&
+			rangesOverlap(other.StartDate, other.EndDate, other.open, row.StartDate, row.EndDate, row.open) {
+			return db.NewConflictError("workspace already offered for this duration")
+		}
+	}
+	return nil
+}
+
+func (m *MemoryDBStore) CreateDefaultOffering(offering *model.Offering) (string, error) {
+	row := defaultOffering(offering.WorkspaceID, offering.StartDate)
+	err := m.update(func() error {
+		if m.hasBookingsSince(offering.WorkspaceID, offering.StartDate) {
+			return db.NewConflictError("workspace has outstanding bookings")
+		}
+		m.endAssignments(offering.WorkspaceID, offering.StartDate)
+		m.endOfferings(offering.WorkspaceID, offering.StartDate)
+		return m.insertOffering(row)
+	})
+	if err != nil {
+		return "", err
+	}
+	return row.ID, nil
+}
+
+func (m *MemoryDBStore) UpdateOffering(id string, offering *model.Offering) error {
+	return m.update(func() error {
+		existing := m.offering(id)
+		if existing == nil {
+			return db.NotFoundError
+		}
+		row := &offeringRow{Offering: model.Offering{
+			ID:          id,
+			UserID:      offering.UserID,
+			WorkspaceID: offering.WorkspaceID,
+			Cancelled:   offering.Cancelled,
+			StartDate:   offering.StartDate,
+			EndDate:     offering.EndDate,
+			CreatedBy:   offering.CreatedBy,
+			SeriesID:    existing.SeriesID,
+		}}
+		if err := m.checkOfferingRow(row); err != nil {
+			return err
+		}
+		*existing = *row
+		return nil
+	})
+}
+
+func (m *MemoryDBStore) RemoveOffering(id string) error {
+	return m.update(func() error {
+		row := m.offering(id)
+		if row == nil {
+			return db.NotFoundError
+		}
+		if row.UserID == utils.EmptyUserUUID { // check if offering is for a unassigned workspace
+			return db.NewForbiddenError("cannot remove offerings by default user")
+		}
+		if err := m.checkOfferingWithdrawable(row.WorkspaceID, row.StartDate, row.EndDate); err != nil {
+			return err
+		}
+		row.Cancelled = true
+		m.queueNotification(&model.Notification{
+			Kind:      model.NotificationCancellation,
+			Subject:   model.NotificationOffering,
+			SubjectID: row.ID,
+			UserID:    row.UserID,
+			StartDate: row.StartDate,
+			EndDate:   row.EndDate,
+		}, row.WorkspaceID)
+		return nil
+	})
+}
+
+// checkOfferingWithdrawable makes sure no booking was made inside the offering period
+func (t *tables) checkOfferingWithdrawable(workspaceId string, start, end time.Time) error {
+	for _, booking := range t.bookings {
+		if booking.WorkspaceID == workspaceId && !booking.Cancelled &&
+			!booking.StartDate.Before(start) && !booking.EndDate.After(end) {
+			return db.NewConflictError("conflicting bookings for this offering period; cannot delete")
+		}
+	}
+	return nil
+}
+
+// GetExpiredOfferings includes ended default offerings, unlike the other queries, as the postgres query does
+func (m *MemoryDBStore) GetExpiredOfferings(since time.Time) ([]*model.Offering, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	offerings := make([]*model.Offering, 0)
+	for _, row := range m.offerings {
+		if !row.open && row.EndDate.Before(since) {
+			offering := row.Offering
+			offerings = append(offerings, &offering)
+		}
+	}
+	return offerings, nil
+}
+
+func (m *MemoryDBStore) DeleteOfferings(ids []string) error {
+	return m.update(func() error {
+		for _, id := range ids {
+			offerings := m.offerings[:0]
+			for _, row := range m.offerings {
+				if row.ID != id {
+					offerings = append(offerings, row)
+				}
+			}
+			m.offerings = offerings
+		}
+		return nil
+	})
+}
+
+// queryOfferings returns the offerings matching where; default offerings are left out like in postgres
+func (m *MemoryDBStore) queryOfferings(where func(row *offeringRow) bool) []*model.Offering {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	offerings := make([]*model.Offering, 0)
+	for _, row := range m.offerings {
+		if row.UserID != utils.EmptyUserUUID && where(row) {
+			offering := row.Offering
+			offerings = append(offerings, &offering)
+		}
+	}
+	return offerings
+}
+
+func (m *MemoryDBStore) queryExpandedOfferings(where func(row *offeringRow) bool) []*model.ExpandedOffering {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	offerings := make([]*model.ExpandedOffering, 0)
+	for _, row := range m.offerings {
+		if row.UserID == utils.EmptyUserUUID || !where(row) {
+			continue
+		}
+		if expanded := m.expandOffering(row); expanded != nil {
+			offerings = append(offerings, expanded)
+		}
+	}
+	return offerings
+}
+
+// expandOffering joins the offering with its user, workspace and floor; nil if any of them is missing
+func (t *tables) expandOffering(row *offeringRow) *model.ExpandedOffering {
+	user := t.user(row.UserID)
+	workspace := t.workspace(row.WorkspaceID)
+	if user == nil || workspace == nil {
+		return nil
+	}
+	floor := t.floor(workspace.Floor)
+	if floor == nil {
+		return nil
+	}
+	return &model.ExpandedOffering{
+		Offering:      row.Offering,
+		WorkspaceName: workspace.Name,
+		UserName:      user.Name,
+		FloorID:       floor.ID,
+		FloorName:     floor.Name,
+	}
+}
+
+// sortOfferings orders offerings by start time, as the series queries do
+func sortOfferings(offerings []*model.Offering) {
+	sort.SliceStable(offerings, func(i, j int) bool {
+		return offerings[i].StartDate.Before(offerings[j].StartDate)
+	})
+}
@@ -0,0 +1,163 @@
+package memory
+
+import (
+	"go-api/db"
+	"go-api/model"
+	"go-api/utils"
+	"sort"
+	"time"
+)
+
+// queueNotification adds n to the outbox, filling in the user's and workspace's details. Like the postgres store,
+// nothing is queued for the default user or for a user, workspace or floor that doesn't exist
+func (t *tables) queueNotification(n *model.Notification, workspaceId string) {
+	if n.UserID == utils.EmptyUserUUID {
+		return
+	}
+	user, workspace := t.user(n.UserID), t.workspace(workspaceId)
+	if user == nil || workspace == nil {
+		return
+	}
+	floor := t.floor(workspace.Floor)
+	if floor == nil {
+		return
+	}
+	n.Name, n.Email, n.Locale, n.TimeZone = user.Name, user.Email, user.Locale, user.TimeZone
+	n.WorkspaceName, n.FloorID, n.FloorName, n.FloorAddress = workspace.Name, floor.ID, floor.Name, floor.Address
+	now := time.Now()
+	t.outbox = append(t.outbox, &model.OutboxMessage{
+		ID:            newID(),
+		Notification:  *n,
+		Status:        model.OutboxPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+}
+
+// queueBookingNotifications queues n, a notification about a booking for the user it is for, then a copy of it for
+// the user who made the booking for them and for the owner of the offering the booking is in
+func (t *tables) queueBookingNotifications(n *model.Notification, workspaceId, createdBy string) {
+	t.queueNotification(n, workspaceId)
+	var owner string
+	for _, offering := range t.offerings {
+		if offering.WorkspaceID == workspaceId && !offering.Cancelled && !offering.StartDate.After(n.StartDate) &&
+			(offering.open || !offering.EndDate.Before(n.EndDate)) {
+			owner = offering.UserID
+			break
+		}
+	}
+	for _, party := range model.InterestedParties(n.UserID, createdBy, owner) {
+		copied := *n
+		copied.UserID, copied.Role, copied.BookerName = party.UserID, party.Role, n.Name
+		t.queueNotification(&copied, workspaceId)
+	}
+}
+
+func (t *tables) outboxMessage(id string) *model.OutboxMessage {
+	for _, row := range t.outbox {
+		if row.ID == id {
+			return row
+		}
+	}
+	return nil
+}
+
+func (m *MemoryDBStore) ClaimOutboxMessages(now time.Time, limit int, lease time.Duration) ([]*model.OutboxMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	due := make([]*model.OutboxMessage, 0)
+	for _, row := range m.outbox {
+		if row.Status == model.OutboxPending && !row.NextAttemptAt.After(now) {
+			due = append(due, row)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	messages := make([]*model.OutboxMessage, 0, len(due))
+	for _, row := range due {
+		row.NextAttemptAt = now.Add(lease)
+		message := *row
+		messages = append(messages, &message)
+	}
+	return messages, nil
+}
+
+func (m *MemoryDBStore) MarkOutboxDelivered(id string, at time.Time) error {
+	return m.update(func() error {
+		row := m.outboxMessage(id)
+		if row == nil || row.Status != model.OutboxPending {
+			return db.NotFoundError
+		}
+		row.Status = model.OutboxDelivered
+		row.Attempts++
+		row.DeliveredAt = &at
+		return nil
+	})
+}
+
+func (m *MemoryDBStore) MarkOutboxFailed(id string, reason string, next *time.Time) error {
+	return m.update(func() error {
+		row := m.outboxMessage(id)
+		if row == nil || row.Status != model.OutboxPending {
+			return db.NotFoundError
+		}
+		row.Attempts++
+		row.LastError = reason
+		if next == nil {
+			row.Status = model.OutboxDead
+		} else {
+			row.NextAttemptAt = *next
+		}
+		return nil
+	})
+}
+
+func (m *MemoryDBStore) GetOutboxMessages(status string) ([]*model.OutboxMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	messages := make([]*model.OutboxMessage, 0)
+	// newest first, also when the clock gave two messages the same time
+	for i := len(m.outbox) - 1; i >= 0; i-- {
+		if row := m.outbox[i]; status == "" || row.Status == status {
+			message := *row
+			messages = append(messages, &message)
+		}
+	}
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].CreatedAt.After(messages[j].CreatedAt) })
+	return messages, nil
+}
+
+func (m *MemoryDBStore) GetOneOutboxMessage(id string) (*model.OutboxMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	row := m.outboxMessage(id)
+	if row == nil {
+		return nil, db.NotFoundError
+	}
+	message := *row
+	return &message, nil
+}
+
+func (m *MemoryDBStore) ReplayOutboxMessage(id string, now time.Time) (*model.OutboxMessage, error) {
+	var message model.OutboxMessage
+	err := m.update(func() error {
+		row := m.outboxMessage(id)
+		if row == nil {
+			return db.NotFoundError
+		}
+		if row.Status != model.OutboxDead {
+			return db.NewConflictError("only dead messages can be replayed, this one is %s", row.Status)
+		}
+		row.Status = model.OutboxPending
+		row.Attempts = 0
+		row.NextAttemptAt = now
+		message = *row
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"go-api/db"
+	"go-api/model"
+	"sort"
+	"time"
+)
+
+func (m *MemoryDBStore) GetBookingPolicy() (*model.BookingPolicy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	policy := m.policy
+	return &policy, nil
+}
+
+func (m *MemoryDBStore) UpdateBookingPolicy(policy *model.BookingPolicy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.policy = *policy
+	return nil
+}
+
+func (m *MemoryDBStore) SetDefaultZone(zone *time.Location) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.defaultZone = zone
+}
+
+func (m *MemoryDBStore) GetFloorBlackouts(floorId string) ([]*model.FloorBlackout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	blackouts := make([]*model.FloorBlackout, 0)
+	for _, row := range m.blackouts {
+		if row.FloorID == floorId {
+			blackout := *row
+			blackouts = append(blackouts, &blackout)
+		}
+	}
+	sort.SliceStable(blackouts, func(i, j int) bool {
+		return blackouts[i].StartDate.Before(blackouts[j].StartDate)
+	})
+	return blackouts, nil
+}
+
+func (m *MemoryDBStore) CreateFloorBlackout(blackout *model.FloorBlackout) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.floor(blackout.FloorID) == nil {
+		return "", foreignKeyError("floor_blackouts", "floor_id")
+	}
+	row := &model.FloorBlackout{
+		ID:        newID(),
+		FloorID:   blackout.FloorID,
+		StartDate: blackout.StartDate,
+		EndDate:   blackout.EndDate,
+		Reason:    blackout.Reason,
+	}
+	m.blackouts = append(m.blackouts, row)
+	return row.ID, nil
+}
+
+func (m *MemoryDBStore) RemoveFloorBlackout(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, row := range m.blackouts {
+		if row.ID == id {
+			m.blackouts = append(m.blackouts[:i], m.blackouts[i+1:]...)
+			return nil
+		}
+	}
+	return db.NotFoundError
+}
+
+// checkBookingPolicy makes sure the booking follows the booking policy and doesn't fall in a blackout of
+// the workspace's floor. Like checkBookingAvailable, booking.ID is left out when counting other bookings
+func (t *tables) checkBookingPolicy(booking *model.Booking) error {
+	if workspace := t.workspace(booking.WorkspaceID); workspace != nil {
+		var blackout *model.FloorBlackout
+		for _, row := range t.blackouts {
+			if row.FloorID == workspace.Floor && row.StartDate.Before(booking.EndDate) && row.EndDate.After(booking.StartDate) &&
+				(blackout == nil || row.StartDate.Before(blackout.StartDate)) {
+				blackout = row
+			}
+		}
+		if blackout != nil {
+			if blackout.Reason != "" {
+				return db.NewPolicyError(db.PolicyFloorBlackout, "the floor is closed for booking during this time (%s)", blackout.Reason)
+			}
+			return db.NewPolicyError(db.PolicyFloorBlackout, "the floor is closed for booking during this time")
+		}
+	}
+
+	policy := t.policy
+	now := time.Now()
+	if policy.MaxAdvanceDays > 0 && booking.StartDate.After(now.AddDate(0, 0, policy.MaxAdvanceDays)) {
+		return db.NewPolicyError(db.PolicyAdvanceWindow, "bookings can be made at most %d days in advance", policy.MaxAdvanceDays)
+	}
+	if policy.MaxDurationHours > 0 && booking.EndDate.Sub(booking.StartDate) > time.Duration(policy.MaxDurationHours)*time.Hour {
+		return db.NewPolicyError(db.PolicyMaxDuration, "bookings can be at most %d hours long", policy.MaxDurationHours)
+	}
+	if policy.MaxFutureBookings > 0 && booking.EndDate.After(now) {
+		count := 0
+		for _, other := range t.bookings {
+			if other.UserID == booking.UserID && !other.Cancelled && other.EndDate.After(now) && other.ID != booking.ID {
+				count++
+			}
+		}
+		if count >= policy.MaxFutureBookings {
+			return db.NewPolicyError(db.PolicyFutureBookings, "users can have at most %d upcoming bookings", policy.MaxFutureBookings)
+		}
+	}
+	if policy.MaxDepartmentDaily > 0 {
+		user := t.user(booking.UserID)
+		if user == nil {
+			return nil
+		}
+		// Find the first day of the booking on which the user's department is already at the cap
+		for _, day := range db.UserDays(user.TimeZone, t.defaultZone, booking.StartDate, booking.EndDate) {
+			count := 0
+			for _, other := range t.bookings {
+				bookedBy := t.user(other.UserID)
+				if bookedBy != nil && bookedBy.Department == user.Department && !other.Cancelled && other.ID != booking.ID &&
+					other.StartDate.Before(day.AddDate(0, 0, 1)) && other.EndDate.After(day) {
+					count++
+				}
+			}
+			if count >= policy.MaxDepartmentDaily {
+				return db.NewPolicyError(db.PolicyDepartmentDaily, "the department already has %d bookings on %s", policy.MaxDepartmentDaily, day.Format("2006-01-02"))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"go-api/model"
+	"go-api/utils"
+	"time"
+)
+
+func (m *MemoryDBStore) QueueReminder(key string, n *model.Notification) (bool, error) {
+	queued := false
+	err := m.update(func() error {
+		user := m.user(n.UserID)
+		if n.UserID == utils.EmptyUserUUID || user == nil || user.deleted {
+			return nil
+		}
+		if (n.Kind == model.NotificationDigest && user.DigestOptOut) || (n.Kind != model.NotificationDigest && user.RemindersOptOut) {
+			return nil
+		}
+		for _, row := range m.reminders {
+			if row.key == key {
+				return nil
+			}
+		}
+		now := time.Now()
+		m.reminders = append(m.reminders, &reminderRow{key: key, queuedAt: now})
+		n.Name, n.Email, n.Locale, n.TimeZone = user.Name, user.Email, user.Locale, user.TimeZone
+		m.outbox = append(m.outbox, &model.OutboxMessage{
+			ID:            newID(),
+			Notification:  *n,
+			Status:        model.OutboxPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+		queued = true
+		return nil
+	})
+	return queued, err
+}
+
+func (m *MemoryDBStore) DeleteRemindersBefore(t time.Time) error {
+	return m.update(func() error {
+		kept := m.reminders[:0]
+		for _, row := range m.reminders {
+			if !row.queuedAt.Before(t) {
+				kept = append(kept, row)
+			}
+		}
+		m.reminders = kept
+		return nil
+	})
+}
@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"go-api/db"
+	"go-api/model"
+	"sort"
+	"time"
+)
+
+func (t *tables) emailTemplate(event, locale string) *model.EmailTemplate {
+	for _, row := range t.templates {
+		if row.Event == event && row.Locale == locale {
+			return row
+		}
+	}
+	return nil
+}
+
+func (m *MemoryDBStore) GetEmailTemplates() ([]*model.EmailTemplate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	templates := make([]*model.EmailTemplate, 0, len(m.templates))
+	for _, row := range m.templates {
+		template := *row
+		templates = append(templates, &template)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i].Event != templates[j].Event {
+			return templates[i].Event < templates[j].Event
+		}
+		return templates[i].Locale < templates[j].Locale
+	})
+	return templates, nil
+}
+
+func (m *MemoryDBStore) GetEmailTemplate(event, locale string) (*model.EmailTemplate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	row := m.emailTemplate(event, locale)
+	if row == nil {
+		return nil, db.NotFoundError
+	}
+	template := *row
+	return &template, nil
+}
+
+func (m *MemoryDBStore) UpsertEmailTemplate(template *model.EmailTemplate) error {
+	return m.update(func() error {
+		template.UpdatedAt = time.Now()
+		row := *template
+		if existing := m.emailTemplate(template.Event, template.Locale); existing != nil {
+			*existing = row
+			return nil
+		}
+		m.templates = append(m.templates, &row)
+		return nil
+	})
+}
+
+func (m *MemoryDBStore) RemoveEmailTemplate(event, locale string) error {
+	return m.update(func() error {
+		for i, row := range m.templates {
+			if row.Event == event && row.Locale == locale {
+				m.templates = append(m.templates[:i], m.templates[i+1:]...)
+				return nil
+			}
+		}
+		return db.NotFoundError
+	})
+}
@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"fmt"
+	"go-api/db"
+	"go-api/model"
+	"go-api/utils"
+	"time"
+)
+
+func (m *MemoryDBStore) GetOneUser(id string) (*model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	row := m.user(id)
+	if row == nil {
+		return nil, db.NotFoundError
+	}
+	user := row.User
+	return &user, nil
+}
+
+func (m *MemoryDBStore) GetAllUsers() ([]*model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	users := make([]*model.User, 0)
+	for _, row := range m.users {
+		if !row.deleted && row.ID != utils.EmptyUserUUID {
+			user := row.User
+			users = append(users, &user)
+		}
+	}
+	return users, nil
+}
+
+func (m *MemoryDBStore) CreateUser(user *model.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.user(user.ID) != nil {
+		return fmt.Errorf("duplicate key value violates unique constraint \"users_pkey\"")
+	}
+	m.users = append(m.users, &userRow{User: *user})
+	return nil
+}
+
+func (m *MemoryDBStore) UpdateUserPreferences(id string, preferences *model.UserPreferences) error {
+	return m.update(func() error {
+		row := m.user(id)
+		if row == nil || row.deleted {
+			return db.NotFoundError
+		}
+		row.Locale, row.TimeZone = preferences.Locale, preferences.TimeZone
+		row.RemindersOptOut, row.DigestOptOut = preferences.RemindersOptOut, preferences.DigestOptOut
+		return nil
+	})
+}
+
+// GetAssignedUsers returns the users assigned a workspace for the whole period who haven't offered it during any of it
+func (m *MemoryDBStore) GetAssignedUsers(start, end time.Time) ([]*model.UserAssignment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	offered := make(map[string]bool)
+	for _, offering := range m.offerings {
+		if !offering.Cancelled && !offering.open && offering.UserID != utils.EmptyUserUUID &&
+			overlaps(offering.StartDate, offering.EndDate, start, end) {
+			offered[offering.UserID] = true
+		}
+	}
+	users := make([]*model.UserAssignment, 0)
+	for _, assignment := range m.assignments {
+		user := m.user(assignment.UserID)
+		workspace := m.workspace(assignment.WorkspaceID)
+		if user == nil || user.deleted || workspace == nil || workspace.deleted {
+			continue
+		}
+		if assignment.StartDate.After(start) || (!assignment.open && assignment.EndDate.Before(end)) {
+			continue
+		}
+		if !offered[user.ID] {
+			users = append(users, userAssignment(user, assignment))
+		}
+	}
+	return users, nil
+}
+
+func (m *MemoryDBStore) GetAssignedUsersByTime(timestamp time.Time) ([]*model.UserAssignment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	users := make([]*model.UserAssignment, 0)
+	for _, assignment := range m.assignments {
+		user := m.user(assignment.UserID)
+		workspace := m.workspace(assignment.WorkspaceID)
+		if user == nil || workspace == nil || workspace.deleted {
+			continue
+		}
+		if assignment.StartDate.After(timestamp) || (!assignment.open && assignment.EndDate.Before(timestamp)) {
+			continue
+		}
+		users = append(users, userAssignment(user, assignment))
+	}
+	return users, nil
+}
+
+func userAssignment(user *userRow, assignment *assignmentRow) *model.UserAssignment {
+	return &model.UserAssignment{
+		ID:          user.ID,
+		Name:        user.Name,
+		Department:  user.Department,
+		IsAdmin:     user.IsAdmin,
+		Email:       user.Email,
+		WorkspaceId: assignment.WorkspaceID,
+	}
+}
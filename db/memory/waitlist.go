@@ -0,0 +1,288 @@
+package memory
+
+import (
+	"encoding/json"
+	"go-api/db"
+	"go-api/model"
+	"reflect"
+	"sort"
+	"time"
+)
+
+func (m *MemoryDBStore) CreateWaitlistEntry(entry *model.WaitlistEntry) (string, error) {
+	if entry.Props == nil {
+		entry.Props = model.Attrs{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.user(entry.UserID) == nil {
+		return "", foreignKeyError("waitlist", "user_id")
+	}
+	if m.floor(entry.FloorID) == nil {
+		return "", foreignKeyError("waitlist", "floor_id")
+	}
+	row := &model.WaitlistEntry{
+		ID:        newID(),
+		UserID:    entry.UserID,
+		FloorID:   entry.FloorID,
+		Props:     entry.Props,
+		StartDate: entry.StartDate,
+		EndDate:   entry.EndDate,
+		AutoBook:  entry.AutoBook,
+		Status:    model.WaitlistWaiting,
+		CreatedAt: time.Now(),
+	}
+	m.waitlist = append(m.waitlist, row)
+	return row.ID, nil
+}
+
+func (m *MemoryDBStore) GetOneWaitlistEntry(id string) (*model.WaitlistEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	row := m.waitlistEntry(id)
+	if row == nil {
+		return nil, db.NotFoundError
+	}
+	entry := *row
+	return &entry, nil
+}
+
+func (m *MemoryDBStore) GetWaitlistByUserID(id string) ([]*model.WaitlistEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := make([]*model.WaitlistEntry, 0)
+	for _, row := range m.waitlist {
+		if row.UserID == id {
+			entry := *row
+			entries = append(entries, &entry)
+		}
+	}
+	sortWaitlist(entries)
+	return entries, nil
+}
+
+// CancelWaitlistEntry takes a waiting or held entry off the waitlist and returns it as it was before,
+// so the caller can tell whether a hold was released
+func (m *MemoryDBStore) CancelWaitlistEntry(id string) (*model.WaitlistEntry, error) {
+	var entry model.WaitlistEntry
+	err := m.update(func() error {
+		row := m.waitlistEntry(id)
+		if row == nil {
+			return db.NotFoundError
+		}
+		if row.Status != model.WaitlistWaiting && row.Status != model.WaitlistHeld {
+			return db.NewConflictError("waitlist entry is already %s", row.Status)
+		}
+		entry = *row
+		row.Status = model.WaitlistCancelled
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// ClaimWaitlistHold books the workspace held for the entry. An expired hold is marked as such and an
+// invalid operation error is returned
+func (m *MemoryDBStore) ClaimWaitlistHold(id string) (string, error) {
+	var bookingID string
+	expired := false
+	err := m.update(func() error {
+		row := m.waitlistEntry(id)
+		if row == nil {
+			return db.NotFoundError
+		}
+		if row.Status != model.WaitlistHeld {
+			return db.NewConflictError("waitlist entry has no hold to claim")
+		}
+		if row.HoldExpiresAt == nil || !row.HoldExpiresAt.After(time.Now()) {
+			// the entry stays expired, so this change isn't rolled back
+			row.Status = model.WaitlistExpired
+			expired = true
+			return nil
+		}
+		var err error
+		bookingID, err = m.bookWaitlistEntry(row, row.HoldWorkspaceID)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if expired {
+		return "", db.HoldExpiredError
+	}
+	return bookingID, nil
+}
+
+// ProcessWaitlist offers a workspace that has just become free between start and end to the oldest waiting entry
+// it suits: one on the workspace's floor, asking for properties the workspace has, and whose whole time range the
+// workspace is now available for. A zero end, e.g. of an open-ended default offering, frees the workspace from start on.
+// The entry is booked or given a hold until holdUntil, its user is notified of either through the outbox, and it is
+// returned; nil is returned when nobody on the waitlist can use the workspace
+func (m *MemoryDBStore) ProcessWaitlist(workspaceId string, start, end time.Time, holdUntil time.Time) (*model.WaitlistEntry, error) {
+	var processed *model.WaitlistEntry
+	err := m.update(func() error {
+		workspace := m.workspace(workspaceId)
+		if workspace == nil || workspace.deleted {
+			return nil
+		}
+		candidates := make([]*model.WaitlistEntry, 0)
+		for _, row := range m.waitlist {
+			if row.FloorID == workspace.Floor && row.Status == model.WaitlistWaiting &&
+				(end.IsZero() || !row.StartDate.After(end)) && !row.EndDate.Before(start) && contains(workspace.Props, row.Props) {
+				candidates = append(candidates, row)
+			}
+		}
+		sortWaitlist(candidates)
+
+		for _, row := range candidates {
+			err := m.checkBookingAvailable(&model.Booking{
+				WorkspaceID: workspaceId,
+				UserID:      row.UserID,
+				StartDate:   row.StartDate,
+				EndDate:     row.EndDate,
+			})
+			if err != nil {
+				if db.AsError(err) != nil {
+					continue
+				}
+				return err
+			}
+			if row.AutoBook {
+				if _, err = m.bookWaitlistEntry(row, workspaceId); err != nil {
+					return err
+				}
+			} else {
+				holdExpiresAt := holdUntil
+				row.Status = model.WaitlistHeld
+				row.HoldWorkspaceID = workspaceId
+				row.HoldExpiresAt = &holdExpiresAt
+				m.queueNotification(&model.Notification{
+					Kind:          model.NotificationHold,
+					Subject:       model.NotificationWaitlist,
+					SubjectID:     row.ID,
+					UserID:        row.UserID,
+					StartDate:     row.StartDate,
+					EndDate:       row.EndDate,
+					HoldExpiresAt: &holdExpiresAt,
+				}, workspaceId)
+			}
+			entry := *row
+			processed = &entry
+			return nil
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return processed, nil
+}
+
+// ExpireWaitlistHolds marks the holds that ran out by now as expired and returns their entries, so the workspaces
+// they held can be offered to whoever is next
+func (m *MemoryDBStore) ExpireWaitlistHolds(now time.Time) ([]*model.WaitlistEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	expired := make([]*model.WaitlistEntry, 0)
+	for _, row := range m.waitlist {
+		if row.Status == model.WaitlistHeld && row.HoldExpiresAt != nil && !row.HoldExpiresAt.After(now) {
+			row.Status = model.WaitlistExpired
+			entry := *row
+			expired = append(expired, &entry)
+		}
+	}
+	return expired, nil
+}
+
+// bookWaitlistEntry books workspaceId for the entry's user and time range, marks the entry as booked and queues the
+// booking's confirmation
+func (t *tables) bookWaitlistEntry(entry *model.WaitlistEntry, workspaceId string) (string, error) {
+	booking := &model.Booking{
+		WorkspaceID: workspaceId,
+		UserID:      entry.UserID,
+		StartDate:   entry.StartDate,
+		EndDate:     entry.EndDate,
+		CreatedBy:   entry.UserID,
+	}
+	if err := t.checkBookingAvailable(booking); err != nil {
+		return "", err
+	}
+	if err := t.insertBooking(booking); err != nil {
+		return "", err
+	}
+	entry.Status = model.WaitlistBooked
+	entry.BookingID = booking.ID
+	entry.HoldWorkspaceID = workspaceId
+	t.queueBookingNotifications(&model.Notification{
+		Kind:      model.NotificationConfirmation,
+		Subject:   model.NotificationBooking,
+		SubjectID: booking.ID,
+		UserID:    booking.UserID,
+		StartDate: booking.StartDate,
+		EndDate:   booking.EndDate,
+	}, workspaceId, booking.CreatedBy)
+	return booking.ID, nil
+}
+
+// contains is jsonb's @>: every property asked for is in props, with the same value. Both sides go through
+// JSON first so that values compare as they would once stored
+func contains(props, wanted model.Attrs) bool {
+	var have, want map[string]interface{}
+	if !normalize(props, &have) || !normalize(wanted, &want) {
+		return false
+	}
+	return containsValue(have, want)
+}
+
+func normalize(attrs model.Attrs, into *map[string]interface{}) bool {
+	b, err := json.Marshal(attrs)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(b, into) == nil
+}
+
+func containsValue(have, want interface{}) bool {
+	switch want := want.(type) {
+	case map[string]interface{}:
+		have, ok := have.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range want {
+			if !containsValue(have[key], value) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		have, ok := have.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, value := range want {
+			found := false
+			for _, candidate := range have {
+				if containsValue(candidate, value) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(have, want)
+	}
+}
+
+// sortWaitlist orders entries oldest first, which is their turn on the waitlist
+func sortWaitlist(entries []*model.WaitlistEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+}
@@ -0,0 +1,221 @@
+package memory
+
+import (
+	"go-api/db"
+	"go-api/model"
+	"sort"
+	"time"
+)
+
+func (t *tables) webhook(id string) *model.Webhook {
+	for _, row := range t.webhooks {
+		if row.ID == id {
+			return row
+		}
+	}
+	return nil
+}
+
+func (t *tables) webhookDelivery(id string) *model.WebhookDelivery {
+	for _, row := range t.webhookDeliveries {
+		if row.ID == id {
+			return row
+		}
+	}
+	return nil
+}
+
+func (m *MemoryDBStore) GetWebhooks() ([]*model.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	webhooks := make([]*model.Webhook, 0, len(m.webhooks))
+	for _, row := range m.webhooks {
+		webhook := *row
+		webhooks = append(webhooks, &webhook)
+	}
+	return webhooks, nil
+}
+
+func (m *MemoryDBStore) GetOneWebhook(id string) (*model.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	row := m.webhook(id)
+	if row == nil {
+		return nil, db.NotFoundError
+	}
+	webhook := *row
+	return &webhook, nil
+}
+
+func (m *MemoryDBStore) CreateWebhook(webhook *model.Webhook) (string, error) {
+	err := m.update(func() error {
+		if webhook.FloorID != "" && m.floor(webhook.FloorID) == nil {
+			return db.NotFoundError
+		}
+		if webhook.Events == nil {
+			webhook.Events = []string{}
+		}
+		webhook.ID, webhook.CreatedAt = newID(), time.Now()
+		row := *webhook
+		m.webhooks = append(m.webhooks, &row)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return webhook.ID, nil
+}
+
+func (m *MemoryDBStore) UpdateWebhook(id string, webhook *model.Webhook) error {
+	return m.update(func() error {
+		row := m.webhook(id)
+		if row == nil || (webhook.FloorID != "" && m.floor(webhook.FloorID) == nil) {
+			return db.NotFoundError
+		}
+		if webhook.Events == nil {
+			webhook.Events = []string{}
+		}
+		row.URL, row.Secret, row.Events, row.FloorID = webhook.URL, webhook.Secret, webhook.Events, webhook.FloorID
+		row.Active, row.FailingSince, row.DisabledAt = webhook.Active, webhook.FailingSince, webhook.DisabledAt
+		return nil
+	})
+}
+
+func (m *MemoryDBStore) RemoveWebhook(id string) error {
+	return m.update(func() error {
+		if m.webhook(id) == nil {
+			return db.NotFoundError
+		}
+		m.removeWebhooks(map[string]bool{id: true})
+		return nil
+	})
+}
+
+// removeWebhooks removes the webhooks whose ids are set and their deliveries (ON DELETE CASCADE)
+func (t *tables) removeWebhooks(ids map[string]bool) {
+	webhooks := t.webhooks[:0]
+	for _, row := range t.webhooks {
+		if !ids[row.ID] {
+			webhooks = append(webhooks, row)
+		}
+	}
+	t.webhooks = webhooks
+	deliveries := t.webhookDeliveries[:0]
+	for _, row := range t.webhookDeliveries {
+		if !ids[row.WebhookID] {
+			deliveries = append(deliveries, row)
+		}
+	}
+	t.webhookDeliveries = deliveries
+}
+
+func (m *MemoryDBStore) QueueWebhookDeliveries(eventType, floorId string, payload []byte) (int, error) {
+	count := 0
+	err := m.update(func() error {
+		now := time.Now()
+		for _, webhook := range m.webhooks {
+			if !webhook.Active || !webhook.Wants(eventType, floorId) {
+				continue
+			}
+			m.webhookDeliveries = append(m.webhookDeliveries, &model.WebhookDelivery{
+				Delivery: model.Delivery{
+					ID:            newID(),
+					Event:         eventType,
+					Status:        model.OutboxPending,
+					NextAttemptAt: now,
+					CreatedAt:     now,
+				},
+				WebhookID: webhook.ID,
+				Payload:   append([]byte(nil), payload...),
+			})
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (m *MemoryDBStore) ClaimWebhookDeliveries(now time.Time, limit int, lease time.Duration) ([]*model.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// the deliveries of inactive webhooks wait until they are active again
+	states := make([]*model.Delivery, len(m.webhookDeliveries))
+	for i, row := range m.webhookDeliveries {
+		if webhook := m.webhook(row.WebhookID); webhook != nil && webhook.Active {
+			states[i] = &row.Delivery
+		}
+	}
+	deliveries := make([]*model.WebhookDelivery, 0)
+	for _, i := range claimDeliveries(states, now, limit, lease) {
+		delivery := *m.webhookDeliveries[i]
+		deliveries = append(deliveries, &delivery)
+	}
+	return deliveries, nil
+}
+
+func (m *MemoryDBStore) MarkWebhookDelivered(id string, attempt *model.DeliveryAttempt) error {
+	return m.update(func() error {
+		row := m.webhookDelivery(id)
+		if row == nil || row.Status != model.OutboxPending {
+			return db.NotFoundError
+		}
+		row.Delivered(attempt)
+		if webhook := m.webhook(row.WebhookID); webhook != nil {
+			webhook.FailingSince = nil
+		}
+		return nil
+	})
+}
+
+func (m *MemoryDBStore) MarkWebhookFailed(id string, attempt *model.DeliveryAttempt, disableBefore time.Time) (bool, error) {
+	disable := false
+	err := m.update(func() error {
+		row := m.webhookDelivery(id)
+		if row == nil || row.Status != model.OutboxPending {
+			return db.NotFoundError
+		}
+		row.Failed(attempt)
+		webhook := m.webhook(row.WebhookID)
+		if webhook == nil {
+			return nil
+		}
+		if webhook.FailingSince == nil {
+			at := attempt.At
+			webhook.FailingSince = &at
+		}
+		if webhook.Active && webhook.FailingSince.Before(disableBefore) {
+			at := attempt.At
+			webhook.Active, webhook.DisabledAt, disable = false, &at, true
+		}
+		return nil
+	})
+	return disable, err
+}
+
+func (m *MemoryDBStore) GetWebhookDeliveries(webhookId, status string) ([]*model.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deliveries := make([]*model.WebhookDelivery, 0)
+	// newest first, also when the clock gave two deliveries the same time
+	for i := len(m.webhookDeliveries) - 1; i >= 0; i-- {
+		if row := m.webhookDeliveries[i]; row.WebhookID == webhookId && (status == "" || row.Status == status) {
+			delivery := *row
+			deliveries = append(deliveries, &delivery)
+		}
+	}
+	sort.SliceStable(deliveries, func(i, j int) bool { return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt) })
+	return deliveries, nil
+}
+
+func (m *MemoryDBStore) DeleteWebhookDeliveriesBefore(t time.Time) error {
+	return m.update(func() error {
+		deliveries := m.webhookDeliveries[:0]
+		for _, row := range m.webhookDeliveries {
+			if !expiredDelivery(&row.Delivery, t) {
+				deliveries = append(deliveries, row)
+			}
+		}
+		m.webhookDeliveries = deliveries
+		return nil
+	})
+}
@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"go-api/model"
+	"time"
+)
+
+func (m *MemoryDBStore) IsAssigned(id string, startTime time.Time, endTime time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, assignment := range m.assignments {
+		// comparisons with a NULL end_time are never true, so current assignments don't count
+		if assignment.WorkspaceID == id && !assignment.open &&
+			overlaps(assignment.StartDate, assignment.EndDate, startTime, endTime) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryDBStore) IsFullyAssigned(id string, startTime time.Time, endTime time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, assignment := range m.assignments {
+		if assignment.WorkspaceID == id && !assignment.open &&
+			!assignment.StartDate.After(startTime) && !assignment.EndDate.Before(endTime) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryDBStore) GetExpiredAssignments(since time.Time) ([]*model.Assignment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	assignments := make([]*model.Assignment, 0)
+	for _, row := range m.assignments {
+		if !row.open && row.EndDate.Before(since) {
+			assignment := row.Assignment
+			assignments = append(assignments, &assignment)
+		}
+	}
+	return assignments, nil
+}
+
+func (m *MemoryDBStore) DeleteAssignments(ids []string) error {
+	return m.update(func() error {
+		for _, id := range ids {
+			assignments := m.assignments[:0]
+			for _, row := range m.assignments {
+				if row.ID != id {
+					assignments = append(assignments, row)
+				}
+			}
+			m.assignments = assignments
+		}
+		return nil
+	})
+}
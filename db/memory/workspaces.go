@@ -0,0 +1,316 @@
+package memory
+
+import (
+	"go-api/db"
+	"go-api/model"
+	"go-api/utils"
+	"time"
+)
+
+func (m *MemoryDBStore) GetOneWorkspace(id string) (*model.Workspace, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	row := m.workspace(id)
+	if row == nil {
+		return nil, db.NotFoundError
+	}
+	workspace := row.Workspace
+	return &workspace, nil
+}
+
+func (m *MemoryDBStore) UpdateWorkspace(id string, workspace *model.Workspace) error {
+	return m.update(func() error {
+		for _, row := range m.workspaces {
+			if row.Name == workspace.Name && row.Floor == workspace.Floor && row.ID != id {
+				return db.NewConflictError("workspace name already exists")
+			}
+		}
+		row := m.workspace(id)
+		if row == nil {
+			return db.NotFoundError
+		}
+		if m.floor(workspace.Floor) == nil {
+			return foreignKeyError("workspaces", "floor_id")
+		}
+		row.Name = workspace.Name
+		row.Floor = workspace.Floor
+		row.Details = workspace.Details
+		workspace.ID = id
+		return nil
+	})
+}
+
+func (m *MemoryDBStore) UpdateWorkspaceMetadata(id string, properties *model.Attrs) error {
+	return m.update(func() error {
+		row := m.workspace(id)
+		if row == nil {
+			return db.NotFoundError
+		}
+		row.Props = *properties
+		return nil
+	})
+}
+
+func (m *MemoryDBStore) CreateWorkspace(workspace *model.Workspace) (string, error) {
+	var id string
+	err := m.update(func() error {
+		var err error
+		id, err = m.createWorkspace(workspace)
+		return err
+	})
+	return id, err
+}
+
+func (t *tables) createWorkspace(workspace *model.Workspace) (string, error) {
+	for _, row := range t.workspaces {
+		if row.Name == workspace.Name && row.Floor == workspace.Floor {
+			return "", db.NewConflictError("workspace name: %s already exists on floor: %s", workspace.Name, workspace.Floor)
+		}
+	}
+	if t.floor(workspace.Floor) == nil {
+		return "", foreignKeyError("workspaces", "floor_id")
+	}
+	row := &workspaceRow{Workspace: *workspace}
+	row.ID = newID()
+	t.workspaces = append(t.workspaces, row)
+	return row.ID, nil
+}
+
+func (m *MemoryDBStore) UpsertWorkspace(workspace *model.Workspace) (string, error) {
+	var id string
+	err := m.update(func() error {
+		if workspace.ID == "" {
+			for _, row := range m.workspaces {
+				if row.Name == workspace.Name && row.Floor == workspace.Floor {
+					workspace.ID = row.ID
+					break
+				}
+			}
+		}
+		if workspace.ID == "" {
+			var err error
+			id, err = m.createWorkspace(workspace)
+			return err
+		}
+		row := m.workspace(workspace.ID)
+		if row == nil {
+			return db.NotFoundError
+		}
+		row.Name = workspace.Name
+		row.Floor = workspace.Floor
+		row.Props = workspace.Props
+		row.Details = workspace.Details
+		id = row.ID
+		return nil
+	})
+	return id, err
+}
+
+func (m *MemoryDBStore) RemoveWorkspace(id string) error {
+	return m.update(func() error {
+		row := m.workspace(id)
+		if row == nil {
+			return db.NotFoundError
+		}
+		row.deleted = true
+		return nil
+	})
+}
+
+func (m *MemoryDBStore) GetAllWorkspaces() ([]*model.Workspace, error) {
+	return m.queryWorkspaces(func(row *workspaceRow) bool { return !row.deleted }), nil
+}
+
+func (m *MemoryDBStore) GetAllWorkspacesByFloor(floorId string) ([]*model.Workspace, error) {
+	return m.queryWorkspaces(func(row *workspaceRow) bool { return row.Floor == floorId && !row.deleted }), nil
+}
+
+func (m *MemoryDBStore) CountWorkspacesByFloor(floorId string) (int, error) {
+	return len(m.queryWorkspaces(func(row *workspaceRow) bool { return row.Floor == floorId })), nil
+}
+
+func (m *MemoryDBStore) GetDeletedWorkspaces() ([]*model.Workspace, error) {
+	return m.queryWorkspaces(func(row *workspaceRow) bool { return row.deleted }), nil
+}
+
+func (m *MemoryDBStore) queryWorkspaces(where func(row *workspaceRow) bool) []*model.Workspace {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	workspaces := make([]*model.Workspace, 0)
+	for _, row := range m.workspaces {
+		if where(row) {
+			workspace := row.Workspace
+			workspaces = append(workspaces, &workspace)
+		}
+	}
+	return workspaces
+}
+
+func (m *MemoryDBStore) DeleteWorkspaces(ids []string) error {
+	return m.update(func() error {
+		for _, id := range ids {
+			workspaces := m.workspaces[:0]
+			for _, row := range m.workspaces {
+				if row.ID != id {
+					workspaces = append(workspaces, row)
+				}
+			}
+			m.workspaces = workspaces
+		}
+		return nil
+	})
+}
+
+func (m *MemoryDBStore) CreateAssignment(userId, workspaceId string) error {
+	return m.update(func() error {
+		now := time.Now()
+		for _, assignment := range m.assignments {
+			if assignment.UserID == userId && assignment.open {
+				// Already assigned to this or another workspace
+				return nil
+			}
+		}
+		if m.hasBookingsSince(workspaceId, now) {
+			return db.NewConflictError("workspace has outstanding bookings")
+		}
+		m.endAssignments(workspaceId, now)
+		m.endOfferings(workspaceId, now)
+		return m.insertAssignment(userId, workspaceId, time.Now())
+	})
+}
+
+// CreateAssignWorkspace creates the workspace if it doesn't exist, then assigns it to userId, or makes it an
+// unassigned workspace with a default offering if userId is empty; see PostgresDBStore.CreateAssignWorkspace
+func (m *MemoryDBStore) CreateAssignWorkspace(workspace *model.Workspace, userId string) (string, error) {
+	var workspaceId string
+	err := m.update(func() error {
+		now := time.Now()
+		newWorkspaceCreated := false
+		for _, row := range m.workspaces {
+			if row.Name == workspace.Name && row.Floor == workspace.Floor {
+				workspaceId = row.ID
+				break
+			}
+		}
+		if workspaceId == "" {
+			if m.floor(workspace.Floor) == nil {
+				return foreignKeyError("workspaces", "floor_id")
+			}
+			row := &workspaceRow{Workspace: model.Workspace{Name: workspace.Name, Floor: workspace.Floor}}
+			row.ID = newID()
+			m.workspaces = append(m.workspaces, row)
+			workspaceId = row.ID
+			workspace.ID = row.ID
+			newWorkspaceCreated = true
+		}
+
+		currentlyAssignedUserId := ""
+		if !newWorkspaceCreated {
+			for _, assignment := range m.assignments {
+				if assignment.WorkspaceID == workspaceId && assignment.open {
+					currentlyAssignedUserId = assignment.UserID
+					break
+				}
+			}
+		}
+		if userId == "" {
+			if newWorkspaceCreated {
+				return m.insertOffering(defaultOffering(workspaceId, now))
+			}
+			if currentlyAssignedUserId != "" {
+				for _, assignment := range m.assignments {
+					if assignment.WorkspaceID == workspaceId && assignment.UserID == currentlyAssignedUserId && assignment.open {
+						assignment.EndDate = now
+						assignment.open = false
+					}
+				}
+				return m.insertOffering(defaultOffering(workspaceId, now))
+			}
+			// already offered -> do nothing
+			return nil
+		}
+		if newWorkspaceCreated {
+			return m.insertAssignment(userId, workspaceId, now)
+		}
+		if currentlyAssignedUserId == userId {
+			return nil
+		}
+		if m.hasBookingsSince(workspaceId, now) {
+			return db.NewConflictError("workspace has outstanding bookings")
+		}
+		m.endAssignments(workspaceId, now)
+		m.endOfferings(workspaceId, now)
+		return m.insertAssignment(userId, workspaceId, now)
+	})
+	if err != nil {
+		return "", err
+	}
+	return workspaceId, nil
+}
+
+// hasBookingsSince reports whether the workspace has any booking, cancelled or not, ending at or after since
+func (t *tables) hasBookingsSince(workspaceId string, since time.Time) bool {
+	for _, booking := range t.bookings {
+		if booking.WorkspaceID == workspaceId && !booking.EndDate.Before(since) {
+			return true
+		}
+	}
+	return false
+}
+
+// endAssignments ends the current assignment of the workspace at end
+func (t *tables) endAssignments(workspaceId string, end time.Time) {
+	for _, assignment := range t.assignments {
+		if assignment.WorkspaceID == workspaceId && assignment.open {
+			assignment.EndDate = end
+			assignment.open = false
+		}
+	}
+}
+
+// endOfferings ends the default offering of the workspace at end and cancels the offerings that haven't ended by then
+func (t *tables) endOfferings(workspaceId string, end time.Time) {
+	for _, offering := range t.offerings {
+		if offering.WorkspaceID == workspaceId && offering.open {
+			offering.EndDate = end
+			offering.open = false
+		}
+	}
+	for _, offering := range t.offerings {
+		if offering.WorkspaceID == workspaceId && !offering.EndDate.Before(end) {
+			offering.Cancelled = true
+		}
+	}
+}
+
+func (t *tables) insertAssignment(userId, workspaceId string, start time.Time) error {
+	if t.user(userId) == nil {
+		return foreignKeyError("workspace_assignee", "user_id")
+	}
+	if t.workspace(workspaceId) == nil {
+		return foreignKeyError("workspace_assignee", "workspace_id")
+	}
+	for _, assignment := range t.assignments {
+		if assignment.WorkspaceID == workspaceId &&
+			rangesOverlap(assignment.StartDate, assignment.EndDate, assignment.open, start, time.Time{}, true) {
+			return db.NewConflictError("workspace is already assigned for this duration")
+		}
+	}
+	row := &assignmentRow{Assignment: model.Assignment{
+		ID:          newID(),
+		WorkspaceID: workspaceId,
+		UserID:      userId,
+		StartDate:   start,
+	}, open: true}
+	t.assignments = append(t.assignments, row)
+	return nil
+}
+
+func defaultOffering(workspaceId string, start time.Time) *offeringRow {
+	return &offeringRow{Offering: model.Offering{
+		WorkspaceID: workspaceId,
+		UserID:      utils.EmptyUserUUID,
+		StartDate:   start,
+		CreatedBy:   utils.EmptyUserUUID,
+	}, open: true}
+}
@@ -1,10 +1,11 @@
+package migrations
+
+// initialSchema is the schema as it stood when migrations were introduced, previously resources/tables.sql
+var initialSchema = Migration{
+	Version: 1,
+	Name:    "initial_schema",
+	Up: `
 create extension if not exists "uuid-ossp";
-DROP TABLE IF EXISTS offerings;
-DROP TABLE IF EXISTS bookings;
-DROP TABLE IF EXISTS workspace_assignee;
-DROP TABLE IF EXISTS workspaces;
-DROP TABLE IF EXISTS users;
-DROP TABLE IF EXISTS floors;
 
 CREATE TABLE floors
 (
@@ -67,4 +68,14 @@ CREATE TABLE workspace_assignee
     workspace_id uuid REFERENCES workspaces (id) NOT NULL,
     start_time   TIMESTAMPTZ                     NOT NULL,
     end_time     TIMESTAMPTZ
-);
\ No newline at end of file
+);
+`,
+	Down: `
+DROP TABLE IF EXISTS offerings;
+DROP TABLE IF EXISTS bookings;
+DROP TABLE IF EXISTS workspace_assignee;
+DROP TABLE IF EXISTS workspaces;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS floors;
+`,
+}
@@ -0,0 +1,48 @@
+package migrations
+
+// series are recurring bookings and offerings; each occurrence is a booking or offering of its own linked to its series
+var series = Migration{
+	Version: 2,
+	Name:    "series",
+	Up: `
+CREATE TABLE booking_series
+(
+    id           uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id      uuid REFERENCES users (id)      NOT NULL,
+    workspace_id uuid REFERENCES workspaces (id) NOT NULL,
+    cancelled    BOOLEAN          DEFAULT FALSE,
+    start_time   TIMESTAMPTZ                     NOT NULL,
+    end_time     TIMESTAMPTZ                     NOT NULL,
+    recurrence   JSON                            NOT NULL,
+    created_by   uuid REFERENCES users (id)      NOT NULL
+);
+
+CREATE TABLE offering_series
+(
+    id           uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id      uuid REFERENCES users (id)      NOT NULL,
+    workspace_id uuid REFERENCES workspaces (id) NOT NULL,
+    cancelled    BOOLEAN          DEFAULT FALSE,
+    start_time   TIMESTAMPTZ                     NOT NULL,
+    end_time     TIMESTAMPTZ                     NOT NULL,
+    recurrence   JSON                            NOT NULL,
+    created_by   uuid REFERENCES users (id)      NOT NULL
+);
+
+ALTER TABLE bookings
+    ADD COLUMN series_id uuid REFERENCES booking_series (id);
+
+ALTER TABLE offerings
+    ADD COLUMN series_id uuid REFERENCES offering_series (id);
+`,
+	Down: `
+ALTER TABLE offerings
+    DROP COLUMN IF EXISTS series_id;
+
+ALTER TABLE bookings
+    DROP COLUMN IF EXISTS series_id;
+
+DROP TABLE IF EXISTS offering_series;
+DROP TABLE IF EXISTS booking_series;
+`,
+}
@@ -0,0 +1,27 @@
+package migrations
+
+// waitlist queues users for a floor until a matching workspace frees up, then books or holds it for them
+var waitlist = Migration{
+	Version: 3,
+	Name:    "waitlist",
+	Up: `
+CREATE TABLE waitlist
+(
+    id                uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id           uuid REFERENCES users (id)  NOT NULL,
+    floor_id          uuid REFERENCES floors (id) ON DELETE CASCADE NOT NULL,
+    properties        JSON             DEFAULT '{}'::json,
+    start_time        TIMESTAMPTZ                 NOT NULL,
+    end_time          TIMESTAMPTZ                 NOT NULL,
+    auto_book         BOOLEAN          DEFAULT FALSE,
+    status            TEXT             DEFAULT 'waiting',
+    hold_workspace_id uuid REFERENCES workspaces (id) ON DELETE SET NULL,
+    hold_expires_at   TIMESTAMPTZ,
+    booking_id        uuid REFERENCES bookings (id) ON DELETE SET NULL,
+    created_at        TIMESTAMPTZ      DEFAULT now()
+);
+`,
+	Down: `
+DROP TABLE IF EXISTS waitlist;
+`,
+}
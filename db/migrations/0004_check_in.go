@@ -0,0 +1,23 @@
+package migrations
+
+// checkIn records when a booking was last checked in to, and which bookings were released as no-shows. Bookings
+// made before check-in existed were never asked to check in, so only the ones made from now on require it
+var checkIn = Migration{
+	Version: 4,
+	Name:    "check_in",
+	Up: `
+ALTER TABLE bookings
+    ADD COLUMN checked_in_at     TIMESTAMPTZ,
+    ADD COLUMN no_show           BOOLEAN DEFAULT FALSE,
+    ADD COLUMN requires_check_in BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE bookings
+    ALTER COLUMN requires_check_in SET DEFAULT TRUE;
+`,
+	Down: `
+ALTER TABLE bookings
+    DROP COLUMN IF EXISTS checked_in_at,
+    DROP COLUMN IF EXISTS no_show,
+    DROP COLUMN IF EXISTS requires_check_in;
+`,
+}
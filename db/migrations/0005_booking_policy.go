@@ -0,0 +1,32 @@
+package migrations
+
+// bookingPolicy holds the single row of limits bookings must keep to, and the periods floors can't be booked
+var bookingPolicy = Migration{
+	Version: 5,
+	Name:    "booking_policy",
+	Up: `
+CREATE TABLE booking_policy
+(
+    id                   BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    max_advance_days     INT     NOT NULL DEFAULT 0,
+    max_duration_hours   INT     NOT NULL DEFAULT 0,
+    max_future_bookings  INT     NOT NULL DEFAULT 0,
+    max_department_daily INT     NOT NULL DEFAULT 0
+);
+
+insert into booking_policy DEFAULT VALUES;
+
+CREATE TABLE floor_blackouts
+(
+    id         uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    floor_id   uuid REFERENCES floors (id) ON DELETE CASCADE NOT NULL,
+    start_time TIMESTAMPTZ                 NOT NULL,
+    end_time   TIMESTAMPTZ                 NOT NULL,
+    reason     TEXT             DEFAULT ''
+);
+`,
+	Down: `
+DROP TABLE IF EXISTS floor_blackouts;
+DROP TABLE IF EXISTS booking_policy;
+`,
+}
@@ -0,0 +1,100 @@
+package migrations
+
+// noOverlap lets the database reject double bookings, offerings and assignments of a workspace, even between requests
+// racing each other. Rows that already overlap would make adding the constraints fail, so they are resolved first:
+// walking each workspace's rows in start order, a booking or offering that starts before one kept earlier ends, or
+// that ends before it starts, is cancelled, and an assignment ends when the next one starts. Each is reported with a
+// notice
+var noOverlap = Migration{
+	Version: 6,
+	Name:    "no_overlap",
+	Up: `
+create extension if not exists btree_gist;
+
+DO $$
+DECLARE
+    r         RECORD;
+    workspace uuid;
+    kept_id   uuid;
+    kept_end  TIMESTAMPTZ;
+BEGIN
+    workspace := NULL;
+    FOR r IN SELECT id, workspace_id, start_time, end_time FROM bookings WHERE NOT cancelled
+             ORDER BY workspace_id, start_time, id
+    LOOP
+        IF r.workspace_id IS DISTINCT FROM workspace THEN
+            workspace := r.workspace_id;
+            kept_end := '-infinity';
+        END IF;
+        IF r.end_time < r.start_time OR r.start_time < kept_end THEN
+            UPDATE bookings SET cancelled = TRUE WHERE id = r.id;
+            RAISE NOTICE 'cancelled booking % of workspace %: it overlaps an earlier booking', r.id, r.workspace_id;
+        ELSE
+            kept_end := greatest(kept_end, r.end_time);
+        END IF;
+    END LOOP;
+
+    workspace := NULL;
+    FOR r IN SELECT id, workspace_id, start_time, coalesce(end_time, 'infinity') AS end_time FROM offerings
+             WHERE NOT cancelled ORDER BY workspace_id, start_time, id
+    LOOP
+        IF r.workspace_id IS DISTINCT FROM workspace THEN
+            workspace := r.workspace_id;
+            kept_end := '-infinity';
+        END IF;
+        IF r.end_time < r.start_time OR r.start_time < kept_end THEN
+            UPDATE offerings SET cancelled = TRUE WHERE id = r.id;
+            RAISE NOTICE 'cancelled offering % of workspace %: it overlaps an earlier offering', r.id, r.workspace_id;
+        ELSE
+            kept_end := greatest(kept_end, r.end_time);
+        END IF;
+    END LOOP;
+
+    workspace := NULL;
+    FOR r IN SELECT id, workspace_id, start_time, coalesce(end_time, 'infinity') AS end_time FROM workspace_assignee
+             ORDER BY workspace_id, start_time, id
+    LOOP
+        IF r.end_time < r.start_time THEN
+            UPDATE workspace_assignee SET end_time = start_time WHERE id = r.id;
+            RAISE NOTICE 'ended assignment % of workspace % when it starts: it ended before it started', r.id, r.workspace_id;
+            CONTINUE;
+        END IF;
+        IF r.workspace_id IS DISTINCT FROM workspace THEN
+            workspace := r.workspace_id;
+            kept_end := '-infinity';
+        ELSIF r.start_time < kept_end THEN
+            UPDATE workspace_assignee SET end_time = r.start_time WHERE id = kept_id;
+            RAISE NOTICE 'ended assignment % of workspace % when assignment % starts', kept_id, r.workspace_id, r.id;
+        END IF;
+        kept_id := r.id;
+        kept_end := r.end_time;
+    END LOOP;
+END
+$$;
+
+ALTER TABLE bookings
+    ADD CONSTRAINT bookings_no_overlap EXCLUDE USING gist (
+        workspace_id WITH =, tstzrange(start_time, end_time, '[)') WITH &&
+    ) WHERE (NOT cancelled);
+
+ALTER TABLE offerings
+    ADD CONSTRAINT offerings_no_overlap EXCLUDE USING gist (
+        workspace_id WITH =, tstzrange(start_time, end_time, '[)') WITH &&
+    ) WHERE (NOT cancelled);
+
+ALTER TABLE workspace_assignee
+    ADD CONSTRAINT workspace_assignee_no_overlap EXCLUDE USING gist (
+        workspace_id WITH =, tstzrange(start_time, end_time, '[)') WITH &&
+    );
+`,
+	Down: `
+ALTER TABLE workspace_assignee
+    DROP CONSTRAINT IF EXISTS workspace_assignee_no_overlap;
+
+ALTER TABLE offerings
+    DROP CONSTRAINT IF EXISTS offerings_no_overlap;
+
+ALTER TABLE bookings
+    DROP CONSTRAINT IF EXISTS bookings_no_overlap;
+`,
+}
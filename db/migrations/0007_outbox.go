@@ -0,0 +1,25 @@
+package migrations
+
+// outbox queues notifications in the same transaction as the booking or offering change they are about
+var outbox = Migration{
+	Version: 7,
+	Name:    "outbox",
+	Up: `
+CREATE TABLE outbox
+(
+    id              uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    notification    JSONB       NOT NULL,
+    status          TEXT        NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts        INT         NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error      TEXT        NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at    TIMESTAMPTZ
+);
+
+CREATE INDEX outbox_due ON outbox (next_attempt_at) WHERE status = 'pending';
+`,
+	Down: `
+DROP TABLE IF EXISTS outbox;
+`,
+}
@@ -0,0 +1,31 @@
+package migrations
+
+// emailTemplates lets admins override the built in email content per event and locale, and users pick the locale
+// and time zone of their emails
+var emailTemplates = Migration{
+	Version: 8,
+	Name:    "email_templates",
+	Up: `
+ALTER TABLE users
+    ADD COLUMN locale    TEXT NOT NULL DEFAULT '',
+    ADD COLUMN time_zone TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE email_templates
+(
+    event      TEXT        NOT NULL,
+    locale     TEXT        NOT NULL,
+    subject    TEXT        NOT NULL,
+    text       TEXT        NOT NULL,
+    html       TEXT        NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (event, locale)
+);
+`,
+	Down: `
+DROP TABLE IF EXISTS email_templates;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS locale,
+    DROP COLUMN IF EXISTS time_zone;
+`,
+}
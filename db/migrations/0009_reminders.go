@@ -0,0 +1,28 @@
+package migrations
+
+// reminders records the reminders and digests already queued, so each is sent once however many instances run the
+// scheduler, and lets users opt out of them
+var reminders = Migration{
+	Version: 9,
+	Name:    "reminders",
+	Up: `
+ALTER TABLE users
+    ADD COLUMN reminders_opt_out BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN digest_opt_out    BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE reminders
+(
+    key       TEXT PRIMARY KEY,
+    queued_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX reminders_queued_at_idx ON reminders (queued_at);
+`,
+	Down: `
+DROP TABLE IF EXISTS reminders;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS reminders_opt_out,
+    DROP COLUMN IF EXISTS digest_opt_out;
+`,
+}
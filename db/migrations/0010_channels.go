@@ -0,0 +1,27 @@
+package migrations
+
+// channels are where the notifications of a user or a floor are sent besides email
+var channels = Migration{
+	Version: 10,
+	Name:    "channels",
+	Up: `
+CREATE TABLE channels
+(
+    id         uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id    uuid REFERENCES users (id) ON DELETE CASCADE,
+    floor_id   uuid REFERENCES floors (id) ON DELETE CASCADE,
+    kind       TEXT        NOT NULL CHECK (kind IN ('webhook', 'slack', 'teams')),
+    url        TEXT        NOT NULL,
+    secret     TEXT        NOT NULL DEFAULT '',
+    events     TEXT[]      NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK ((user_id IS NULL) <> (floor_id IS NULL))
+);
+
+CREATE INDEX channels_user_id_idx ON channels (user_id);
+CREATE INDEX channels_floor_id_idx ON channels (floor_id);
+`,
+	Down: `
+DROP TABLE IF EXISTS channels;
+`,
+}
@@ -0,0 +1,43 @@
+package migrations
+
+// webhooks are the subscriptions of other systems to the events of the app, and the deliveries queued for them
+var webhooks = Migration{
+	Version: 11,
+	Name:    "webhooks",
+	Up: `
+CREATE TABLE webhooks
+(
+    id            uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    url           TEXT        NOT NULL,
+    secret        TEXT        NOT NULL,
+    events        TEXT[]      NOT NULL DEFAULT '{}',
+    floor_id      uuid REFERENCES floors (id) ON DELETE CASCADE,
+    active        BOOLEAN     NOT NULL DEFAULT TRUE,
+    failing_since TIMESTAMPTZ,
+    disabled_at   TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE webhook_deliveries
+(
+    id              uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    webhook_id      uuid        NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event           TEXT        NOT NULL,
+    payload         JSONB       NOT NULL,
+    status          TEXT        NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts        INT         NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    response_status INT         NOT NULL DEFAULT 0,
+    last_error      TEXT        NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at    TIMESTAMPTZ
+);
+
+CREATE INDEX webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, created_at);
+CREATE INDEX webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+`,
+	Down: `
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
+`,
+}
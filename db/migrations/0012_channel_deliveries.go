@@ -0,0 +1,33 @@
+package migrations
+
+// channelDeliveries are the messages queued for the channels of users and floors, sent and retried on their own
+// rather than right after the email they go with. A channel gets one delivery per event_key, so that an event queued
+// again when its email is retried isn't sent twice
+var channelDeliveries = Migration{
+	Version: 12,
+	Name:    "channel_deliveries",
+	Up: `
+CREATE TABLE channel_deliveries
+(
+    id              uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    channel_id      uuid        NOT NULL REFERENCES channels (id) ON DELETE CASCADE,
+    event           TEXT        NOT NULL,
+    body            JSONB       NOT NULL,
+    event_key       TEXT        NOT NULL DEFAULT '',
+    status          TEXT        NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts        INT         NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    response_status INT         NOT NULL DEFAULT 0,
+    last_error      TEXT        NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at    TIMESTAMPTZ
+);
+
+CREATE INDEX channel_deliveries_channel_id_idx ON channel_deliveries (channel_id);
+CREATE INDEX channel_deliveries_due ON channel_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE UNIQUE INDEX channel_deliveries_event_key ON channel_deliveries (channel_id, event_key) WHERE event_key <> '';
+`,
+	Down: `
+DROP TABLE IF EXISTS channel_deliveries;
+`,
+}
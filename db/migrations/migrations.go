@@ -0,0 +1,321 @@
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	_ "github.com/lib/pq"
+	"log"
+	"time"
+)
+
+// lockKey is the pg_advisory_lock key held while migrating so two instances starting at once don't both migrate
+const lockKey = 7315091203
+
+// Migration is one versioned schema change. Up and Down each run in a single transaction
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Checksum identifies the Up script so edits to an already applied migration are caught
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// All is every migration in the order it is applied. New migrations are appended with the next version
+var All = []Migration{
+	initialSchema,
+	series,
+	waitlist,
+	checkIn,
+	bookingPolicy,
+	noOverlap,
+	outbox,
+	emailTemplates,
+	reminders,
+	channels,
+	webhooks,
+	channelDeliveries,
+}
+
+// Status is a migration and when it was applied, nil if it is still pending
+type Status struct {
+	Migration
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+type Migrator struct {
+	database   *sql.DB
+	migrations []Migration
+}
+
+func New(database *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{database: database, migrations: migrations}
+}
+
+// Open connects to dbUrl and returns a Migrator for All; Close releases the connection
+func Open(dbUrl string) (*Migrator, error) {
+	database, err := sql.Open("postgres", dbUrl)
+	if err != nil {
+		return nil, err
+	}
+	if err = database.Ping(); err != nil {
+		database.Close()
+		return nil, err
+	}
+	return New(database, All), nil
+}
+
+func (m *Migrator) Close() {
+	m.database.Close()
+}
+
+// Up applies every pending migration in order and returns the ones it applied
+func (m *Migrator) Up() ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(func(conn *sql.Conn, done map[int]string) error {
+		if len(done) == 0 {
+			if err := checkEmpty(conn); err != nil {
+				return err
+			}
+		}
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			log.Printf("Migrator.Up: applying %s", migration)
+			err := inTx(conn, func(tx *sql.Tx) error {
+				if _, err := tx.Exec(migration.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec(`INSERT INTO schema_migrations(version, name, checksum) VALUES ($1, $2, $3)`,
+					migration.Version, migration.Name, migration.Checksum())
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("applying %s: %w", migration, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns the ones it reverted
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.locked(func(conn *sql.Conn, done map[int]string) error {
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("%s cannot be reverted", migration)
+			}
+			log.Printf("Migrator.Down: reverting %s", migration)
+			err := inTx(conn, func(tx *sql.Tx) error {
+				if _, err := tx.Exec(migration.Down); err != nil {
+					return err
+				}
+				_, err := tx.Exec(`DELETE FROM schema_migrations WHERE version=$1`, migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("reverting %s: %w", migration, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Baseline records every migration up to version as applied without running it. It is for databases whose
+// schema was created by hand before migrations existed, once that schema matches those migrations
+func (m *Migrator) Baseline(version int) error {
+	return m.locked(func(conn *sql.Conn, done map[int]string) error {
+		if len(done) > 0 {
+			return fmt.Errorf("database already has migrations applied")
+		}
+		return inTx(conn, func(tx *sql.Tx) error {
+			for _, migration := range m.migrations {
+				if migration.Version > version {
+					break
+				}
+				_, err := tx.Exec(`INSERT INTO schema_migrations(version, name, checksum) VALUES ($1, $2, $3)`,
+					migration.Version, migration.Name, migration.Checksum())
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// Status lists every migration with the time it was applied
+func (m *Migrator) Status() ([]*Status, error) {
+	var statuses []*Status
+	err := m.locked(func(conn *sql.Conn, done map[int]string) error {
+		rows, err := conn.QueryContext(context.Background(), `SELECT version, applied_at FROM schema_migrations`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		appliedAt := make(map[int]time.Time)
+		for rows.Next() {
+			var version int
+			var at time.Time
+			if err = rows.Scan(&version, &at); err != nil {
+				return err
+			}
+			appliedAt[version] = at
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			status := &Status{Migration: migration}
+			if at, ok := appliedAt[migration.Version]; ok {
+				status.AppliedAt = &at
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// locked runs fn on a single connection holding the migration lock, after making sure schema_migrations exists
+// and that what it records agrees with m.migrations. done maps applied versions to their checksums
+func (m *Migrator) locked(fn func(conn *sql.Conn, done map[int]string) error) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	conn, err := m.database.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, lockKey)
+
+	_, err = conn.ExecContext(ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations
+				(
+					version    INT PRIMARY KEY,
+					name       TEXT        NOT NULL,
+					checksum   TEXT        NOT NULL,
+					applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+				)`)
+	if err != nil {
+		return err
+	}
+	done, err := m.applied(conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn, done)
+}
+
+// applied reads schema_migrations and fails if it holds a version we don't know or one whose script has changed
+func (m *Migrator) applied(conn *sql.Conn) (map[int]string, error) {
+	rows, err := conn.QueryContext(context.Background(), `SELECT version, checksum FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	done := make(map[int]string)
+	for rows.Next() {
+		var version int
+		var checksum string
+		if err = rows.Scan(&version, &checksum); err != nil {
+			return nil, err
+		}
+		done[version] = checksum
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	known := make(map[int]Migration)
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+	for version, checksum := range done {
+		migration, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("database has unknown migration %d applied", version)
+		}
+		if migration.Checksum() != checksum {
+			return nil, fmt.Errorf("%s has changed since it was applied", migration)
+		}
+	}
+	return done, nil
+}
+
+func (m *Migrator) validate() error {
+	for i, migration := range m.migrations {
+		if migration.Version <= 0 || (i > 0 && migration.Version <= m.migrations[i-1].Version) {
+			return fmt.Errorf("%s is out of order", migration)
+		}
+	}
+	return nil
+}
+
+// checkEmpty refuses to migrate a database that has tables but no migration history, rather than failing
+// halfway through 0001; such a database needs a Baseline first
+func checkEmpty(conn *sql.Conn) error {
+	var count int
+	err := conn.QueryRowContext(context.Background(),
+		`SELECT count(*) FROM information_schema.tables
+					WHERE table_schema = current_schema() AND table_name <> 'schema_migrations'`,
+	).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("database has tables but no migration history; run `migrate baseline <version>` once it matches that migration")
+	}
+	return nil
+}
+
+func inTx(conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err = fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Reset drops everything in the current schema and migrates it back up. It is only meant for test databases
+func Reset(dbUrl string) error {
+	m, err := Open(dbUrl)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	if _, err = m.database.Exec(`DROP SCHEMA IF EXISTS public CASCADE; CREATE SCHEMA public`); err != nil {
+		return err
+	}
+	_, err = m.Up()
+	return err
+}
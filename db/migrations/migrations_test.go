@@ -0,0 +1,257 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"sync"
+	"testing"
+)
+
+var testMigrations = []Migration{
+	initialSchema,
+	{
+		Version: 2,
+		Name:    "add_floor_notes",
+		Up:      `ALTER TABLE floors ADD COLUMN notes TEXT DEFAULT ''`,
+		Down:    `ALTER TABLE floors DROP COLUMN notes`,
+	},
+}
+
+func TestAllOrdered(t *testing.T) {
+	assert.NoError(t, New(nil, All).validate())
+	assert.Error(t, New(nil, []Migration{testMigrations[1], testMigrations[0]}).validate())
+	assert.Error(t, New(nil, []Migration{initialSchema, initialSchema}).validate())
+}
+
+func TestChecksum(t *testing.T) {
+	changed := initialSchema
+	changed.Up += "\nCREATE TABLE extra (id INT);"
+	assert.Equal(t, initialSchema.Checksum(), initialSchema.Checksum())
+	assert.NotEqual(t, initialSchema.Checksum(), changed.Checksum())
+	assert.Equal(t, "0001_initial_schema", initialSchema.String())
+}
+
+type MigrationsTestSuite struct {
+	suite.Suite
+	dbUrl    string
+	database *sql.DB
+}
+
+func (suite *MigrationsTestSuite) SetupSuite() {
+	suite.dbUrl = os.Getenv("TEST_DB_URL")
+	database, err := sql.Open("postgres", suite.dbUrl)
+	if err != nil {
+		suite.FailNow("failed to connect to DB" + err.Error())
+	}
+	suite.database = database
+}
+
+func (suite *MigrationsTestSuite) SetupTest() {
+	_, err := suite.database.Exec(`DROP SCHEMA IF EXISTS public CASCADE; CREATE SCHEMA public`)
+	if err != nil {
+		suite.FailNow("failed to reset test db" + err.Error())
+	}
+}
+
+func (suite *MigrationsTestSuite) TearDownSuite() {
+	// Leave the schema the way the other test suites expect it
+	if err := Reset(suite.dbUrl); err != nil {
+		suite.Fail("failed to reset test db", err)
+	}
+	suite.database.Close()
+}
+
+func TestMigrations(t *testing.T) {
+	suite.Run(t, new(MigrationsTestSuite))
+}
+
+func (suite *MigrationsTestSuite) TestUpDown() {
+	t := suite.T()
+	m := New(suite.database, testMigrations)
+
+	applied, err := m.Up()
+	assert.NoError(t, err)
+	assert.Len(t, applied, 2)
+	_, err = suite.database.Exec(`SELECT notes FROM floors`)
+	assert.NoError(t, err)
+
+	applied, err = m.Up()
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+
+	reverted, err := m.Down(1)
+	assert.NoError(t, err)
+	if assert.Len(t, reverted, 1) {
+		assert.Equal(t, 2, reverted[0].Version)
+	}
+	_, err = suite.database.Exec(`SELECT notes FROM floors`)
+	assert.Error(t, err)
+
+	statuses, err := m.Status()
+	assert.NoError(t, err)
+	if assert.Len(t, statuses, 2) {
+		assert.NotNil(t, statuses[0].AppliedAt)
+		assert.Nil(t, statuses[1].AppliedAt)
+	}
+
+	reverted, err = m.Down(10)
+	assert.NoError(t, err)
+	assert.Len(t, reverted, 1)
+	var count int
+	assert.NoError(t, suite.database.QueryRow(
+		`SELECT count(*) FROM information_schema.tables
+					WHERE table_schema = current_schema() AND table_name <> 'schema_migrations'`,
+	).Scan(&count))
+	assert.Equal(t, 0, count)
+}
+
+func (suite *MigrationsTestSuite) TestChangedMigration() {
+	t := suite.T()
+	_, err := New(suite.database, testMigrations).Up()
+	assert.NoError(t, err)
+
+	changed := append([]Migration{}, testMigrations...)
+	changed[1].Up = `ALTER TABLE floors ADD COLUMN notes TEXT DEFAULT 'none'`
+	_, err = New(suite.database, changed).Up()
+	assert.EqualError(t, err, "0002_add_floor_notes has changed since it was applied")
+
+	_, err = New(suite.database, testMigrations[:1]).Up()
+	assert.EqualError(t, err, "database has unknown migration 2 applied")
+}
+
+func (suite *MigrationsTestSuite) TestFailedMigrationRollsBack() {
+	t := suite.T()
+	broken := append([]Migration{}, testMigrations...)
+	broken[1].Up = `ALTER TABLE floors ADD COLUMN notes TEXT; ALTER TABLE nowhere ADD COLUMN notes TEXT`
+	applied, err := New(suite.database, broken).Up()
+	assert.Error(t, err)
+	assert.Len(t, applied, 1)
+
+	// The half applied migration left nothing behind, so the fixed one still applies
+	applied, err = New(suite.database, testMigrations).Up()
+	assert.NoError(t, err)
+	assert.Len(t, applied, 1)
+}
+
+func (suite *MigrationsTestSuite) TestBaseline() {
+	t := suite.T()
+	_, err := suite.database.Exec(initialSchema.Up)
+	assert.NoError(t, err)
+
+	m := New(suite.database, testMigrations)
+	_, err = m.Up()
+	assert.Error(t, err, "tables without history must be baselined first")
+
+	assert.NoError(t, m.Baseline(1))
+	applied, err := m.Up()
+	assert.NoError(t, err)
+	if assert.Len(t, applied, 1) {
+		assert.Equal(t, 2, applied[0].Version)
+	}
+	assert.Error(t, m.Baseline(1))
+}
+
+func (suite *MigrationsTestSuite) TestConcurrentUp() {
+	t := suite.T()
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		total int
+	)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			applied, err := New(suite.database, testMigrations).Up()
+			assert.NoError(t, err)
+			mu.Lock()
+			total += len(applied)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, len(testMigrations), total)
+}
+
+func (suite *MigrationsTestSuite) TestNoOverlapResolvesOverlaps() {
+	t := suite.T()
+	_, err := New(suite.database, All[:noOverlap.Version-1]).Up()
+	assert.NoError(t, err)
+	workspaceId := suite.createWorkspace()
+	insert := func(table string, start int, end interface{}) string {
+		query := `INSERT INTO %s (user_id, created_by, workspace_id, start_time, end_time)
+			VALUES ($1, $1, $2, now() + $3 * interval '1 hour', now() + $4 * interval '1 hour') RETURNING id`
+		if table == "workspace_assignee" {
+			query = `INSERT INTO %s (user_id, workspace_id, start_time, end_time)
+				VALUES ($1, $2, now() + $3 * interval '1 hour', now() + $4 * interval '1 hour') RETURNING id`
+		}
+		var id string
+		assert.NoError(t, suite.database.QueryRow(fmt.Sprintf(query, table),
+			"decade00-0000-4000-a000-000000000000", workspaceId, start, end).Scan(&id))
+		return id
+	}
+	kept := insert("bookings", 0, 2)
+	overlapping := insert("bookings", 1, 3)
+	after := insert("bookings", 2, 4)
+	inverted := insert("bookings", 6, 5)
+	openOffering := insert("offerings", 0, nil)
+	laterOffering := insert("offerings", 5, 6)
+	firstAssignment := insert("workspace_assignee", 0, nil)
+	secondAssignment := insert("workspace_assignee", 3, nil)
+
+	_, err = New(suite.database, All[:noOverlap.Version]).Up()
+	assert.NoError(t, err)
+	cancelled := func(table, id string) bool {
+		var cancelled bool
+		assert.NoError(t, suite.database.QueryRow(`SELECT cancelled FROM `+table+` WHERE id=$1`, id).Scan(&cancelled))
+		return cancelled
+	}
+	assert.False(t, cancelled("bookings", kept))
+	assert.True(t, cancelled("bookings", overlapping))
+	assert.False(t, cancelled("bookings", after), "only what overlaps a booking that is kept is cancelled")
+	assert.True(t, cancelled("bookings", inverted))
+	assert.False(t, cancelled("offerings", openOffering))
+	assert.True(t, cancelled("offerings", laterOffering))
+	var ends bool
+	assert.NoError(t, suite.database.QueryRow(
+		`SELECT a.end_time = b.start_time FROM workspace_assignee a, workspace_assignee b WHERE a.id=$1 AND b.id=$2`,
+		firstAssignment, secondAssignment,
+	).Scan(&ends))
+	assert.True(t, ends, "an assignment ends when the next one starts")
+}
+
+func (suite *MigrationsTestSuite) TestCheckInOnlyRequiredOfNewBookings() {
+	t := suite.T()
+	_, err := New(suite.database, All[:checkIn.Version-1]).Up()
+	assert.NoError(t, err)
+	workspaceId := suite.createWorkspace()
+	book := `INSERT INTO bookings (user_id, created_by, workspace_id, start_time, end_time)
+		VALUES ('decade00-0000-4000-a000-000000000000', 'decade00-0000-4000-a000-000000000000', $1, now(), now() + interval '1 hour')
+		RETURNING id`
+	var before, after string
+	assert.NoError(t, suite.database.QueryRow(book, workspaceId).Scan(&before))
+	_, err = New(suite.database, All[:checkIn.Version]).Up()
+	assert.NoError(t, err)
+	assert.NoError(t, suite.database.QueryRow(book, workspaceId).Scan(&after))
+
+	requires := func(id string) bool {
+		var requires bool
+		assert.NoError(t, suite.database.QueryRow(`SELECT requires_check_in FROM bookings WHERE id=$1`, id).Scan(&requires))
+		return requires
+	}
+	assert.False(t, requires(before), "bookings made before check-in existed are never released")
+	assert.True(t, requires(after))
+}
+
+// createWorkspace adds a workspace on a new floor and returns its id
+func (suite *MigrationsTestSuite) createWorkspace() string {
+	var id string
+	assert.NoError(suite.T(), suite.database.QueryRow(
+		`WITH floor AS (INSERT INTO floors (name, download_url, address) VALUES ('West', '', '') RETURNING id)
+			INSERT INTO workspaces (floor_id, name) SELECT id, 'W-001' FROM floor RETURNING id`,
+	).Scan(&id))
+	return id
+}
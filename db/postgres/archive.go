@@ -0,0 +1,209 @@
+package postgres
+
+import (
+	"database/sql"
+	"github.com/lib/pq"
+	"go-api/db"
+	"go-api/model"
+	"time"
+)
+
+// foreignKeyViolation is the SQLSTATE postgres reports when a row references a row that doesn't exist
+const foreignKeyViolation = "23503"
+
+// archivableWorkspace holds for a workspace w that is deleted and that nothing will reference once the rows that
+// ended before $1 are archived. Series are never archived, so a workspace with any stays
+const archivableWorkspace = `w.deleted AND
+	NOT EXISTS (SELECT 1 FROM bookings b WHERE b.workspace_id = w.id AND b.end_time >= $1) AND
+	NOT EXISTS (SELECT 1 FROM offerings o WHERE o.workspace_id = w.id AND (o.end_time IS NULL OR o.end_time >= $1)) AND
+	NOT EXISTS (SELECT 1 FROM workspace_assignee a WHERE a.workspace_id = w.id AND (a.end_time IS NULL OR a.end_time >= $1)) AND
+	NOT EXISTS (SELECT 1 FROM booking_series bs WHERE bs.workspace_id = w.id) AND
+	NOT EXISTS (SELECT 1 FROM offering_series os WHERE os.workspace_id = w.id)`
+
+func (p PostgresDBStore) GetArchivableWorkspaces(cutoff time.Time) ([]*model.Workspace, error) {
+	rows, err := p.database.Query(
+		`SELECT w.id, w.name, w.floor_id, w.details, w.metadata FROM workspaces w WHERE `+archivableWorkspace, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	workspaces := make([]*model.Workspace, 0)
+	for rows.Next() {
+		var workspace model.Workspace
+		if err = rows.Scan(&workspace.ID, &workspace.Name, &workspace.Floor, &workspace.Details, &workspace.Props); err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, &workspace)
+	}
+	return workspaces, rows.Err()
+}
+
+func (p PostgresDBStore) GetArchivableFloors(cutoff time.Time) ([]*model.Floor, error) {
+	return p.queryMultipleFloors(
+		`SELECT f.id, f.name, f.download_url, f.address FROM floors f
+			WHERE f.deleted AND NOT EXISTS (
+				SELECT 1 FROM workspaces w WHERE w.floor_id = f.id AND NOT (`+archivableWorkspace+`)
+			)`, cutoff)
+}
+
+// DeleteArchivedRows deletes children before parents. Each delete rechecks that its rows can still be archived
+// and the whole transaction is rolled back if one of them deletes fewer rows than it was given
+func (p PostgresDBStore) DeleteArchivedRows(rows *model.ArchivedRows, cutoff time.Time) error {
+	var bookingIds, offeringIds, assignmentIds, workspaceIds, floorIds []string
+	for _, b := range rows.Bookings {
+		bookingIds = append(bookingIds, b.ID)
+	}
+	for _, o := range rows.Offerings {
+		offeringIds = append(offeringIds, o.ID)
+	}
+	for _, a := range rows.Assignments {
+		assignmentIds = append(assignmentIds, a.ID)
+	}
+	for _, w := range rows.Workspaces {
+		workspaceIds = append(workspaceIds, w.ID)
+	}
+	for _, f := range rows.Floors {
+		floorIds = append(floorIds, f.ID)
+	}
+	deletes := []struct {
+		statement string
+		args      []interface{}
+		count     int
+	}{
+		{`DELETE FROM bookings WHERE end_time < $1 AND id = ANY($2::uuid[])`,
+			[]interface{}{cutoff, pq.Array(bookingIds)}, len(bookingIds)},
+		{`DELETE FROM offerings WHERE end_time < $1 AND id = ANY($2::uuid[])`,
+			[]interface{}{cutoff, pq.Array(offeringIds)}, len(offeringIds)},
+		{`DELETE FROM workspace_assignee WHERE end_time < $1 AND id = ANY($2::uuid[])`,
+			[]interface{}{cutoff, pq.Array(assignmentIds)}, len(assignmentIds)},
+		{`DELETE FROM workspaces w WHERE ` + archivableWorkspace + ` AND w.id = ANY($2::uuid[])`,
+			[]interface{}{cutoff, pq.Array(workspaceIds)}, len(workspaceIds)},
+		{`DELETE FROM floors WHERE deleted AND id = ANY($1::uuid[])`,
+			[]interface{}{pq.Array(floorIds)}, len(floorIds)},
+	}
+
+	tx, err := p.database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, d := range deletes {
+		if d.count == 0 {
+			continue
+		}
+		result, err := tx.Exec(d.statement, d.args...)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == foreignKeyViolation {
+				return db.NewConflictError("archived rows are still referenced")
+			}
+			return err
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if deleted != int64(d.count) {
+			return db.NewConflictError("rows changed while they were being archived")
+		}
+	}
+	return tx.Commit()
+}
+
+// RestoreArchivedRows inserts the rows parents first in one transaction. ON CONFLICT DO NOTHING skips both rows that
+// are already there and rows an exclusion constraint rejects, so restoring the same rows twice changes nothing
+func (p PostgresDBStore) RestoreArchivedRows(rows *model.ArchivedRows) (*model.RestoreReport, error) {
+	tx, err := p.database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	report := &model.RestoreReport{}
+	count := func(result sql.Result, restored, skipped *int) error {
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if inserted > 0 {
+			*restored++
+		} else {
+			*skipped++
+		}
+		return nil
+	}
+	for _, f := range rows.Floors {
+		result, err := tx.Exec(
+			`INSERT INTO floors (id, name, address, download_url, deleted) VALUES ($1, $2, $3, $4, true)
+				ON CONFLICT DO NOTHING`,
+			f.ID, f.Name, f.Address, f.DownloadURL)
+		if err == nil {
+			err = count(result, &report.Restored.Floors, &report.Skipped.Floors)
+		}
+		if err != nil {
+			return nil, restoreError(err)
+		}
+	}
+	for _, w := range rows.Workspaces {
+		result, err := tx.Exec(
+			`INSERT INTO workspaces (id, floor_id, name, details, metadata, deleted) VALUES ($1, $2, $3, $4, $5, true)
+				ON CONFLICT DO NOTHING`,
+			w.ID, w.Floor, w.Name, w.Details, w.Props)
+		if err == nil {
+			err = count(result, &report.Restored.Workspaces, &report.Skipped.Workspaces)
+		}
+		if err != nil {
+			return nil, restoreError(err)
+		}
+	}
+	for _, a := range rows.Assignments {
+		result, err := tx.Exec(
+			`INSERT INTO workspace_assignee (id, user_id, workspace_id, start_time, end_time) VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT DO NOTHING`,
+			a.ID, a.UserID, a.WorkspaceID, a.StartDate, a.EndDate)
+		if err == nil {
+			err = count(result, &report.Restored.Assignments, &report.Skipped.Assignments)
+		}
+		if err != nil {
+			return nil, restoreError(err)
+		}
+	}
+	for _, o := range rows.Offerings {
+		result, err := tx.Exec(
+			`INSERT INTO offerings (id, user_id, workspace_id, start_time, end_time, cancelled, created_by, series_id)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, '')::uuid)
+				ON CONFLICT DO NOTHING`,
+			o.ID, o.UserID, o.WorkspaceID, o.StartDate, o.EndDate, o.Cancelled, o.CreatedBy, o.SeriesID)
+		if err == nil {
+			err = count(result, &report.Restored.Offerings, &report.Skipped.Offerings)
+		}
+		if err != nil {
+			return nil, restoreError(err)
+		}
+	}
+	for _, b := range rows.Bookings {
+		result, err := tx.Exec(
+			`INSERT INTO bookings (id, user_id, workspace_id, start_time, end_time, cancelled, created_by, series_id,
+				                      checked_in_at, no_show)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, '')::uuid, $9, $10)
+				ON CONFLICT DO NOTHING`,
+			b.ID, b.UserID, b.WorkspaceID, b.StartDate, b.EndDate, b.Cancelled, b.CreatedBy, b.SeriesID,
+			nullTime(b.CheckedInAt), b.NoShow)
+		if err == nil {
+			err = count(result, &report.Restored.Bookings, &report.Skipped.Bookings)
+		}
+		if err != nil {
+			return nil, restoreError(err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// restoreError reports rows that reference rows missing from both the database and the restored rows
+func restoreError(err error) error {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == foreignKeyViolation {
+		return db.NewValidationError("archived rows reference rows that no longer exist")
+	}
+	return err
+}
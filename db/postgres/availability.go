@@ -14,11 +14,18 @@ func (p PostgresDBStore) FindAvailability(floorId string, start time.Time, end t
 		return nil, err
 	}
 	defer tx.Rollback()
+	// Workspaces held for a waitlisted user count as booked
 	sqlStmtBookings := `SELECT b.workspace_id from bookings b INNER JOIN workspaces w ON w.id=b.workspace_id
 			WHERE w.floor_id=$1 AND b.cancelled=false AND w.deleted=FALSE AND
 			( (b.start_time <= $2 AND b.end_time >= $2) 
 				OR (b.start_time >= $2 AND b.end_time <= $3) 
-				OR (b.end_time >= $3 AND b.start_time <= $3) );`
+				OR (b.end_time >= $3 AND b.start_time <= $3) )
+			UNION
+			SELECT wl.hold_workspace_id from waitlist wl INNER JOIN workspaces w ON w.id=wl.hold_workspace_id
+			WHERE w.floor_id=$1 AND wl.status='held' AND wl.hold_expires_at > now() AND
+			( (wl.start_time <= $2 AND wl.end_time >= $2) 
+				OR (wl.start_time >= $2 AND wl.end_time <= $3) 
+				OR (wl.end_time >= $3 AND wl.start_time <= $3) );`
 	rows, err := tx.Query(sqlStmtBookings, floorId, start, end)
 	if err != nil {
 		log.Printf("PostgresDBStore.FindAvailability.getBookings: %v, sqlStatement: %s\n", err, sqlStmtBookings)
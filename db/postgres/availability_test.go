@@ -4,6 +4,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"go-api/db"
+	"go-api/db/migrations"
 	"go-api/utils"
 	"os"
 	"testing"
@@ -28,8 +29,10 @@ type AvailabilityTestSuite struct {
 
 func (suite *AvailabilityTestSuite) SetupSuite() {
 	dbUrl := os.Getenv("TEST_DB_URL")
+	if err := migrations.Reset(dbUrl); err != nil {
+		suite.FailNow("failed to migrate test db", err)
+	}
 	fixtures := []string{
-		"../../resources/tables.sql",
 		"../../test-fixtures/floors.sql",
 		"../../test-fixtures/users.sql",
 		"../../test-fixtures/workspaces.sql",
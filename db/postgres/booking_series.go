@@ -0,0 +1,165 @@
+package postgres
+
+import (
+	"go-api/db"
+	"go-api/model"
+	"time"
+)
+
+// CreateBookingSeries stores the series and books each occurrence that is available; occurrences that are not
+// offered or already booked are returned as conflicts instead of failing the whole series. That includes occurrences
+// another request books between their check and their insert: each is inserted under a savepoint, so that the
+// constraint rejecting it only undoes that occurrence
+func (p PostgresDBStore) CreateBookingSeries(series *model.BookingSeries, occurrences []*model.Booking) ([]*model.Booking, []*model.SeriesConflict, error) {
+	tx, err := p.database.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
+		`INSERT INTO booking_series(user_id, workspace_id, start_time, end_time, recurrence, created_by)
+				VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		series.UserID,
+		series.WorkspaceID,
+		series.StartDate,
+		series.EndDate,
+		series.Recurrence,
+		series.CreatedBy,
+	).Scan(&series.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bookings := make([]*model.Booking, 0)
+	conflicts := make([]*model.SeriesConflict, 0)
+	for _, occurrence := range occurrences {
+		if err = p.checkBookingAvailable(tx, occurrence); err != nil {
+			domainErr := db.AsError(err)
+			if domainErr == nil {
+				return nil, nil, err
+			}
+			conflicts = append(conflicts, &model.SeriesConflict{
+				StartDate: occurrence.StartDate,
+				EndDate:   occurrence.EndDate,
+				Reason:    domainErr.Message,
+				Code:      domainErr.Code,
+			})
+			continue
+		}
+		if _, err = tx.Exec(`SAVEPOINT occurrence`); err != nil {
+			return nil, nil, err
+		}
+		err = tx.QueryRow(
+			`INSERT INTO bookings(user_id, workspace_id, start_time, end_time, created_by, series_id)
+					VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+			series.UserID,
+			series.WorkspaceID,
+			occurrence.StartDate,
+			occurrence.EndDate,
+			series.CreatedBy,
+			series.ID,
+		).Scan(&occurrence.ID)
+		if err != nil {
+			domainErr := db.AsError(translateError(err))
+			if domainErr == nil {
+				return nil, nil, err
+			}
+			if _, err = tx.Exec(`ROLLBACK TO SAVEPOINT occurrence`); err != nil {
+				return nil, nil, err
+			}
+			conflicts = append(conflicts, &model.SeriesConflict{
+				StartDate: occurrence.StartDate,
+				EndDate:   occurrence.EndDate,
+				Reason:    domainErr.Message,
+				Code:      domainErr.Code,
+			})
+			continue
+		}
+		occurrence.UserID = series.UserID
+		occurrence.WorkspaceID = series.WorkspaceID
+		occurrence.CreatedBy = series.CreatedBy
+		occurrence.SeriesID = series.ID
+		bookings = append(bookings, occurrence)
+	}
+	if len(bookings) == 0 {
+		err := db.NewConflictError("no occurrence of the series is available")
+		err.Details = conflicts
+		return nil, conflicts, err
+	}
+	err = queueBookingNotifications(tx, model.BookingSeriesNotification(model.NotificationConfirmation, series.ID, bookings), series.WorkspaceID, series.CreatedBy)
+	if err != nil {
+		return nil, nil, err
+	}
+	return bookings, conflicts, tx.Commit()
+}
+
+func (p PostgresDBStore) GetBookingSeries(id string) (*model.BookingSeries, error) {
+	sqlStatement := `SELECT id, user_id, workspace_id, start_time, end_time, recurrence, cancelled, created_by
+					 FROM booking_series WHERE id=$1;`
+	var series model.BookingSeries
+	err := p.database.QueryRow(sqlStatement, id).Scan(
+		&series.ID,
+		&series.UserID,
+		&series.WorkspaceID,
+		&series.StartDate,
+		&series.EndDate,
+		&series.Recurrence,
+		&series.Cancelled,
+		&series.CreatedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+func (p PostgresDBStore) GetBookingsBySeriesID(id string) ([]*model.Booking, error) {
+	sqlStatement :=
+		`SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by, COALESCE(series_id::text, ''), checked_in_at, no_show FROM bookings
+				WHERE series_id=$1 ORDER BY start_time;`
+	return p.queryMultipleBookings(sqlStatement, id)
+}
+
+// CancelBookingSeries cancels the occurrences of a series starting at or after from and returns them;
+// a zero from cancels every remaining occurrence and marks the series itself as cancelled
+func (p PostgresDBStore) CancelBookingSeries(id string, from time.Time) ([]*model.Booking, error) {
+	tx, err := p.database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var cancelled bool
+	err = tx.QueryRow(`SELECT cancelled FROM booking_series WHERE id=$1 FOR UPDATE`, id).Scan(&cancelled)
+	if err != nil {
+		return nil, err
+	}
+	if cancelled {
+		return nil, db.NewConflictError("series is already cancelled")
+	}
+	if from.IsZero() {
+		if _, err = tx.Exec(`UPDATE booking_series SET cancelled = true WHERE id = $1`, id); err != nil {
+			return nil, err
+		}
+	}
+
+	bookings, err := queryBookings(tx.Query(
+		`UPDATE bookings
+				SET cancelled = true
+				WHERE series_id = $1 AND cancelled = FALSE AND start_time >= $2
+				RETURNING id, user_id, workspace_id, start_time, end_time, cancelled, created_by, series_id::text, checked_in_at, no_show;`,
+		id, from,
+	))
+	if err != nil {
+		return nil, err
+	}
+	if len(bookings) > 0 {
+		first := bookings[0]
+		err = queueBookingNotifications(tx, model.BookingSeriesNotification(model.NotificationCancellation, id, bookings), first.WorkspaceID, first.CreatedBy)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bookings, tx.Commit()
+}
@@ -1,14 +1,15 @@
 package postgres
 
 import (
-	"errors"
+	"database/sql"
+	"go-api/db"
 	"go-api/model"
 	"log"
 	"time"
 )
 
 func (p PostgresDBStore) GetOneBooking(id string) (*model.Booking, error) {
-	sqlStatement := `SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by FROM bookings WHERE id=$1;`
+	sqlStatement := `SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by, COALESCE(series_id::text, ''), checked_in_at, no_show FROM bookings WHERE id=$1;`
 	var booking model.Booking
 	row := p.database.QueryRow(sqlStatement, id)
 	err := row.Scan(
@@ -19,6 +20,9 @@ func (p PostgresDBStore) GetOneBooking(id string) (*model.Booking, error) {
 		&booking.EndDate,
 		&booking.Cancelled,
 		&booking.CreatedBy,
+		&booking.SeriesID,
+		&booking.CheckedInAt,
+		&booking.NoShow,
 	)
 	if err != nil {
 		return nil, err
@@ -27,7 +31,7 @@ func (p PostgresDBStore) GetOneBooking(id string) (*model.Booking, error) {
 }
 
 func (p PostgresDBStore) GetOneExpandedBooking(id string) (*model.ExpandedBooking, error) {
-	sqlStatement := `SELECT b.id, u.id, w.id, b.start_time, b.end_time, b.cancelled, b.created_by, w.name, u.name, f.id, f.name
+	sqlStatement := `SELECT b.id, u.id, w.id, b.start_time, b.end_time, b.cancelled, b.created_by, COALESCE(b.series_id::text, ''), b.checked_in_at, b.no_show, w.name, u.name, f.id, f.name
 					 FROM bookings AS b
          			 INNER JOIN users AS u ON b.user_id = u.id
          			 INNER JOIN workspaces AS w ON b.workspace_id = w.id
@@ -43,6 +47,9 @@ func (p PostgresDBStore) GetOneExpandedBooking(id string) (*model.ExpandedBookin
 		&eBooking.EndDate,
 		&eBooking.Cancelled,
 		&eBooking.CreatedBy,
+		&eBooking.SeriesID,
+		&eBooking.CheckedInAt,
+		&eBooking.NoShow,
 		&eBooking.WorkspaceName,
 		&eBooking.UserName,
 		&eBooking.FloorID,
@@ -55,12 +62,12 @@ func (p PostgresDBStore) GetOneExpandedBooking(id string) (*model.ExpandedBookin
 }
 
 func (p PostgresDBStore) GetAllBookings() ([]*model.Booking, error) {
-	sqlStatement := `SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by FROM bookings;`
+	sqlStatement := `SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by, COALESCE(series_id::text, ''), checked_in_at, no_show FROM bookings;`
 	return p.queryMultipleBookings(sqlStatement)
 }
 
 func (p PostgresDBStore) GetAllExpandedBookings() ([]*model.ExpandedBooking, error) {
-	sqlStatement := `SELECT b.id, u.id, w.id, b.start_time, b.end_time, b.cancelled, b.created_by, w.name, u.name, f.id, f.name
+	sqlStatement := `SELECT b.id, u.id, w.id, b.start_time, b.end_time, b.cancelled, b.created_by, COALESCE(b.series_id::text, ''), b.checked_in_at, b.no_show, w.name, u.name, f.id, f.name
 					 FROM bookings AS b
          			 INNER JOIN users AS u ON b.user_id = u.id
          			 INNER JOIN workspaces AS w ON b.workspace_id = w.id
@@ -71,13 +78,13 @@ func (p PostgresDBStore) GetAllExpandedBookings() ([]*model.ExpandedBooking, err
 
 func (p PostgresDBStore) GetBookingsByWorkspaceID(id string) ([]*model.Booking, error) {
 	sqlStatement :=
-		`SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by FROM bookings WHERE workspace_id=$1;`
+		`SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by, COALESCE(series_id::text, ''), checked_in_at, no_show FROM bookings WHERE workspace_id=$1;`
 	return p.queryMultipleBookings(sqlStatement, id)
 }
 
 func (p PostgresDBStore) GetExpandedBookingsByWorkspaceID(id string) ([]*model.ExpandedBooking, error) {
 	sqlStatement :=
-		`SELECT b.id, u.id, w.id, b.start_time, b.end_time, b.cancelled, b.created_by, w.name, u.name, f.id, f.name
+		`SELECT b.id, u.id, w.id, b.start_time, b.end_time, b.cancelled, b.created_by, COALESCE(b.series_id::text, ''), b.checked_in_at, b.no_show, w.name, u.name, f.id, f.name
 		 FROM bookings AS b
 		 INNER JOIN users AS u ON b.user_id = u.id
 		 INNER JOIN workspaces AS w ON b.workspace_id = w.id
@@ -89,13 +96,13 @@ func (p PostgresDBStore) GetExpandedBookingsByWorkspaceID(id string) ([]*model.E
 
 func (p PostgresDBStore) GetBookingsByUserID(id string) ([]*model.Booking, error) {
 	sqlStatement :=
-		`SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by FROM bookings WHERE user_id=$1;`
+		`SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by, COALESCE(series_id::text, ''), checked_in_at, no_show FROM bookings WHERE user_id=$1;`
 	return p.queryMultipleBookings(sqlStatement, id)
 }
 
 func (p PostgresDBStore) GetExpandedBookingsByUserID(id string) ([]*model.ExpandedBooking, error) {
 	sqlStatement :=
-		`SELECT b.id, u.id, w.id, b.start_time, b.end_time, b.cancelled, b.created_by, w.name, u.name, f.id, f.name
+		`SELECT b.id, u.id, w.id, b.start_time, b.end_time, b.cancelled, b.created_by, COALESCE(b.series_id::text, ''), b.checked_in_at, b.no_show, w.name, u.name, f.id, f.name
 		 FROM bookings AS b
 		 INNER JOIN users AS u ON b.user_id = u.id
 		 INNER JOIN workspaces AS w ON b.workspace_id = w.id
@@ -107,7 +114,7 @@ func (p PostgresDBStore) GetExpandedBookingsByUserID(id string) ([]*model.Expand
 
 func (p PostgresDBStore) GetBookingsByDateRange(start time.Time, end time.Time) ([]*model.Booking, error) {
 	sqlStatement :=
-		`SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by FROM bookings 
+		`SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by, COALESCE(series_id::text, ''), checked_in_at, no_show FROM bookings 
 				WHERE (start_time >= $1 AND end_time <= $2) OR 
 						(start_time <= $1 AND end_time >= $2) OR 
 						(start_time <= $1 AND end_time >= $1) OR 
@@ -117,7 +124,7 @@ func (p PostgresDBStore) GetBookingsByDateRange(start time.Time, end time.Time)
 
 func (p PostgresDBStore) GetExpandedBookingsByDateRange(start time.Time, end time.Time) ([]*model.ExpandedBooking, error) {
 	sqlStatement :=
-		`SELECT b.id, u.id, w.id, b.start_time, b.end_time, b.cancelled, b.created_by, w.name, u.name, f.id, f.name
+		`SELECT b.id, u.id, w.id, b.start_time, b.end_time, b.cancelled, b.created_by, COALESCE(b.series_id::text, ''), b.checked_in_at, b.no_show, w.name, u.name, f.id, f.name
 		 FROM bookings AS b
 		 INNER JOIN users AS u ON b.user_id = u.id
 		 INNER JOIN workspaces AS w ON b.workspace_id = w.id
@@ -133,97 +140,298 @@ func (p PostgresDBStore) GetExpandedBookingsByDateRange(start time.Time, end tim
 func (p PostgresDBStore) CreateBooking(booking *model.Booking) (string, error) {
 	tx, err := p.database.Begin()
 	if err != nil {
-		return "", nil
+		return "", err
+	}
+	defer tx.Rollback()
+	if err = p.checkBookingAvailable(tx, booking); err != nil {
+		return "", err
+	}
+
+	sqlStatement :=
+		`INSERT INTO bookings(user_id, workspace_id, start_time, end_time, created_by) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	var id string
+	err = tx.QueryRow(sqlStatement,
+		booking.UserID,
+		booking.WorkspaceID,
+		booking.StartDate,
+		booking.EndDate,
+		booking.CreatedBy,
+	).Scan(&id)
+	if err != nil {
+		return "", translateError(err)
+	}
+	err = queueBookingNotifications(tx, &model.Notification{
+		Kind:      model.NotificationConfirmation,
+		Subject:   model.NotificationBooking,
+		SubjectID: id,
+		UserID:    booking.UserID,
+		StartDate: booking.StartDate,
+		EndDate:   booking.EndDate,
+	}, booking.WorkspaceID, booking.CreatedBy)
+	if err != nil {
+		return "", err
 	}
+	return id, tx.Commit()
+}
+
+// checkBookingAvailable makes sure the workspace is offered for the whole booking, that no other booking
+// overlaps it, that it isn't held for another user and that the booking policy allows it; booking.ID is
+// ignored when looking for overlaps so a booking can be moved
+func (p PostgresDBStore) checkBookingAvailable(tx *sql.Tx, booking *model.Booking) error {
 	// Check if offering still exists
 	var count int
-	err = tx.QueryRow(
+	err := tx.QueryRow(
 		`SELECT count(*) FROM offerings 
 					WHERE workspace_id=$1 AND cancelled=FALSE AND
                     	   (start_time <= $2 AND (end_time >= $3 OR end_time IS NULL))`,
 		booking.WorkspaceID, booking.StartDate, booking.EndDate,
 	).Scan(&count)
 	if err != nil || count == 0 {
-		return "", errors.New("invalid operation: workspace is not offered")
+		return db.NewValidationError("workspace is not offered")
 	}
 
 	// Check for conflicts
 	err = tx.QueryRow(
 		`SELECT count(*) FROM bookings 
-					WHERE workspace_id=$1 AND cancelled=FALSE AND
+					WHERE workspace_id=$1 AND cancelled=FALSE AND id::text <> $4 AND
                     	   ((start_time <= $2 AND end_time >= $3) OR
                     	    (start_time <= $2 AND end_time >= $2) OR 
                     	    (start_time <= $3 AND end_time >= $3) OR
                     	    (start_time >= $2 AND end_time <= $3))`,
-		booking.WorkspaceID, booking.StartDate, booking.EndDate,
+		booking.WorkspaceID, booking.StartDate, booking.EndDate, booking.ID,
 	).Scan(&count)
 	if err != nil || count > 0 {
-		return "", errors.New("invalid operation: workspace already booked for this duration")
+		return db.NewConflictError("workspace already booked for this duration")
 	}
 
-	sqlStatement :=
-		`INSERT INTO bookings(user_id, workspace_id, start_time, end_time, created_by) VALUES ($1, $2, $3, $4, $5) RETURNING id`
-	var id string
-	err = tx.QueryRow(sqlStatement,
-		booking.UserID,
-		booking.WorkspaceID,
-		booking.StartDate,
-		booking.EndDate,
-		booking.CreatedBy,
-	).Scan(&id)
-	if err != nil {
-		return "", err
+	// Check the workspace isn't held for someone on the waitlist
+	err = tx.QueryRow(
+		`SELECT count(*) FROM waitlist 
+					WHERE hold_workspace_id=$1 AND status='held' AND hold_expires_at > now() AND user_id::text <> $4 AND
+                    	   ((start_time <= $2 AND end_time >= $3) OR
+                    	    (start_time <= $2 AND end_time >= $2) OR 
+                    	    (start_time <= $3 AND end_time >= $3) OR
+                    	    (start_time >= $2 AND end_time <= $3))`,
+		booking.WorkspaceID, booking.StartDate, booking.EndDate, booking.UserID,
+	).Scan(&count)
+	if err != nil || count > 0 {
+		return db.NewConflictError("workspace is held for a waitlisted user")
 	}
-	return id, tx.Commit()
+	return p.checkBookingPolicy(tx, booking)
 }
 
+// UpdateBooking moves a booking to a new workspace and/or time; the same availability rules as CreateBooking apply
 func (p PostgresDBStore) UpdateBooking(id string, booking *model.Booking) error {
+	tx, err := p.database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var cancelled bool
+	// Lock the row so two concurrent moves of the same booking can't both pass the checks
+	err = tx.QueryRow(`SELECT cancelled FROM bookings WHERE id=$1 FOR UPDATE`, id).Scan(&cancelled)
+	if err != nil {
+		return err
+	}
+	if cancelled {
+		return db.NewConflictError("cancelled bookings cannot be modified")
+	}
+	booking.ID = id
+	if err = p.checkBookingAvailable(tx, booking); err != nil {
+		return err
+	}
+
 	sqlStatement :=
 		`UPDATE bookings
-				SET user_id = $2, workspace_id = $3, cancelled = $4, start_time = $5, end_time = $6, created_by = $7
+				SET workspace_id = $2, start_time = $3, end_time = $4
 				WHERE id = $1
-				RETURNING id;`
-	var _id string
-	err := p.database.QueryRow(sqlStatement,
+				RETURNING id, user_id;`
+	var _id, userId string
+	err = tx.QueryRow(sqlStatement,
 		id,
-		booking.UserID,
 		booking.WorkspaceID,
-		booking.Cancelled,
 		booking.StartDate,
 		booking.EndDate,
-		booking.CreatedBy,
-	).Scan(&_id)
+	).Scan(&_id, &userId)
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 	if _id != id {
-		return CreateError
+		return db.NotFoundError
+	}
+	err = queueNotification(tx, &model.Notification{
+		Kind:      model.NotificationUpdate,
+		Subject:   model.NotificationBooking,
+		SubjectID: id,
+		UserID:    userId,
+		StartDate: booking.StartDate,
+		EndDate:   booking.EndDate,
+	}, booking.WorkspaceID)
+	if err != nil {
+		return err
 	}
-	return nil
+	return tx.Commit()
 }
 
 func (p PostgresDBStore) RemoveBooking(id string) error {
-	sqlStatement :=
+	tx, err := p.database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	notification := &model.Notification{
+		Kind:      model.NotificationCancellation,
+		Subject:   model.NotificationBooking,
+		SubjectID: id,
+	}
+	var cancelled bool
+	if err = tx.QueryRow(`SELECT cancelled FROM bookings WHERE id=$1 FOR UPDATE`, id).Scan(&cancelled); err != nil {
+		return err
+	}
+	if cancelled {
+		return db.NewConflictError("booking is already cancelled")
+	}
+	var workspaceId, createdBy string
+	err = tx.QueryRow(
 		`UPDATE bookings
 				SET cancelled = true
-				WHERE id = $1
-				RETURNING id;`
-	var _id string
-	err := p.database.QueryRow(sqlStatement,
+				WHERE id = $1 AND cancelled = FALSE
+				RETURNING user_id, workspace_id, start_time, end_time, created_by;`,
 		id,
-	).Scan(&_id)
+	).Scan(&notification.UserID, &workspaceId, &notification.StartDate, &notification.EndDate, &createdBy)
 	if err != nil {
 		return err
 	}
-	if _id != id {
-		return CreateError
+	if err = queueBookingNotifications(tx, notification, workspaceId, createdBy); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CheckInBooking records that the user arrived for booking id. Check-in opens grace before each day of the
+// booking starts and closes grace after; later than that the rest of the booking is released as a no-show
+func (p PostgresDBStore) CheckInBooking(id string, now time.Time, grace time.Duration) (*model.Booking, error) {
+	tx, err := p.database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var booking model.Booking
+	var checkedInAt sql.NullTime
+	err = tx.QueryRow(`SELECT cancelled, start_time, end_time, checked_in_at FROM bookings WHERE id=$1 FOR UPDATE`, id).
+		Scan(&booking.Cancelled, &booking.StartDate, &booking.EndDate, &checkedInAt)
+	if err != nil {
+		return nil, err
+	}
+	if booking.Cancelled {
+		return nil, db.NewConflictError("booking is cancelled")
+	}
+	day := booking.DayStart(now.Add(grace))
+	if checkedInAt.Valid && !checkedInAt.Time.Before(day.Add(-grace)) {
+		return nil, db.NewConflictError("already checked in")
+	}
+	if now.Before(day.Add(-grace)) {
+		return nil, db.NewValidationError("check-in is not open yet")
+	}
+	if now.After(day.Add(grace)) || !day.Before(booking.EndDate) {
+		return nil, db.NewValidationError("check-in window has passed")
+	}
+	if _, err = tx.Exec(`UPDATE bookings SET checked_in_at = $2 WHERE id = $1`, id, now); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return p.GetOneBooking(id)
+}
+
+// ReleaseNoShows releases the bookings nobody checked in to on the day of the booking now falls on, once grace
+// has passed since it started, and marks them as no-shows. A booking missed on its first day is cancelled, one missed
+// on a later day ends when that day starts. It returns the released part of each booking, as a cancelled booking.
+// Bookings made before check-in existed don't require it and are never released
+func (p PostgresDBStore) ReleaseNoShows(now time.Time, grace time.Duration) ([]*model.Booking, error) {
+	tx, err := p.database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	// the conditions on b are checked again against the latest row if another instance releases it first
+	sqlStatement :=
+		`UPDATE bookings b
+				SET no_show = TRUE,
+				    cancelled = d.day_start = b.start_time,
+				    end_time = CASE WHEN d.day_start = b.start_time THEN b.end_time ELSE d.day_start END
+				FROM (SELECT id, end_time,
+				             start_time + floor(extract(epoch FROM $1::timestamptz - start_time) / $3::float8) * $3::float8 * interval '1 second' AS day_start
+				      FROM bookings
+				      WHERE cancelled = FALSE AND requires_check_in AND start_time < $1 AND end_time > $1) d
+				WHERE b.id = d.id AND b.cancelled = FALSE AND b.end_time > d.day_start
+				  AND d.day_start + $2::float8 * interval '1 second' < $1
+				  AND (b.checked_in_at IS NULL OR b.checked_in_at < d.day_start - $2::float8 * interval '1 second')
+				RETURNING b.id, b.user_id, b.workspace_id, d.day_start, d.end_time, TRUE, b.created_by, COALESCE(b.series_id::text, ''), b.checked_in_at, b.no_show;`
+	released, err := queryBookings(tx.Query(sqlStatement, now, grace.Seconds(), model.BookingDay.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	for _, booking := range released {
+		err = queueBookingNotifications(tx, &model.Notification{
+			Kind:      model.NotificationCancellation,
+			Subject:   model.NotificationBooking,
+			SubjectID: booking.ID,
+			UserID:    booking.UserID,
+			StartDate: booking.StartDate,
+			EndDate:   booking.EndDate,
+		}, booking.WorkspaceID, booking.CreatedBy)
+		if err != nil {
+			return nil, err
+		}
 	}
-	return nil
+	return released, tx.Commit()
+}
+
+// GetNoShowCounts counts the no-shows of every user with at least min of them since the given time, worst first
+func (p PostgresDBStore) GetNoShowCounts(since time.Time, min int) ([]*model.NoShowCount, error) {
+	sqlStatement :=
+		`SELECT u.id, u.name, u.department, count(*) AS no_shows
+		 FROM bookings AS b
+		 INNER JOIN users AS u ON b.user_id = u.id
+		 WHERE b.no_show = TRUE AND b.start_time >= $1
+		 GROUP BY u.id, u.name, u.department
+		 HAVING count(*) >= $2
+		 ORDER BY no_shows DESC, u.name;`
+	rows, err := p.database.Query(sqlStatement, since, min)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make([]*model.NoShowCount, 0)
+	for rows.Next() {
+		var count model.NoShowCount
+		err := rows.Scan(
+			&count.UserID,
+			&count.UserName,
+			&count.Department,
+			&count.NoShows,
+		)
+		if err != nil {
+			// dont cause panic here, log it
+			log.Printf("PostgresDBStore.GetNoShowCounts: %v, sqlStatement: %s\n", err, sqlStatement)
+			continue
+		}
+		counts = append(counts, &count)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
 }
 
 func (p PostgresDBStore) GetExpiredBookings(since time.Time) ([]*model.Booking, error) {
 	sqlStatement :=
-		`SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by FROM bookings 
+		`SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by, COALESCE(series_id::text, ''), checked_in_at, no_show FROM bookings 
 				WHERE end_time < $1`
 	return p.queryMultipleBookings(sqlStatement, since)
 }
@@ -245,6 +453,9 @@ func (p PostgresDBStore) queryMultipleBookings(sqlStatement string, args ...inte
 			&booking.EndDate,
 			&booking.Cancelled,
 			&booking.CreatedBy,
+			&booking.SeriesID,
+			&booking.CheckedInAt,
+			&booking.NoShow,
 		)
 		if err != nil {
 			// dont cause panic here, log it
@@ -259,6 +470,36 @@ func (p PostgresDBStore) queryMultipleBookings(sqlStatement string, args ...inte
 	return bookings, nil
 }
 
+// queryBookings reads the bookings rows returns, in the column order of queryMultipleBookings, failing on the first
+// row that can't be read; it is for statements that change the bookings, whose result can't be partly read
+func queryBookings(rows *sql.Rows, err error) ([]*model.Booking, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	bookings := make([]*model.Booking, 0)
+	for rows.Next() {
+		var booking model.Booking
+		err := rows.Scan(
+			&booking.ID,
+			&booking.UserID,
+			&booking.WorkspaceID,
+			&booking.StartDate,
+			&booking.EndDate,
+			&booking.Cancelled,
+			&booking.CreatedBy,
+			&booking.SeriesID,
+			&booking.CheckedInAt,
+			&booking.NoShow,
+		)
+		if err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, &booking)
+	}
+	return bookings, rows.Err()
+}
+
 func (p PostgresDBStore) queryMultipleExpandedBookings(sqlStatement string, args ...interface{}) ([]*model.ExpandedBooking, error) {
 	rows, err := p.database.Query(sqlStatement, args...)
 	if err != nil {
@@ -276,6 +517,9 @@ func (p PostgresDBStore) queryMultipleExpandedBookings(sqlStatement string, args
 			&eBooking.EndDate,
 			&eBooking.Cancelled,
 			&eBooking.CreatedBy,
+			&eBooking.SeriesID,
+			&eBooking.CheckedInAt,
+			&eBooking.NoShow,
 			&eBooking.WorkspaceName,
 			&eBooking.UserName,
 			&eBooking.FloorID,
@@ -0,0 +1,192 @@
+package postgres
+
+import (
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go-api/db"
+	"go-api/db/migrations"
+	"go-api/model"
+	"go-api/utils"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	BarryId = "e99a988a-1d41-3997-8d59-959a48ac24a0"
+	DianaId = "ab6c2c4f-c112-3c1e-bcf1-42cdea289c1f"
+
+	concurrentRequests = 20
+)
+
+type OverlapTestSuite struct {
+	suite.Suite
+	store *db.DataStore
+}
+
+func (suite *OverlapTestSuite) SetupSuite() {
+	dbUrl := os.Getenv("TEST_DB_URL")
+	if err := migrations.Reset(dbUrl); err != nil {
+		suite.FailNow("failed to migrate test db", err)
+	}
+	fixtures := []string{
+		"../../test-fixtures/floors.sql",
+		"../../test-fixtures/users.sql",
+		"../../test-fixtures/workspaces.sql",
+		"../../test-fixtures/book_offer.sql",
+	}
+	if err := utils.RunFixturesOnDB(dbUrl, fixtures); err != nil {
+		suite.FailNow("failed to re-seed test db")
+	}
+	store, err := NewPostgresDataStore(dbUrl)
+	if err != nil {
+		suite.FailNow("failed to connect to DB" + err.Error())
+	}
+	suite.store = store
+}
+
+func TestOverlap(t *testing.T) {
+	suite.Run(t, new(OverlapTestSuite))
+}
+
+// race runs create from many goroutines at once and returns the ids that were created and the errors of the rest
+func race(create func() (string, error)) ([]string, []error) {
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		ids   []string
+		errs  []error
+		start = make(chan struct{})
+	)
+	for i := 0; i < concurrentRequests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			id, err := create()
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			ids = append(ids, id)
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return ids, errs
+}
+
+func (suite *OverlapTestSuite) TestConcurrentBookings() {
+	t := suite.T()
+	ids, errs := race(func() (string, error) {
+		return suite.store.BookingProvider.CreateBooking(&model.Booking{
+			UserID:      BarryId,
+			WorkspaceID: Workspace5,
+			StartDate:   date("2019-01-23T09:00:00Z"),
+			EndDate:     date("2019-01-23T17:00:00Z"),
+			CreatedBy:   BarryId,
+		})
+	})
+	assert.Len(t, ids, 1)
+	assert.Len(t, errs, concurrentRequests-1)
+	for _, err := range errs {
+		assert.True(t, db.IsKind(err, db.Conflict), err.Error())
+	}
+	for _, id := range ids {
+		assert.NoError(t, suite.store.BookingProvider.RemoveBooking(id))
+	}
+}
+
+func (suite *OverlapTestSuite) TestConcurrentOfferings() {
+	t := suite.T()
+	ids, errs := race(func() (string, error) {
+		return suite.store.OfferingProvider.CreateOffering(&model.Offering{
+			UserID:      DianaId,
+			WorkspaceID: Workspace5,
+			StartDate:   date("2019-02-04T00:00:00Z"),
+			EndDate:     date("2019-02-08T23:59:59Z"),
+			CreatedBy:   DianaId,
+		})
+	})
+	assert.Len(t, ids, 1)
+	assert.Len(t, errs, concurrentRequests-1)
+	for _, err := range errs {
+		assert.True(t, db.IsKind(err, db.Conflict), err.Error())
+	}
+	for _, id := range ids {
+		assert.NoError(t, suite.store.OfferingProvider.RemoveOffering(id))
+	}
+}
+
+func (suite *OverlapTestSuite) TestConcurrentOfferingSeries() {
+	t := suite.T()
+	var mu sync.Mutex
+	day := 0
+	var conflicts int
+	ids, errs := race(func() (string, error) {
+		mu.Lock()
+		day++
+		own := date("2019-03-01T00:00:00Z").AddDate(0, 0, day)
+		mu.Unlock()
+		series := &model.OfferingSeries{
+			UserID:      DianaId,
+			WorkspaceID: Workspace5,
+			StartDate:   date("2019-02-11T00:00:00Z"),
+			EndDate:     date("2019-02-11T23:59:59Z"),
+			Recurrence:  model.Recurrence{Frequency: model.FrequencyDaily, Count: 2},
+			CreatedBy:   DianaId,
+		}
+		// every series wants the same first day and a day of its own
+		_, seriesConflicts, err := suite.store.OfferingProvider.CreateOfferingSeries(series, []*model.Offering{
+			{StartDate: series.StartDate, EndDate: series.EndDate},
+			{StartDate: own, EndDate: own.Add(time.Hour)},
+		})
+		mu.Lock()
+		conflicts += len(seriesConflicts)
+		mu.Unlock()
+		return series.ID, err
+	})
+	assert.Len(t, ids, concurrentRequests, "losing the race for one occurrence doesn't fail the series")
+	assert.Empty(t, errs)
+	assert.Equal(t, concurrentRequests-1, conflicts)
+	for _, id := range ids {
+		_, _, err := suite.store.OfferingProvider.CancelOfferingSeries(id, time.Time{})
+		assert.NoError(t, err)
+	}
+}
+
+func (suite *OverlapTestSuite) TestConcurrentAssignments() {
+	t := suite.T()
+	_, errs := race(func() (string, error) {
+		return "", suite.store.WorkspaceProvider.CreateAssignment(BarryId, Workspace6)
+	})
+	// requests that start after the first one committed see the assignment and leave it be
+	for _, err := range errs {
+		assert.True(t, db.IsKind(err, db.Conflict), err.Error())
+	}
+	var count int
+	err := suite.store.WorkspaceProvider.(*PostgresDBStore).database.QueryRow(
+		`SELECT count(*) FROM workspace_assignee WHERE workspace_id=$1 AND end_time IS NULL`, Workspace6,
+	).Scan(&count)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestTranslateError(t *testing.T) {
+	err := translateError(&pq.Error{Code: exclusionViolation, Constraint: "bookings_no_overlap"})
+	assert.True(t, db.IsKind(err, db.Conflict))
+	assert.Equal(t, "workspace already booked for this duration", err.Error())
+
+	err = translateError(&pq.Error{Code: exclusionViolation, Constraint: "workspace_assignee_no_overlap"})
+	assert.Equal(t, "workspace is already assigned for this duration", err.Error())
+
+	err = translateError(&pq.Error{Code: dataException, Message: "range lower bound must be less than or equal to range upper bound"})
+	assert.True(t, db.IsKind(err, db.Validation))
+
+	other := &pq.Error{Code: "23505", Constraint: "users_pkey"}
+	assert.Equal(t, other, translateError(other))
+}
@@ -0,0 +1,160 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/lib/pq"
+	"go-api/db"
+	"go-api/model"
+	"time"
+)
+
+const channelColumns = `id, COALESCE(user_id::text, ''), COALESCE(floor_id::text, ''), kind, url, secret, events, created_at`
+
+const channelDeliveryColumns = `id, channel_id, event, event_key, body, status, attempts, next_attempt_at,
+	response_status, last_error, created_at, delivered_at`
+
+func scanChannel(row scanner) (*model.Channel, error) {
+	var channel model.Channel
+	err := row.Scan(
+		&channel.ID,
+		&channel.UserID,
+		&channel.FloorID,
+		&channel.Kind,
+		&channel.URL,
+		&channel.Secret,
+		pq.Array(&channel.Events),
+		&channel.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
+
+func scanChannelDelivery(row scanner) (*model.ChannelDelivery, error) {
+	var delivery model.ChannelDelivery
+	var body []byte
+	var deliveredAt sql.NullTime
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.ChannelID,
+		&delivery.Event,
+		&delivery.Key,
+		&body,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.ResponseStatus,
+		&delivery.LastError,
+		&delivery.CreatedAt,
+		&deliveredAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Body = json.RawMessage(body)
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	return &delivery, nil
+}
+
+func (p PostgresDBStore) GetChannels(userId, floorId string) ([]*model.Channel, error) {
+	rows, err := p.database.Query(
+		`SELECT `+channelColumns+` FROM channels
+				WHERE user_id = NULLIF($1, '')::uuid OR floor_id = NULLIF($2, '')::uuid ORDER BY created_at, id`,
+		userId, floorId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	channels := make([]*model.Channel, 0)
+	for rows.Next() {
+		channel, err := scanChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, channel)
+	}
+	return channels, rows.Err()
+}
+
+func (p PostgresDBStore) GetOneChannel(id string) (*model.Channel, error) {
+	return scanChannel(p.database.QueryRow(`SELECT `+channelColumns+` FROM channels WHERE id = $1`, id))
+}
+
+func (p PostgresDBStore) CreateChannel(channel *model.Channel) (string, error) {
+	if (channel.UserID == "") == (channel.FloorID == "") {
+		return "", db.NewValidationError("a channel belongs to either a user or a floor")
+	}
+	if channel.Events == nil {
+		channel.Events = []string{}
+	}
+	err := p.database.QueryRow(
+		`INSERT INTO channels(user_id, floor_id, kind, url, secret, events)
+				VALUES (NULLIF($1, '')::uuid, NULLIF($2, '')::uuid, $3, $4, $5, $6)
+				RETURNING id, created_at`,
+		channel.UserID, channel.FloorID, channel.Kind, channel.URL, channel.Secret, pq.Array(channel.Events),
+	).Scan(&channel.ID, &channel.CreatedAt)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == foreignKeyViolation {
+		return "", db.NotFoundError
+	}
+	if err != nil {
+		return "", err
+	}
+	return channel.ID, nil
+}
+
+func (p PostgresDBStore) RemoveChannel(id string) error {
+	var removed string
+	err := p.database.QueryRow(`DELETE FROM channels WHERE id = $1 RETURNING id`, id).Scan(&removed)
+	if err == sql.ErrNoRows {
+		return db.NotFoundError
+	}
+	return err
+}
+
+func (p PostgresDBStore) QueueChannelDelivery(channelId, eventType, key string, body []byte) error {
+	_, err := p.database.Exec(
+		`INSERT INTO channel_deliveries(channel_id, event, event_key, body) VALUES ($1, $2, $3, $4::jsonb)
+				ON CONFLICT (channel_id, event_key) WHERE event_key <> '' DO NOTHING`,
+		channelId, eventType, key, string(body),
+	)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == foreignKeyViolation {
+		return db.NotFoundError
+	}
+	return err
+}
+
+func (p PostgresDBStore) ClaimChannelDeliveries(now time.Time, limit int, lease time.Duration) ([]*model.ChannelDelivery, error) {
+	rows, err := channelQueue.claim(p.database, channelDeliveryColumns, now, limit, lease)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	deliveries := make([]*model.ChannelDelivery, 0)
+	for rows.Next() {
+		delivery, err := scanChannelDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+func (p PostgresDBStore) MarkChannelDelivered(id string, attempt *model.DeliveryAttempt) error {
+	_, err := channelQueue.markDelivered(p.database, id, attempt)
+	return err
+}
+
+func (p PostgresDBStore) MarkChannelFailed(id string, attempt *model.DeliveryAttempt) error {
+	_, err := channelQueue.markFailed(p.database, id, attempt)
+	return err
+}
+
+func (p PostgresDBStore) DeleteChannelDeliveriesBefore(t time.Time) error {
+	return channelQueue.deleteBefore(p.database, t)
+}
@@ -0,0 +1,20 @@
+package postgres
+
+import (
+	"github.com/stretchr/testify/suite"
+	"go-api/db"
+	"go-api/db/migrations"
+	"go-api/db/storetest"
+	"os"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	dbUrl := os.Getenv("TEST_DB_URL")
+	suite.Run(t, &storetest.Suite{NewStore: func() (*db.DataStore, error) {
+		if err := migrations.Reset(dbUrl); err != nil {
+			return nil, err
+		}
+		return NewPostgresDataStore(dbUrl)
+	}})
+}
@@ -2,16 +2,52 @@ package postgres
 
 import (
 	"database/sql"
-	"errors"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"go-api/db"
+	"strings"
+	"time"
 )
 
 type PostgresDBStore struct {
 	database *sql.DB
+	// defaultZone is the zone the department cap counts days in for users without a time zone
+	defaultZone *time.Location
 }
 
-var CreateError = errors.New("create error")
+// exclusionViolation is the SQLSTATE postgres reports when a row breaks an EXCLUDE constraint
+const exclusionViolation = "23P01"
+
+// dataException is the SQLSTATE postgres reports, among others, when the range of an EXCLUDE constraint ends before
+// it starts
+const dataException = "22000"
+
+// rangeError is the error of a row whose range ends before it starts, as the memory store reports it
+var rangeError = db.NewValidationError("end time is before start time")
+
+// overlapMessages maps the exclusion constraints in migration 0006 to the conflict they represent
+var overlapMessages = map[string]string{
+	"bookings_no_overlap":           "workspace already booked for this duration",
+	"offerings_no_overlap":          "workspace already offered for this duration",
+	"workspace_assignee_no_overlap": "workspace is already assigned for this duration",
+}
+
+// translateError turns overlap constraint violations into conflict errors, and ranges that end before they start
+// into validation errors. The availability checks catch most overlaps first, but only the constraints hold when two
+// transactions race for the same workspace
+func translateError(err error) error {
+	pqErr, ok := err.(*pq.Error)
+	if ok && pqErr.Code == dataException && strings.HasPrefix(pqErr.Message, "range lower bound must be") {
+		return rangeError
+	}
+	if !ok || pqErr.Code != exclusionViolation {
+		return err
+	}
+	message, ok := overlapMessages[pqErr.Constraint]
+	if !ok {
+		message = "workspace is not available for this duration"
+	}
+	return db.NewConflictError(message)
+}
 
 func (p PostgresDBStore) Close() {
 	p.database.Close()
@@ -35,5 +71,14 @@ func NewPostgresDataStore(dbUrl string) (*db.DataStore, error) {
 		UserProvider:      dbStore,
 		FloorProvider:     dbStore,
 		AssigneeProvider:  dbStore,
+		WaitlistProvider:  dbStore,
+		PolicyProvider:    dbStore,
+		LockProvider:      dbStore,
+		ArchiveProvider:   dbStore,
+		OutboxProvider:    dbStore,
+		TemplateProvider:  dbStore,
+		ReminderProvider:  dbStore,
+		ChannelProvider:   dbStore,
+		WebhookProvider:   dbStore,
 	}, nil
 }
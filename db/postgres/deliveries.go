@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"go-api/db"
+	"go-api/model"
+	"time"
+)
+
+// deliveryQueue is a table of deliveries, channel_deliveries or webhook_deliveries. Both are claimed, marked and
+// forgotten alike; they only differ in the column of the channel or webhook a delivery is sent to
+type deliveryQueue struct {
+	table  string
+	target string
+	// due narrows the pending deliveries that can be claimed, e.g. to those of active webhooks; empty means all
+	due string
+}
+
+var (
+	channelQueue = deliveryQueue{table: "channel_deliveries", target: "channel_id"}
+	webhookQueue = deliveryQueue{
+		table:  "webhook_deliveries",
+		target: "webhook_id",
+		due:    "webhook_id IN (SELECT id FROM webhooks WHERE active)",
+	}
+)
+
+// rowQuerier is a *sql.DB or a *sql.Tx
+type rowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// claim returns the columns of up to limit pending deliveries due at now and pushes their next attempt lease past
+// now, so that other instances don't claim them while they are being sent
+func (q deliveryQueue) claim(database *sql.DB, columns string, now time.Time, limit int, lease time.Duration) (*sql.Rows, error) {
+	due := "TRUE"
+	if q.due != "" {
+		due = q.due
+	}
+	// SKIP LOCKED lets instances claiming at the same time take different deliveries
+	return database.Query(
+		fmt.Sprintf(`UPDATE %[1]s SET next_attempt_at = $2
+				WHERE id IN (SELECT id FROM %[1]s
+				             WHERE status = 'pending' AND next_attempt_at <= $1 AND %[2]s
+				             ORDER BY next_attempt_at LIMIT $3 FOR UPDATE SKIP LOCKED)
+				RETURNING %[3]s`, q.table, due, columns),
+		now, now.Add(lease), limit,
+	)
+}
+
+// markDelivered records the successful attempt of the pending delivery with id and returns what it was sent to
+func (q deliveryQueue) markDelivered(database rowQuerier, id string, attempt *model.DeliveryAttempt) (string, error) {
+	var target string
+	err := database.QueryRow(
+		fmt.Sprintf(`UPDATE %s SET status = 'delivered', attempts = attempts + 1, response_status = $2,
+				       last_error = '', delivered_at = $3
+				WHERE id = $1 AND status = 'pending'
+				RETURNING %s`, q.table, q.target),
+		id, attempt.ResponseStatus, attempt.At,
+	).Scan(&target)
+	if err == sql.ErrNoRows {
+		return "", db.NotFoundError
+	}
+	return target, err
+}
+
+// markFailed records the failed attempt of the pending delivery with id and returns what it was sent to. The
+// delivery is retried at attempt.NextAttemptAt, or dead-lettered if it is nil
+func (q deliveryQueue) markFailed(database rowQuerier, id string, attempt *model.DeliveryAttempt) (string, error) {
+	var target string
+	err := database.QueryRow(
+		fmt.Sprintf(`UPDATE %s SET attempts = attempts + 1, response_status = $2, last_error = $3,
+				       status = CASE WHEN $4::timestamptz IS NULL THEN 'dead' ELSE 'pending' END,
+				       next_attempt_at = COALESCE($4::timestamptz, next_attempt_at)
+				WHERE id = $1 AND status = 'pending'
+				RETURNING %s`, q.table, q.target),
+		id, attempt.ResponseStatus, attempt.Error, nullTime(attempt.NextAttemptAt),
+	).Scan(&target)
+	if err == sql.ErrNoRows {
+		return "", db.NotFoundError
+	}
+	return target, err
+}
+
+// deleteBefore forgets the delivered and dead deliveries created before t
+func (q deliveryQueue) deleteBefore(database *sql.DB, t time.Time) error {
+	_, err := database.Exec(fmt.Sprintf(`DELETE FROM %s WHERE status <> 'pending' AND created_at < $1`, q.table), t)
+	return err
+}
@@ -1,7 +1,7 @@
 package postgres
 
 import (
-	"errors"
+	"go-api/db"
 	"go-api/model"
 	"log"
 	"time"
@@ -81,7 +81,7 @@ func (p PostgresDBStore) CreateFloor(floor *model.Floor) (string, error) {
 //		return err
 //	}
 //	if _id != id {
-//		return CreateError
+//		return db.NotFoundError
 //	}
 //	return nil
 //}
@@ -107,7 +107,7 @@ func (p PostgresDBStore) RemoveFloor(id string, force bool) error {
 	}
 	if count > 0 && !force {
 		log.Println("Postgres.RemoveFloor: there are existing bookings for workspaces on this floor")
-		return errors.New("invalid operation: there are existing bookings for workspaces on this floor")
+		return db.NewConflictError("there are existing bookings for workspaces on this floor")
 	}
 
 	deleteWorkspaceStmt := `UPDATE workspaces SET deleted=true WHERE floor_id=$1`
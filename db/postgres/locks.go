@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"context"
+	"hash/fnv"
+)
+
+// TryLock takes a session level advisory lock keyed by a hash of name on a connection of its own, which release
+// closes. If the instance dies the connection drops and postgres frees the lock for another instance
+func (p PostgresDBStore) TryLock(name string) (func(), bool, error) {
+	ctx := context.Background()
+	conn, err := p.database.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	var acquired bool
+	if err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, lockKey(name)).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	if !acquired {
+		conn.Close()
+		return nil, false, nil
+	}
+	release := func() {
+		conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, lockKey(name))
+		conn.Close()
+	}
+	return release, true, nil
+}
+
+// lockKey maps a lock name to an advisory lock key
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("go-api:" + name))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,210 @@
+package postgres
+
+import (
+	"go-api/db"
+	"go-api/model"
+	"time"
+)
+
+// CreateOfferingSeries stores the series and offers each occurrence the workspace is assigned for and not already
+// offered; the other occurrences, also those lost to a concurrent offering, are returned as conflicts instead of
+// failing the whole series
+func (p PostgresDBStore) CreateOfferingSeries(series *model.OfferingSeries, occurrences []*model.Offering) ([]*model.Offering, []*model.SeriesConflict, error) {
+	tx, err := p.database.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
+		`INSERT INTO offering_series(user_id, workspace_id, start_time, end_time, recurrence, created_by)
+				VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		series.UserID,
+		series.WorkspaceID,
+		series.StartDate,
+		series.EndDate,
+		series.Recurrence,
+		series.CreatedBy,
+	).Scan(&series.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	offerings := make([]*model.Offering, 0)
+	conflicts := make([]*model.SeriesConflict, 0)
+	for _, occurrence := range occurrences {
+		if err = checkOfferingAvailable(tx, occurrence); err != nil {
+			domainErr := db.AsError(err)
+			if domainErr == nil {
+				return nil, nil, err
+			}
+			conflicts = append(conflicts, &model.SeriesConflict{
+				StartDate: occurrence.StartDate,
+				EndDate:   occurrence.EndDate,
+				Reason:    domainErr.Message,
+				Code:      domainErr.Code,
+			})
+			continue
+		}
+		if _, err = tx.Exec(`SAVEPOINT occurrence`); err != nil {
+			return nil, nil, err
+		}
+		err = tx.QueryRow(
+			`INSERT INTO offerings(user_id, workspace_id, start_time, end_time, created_by, series_id)
+					VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+			series.UserID,
+			series.WorkspaceID,
+			occurrence.StartDate,
+			occurrence.EndDate,
+			series.CreatedBy,
+			series.ID,
+		).Scan(&occurrence.ID)
+		if err != nil {
+			domainErr := db.AsError(translateError(err))
+			if domainErr == nil {
+				return nil, nil, err
+			}
+			if _, err = tx.Exec(`ROLLBACK TO SAVEPOINT occurrence`); err != nil {
+				return nil, nil, err
+			}
+			conflicts = append(conflicts, &model.SeriesConflict{
+				StartDate: occurrence.StartDate,
+				EndDate:   occurrence.EndDate,
+				Reason:    domainErr.Message,
+				Code:      domainErr.Code,
+			})
+			continue
+		}
+		occurrence.UserID = series.UserID
+		occurrence.WorkspaceID = series.WorkspaceID
+		occurrence.CreatedBy = series.CreatedBy
+		occurrence.SeriesID = series.ID
+		offerings = append(offerings, occurrence)
+	}
+	if len(offerings) == 0 {
+		err := db.NewConflictError("no occurrence of the series can be offered")
+		err.Details = conflicts
+		return nil, conflicts, err
+	}
+	err = queueNotification(tx, model.OfferingSeriesNotification(model.NotificationConfirmation, series.ID, offerings), series.WorkspaceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return offerings, conflicts, tx.Commit()
+}
+
+func (p PostgresDBStore) GetOfferingSeries(id string) (*model.OfferingSeries, error) {
+	sqlStatement := `SELECT id, user_id, workspace_id, start_time, end_time, recurrence, cancelled, created_by
+					 FROM offering_series WHERE id=$1;`
+	var series model.OfferingSeries
+	err := p.database.QueryRow(sqlStatement, id).Scan(
+		&series.ID,
+		&series.UserID,
+		&series.WorkspaceID,
+		&series.StartDate,
+		&series.EndDate,
+		&series.Recurrence,
+		&series.Cancelled,
+		&series.CreatedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+func (p PostgresDBStore) GetOfferingsBySeriesID(id string) ([]*model.Offering, error) {
+	sqlStatement :=
+		`SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by, COALESCE(series_id::text, '') FROM offerings
+				WHERE series_id=$1 ORDER BY start_time;`
+	return p.queryMultipleOfferings(sqlStatement, id)
+}
+
+// CancelOfferingSeries withdraws the occurrences of a series starting at or after from. As with RemoveOffering,
+// occurrences that already have bookings inside them stay offered and are returned as skipped. A zero from
+// covers every occurrence, and the series itself is marked cancelled once none of them are left
+func (p PostgresDBStore) CancelOfferingSeries(id string, from time.Time) ([]*model.Offering, []*model.SeriesConflict, error) {
+	tx, err := p.database.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var cancelled bool
+	err = tx.QueryRow(`SELECT cancelled FROM offering_series WHERE id=$1 FOR UPDATE`, id).Scan(&cancelled)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cancelled {
+		return nil, nil, db.NewConflictError("series is already cancelled")
+	}
+
+	rows, err := tx.Query(
+		`SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by, series_id::text
+				FROM offerings
+				WHERE series_id = $1 AND cancelled = FALSE AND start_time >= $2
+				ORDER BY start_time
+				FOR UPDATE`,
+		id, from,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	occurrences := make([]*model.Offering, 0)
+	for rows.Next() {
+		var offering model.Offering
+		err := rows.Scan(
+			&offering.ID,
+			&offering.UserID,
+			&offering.WorkspaceID,
+			&offering.StartDate,
+			&offering.EndDate,
+			&offering.Cancelled,
+			&offering.CreatedBy,
+			&offering.SeriesID,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+		occurrences = append(occurrences, &offering)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	withdrawn := make([]*model.Offering, 0)
+	skipped := make([]*model.SeriesConflict, 0)
+	for _, offering := range occurrences {
+		if err = checkOfferingWithdrawable(tx, offering.WorkspaceID, offering.StartDate, offering.EndDate); err != nil {
+			domainErr := db.AsError(err)
+			if domainErr == nil {
+				return nil, nil, err
+			}
+			skipped = append(skipped, &model.SeriesConflict{
+				StartDate: offering.StartDate,
+				EndDate:   offering.EndDate,
+				Reason:    domainErr.Message,
+				Code:      domainErr.Code,
+			})
+			continue
+		}
+		if _, err = tx.Exec(`UPDATE offerings SET cancelled = true WHERE id = $1`, offering.ID); err != nil {
+			return nil, nil, err
+		}
+		offering.Cancelled = true
+		withdrawn = append(withdrawn, offering)
+	}
+	if from.IsZero() && len(skipped) == 0 {
+		if _, err = tx.Exec(`UPDATE offering_series SET cancelled = true WHERE id = $1`, id); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(withdrawn) > 0 {
+		err = queueNotification(tx, model.OfferingSeriesNotification(model.NotificationCancellation, id, withdrawn), withdrawn[0].WorkspaceID)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return withdrawn, skipped, tx.Commit()
+}
@@ -1,7 +1,8 @@
 package postgres
 
 import (
-	"errors"
+	"database/sql"
+	"go-api/db"
 	"go-api/model"
 	"go-api/utils"
 	"log"
@@ -9,7 +10,7 @@ import (
 )
 
 func (p PostgresDBStore) GetOneOffering(id string) (*model.Offering, error) {
-	sqlStatement := `SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by FROM offerings WHERE id=$1;`
+	sqlStatement := `SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by, COALESCE(series_id::text, '') FROM offerings WHERE id=$1;`
 	var offering model.Offering
 	row := p.database.QueryRow(sqlStatement, id)
 	err := row.Scan(
@@ -20,6 +21,7 @@ func (p PostgresDBStore) GetOneOffering(id string) (*model.Offering, error) {
 		&offering.EndDate,
 		&offering.Cancelled,
 		&offering.CreatedBy,
+		&offering.SeriesID,
 	)
 	if err != nil {
 		return nil, err
@@ -28,7 +30,7 @@ func (p PostgresDBStore) GetOneOffering(id string) (*model.Offering, error) {
 }
 
 func (p PostgresDBStore) GetOneExpandedOffering(id string) (*model.ExpandedOffering, error) {
-	sqlStatement := `SELECT o.id, u.id, w.id, o.start_time, o.end_time, o.cancelled, o.created_by, w.name, u.name, f.id, f.name
+	sqlStatement := `SELECT o.id, u.id, w.id, o.start_time, o.end_time, o.cancelled, o.created_by, COALESCE(o.series_id::text, ''), w.name, u.name, f.id, f.name
 					 FROM offerings AS o
          			 INNER JOIN users AS u ON o.user_id = u.id
          			 INNER JOIN workspaces AS w ON o.workspace_id = w.id
@@ -44,6 +46,7 @@ func (p PostgresDBStore) GetOneExpandedOffering(id string) (*model.ExpandedOffer
 		&eOffering.EndDate,
 		&eOffering.Cancelled,
 		&eOffering.CreatedBy,
+		&eOffering.SeriesID,
 		&eOffering.WorkspaceName,
 		&eOffering.UserName,
 		&eOffering.FloorID,
@@ -56,12 +59,12 @@ func (p PostgresDBStore) GetOneExpandedOffering(id string) (*model.ExpandedOffer
 }
 
 func (p PostgresDBStore) GetAllOfferings() ([]*model.Offering, error) {
-	sqlStatement := `SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by FROM offerings;`
+	sqlStatement := `SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by, COALESCE(series_id::text, '') FROM offerings;`
 	return p.queryMultipleOfferings(sqlStatement)
 }
 
 func (p PostgresDBStore) GetAllExpandedOfferings() ([]*model.ExpandedOffering, error) {
-	sqlStatement := `SELECT o.id, u.id, w.id, o.start_time, o.end_time, o.cancelled, o.created_by, w.name, u.name, f.id, f.name
+	sqlStatement := `SELECT o.id, u.id, w.id, o.start_time, o.end_time, o.cancelled, o.created_by, COALESCE(o.series_id::text, ''), w.name, u.name, f.id, f.name
 					 FROM offerings AS o
          			 INNER JOIN users AS u ON o.user_id = u.id
          			 INNER JOIN workspaces AS w ON o.workspace_id = w.id
@@ -72,12 +75,12 @@ func (p PostgresDBStore) GetAllExpandedOfferings() ([]*model.ExpandedOffering, e
 
 func (p PostgresDBStore) GetOfferingsByWorkspaceID(id string) ([]*model.Offering, error) {
 	sqlStatement :=
-		`SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by FROM offerings WHERE workspace_id=$1;`
+		`SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by, COALESCE(series_id::text, '') FROM offerings WHERE workspace_id=$1;`
 	return p.queryMultipleOfferings(sqlStatement, id)
 }
 
 func (p PostgresDBStore) GetExpandedOfferingsByWorkspaceID(id string) ([]*model.ExpandedOffering, error) {
-	sqlStatement := `SELECT o.id, u.id, w.id, o.start_time, o.end_time, o.cancelled, o.created_by, w.name, u.name, f.id, f.name
+	sqlStatement := `SELECT o.id, u.id, w.id, o.start_time, o.end_time, o.cancelled, o.created_by, COALESCE(o.series_id::text, ''), w.name, u.name, f.id, f.name
 					 FROM offerings AS o
          			 INNER JOIN users AS u ON o.user_id = u.id
          			 INNER JOIN workspaces AS w ON o.workspace_id = w.id
@@ -89,12 +92,12 @@ func (p PostgresDBStore) GetExpandedOfferingsByWorkspaceID(id string) ([]*model.
 
 func (p PostgresDBStore) GetOfferingsByUserID(id string) ([]*model.Offering, error) {
 	sqlStatement :=
-		`SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by FROM offerings WHERE user_id=$1;`
+		`SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by, COALESCE(series_id::text, '') FROM offerings WHERE user_id=$1;`
 	return p.queryMultipleOfferings(sqlStatement, id)
 }
 
 func (p PostgresDBStore) GetExpandedOfferingsByUserID(id string) ([]*model.ExpandedOffering, error) {
-	sqlStatement := `SELECT o.id, u.id, w.id, o.start_time, o.end_time, o.cancelled, o.created_by, w.name, u.name, f.id, f.name
+	sqlStatement := `SELECT o.id, u.id, w.id, o.start_time, o.end_time, o.cancelled, o.created_by, COALESCE(o.series_id::text, ''), w.name, u.name, f.id, f.name
 					 FROM offerings AS o
          			 INNER JOIN users AS u ON o.user_id = u.id
          			 INNER JOIN workspaces AS w ON o.workspace_id = w.id
@@ -106,13 +109,13 @@ func (p PostgresDBStore) GetExpandedOfferingsByUserID(id string) ([]*model.Expan
 
 func (p PostgresDBStore) GetOfferingsByDateRange(start time.Time, end time.Time) ([]*model.Offering, error) {
 	sqlStatement :=
-		`SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by FROM offerings 
+		`SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by, COALESCE(series_id::text, '') FROM offerings 
 				WHERE start_time <= $1 AND end_time >= $2;`
 	return p.queryMultipleOfferings(sqlStatement, start, end)
 }
 
 func (p PostgresDBStore) GetExpandedOfferingsByDateRange(start time.Time, end time.Time) ([]*model.ExpandedOffering, error) {
-	sqlStatement := `SELECT o.id, u.id, w.id, o.start_time, o.end_time, o.cancelled, o.created_by, w.name, u.name, f.id, f.name
+	sqlStatement := `SELECT o.id, u.id, w.id, o.start_time, o.end_time, o.cancelled, o.created_by, COALESCE(o.series_id::text, ''), w.name, u.name, f.id, f.name
 					 FROM offerings AS o
          			 INNER JOIN users AS u ON o.user_id = u.id
          			 INNER JOIN workspaces AS w ON o.workspace_id = w.id
@@ -124,7 +127,7 @@ func (p PostgresDBStore) GetExpandedOfferingsByDateRange(start time.Time, end ti
 
 func (p PostgresDBStore) GetOfferingsByWorkspaceIDAndDateRange(id string, start time.Time, end time.Time) (*model.Offering, error) {
 	sqlStatement :=
-		`SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by
+		`SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by, COALESCE(series_id::text, '')
 		 FROM offerings
 		 WHERE workspace_id=$1 AND start_time <= $2 AND end_time >= $3;`
 	var offering model.Offering
@@ -137,6 +140,7 @@ func (p PostgresDBStore) GetOfferingsByWorkspaceIDAndDateRange(id string, start
 		&offering.EndDate,
 		&offering.Cancelled,
 		&offering.CreatedBy,
+		&offering.SeriesID,
 	)
 	if err != nil {
 		return nil, err
@@ -151,16 +155,50 @@ func (p PostgresDBStore) CreateOffering(offering *model.Offering) (string, error
 	}
 	defer tx.Rollback()
 
+	if err = checkOfferingAvailable(tx, offering); err != nil {
+		return "", err
+	}
+
+	sqlStatement :=
+		`INSERT INTO offerings(user_id, workspace_id, start_time, end_time, created_by) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	var id string
+	err = tx.QueryRow(sqlStatement,
+		offering.UserID,
+		offering.WorkspaceID,
+		offering.StartDate,
+		offering.EndDate,
+		offering.CreatedBy,
+	).Scan(&id)
+	if err != nil {
+		return "", translateError(err)
+	}
+	err = queueNotification(tx, &model.Notification{
+		Kind:      model.NotificationConfirmation,
+		Subject:   model.NotificationOffering,
+		SubjectID: id,
+		UserID:    offering.UserID,
+		StartDate: offering.StartDate,
+		EndDate:   offering.EndDate,
+	}, offering.WorkspaceID)
+	if err != nil {
+		return "", err
+	}
+	return id, tx.Commit()
+}
+
+// checkOfferingAvailable makes sure the workspace is assigned for the whole offering and that no other
+// offering overlaps it
+func checkOfferingAvailable(tx *sql.Tx, offering *model.Offering) error {
 	// Check if its currently assigned
 	var count int
-	err = tx.QueryRow(
+	err := tx.QueryRow(
 		`SELECT count(*) FROM workspace_assignee 
 					WHERE workspace_id=$1 AND 
                     	   (start_time <= $2 AND (end_time >= $3 OR end_time IS NULL))`,
 		offering.WorkspaceID, offering.StartDate, offering.EndDate,
 	).Scan(&count)
 	if err != nil || count == 0 {
-		return "", errors.New("invalid operation: unassigned workspace cannot be offered")
+		return db.NewValidationError("unassigned workspace cannot be offered")
 	}
 
 	// Check for conflicts
@@ -174,23 +212,9 @@ func (p PostgresDBStore) CreateOffering(offering *model.Offering) (string, error
 		offering.WorkspaceID, offering.StartDate, offering.EndDate,
 	).Scan(&count)
 	if err != nil || count > 0 {
-		return "", errors.New("invalid operation: workspace already offered for this duration")
+		return db.NewConflictError("workspace already offered for this duration")
 	}
-
-	sqlStatement :=
-		`INSERT INTO offerings(user_id, workspace_id, start_time, end_time, created_by) VALUES ($1, $2, $3, $4, $5) RETURNING id`
-	var id string
-	err = tx.QueryRow(sqlStatement,
-		offering.UserID,
-		offering.WorkspaceID,
-		offering.StartDate,
-		offering.EndDate,
-		offering.CreatedBy,
-	).Scan(&id)
-	if err != nil {
-		return "", err
-	}
-	return id, tx.Commit()
+	return nil
 }
 
 func (p PostgresDBStore) CreateDefaultOffering(offering *model.Offering) (string, error) {
@@ -207,7 +231,7 @@ func (p PostgresDBStore) CreateDefaultOffering(offering *model.Offering) (string
 		offering.WorkspaceID, offering.StartDate,
 	).Scan(&count)
 	if count > 0 {
-		return "", errors.New("invalid operation: workspace has outstanding bookings")
+		return "", db.NewConflictError("workspace has outstanding bookings")
 	}
 
 	// End the assignments
@@ -238,7 +262,7 @@ func (p PostgresDBStore) CreateDefaultOffering(offering *model.Offering) (string
 	err = tx.QueryRow(sqlStatement, utils.EmptyUserUUID, offering.WorkspaceID, offering.StartDate, nil, utils.EmptyUserUUID).Scan(&id)
 	if err != nil {
 		log.Printf("PostgresDBStore.CreateDefaultOffering: error creating new default offerings: %v\n", err)
-		return "", err
+		return "", translateError(err)
 	}
 	return id, tx.Commit()
 }
@@ -260,10 +284,10 @@ func (p PostgresDBStore) UpdateOffering(id string, offering *model.Offering) err
 		offering.CreatedBy,
 	).Scan(&_id)
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 	if _id != id {
-		return CreateError
+		return db.NotFoundError
 	}
 
 	return nil
@@ -284,17 +308,11 @@ func (p PostgresDBStore) RemoveOffering(id string) error {
 		return err
 	}
 	if userId == utils.EmptyUserUUID { // check if offering is for a unassigned workspace
-		return errors.New("invalid operation: cannot remove offerings by default user")
+		return db.NewForbiddenError("cannot remove offerings by default user")
 	}
 
-	// Check for any bookings in this offering period
-	var count int
-	err = tx.QueryRow(
-		`SELECT count(*) FROM bookings WHERE workspace_id=$1 AND cancelled=FALSE AND (start_time >= $2 AND end_time <= $3)`,
-		workspaceId, start, end,
-	).Scan(&count)
-	if count > 0 {
-		return errors.New("invalid operation: conflicting bookings for this offering period; cannot delete")
+	if err = checkOfferingWithdrawable(tx, workspaceId, start, end); err != nil {
+		return err
 	}
 	sqlStatement :=
 		`UPDATE offerings
@@ -309,11 +327,35 @@ func (p PostgresDBStore) RemoveOffering(id string) error {
 		return err
 	}
 	if _id != id {
-		return CreateError
+		return db.NotFoundError
+	}
+	err = queueNotification(tx, &model.Notification{
+		Kind:      model.NotificationCancellation,
+		Subject:   model.NotificationOffering,
+		SubjectID: id,
+		UserID:    userId,
+		StartDate: start,
+		EndDate:   end,
+	}, workspaceId)
+	if err != nil {
+		return err
 	}
 	return tx.Commit()
 }
 
+// checkOfferingWithdrawable makes sure no booking was made inside the offering period
+func checkOfferingWithdrawable(tx *sql.Tx, workspaceId string, start, end time.Time) error {
+	var count int
+	err := tx.QueryRow(
+		`SELECT count(*) FROM bookings WHERE workspace_id=$1 AND cancelled=FALSE AND (start_time >= $2 AND end_time <= $3)`,
+		workspaceId, start, end,
+	).Scan(&count)
+	if err != nil || count > 0 {
+		return db.NewConflictError("conflicting bookings for this offering period; cannot delete")
+	}
+	return nil
+}
+
 func (p PostgresDBStore) queryMultipleOfferings(sqlStatement string, args ...interface{}) ([]*model.Offering, error) {
 	rows, err := p.database.Query(sqlStatement, args...)
 	if err != nil {
@@ -331,6 +373,7 @@ func (p PostgresDBStore) queryMultipleOfferings(sqlStatement string, args ...int
 			&offering.EndDate,
 			&offering.Cancelled,
 			&offering.CreatedBy,
+			&offering.SeriesID,
 		)
 		if err != nil {
 			// dont cause panic here, log it
@@ -349,7 +392,7 @@ func (p PostgresDBStore) queryMultipleOfferings(sqlStatement string, args ...int
 
 func (p PostgresDBStore) GetExpiredOfferings(since time.Time) ([]*model.Offering, error) {
 	sqlStatement :=
-		`SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by FROM bookings 
+		`SELECT id, user_id, workspace_id, start_time, end_time, cancelled, created_by, COALESCE(series_id::text, '') FROM offerings
 				WHERE end_time < $1`
 	return p.queryMultipleOfferings(sqlStatement, since)
 }
@@ -371,6 +414,7 @@ func (p PostgresDBStore) queryMultipleExpandedOfferings(sqlStatement string, arg
 			&eOffering.EndDate,
 			&eOffering.Cancelled,
 			&eOffering.CreatedBy,
+			&eOffering.SeriesID,
 			&eOffering.WorkspaceName,
 			&eOffering.UserName,
 			&eOffering.FloorID,
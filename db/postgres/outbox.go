@@ -0,0 +1,179 @@
+package postgres
+
+import (
+	"database/sql"
+	"go-api/db"
+	"go-api/model"
+	"go-api/utils"
+	"time"
+)
+
+const outboxColumns = `id, notification, status, attempts, next_attempt_at, last_error, created_at, delivered_at`
+
+func scanOutboxMessage(row scanner) (*model.OutboxMessage, error) {
+	var message model.OutboxMessage
+	var deliveredAt sql.NullTime
+	err := row.Scan(
+		&message.ID,
+		&message.Notification,
+		&message.Status,
+		&message.Attempts,
+		&message.NextAttemptAt,
+		&message.LastError,
+		&message.CreatedAt,
+		&deliveredAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if deliveredAt.Valid {
+		message.DeliveredAt = &deliveredAt.Time
+	}
+	return &message, nil
+}
+
+func queryOutboxMessages(rows *sql.Rows, err error) ([]*model.OutboxMessage, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	messages := make([]*model.OutboxMessage, 0)
+	for rows.Next() {
+		message, err := scanOutboxMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
+
+// queueNotification adds n to the outbox as part of tx, filling in the user's and workspace's details. Nothing is
+// queued for the default user, who has no mailbox, or for a user that doesn't exist
+func queueNotification(tx *sql.Tx, n *model.Notification, workspaceId string) error {
+	if n.UserID == utils.EmptyUserUUID {
+		return nil
+	}
+	err := tx.QueryRow(
+		`SELECT u.name, COALESCE(u.email, ''), u.locale, u.time_zone, w.name, f.id, f.name, f.address
+				FROM users u, workspaces w JOIN floors f ON f.id = w.floor_id
+				WHERE u.id = $1 AND w.id = $2`,
+		n.UserID, workspaceId,
+	).Scan(&n.Name, &n.Email, &n.Locale, &n.TimeZone, &n.WorkspaceName, &n.FloorID, &n.FloorName, &n.FloorAddress)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO outbox(notification) VALUES ($1)`, n)
+	return err
+}
+
+// queueBookingNotifications queues n, a notification about a booking for the user it is for, then a copy of it for
+// the user who made the booking for them and for the owner of the offering the booking is in, as part of tx
+func queueBookingNotifications(tx *sql.Tx, n *model.Notification, workspaceId, createdBy string) error {
+	if err := queueNotification(tx, n, workspaceId); err != nil {
+		return err
+	}
+	var owner string
+	err := tx.QueryRow(
+		`SELECT user_id FROM offerings
+				WHERE workspace_id = $1 AND cancelled = FALSE AND start_time <= $2 AND (end_time >= $3 OR end_time IS NULL)
+				LIMIT 1`,
+		workspaceId, n.StartDate, n.EndDate,
+	).Scan(&owner)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	for _, party := range model.InterestedParties(n.UserID, createdBy, owner) {
+		copied := *n
+		copied.UserID, copied.Role, copied.BookerName = party.UserID, party.Role, n.Name
+		if err = queueNotification(tx, &copied, workspaceId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p PostgresDBStore) ClaimOutboxMessages(now time.Time, limit int, lease time.Duration) ([]*model.OutboxMessage, error) {
+	// SKIP LOCKED lets instances claiming at the same time take different messages
+	return queryOutboxMessages(p.database.Query(
+		`UPDATE outbox SET next_attempt_at = $2
+				WHERE id IN (SELECT id FROM outbox WHERE status = 'pending' AND next_attempt_at <= $1
+				             ORDER BY next_attempt_at LIMIT $3 FOR UPDATE SKIP LOCKED)
+				RETURNING `+outboxColumns,
+		now, now.Add(lease), limit,
+	))
+}
+
+func (p PostgresDBStore) MarkOutboxDelivered(id string, at time.Time) error {
+	result, err := p.database.Exec(
+		`UPDATE outbox SET status = 'delivered', attempts = attempts + 1, delivered_at = $2
+				WHERE id = $1 AND status = 'pending'`,
+		id, at,
+	)
+	if err != nil {
+		return err
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		return db.NotFoundError
+	}
+	return nil
+}
+
+func (p PostgresDBStore) MarkOutboxFailed(id string, reason string, next *time.Time) error {
+	var nextAttempt interface{}
+	if next != nil {
+		nextAttempt = *next
+	}
+	result, err := p.database.Exec(
+		`UPDATE outbox SET attempts = attempts + 1, last_error = $2,
+				       status = CASE WHEN $3::timestamptz IS NULL THEN 'dead' ELSE 'pending' END,
+				       next_attempt_at = COALESCE($3::timestamptz, next_attempt_at)
+				WHERE id = $1 AND status = 'pending'`,
+		id, reason, nextAttempt,
+	)
+	if err != nil {
+		return err
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		return db.NotFoundError
+	}
+	return nil
+}
+
+func (p PostgresDBStore) GetOutboxMessages(status string) ([]*model.OutboxMessage, error) {
+	return queryOutboxMessages(p.database.Query(
+		`SELECT `+outboxColumns+` FROM outbox WHERE $1 = '' OR status = $1 ORDER BY created_at DESC`,
+		status,
+	))
+}
+
+func (p PostgresDBStore) GetOneOutboxMessage(id string) (*model.OutboxMessage, error) {
+	return scanOutboxMessage(p.database.QueryRow(`SELECT `+outboxColumns+` FROM outbox WHERE id = $1`, id))
+}
+
+func (p PostgresDBStore) ReplayOutboxMessage(id string, now time.Time) (*model.OutboxMessage, error) {
+	tx, err := p.database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	var status string
+	err = tx.QueryRow(`SELECT status FROM outbox WHERE id = $1 FOR UPDATE`, id).Scan(&status)
+	if err != nil {
+		return nil, err
+	}
+	if status != model.OutboxDead {
+		return nil, db.NewConflictError("only dead messages can be replayed, this one is %s", status)
+	}
+	message, err := scanOutboxMessage(tx.QueryRow(
+		`UPDATE outbox SET status = 'pending', attempts = 0, next_attempt_at = $2 WHERE id = $1 RETURNING `+outboxColumns,
+		id, now,
+	))
+	if err != nil {
+		return nil, err
+	}
+	return message, tx.Commit()
+}
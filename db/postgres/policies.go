@@ -0,0 +1,202 @@
+package postgres
+
+import (
+	"database/sql"
+	"github.com/lib/pq"
+	"go-api/db"
+	"go-api/model"
+	"log"
+	"time"
+)
+
+const bookingPolicyQuery = `SELECT max_advance_days, max_duration_hours, max_future_bookings, max_department_daily FROM booking_policy`
+
+func (p PostgresDBStore) GetBookingPolicy() (*model.BookingPolicy, error) {
+	return scanBookingPolicy(p.database.QueryRow(bookingPolicyQuery))
+}
+
+func scanBookingPolicy(row *sql.Row) (*model.BookingPolicy, error) {
+	var policy model.BookingPolicy
+	err := row.Scan(
+		&policy.MaxAdvanceDays,
+		&policy.MaxDurationHours,
+		&policy.MaxFutureBookings,
+		&policy.MaxDepartmentDaily,
+	)
+	if err != nil && err != sql.ErrNoRows { // no row means nothing is enforced
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (p PostgresDBStore) UpdateBookingPolicy(policy *model.BookingPolicy) error {
+	sqlStatement :=
+		`INSERT INTO booking_policy(id, max_advance_days, max_duration_hours, max_future_bookings, max_department_daily)
+				VALUES (TRUE, $1, $2, $3, $4)
+				ON CONFLICT (id) DO UPDATE
+				SET max_advance_days = $1, max_duration_hours = $2, max_future_bookings = $3, max_department_daily = $4;`
+	_, err := p.database.Exec(sqlStatement,
+		policy.MaxAdvanceDays,
+		policy.MaxDurationHours,
+		policy.MaxFutureBookings,
+		policy.MaxDepartmentDaily,
+	)
+	return err
+}
+
+func (p *PostgresDBStore) SetDefaultZone(zone *time.Location) {
+	p.defaultZone = zone
+}
+
+func (p PostgresDBStore) GetFloorBlackouts(floorId string) ([]*model.FloorBlackout, error) {
+	sqlStatement :=
+		`SELECT id, floor_id, start_time, end_time, reason FROM floor_blackouts WHERE floor_id=$1 ORDER BY start_time;`
+	rows, err := p.database.Query(sqlStatement, floorId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	blackouts := make([]*model.FloorBlackout, 0)
+	for rows.Next() {
+		var blackout model.FloorBlackout
+		err := rows.Scan(
+			&blackout.ID,
+			&blackout.FloorID,
+			&blackout.StartDate,
+			&blackout.EndDate,
+			&blackout.Reason,
+		)
+		if err != nil {
+			// dont cause panic here, log it
+			log.Printf("PostgresDBStore.GetFloorBlackouts: %v, sqlStatement: %s\n", err, sqlStatement)
+			continue
+		}
+		blackouts = append(blackouts, &blackout)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return blackouts, nil
+}
+
+func (p PostgresDBStore) CreateFloorBlackout(blackout *model.FloorBlackout) (string, error) {
+	sqlStatement :=
+		`INSERT INTO floor_blackouts(floor_id, start_time, end_time, reason) VALUES ($1, $2, $3, $4) RETURNING id`
+	var id string
+	err := p.database.QueryRow(sqlStatement,
+		blackout.FloorID,
+		blackout.StartDate,
+		blackout.EndDate,
+		blackout.Reason,
+	).Scan(&id)
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (p PostgresDBStore) RemoveFloorBlackout(id string) error {
+	res, err := p.database.Exec(`DELETE FROM floor_blackouts WHERE id=$1`, id)
+	if err != nil {
+		return err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return db.NotFoundError
+	}
+	return nil
+}
+
+// checkBookingPolicy makes sure the booking follows the booking policy and doesn't fall in a blackout of
+// the workspace's floor. Like checkBookingAvailable, booking.ID is left out when counting other bookings
+func (p PostgresDBStore) checkBookingPolicy(tx *sql.Tx, booking *model.Booking) error {
+	var reason string
+	err := tx.QueryRow(
+		`SELECT fb.reason FROM floor_blackouts AS fb
+				INNER JOIN workspaces AS w ON w.floor_id = fb.floor_id
+				WHERE w.id=$1 AND fb.start_time < $3 AND fb.end_time > $2
+				ORDER BY fb.start_time LIMIT 1`,
+		booking.WorkspaceID, booking.StartDate, booking.EndDate,
+	).Scan(&reason)
+	if err == nil {
+		if reason != "" {
+			return db.NewPolicyError(db.PolicyFloorBlackout, "the floor is closed for booking during this time (%s)", reason)
+		}
+		return db.NewPolicyError(db.PolicyFloorBlackout, "the floor is closed for booking during this time")
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	policy, err := scanBookingPolicy(tx.QueryRow(bookingPolicyQuery))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if policy.MaxAdvanceDays > 0 && booking.StartDate.After(now.AddDate(0, 0, policy.MaxAdvanceDays)) {
+		return db.NewPolicyError(db.PolicyAdvanceWindow, "bookings can be made at most %d days in advance", policy.MaxAdvanceDays)
+	}
+	if policy.MaxDurationHours > 0 && booking.EndDate.Sub(booking.StartDate) > time.Duration(policy.MaxDurationHours)*time.Hour {
+		return db.NewPolicyError(db.PolicyMaxDuration, "bookings can be at most %d hours long", policy.MaxDurationHours)
+	}
+	if policy.MaxFutureBookings <= 0 && policy.MaxDepartmentDaily <= 0 {
+		return nil
+	}
+	// Lock the user so that two of their bookings can't both be counted below the caps at once
+	var department, timeZone string
+	err = tx.QueryRow(`SELECT department, time_zone FROM users WHERE id=$1 FOR UPDATE`, booking.UserID).Scan(&department, &timeZone)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if policy.MaxFutureBookings > 0 && booking.EndDate.After(now) {
+		var count int
+		err = tx.QueryRow(
+			`SELECT count(*) FROM bookings
+					WHERE user_id=$1 AND cancelled=FALSE AND end_time > $2 AND id::text <> $3`,
+			booking.UserID, now, booking.ID,
+		).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count >= policy.MaxFutureBookings {
+			return db.NewPolicyError(db.PolicyFutureBookings, "users can have at most %d upcoming bookings", policy.MaxFutureBookings)
+		}
+	}
+	if policy.MaxDepartmentDaily > 0 {
+		// The department's members book concurrently, so they take a lock of the whole department
+		if _, err = tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, department); err != nil {
+			return err
+		}
+		// Find the first day of the booking on which the user's department is already at the cap
+		days := db.UserDays(timeZone, p.defaultZone, booking.StartDate, booking.EndDate)
+		starts := make([]string, len(days))
+		ends := make([]string, len(days))
+		for i, day := range days {
+			starts[i], ends[i] = day.Format(time.RFC3339), day.AddDate(0, 0, 1).Format(time.RFC3339)
+		}
+		var i int
+		err = tx.QueryRow(
+			`SELECT d.i FROM unnest($1::timestamptz[], $2::timestamptz[]) WITH ORDINALITY AS d(day_start, day_end, i)
+					WHERE (SELECT count(*) FROM bookings AS b
+					       INNER JOIN users AS u ON b.user_id = u.id
+					       WHERE u.department = $3 AND b.cancelled=FALSE AND b.id::text <> $4 AND
+					             b.start_time < d.day_end AND b.end_time > d.day_start) >= $5
+					ORDER BY d.i LIMIT 1`,
+			pq.Array(starts), pq.Array(ends), department, booking.ID, policy.MaxDepartmentDaily,
+		).Scan(&i)
+		if err == nil {
+			return db.NewPolicyError(db.PolicyDepartmentDaily, "the department already has %d bookings on %s", policy.MaxDepartmentDaily, days[i-1].Format("2006-01-02"))
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"database/sql"
+	"go-api/model"
+	"go-api/utils"
+	"time"
+)
+
+func (p PostgresDBStore) QueueReminder(key string, n *model.Notification) (bool, error) {
+	if n.UserID == utils.EmptyUserUUID {
+		return false, nil
+	}
+	tx, err := p.database.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	var remindersOptOut, digestOptOut bool
+	err = tx.QueryRow(
+		`SELECT name, COALESCE(email, ''), locale, time_zone, reminders_opt_out, digest_opt_out
+				FROM users WHERE id = $1 AND deleted = FALSE`,
+		n.UserID,
+	).Scan(&n.Name, &n.Email, &n.Locale, &n.TimeZone, &remindersOptOut, &digestOptOut)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if (n.Kind == model.NotificationDigest && digestOptOut) || (n.Kind != model.NotificationDigest && remindersOptOut) {
+		return false, nil
+	}
+	result, err := tx.Exec(`INSERT INTO reminders(key) VALUES ($1) ON CONFLICT DO NOTHING`, key)
+	if err != nil {
+		return false, err
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		return false, err
+	}
+	if _, err = tx.Exec(`INSERT INTO outbox(notification) VALUES ($1)`, n); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (p PostgresDBStore) DeleteRemindersBefore(t time.Time) error {
+	_, err := p.database.Exec(`DELETE FROM reminders WHERE queued_at < $1`, t)
+	return err
+}
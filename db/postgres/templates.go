@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"database/sql"
+	"go-api/db"
+	"go-api/model"
+)
+
+const emailTemplateColumns = `event, locale, subject, text, html, updated_at`
+
+func scanEmailTemplate(row scanner) (*model.EmailTemplate, error) {
+	var template model.EmailTemplate
+	err := row.Scan(
+		&template.Event,
+		&template.Locale,
+		&template.Subject,
+		&template.Text,
+		&template.HTML,
+		&template.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (p PostgresDBStore) GetEmailTemplates() ([]*model.EmailTemplate, error) {
+	rows, err := p.database.Query(`SELECT ` + emailTemplateColumns + ` FROM email_templates ORDER BY event, locale`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	templates := make([]*model.EmailTemplate, 0)
+	for rows.Next() {
+		template, err := scanEmailTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+	return templates, rows.Err()
+}
+
+func (p PostgresDBStore) GetEmailTemplate(event, locale string) (*model.EmailTemplate, error) {
+	return scanEmailTemplate(p.database.QueryRow(
+		`SELECT `+emailTemplateColumns+` FROM email_templates WHERE event = $1 AND locale = $2`,
+		event, locale,
+	))
+}
+
+func (p PostgresDBStore) UpsertEmailTemplate(template *model.EmailTemplate) error {
+	return p.database.QueryRow(
+		`INSERT INTO email_templates(event, locale, subject, text, html) VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT (event, locale) DO UPDATE SET subject = $3, text = $4, html = $5, updated_at = now()
+				RETURNING updated_at`,
+		template.Event, template.Locale, template.Subject, template.Text, template.HTML,
+	).Scan(&template.UpdatedAt)
+}
+
+func (p PostgresDBStore) RemoveEmailTemplate(event, locale string) error {
+	var removed string
+	err := p.database.QueryRow(
+		`DELETE FROM email_templates WHERE event = $1 AND locale = $2 RETURNING event`,
+		event, locale,
+	).Scan(&removed)
+	if err == sql.ErrNoRows {
+		return db.NotFoundError
+	}
+	return err
+}
@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"go-api/db"
 	"go-api/model"
 	"go-api/utils"
 	"log"
@@ -8,7 +9,7 @@ import (
 )
 
 func (p PostgresDBStore) GetOneUser(id string) (*model.User, error) {
-	sqlStatement := `SELECT id, name, email, department, is_admin FROM users WHERE id=$1;`
+	sqlStatement := `SELECT id, name, email, department, is_admin, locale, time_zone, reminders_opt_out, digest_opt_out FROM users WHERE id=$1;`
 	var user model.User
 	row := p.database.QueryRow(sqlStatement, id)
 	err := row.Scan(
@@ -17,6 +18,10 @@ func (p PostgresDBStore) GetOneUser(id string) (*model.User, error) {
 		&user.Email,
 		&user.Department,
 		&user.IsAdmin,
+		&user.Locale,
+		&user.TimeZone,
+		&user.RemindersOptOut,
+		&user.DigestOptOut,
 	)
 	if err != nil {
 		return nil, err
@@ -25,13 +30,14 @@ func (p PostgresDBStore) GetOneUser(id string) (*model.User, error) {
 }
 
 func (p PostgresDBStore) GetAllUsers() ([]*model.User, error) {
-	sqlStatement := `SELECT id, name, email, department, is_admin FROM users WHERE deleted=FALSE;`
+	sqlStatement := `SELECT id, name, email, department, is_admin, locale, time_zone, reminders_opt_out, digest_opt_out FROM users WHERE deleted=FALSE;`
 	return p.queryMultipleUsers(sqlStatement)
 }
 
 func (p PostgresDBStore) CreateUser(user *model.User) error {
 	sqlStatement :=
-		`INSERT INTO users(id, name, email, department, is_admin) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+		`INSERT INTO users(id, name, email, department, is_admin, locale, time_zone, reminders_opt_out, digest_opt_out)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
 	var id string
 	err := p.database.QueryRow(sqlStatement,
 		user.ID,
@@ -39,12 +45,16 @@ func (p PostgresDBStore) CreateUser(user *model.User) error {
 		user.Email,
 		user.Department,
 		user.IsAdmin,
+		user.Locale,
+		user.TimeZone,
+		user.RemindersOptOut,
+		user.DigestOptOut,
 	).Scan(&id)
 	if err != nil {
 		return err
 	}
 	if id != user.ID {
-		return CreateError
+		return db.NewConflictError("user %s was stored as %s", user.ID, id)
 	}
 	return nil
 }
@@ -144,6 +154,21 @@ func (p PostgresDBStore) GetAssignedUsersByTime(timestamp time.Time) ([]*model.U
 	return assignedUsers, nil
 }
 
+func (p PostgresDBStore) UpdateUserPreferences(id string, preferences *model.UserPreferences) error {
+	result, err := p.database.Exec(
+		`UPDATE users SET locale = $2, time_zone = $3, reminders_opt_out = $4, digest_opt_out = $5
+				WHERE id = $1 AND deleted = FALSE`,
+		id, preferences.Locale, preferences.TimeZone, preferences.RemindersOptOut, preferences.DigestOptOut,
+	)
+	if err != nil {
+		return err
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		return db.NotFoundError
+	}
+	return nil
+}
+
 //func (p PostgresDBStore) UpdateUser(id string, user *model.User) error {
 //	sqlStatement :=
 //		`UPDATE users
@@ -161,7 +186,7 @@ func (p PostgresDBStore) GetAssignedUsersByTime(timestamp time.Time) ([]*model.U
 //		return err
 //	}
 //	if _id != id {
-//		return CreateError
+//		return db.NotFoundError
 //	}
 //	return nil
 //}
@@ -179,7 +204,7 @@ func (p PostgresDBStore) GetAssignedUsersByTime(timestamp time.Time) ([]*model.U
 //		return err
 //	}
 //	if _id != id {
-//		return CreateError
+//		return db.NotFoundError
 //	}
 //	return nil
 //}
@@ -199,6 +224,10 @@ func (p PostgresDBStore) queryMultipleUsers(sqlStatement string, args ...interfa
 			&user.Email,
 			&user.Department,
 			&user.IsAdmin,
+			&user.Locale,
+			&user.TimeZone,
+			&user.RemindersOptOut,
+			&user.DigestOptOut,
 		)
 		if err != nil {
 			// dont cause panic here, log it
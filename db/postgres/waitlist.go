@@ -0,0 +1,300 @@
+package postgres
+
+import (
+	"database/sql"
+	"go-api/db"
+	"go-api/model"
+	"log"
+	"time"
+)
+
+const waitlistColumns = `id, user_id, floor_id, COALESCE(properties, '{}'::json), start_time, end_time, auto_book, status,
+		COALESCE(hold_workspace_id::text, ''), hold_expires_at, COALESCE(booking_id::text, ''), created_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWaitlistEntry(row scanner) (*model.WaitlistEntry, error) {
+	var entry model.WaitlistEntry
+	var holdExpiresAt sql.NullTime
+	err := row.Scan(
+		&entry.ID,
+		&entry.UserID,
+		&entry.FloorID,
+		&entry.Props,
+		&entry.StartDate,
+		&entry.EndDate,
+		&entry.AutoBook,
+		&entry.Status,
+		&entry.HoldWorkspaceID,
+		&holdExpiresAt,
+		&entry.BookingID,
+		&entry.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if holdExpiresAt.Valid {
+		entry.HoldExpiresAt = &holdExpiresAt.Time
+	}
+	return &entry, nil
+}
+
+func (p PostgresDBStore) CreateWaitlistEntry(entry *model.WaitlistEntry) (string, error) {
+	if entry.Props == nil {
+		entry.Props = model.Attrs{}
+	}
+	sqlStatement :=
+		`INSERT INTO waitlist(user_id, floor_id, properties, start_time, end_time, auto_book) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	var id string
+	err := p.database.QueryRow(sqlStatement,
+		entry.UserID,
+		entry.FloorID,
+		entry.Props,
+		entry.StartDate,
+		entry.EndDate,
+		entry.AutoBook,
+	).Scan(&id)
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (p PostgresDBStore) GetOneWaitlistEntry(id string) (*model.WaitlistEntry, error) {
+	return scanWaitlistEntry(p.database.QueryRow(`SELECT `+waitlistColumns+` FROM waitlist WHERE id=$1`, id))
+}
+
+func (p PostgresDBStore) GetWaitlistByUserID(id string) ([]*model.WaitlistEntry, error) {
+	sqlStatement := `SELECT ` + waitlistColumns + ` FROM waitlist WHERE user_id=$1 ORDER BY created_at`
+	rows, err := p.database.Query(sqlStatement, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := make([]*model.WaitlistEntry, 0)
+	for rows.Next() {
+		entry, err := scanWaitlistEntry(rows)
+		if err != nil {
+			// dont cause panic here, log it
+			log.Printf("PostgresDBStore.GetWaitlistByUserID: %v, sqlStatement: %s\n", err, sqlStatement)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// CancelWaitlistEntry takes a waiting or held entry off the waitlist and returns it as it was before,
+// so the caller can tell whether a hold was released
+func (p PostgresDBStore) CancelWaitlistEntry(id string) (*model.WaitlistEntry, error) {
+	tx, err := p.database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	entry, err := scanWaitlistEntry(tx.QueryRow(`SELECT `+waitlistColumns+` FROM waitlist WHERE id=$1 FOR UPDATE`, id))
+	if err != nil {
+		return nil, err
+	}
+	if entry.Status != model.WaitlistWaiting && entry.Status != model.WaitlistHeld {
+		return nil, db.NewConflictError("waitlist entry is already %s", entry.Status)
+	}
+	if _, err = tx.Exec(`UPDATE waitlist SET status=$2 WHERE id=$1`, id, model.WaitlistCancelled); err != nil {
+		return nil, err
+	}
+	return entry, tx.Commit()
+}
+
+// ClaimWaitlistHold books the workspace held for the entry. An expired hold is marked as such and an
+// invalid operation error is returned
+func (p PostgresDBStore) ClaimWaitlistHold(id string) (string, error) {
+	tx, err := p.database.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	entry, err := scanWaitlistEntry(tx.QueryRow(`SELECT `+waitlistColumns+` FROM waitlist WHERE id=$1 FOR UPDATE`, id))
+	if err != nil {
+		return "", err
+	}
+	if entry.Status != model.WaitlistHeld {
+		return "", db.NewConflictError("waitlist entry has no hold to claim")
+	}
+	if entry.HoldExpiresAt == nil || !entry.HoldExpiresAt.After(time.Now()) {
+		if _, err = tx.Exec(`UPDATE waitlist SET status=$2 WHERE id=$1`, id, model.WaitlistExpired); err != nil {
+			return "", err
+		}
+		if err = tx.Commit(); err != nil {
+			return "", err
+		}
+		return "", db.HoldExpiredError
+	}
+	bookingID, err := p.bookWaitlistEntry(tx, entry, entry.HoldWorkspaceID)
+	if err != nil {
+		return "", err
+	}
+	return bookingID, tx.Commit()
+}
+
+// ProcessWaitlist offers a workspace that has just become free between start and end to the oldest waiting entry
+// it suits: one on the workspace's floor, asking for properties the workspace has, and whose whole time range the
+// workspace is now available for. A zero end, e.g. of an open-ended default offering, frees the workspace from start on.
+// The entry is booked or given a hold until holdUntil, its user is notified of either through the outbox, and it is
+// returned; nil is returned when nobody on the waitlist can use the workspace
+func (p PostgresDBStore) ProcessWaitlist(workspaceId string, start, end time.Time, holdUntil time.Time) (*model.WaitlistEntry, error) {
+	tx, err := p.database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var until *time.Time
+	if !end.IsZero() {
+		until = &end
+	}
+	rows, err := tx.Query(
+		`SELECT wl.id, wl.user_id, wl.floor_id, COALESCE(wl.properties, '{}'::json), wl.start_time, wl.end_time, wl.auto_book, wl.status,
+					'', wl.hold_expires_at, '', wl.created_at
+				FROM waitlist wl INNER JOIN workspaces w ON w.floor_id = wl.floor_id
+				WHERE w.id=$1 AND w.deleted=FALSE AND wl.status=$4 AND
+				      COALESCE(w.metadata, '{}')::jsonb @> COALESCE(wl.properties, '{}')::jsonb AND
+				      ($3::timestamptz IS NULL OR wl.start_time <= $3) AND wl.end_time >= $2
+				ORDER BY wl.created_at
+				FOR UPDATE OF wl SKIP LOCKED`,
+		workspaceId, start, nullTime(until), model.WaitlistWaiting,
+	)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]*model.WaitlistEntry, 0)
+	for rows.Next() {
+		entry, err := scanWaitlistEntry(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		candidates = append(candidates, entry)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range candidates {
+		err = p.checkBookingAvailable(tx, &model.Booking{
+			WorkspaceID: workspaceId,
+			UserID:      entry.UserID,
+			StartDate:   entry.StartDate,
+			EndDate:     entry.EndDate,
+		})
+		if err != nil {
+			if db.AsError(err) != nil {
+				continue
+			}
+			return nil, err
+		}
+		if entry.AutoBook {
+			if _, err = p.bookWaitlistEntry(tx, entry, workspaceId); err != nil {
+				return nil, err
+			}
+		} else {
+			_, err = tx.Exec(`UPDATE waitlist SET status=$2, hold_workspace_id=$3, hold_expires_at=$4 WHERE id=$1`,
+				entry.ID, model.WaitlistHeld, workspaceId, holdUntil)
+			if err != nil {
+				return nil, err
+			}
+			entry.Status = model.WaitlistHeld
+			entry.HoldWorkspaceID = workspaceId
+			entry.HoldExpiresAt = &holdUntil
+			err = queueNotification(tx, &model.Notification{
+				Kind:          model.NotificationHold,
+				Subject:       model.NotificationWaitlist,
+				SubjectID:     entry.ID,
+				UserID:        entry.UserID,
+				StartDate:     entry.StartDate,
+				EndDate:       entry.EndDate,
+				HoldExpiresAt: &holdUntil,
+			}, workspaceId)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return entry, tx.Commit()
+	}
+	return nil, tx.Commit()
+}
+
+// ExpireWaitlistHolds marks the holds that ran out by now as expired and returns their entries, so the workspaces
+// they held can be offered to whoever is next
+func (p PostgresDBStore) ExpireWaitlistHolds(now time.Time) ([]*model.WaitlistEntry, error) {
+	sqlStatement := `UPDATE waitlist SET status=$1 WHERE status=$2 AND hold_expires_at <= $3 RETURNING ` + waitlistColumns
+	rows, err := p.database.Query(sqlStatement, model.WaitlistExpired, model.WaitlistHeld, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := make([]*model.WaitlistEntry, 0)
+	for rows.Next() {
+		entry, err := scanWaitlistEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// bookWaitlistEntry books workspaceId for the entry's user and time range, marks the entry as booked and queues the
+// booking's confirmation
+func (p PostgresDBStore) bookWaitlistEntry(tx *sql.Tx, entry *model.WaitlistEntry, workspaceId string) (string, error) {
+	booking := &model.Booking{
+		WorkspaceID: workspaceId,
+		UserID:      entry.UserID,
+		StartDate:   entry.StartDate,
+		EndDate:     entry.EndDate,
+		CreatedBy:   entry.UserID,
+	}
+	if err := p.checkBookingAvailable(tx, booking); err != nil {
+		return "", err
+	}
+	var bookingID string
+	err := tx.QueryRow(
+		`INSERT INTO bookings(user_id, workspace_id, start_time, end_time, created_by) VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		booking.UserID,
+		booking.WorkspaceID,
+		booking.StartDate,
+		booking.EndDate,
+		booking.CreatedBy,
+	).Scan(&bookingID)
+	if err != nil {
+		return "", translateError(err)
+	}
+	_, err = tx.Exec(`UPDATE waitlist SET status=$2, booking_id=$3, hold_workspace_id=$4 WHERE id=$1`,
+		entry.ID, model.WaitlistBooked, bookingID, workspaceId)
+	if err != nil {
+		return "", err
+	}
+	err = queueBookingNotifications(tx, &model.Notification{
+		Kind:      model.NotificationConfirmation,
+		Subject:   model.NotificationBooking,
+		SubjectID: bookingID,
+		UserID:    booking.UserID,
+		StartDate: booking.StartDate,
+		EndDate:   booking.EndDate,
+	}, workspaceId, booking.CreatedBy)
+	if err != nil {
+		return "", err
+	}
+	entry.Status = model.WaitlistBooked
+	entry.BookingID = bookingID
+	entry.HoldWorkspaceID = workspaceId
+	return bookingID, nil
+}
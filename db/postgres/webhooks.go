@@ -0,0 +1,243 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/lib/pq"
+	"go-api/db"
+	"go-api/model"
+	"time"
+)
+
+const webhookColumns = `id, url, secret, events, COALESCE(floor_id::text, ''), active, failing_since, disabled_at, created_at`
+
+const webhookDeliveryColumns = `id, webhook_id, event, payload, status, attempts, next_attempt_at, response_status,
+	last_error, created_at, delivered_at`
+
+func scanWebhook(row scanner) (*model.Webhook, error) {
+	var webhook model.Webhook
+	var failingSince, disabledAt sql.NullTime
+	err := row.Scan(
+		&webhook.ID,
+		&webhook.URL,
+		&webhook.Secret,
+		pq.Array(&webhook.Events),
+		&webhook.FloorID,
+		&webhook.Active,
+		&failingSince,
+		&disabledAt,
+		&webhook.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if failingSince.Valid {
+		webhook.FailingSince = &failingSince.Time
+	}
+	if disabledAt.Valid {
+		webhook.DisabledAt = &disabledAt.Time
+	}
+	return &webhook, nil
+}
+
+func scanWebhookDelivery(row scanner) (*model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	var payload []byte
+	var deliveredAt sql.NullTime
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.Event,
+		&payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.ResponseStatus,
+		&delivery.LastError,
+		&delivery.CreatedAt,
+		&deliveredAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Payload = json.RawMessage(payload)
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	return &delivery, nil
+}
+
+func queryWebhookDeliveries(rows *sql.Rows, err error) ([]*model.WebhookDelivery, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	deliveries := make([]*model.WebhookDelivery, 0)
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// nullTime is t as a query argument, NULL if it is nil
+func nullTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
+
+func (p PostgresDBStore) GetWebhooks() ([]*model.Webhook, error) {
+	rows, err := p.database.Query(`SELECT ` + webhookColumns + ` FROM webhooks ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	webhooks := make([]*model.Webhook, 0)
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+func (p PostgresDBStore) GetOneWebhook(id string) (*model.Webhook, error) {
+	return scanWebhook(p.database.QueryRow(`SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`, id))
+}
+
+func (p PostgresDBStore) CreateWebhook(webhook *model.Webhook) (string, error) {
+	if webhook.Events == nil {
+		webhook.Events = []string{}
+	}
+	err := p.database.QueryRow(
+		`INSERT INTO webhooks(url, secret, events, floor_id, active, disabled_at)
+				VALUES ($1, $2, $3, NULLIF($4, '')::uuid, $5, $6)
+				RETURNING id, created_at`,
+		webhook.URL, webhook.Secret, pq.Array(webhook.Events), webhook.FloorID, webhook.Active, nullTime(webhook.DisabledAt),
+	).Scan(&webhook.ID, &webhook.CreatedAt)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == foreignKeyViolation {
+		return "", db.NotFoundError
+	}
+	if err != nil {
+		return "", err
+	}
+	return webhook.ID, nil
+}
+
+func (p PostgresDBStore) UpdateWebhook(id string, webhook *model.Webhook) error {
+	if webhook.Events == nil {
+		webhook.Events = []string{}
+	}
+	result, err := p.database.Exec(
+		`UPDATE webhooks SET url = $2, secret = $3, events = $4, floor_id = NULLIF($5, '')::uuid, active = $6,
+				       failing_since = $7, disabled_at = $8
+				WHERE id = $1`,
+		id, webhook.URL, webhook.Secret, pq.Array(webhook.Events), webhook.FloorID, webhook.Active,
+		nullTime(webhook.FailingSince), nullTime(webhook.DisabledAt),
+	)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == foreignKeyViolation {
+		return db.NotFoundError
+	}
+	if err != nil {
+		return err
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		return db.NotFoundError
+	}
+	return nil
+}
+
+func (p PostgresDBStore) RemoveWebhook(id string) error {
+	var removed string
+	err := p.database.QueryRow(`DELETE FROM webhooks WHERE id = $1 RETURNING id`, id).Scan(&removed)
+	if err == sql.ErrNoRows {
+		return db.NotFoundError
+	}
+	return err
+}
+
+func (p PostgresDBStore) QueueWebhookDeliveries(eventType, floorId string, payload []byte) (int, error) {
+	result, err := p.database.Exec(
+		`INSERT INTO webhook_deliveries(webhook_id, event, payload)
+				SELECT id, $1, $3::jsonb FROM webhooks
+				WHERE active AND (cardinality(events) = 0 OR $1 = ANY(events))
+				      AND (floor_id IS NULL OR floor_id = NULLIF($2, '')::uuid)`,
+		eventType, floorId, string(payload),
+	)
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	return int(count), err
+}
+
+func (p PostgresDBStore) ClaimWebhookDeliveries(now time.Time, limit int, lease time.Duration) ([]*model.WebhookDelivery, error) {
+	return queryWebhookDeliveries(webhookQueue.claim(p.database, webhookDeliveryColumns, now, limit, lease))
+}
+
+func (p PostgresDBStore) MarkWebhookDelivered(id string, attempt *model.DeliveryAttempt) error {
+	tx, err := p.database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	webhookId, err := webhookQueue.markDelivered(tx, id, attempt)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`UPDATE webhooks SET failing_since = NULL WHERE id = $1`, webhookId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p PostgresDBStore) MarkWebhookFailed(id string, attempt *model.DeliveryAttempt, disableBefore time.Time) (bool, error) {
+	tx, err := p.database.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	webhookId, err := webhookQueue.markFailed(tx, id, attempt)
+	if err != nil {
+		return false, err
+	}
+	webhook, err := scanWebhook(tx.QueryRow(`SELECT `+webhookColumns+` FROM webhooks WHERE id = $1 FOR UPDATE`, webhookId))
+	if err != nil {
+		return false, err
+	}
+	failingSince := attempt.At
+	if webhook.FailingSince != nil {
+		failingSince = *webhook.FailingSince
+	}
+	disable := webhook.Active && failingSince.Before(disableBefore)
+	_, err = tx.Exec(
+		`UPDATE webhooks SET failing_since = $2, active = active AND NOT $3,
+				       disabled_at = CASE WHEN $3 THEN $4 ELSE disabled_at END
+				WHERE id = $1`,
+		webhookId, failingSince, disable, attempt.At,
+	)
+	if err != nil {
+		return false, err
+	}
+	return disable, tx.Commit()
+}
+
+func (p PostgresDBStore) GetWebhookDeliveries(webhookId, status string) ([]*model.WebhookDelivery, error) {
+	return queryWebhookDeliveries(p.database.Query(
+		`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries
+				WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+				ORDER BY created_at DESC`,
+		webhookId, status,
+	))
+}
+
+func (p PostgresDBStore) DeleteWebhookDeliveriesBefore(t time.Time) error {
+	return webhookQueue.deleteBefore(p.database, t)
+}
@@ -33,7 +33,7 @@ func (p PostgresDBStore) IsFullyAssigned(id string, startTime time.Time, endTime
 	// assigned start <= booking start & assigned end either null or >= booking end
 	sqlStatement := `SELECT workspace_id FROM workspace_assignee
 					 	WHERE workspace_id=$1 AND (
-					 	    (start_time <= $1 AND end_time >=$3));`
+					 	    (start_time <= $2 AND end_time >=$3));`
 	var returned string
 	row := p.database.QueryRow(sqlStatement, id, startTime, endTime)
 	switch err := row.Scan(&returned); err {
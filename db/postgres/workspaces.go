@@ -2,8 +2,7 @@ package postgres
 
 import (
 	"database/sql"
-	"errors"
-	"fmt"
+	"go-api/db"
 	"go-api/model"
 	"go-api/utils"
 	"log"
@@ -36,7 +35,7 @@ func (p PostgresDBStore) UpdateWorkspace(id string, workspace *model.Workspace)
 		return err
 	}
 	if count > 0 {
-		return errors.New("workspace name already exists")
+		return db.NewConflictError("workspace name already exists")
 	}
 	sqlStatement :=
 		`UPDATE workspaces
@@ -50,8 +49,11 @@ func (p PostgresDBStore) UpdateWorkspace(id string, workspace *model.Workspace)
 	if err != nil {
 		return err
 	}
-	if _id != id || name != workspace.Name || floorId != workspace.Floor {
-		return CreateError
+	if _id != id {
+		return db.NotFoundError
+	}
+	if name != workspace.Name || floorId != workspace.Floor {
+		return db.NewConflictError("workspace changed while it was being updated")
 	}
 	workspace.ID = _id
 	return tx.Commit()
@@ -65,7 +67,7 @@ func (p PostgresDBStore) UpdateWorkspaceMetadata(id string, properties *model.At
 		return err
 	}
 	if _id != id {
-		return CreateError
+		return db.NotFoundError
 	}
 	return nil
 }
@@ -84,7 +86,7 @@ func (p PostgresDBStore) CreateWorkspace(workspace *model.Workspace) (string, er
 		return "", err
 	}
 	if count > 0 {
-		return "", errors.New(fmt.Sprintf("workspace name: %s already exists on floor: %s", workspace.Name, workspace.Floor))
+		return "", db.NewConflictError("workspace name: %s already exists on floor: %s", workspace.Name, workspace.Floor)
 	}
 	createWorkspaceStmt :=
 		`INSERT INTO workspaces(name, floor_id, metadata, details) VALUES ($1, $2, $3, $4) RETURNING id`
@@ -252,7 +254,7 @@ func (p PostgresDBStore) CreateAssignment(userId, workspaceId string) error {
 		return err
 	}
 	if count > 0 {
-		return errors.New("invalid operation: workspace has outstanding bookings")
+		return db.NewConflictError("workspace has outstanding bookings")
 	}
 
 	// End the assignments
@@ -284,7 +286,7 @@ func (p PostgresDBStore) CreateAssignment(userId, workspaceId string) error {
 	err = tx.QueryRow(sqlStatement, userId, workspaceId, time.Now()).Scan(&id)
 	if err != nil {
 		log.Printf("PostgresDBStore.CreateDefaultOffering: error creating new assignment: %v\n", err)
-		return err
+		return translateError(err)
 	}
 	return tx.Commit()
 }
@@ -350,7 +352,7 @@ func (p PostgresDBStore) CreateAssignWorkspace(workspace *model.Workspace, userI
 			var id string
 			err := tx.QueryRow(sqlStatement, utils.EmptyUserUUID, workspaceId, now, nil, utils.EmptyUserUUID).Scan(&id)
 			if err != nil {
-				return "", err
+				return "", translateError(err)
 			}
 			log.Println("--- Created New Default offering: ", id)
 		} else {
@@ -371,7 +373,7 @@ func (p PostgresDBStore) CreateAssignWorkspace(workspace *model.Workspace, userI
 				var id string
 				err := tx.QueryRow(sqlStatement, utils.EmptyUserUUID, workspaceId, now, nil, utils.EmptyUserUUID).Scan(&id)
 				if err != nil {
-					return "", err
+					return "", translateError(err)
 				}
 				log.Println("--- Created New Default offering: ", id)
 			}
@@ -385,7 +387,7 @@ func (p PostgresDBStore) CreateAssignWorkspace(workspace *model.Workspace, userI
 				`INSERT INTO workspace_assignee(user_id, workspace_id, start_time) VALUES ($1, $2, $3) RETURNING id`
 			err = tx.QueryRow(createAssignmentStmt, userId, workspaceId, now).Scan(&waId)
 			if err != nil {
-				return "", err
+				return "", translateError(err)
 			}
 			//log.Println("--- Created Assignment: ", waId)
 		} else if currentlyAssignedUserId != userId {
@@ -399,7 +401,7 @@ func (p PostgresDBStore) CreateAssignWorkspace(workspace *model.Workspace, userI
 				return "", err
 			}
 			if count > 0 {
-				return "", errors.New("invalid operation: workspace has outstanding bookings")
+				return "", db.NewConflictError("workspace has outstanding bookings")
 			}
 			// cancel current assignment and offering
 			updateAssignmentsStmt := `UPDATE workspace_assignee SET end_time=$2 WHERE workspace_id=$1 AND end_time IS NULL RETURNING id`
@@ -433,7 +435,7 @@ func (p PostgresDBStore) CreateAssignWorkspace(workspace *model.Workspace, userI
 				`INSERT INTO workspace_assignee(user_id, workspace_id, start_time) VALUES ($1, $2, $3) RETURNING id`
 			err = tx.QueryRow(createAssignmentStmt, userId, workspaceId, now).Scan(&waId)
 			if err != nil {
-				return "", nil
+				return "", translateError(err)
 			}
 			//log.Println("--- Created Assignment: ", waId)
 		}
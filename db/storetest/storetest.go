@@ -0,0 +1,1416 @@
+// Package storetest is the conformance suite every DataStore implementation has to pass, so that the in-memory
+// store used in tests and local development behaves like postgres
+package storetest
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go-api/archive"
+	"go-api/db"
+	"go-api/model"
+	"sync"
+	"time"
+)
+
+const (
+	AliceId = "a11ce000-0000-4000-a000-000000000001"
+	BobId   = "b0b00000-0000-4000-a000-000000000002"
+	CarolId = "ca201000-0000-4000-a000-000000000003"
+
+	// MissingId is a well-formed id nothing has
+	MissingId = "00000000-0000-4000-a000-00000000dead"
+	// ArchivedId and OrphanId are ids of rows restored from archives
+	ArchivedId = "00000000-0000-4000-a000-00000000a4c1"
+	OrphanId   = "00000000-0000-4000-a000-00000000f00d"
+
+	concurrentRequests = 20
+)
+
+// Suite runs against a new store for every test. The store is seeded with a floor, three users, alice and bob
+// from R&D and carol from Ops, and three workspaces: W1 assigned to alice and offered by her for the three days
+// starting a week from now, W2 assigned to bob, and W3 unassigned
+type Suite struct {
+	suite.Suite
+	// NewStore returns an empty store
+	NewStore func() (*db.DataStore, error)
+
+	store           *db.DataStore
+	now             time.Time
+	base            time.Time
+	floorId         string
+	w1, w2, w3      string
+	aliceOfferingId string
+	defaultOffering string
+}
+
+func (s *Suite) SetupTest() {
+	store, err := s.NewStore()
+	if err != nil {
+		s.FailNow("failed to create store", err.Error())
+	}
+	s.store = store
+	s.now = time.Now().UTC().Truncate(time.Second)
+	s.base = time.Date(s.now.Year(), s.now.Month(), s.now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 7)
+
+	s.floorId, err = store.FloorProvider.CreateFloor(&model.Floor{Name: "Floor 1", DownloadURL: "https://example.com/floor-1"})
+	s.Require().NoError(err)
+	users := []*model.User{
+		{ID: AliceId, Name: "Alice", Email: "alice@example.com", Department: "R&D"},
+		{ID: BobId, Name: "Bob", Email: "bob@example.com", Department: "R&D"},
+		{ID: CarolId, Name: "Carol", Email: "carol@example.com", Department: "Ops"},
+	}
+	for _, user := range users {
+		s.Require().NoError(store.UserProvider.CreateUser(user))
+	}
+	s.w1, err = store.WorkspaceProvider.CreateWorkspace(&model.Workspace{Name: "W1", Floor: s.floorId, Props: model.Attrs{"monitor": true}})
+	s.Require().NoError(err)
+	s.w2, err = store.WorkspaceProvider.CreateWorkspace(&model.Workspace{Name: "W2", Floor: s.floorId, Props: model.Attrs{}})
+	s.Require().NoError(err)
+	s.w3, err = store.WorkspaceProvider.CreateWorkspace(&model.Workspace{Name: "W3", Floor: s.floorId, Props: model.Attrs{}})
+	s.Require().NoError(err)
+
+	s.Require().NoError(store.WorkspaceProvider.CreateAssignment(AliceId, s.w1))
+	s.Require().NoError(store.WorkspaceProvider.CreateAssignment(BobId, s.w2))
+	s.defaultOffering, err = store.OfferingProvider.CreateDefaultOffering(&model.Offering{WorkspaceID: s.w3, StartDate: s.now})
+	s.Require().NoError(err)
+	s.aliceOfferingId, err = store.OfferingProvider.CreateOffering(&model.Offering{
+		UserID:      AliceId,
+		WorkspaceID: s.w1,
+		StartDate:   s.base,
+		EndDate:     s.base.AddDate(0, 0, 3),
+		CreatedBy:   AliceId,
+	})
+	s.Require().NoError(err)
+}
+
+func (s *Suite) TearDownTest() {
+	s.store.Close()
+}
+
+// day returns the given hours of the nth day after the start of alice's offering
+func (s *Suite) day(n int, from, to int) (time.Time, time.Time) {
+	day := s.base.AddDate(0, 0, n)
+	return day.Add(time.Duration(from) * time.Hour), day.Add(time.Duration(to) * time.Hour)
+}
+
+func (s *Suite) book(userId, workspaceId string, start, end time.Time) (string, error) {
+	return s.store.BookingProvider.CreateBooking(&model.Booking{
+		UserID:      userId,
+		WorkspaceID: workspaceId,
+		StartDate:   start,
+		EndDate:     end,
+		CreatedBy:   userId,
+	})
+}
+
+// assertError asserts that err is a domain error of the given kind and code
+func (s *Suite) assertError(err error, kind, code string) {
+	domainErr := db.AsError(err)
+	if s.NotNil(domainErr, "expected a %s error, got %v", kind, err) {
+		s.Equal(kind, domainErr.Kind)
+		s.Equal(code, domainErr.Code)
+	}
+}
+
+func (s *Suite) TestWorkspaces() {
+	_, err := s.store.WorkspaceProvider.CreateWorkspace(&model.Workspace{Name: "W1", Floor: s.floorId})
+	s.assertError(err, db.Conflict, db.Conflict)
+
+	err = s.store.WorkspaceProvider.UpdateWorkspace(s.w2, &model.Workspace{Name: "W1", Floor: s.floorId})
+	s.assertError(err, db.Conflict, db.Conflict)
+
+	err = s.store.WorkspaceProvider.UpdateWorkspace(s.w2, &model.Workspace{Name: "W2a", Floor: s.floorId, Details: "by the window"})
+	s.NoError(err)
+	workspace, err := s.store.WorkspaceProvider.GetOneWorkspace(s.w2)
+	s.Require().NoError(err)
+	s.Equal("W2a", workspace.Name)
+	s.Equal("by the window", workspace.Details)
+
+	_, err = s.store.WorkspaceProvider.GetOneWorkspace(MissingId)
+	s.True(db.IsKind(err, db.NotFound), "got %v", err)
+
+	count, err := s.store.WorkspaceProvider.CountWorkspacesByFloor(s.floorId)
+	s.NoError(err)
+	s.Equal(3, count)
+}
+
+func (s *Suite) TestBookings() {
+	start, end := s.day(0, 9, 17)
+	id, err := s.book(CarolId, s.w1, start, end)
+	s.Require().NoError(err)
+
+	booking, err := s.store.BookingProvider.GetOneExpandedBooking(id)
+	s.Require().NoError(err)
+	s.Equal("W1", booking.WorkspaceName)
+	s.Equal("Carol", booking.UserName)
+	s.Equal(s.floorId, booking.FloorID)
+	s.True(booking.StartDate.Equal(start))
+
+	_, err = s.book(BobId, s.w1, start.Add(2*time.Hour), end.Add(2*time.Hour))
+	s.assertError(err, db.Conflict, db.Conflict)
+
+	// alice's offering ends after three days
+	start, end = s.day(3, 9, 17)
+	_, err = s.book(BobId, s.w1, start, end)
+	s.assertError(err, db.Validation, db.Validation)
+
+	// W2 is bob's and he hasn't offered it
+	_, err = s.book(CarolId, s.w2, start, end)
+	s.assertError(err, db.Validation, db.Validation)
+
+	// W3 is unassigned, so it is offered from now on
+	_, err = s.book(CarolId, s.w3, end, start)
+	s.assertError(err, db.Validation, db.Validation)
+	_, err = s.book(CarolId, s.w3, start, end)
+	s.NoError(err)
+
+	bookings, err := s.store.BookingProvider.GetBookingsByUserID(CarolId)
+	s.NoError(err)
+	s.Len(bookings, 2)
+
+	_, err = s.store.BookingProvider.GetOneBooking(MissingId)
+	s.True(db.IsKind(err, db.NotFound), "got %v", err)
+}
+
+func (s *Suite) TestUpdateBooking() {
+	start, end := s.day(0, 9, 12)
+	first, err := s.book(CarolId, s.w1, start, end)
+	s.Require().NoError(err)
+	second, err := s.book(BobId, s.w1, start.Add(4*time.Hour), end.Add(4*time.Hour))
+	s.Require().NoError(err)
+
+	// a booking doesn't conflict with itself
+	err = s.store.BookingProvider.UpdateBooking(first, &model.Booking{WorkspaceID: s.w1, UserID: CarolId, StartDate: start, EndDate: end.Add(30 * time.Minute)})
+	s.NoError(err)
+
+	err = s.store.BookingProvider.UpdateBooking(first, &model.Booking{WorkspaceID: s.w1, UserID: CarolId, StartDate: start, EndDate: end.Add(5 * time.Hour)})
+	s.assertError(err, db.Conflict, db.Conflict)
+
+	s.NoError(s.store.BookingProvider.RemoveBooking(second))
+	err = s.store.BookingProvider.UpdateBooking(second, &model.Booking{WorkspaceID: s.w1, UserID: BobId, StartDate: start, EndDate: end})
+	s.assertError(err, db.Conflict, db.Conflict)
+
+	err = s.store.BookingProvider.UpdateBooking(MissingId, &model.Booking{WorkspaceID: s.w1, UserID: BobId, StartDate: start, EndDate: end})
+	s.True(db.IsKind(err, db.NotFound), "got %v", err)
+
+	booking, err := s.store.BookingProvider.GetOneBooking(first)
+	s.Require().NoError(err)
+	s.True(booking.EndDate.Equal(end.Add(30 * time.Minute)))
+}
+
+func (s *Suite) TestRemoveBooking() {
+	start, end := s.day(0, 9, 12)
+	bookingId, err := s.book(CarolId, s.w1, start, end)
+	s.Require().NoError(err)
+
+	s.NoError(s.store.BookingProvider.RemoveBooking(bookingId))
+	notified := len(s.queued(model.NotificationCancellation, bookingId))
+	s.NotZero(notified)
+
+	// cancelling twice is refused and doesn't notify again
+	err = s.store.BookingProvider.RemoveBooking(bookingId)
+	s.assertError(err, db.Conflict, db.Conflict)
+	s.Len(s.queued(model.NotificationCancellation, bookingId), notified)
+
+	err = s.store.BookingProvider.RemoveBooking(MissingId)
+	s.True(db.IsKind(err, db.NotFound), "got %v", err)
+}
+
+func (s *Suite) TestOfferings() {
+	start, end := s.day(1, 0, 48)
+	_, err := s.store.OfferingProvider.CreateOffering(&model.Offering{
+		UserID: AliceId, WorkspaceID: s.w1, StartDate: start, EndDate: end, CreatedBy: AliceId,
+	})
+	s.assertError(err, db.Conflict, db.Conflict)
+
+	_, err = s.store.OfferingProvider.CreateOffering(&model.Offering{
+		UserID: CarolId, WorkspaceID: s.w3, StartDate: start, EndDate: end, CreatedBy: CarolId,
+	})
+	s.assertError(err, db.Validation, db.Validation)
+
+	// default offerings are never listed and can't be withdrawn
+	offerings, err := s.store.OfferingProvider.GetOfferingsByWorkspaceID(s.w3)
+	s.NoError(err)
+	s.Empty(offerings)
+	err = s.store.OfferingProvider.RemoveOffering(s.defaultOffering)
+	s.assertError(err, db.Forbidden, db.Forbidden)
+
+	start, end = s.day(1, 9, 17)
+	_, err = s.book(CarolId, s.w1, start, end)
+	s.Require().NoError(err)
+	err = s.store.OfferingProvider.RemoveOffering(s.aliceOfferingId)
+	s.assertError(err, db.Conflict, db.Conflict)
+
+	expired, err := s.store.OfferingProvider.GetExpiredOfferings(s.base.AddDate(0, 1, 0))
+	s.NoError(err)
+	if s.Len(expired, 1) {
+		s.Equal(s.aliceOfferingId, expired[0].ID)
+	}
+}
+
+func (s *Suite) TestAssignment() {
+	start, end := s.day(0, 9, 17)
+	_, err := s.book(BobId, s.w3, start, end)
+	s.Require().NoError(err)
+	err = s.store.WorkspaceProvider.CreateAssignment(CarolId, s.w3)
+	s.assertError(err, db.Conflict, db.Conflict)
+
+	id, err := s.store.WorkspaceProvider.CreateAssignWorkspace(&model.Workspace{Name: "W4", Floor: s.floorId}, CarolId)
+	s.Require().NoError(err)
+	// assigning the same user again changes nothing
+	again, err := s.store.WorkspaceProvider.CreateAssignWorkspace(&model.Workspace{Name: "W4", Floor: s.floorId}, CarolId)
+	s.NoError(err)
+	s.Equal(id, again)
+
+	users, err := s.store.UserProvider.GetAssignedUsersByTime(time.Now().Add(time.Minute))
+	s.NoError(err)
+	assigned := make(map[string]string)
+	for _, user := range users {
+		assigned[user.ID] = user.WorkspaceId
+	}
+	s.Equal(map[string]string{AliceId: s.w1, BobId: s.w2, CarolId: id}, assigned)
+
+	// W4 is carol's, so nobody else can book it until she offers it
+	_, err = s.book(BobId, id, start, end)
+	s.assertError(err, db.Validation, db.Validation)
+}
+
+func (s *Suite) TestBookingSeries() {
+	start, end := s.day(1, 9, 17)
+	_, err := s.book(BobId, s.w1, start, end)
+	s.Require().NoError(err)
+
+	series := &model.BookingSeries{
+		UserID:      CarolId,
+		WorkspaceID: s.w1,
+		StartDate:   start.AddDate(0, 0, -1),
+		EndDate:     end.AddDate(0, 0, -1),
+		Recurrence:  model.Recurrence{Frequency: model.FrequencyDaily, Interval: 1, Count: 3},
+		CreatedBy:   CarolId,
+	}
+	occurrences := make([]*model.Booking, 0)
+	for i := 0; i < 3; i++ {
+		occurrences = append(occurrences, &model.Booking{
+			UserID:      CarolId,
+			WorkspaceID: s.w1,
+			StartDate:   series.StartDate.AddDate(0, 0, i),
+			EndDate:     series.EndDate.AddDate(0, 0, i),
+			CreatedBy:   CarolId,
+		})
+	}
+	bookings, conflicts, err := s.store.BookingProvider.CreateBookingSeries(series, occurrences)
+	s.Require().NoError(err)
+	s.Len(bookings, 2)
+	if s.Len(conflicts, 1) {
+		s.True(conflicts[0].StartDate.Equal(start))
+		s.Equal(db.Conflict, conflicts[0].Code)
+	}
+
+	stored, err := s.store.BookingProvider.GetBookingsBySeriesID(series.ID)
+	s.NoError(err)
+	if s.Len(stored, 2) {
+		s.True(stored[0].StartDate.Before(stored[1].StartDate))
+		s.Equal(series.ID, stored[0].SeriesID)
+	}
+
+	cancelled, err := s.store.BookingProvider.CancelBookingSeries(series.ID, time.Time{})
+	s.NoError(err)
+	s.Len(cancelled, 2)
+	_, err = s.store.BookingProvider.CancelBookingSeries(series.ID, time.Time{})
+	s.assertError(err, db.Conflict, db.Conflict)
+
+	// a series none of whose occurrences can be booked isn't created at all
+	failed := &model.BookingSeries{
+		UserID:      CarolId,
+		WorkspaceID: s.w2,
+		StartDate:   start,
+		EndDate:     end,
+		Recurrence:  model.Recurrence{Frequency: model.FrequencyDaily, Interval: 1, Count: 1},
+		CreatedBy:   CarolId,
+	}
+	_, conflicts, err = s.store.BookingProvider.CreateBookingSeries(failed, []*model.Booking{
+		{UserID: CarolId, WorkspaceID: s.w2, StartDate: start, EndDate: end, CreatedBy: CarolId},
+	})
+	s.assertError(err, db.Conflict, db.Conflict)
+	s.Len(conflicts, 1)
+	_, err = s.store.BookingProvider.GetBookingSeries(failed.ID)
+	s.True(db.IsKind(err, db.NotFound), "got %v", err)
+}
+
+func (s *Suite) TestOfferingSeries() {
+	series := &model.OfferingSeries{
+		UserID:      BobId,
+		WorkspaceID: s.w2,
+		Recurrence:  model.Recurrence{Frequency: model.FrequencyDaily, Interval: 1, Count: 3},
+		CreatedBy:   BobId,
+	}
+	series.StartDate, series.EndDate = s.day(0, 0, 24)
+	occurrences := make([]*model.Offering, 0)
+	for i := 0; i < 3; i++ {
+		occurrences = append(occurrences, &model.Offering{
+			UserID:      BobId,
+			WorkspaceID: s.w2,
+			StartDate:   series.StartDate.AddDate(0, 0, i),
+			EndDate:     series.EndDate.AddDate(0, 0, i).Add(-time.Minute),
+			CreatedBy:   BobId,
+		})
+	}
+	offerings, conflicts, err := s.store.OfferingProvider.CreateOfferingSeries(series, occurrences)
+	s.Require().NoError(err)
+	s.Len(offerings, 3)
+	s.Empty(conflicts)
+
+	start, end := s.day(1, 9, 17)
+	_, err = s.book(CarolId, s.w2, start, end)
+	s.Require().NoError(err)
+
+	withdrawn, skipped, err := s.store.OfferingProvider.CancelOfferingSeries(series.ID, time.Time{})
+	s.NoError(err)
+	s.Len(withdrawn, 2)
+	if s.Len(skipped, 1) {
+		s.True(skipped[0].StartDate.Equal(start.Add(-9 * time.Hour)))
+	}
+	stored, err := s.store.OfferingProvider.GetOfferingSeries(series.ID)
+	s.NoError(err)
+	s.False(stored.Cancelled)
+}
+
+func (s *Suite) TestConcurrentBookings() {
+	start, end := s.day(0, 9, 17)
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		created int
+		ready   = make(chan struct{})
+	)
+	for i := 0; i < concurrentRequests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-ready
+			_, err := s.book(CarolId, s.w1, start, end)
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				created++
+			} else {
+				assert.True(s.T(), db.IsKind(err, db.Conflict), "got %v", err)
+			}
+		}()
+	}
+	close(ready)
+	wg.Wait()
+	s.Equal(1, created)
+}
+
+func (s *Suite) TestCheckIn() {
+	start := time.Now().Add(5 * time.Minute)
+	id, err := s.book(CarolId, s.w3, start, start.Add(2*time.Hour))
+	s.Require().NoError(err)
+
+	_, err = s.store.BookingProvider.CheckInBooking(id, start.Add(-time.Hour), 15*time.Minute)
+	s.assertError(err, db.Validation, db.Validation)
+	booking, err := s.store.BookingProvider.CheckInBooking(id, start, 15*time.Minute)
+	s.Require().NoError(err)
+	s.NotNil(booking.CheckedInAt)
+	_, err = s.store.BookingProvider.CheckInBooking(id, start, 15*time.Minute)
+	s.assertError(err, db.Conflict, db.Conflict)
+
+	later := start.Add(3 * time.Hour)
+	missed, err := s.book(CarolId, s.w3, later, later.Add(2*time.Hour))
+	s.Require().NoError(err)
+	released, err := s.store.BookingProvider.ReleaseNoShows(later.Add(16*time.Minute), 15*time.Minute)
+	s.NoError(err)
+	if s.Len(released, 1) {
+		s.Equal(missed, released[0].ID)
+		s.True(released[0].NoShow)
+	}
+
+	counts, err := s.store.BookingProvider.GetNoShowCounts(s.now, 1)
+	s.NoError(err)
+	if s.Len(counts, 1) {
+		s.Equal(CarolId, counts[0].UserID)
+		s.Equal(1, counts[0].NoShows)
+	}
+}
+
+func (s *Suite) TestCheckInEveryDay() {
+	grace := 15 * time.Minute
+	start := s.base.Add(9 * time.Hour)
+	end := start.Add(2*model.BookingDay + 8*time.Hour)
+	id, err := s.book(CarolId, s.w3, start, end)
+	s.Require().NoError(err)
+
+	_, err = s.store.BookingProvider.CheckInBooking(id, start.Add(5*time.Minute), grace)
+	s.NoError(err)
+	released, err := s.store.BookingProvider.ReleaseNoShows(start.Add(time.Hour), grace)
+	s.NoError(err)
+	s.Empty(released)
+	_, err = s.store.BookingProvider.CheckInBooking(id, start.Add(time.Hour), grace)
+	s.assertError(err, db.Conflict, db.Conflict)
+
+	nextDay := start.Add(model.BookingDay)
+	_, err = s.store.BookingProvider.CheckInBooking(id, nextDay.Add(-10*time.Minute), grace)
+	s.NoError(err, "every day of the booking is checked in to")
+	_, err = s.store.BookingProvider.CheckInBooking(id, nextDay, grace)
+	s.assertError(err, db.Conflict, db.Conflict)
+	released, err = s.store.BookingProvider.ReleaseNoShows(nextDay.Add(time.Hour), grace)
+	s.NoError(err)
+	s.Empty(released)
+
+	lastDay := nextDay.Add(model.BookingDay)
+	_, err = s.store.BookingProvider.CheckInBooking(id, lastDay.Add(time.Hour), grace)
+	s.assertError(err, db.Validation, db.Validation)
+	released, err = s.store.BookingProvider.ReleaseNoShows(lastDay.Add(time.Hour), grace)
+	s.NoError(err)
+	if s.Len(released, 1) {
+		s.Equal(id, released[0].ID)
+		s.True(released[0].StartDate.Equal(lastDay), "only the missed day is released")
+		s.True(released[0].EndDate.Equal(end))
+	}
+	booking, err := s.store.BookingProvider.GetOneBooking(id)
+	s.Require().NoError(err)
+	s.False(booking.Cancelled, "the days checked in to are kept")
+	s.True(booking.NoShow)
+	s.True(booking.EndDate.Equal(lastDay))
+	released, err = s.store.BookingProvider.ReleaseNoShows(lastDay.Add(2*time.Hour), grace)
+	s.NoError(err)
+	s.Empty(released)
+}
+
+func (s *Suite) TestPolicy() {
+	start, end := s.day(0, 9, 17)
+	s.NoError(s.store.PolicyProvider.UpdateBookingPolicy(&model.BookingPolicy{MaxAdvanceDays: 3}))
+	_, err := s.book(CarolId, s.w1, start, end)
+	s.assertError(err, db.Validation, db.PolicyAdvanceWindow)
+
+	s.NoError(s.store.PolicyProvider.UpdateBookingPolicy(&model.BookingPolicy{MaxDurationHours: 4}))
+	_, err = s.book(CarolId, s.w1, start, end)
+	s.assertError(err, db.Validation, db.PolicyMaxDuration)
+
+	s.NoError(s.store.PolicyProvider.UpdateBookingPolicy(&model.BookingPolicy{MaxFutureBookings: 1}))
+	_, err = s.book(CarolId, s.w1, start, end)
+	s.NoError(err)
+	_, err = s.book(CarolId, s.w3, start, end)
+	s.assertError(err, db.Validation, db.PolicyFutureBookings)
+
+	s.NoError(s.store.PolicyProvider.UpdateBookingPolicy(&model.BookingPolicy{MaxDepartmentDaily: 1}))
+	start, end = s.day(1, 9, 17)
+	_, err = s.book(BobId, s.w3, start, end)
+	s.NoError(err)
+	_, err = s.book(AliceId, s.w1, start, end)
+	s.assertError(err, db.Validation, db.PolicyDepartmentDaily)
+
+	s.NoError(s.store.PolicyProvider.UpdateBookingPolicy(&model.BookingPolicy{}))
+	policy, err := s.store.PolicyProvider.GetBookingPolicy()
+	s.NoError(err)
+	s.Equal(model.BookingPolicy{}, *policy)
+
+	start, end = s.day(2, 9, 17)
+	blackoutId, err := s.store.PolicyProvider.CreateFloorBlackout(&model.FloorBlackout{
+		FloorID: s.floorId, StartDate: start, EndDate: end, Reason: "painting",
+	})
+	s.Require().NoError(err)
+	_, err = s.book(CarolId, s.w1, start.Add(time.Hour), end.Add(time.Hour))
+	s.assertError(err, db.Validation, db.PolicyFloorBlackout)
+	blackouts, err := s.store.PolicyProvider.GetFloorBlackouts(s.floorId)
+	s.NoError(err)
+	s.Len(blackouts, 1)
+	s.NoError(s.store.PolicyProvider.RemoveFloorBlackout(blackoutId))
+	err = s.store.PolicyProvider.RemoveFloorBlackout(blackoutId)
+	s.True(db.IsKind(err, db.NotFound), "got %v", err)
+	_, err = s.book(CarolId, s.w1, start.Add(time.Hour), end.Add(time.Hour))
+	s.NoError(err)
+}
+
+func (s *Suite) TestDepartmentDailyZone() {
+	s.NoError(s.store.PolicyProvider.UpdateBookingPolicy(&model.BookingPolicy{MaxDepartmentDaily: 1}))
+	s.Require().NoError(s.store.UserProvider.UpdateUserPreferences(AliceId, &model.UserPreferences{TimeZone: "America/Los_Angeles"}))
+
+	// Bob's early morning in UTC is still the day before in Alice's zone, the zone her days are counted in
+	start, end := s.day(2, 1, 3)
+	_, err := s.book(BobId, s.w3, start, end)
+	s.Require().NoError(err)
+	start, end = s.day(2, 17, 19)
+	_, err = s.book(AliceId, s.w1, start, end)
+	s.NoError(err)
+	start, end = s.day(1, 20, 22)
+	_, err = s.book(AliceId, s.w1, start, end)
+	s.assertError(err, db.Validation, db.PolicyDepartmentDaily)
+}
+
+func (s *Suite) TestWaitlistHold() {
+	start, end := s.day(0, 9, 17)
+	bookingId, err := s.book(BobId, s.w1, start, end)
+	s.Require().NoError(err)
+	entryId, err := s.store.WaitlistProvider.CreateWaitlistEntry(&model.WaitlistEntry{
+		UserID: CarolId, FloorID: s.floorId, Props: model.Attrs{"monitor": true}, StartDate: start, EndDate: end,
+	})
+	s.Require().NoError(err)
+	fussy, err := s.store.WaitlistProvider.CreateWaitlistEntry(&model.WaitlistEntry{
+		UserID: AliceId, FloorID: s.floorId, Props: model.Attrs{"standing": true}, StartDate: start, EndDate: end,
+	})
+	s.Require().NoError(err)
+
+	s.NoError(s.store.BookingProvider.RemoveBooking(bookingId))
+	entry, err := s.store.WaitlistProvider.ProcessWaitlist(s.w1, start, end, time.Now().Add(time.Hour))
+	s.Require().NoError(err)
+	if s.NotNil(entry) {
+		s.Equal(entryId, entry.ID)
+		s.Equal(model.WaitlistHeld, entry.Status)
+		s.Equal(s.w1, entry.HoldWorkspaceID)
+	}
+
+	_, err = s.book(BobId, s.w1, start, end)
+	s.assertError(err, db.Conflict, db.Conflict)
+
+	bookingId, err = s.store.WaitlistProvider.ClaimWaitlistHold(entryId)
+	s.Require().NoError(err)
+	entry, err = s.store.WaitlistProvider.GetOneWaitlistEntry(entryId)
+	s.Require().NoError(err)
+	s.Equal(model.WaitlistBooked, entry.Status)
+	s.Equal(bookingId, entry.BookingID)
+
+	entry, err = s.store.WaitlistProvider.GetOneWaitlistEntry(fussy)
+	s.Require().NoError(err)
+	s.Equal(model.WaitlistWaiting, entry.Status)
+	_, err = s.store.WaitlistProvider.CancelWaitlistEntry(fussy)
+	s.NoError(err)
+	_, err = s.store.WaitlistProvider.CancelWaitlistEntry(fussy)
+	s.assertError(err, db.Conflict, db.Conflict)
+}
+
+func (s *Suite) TestWaitlistExpiredHold() {
+	start, end := s.day(0, 9, 17)
+	entryId, err := s.store.WaitlistProvider.CreateWaitlistEntry(&model.WaitlistEntry{
+		UserID: CarolId, FloorID: s.floorId, StartDate: start, EndDate: end,
+	})
+	s.Require().NoError(err)
+	entry, err := s.store.WaitlistProvider.ProcessWaitlist(s.w3, start, end, time.Now().Add(-time.Minute))
+	s.Require().NoError(err)
+	s.Require().NotNil(entry)
+
+	_, err = s.store.WaitlistProvider.ClaimWaitlistHold(entryId)
+	s.assertError(err, db.Conflict, db.HoldExpiredError.Code)
+	entry, err = s.store.WaitlistProvider.GetOneWaitlistEntry(entryId)
+	s.Require().NoError(err)
+	s.Equal(model.WaitlistExpired, entry.Status)
+
+	auto, err := s.store.WaitlistProvider.CreateWaitlistEntry(&model.WaitlistEntry{
+		UserID: CarolId, FloorID: s.floorId, StartDate: start, EndDate: end, AutoBook: true,
+	})
+	s.Require().NoError(err)
+	entry, err = s.store.WaitlistProvider.ProcessWaitlist(s.w3, start, end, time.Now().Add(time.Hour))
+	s.Require().NoError(err)
+	if s.NotNil(entry) {
+		s.Equal(auto, entry.ID)
+		s.Equal(model.WaitlistBooked, entry.Status)
+		s.NotEmpty(entry.BookingID)
+	}
+}
+
+func (s *Suite) TestWaitlistHoldPassedOn() {
+	start, end := s.day(0, 9, 17)
+	first, err := s.store.WaitlistProvider.CreateWaitlistEntry(&model.WaitlistEntry{
+		UserID: CarolId, FloorID: s.floorId, StartDate: start, EndDate: end,
+	})
+	s.Require().NoError(err)
+	next, err := s.store.WaitlistProvider.CreateWaitlistEntry(&model.WaitlistEntry{
+		UserID: BobId, FloorID: s.floorId, StartDate: start, EndDate: end,
+	})
+	s.Require().NoError(err)
+	holdUntil := s.now.Add(time.Minute)
+	entry, err := s.store.WaitlistProvider.ProcessWaitlist(s.w3, start, end, holdUntil)
+	s.Require().NoError(err)
+	s.Require().NotNil(entry)
+	s.Equal(first, entry.ID)
+
+	expired, err := s.store.WaitlistProvider.ExpireWaitlistHolds(s.now)
+	s.NoError(err)
+	s.Empty(expired)
+	expired, err = s.store.WaitlistProvider.ExpireWaitlistHolds(holdUntil)
+	s.NoError(err)
+	if s.Len(expired, 1) {
+		s.Equal(first, expired[0].ID)
+		s.Equal(model.WaitlistExpired, expired[0].Status)
+		s.Equal(s.w3, expired[0].HoldWorkspaceID)
+	}
+	expired, err = s.store.WaitlistProvider.ExpireWaitlistHolds(holdUntil)
+	s.NoError(err)
+	s.Empty(expired, "a hold only expires once")
+
+	entry, err = s.store.WaitlistProvider.ProcessWaitlist(s.w3, start, end, s.now.Add(time.Hour))
+	s.Require().NoError(err)
+	if s.NotNil(entry) {
+		s.Equal(next, entry.ID, "the next entry gets the hold without anything being cancelled")
+		s.Equal(model.WaitlistHeld, entry.Status)
+	}
+}
+
+func (s *Suite) TestWaitlistOpenEnded() {
+	start, end := s.day(1, 9, 17)
+	entryId, err := s.store.WaitlistProvider.CreateWaitlistEntry(&model.WaitlistEntry{
+		UserID: CarolId, FloorID: s.floorId, StartDate: start, EndDate: end,
+	})
+	s.Require().NoError(err)
+
+	// a workspace freed with no end, e.g. by an open-ended default offering, suits any entry after start
+	entry, err := s.store.WaitlistProvider.ProcessWaitlist(s.w3, start.Add(-time.Hour), time.Time{}, time.Now().Add(time.Hour))
+	s.Require().NoError(err)
+	if s.NotNil(entry) {
+		s.Equal(entryId, entry.ID)
+		s.Equal(model.WaitlistHeld, entry.Status)
+	}
+}
+
+func (s *Suite) TestFindAvailability() {
+	start, end := s.day(0, 9, 17)
+	available, err := s.store.WorkspaceProvider.FindAvailability(s.floorId, start, end)
+	s.NoError(err)
+	s.ElementsMatch([]string{s.w1, s.w3}, available)
+
+	_, err = s.book(CarolId, s.w3, start, end)
+	s.Require().NoError(err)
+	available, err = s.store.WorkspaceProvider.FindAvailability(s.floorId, start, end)
+	s.NoError(err)
+	s.ElementsMatch([]string{s.w1}, available)
+}
+
+func (s *Suite) TestRemoveFloor() {
+	start, end := s.day(0, 9, 17)
+	_, err := s.book(CarolId, s.w3, start, end)
+	s.Require().NoError(err)
+
+	err = s.store.FloorProvider.RemoveFloor(s.floorId, false)
+	s.assertError(err, db.Conflict, db.Conflict)
+	s.NoError(s.store.FloorProvider.RemoveFloor(s.floorId, true))
+
+	floors, err := s.store.FloorProvider.GetAllFloors()
+	s.NoError(err)
+	s.Empty(floors)
+	deleted, err := s.store.FloorProvider.GetDeletedFloors()
+	s.NoError(err)
+	s.Len(deleted, 1)
+	workspaces, err := s.store.WorkspaceProvider.GetAllWorkspacesByFloor(s.floorId)
+	s.NoError(err)
+	s.Empty(workspaces)
+	workspaces, err = s.store.WorkspaceProvider.GetDeletedWorkspaces()
+	s.NoError(err)
+	s.Len(workspaces, 3)
+}
+
+func (s *Suite) TestTryLock() {
+	release, acquired, err := s.store.LockProvider.TryLock("archiver")
+	s.Require().NoError(err)
+	s.Require().True(acquired)
+
+	_, acquired, err = s.store.LockProvider.TryLock("archiver")
+	s.NoError(err)
+	s.False(acquired, "lock is held")
+	other, acquired, err := s.store.LockProvider.TryLock("reminders")
+	s.NoError(err)
+	s.True(acquired, "locks are independent")
+	other()
+
+	release()
+	release, acquired, err = s.store.LockProvider.TryLock("archiver")
+	s.NoError(err)
+	s.True(acquired, "lock was released")
+	release()
+}
+
+func (s *Suite) TestRestoreArchivedRows() {
+	start, end := s.day(0, 9, 17)
+	id, err := s.book(CarolId, s.w3, start, end)
+	s.Require().NoError(err)
+	booking, err := s.store.BookingProvider.GetOneBooking(id)
+	s.Require().NoError(err)
+	s.Require().NoError(s.store.BookingProvider.DeleteBookings([]string{id}))
+
+	overlapping := &model.Booking{ID: ArchivedId, WorkspaceID: s.w3, UserID: BobId, CreatedBy: BobId, StartDate: start, EndDate: end}
+	report, err := s.store.ArchiveProvider.RestoreArchivedRows(&model.ArchivedRows{Bookings: []*model.Booking{booking, overlapping}})
+	s.Require().NoError(err)
+	s.Equal(1, report.Restored.Bookings)
+	s.Equal(1, report.Skipped.Bookings, "the overlapping booking is skipped")
+	restored, err := s.store.BookingProvider.GetOneBooking(id)
+	if s.NoError(err) {
+		s.Equal(CarolId, restored.UserID)
+		s.True(start.Equal(restored.StartDate))
+	}
+
+	report, err = s.store.ArchiveProvider.RestoreArchivedRows(&model.ArchivedRows{Bookings: []*model.Booking{booking}})
+	s.Require().NoError(err)
+	s.Equal(0, report.Restored.Bookings)
+	s.Equal(1, report.Skipped.Bookings, "restoring twice changes nothing")
+
+	floor := &model.Floor{ID: ArchivedId, Name: "Old floor", Address: "Old address"}
+	workspace := &model.Workspace{ID: ArchivedId, Name: "Old desk", Floor: ArchivedId, Props: model.Attrs{}}
+	oldBooking := &model.Booking{ID: ArchivedId, WorkspaceID: ArchivedId, UserID: AliceId, CreatedBy: AliceId, StartDate: start, EndDate: end}
+	report, err = s.store.ArchiveProvider.RestoreArchivedRows(&model.ArchivedRows{
+		Floors:     []*model.Floor{floor},
+		Workspaces: []*model.Workspace{workspace},
+		Bookings:   []*model.Booking{oldBooking},
+	})
+	s.Require().NoError(err)
+	s.Equal(model.ArchiveCounts{Bookings: 1, Workspaces: 1, Floors: 1}, report.Restored)
+	floors, err := s.store.FloorProvider.GetDeletedFloors()
+	s.NoError(err)
+	s.Len(floors, 1, "restored floors stay deleted")
+	workspaces, err := s.store.WorkspaceProvider.GetDeletedWorkspaces()
+	s.NoError(err)
+	s.Len(workspaces, 1, "restored workspaces stay deleted")
+
+	orphan := &model.Booking{ID: OrphanId, WorkspaceID: OrphanId, UserID: AliceId, CreatedBy: AliceId, StartDate: start, EndDate: end}
+	_, err = s.store.ArchiveProvider.RestoreArchivedRows(&model.ArchivedRows{
+		Floors:   []*model.Floor{{ID: OrphanId, Name: "Orphan floor", Address: "Nowhere"}},
+		Bookings: []*model.Booking{orphan},
+	})
+	s.assertError(err, db.Validation, db.Validation)
+	floors, err = s.store.FloorProvider.GetDeletedFloors()
+	s.NoError(err)
+	s.Len(floors, 1, "a failed restore restores nothing")
+}
+
+// TestArchiveRoundTrip archives bookings and offerings in every format and restores them, keeping the series they
+// belong to and their check-ins
+func (s *Suite) TestArchiveRoundTrip() {
+	start, end := s.day(0, 9, 17)
+	bookingSeries := &model.BookingSeries{
+		UserID: BobId, WorkspaceID: s.w3, StartDate: start, EndDate: end,
+		Recurrence: model.Recurrence{Frequency: model.FrequencyDaily, Count: 1}, CreatedBy: BobId,
+	}
+	_, _, err := s.store.BookingProvider.CreateBookingSeries(bookingSeries, []*model.Booking{
+		{WorkspaceID: s.w3, UserID: BobId, StartDate: start, EndDate: end},
+	})
+	s.Require().NoError(err)
+	offeringSeries := &model.OfferingSeries{
+		UserID: BobId, WorkspaceID: s.w2, StartDate: start, EndDate: end,
+		Recurrence: model.Recurrence{Frequency: model.FrequencyDaily, Count: 1}, CreatedBy: BobId,
+	}
+	offerings, _, err := s.store.OfferingProvider.CreateOfferingSeries(offeringSeries, []*model.Offering{
+		{WorkspaceID: s.w2, UserID: BobId, StartDate: start, EndDate: end, CreatedBy: BobId},
+	})
+	s.Require().NoError(err)
+	start, end = s.day(1, 9, 17)
+	checkedIn, err := s.book(CarolId, s.w3, start, end)
+	s.Require().NoError(err)
+	_, err = s.store.BookingProvider.CheckInBooking(checkedIn, start.Add(5*time.Minute+123456*time.Microsecond), 15*time.Minute)
+	s.Require().NoError(err)
+	start, end = s.day(2, 9, 17)
+	noShow, err := s.book(CarolId, s.w3, start, end)
+	s.Require().NoError(err)
+	_, err = s.store.BookingProvider.ReleaseNoShows(start.Add(16*time.Minute), 15*time.Minute)
+	s.Require().NoError(err)
+
+	occurrences, err := s.store.BookingProvider.GetBookingsBySeriesID(bookingSeries.ID)
+	s.Require().NoError(err)
+	s.Require().Len(occurrences, 1)
+	rows := &model.ArchivedRows{Bookings: occurrences, Offerings: offerings}
+	for _, id := range []string{checkedIn, noShow} {
+		booking, err := s.store.BookingProvider.GetOneBooking(id)
+		s.Require().NoError(err)
+		rows.Bookings = append(rows.Bookings, booking)
+	}
+	offering, err := s.store.OfferingProvider.GetOneOffering(offerings[0].ID)
+	s.Require().NoError(err)
+	rows.Offerings = []*model.Offering{offering}
+	s.Require().NotNil(rows.Bookings[1].CheckedInAt)
+	s.Require().True(rows.Bookings[2].NoShow)
+
+	for _, name := range archive.Formats() {
+		format, err := archive.Lookup(name)
+		s.Require().NoError(err)
+		content := new(bytes.Buffer)
+		s.Require().NoError(format.Write(content, "archive"+format.Extension(), rows))
+		read, err := archive.Read(content)
+		s.Require().NoError(err, name)
+
+		s.Require().NoError(s.store.BookingProvider.DeleteBookings([]string{occurrences[0].ID, checkedIn, noShow}))
+		s.Require().NoError(s.store.OfferingProvider.DeleteOfferings([]string{offering.ID}))
+		report, err := s.store.ArchiveProvider.RestoreArchivedRows(&read.ArchivedRows)
+		s.Require().NoError(err, name)
+		s.Equal(model.ArchiveCounts{Bookings: 3, Offerings: 1}, report.Restored, name)
+
+		for _, archived := range rows.Bookings {
+			restored, err := s.store.BookingProvider.GetOneBooking(archived.ID)
+			if s.NoError(err, name) {
+				s.Equal(archived.SeriesID, restored.SeriesID, name)
+				s.Equal(archived.NoShow, restored.NoShow, name)
+				s.Equal(archived.Cancelled, restored.Cancelled, name)
+				if archived.CheckedInAt == nil {
+					s.Nil(restored.CheckedInAt, name)
+				} else if s.NotNil(restored.CheckedInAt, name) {
+					s.True(archived.CheckedInAt.Equal(*restored.CheckedInAt), name)
+				}
+			}
+		}
+		restored, err := s.store.OfferingProvider.GetOneOffering(offering.ID)
+		if s.NoError(err, name) {
+			s.Equal(offeringSeries.ID, restored.SeriesID, name)
+		}
+	}
+	s.Equal(bookingSeries.ID, rows.Bookings[0].SeriesID)
+}
+
+func (s *Suite) TestDeleteArchivedRows() {
+	start, end := s.day(0, 9, 17)
+	id, err := s.book(CarolId, s.w3, start, end)
+	s.Require().NoError(err)
+	cutoff := s.base.AddDate(0, 0, 30)
+	bookings, err := s.store.BookingProvider.GetExpiredBookings(cutoff)
+	s.Require().NoError(err)
+	s.Require().Len(bookings, 1)
+
+	missing := &model.Booking{ID: ArchivedId, WorkspaceID: s.w3, UserID: CarolId, CreatedBy: CarolId, StartDate: start, EndDate: end}
+	err = s.store.ArchiveProvider.DeleteArchivedRows(&model.ArchivedRows{Bookings: []*model.Booking{bookings[0], missing}}, cutoff)
+	s.assertError(err, db.Conflict, db.Conflict)
+	_, err = s.store.BookingProvider.GetOneBooking(id)
+	s.NoError(err, "a failed delete deletes nothing")
+
+	s.Require().NoError(s.store.ArchiveProvider.DeleteArchivedRows(&model.ArchivedRows{Bookings: bookings}, cutoff))
+	_, err = s.store.BookingProvider.GetOneBooking(id)
+	s.assertError(err, db.NotFound, db.NotFound)
+
+	s.Require().NoError(s.store.FloorProvider.RemoveFloor(s.floorId, true))
+	workspaces, err := s.store.ArchiveProvider.GetArchivableWorkspaces(cutoff)
+	s.NoError(err)
+	s.Empty(workspaces, "the deleted workspaces are still assigned and offered")
+	floors, err := s.store.ArchiveProvider.GetArchivableFloors(cutoff)
+	s.NoError(err)
+	s.Empty(floors, "the deleted floor still has workspaces that can't be archived")
+}
+
+func (s *Suite) TestOutbox() {
+	// setup queued a confirmation of alice's offering
+	messages, err := s.store.OutboxProvider.GetOutboxMessages(model.OutboxPending)
+	s.Require().NoError(err)
+	s.Require().Len(messages, 1)
+	s.Equal(model.NotificationOffering, messages[0].Notification.Subject)
+	s.Equal(s.aliceOfferingId, messages[0].Notification.SubjectID)
+
+	start, end := s.day(0, 9, 17)
+	id, err := s.book(CarolId, s.w3, start, end)
+	s.Require().NoError(err)
+	_, err = s.book(BobId, s.w3, start, end)
+	s.assertError(err, db.Conflict, db.Conflict)
+	messages, err = s.store.OutboxProvider.GetOutboxMessages("")
+	s.Require().NoError(err)
+	s.Require().Len(messages, 2, "failed changes queue nothing")
+	booked := messages[0]
+	s.Equal(model.Notification{
+		Kind:          model.NotificationConfirmation,
+		Subject:       model.NotificationBooking,
+		SubjectID:     id,
+		UserID:        CarolId,
+		Name:          "Carol",
+		Email:         "carol@example.com",
+		WorkspaceName: "W3",
+		FloorID:       s.floorId,
+		FloorName:     "Floor 1",
+		StartDate:     booked.Notification.StartDate,
+		EndDate:       booked.Notification.EndDate,
+	}, booked.Notification)
+	s.True(start.Equal(booked.Notification.StartDate))
+
+	now := time.Now().Add(time.Second)
+	claimed, err := s.store.OutboxProvider.ClaimOutboxMessages(now, 10, time.Minute)
+	s.Require().NoError(err)
+	s.Len(claimed, 2)
+	claimed, err = s.store.OutboxProvider.ClaimOutboxMessages(now, 10, time.Minute)
+	s.Require().NoError(err)
+	s.Empty(claimed, "claimed messages are leased")
+
+	retry := now.Add(time.Hour)
+	s.NoError(s.store.OutboxProvider.MarkOutboxFailed(booked.ID, "mailbox full", &retry))
+	s.NoError(s.store.OutboxProvider.MarkOutboxFailed(messages[1].ID, "no such user", nil))
+	message, err := s.store.OutboxProvider.GetOneOutboxMessage(booked.ID)
+	if s.NoError(err) {
+		s.Equal(model.OutboxPending, message.Status)
+		s.Equal(1, message.Attempts)
+		s.Equal("mailbox full", message.LastError)
+		s.True(retry.Equal(message.NextAttemptAt))
+	}
+	s.NoError(s.store.OutboxProvider.MarkOutboxDelivered(booked.ID, now))
+	s.assertError(s.store.OutboxProvider.MarkOutboxDelivered(booked.ID, now), db.NotFound, db.NotFound)
+
+	dead, err := s.store.OutboxProvider.GetOutboxMessages(model.OutboxDead)
+	s.Require().NoError(err)
+	s.Require().Len(dead, 1)
+	replayed, err := s.store.OutboxProvider.ReplayOutboxMessage(dead[0].ID, now)
+	if s.NoError(err) {
+		s.Equal(model.OutboxPending, replayed.Status)
+		s.Equal(0, replayed.Attempts)
+	}
+	_, err = s.store.OutboxProvider.ReplayOutboxMessage(dead[0].ID, now)
+	s.assertError(err, db.Conflict, db.Conflict)
+
+	s.Require().NoError(s.store.BookingProvider.RemoveBooking(id))
+	messages, err = s.store.OutboxProvider.GetOutboxMessages(model.OutboxPending)
+	s.Require().NoError(err)
+	if s.Len(messages, 2) {
+		s.Equal(model.NotificationCancellation, messages[0].Notification.Kind)
+		s.Equal(id, messages[0].Notification.SubjectID)
+	}
+}
+
+func (s *Suite) TestEmailTemplates() {
+	s.Require().NoError(s.store.UserProvider.UpdateUserPreferences(CarolId, &model.UserPreferences{Locale: "fr-CA", TimeZone: "America/Toronto"}))
+	s.assertError(s.store.UserProvider.UpdateUserPreferences(MissingId, &model.UserPreferences{Locale: "fr"}), db.NotFound, db.NotFound)
+	carol, err := s.store.UserProvider.GetOneUser(CarolId)
+	if s.NoError(err) {
+		s.Equal("fr-CA", carol.Locale)
+		s.Equal("America/Toronto", carol.TimeZone)
+	}
+	start, end := s.day(0, 9, 17)
+	_, err = s.book(CarolId, s.w3, start, end)
+	s.Require().NoError(err)
+	messages, err := s.store.OutboxProvider.GetOutboxMessages("")
+	s.Require().NoError(err)
+	if s.NotEmpty(messages) {
+		s.Equal("fr-CA", messages[0].Notification.Locale, "notifications are written in the user's locale")
+		s.Equal("America/Toronto", messages[0].Notification.TimeZone)
+	}
+
+	_, err = s.store.TemplateProvider.GetEmailTemplate("booking_confirmed", "fr")
+	s.assertError(err, db.NotFound, db.NotFound)
+	template := &model.EmailTemplate{Event: "booking_confirmed", Locale: "fr", Subject: "Réservé", Text: "{{.WorkspaceName}}"}
+	s.Require().NoError(s.store.TemplateProvider.UpsertEmailTemplate(template))
+	s.False(template.UpdatedAt.IsZero())
+	template.Subject = "Réservation confirmée"
+	s.Require().NoError(s.store.TemplateProvider.UpsertEmailTemplate(template))
+	s.Require().NoError(s.store.TemplateProvider.UpsertEmailTemplate(&model.EmailTemplate{Event: "booking_cancelled", Locale: "fr", Subject: "Annulée", Text: "-"}))
+
+	stored, err := s.store.TemplateProvider.GetEmailTemplate("booking_confirmed", "fr")
+	if s.NoError(err) {
+		s.Equal("Réservation confirmée", stored.Subject)
+		s.Equal("{{.WorkspaceName}}", stored.Text)
+	}
+	_, err = s.store.TemplateProvider.GetEmailTemplate("booking_confirmed", "fr-CA")
+	s.assertError(err, db.NotFound, db.NotFound)
+	templates, err := s.store.TemplateProvider.GetEmailTemplates()
+	s.Require().NoError(err)
+	if s.Len(templates, 2, "upserting replaces the template") {
+		s.Equal("booking_cancelled", templates[0].Event)
+	}
+
+	s.NoError(s.store.TemplateProvider.RemoveEmailTemplate("booking_confirmed", "fr"))
+	s.assertError(s.store.TemplateProvider.RemoveEmailTemplate("booking_confirmed", "fr"), db.NotFound, db.NotFound)
+}
+
+func (s *Suite) TestReminders() {
+	start, end := s.day(0, 9, 17)
+	reminder := func(userId string) *model.Notification {
+		return &model.Notification{
+			Kind:          model.NotificationReminder,
+			Subject:       model.NotificationBooking,
+			SubjectID:     "b1",
+			UserID:        userId,
+			WorkspaceName: "W1",
+			FloorName:     "Floor 1",
+			StartDate:     start,
+			EndDate:       end,
+		}
+	}
+	queued, err := s.store.ReminderProvider.QueueReminder("reminder:b1", reminder(AliceId))
+	s.Require().NoError(err)
+	s.True(queued)
+	queued, err = s.store.ReminderProvider.QueueReminder("reminder:b1", reminder(AliceId))
+	s.Require().NoError(err)
+	s.False(queued, "a key is only queued once")
+	queued, err = s.store.ReminderProvider.QueueReminder("reminder:missing", reminder(MissingId))
+	s.Require().NoError(err)
+	s.False(queued)
+
+	s.Require().NoError(s.store.UserProvider.UpdateUserPreferences(BobId, &model.UserPreferences{RemindersOptOut: true}))
+	queued, err = s.store.ReminderProvider.QueueReminder("reminder:b2", reminder(BobId))
+	s.Require().NoError(err)
+	s.False(queued, "Bob opted out of reminders")
+	queued, err = s.store.ReminderProvider.QueueReminder("digest:bob", &model.Notification{
+		Kind:   model.NotificationDigest,
+		UserID: BobId,
+		Items:  []*model.DigestItem{{Subject: model.NotificationBooking, SubjectID: "b2", StartDate: start, EndDate: end}},
+	})
+	s.Require().NoError(err)
+	s.True(queued, "but not of the digest")
+	bob, err := s.store.UserProvider.GetOneUser(BobId)
+	if s.NoError(err) {
+		s.True(bob.RemindersOptOut)
+		s.False(bob.DigestOptOut)
+	}
+
+	pending, err := s.store.OutboxProvider.GetOutboxMessages(model.OutboxPending)
+	s.Require().NoError(err)
+	messages := make([]*model.OutboxMessage, 0)
+	for _, message := range pending {
+		if message.Notification.Kind == model.NotificationReminder || message.Notification.Kind == model.NotificationDigest {
+			messages = append(messages, message)
+		}
+	}
+	if s.Len(messages, 2) {
+		s.Equal(model.NotificationDigest, messages[0].Notification.Kind)
+		s.Equal("Bob", messages[0].Notification.Name, "the user's details are filled in")
+		s.Len(messages[0].Notification.Items, 1)
+		s.Equal("alice@example.com", messages[1].Notification.Email)
+		s.Equal("W1", messages[1].Notification.WorkspaceName)
+	}
+
+	s.Require().NoError(s.store.ReminderProvider.DeleteRemindersBefore(time.Now().Add(time.Minute)))
+	queued, err = s.store.ReminderProvider.QueueReminder("reminder:b1", reminder(AliceId))
+	s.Require().NoError(err)
+	s.True(queued, "forgotten keys can be queued again")
+}
+
+func (s *Suite) TestBookingParties() {
+	start, end := s.day(0, 9, 17)
+	id, err := s.store.BookingProvider.CreateBooking(&model.Booking{
+		UserID:      BobId,
+		WorkspaceID: s.w1,
+		StartDate:   start,
+		EndDate:     end,
+		CreatedBy:   CarolId,
+	})
+	s.Require().NoError(err)
+	s.Require().NoError(s.store.BookingProvider.RemoveBooking(id))
+
+	messages, err := s.store.OutboxProvider.GetOutboxMessages(model.OutboxPending)
+	s.Require().NoError(err)
+	parties := make(map[string]*model.Notification)
+	for _, message := range messages {
+		n := message.Notification
+		if n.Subject == model.NotificationBooking && n.SubjectID == id {
+			parties[n.Kind+":"+n.UserID] = &message.Notification
+		}
+	}
+	s.Len(parties, 6)
+	for _, kind := range []string{model.NotificationConfirmation, model.NotificationCancellation} {
+		if booker := parties[kind+":"+BobId]; s.NotNil(booker, kind) {
+			s.Empty(booker.Role)
+			s.Empty(booker.BookerName)
+		}
+		if creator := parties[kind+":"+CarolId]; s.NotNil(creator, kind) {
+			s.Equal(model.RoleCreator, creator.Role)
+			s.Equal("Bob", creator.BookerName)
+			s.Equal("carol@example.com", creator.Email)
+		}
+		if owner := parties[kind+":"+AliceId]; s.NotNil(owner, kind) {
+			s.Equal(model.RoleOwner, owner.Role)
+			s.Equal("Bob", owner.BookerName)
+			s.Equal("W1", owner.WorkspaceName)
+		}
+	}
+
+	// alice booking her own offered desk is only told once
+	id, err = s.book(AliceId, s.w1, start, end)
+	s.Require().NoError(err)
+	messages, err = s.store.OutboxProvider.GetOutboxMessages(model.OutboxPending)
+	s.Require().NoError(err)
+	count := 0
+	for _, message := range messages {
+		if message.Notification.SubjectID == id {
+			count++
+			s.Empty(message.Notification.Role)
+		}
+	}
+	s.Equal(1, count)
+}
+
+// queued returns the pending notifications of the given kind about subjectId, by the user they are for
+func (s *Suite) queued(kind, subjectId string) map[string]*model.Notification {
+	messages, err := s.store.OutboxProvider.GetOutboxMessages(model.OutboxPending)
+	s.Require().NoError(err)
+	notifications := make(map[string]*model.Notification)
+	for _, message := range messages {
+		if n := message.Notification; n.Kind == kind && n.SubjectID == subjectId {
+			notifications[n.UserID] = &message.Notification
+		}
+	}
+	return notifications
+}
+
+func (s *Suite) TestQueuedNotifications() {
+	start, end := s.day(0, 9, 17)
+	series := &model.BookingSeries{
+		UserID:      BobId,
+		WorkspaceID: s.w1,
+		StartDate:   start,
+		EndDate:     end,
+		Recurrence:  model.Recurrence{Frequency: model.FrequencyDaily, Count: 2},
+		CreatedBy:   BobId,
+	}
+	bookings, _, err := s.store.BookingProvider.CreateBookingSeries(series, []*model.Booking{
+		{WorkspaceID: s.w1, UserID: BobId, StartDate: start, EndDate: end},
+		{WorkspaceID: s.w1, UserID: BobId, StartDate: start.AddDate(0, 0, 1), EndDate: end.AddDate(0, 0, 1)},
+	})
+	s.Require().NoError(err)
+	confirmed := s.queued(model.NotificationConfirmation, series.ID)
+	s.Len(confirmed, 2)
+	if booker := confirmed[BobId]; s.NotNil(booker) {
+		s.True(booker.Series)
+		s.Len(booker.Occurrences, 2)
+		s.True(booker.StartDate.Equal(start))
+	}
+	if owner := confirmed[AliceId]; s.NotNil(owner) {
+		s.Equal(model.RoleOwner, owner.Role)
+	}
+
+	err = s.store.BookingProvider.UpdateBooking(bookings[0].ID, &model.Booking{WorkspaceID: s.w1, UserID: BobId, StartDate: start, EndDate: end.Add(-time.Hour)})
+	s.Require().NoError(err)
+	if updated := s.queued(model.NotificationUpdate, bookings[0].ID)[BobId]; s.NotNil(updated) {
+		s.True(updated.EndDate.Equal(end.Add(-time.Hour)))
+	}
+
+	_, err = s.store.BookingProvider.CancelBookingSeries(series.ID, start.AddDate(0, 0, 1))
+	s.Require().NoError(err)
+	cancelled := s.queued(model.NotificationCancellation, series.ID)
+	s.Len(cancelled, 2)
+	if booker := cancelled[BobId]; s.NotNil(booker) {
+		s.Len(booker.Occurrences, 1)
+	}
+
+	// the waitlisted user is told of their hold, and of the booking once they claim it
+	entryId, err := s.store.WaitlistProvider.CreateWaitlistEntry(&model.WaitlistEntry{
+		UserID: CarolId, FloorID: s.floorId, StartDate: start.AddDate(0, 0, 1), EndDate: end.AddDate(0, 0, 1),
+	})
+	s.Require().NoError(err)
+	holdUntil := time.Now().Add(time.Hour).Truncate(time.Second)
+	_, err = s.store.WaitlistProvider.ProcessWaitlist(s.w1, start.AddDate(0, 0, 1), end.AddDate(0, 0, 1), holdUntil)
+	s.Require().NoError(err)
+	if held := s.queued(model.NotificationHold, entryId)[CarolId]; s.NotNil(held) {
+		s.Equal(model.NotificationWaitlist, held.Subject)
+		s.Equal("W1", held.WorkspaceName)
+		if s.NotNil(held.HoldExpiresAt) {
+			s.True(held.HoldExpiresAt.Equal(holdUntil))
+		}
+	}
+	bookingId, err := s.store.WaitlistProvider.ClaimWaitlistHold(entryId)
+	s.Require().NoError(err)
+	s.Len(s.queued(model.NotificationConfirmation, bookingId), 2)
+
+	// a no-show is told of the part of their booking that was released
+	noShowStart := time.Now().Add(5 * time.Minute)
+	noShow, err := s.book(CarolId, s.w3, noShowStart, noShowStart.Add(2*time.Hour))
+	s.Require().NoError(err)
+	_, err = s.store.BookingProvider.ReleaseNoShows(noShowStart.Add(16*time.Minute), 15*time.Minute)
+	s.Require().NoError(err)
+	s.NotNil(s.queued(model.NotificationCancellation, noShow)[CarolId])
+}
+
+func (s *Suite) TestChannels() {
+	_, err := s.store.ChannelProvider.CreateChannel(&model.Channel{UserID: AliceId, FloorID: s.floorId, Kind: model.ChannelSlack, URL: "https://example.com"})
+	s.assertError(err, db.Validation, db.Validation)
+	_, err = s.store.ChannelProvider.CreateChannel(&model.Channel{UserID: MissingId, Kind: model.ChannelSlack, URL: "https://example.com"})
+	s.assertError(err, db.NotFound, db.NotFound)
+
+	aliceChannel := &model.Channel{UserID: AliceId, Kind: model.ChannelWebhook, URL: "https://example.com/a", Secret: "s3cret", Events: []string{"booking.created"}}
+	aliceId, err := s.store.ChannelProvider.CreateChannel(aliceChannel)
+	s.Require().NoError(err)
+	s.Equal(aliceId, aliceChannel.ID)
+	floorId, err := s.store.ChannelProvider.CreateChannel(&model.Channel{FloorID: s.floorId, Kind: model.ChannelTeams, URL: "https://example.com/f"})
+	s.Require().NoError(err)
+
+	channel, err := s.store.ChannelProvider.GetOneChannel(aliceId)
+	if s.NoError(err) {
+		s.Equal(AliceId, channel.UserID)
+		s.Empty(channel.FloorID)
+		s.Equal("s3cret", channel.Secret)
+		s.Equal([]string{"booking.created"}, channel.Events)
+		s.False(channel.CreatedAt.IsZero())
+	}
+	channels, err := s.store.ChannelProvider.GetChannels(AliceId, s.floorId)
+	s.Require().NoError(err)
+	if s.Len(channels, 2) {
+		s.Equal(aliceId, channels[0].ID)
+		s.Equal(floorId, channels[1].ID)
+		s.Equal([]string{}, channels[1].Events)
+	}
+	channels, err = s.store.ChannelProvider.GetChannels("", s.floorId)
+	s.Require().NoError(err)
+	s.Len(channels, 1)
+	channels, err = s.store.ChannelProvider.GetChannels(BobId, "")
+	s.Require().NoError(err)
+	s.Empty(channels)
+
+	s.NoError(s.store.ChannelProvider.RemoveChannel(aliceId))
+	s.assertError(s.store.ChannelProvider.RemoveChannel(aliceId), db.NotFound, db.NotFound)
+	_, err = s.store.ChannelProvider.GetOneChannel(aliceId)
+	s.assertError(err, db.NotFound, db.NotFound)
+}
+
+func (s *Suite) TestChannelDeliveries() {
+	s.assertError(s.store.ChannelProvider.QueueChannelDelivery(MissingId, "booking.created", "", []byte(`{}`)), db.NotFound, db.NotFound)
+	aliceId, err := s.store.ChannelProvider.CreateChannel(&model.Channel{UserID: AliceId, Kind: model.ChannelSlack, URL: "https://example.com/a"})
+	s.Require().NoError(err)
+	floorId, err := s.store.ChannelProvider.CreateChannel(&model.Channel{FloorID: s.floorId, Kind: model.ChannelTeams, URL: "https://example.com/f"})
+	s.Require().NoError(err)
+	s.Require().NoError(s.store.ChannelProvider.QueueChannelDelivery(aliceId, "booking.created", "m1", []byte(`{"text": "booked"}`)))
+	s.Require().NoError(s.store.ChannelProvider.QueueChannelDelivery(floorId, "booking.created", "m1", []byte(`{"text": "booked"}`)))
+	// retrying the event doesn't queue it again
+	s.Require().NoError(s.store.ChannelProvider.QueueChannelDelivery(aliceId, "booking.created", "m1", []byte(`{"text": "booked"}`)))
+
+	now := time.Now().Add(time.Second)
+	claimed, err := s.store.ChannelProvider.ClaimChannelDeliveries(now, 1, time.Minute)
+	s.Require().NoError(err)
+	s.Require().Len(claimed, 1, "no more than the limit are claimed")
+	first := claimed[0]
+	s.Equal("booking.created", first.Event)
+	s.Equal("m1", first.Key)
+	s.JSONEq(`{"text": "booked"}`, string(first.Body))
+	s.Equal(model.OutboxPending, first.Status)
+	claimed, err = s.store.ChannelProvider.ClaimChannelDeliveries(now, 10, time.Minute)
+	s.Require().NoError(err)
+	s.Require().Len(claimed, 1, "claimed deliveries are leased and keyed ones queued once")
+	second := claimed[0]
+	s.NotEqual(first.ID, second.ID)
+
+	retry := now.Add(time.Hour)
+	s.NoError(s.store.ChannelProvider.MarkChannelFailed(first.ID, &model.DeliveryAttempt{At: now, ResponseStatus: 500, Error: "responded 500", NextAttemptAt: &retry}))
+	s.NoError(s.store.ChannelProvider.MarkChannelDelivered(second.ID, &model.DeliveryAttempt{At: now, ResponseStatus: 200}))
+	s.assertError(s.store.ChannelProvider.MarkChannelDelivered(second.ID, &model.DeliveryAttempt{At: now}), db.NotFound, db.NotFound)
+	claimed, err = s.store.ChannelProvider.ClaimChannelDeliveries(now.Add(2*time.Minute), 10, time.Minute)
+	s.Require().NoError(err)
+	s.Empty(claimed, "the failed delivery waits for its retry, the delivered one is done")
+
+	claimed, err = s.store.ChannelProvider.ClaimChannelDeliveries(retry, 10, time.Minute)
+	s.Require().NoError(err)
+	if s.Len(claimed, 1) {
+		s.Equal(first.ID, claimed[0].ID)
+		s.Equal(1, claimed[0].Attempts)
+		s.Equal(500, claimed[0].ResponseStatus)
+		s.Equal("responded 500", claimed[0].LastError)
+	}
+	s.NoError(s.store.ChannelProvider.MarkChannelFailed(first.ID, &model.DeliveryAttempt{At: retry, Error: "connection refused"}))
+	s.assertError(s.store.ChannelProvider.MarkChannelFailed(first.ID, &model.DeliveryAttempt{At: retry}), db.NotFound, db.NotFound)
+	claimed, err = s.store.ChannelProvider.ClaimChannelDeliveries(retry.Add(24*time.Hour), 10, time.Minute)
+	s.Require().NoError(err)
+	s.Empty(claimed, "dead deliveries aren't retried")
+
+	s.NoError(s.store.ChannelProvider.QueueChannelDelivery(aliceId, "booking.cancelled", "", []byte(`{}`)))
+	s.NoError(s.store.ChannelProvider.DeleteChannelDeliveriesBefore(retry.Add(24 * time.Hour)))
+	s.NoError(s.store.ChannelProvider.RemoveChannel(aliceId))
+	claimed, err = s.store.ChannelProvider.ClaimChannelDeliveries(retry.Add(24*time.Hour), 10, time.Minute)
+	s.Require().NoError(err)
+	s.Empty(claimed, "the deliveries of a removed channel go with it")
+}
+
+func (s *Suite) TestWebhooks() {
+	_, err := s.store.WebhookProvider.CreateWebhook(&model.Webhook{URL: "https://example.com", Secret: "s3cret", FloorID: MissingId})
+	s.assertError(err, db.NotFound, db.NotFound)
+
+	all := &model.Webhook{URL: "https://example.com/all", Secret: "s3cret", Active: true}
+	allId, err := s.store.WebhookProvider.CreateWebhook(all)
+	s.Require().NoError(err)
+	s.Equal(allId, all.ID)
+	floorId, err := s.store.WebhookProvider.CreateWebhook(&model.Webhook{URL: "https://example.com/floor", Secret: "s3cret", FloorID: s.floorId, Events: []string{"booking.created"}, Active: true})
+	s.Require().NoError(err)
+	_, err = s.store.WebhookProvider.CreateWebhook(&model.Webhook{URL: "https://example.com/off", Secret: "s3cret"})
+	s.Require().NoError(err)
+	webhooks, err := s.store.WebhookProvider.GetWebhooks()
+	s.Require().NoError(err)
+	if s.Len(webhooks, 3) {
+		s.Equal(allId, webhooks[0].ID)
+		s.Equal([]string{}, webhooks[0].Events)
+		s.Equal("s3cret", webhooks[0].Secret)
+		s.Equal(s.floorId, webhooks[1].FloorID)
+		s.False(webhooks[2].Active)
+	}
+
+	queue := func(eventType, floorId string) int {
+		count, err := s.store.WebhookProvider.QueueWebhookDeliveries(eventType, floorId, []byte(`{"type": "`+eventType+`"}`))
+		s.Require().NoError(err)
+		return count
+	}
+	s.Equal(2, queue("booking.created", s.floorId))
+	s.Equal(1, queue("booking.cancelled", s.floorId), "the floor's webhook only wants bookings being created")
+	s.Equal(1, queue("booking.created", ""), "the floor's webhook only wants events about its floor")
+	deliveries, err := s.store.WebhookProvider.GetWebhookDeliveries(allId, "")
+	s.Require().NoError(err)
+	if s.Len(deliveries, 3) {
+		s.Equal("booking.created", deliveries[0].Event)
+		s.JSONEq(`{"type": "booking.created"}`, string(deliveries[0].Payload))
+		s.Equal("booking.cancelled", deliveries[1].Event)
+		s.Equal(model.OutboxPending, deliveries[0].Status)
+	}
+
+	now := time.Now().Add(time.Second)
+	claimed, err := s.store.WebhookProvider.ClaimWebhookDeliveries(now, 10, time.Minute)
+	s.Require().NoError(err)
+	s.Require().Len(claimed, 4)
+	claimed, err = s.store.WebhookProvider.ClaimWebhookDeliveries(now, 10, time.Minute)
+	s.Require().NoError(err)
+	s.Empty(claimed, "claimed deliveries are leased")
+
+	floorDeliveries, err := s.store.WebhookProvider.GetWebhookDeliveries(floorId, "")
+	s.Require().NoError(err)
+	s.Require().Len(floorDeliveries, 1)
+	retry := now.Add(time.Hour)
+	disabled, err := s.store.WebhookProvider.MarkWebhookFailed(floorDeliveries[0].ID, &model.DeliveryAttempt{At: now, ResponseStatus: 500, Error: "responded 500", NextAttemptAt: &retry}, now.Add(-24*time.Hour))
+	s.Require().NoError(err)
+	s.False(disabled)
+	webhook, err := s.store.WebhookProvider.GetOneWebhook(floorId)
+	if s.NoError(err) {
+		s.True(webhook.Active)
+		s.True(webhook.FailingSince != nil && now.Equal(*webhook.FailingSince))
+	}
+	deliveries, err = s.store.WebhookProvider.GetWebhookDeliveries(floorId, model.OutboxPending)
+	if s.NoError(err) && s.Len(deliveries, 1) {
+		s.Equal(1, deliveries[0].Attempts)
+		s.Equal(500, deliveries[0].ResponseStatus)
+		s.Equal("responded 500", deliveries[0].LastError)
+		s.True(retry.Equal(deliveries[0].NextAttemptAt))
+	}
+
+	deliveries, err = s.store.WebhookProvider.GetWebhookDeliveries(allId, "")
+	s.Require().NoError(err)
+	disabled, err = s.store.WebhookProvider.MarkWebhookFailed(deliveries[0].ID, &model.DeliveryAttempt{At: now, Error: "connection refused"}, now.Add(time.Second))
+	s.Require().NoError(err)
+	s.True(disabled, "the webhook has been failing for longer than allowed")
+	webhook, err = s.store.WebhookProvider.GetOneWebhook(allId)
+	if s.NoError(err) {
+		s.False(webhook.Active)
+		s.True(webhook.DisabledAt != nil && now.Equal(*webhook.DisabledAt))
+	}
+	deliveries, err = s.store.WebhookProvider.GetWebhookDeliveries(allId, model.OutboxDead)
+	s.Require().NoError(err)
+	s.Len(deliveries, 1)
+
+	later := now.Add(2 * time.Hour)
+	claimed, err = s.store.WebhookProvider.ClaimWebhookDeliveries(later, 10, time.Minute)
+	s.Require().NoError(err)
+	if s.Len(claimed, 1, "the deliveries of disabled webhooks wait") {
+		s.Equal(floorId, claimed[0].WebhookID)
+	}
+	s.NoError(s.store.WebhookProvider.MarkWebhookDelivered(claimed[0].ID, &model.DeliveryAttempt{At: later, ResponseStatus: 204}))
+	s.assertError(s.store.WebhookProvider.MarkWebhookDelivered(claimed[0].ID, &model.DeliveryAttempt{At: later}), db.NotFound, db.NotFound)
+	webhook, err = s.store.WebhookProvider.GetOneWebhook(floorId)
+	if s.NoError(err) {
+		s.Nil(webhook.FailingSince)
+	}
+
+	webhook, err = s.store.WebhookProvider.GetOneWebhook(allId)
+	s.Require().NoError(err)
+	webhook.Active, webhook.FailingSince, webhook.DisabledAt = true, nil, nil
+	webhook.Events = []string{"booking.cancelled"}
+	s.NoError(s.store.WebhookProvider.UpdateWebhook(allId, webhook))
+	s.assertError(s.store.WebhookProvider.UpdateWebhook(MissingId, webhook), db.NotFound, db.NotFound)
+	webhook, err = s.store.WebhookProvider.GetOneWebhook(allId)
+	if s.NoError(err) {
+		s.True(webhook.Active)
+		s.Nil(webhook.DisabledAt)
+		s.Equal([]string{"booking.cancelled"}, webhook.Events)
+	}
+
+	s.NoError(s.store.WebhookProvider.DeleteWebhookDeliveriesBefore(time.Now().Add(time.Minute)))
+	deliveries, err = s.store.WebhookProvider.GetWebhookDeliveries(allId, "")
+	s.Require().NoError(err)
+	s.Len(deliveries, 2, "pending deliveries are kept")
+	deliveries, err = s.store.WebhookProvider.GetWebhookDeliveries(floorId, "")
+	s.Require().NoError(err)
+	s.Empty(deliveries)
+
+	s.NoError(s.store.WebhookProvider.RemoveWebhook(allId))
+	s.assertError(s.store.WebhookProvider.RemoveWebhook(allId), db.NotFound, db.NotFound)
+	deliveries, err = s.store.WebhookProvider.GetWebhookDeliveries(allId, "")
+	s.Require().NoError(err)
+	s.Empty(deliveries, "deliveries go with their webhook")
+}
@@ -0,0 +1,203 @@
+// Package ical writes iCalendar (RFC 5545) calendars of bookings and offerings: the invitations attached to emails,
+// which use the REQUEST and CANCEL methods of iTIP (RFC 5546), and the feeds calendar apps subscribe to
+package ical
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// MethodPublish is for feeds, MethodRequest invites the attendees to an event or updates it and MethodCancel
+	// cancels it
+	MethodPublish = "PUBLISH"
+	MethodRequest = "REQUEST"
+	MethodCancel  = "CANCEL"
+
+	StatusConfirmed = "CONFIRMED"
+	StatusCancelled = "CANCELLED"
+
+	ContentType = "text/calendar"
+
+	prodID     = "-//IWork//Workspace Booking//EN"
+	uidDomain  = "iwork"
+	timeLayout = "20060102T150405Z"
+	// lineLength is the most octets a content line has before it is folded, not counting the line break
+	lineLength = 75
+)
+
+// sequenceEpoch is the time Sequence counts from
+var sequenceEpoch = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Person is the organizer or an attendee of an event
+type Person struct {
+	Name  string
+	Email string
+}
+
+type Event struct {
+	// UID identifies the event across every invitation and feed it is in, see UID
+	UID string
+	// Sequence is the revision of the event; an invitation only replaces one with a lower sequence
+	Sequence    int
+	Status      string
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+	// Free marks an event that doesn't make its attendees busy, e.g. a workspace offered to others
+	Free      bool
+	Organizer *Person
+	Attendees []*Person
+}
+
+type Calendar struct {
+	// Method is MethodRequest or MethodCancel for invitations and MethodPublish for feeds
+	Method string
+	// Name is what calendar apps call a feed
+	Name   string
+	Events []*Event
+	// Stamp is when the calendar was written, the DTSTAMP of its events; zero means now
+	Stamp time.Time
+}
+
+// UID is the UID of the event of the booking or offering with id; kind is "booking" or "offering"
+func UID(kind, id string) string {
+	return fmt.Sprintf("%s-%s@%s", kind, id, uidDomain)
+}
+
+// Sequence is the sequence of an invitation sent at t. Bookings and offerings aren't versioned, so it counts the
+// seconds since 2020 instead, which gives every later invitation for an event a higher sequence
+func Sequence(t time.Time) int {
+	if t.Before(sequenceEpoch) {
+		return 0
+	}
+	return int(t.Sub(sequenceEpoch) / time.Second)
+}
+
+// MediaType is the Content-Type of c, with its method
+func (c *Calendar) MediaType() string {
+	if c.Method == "" {
+		return ContentType + "; charset=utf-8"
+	}
+	return ContentType + "; charset=utf-8; method=" + c.Method
+}
+
+func (c *Calendar) Bytes() []byte {
+	content := new(bytes.Buffer)
+	_ = c.Encode(content)
+	return content.Bytes()
+}
+
+// Encode writes c with CRLF line breaks, folding lines longer than 75 octets
+func (c *Calendar) Encode(w io.Writer) error {
+	stamp := c.Stamp
+	if stamp.IsZero() {
+		stamp = time.Now()
+	}
+	e := &encoder{w: w}
+	e.line("BEGIN", "VCALENDAR")
+	e.line("VERSION", "2.0")
+	e.line("PRODID", prodID)
+	e.line("CALSCALE", "GREGORIAN")
+	if c.Method != "" {
+		e.line("METHOD", c.Method)
+	}
+	if c.Name != "" {
+		e.line("X-WR-CALNAME", escape(c.Name))
+		e.line("NAME", escape(c.Name))
+	}
+	if c.Method == MethodPublish {
+		e.line("REFRESH-INTERVAL;VALUE=DURATION", "PT1H")
+		e.line("X-PUBLISHED-TTL", "PT1H")
+	}
+	for _, event := range c.Events {
+		e.event(event, stamp)
+	}
+	e.line("END", "VCALENDAR")
+	return e.err
+}
+
+type encoder struct {
+	w   io.Writer
+	err error
+}
+
+func (e *encoder) event(event *Event, stamp time.Time) {
+	e.line("BEGIN", "VEVENT")
+	e.line("UID", event.UID)
+	e.line("DTSTAMP", stamp.UTC().Format(timeLayout))
+	e.line("DTSTART", event.Start.UTC().Format(timeLayout))
+	e.line("DTEND", event.End.UTC().Format(timeLayout))
+	e.line("SEQUENCE", fmt.Sprint(event.Sequence))
+	if event.Status != "" {
+		e.line("STATUS", event.Status)
+	}
+	e.line("SUMMARY", escape(event.Summary))
+	if event.Description != "" {
+		e.line("DESCRIPTION", escape(event.Description))
+	}
+	if event.Location != "" {
+		e.line("LOCATION", escape(event.Location))
+	}
+	if event.Free {
+		e.line("TRANSP", "TRANSPARENT")
+	} else {
+		e.line("TRANSP", "OPAQUE")
+	}
+	if event.Organizer != nil {
+		e.line("ORGANIZER"+commonName(event.Organizer), "mailto:"+event.Organizer.Email)
+	}
+	for _, attendee := range event.Attendees {
+		e.line("ATTENDEE"+commonName(attendee)+";ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=FALSE",
+			"mailto:"+attendee.Email)
+	}
+	e.line("END", "VEVENT")
+}
+
+// line writes a content line, folded after every 75 octets without splitting a character
+func (e *encoder) line(name, value string) {
+	if e.err != nil {
+		return
+	}
+	content := name + ":" + value
+	folded := new(strings.Builder)
+	for width := lineLength; len(content) > width; width = lineLength - 1 {
+		cut := width
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		folded.WriteString(content[:cut])
+		folded.WriteString("\r\n ")
+		content = content[cut:]
+	}
+	folded.WriteString(content)
+	folded.WriteString("\r\n")
+	_, e.err = io.WriteString(e.w, folded.String())
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// escape escapes a TEXT value
+func escape(text string) string {
+	return textEscaper.Replace(text)
+}
+
+// commonName is the CN parameter of person, quoted since names can hold commas and colons
+func commonName(person *Person) string {
+	if person.Name == "" {
+		return ""
+	}
+	name := strings.Map(func(r rune) rune {
+		if r == '"' || r < ' ' {
+			return -1
+		}
+		return r
+	}, person.Name)
+	return `;CN="` + name + `"`
+}
@@ -0,0 +1,58 @@
+package ical
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEncode(t *testing.T) {
+	start := time.Date(2021, time.March, 1, 9, 0, 0, 0, time.FixedZone("PST", -8*60*60))
+	calendar := &Calendar{
+		Method: MethodCancel,
+		Stamp:  time.Date(2021, time.February, 1, 0, 0, 0, 0, time.UTC),
+		Events: []*Event{{
+			UID:         UID("booking", "b1"),
+			Sequence:    3,
+			Status:      StatusCancelled,
+			Summary:     "Desk, by the window; quiet",
+			Description: "Line one\nLine two with a backslash \\ and " + strings.Repeat("é", 60),
+			Start:       start,
+			End:         start.Add(8 * time.Hour),
+			Organizer:   &Person{Name: "IWork", Email: "iwork@example.com"},
+			Attendees:   []*Person{{Name: `Doe, "Jane"`, Email: "jane@example.com"}},
+		}},
+	}
+	content := string(calendar.Bytes())
+	unfolded := strings.Replace(content, "\r\n ", "", -1)
+	assert.True(t, strings.HasPrefix(content, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(content, "END:VEVENT\r\nEND:VCALENDAR\r\n"))
+	for _, line := range []string{
+		"METHOD:CANCEL",
+		"UID:booking-b1@iwork",
+		"DTSTAMP:20210201T000000Z",
+		"DTSTART:20210301T170000Z",
+		"DTEND:20210302T010000Z",
+		"SEQUENCE:3",
+		"STATUS:CANCELLED",
+		`SUMMARY:Desk\, by the window\; quiet`,
+		"ORGANIZER;CN=\"IWork\":mailto:iwork@example.com",
+		"ATTENDEE;CN=\"Doe, Jane\";ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=FALSE:mailto:jane@example.com",
+	} {
+		assert.Contains(t, unfolded, "\r\n"+line+"\r\n")
+	}
+	assert.Contains(t, unfolded, `DESCRIPTION:Line one\nLine two with a backslash \\ and `+strings.Repeat("é", 60)+"\r\n")
+	for _, line := range strings.Split(strings.TrimSuffix(content, "\r\n"), "\r\n") {
+		assert.True(t, len(line) <= 75, "lines are folded at 75 octets: %q", line)
+		assert.True(t, utf8.ValidString(line), "folding doesn't split characters: %q", line)
+	}
+	assert.Equal(t, "text/calendar; charset=utf-8; method=CANCEL", calendar.MediaType())
+}
+
+func TestSequence(t *testing.T) {
+	now := time.Now()
+	assert.True(t, Sequence(now.Add(time.Second)) > Sequence(now))
+	assert.Equal(t, 0, Sequence(time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)))
+}
@@ -0,0 +1,264 @@
+package mail
+
+// dateLayouts is how the date template function formats times in each language
+var dateLayouts = map[string]string{
+	"en": "Monday 02 Jan 06 15:04",
+	"fr": "02/01/2006 15:04",
+}
+
+const (
+	enTextFooter = `
+You can manage your bookings and offerings under the manage tab{{if .AppURL}} at {{.AppURL}}{{end}}.
+Please note that cancelling this invite won't cancel this action. Please contact an admin in this scenario.`
+	enHTMLFooter = `
+<p>You can manage your bookings and offerings under the manage tab{{if .AppURL}} at <a href="{{.AppURL}}">{{.AppURL}}</a>{{end}}.<br>
+Please note that cancelling this invite won't cancel this action. Please contact an admin in this scenario.</p>`
+
+	frTextFooter = `
+Vous pouvez gérer vos réservations et vos offres dans l'onglet Gérer{{if .AppURL}} sur {{.AppURL}}{{end}}.
+Annuler cette invitation n'annule pas cette action. Veuillez contacter un administrateur dans ce cas.`
+	frHTMLFooter = `
+<p>Vous pouvez gérer vos réservations et vos offres dans l'onglet Gérer{{if .AppURL}} sur <a href="{{.AppURL}}">{{.AppURL}}</a>{{end}}.<br>
+Annuler cette invitation n'annule pas cette action. Veuillez contacter un administrateur dans ce cas.</p>`
+
+	// frType is the French for the Type of series and waitlist emails
+	frType = `{{if eq .Type "offering series"}}série d'offres{{else if eq .Type "booking series"}}série de réservations{{else if eq .Type "offering"}}offre{{else}}réservation{{end}}`
+	// enWhen and frWhen end the emails to the other parties of a booking with its dates, which are a list for series
+	enWhen = `{{if .Occurrences}} for the following dates:
+{{range .Occurrences}}{{date .}}
+{{end}}{{else}} for the duration of {{date .Start}} to {{date .End}}.
+{{end}}`
+	enWhenHTML = `{{if .Occurrences}} for the following dates:</p>
+<ul>{{range .Occurrences}}<li>{{date .}}</li>{{end}}</ul>{{else}}
+for the duration of <strong>{{date .Start}}</strong> to <strong>{{date .End}}</strong>.</p>{{end}}`
+	frWhen = `{{if .Occurrences}} pour les dates suivantes :
+{{range .Occurrences}}{{date .}}
+{{end}}{{else}} du {{date .Start}} au {{date .End}}.
+{{end}}`
+	frWhenHTML = `{{if .Occurrences}} pour les dates suivantes :</p>
+<ul>{{range .Occurrences}}<li>{{date .}}</li>{{end}}</ul>{{else}}
+du <strong>{{date .Start}}</strong> au <strong>{{date .End}}</strong>.</p>{{end}}`
+
+	// frItemType is the capitalized French for the Type of the items of a digest
+	frItemType = `{{if eq .Type "offering"}}Offre{{else}}Réservation{{end}}`
+)
+
+// builtin are the templates used when neither the database nor the template directory has one
+var builtin = builtinSource{
+	"en": {
+		BookingConfirmed: {
+			Subject: `{{.Type}} for {{.WorkspaceName}} at {{.FloorName}}`,
+			Text: `Your {{.Type}} for workspace {{.WorkspaceName}} on floor {{.FloorName}} for the duration of {{date .Start}} to {{date .End}} has now been confirmed.
+{{if .MapURL}}Google Map Link: {{.MapURL}}
+{{end}}` + enTextFooter,
+			HTML: `<p>Your {{.Type}} for workspace <strong>{{.WorkspaceName}}</strong> on floor <strong>{{.FloorName}}</strong>
+for the duration of <strong>{{date .Start}}</strong> to <strong>{{date .End}}</strong> has now been confirmed.</p>
+{{if .MapURL}}<p><a href="{{.MapURL}}">Google Map Link</a></p>{{end}}` + enHTMLFooter,
+		},
+		BookingCancelled: {
+			Subject: `{{.Type}} cancellation for {{.WorkspaceName}}`,
+			Text: `Your {{.Type}} for workspace {{.WorkspaceName}} on floor {{.FloorName}} for the duration of {{date .Start}} to {{date .End}} has now been cancelled.
+` + enTextFooter,
+			HTML: `<p>Your {{.Type}} for workspace <strong>{{.WorkspaceName}}</strong> on floor <strong>{{.FloorName}}</strong>
+for the duration of <strong>{{date .Start}}</strong> to <strong>{{date .End}}</strong> has now been cancelled.</p>` + enHTMLFooter,
+		},
+		BookingUpdated: {
+			Subject: `Updated {{.Type}} for {{.WorkspaceName}} at {{.FloorName}}`,
+			Text: `Your {{.Type}} has been changed to workspace {{.WorkspaceName}} on floor {{.FloorName}} for the duration of {{date .Start}} to {{date .End}}.
+Please disregard the invite for the previous time.
+` + enTextFooter,
+			HTML: `<p>Your {{.Type}} has been changed to workspace <strong>{{.WorkspaceName}}</strong> on floor <strong>{{.FloorName}}</strong>
+for the duration of <strong>{{date .Start}}</strong> to <strong>{{date .End}}</strong>.
+Please disregard the invite for the previous time.</p>` + enHTMLFooter,
+		},
+		OfferingConfirmed: {
+			Subject: `{{.Type}} for {{.WorkspaceName}} at {{.FloorName}}`,
+			Text: `Your {{.Type}} for workspace {{.WorkspaceName}} on floor {{.FloorName}} for the duration of {{date .Start}} to {{date .End}} has now been confirmed.
+` + enTextFooter,
+			HTML: `<p>Your {{.Type}} for workspace <strong>{{.WorkspaceName}}</strong> on floor <strong>{{.FloorName}}</strong>
+for the duration of <strong>{{date .Start}}</strong> to <strong>{{date .End}}</strong> has now been confirmed.</p>` + enHTMLFooter,
+		},
+		OfferingCancelled: {
+			Subject: `{{.Type}} cancellation for {{.WorkspaceName}}`,
+			Text: `Your {{.Type}} for workspace {{.WorkspaceName}} on floor {{.FloorName}} for the duration of {{date .Start}} to {{date .End}} has now been cancelled.
+` + enTextFooter,
+			HTML: `<p>Your {{.Type}} for workspace <strong>{{.WorkspaceName}}</strong> on floor <strong>{{.FloorName}}</strong>
+for the duration of <strong>{{date .Start}}</strong> to <strong>{{date .End}}</strong> has now been cancelled.</p>` + enHTMLFooter,
+		},
+		SeriesConfirmed: {
+			Subject: `{{.Type}} confirmed for {{.WorkspaceName}}`,
+			Text: `Your {{.Type}} for workspace {{.WorkspaceName}} on floor {{.FloorName}} has now been confirmed for the following dates:
+{{range .Occurrences}}{{date .}}
+{{end}}` + enTextFooter,
+			HTML: `<p>Your {{.Type}} for workspace <strong>{{.WorkspaceName}}</strong> on floor <strong>{{.FloorName}}</strong> has now been confirmed for the following dates:</p>
+<ul>{{range .Occurrences}}<li>{{date .}}</li>{{end}}</ul>` + enHTMLFooter,
+		},
+		SeriesCancelled: {
+			Subject: `{{.Type}} cancelled for {{.WorkspaceName}}`,
+			Text: `Your {{.Type}} for workspace {{.WorkspaceName}} on floor {{.FloorName}} has now been cancelled for the following dates:
+{{range .Occurrences}}{{date .}}
+{{end}}` + enTextFooter,
+			HTML: `<p>Your {{.Type}} for workspace <strong>{{.WorkspaceName}}</strong> on floor <strong>{{.FloorName}}</strong> has now been cancelled for the following dates:</p>
+<ul>{{range .Occurrences}}<li>{{date .}}</li>{{end}}</ul>` + enHTMLFooter,
+		},
+		WaitlistHeld: {
+			Subject: `{{.Type}}: {{.WorkspaceName}} is held for you`,
+			Text: `A workspace matching your {{.Type}} request is now free: workspace {{.WorkspaceName}} on floor {{.FloorName}} for the duration of {{date .Start}} to {{date .End}}.
+It is held for you until {{date .HoldExpires}}, claim it before then to book it.
+` + enTextFooter,
+			HTML: `<p>A workspace matching your {{.Type}} request is now free: workspace <strong>{{.WorkspaceName}}</strong> on floor <strong>{{.FloorName}}</strong>
+for the duration of <strong>{{date .Start}}</strong> to <strong>{{date .End}}</strong>.
+It is held for you until <strong>{{date .HoldExpires}}</strong>, claim it before then to book it.</p>` + enHTMLFooter,
+		},
+		Reminder: {
+			Subject: `Reminder: {{.Type}} for {{.WorkspaceName}} at {{.FloorName}}`,
+			Text: `This is a reminder of your {{.Type}} for workspace {{.WorkspaceName}} on floor {{.FloorName}} from {{date .Start}} to {{date .End}}.
+{{if .MapURL}}Google Map Link: {{.MapURL}}
+{{end}}` + enTextFooter,
+			HTML: `<p>This is a reminder of your {{.Type}} for workspace <strong>{{.WorkspaceName}}</strong> on floor <strong>{{.FloorName}}</strong>
+from <strong>{{date .Start}}</strong> to <strong>{{date .End}}</strong>.</p>
+{{if .MapURL}}<p><a href="{{.MapURL}}">Google Map Link</a></p>{{end}}` + enHTMLFooter,
+		},
+		Digest: {
+			Subject: `Your bookings and offerings from {{date .Start}}`,
+			Text: `Here is what is coming up from {{date .Start}} to {{date .End}}:
+{{range .Items}}{{.Type}} of workspace {{.WorkspaceName}} on floor {{.FloorName}} from {{date .Start}} to {{date .End}}
+{{end}}` + enTextFooter,
+			HTML: `<p>Here is what is coming up from <strong>{{date .Start}}</strong> to <strong>{{date .End}}</strong>:</p>
+<ul>{{range .Items}}<li>{{.Type}} of workspace <strong>{{.WorkspaceName}}</strong> on floor <strong>{{.FloorName}}</strong>
+from <strong>{{date .Start}}</strong> to <strong>{{date .End}}</strong></li>{{end}}</ul>` + enHTMLFooter,
+		},
+		BookingConfirmedCreator: {
+			Subject: `{{.Type}} for {{.BookerName}} at {{.WorkspaceName}}`,
+			Text:    `Your {{.Type}} for {{.BookerName}} of workspace {{.WorkspaceName}} on floor {{.FloorName}} has now been confirmed` + enWhen + enTextFooter,
+			HTML: `<p>Your {{.Type}} for <strong>{{.BookerName}}</strong> of workspace <strong>{{.WorkspaceName}}</strong> on floor <strong>{{.FloorName}}</strong>
+has now been confirmed` + enWhenHTML + enHTMLFooter,
+		},
+		BookingCancelledCreator: {
+			Subject: `{{.Type}} cancelled for {{.BookerName}} at {{.WorkspaceName}}`,
+			Text:    `Your {{.Type}} for {{.BookerName}} of workspace {{.WorkspaceName}} on floor {{.FloorName}} has now been cancelled` + enWhen + enTextFooter,
+			HTML: `<p>Your {{.Type}} for <strong>{{.BookerName}}</strong> of workspace <strong>{{.WorkspaceName}}</strong> on floor <strong>{{.FloorName}}</strong>
+has now been cancelled` + enWhenHTML + enHTMLFooter,
+		},
+		BookingConfirmedOwner: {
+			Subject: `{{.BookerName}} booked your workspace {{.WorkspaceName}}`,
+			Text:    `{{.BookerName}} booked your workspace {{.WorkspaceName}} on floor {{.FloorName}}` + enWhen + enTextFooter,
+			HTML: `<p><strong>{{.BookerName}}</strong> booked your workspace <strong>{{.WorkspaceName}}</strong> on floor <strong>{{.FloorName}}</strong>` +
+				enWhenHTML + enHTMLFooter,
+		},
+		BookingCancelledOwner: {
+			Subject: `Booking of your workspace {{.WorkspaceName}} cancelled`,
+			Text:    `The {{.Type}} of {{.BookerName}} for your workspace {{.WorkspaceName}} on floor {{.FloorName}} has been cancelled` + enWhen + enTextFooter,
+			HTML: `<p>The {{.Type}} of <strong>{{.BookerName}}</strong> for your workspace <strong>{{.WorkspaceName}}</strong> on floor <strong>{{.FloorName}}</strong>
+has been cancelled` + enWhenHTML + enHTMLFooter,
+		},
+	},
+	"fr": {
+		BookingConfirmed: {
+			Subject: `Réservation de {{.WorkspaceName}} à {{.FloorName}}`,
+			Text: `Votre réservation du poste {{.WorkspaceName}} à l'étage {{.FloorName}} du {{date .Start}} au {{date .End}} est confirmée.
+{{if .MapURL}}Lien Google Maps : {{.MapURL}}
+{{end}}` + frTextFooter,
+			HTML: `<p>Votre réservation du poste <strong>{{.WorkspaceName}}</strong> à l'étage <strong>{{.FloorName}}</strong>
+du <strong>{{date .Start}}</strong> au <strong>{{date .End}}</strong> est confirmée.</p>
+{{if .MapURL}}<p><a href="{{.MapURL}}">Lien Google Maps</a></p>{{end}}` + frHTMLFooter,
+		},
+		BookingCancelled: {
+			Subject: `Annulation de la réservation de {{.WorkspaceName}}`,
+			Text: `Votre réservation du poste {{.WorkspaceName}} à l'étage {{.FloorName}} du {{date .Start}} au {{date .End}} est annulée.
+` + frTextFooter,
+			HTML: `<p>Votre réservation du poste <strong>{{.WorkspaceName}}</strong> à l'étage <strong>{{.FloorName}}</strong>
+du <strong>{{date .Start}}</strong> au <strong>{{date .End}}</strong> est annulée.</p>` + frHTMLFooter,
+		},
+		BookingUpdated: {
+			Subject: `Réservation modifiée pour {{.WorkspaceName}} à {{.FloorName}}`,
+			Text: `Votre réservation a été déplacée au poste {{.WorkspaceName}} à l'étage {{.FloorName}} du {{date .Start}} au {{date .End}}.
+Veuillez ignorer l'invitation précédente.
+` + frTextFooter,
+			HTML: `<p>Votre réservation a été déplacée au poste <strong>{{.WorkspaceName}}</strong> à l'étage <strong>{{.FloorName}}</strong>
+du <strong>{{date .Start}}</strong> au <strong>{{date .End}}</strong>.
+Veuillez ignorer l'invitation précédente.</p>` + frHTMLFooter,
+		},
+		OfferingConfirmed: {
+			Subject: `Offre de {{.WorkspaceName}} à {{.FloorName}}`,
+			Text: `Votre offre du poste {{.WorkspaceName}} à l'étage {{.FloorName}} du {{date .Start}} au {{date .End}} est confirmée.
+` + frTextFooter,
+			HTML: `<p>Votre offre du poste <strong>{{.WorkspaceName}}</strong> à l'étage <strong>{{.FloorName}}</strong>
+du <strong>{{date .Start}}</strong> au <strong>{{date .End}}</strong> est confirmée.</p>` + frHTMLFooter,
+		},
+		OfferingCancelled: {
+			Subject: `Annulation de l'offre de {{.WorkspaceName}}`,
+			Text: `Votre offre du poste {{.WorkspaceName}} à l'étage {{.FloorName}} du {{date .Start}} au {{date .End}} est annulée.
+` + frTextFooter,
+			HTML: `<p>Votre offre du poste <strong>{{.WorkspaceName}}</strong> à l'étage <strong>{{.FloorName}}</strong>
+du <strong>{{date .Start}}</strong> au <strong>{{date .End}}</strong> est annulée.</p>` + frHTMLFooter,
+		},
+		SeriesConfirmed: {
+			Subject: `Confirmation : ` + frType + ` pour {{.WorkspaceName}}`,
+			Text: `Votre ` + frType + ` du poste {{.WorkspaceName}} à l'étage {{.FloorName}} est confirmée pour les dates suivantes :
+{{range .Occurrences}}{{date .}}
+{{end}}` + frTextFooter,
+			HTML: `<p>Votre ` + frType + ` du poste <strong>{{.WorkspaceName}}</strong> à l'étage <strong>{{.FloorName}}</strong> est confirmée pour les dates suivantes :</p>
+<ul>{{range .Occurrences}}<li>{{date .}}</li>{{end}}</ul>` + frHTMLFooter,
+		},
+		SeriesCancelled: {
+			Subject: `Annulation : ` + frType + ` pour {{.WorkspaceName}}`,
+			Text: `Votre ` + frType + ` du poste {{.WorkspaceName}} à l'étage {{.FloorName}} est annulée pour les dates suivantes :
+{{range .Occurrences}}{{date .}}
+{{end}}` + frTextFooter,
+			HTML: `<p>Votre ` + frType + ` du poste <strong>{{.WorkspaceName}}</strong> à l'étage <strong>{{.FloorName}}</strong> est annulée pour les dates suivantes :</p>
+<ul>{{range .Occurrences}}<li>{{date .}}</li>{{end}}</ul>` + frHTMLFooter,
+		},
+		WaitlistHeld: {
+			Subject: `{{.WorkspaceName}} vous est réservé`,
+			Text: `Un poste correspondant à votre demande sur liste d'attente est libre : le poste {{.WorkspaceName}} à l'étage {{.FloorName}} du {{date .Start}} au {{date .End}}.
+Il vous est réservé jusqu'au {{date .HoldExpires}}, confirmez-le avant pour le réserver.
+` + frTextFooter,
+			HTML: `<p>Un poste correspondant à votre demande sur liste d'attente est libre : le poste <strong>{{.WorkspaceName}}</strong> à l'étage <strong>{{.FloorName}}</strong>
+du <strong>{{date .Start}}</strong> au <strong>{{date .End}}</strong>.
+Il vous est réservé jusqu'au <strong>{{date .HoldExpires}}</strong>, confirmez-le avant pour le réserver.</p>` + frHTMLFooter,
+		},
+		Reminder: {
+			Subject: `Rappel : ` + frType + ` de {{.WorkspaceName}} à {{.FloorName}}`,
+			Text: `Rappel de votre ` + frType + ` du poste {{.WorkspaceName}} à l'étage {{.FloorName}} du {{date .Start}} au {{date .End}}.
+{{if .MapURL}}Lien Google Maps : {{.MapURL}}
+{{end}}` + frTextFooter,
+			HTML: `<p>Rappel de votre ` + frType + ` du poste <strong>{{.WorkspaceName}}</strong> à l'étage <strong>{{.FloorName}}</strong>
+du <strong>{{date .Start}}</strong> au <strong>{{date .End}}</strong>.</p>
+{{if .MapURL}}<p><a href="{{.MapURL}}">Lien Google Maps</a></p>{{end}}` + frHTMLFooter,
+		},
+		Digest: {
+			Subject: `Vos réservations et offres à partir du {{date .Start}}`,
+			Text: `Voici ce qui est prévu du {{date .Start}} au {{date .End}} :
+{{range .Items}}` + frItemType + ` du poste {{.WorkspaceName}} à l'étage {{.FloorName}} du {{date .Start}} au {{date .End}}
+{{end}}` + frTextFooter,
+			HTML: `<p>Voici ce qui est prévu du <strong>{{date .Start}}</strong> au <strong>{{date .End}}</strong> :</p>
+<ul>{{range .Items}}<li>` + frItemType + ` du poste <strong>{{.WorkspaceName}}</strong> à l'étage <strong>{{.FloorName}}</strong>
+du <strong>{{date .Start}}</strong> au <strong>{{date .End}}</strong></li>{{end}}</ul>` + frHTMLFooter,
+		},
+		BookingConfirmedCreator: {
+			Subject: `Confirmation : ` + frType + ` pour {{.BookerName}} de {{.WorkspaceName}}`,
+			Text:    `Votre ` + frType + ` du poste {{.WorkspaceName}} à l'étage {{.FloorName}} pour {{.BookerName}} est confirmée` + frWhen + frTextFooter,
+			HTML: `<p>Votre ` + frType + ` du poste <strong>{{.WorkspaceName}}</strong> à l'étage <strong>{{.FloorName}}</strong> pour <strong>{{.BookerName}}</strong>
+est confirmée` + frWhenHTML + frHTMLFooter,
+		},
+		BookingCancelledCreator: {
+			Subject: `Annulation : ` + frType + ` pour {{.BookerName}} de {{.WorkspaceName}}`,
+			Text:    `Votre ` + frType + ` du poste {{.WorkspaceName}} à l'étage {{.FloorName}} pour {{.BookerName}} est annulée` + frWhen + frTextFooter,
+			HTML: `<p>Votre ` + frType + ` du poste <strong>{{.WorkspaceName}}</strong> à l'étage <strong>{{.FloorName}}</strong> pour <strong>{{.BookerName}}</strong>
+est annulée` + frWhenHTML + frHTMLFooter,
+		},
+		BookingConfirmedOwner: {
+			Subject: `{{.BookerName}} a réservé votre poste {{.WorkspaceName}}`,
+			Text:    `{{.BookerName}} a réservé votre poste {{.WorkspaceName}} à l'étage {{.FloorName}}` + frWhen + frTextFooter,
+			HTML: `<p><strong>{{.BookerName}}</strong> a réservé votre poste <strong>{{.WorkspaceName}}</strong> à l'étage <strong>{{.FloorName}}</strong>` +
+				frWhenHTML + frHTMLFooter,
+		},
+		BookingCancelledOwner: {
+			Subject: `Réservation de votre poste {{.WorkspaceName}} annulée`,
+			Text:    `La ` + frType + ` de {{.BookerName}} pour votre poste {{.WorkspaceName}} à l'étage {{.FloorName}} est annulée` + frWhen + frTextFooter,
+			HTML: `<p>La ` + frType + ` de <strong>{{.BookerName}}</strong> pour votre poste <strong>{{.WorkspaceName}}</strong> à l'étage <strong>{{.FloorName}}</strong>
+est annulée` + frWhenHTML + frHTMLFooter,
+		},
+	},
+}
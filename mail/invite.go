@@ -0,0 +1,84 @@
+package mail
+
+import (
+	"fmt"
+	"go-api/ical"
+	"time"
+)
+
+// Organizer is who sends the emails of the SendGrid client and organizes the events of their invitations
+var Organizer = &ical.Person{Name: IWorkUserName, Email: IWorkEmail}
+
+// Kind is the kind of the calendar events of typeS, "booking" or "offering", see ical.UID
+func Kind(typeS string) string {
+	if typeS == Offering || typeS == OfferingSeries {
+		return Offering
+	}
+	return Booking
+}
+
+// Summary is the title of the calendar event of a booking or offering of a workspace
+func Summary(kind, workspaceName, floorName string) string {
+	if kind == Offering {
+		return fmt.Sprintf("Offered workspace %s (%s)", workspaceName, floorName)
+	}
+	return fmt.Sprintf("Workspace %s (%s)", workspaceName, floorName)
+}
+
+// Invite is the calendar attached to the email of event: a REQUEST for confirmations and updates and a CANCEL for
+// cancellations, with one event per occurrence for series. It is nil for emails that aren't about a booking or
+// offering, such as holds, and when params don't say which booking or offering the email is about. Every event keeps
+// the UID of its booking or offering, so that calendars replace it. organizer is who sends the email
+func Invite(event, typeS string, params *EmailParams, message *Message, organizer *ical.Person, now time.Time) *ical.Calendar {
+	var method, status string
+	switch event {
+	case BookingConfirmed, BookingUpdated, OfferingConfirmed, SeriesConfirmed:
+		method, status = ical.MethodRequest, ical.StatusConfirmed
+	case BookingCancelled, OfferingCancelled, SeriesCancelled:
+		method, status = ical.MethodCancel, ical.StatusCancelled
+	default:
+		return nil
+	}
+	kind := Kind(typeS)
+	sequence := ical.Sequence(now)
+	if !params.Changed.IsZero() {
+		sequence = ical.Sequence(params.Changed)
+	}
+	newEvent := func(id string, start, end time.Time) *ical.Event {
+		return &ical.Event{
+			UID:         ical.UID(kind, id),
+			Sequence:    sequence,
+			Status:      status,
+			Summary:     Summary(kind, params.WorkspaceName, params.FloorName),
+			Description: message.Text,
+			Location:    location(params),
+			Start:       start,
+			End:         end,
+			Free:        kind == Offering,
+			Organizer:   organizer,
+			Attendees:   []*ical.Person{{Name: params.Name, Email: params.Email}},
+		}
+	}
+	calendar := &ical.Calendar{Method: method, Stamp: now}
+	if len(params.OccurrenceIDs) > 0 {
+		// every occurrence of a series is as long as the first one
+		duration := params.End.Sub(params.Start)
+		for i, id := range params.OccurrenceIDs {
+			calendar.Events = append(calendar.Events, newEvent(id, params.Occurrences[i], params.Occurrences[i].Add(duration)))
+		}
+	} else if params.ID != "" {
+		calendar.Events = append(calendar.Events, newEvent(params.ID, params.Start, params.End))
+	}
+	if len(calendar.Events) == 0 {
+		return nil
+	}
+	return calendar
+}
+
+func location(params *EmailParams) string {
+	location := params.WorkspaceName + ", " + params.FloorName
+	if params.FloorAddress != "" {
+		location += ", " + params.FloorAddress
+	}
+	return location
+}
@@ -0,0 +1,86 @@
+package mail
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-api/ical"
+	"io/ioutil"
+	netmail "net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInvite(t *testing.T) {
+	now := time.Date(2021, time.February, 1, 0, 0, 0, 0, time.UTC)
+	message := &Message{Subject: "subject", Text: "text", HTML: "<p>text</p>"}
+	params := SampleParams()
+	params.ID = "b1"
+
+	invite := Invite(BookingConfirmed, Booking, params, message, Organizer, now)
+	require.NotNil(t, invite)
+	assert.Equal(t, ical.MethodRequest, invite.Method)
+	require.Len(t, invite.Events, 1)
+	event := invite.Events[0]
+	assert.Equal(t, "booking-b1@iwork", event.UID)
+	assert.Equal(t, ical.StatusConfirmed, event.Status)
+	assert.Equal(t, "Workspace W-001 (West 2nd Avenue)", event.Summary)
+	assert.Equal(t, "W-001, West 2nd Avenue, 151 W 2nd Ave, Vancouver, BC", event.Location)
+	assert.Equal(t, params.Start, event.Start)
+	assert.Equal(t, params.End, event.End)
+	assert.False(t, event.Free)
+	assert.Equal(t, []*ical.Person{{Name: "Jane Doe", Email: "jane.doe@example.com"}}, event.Attendees)
+
+	later := Invite(BookingCancelled, Booking, params, message, Organizer, now.Add(time.Minute))
+	require.NotNil(t, later)
+	assert.Equal(t, ical.MethodCancel, later.Method)
+	assert.Equal(t, event.UID, later.Events[0].UID, "a cancellation replaces the invitation")
+	assert.True(t, later.Events[0].Sequence > event.Sequence)
+
+	params.Changed = now.Add(-time.Hour)
+	late := Invite(BookingUpdated, Booking, params, message, Organizer, now.Add(time.Hour))
+	require.NotNil(t, late)
+	assert.Equal(t, event.UID, late.Events[0].UID)
+	assert.True(t, late.Events[0].Sequence < event.Sequence, "a late email counts from when the booking changed")
+
+	series := SampleParams()
+	series.Occurrences = nil
+	for i, id := range []string{"o1", "o2"} {
+		series.AddOccurrence(id, series.Start.AddDate(0, 0, 7*i))
+	}
+	invite = Invite(SeriesConfirmed, OfferingSeries, series, message, Organizer, now)
+	require.NotNil(t, invite)
+	require.Len(t, invite.Events, 2)
+	assert.Equal(t, "offering-o2@iwork", invite.Events[1].UID)
+	assert.Equal(t, series.Start.AddDate(0, 0, 7), invite.Events[1].Start)
+	assert.Equal(t, series.End.AddDate(0, 0, 7), invite.Events[1].End)
+	assert.True(t, invite.Events[1].Free, "offered workspaces don't make their owner busy")
+
+	assert.Nil(t, Invite(WaitlistHeld, Waitlist, params, message, Organizer, now))
+	assert.Nil(t, Invite(BookingConfirmed, Booking, SampleParams(), message, Organizer, now), "no id, no event")
+}
+
+func TestMIMEMessage(t *testing.T) {
+	now := time.Date(2021, time.February, 1, 0, 0, 0, 0, time.UTC)
+	message := &Message{Subject: "Réservation", Text: "text", HTML: "<p>text</p>"}
+	params := SampleParams()
+	params.ID = "b1"
+	invite := Invite(BookingConfirmed, Booking, params, message, Organizer, now)
+	to := &ical.Person{Name: params.Name, Email: params.Email}
+
+	parsed, err := netmail.ReadMessage(strings.NewReader(string(MIMEMessage(Organizer, to, message, invite, now))))
+	require.NoError(t, err)
+	assert.Equal(t, `"Jane Doe" <jane.doe@example.com>`, parsed.Header.Get("To"))
+	assert.Contains(t, parsed.Header.Get("Content-Type"), "multipart/mixed")
+	body, err := ioutil.ReadAll(parsed.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "Content-Type: text/calendar; charset=utf-8; method=REQUEST\r\n")
+	assert.Contains(t, string(body), "Content-Disposition: attachment; filename="+InviteFile)
+
+	parsed, err = netmail.ReadMessage(strings.NewReader(string(MIMEMessage(Organizer, to, message, nil, now))))
+	require.NoError(t, err)
+	assert.Contains(t, parsed.Header.Get("Content-Type"), "multipart/alternative")
+	body, err = ioutil.ReadAll(parsed.Body)
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), "text/calendar")
+}
@@ -5,6 +5,8 @@ import (
 )
 
 type EmailParams struct {
+	// ID is the booking or offering the email is about, which identifies its calendar event
+	ID            string
 	Name          string
 	Email         string
 	WorkspaceName string
@@ -12,9 +14,48 @@ type EmailParams struct {
 	FloorAddress  string
 	Start         time.Time
 	End           time.Time
+	// Occurrences holds the start of every occurrence when the email is about a whole series, and OccurrenceIDs
+	// their ids; see AddOccurrence
+	Occurrences   []time.Time
+	OccurrenceIDs []string
+	// Changed is when the booking or offering became what the email says. The sequence of its calendar event counts
+	// from it, so an email sent late, e.g. retried or replayed, doesn't replace a newer one; zero means when it is sent
+	Changed time.Time
+	// HoldExpires is when a workspace held for a waitlisted user is released
+	HoldExpires time.Time
+	// Locale and TimeZone are the recipient's, see model.User; empty means the defaults of the Templates
+	Locale   string
+	TimeZone string
+	// Items are the bookings and offerings listed in a digest, which covers Start to End
+	Items []*DigestItem
+	// Role is why the recipient hears about a booking that isn't theirs, RoleCreator or RoleOwner, and BookerName
+	// the name of the user it is for; Role is empty for the booker
+	Role       string
+	BookerName string
+}
+
+// DigestItem is a booking or offering listed in a digest; Type is Booking or Offering
+type DigestItem struct {
+	ID            string
+	Type          string
+	WorkspaceName string
+	FloorName     string
+	FloorAddress  string
+	Start         time.Time
+	End           time.Time
+}
+
+// AddOccurrence adds the occurrence with id starting at start to the series the email is about
+func (p *EmailParams) AddOccurrence(id string, start time.Time) {
+	p.OccurrenceIDs = append(p.OccurrenceIDs, id)
+	p.Occurrences = append(p.Occurrences, start)
 }
 
 type EmailClient interface {
 	SendConfirmation(typeS string, params *EmailParams) error
 	SendCancellation(typeS string, params *EmailParams) error
+	SendUpdate(typeS string, params *EmailParams) error
+	SendHold(typeS string, params *EmailParams) error
+	SendReminder(typeS string, params *EmailParams) error
+	SendDigest(params *EmailParams) error
 }
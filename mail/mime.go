@@ -0,0 +1,74 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"go-api/ical"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"net/textproto"
+	"time"
+)
+
+// InviteFile is the name of the invitation attached to emails
+const InviteFile = "invite.ics"
+
+// MIMEMessage is message as a MIME email from from to to. The text and html are alternatives; an invite is a
+// third alternative, which calendar aware clients show as an invitation, and an invite.ics attachment for the others
+func MIMEMessage(from, to *ical.Person, message *Message, invite *ical.Calendar, now time.Time) []byte {
+	content := new(bytes.Buffer)
+	header := func(name, value string) { fmt.Fprintf(content, "%s: %s\r\n", name, value) }
+	header("From", (&netmail.Address{Name: from.Name, Address: from.Email}).String())
+	header("To", (&netmail.Address{Name: to.Name, Address: to.Email}).String())
+	header("Subject", mime.QEncoding.Encode("utf-8", message.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+
+	alternatives := new(bytes.Buffer)
+	alternative := multipart.NewWriter(alternatives)
+	writeQuotedPrintable(alternative, "text/plain; charset=utf-8", []byte(message.Text))
+	writeQuotedPrintable(alternative, "text/html; charset=utf-8", []byte(message.HTML))
+	if invite != nil {
+		writeQuotedPrintable(alternative, invite.MediaType(), invite.Bytes())
+	}
+	alternative.Close()
+	alternativeType := "multipart/alternative; boundary=" + alternative.Boundary()
+	if invite == nil {
+		header("Content-Type", alternativeType)
+		content.WriteString("\r\n")
+		content.Write(alternatives.Bytes())
+		return content.Bytes()
+	}
+
+	mixed := multipart.NewWriter(content)
+	header("Content-Type", "multipart/mixed; boundary="+mixed.Boundary())
+	content.WriteString("\r\n")
+	part, _ := mixed.CreatePart(textproto.MIMEHeader{"Content-Type": {alternativeType}})
+	part.Write(alternatives.Bytes())
+	part, _ = mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {invite.MediaType() + "; name=" + InviteFile},
+		"Content-Disposition":       {"attachment; filename=" + InviteFile},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	encoded := base64.StdEncoding.EncodeToString(invite.Bytes())
+	for len(encoded) > 76 {
+		fmt.Fprintf(part, "%s\r\n", encoded[:76])
+		encoded = encoded[76:]
+	}
+	fmt.Fprintf(part, "%s\r\n", encoded)
+	mixed.Close()
+	return content.Bytes()
+}
+
+func writeQuotedPrintable(w *multipart.Writer, contentType string, body []byte) {
+	part, _ := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	qp := quotedprintable.NewWriter(part)
+	qp.Write(body)
+	qp.Close()
+}
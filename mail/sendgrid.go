@@ -1,69 +1,84 @@
 package mail
 
 import (
+	"encoding/base64"
 	"errors"
-	"fmt"
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
 	"log"
 	"os"
+	"time"
 )
 
 type SendGridClient struct {
-	client *sendgrid.Client
+	client    *sendgrid.Client
+	templates *Templates
 }
 
 const Booking = "booking"
 const Offering = "offering"
+const BookingSeries = "booking series"
+const OfferingSeries = "offering series"
+const Waitlist = "waitlist"
+const DigestType = "digest"
 const IWorkUserName = "IWork"
 const IWorkEmail = "cs319.icbc@outlook.com"
-const EmailBody = `
-	You can manage your bookings and offerings under the manage tab at <a href="http://icbc-iwork-staging.herokuapp.com/">http://icbc-iwork-staging.herokuapp.com/</a>
-	Please note that cancelling this invite wont cancel this action. Please contact an admin in this scenario
-`
 
 func (c *SendGridClient) SendConfirmation(typeS string, params *EmailParams) error {
-	from := mail.NewEmail(IWorkUserName, IWorkEmail)
-	subject := fmt.Sprintf("Workspace %s confirmed", typeS)
-	to := mail.NewEmail(params.Name, IWorkEmail)
-	plainTextContent := fmt.Sprintf(
-		"Your %s for workspace %s on floor %s for the duration of %s to %s has now been confirmed. \n%s",
-		typeS, params.WorkspaceName, params.FloorName, params.Start, params.End, EmailBody,
-	)
-	htmlContent := plainTextContent
-	message := mail.NewSingleEmail(from, subject, to, plainTextContent, htmlContent)
-	_, err := c.client.Send(message)
-	if err != nil {
-		log.Printf("SendGrid.SendConfirmation: failed to send email: %+v", err)
-		return err
-	}
-	return nil
+	return c.send(ConfirmationEvent(typeS, params), typeS, params)
 }
 
 func (c *SendGridClient) SendCancellation(typeS string, params *EmailParams) error {
-	from := mail.NewEmail(IWorkUserName, IWorkEmail)
-	subject := fmt.Sprintf("Workspace %s cancelled", typeS)
+	return c.send(CancellationEvent(typeS, params), typeS, params)
+}
+
+func (c *SendGridClient) SendUpdate(typeS string, params *EmailParams) error {
+	return c.send(BookingUpdated, typeS, params)
+}
+
+func (c *SendGridClient) SendHold(typeS string, params *EmailParams) error {
+	return c.send(WaitlistHeld, typeS, params)
+}
+
+func (c *SendGridClient) SendReminder(typeS string, params *EmailParams) error {
+	return c.send(Reminder, typeS, params)
+}
+
+func (c *SendGridClient) SendDigest(params *EmailParams) error {
+	return c.send(Digest, DigestType, params)
+}
+
+func (c *SendGridClient) send(event, typeS string, params *EmailParams) error {
+	content, err := c.templates.Render(event, typeS, params)
+	if err != nil {
+		return err
+	}
+	from := mail.NewEmail(Organizer.Name, Organizer.Email)
 	to := mail.NewEmail(params.Name, params.Email)
-	plainTextContent := fmt.Sprintf(
-		"Your %s for workspace %s on floor %s for the duration of %s to %s has now been confirmed. \n%s",
-		typeS, params.WorkspaceName, params.FloorName, params.Start, params.End, EmailBody,
-	)
-	htmlContent := plainTextContent
-	message := mail.NewSingleEmail(from, subject, to, plainTextContent, htmlContent)
-	_, err := c.client.Send(message)
+	message := mail.NewSingleEmail(from, content.Subject, to, content.Text, content.HTML)
+	if invite := Invite(event, typeS, params, content, Organizer, time.Now()); invite != nil {
+		attachment := mail.NewAttachment()
+		attachment.SetContent(base64.StdEncoding.EncodeToString(invite.Bytes()))
+		attachment.SetType(invite.MediaType())
+		attachment.SetFilename(InviteFile)
+		attachment.SetDisposition("attachment")
+		message.AddAttachment(attachment)
+	}
+	_, err = c.client.Send(message)
 	if err != nil {
-		log.Printf("SendGrid.SendConfirmation: failed to send email: %+v", err)
+		log.Printf("SendGrid.send: failed to send %s email: %+v", event, err)
 		return err
 	}
 	return nil
 }
 
-func NewSendGridClient() (EmailClient, error) {
+func NewSendGridClient(templates *Templates) (EmailClient, error) {
 	apiKey := os.Getenv("SENDGRID_API_KEY")
 	if apiKey == "" {
 		return nil, errors.New("API Key missing")
 	}
 	return &SendGridClient{
-		client: sendgrid.NewSendClient(apiKey),
+		client:    sendgrid.NewSendClient(apiKey),
+		templates: templates,
 	}, nil
 }
@@ -0,0 +1,389 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// The events there are email templates for
+const (
+	BookingConfirmed  = "booking_confirmed"
+	BookingCancelled  = "booking_cancelled"
+	BookingUpdated    = "booking_updated"
+	OfferingConfirmed = "offering_confirmed"
+	OfferingCancelled = "offering_cancelled"
+	SeriesConfirmed   = "series_confirmed"
+	SeriesCancelled   = "series_cancelled"
+	WaitlistHeld      = "waitlist_held"
+	Reminder          = "reminder"
+	Digest            = "digest"
+
+	// The events of the emails to the other parties of a booking, see EmailParams.Role
+	BookingConfirmedCreator = "booking_confirmed_creator"
+	BookingCancelledCreator = "booking_cancelled_creator"
+	BookingConfirmedOwner   = "booking_confirmed_owner"
+	BookingCancelledOwner   = "booking_cancelled_owner"
+)
+
+// The roles of the other parties of a booking
+const (
+	RoleCreator = "creator"
+	RoleOwner   = "owner"
+)
+
+// Events is every event there is a template for
+var Events = []string{
+	BookingConfirmed,
+	BookingCancelled,
+	BookingUpdated,
+	OfferingConfirmed,
+	OfferingCancelled,
+	SeriesConfirmed,
+	SeriesCancelled,
+	WaitlistHeld,
+	Reminder,
+	Digest,
+	BookingConfirmedCreator,
+	BookingCancelledCreator,
+	BookingConfirmedOwner,
+	BookingCancelledOwner,
+}
+
+const (
+	DefaultLocale   = "en"
+	DefaultTimeZone = "America/Los_Angeles"
+)
+
+var localePattern = regexp.MustCompile(`^[a-zA-Z]{2,8}(-[a-zA-Z0-9]{1,8})*$`)
+
+func IsEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// IsLocale reports whether locale is a BCP 47 style language tag such as "en" or "fr-CA"
+func IsLocale(locale string) bool {
+	return localePattern.MatchString(locale)
+}
+
+// ConfirmationEvent is the event of a confirmation sent by SendConfirmation
+func ConfirmationEvent(typeS string, params *EmailParams) string {
+	switch {
+	case params.Role == RoleCreator:
+		return BookingConfirmedCreator
+	case params.Role == RoleOwner:
+		return BookingConfirmedOwner
+	case len(params.Occurrences) > 0:
+		return SeriesConfirmed
+	case typeS == Offering:
+		return OfferingConfirmed
+	}
+	return BookingConfirmed
+}
+
+// CancellationEvent is the event of a cancellation sent by SendCancellation
+func CancellationEvent(typeS string, params *EmailParams) string {
+	switch {
+	case params.Role == RoleCreator:
+		return BookingCancelledCreator
+	case params.Role == RoleOwner:
+		return BookingCancelledOwner
+	case len(params.Occurrences) > 0:
+		return SeriesCancelled
+	case typeS == Offering:
+		return OfferingCancelled
+	}
+	return BookingCancelled
+}
+
+// Template is the content of an email. Subject and Text are text/template templates and HTML is an html/template
+// template; an empty HTML is made from Text
+type Template struct {
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html"`
+}
+
+// Message is a rendered Template
+type Message struct {
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html"`
+}
+
+// Source looks up the template of an event in exactly locale. It returns nil if it has none, so that the next
+// source, then the next locale, is tried
+type Source interface {
+	Template(event, locale string) (*Template, error)
+}
+
+// DirSource reads templates from <dir>/<locale>/<event>.subject, .txt and .html; the .html file is optional
+type DirSource string
+
+func (d DirSource) Template(event, locale string) (*Template, error) {
+	if !IsEvent(event) || !IsLocale(locale) {
+		return nil, nil
+	}
+	base := filepath.Join(string(d), locale, event)
+	subject, err := ioutil.ReadFile(base + ".subject")
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	text, err := ioutil.ReadFile(base + ".txt")
+	if err != nil {
+		return nil, err
+	}
+	html, err := ioutil.ReadFile(base + ".html")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return &Template{Subject: string(subject), Text: string(text), HTML: string(html)}, nil
+}
+
+// builtinSource holds the templates in defaults.go
+type builtinSource map[string]map[string]*Template
+
+func (b builtinSource) Template(event, locale string) (*Template, error) {
+	return b[locale][event], nil
+}
+
+type TemplateConfig struct {
+	// AppURL is the web app, linked from every email; empty leaves the link out
+	AppURL string
+	// DefaultLocale is used for users without a locale and when there is no template in theirs; empty means
+	// DefaultLocale
+	DefaultLocale string
+	// DefaultTimeZone is the zone times are shown in for users without one; empty means DefaultTimeZone
+	DefaultTimeZone string
+	// Dir holds templates that override the built in ones, laid out as DirSource expects; empty means none
+	Dir string
+}
+
+// Templates renders the emails of every EmailClient, in the locale and time zone of the user they are sent to
+type Templates struct {
+	sources []Source
+	appURL  string
+	locale  string
+	zone    *time.Location
+}
+
+// NewTemplates renders with the first template found in sources, then in config.Dir, then the built in ones
+func NewTemplates(config *TemplateConfig, sources ...Source) (*Templates, error) {
+	t := &Templates{
+		appURL: strings.TrimRight(config.AppURL, "/"),
+		locale: config.DefaultLocale,
+	}
+	if t.locale == "" {
+		t.locale = DefaultLocale
+	}
+	if !IsLocale(t.locale) {
+		return nil, fmt.Errorf("invalid default locale %q", t.locale)
+	}
+	zone := config.DefaultTimeZone
+	if zone == "" {
+		zone = DefaultTimeZone
+	}
+	var err error
+	if t.zone, err = time.LoadLocation(zone); err != nil {
+		return nil, err
+	}
+	t.sources = append(t.sources, sources...)
+	if config.Dir != "" {
+		t.sources = append(t.sources, DirSource(config.Dir))
+	}
+	t.sources = append(t.sources, builtin)
+	return t, nil
+}
+
+// templateData is what templates are executed against: the email's parameters and the links every email can use
+type templateData struct {
+	*EmailParams
+	// Type is what the email is about, e.g. Booking or OfferingSeries
+	Type   string
+	AppURL string
+	// MapURL searches for the floor's address on Google Maps; empty if the floor has none
+	MapURL string
+}
+
+// Render renders the template of event for typeS in the locale of params, falling back to its language, e.g.
+// "fr" for "fr-CA", then to the default locale. Times are shown in the time zone of params or the default one
+func (t *Templates) Render(event, typeS string, params *EmailParams) (*Message, error) {
+	template, locale, err := t.lookup(event, params.Locale)
+	if err != nil {
+		return nil, err
+	}
+	return t.render(template, locale, typeS, params)
+}
+
+// RenderTemplate renders template as if it were the template of params' locale, without looking anything up
+func (t *Templates) RenderTemplate(template *Template, typeS string, params *EmailParams) (*Message, error) {
+	locale := params.Locale
+	if locale == "" {
+		locale = t.locale
+	}
+	return t.render(template, locale, typeS, params)
+}
+
+func (t *Templates) lookup(event, locale string) (*Template, string, error) {
+	if !IsEvent(event) {
+		return nil, "", fmt.Errorf("unknown email event %q", event)
+	}
+	for _, l := range t.locales(locale) {
+		for _, source := range t.sources {
+			template, err := source.Template(event, l)
+			if err != nil {
+				return nil, "", err
+			}
+			if template != nil {
+				return template, l, nil
+			}
+		}
+	}
+	return nil, "", fmt.Errorf("no %s template for locale %q", event, locale)
+}
+
+// locales is locale and its parents, then the default locale and its parents
+func (t *Templates) locales(locale string) []string {
+	locales := make([]string, 0)
+	for _, l := range []string{locale, t.locale} {
+		for l != "" {
+			if IsLocale(l) && !contains(locales, l) {
+				locales = append(locales, l)
+			}
+			i := strings.LastIndex(l, "-")
+			if i < 0 {
+				break
+			}
+			l = l[:i]
+		}
+	}
+	return locales
+}
+
+// Zone is the time zone named timeZone, or the default one if it is empty or unknown
+func (t *Templates) Zone(timeZone string) *time.Location {
+	if timeZone != "" {
+		if zone, err := time.LoadLocation(timeZone); err == nil {
+			return zone
+		}
+	}
+	return t.zone
+}
+
+func (t *Templates) render(template *Template, locale, typeS string, params *EmailParams) (*Message, error) {
+	zone := t.Zone(params.TimeZone)
+	layout := dateLayouts[DefaultLocale]
+	for _, l := range t.locales(locale) {
+		if dateLayouts[l] != "" {
+			layout = dateLayouts[l]
+			break
+		}
+	}
+	funcs := map[string]interface{}{
+		"date": func(date time.Time) string { return date.In(zone).Format(layout) },
+	}
+	data := &templateData{EmailParams: params, Type: typeS, AppURL: t.appURL}
+	if params.FloorAddress != "" {
+		data.MapURL = "https://www.google.com/maps/search/?api=1&query=" + url.PathEscape(params.FloorAddress)
+	}
+
+	var message Message
+	var err error
+	if message.Subject, err = executeText("subject", template.Subject, funcs, data); err != nil {
+		return nil, err
+	}
+	message.Subject = strings.Join(strings.Fields(message.Subject), " ")
+	if message.Text, err = executeText("text", template.Text, funcs, data); err != nil {
+		return nil, err
+	}
+	message.Text = strings.TrimSpace(message.Text)
+	if template.HTML == "" {
+		message.HTML = strings.Replace(htmltemplate.HTMLEscapeString(message.Text), "\n", "<br>\n", -1)
+		return &message, nil
+	}
+	parsed, err := htmltemplate.New("html").Funcs(funcs).Parse(template.HTML)
+	if err != nil {
+		return nil, err
+	}
+	content := new(bytes.Buffer)
+	if err = parsed.Execute(content, data); err != nil {
+		return nil, err
+	}
+	message.HTML = strings.TrimSpace(content.String())
+	return &message, nil
+}
+
+func executeText(name, text string, funcs map[string]interface{}, data interface{}) (string, error) {
+	parsed, err := texttemplate.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	content := new(bytes.Buffer)
+	if err = parsed.Execute(content, data); err != nil {
+		return "", err
+	}
+	return content.String(), nil
+}
+
+// Validate parses template, so that a template with a syntax error is refused before it is stored
+func Validate(template *Template) error {
+	if strings.TrimSpace(template.Subject) == "" || strings.TrimSpace(template.Text) == "" {
+		return errors.New("a template needs a subject and a text")
+	}
+	funcs := map[string]interface{}{"date": func(time.Time) string { return "" }}
+	if _, err := texttemplate.New("subject").Funcs(funcs).Parse(template.Subject); err != nil {
+		return err
+	}
+	if _, err := texttemplate.New("text").Funcs(funcs).Parse(template.Text); err != nil {
+		return err
+	}
+	_, err := htmltemplate.New("html").Funcs(funcs).Parse(template.HTML)
+	return err
+}
+
+// SampleParams are made up parameters to preview templates with
+func SampleParams() *EmailParams {
+	start := time.Date(2021, time.March, 1, 17, 0, 0, 0, time.UTC)
+	return &EmailParams{
+		Name:          "Jane Doe",
+		Email:         "jane.doe@example.com",
+		WorkspaceName: "W-001",
+		FloorName:     "West 2nd Avenue",
+		FloorAddress:  "151 W 2nd Ave, Vancouver, BC",
+		Start:         start,
+		End:           start.Add(8 * time.Hour),
+		Occurrences:   []time.Time{start, start.AddDate(0, 0, 7), start.AddDate(0, 0, 14)},
+		HoldExpires:   start.Add(-16 * time.Hour),
+		BookerName:    "John Roe",
+		Items: []*DigestItem{
+			{Type: Booking, WorkspaceName: "W-001", FloorName: "West 2nd Avenue", Start: start, End: start.Add(8 * time.Hour)},
+			{Type: Offering, WorkspaceName: "W-002", FloorName: "West 2nd Avenue", Start: start.AddDate(0, 0, 2), End: start.AddDate(0, 0, 4)},
+		},
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package mail
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type mapSource map[string]*Template
+
+func (m mapSource) Template(event, locale string) (*Template, error) {
+	return m[locale+"/"+event], nil
+}
+
+func TestTemplates(t *testing.T) {
+	templates, err := NewTemplates(&TemplateConfig{AppURL: "https://iwork.example.com/"})
+	require.NoError(t, err)
+	params := SampleParams()
+
+	message, err := templates.Render(BookingConfirmed, Booking, params)
+	require.NoError(t, err)
+	assert.Equal(t, "booking for W-001 at West 2nd Avenue", message.Subject)
+	assert.Contains(t, message.Text, "Monday 01 Mar 21 09:00 to Monday 01 Mar 21 17:00", "shown in the default zone")
+	assert.Contains(t, message.Text, "https://iwork.example.com")
+	assert.NotContains(t, message.Text, "herokuapp")
+	assert.Contains(t, message.HTML, `<a href="https://www.google.com/maps/search/?api=1&amp;query=151%20W%202nd%20Ave%2C%20Vancouver%2C%20BC">`)
+
+	params.Locale, params.TimeZone = "fr-CA", "America/Toronto"
+	message, err = templates.Render(BookingCancelled, Booking, params)
+	require.NoError(t, err)
+	assert.Equal(t, "Annulation de la réservation de W-001", message.Subject, "fr-CA falls back to fr")
+	assert.Contains(t, message.Text, "du 01/03/2021 12:00 au 01/03/2021 20:00")
+
+	message, err = templates.Render(SeriesConfirmed, OfferingSeries, params)
+	require.NoError(t, err)
+	assert.Contains(t, message.Text, "Votre série d'offres")
+	assert.Contains(t, message.Text, "08/03/2021 12:00\n15/03/2021 13:00", "after the change to daylight saving time")
+
+	params.Locale, params.TimeZone = "de", "Nowhere/Nothing"
+	message, err = templates.Render(WaitlistHeld, Waitlist, params)
+	require.NoError(t, err)
+	assert.Equal(t, "waitlist: W-001 is held for you", message.Subject, "unknown locales fall back to the default")
+	assert.Contains(t, message.Text, "until Sunday 28 Feb 21 17:00", "unknown zones fall back to the default")
+
+	params.Locale, params.TimeZone = "fr", ""
+	message, err = templates.Render(Digest, DigestType, params)
+	require.NoError(t, err)
+	assert.Contains(t, message.Text, "Réservation du poste W-001")
+	assert.Contains(t, message.Text, "Offre du poste W-002 à l'étage West 2nd Avenue du 03/03/2021 09:00 au 05/03/2021 09:00")
+	assert.Contains(t, message.HTML, "<li>Offre du poste <strong>W-002</strong>")
+
+	params.Role, params.Occurrences = RoleOwner, nil
+	message, err = templates.Render(ConfirmationEvent(Booking, params), Booking, params)
+	require.NoError(t, err)
+	assert.Equal(t, "John Roe a réservé votre poste W-001", message.Subject)
+	assert.Contains(t, message.HTML, "<strong>John Roe</strong> a réservé votre poste")
+	params.Role, params.Locale = RoleCreator, "en"
+	message, err = templates.Render(CancellationEvent(Booking, params), Booking, params)
+	require.NoError(t, err)
+	assert.Equal(t, "booking cancelled for John Roe at W-001", message.Subject)
+	assert.Contains(t, message.Text, "Your booking for John Roe of workspace W-001")
+
+	_, err = templates.Render("birthday", Booking, params)
+	assert.Error(t, err)
+	_, err = NewTemplates(&TemplateConfig{DefaultTimeZone: "Nowhere/Nothing"})
+	assert.Error(t, err)
+}
+
+func TestTemplateSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "en"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "en", Reminder+".subject"), []byte("Don't forget {{.WorkspaceName}}\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "en", Reminder+".txt"), []byte("<{{.Name}}>\nsee you"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "en", BookingConfirmed+".subject"), []byte("From disk"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "en", BookingConfirmed+".txt"), []byte("From disk"), 0644))
+
+	stored := mapSource{"en/" + BookingConfirmed: {Subject: "From the database", Text: "{{.Type}}"}}
+	templates, err := NewTemplates(&TemplateConfig{Dir: dir}, stored)
+	require.NoError(t, err)
+
+	message, err := templates.Render(Reminder, Booking, SampleParams())
+	require.NoError(t, err)
+	assert.Equal(t, "Don't forget W-001", message.Subject)
+	assert.Equal(t, "&lt;Jane Doe&gt;<br>\nsee you", message.HTML, "the html is made from the text when there is none")
+
+	message, err = templates.Render(BookingConfirmed, Booking, SampleParams())
+	require.NoError(t, err)
+	assert.Equal(t, "From the database", message.Subject, "stored templates come first")
+
+	message, err = templates.Render(BookingCancelled, Booking, SampleParams())
+	require.NoError(t, err)
+	assert.Equal(t, "booking cancellation for W-001", message.Subject, "built in templates come last")
+
+	message, err = templates.RenderTemplate(&Template{Subject: "{{.Name}}", Text: "-", HTML: "<b>{{.Name}}</b> {{.AppURL}}"}, Booking, &EmailParams{Name: "<Jane>"})
+	require.NoError(t, err)
+	assert.Equal(t, "<Jane>", message.Subject)
+	assert.Equal(t, "<b>&lt;Jane&gt;</b>", message.HTML)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(&Template{Subject: "{{.Type}}", Text: "{{date .Start}}"}))
+	assert.Error(t, Validate(&Template{Subject: "{{.Type", Text: "-"}))
+	assert.Error(t, Validate(&Template{Subject: "-", Text: "-", HTML: "{{end}}"}))
+	assert.Error(t, Validate(&Template{Subject: "-"}), "a text is required")
+	for _, event := range Events {
+		for locale, templates := range builtin {
+			if assert.NotNil(t, templates[event], "%s %s", locale, event) {
+				assert.NoError(t, Validate(templates[event]), "%s %s", locale, event)
+			}
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"go-api/blob"
+	"go-api/db/memory"
 	"go-api/routes"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func main() {
@@ -13,18 +18,84 @@ func main() {
 		log.Println("Did not find env var PORT, defaulting to 8080")
 	}
 	dbUrl := os.Getenv("DATABASE_URL")
-	gDriveCredentials := os.Getenv("G_DRIVE_CREDENTIALS")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(dbUrl, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if os.Getenv("SKIP_MIGRATIONS") == "" && !strings.HasPrefix(dbUrl, memory.Scheme) {
+		if err := migrateOnStart(dbUrl); err != nil {
+			log.Println("Failed to migrate database")
+			log.Fatal(err)
+		}
+	}
+	blobConfig := blob.Config{
+		Backend:           os.Getenv("BLOB_STORE"),
+		LocalDir:          os.Getenv("BLOB_DIR"),
+		S3Endpoint:        os.Getenv("S3_ENDPOINT"),
+		S3Region:          os.Getenv("S3_REGION"),
+		S3Bucket:          os.Getenv("S3_BUCKET"),
+		S3AccessKey:       os.Getenv("S3_ACCESS_KEY_ID"),
+		S3SecretKey:       os.Getenv("S3_SECRET_ACCESS_KEY"),
+		GDriveCredentials: os.Getenv("G_DRIVE_CREDENTIALS"),
+	}
+	msTenantId := os.Getenv("MICROSOFT_TENANT_ID")
 	msClientId := os.Getenv("MICROSOFT_CLIENT_ID")
 	msGraphScope := os.Getenv("MICROSOFT_GRAPH_SCOPE")
 	msClientSecret := os.Getenv("MICROSOFT_CLIENT_SECRET")
 	adminUserId := os.Getenv("ADMIN_USER_ID")
+	var waitlistHold time.Duration
+	if minutes, err := strconv.Atoi(os.Getenv("WAITLIST_HOLD_MINUTES")); err == nil {
+		waitlistHold = time.Duration(minutes) * time.Minute
+	}
+	var checkInGrace time.Duration
+	if minutes, err := strconv.Atoi(os.Getenv("CHECKIN_GRACE_MINUTES")); err == nil {
+		checkInGrace = time.Duration(minutes) * time.Minute
+	}
+	var archiveRetention time.Duration
+	if days, err := strconv.Atoi(os.Getenv("ARCHIVE_RETENTION_DAYS")); err == nil {
+		archiveRetention = time.Duration(days) * 24 * time.Hour
+	}
+	archiveDryRun, _ := strconv.ParseBool(os.Getenv("ARCHIVE_DRY_RUN"))
+	outboxMaxAttempts, _ := strconv.Atoi(os.Getenv("OUTBOX_MAX_ATTEMPTS"))
+	webhookMaxAttempts, _ := strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS"))
+	var webhookDisableAfter time.Duration
+	if hours, err := strconv.Atoi(os.Getenv("WEBHOOK_DISABLE_AFTER_HOURS")); err == nil {
+		webhookDisableAfter = time.Duration(hours) * time.Hour
+	}
 	app := routes.NewApp(&routes.AppConfig{
 		DbUrl:          dbUrl,
-		GDriveConfig:   gDriveCredentials,
+		PublicUrl:      os.Getenv("PUBLIC_URL"),
+		BlobConfig:     blobConfig,
+		MsTenantId:     msTenantId,
 		MsClientId:     msClientId,
 		MsScope:        msGraphScope,
 		MsClientSecret: msClientSecret,
+		MsTokenUrl:     os.Getenv("MICROSOFT_TOKEN_URL"),
+		MsGraphUrl:     os.Getenv("MICROSOFT_GRAPH_URL"),
 		AdminUserId:    adminUserId,
+		WaitlistHold:   waitlistHold,
+		CheckInGrace:   checkInGrace,
+
+		ArchiveSchedule:  os.Getenv("ARCHIVE_SCHEDULE"),
+		ArchiveRetention: archiveRetention,
+		ArchiveDryRun:    archiveDryRun,
+		ArchiveFormat:    os.Getenv("ARCHIVE_FORMAT"),
+
+		OutboxMaxAttempts:   outboxMaxAttempts,
+		WebhookMaxAttempts:  webhookMaxAttempts,
+		WebhookDisableAfter: webhookDisableAfter,
+
+		AppUrl:          os.Getenv("APP_URL"),
+		TemplateDir:     os.Getenv("MAIL_TEMPLATE_DIR"),
+		DefaultLocale:   os.Getenv("DEFAULT_LOCALE"),
+		DefaultTimeZone: os.Getenv("DEFAULT_TIME_ZONE"),
+
+		CalendarFeedSecret: os.Getenv("CALENDAR_FEED_SECRET"),
+
+		Reminders:      os.Getenv("REMINDERS"),
+		DigestSchedule: os.Getenv("DIGEST_SCHEDULE"),
 	})
 	defer app.Close()
 	err := app.Setup(port)
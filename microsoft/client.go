@@ -2,305 +2,304 @@ package microsoft
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"go-api/ical"
 	"go-api/mail"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 type ADClient struct {
-	clientId      string
-	scope         string
-	clientSecret  string
-	accessToken   string
-	adminUserId   string
-	defaultClient *http.Client
+	clientId     string
+	scope        string
+	clientSecret string
+	adminUserId  string
+	tokenUrl     string
+	graphUrl     string
+	httpClient   *http.Client
+	templates    *mail.Templates
+	// organizer is the admin's mailbox, which every email is sent from
+	organizer *ical.Person
+	// sleep waits out throttling; tests replace it
+	sleep func(time.Duration)
+
+	// mu guards the access token, which concurrent requests share, and when it expires
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
 }
 
 func (c *ADClient) SendConfirmation(typeS string, params *mail.EmailParams) error {
-	loc, err := time.LoadLocation("America/Los_Angeles")
-	if err != nil {
-		return err
-	}
-	inviteContent := fmt.Sprintf(
-		`Your %s for workspace <strong>%s</strong> on floor <strong>%s</strong>
-					for the duration of <strong>%s</strong> to <strong>%s</strong> has now been confirmed. 
-					<br>%s
-					`,
-		typeS, params.WorkspaceName,
-		params.FloorName,
-		params.Start.In(loc).Format("Monday 02 Jan 06 15:04"),
-		params.End.In(loc).Format("Monday 02 Jan 06 15:04"),
-		mail.EmailBody,
-	)
-	if typeS == mail.Booking {
-		inviteContent = fmt.Sprintf(`%s<br> <a href="%s">Google Map Link</a>`,
-			inviteContent,
-			fmt.Sprintf(
-				"https://www.google.com/maps/search/?api=1&query=%s",
-				url.PathEscape(params.FloorAddress),
-			),
-		)
-	}
-
-	return c.sendCalendarInvite(&CalendarInvite{
-		subject:   fmt.Sprintf("%s for %s at %s", typeS, params.WorkspaceName, params.FloorName),
-		content:   inviteContent,
-		startTime: params.Start,
-		endTime:   params.End,
-		location:  params.WorkspaceName,
-		attendees: []*Attendee{
-			{
-				email: params.Email,
-				name:  params.Name,
-			},
-		},
-	})
+	return c.send(mail.ConfirmationEvent(typeS, params), typeS, params)
 }
 
 func (c *ADClient) SendCancellation(typeS string, params *mail.EmailParams) error {
-	loc, err := time.LoadLocation("America/Los_Angeles")
-	if err != nil {
-		return err
-	}
-	cancellationContent := fmt.Sprintf(
-		"Your %s for workspace <strong>%s</strong> on floor <strong>%s</strong> for the duration of <strong>%s</strong> to <strong>%s</strong> has now been cancelled. \n%s",
-		typeS, params.WorkspaceName,
-		params.FloorName,
-		params.Start.In(loc).Format("Monday 02 Jan 06 15:04"),
-		params.End.In(loc).Format("Monday 02 Jan 06 15:04"),
-		mail.EmailBody,
-	)
-	return c.sendEmail(&EmailBody{
-		subject: fmt.Sprintf("%s cancellation for %s", typeS, params.WorkspaceName),
-		content: cancellationContent,
-		attendees: []*Attendee{
-			{
-				email: params.Email,
-				name:  params.Name,
-			},
-		},
-	})
+	return c.send(mail.CancellationEvent(typeS, params), typeS, params)
 }
 
-type Attendee struct {
-	email string
-	name  string
+// SendUpdate re-sends the invitation of the booking with the UID of its event and a higher sequence, so that
+// calendars move the event instead of adding another one
+func (c *ADClient) SendUpdate(typeS string, params *mail.EmailParams) error {
+	return c.send(mail.BookingUpdated, typeS, params)
 }
 
-type CalendarInvite struct {
-	subject   string
-	content   string
-	startTime time.Time
-	endTime   time.Time
-	location  string
-	attendees []*Attendee
+func (c *ADClient) SendHold(typeS string, params *mail.EmailParams) error {
+	return c.send(mail.WaitlistHeld, typeS, params)
 }
 
-type EmailBody struct {
-	subject   string
-	content   string
-	attendees []*Attendee
+func (c *ADClient) SendReminder(typeS string, params *mail.EmailParams) error {
+	return c.send(mail.Reminder, typeS, params)
 }
 
-const TokenUrl = "https://login.microsoftonline.com/de28de2e-eaf8-4937-a102-735e764a6e31/oauth2/v2.0/token"
-const GraphUrl = "https://graph.microsoft.com/v1.0"
+func (c *ADClient) SendDigest(params *mail.EmailParams) error {
+	return c.send(mail.Digest, mail.DigestType, params)
+}
 
-func buildCalendarInviteBody(invite *CalendarInvite) []byte {
-	//{
-	//	"attendees": [
-	//		{
-	//			"emailAddress": {
-	//				"address": "bruce@cs319iwork.onmicrosoft.com",
-	//				"name": "Bruce Wayne"
-	//			},
-	//			"type": "required"
-	//		}
-	//	],
-	//	"body": {
-	//		"content": "sad",
-	//		"contentType": "HTML"
-	//	},
-	//	"end": {
-	//		"dateTime": "2020-03-23T00:03:16",
-	//		"timeZone": "Pacific Standard Time"
-	//	},
-	//	"location": {
-	//		"displayName": "W-001"
-	//	},
-	//	"start": {
-	//		"dateTime": "2020-03-21T20:16:36",
-	//		"timeZone": "Pacific Standard Time"
-	//	},
-	//	"subject": "Booking confirmation for W-001 at West 2nd Avenue"
-	//}
-	body := make(map[string]interface{})
-	body["subject"] = invite.subject
-	body["body"] = map[string]interface{}{
-		"contentType": "HTML",
-		"content":     invite.content,
+// send emails the invitation of the booking or offering as a MIME message, rather than creating an event on the
+// admin's calendar, so that updates and cancellations reach the same event in the attendee's calendar
+func (c *ADClient) send(event, typeS string, params *mail.EmailParams) error {
+	message, err := c.templates.Render(event, typeS, params)
+	if err != nil {
+		return err
 	}
-	body["start"] = map[string]interface{}{
-		"dateTime": invite.startTime.Format("2006-01-02T15:04:05"),
-		"timeZone": "Pacific Standard Time",
+	now := time.Now()
+	invite := mail.Invite(event, typeS, params, message, c.organizer, now)
+	to := &ical.Person{Name: params.Name, Email: params.Email}
+	return c.sendMIME(mail.MIMEMessage(c.organizer, to, message, invite, now))
+}
+
+const DefaultTenantId = "de28de2e-eaf8-4937-a102-735e764a6e31"
+
+// TokenUrl and GraphUrl are where tokens are requested and Graph is reached unless Config says otherwise
+const TokenUrl = "https://login.microsoftonline.com/" + DefaultTenantId + "/oauth2/v2.0/token"
+const GraphUrl = "https://graph.microsoft.com/v1.0"
+
+const (
+	// tokenRefreshMargin is how long before it expires a token is replaced, so that requests don't race its expiry
+	tokenRefreshMargin = 5 * time.Minute
+	// maxThrottleRetries is how many times a throttled request is retried
+	maxThrottleRetries = 3
+	// defaultRetryAfter is the wait after a throttled response without a Retry-After
+	defaultRetryAfter = 5 * time.Second
+	// maxRetryAfter is the longest Retry-After waited out; a request asked to wait longer fails
+	maxRetryAfter = time.Minute
+)
+
+// Config is the app registration the client authenticates as and the mailbox it sends from
+type Config struct {
+	ClientId     string
+	Scope        string
+	ClientSecret string
+	// AdminUserId is the user whose mailbox every email is sent from
+	AdminUserId string
+	// TokenUrl is the OAuth token endpoint; empty means TokenUrl
+	TokenUrl string
+	// GraphUrl is the base URL of Microsoft Graph; empty means GraphUrl
+	GraphUrl string
+	// HTTPClient sends every request; nil means a client with a 30 second timeout
+	HTTPClient *http.Client
+}
+
+func NewADClient(config *Config, templates *mail.Templates) (*ADClient, error) {
+	client := &ADClient{
+		clientId:     config.ClientId,
+		scope:        config.Scope,
+		clientSecret: config.ClientSecret,
+		adminUserId:  config.AdminUserId,
+		tokenUrl:     config.TokenUrl,
+		graphUrl:     strings.TrimRight(config.GraphUrl, "/"),
+		httpClient:   config.HTTPClient,
+		templates:    templates,
+		sleep:        time.Sleep,
 	}
-	body["end"] = map[string]interface{}{
-		"dateTime": invite.endTime.Format("2006-01-02T15:04:05"),
-		"timeZone": "Pacific Standard Time",
+	if client.tokenUrl == "" {
+		client.tokenUrl = TokenUrl
 	}
-	body["location"] = map[string]interface{}{
-		"displayName": invite.location,
+	if client.graphUrl == "" {
+		client.graphUrl = GraphUrl
 	}
-	var attendees []interface{}
-	for _, a := range invite.attendees {
-		var attendee = make(map[string]interface{})
-		attendee["emailAddress"] = map[string]interface{}{
-			"address": a.email,
-			"name":    a.name,
-		}
-		attendee["type"] = "required"
-		attendees = append(attendees, attendee)
+	if client.httpClient == nil {
+		client.httpClient = &http.Client{Timeout: 30 * time.Second}
 	}
-	body["attendees"] = attendees
-	res, err := json.Marshal(body)
+	var err error
+	client.organizer, err = client.getOrganizer()
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	return res
+	return client, nil
 }
 
-func buildEmailBody(email *EmailBody) []byte {
-	//{
-	//	"message": {
-	//		"subject": "Meet for lunch Again?",
-	//		"body": {
-	//			"contentType": "Text",
-	//			"content": "The new cafeteria is open."
-	//		},
-	//		"toRecipients": [
-	//			{
-	//				"emailAddress": {
-	//					"address": "bruce@cs319iwork.onmicrosoft.com"
-	//				}
-	//			}
-	//		]
-	//	},
-	//	"saveToSentItems": "true"
-	//}
-	var attendees []interface{}
-	for _, a := range email.attendees {
-		var attendee = make(map[string]interface{})
-		attendee["emailAddress"] = map[string]interface{}{
-			"address": a.email,
-			"name":    a.name,
-		}
-		attendees = append(attendees, attendee)
-	}
-	body := make(map[string]interface{})
-	body["message"] = map[string]interface{}{
-		"subject": email.subject,
-		"body": map[string]interface{}{
-			"contentType": "Text",
-			"content":     email.content,
-		},
-		"toRecipients": attendees,
-	}
-	body["saveToSentItems"] = true
-
-	res, err := json.Marshal(body)
+// getOrganizer looks up the name and address of the admin's mailbox
+func (c *ADClient) getOrganizer() (*ical.Person, error) {
+	resp, err := c.do("GET", fmt.Sprintf("%s/users/%s?$select=displayName,mail", c.graphUrl, c.adminUserId), "", nil)
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	return res
-}
-
-func NewADClient(clientId, scope, clientSecret, adminUserId string) (*ADClient, error) {
-	client := &ADClient{
-		clientId:     clientId,
-		scope:        scope,
-		clientSecret: clientSecret,
-		adminUserId:  adminUserId,
-		defaultClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(fmt.Sprintf("failed to get the admin user, %d", resp.StatusCode))
 	}
-	err := client.RefreshToken()
-	if err != nil {
+	var user struct {
+		DisplayName string `json:"displayName"`
+		Mail        string `json:"mail"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&user); err != nil {
 		return nil, err
 	}
-	return client, nil
+	if user.Mail == "" {
+		return nil, errors.New("the admin user has no mailbox")
+	}
+	return &ical.Person{Name: user.DisplayName, Email: user.Mail}, nil
 }
 
+// RefreshToken replaces the cached access token with a new one
 func (c *ADClient) RefreshToken() error {
-	if err := c.Ping(); err == nil {
-		return nil
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.fetchToken()
+	return err
+}
+
+// token is the cached access token, or a new one if it expires within tokenRefreshMargin
+func (c *ADClient) token() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.accessToken != "" && time.Now().Add(tokenRefreshMargin).Before(c.expiresAt) {
+		return c.accessToken, nil
+	}
+	return c.fetchToken()
+}
+
+// forget drops token from the cache after Graph rejected it, unless another request already replaced it
+func (c *ADClient) forget(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.accessToken == token {
+		c.accessToken = ""
 	}
+}
+
+// fetchToken requests an access token with the client credentials and caches it until it expires; c.mu is held
+func (c *ADClient) fetchToken() (string, error) {
 	data := url.Values{}
 	data.Set("client_id", c.clientId)
 	data.Set("scope", c.scope)
 	data.Set("client_secret", c.clientSecret)
 	data.Set("grant_type", "client_credentials")
 
-	resp, err := http.Post(TokenUrl, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()))
+	requested := time.Now()
+	resp, err := c.httpClient.PostForm(c.tokenUrl, data)
 	if err != nil {
-		return err
+		return "", err
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return errors.New(fmt.Sprintf("incorrect response code while fetching token, received %d", resp.StatusCode))
+		return "", errors.New(fmt.Sprintf("incorrect response code while fetching token, received %d", resp.StatusCode))
 	}
-	defer resp.Body.Close()
-	reqBody, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
+	var body struct {
+		AccessToken string `json:"access_token"`
+		// ExpiresIn is how many seconds the token is valid for
+		ExpiresIn int64 `json:"expires_in"`
 	}
-	var body map[string]interface{}
-	err = json.Unmarshal(reqBody, &body)
-	accessToken := fmt.Sprintf("%v", body["access_token"])
-	if accessToken == "" {
-		return errors.New("no access token found")
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
 	}
-	c.accessToken = accessToken
-	return nil
+	if body.AccessToken == "" {
+		return "", errors.New("no access token found")
+	}
+	// the lifetime counts from when the token was requested, so that a slow response doesn't make it outlive itself
+	c.accessToken = body.AccessToken
+	c.expiresAt = requested.Add(time.Duration(body.ExpiresIn) * time.Second)
+	return c.accessToken, nil
 }
 
-func (c *ADClient) newRequest(method string, url string, body io.Reader) (*http.Request, error) {
-	req, err := http.NewRequest(method, url, body)
-	if err != nil {
-		return nil, err
+// do sends a request to Graph with the cached access token. A 401 gets a new token and is retried once; a 429 or
+// 503 is retried after its Retry-After, up to maxThrottleRetries times. The caller closes the response body
+func (c *ADClient) do(method, url, contentType string, body []byte) (*http.Response, error) {
+	refreshed := false
+	throttled := 0
+	for {
+		token, err := c.token()
+		if err != nil {
+			return nil, err
+		}
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequest(method, url, reader)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		switch resp.StatusCode {
+		case http.StatusUnauthorized:
+			if refreshed {
+				return resp, nil
+			}
+			resp.Body.Close()
+			c.forget(token)
+			refreshed = true
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			wait := retryAfter(resp.Header.Get("Retry-After"), time.Now())
+			if throttled == maxThrottleRetries || wait > maxRetryAfter {
+				return resp, nil
+			}
+			resp.Body.Close()
+			throttled++
+			c.sleep(wait)
+		default:
+			return resp, nil
+		}
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.accessToken))
-	return req, nil
 }
 
-func (c *ADClient) doRequest(method string, url string, body io.Reader) (*http.Response, error) {
-	req, err := c.newRequest(method, url, body)
-	if err != nil {
-		return nil, err
+// retryAfter is the wait a Retry-After header asks for, in seconds or as an HTTP date, at now
+func retryAfter(header string, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
 	}
-	req.Header.Set("Content-Type", "application/json")
-	return c.defaultClient.Do(req)
+	if at, err := http.ParseTime(header); err == nil {
+		if wait := at.Sub(now); wait > 0 {
+			return wait
+		}
+		return 0
+	}
+	return defaultRetryAfter
 }
 
+// Ping checks that Graph accepts the client's token
 func (c *ADClient) Ping() error {
-	resp, err := c.doRequest("GET", GraphUrl, nil)
+	resp, err := c.do("GET", c.graphUrl, "", nil)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return errors.New(fmt.Sprintf("token likely expired; statusCode=%d", resp.StatusCode))
+		return errors.New(fmt.Sprintf("graph is unreachable; statusCode=%d", resp.StatusCode))
 	}
 	return nil
 }
 
 func (c *ADClient) GetAllUsers() (map[string]interface{}, error) {
-	reqUrl := fmt.Sprintf("%s/users", GraphUrl)
-	resp, err := c.doRequest("GET", reqUrl, nil)
+	reqUrl := fmt.Sprintf("%s/users", c.graphUrl)
+	resp, err := c.do("GET", reqUrl, "", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -314,26 +313,11 @@ func (c *ADClient) GetAllUsers() (map[string]interface{}, error) {
 	return body, err
 }
 
-func (c *ADClient) sendCalendarInvite(invite *CalendarInvite) error {
-	_ = c.RefreshToken()
-	reqUrl := fmt.Sprintf("%s/users/%s/events", GraphUrl, c.adminUserId)
-	body := bytes.NewBuffer(buildCalendarInviteBody(invite))
-	resp, err := c.doRequest("POST", reqUrl, body)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 300 {
-		return errors.New(fmt.Sprintf("failed to send email, %d", resp.StatusCode))
-	}
-	return nil
-}
-
-func (c *ADClient) sendEmail(email *EmailBody) error {
-	_ = c.RefreshToken()
-	reqUrl := fmt.Sprintf("%s/users/%s/sendmail", GraphUrl, c.adminUserId)
-	body := bytes.NewBuffer(buildEmailBody(email))
-	resp, err := c.doRequest("POST", reqUrl, body)
+// sendMIME sends a MIME message from the admin's mailbox
+func (c *ADClient) sendMIME(message []byte) error {
+	reqUrl := fmt.Sprintf("%s/users/%s/sendMail", c.graphUrl, c.adminUserId)
+	body := []byte(base64.StdEncoding.EncodeToString(message))
+	resp, err := c.do("POST", reqUrl, "text/plain", body)
 	if err != nil {
 		return err
 	}
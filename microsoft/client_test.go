@@ -0,0 +1,221 @@
+package microsoft
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-api/mail"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// response is how the Graph stand-in answers a sendMail
+type response struct {
+	status     int
+	retryAfter string
+}
+
+// graph stands in for the token endpoint and the parts of Graph the client uses
+type graph struct {
+	mu        sync.Mutex
+	expiresIn int
+	tokens    int
+	revoked   map[string]bool
+	// responses answer the next sendMails, in order; the others are accepted
+	responses []response
+	sends     int
+	// messages are the MIME messages sent
+	messages []string
+}
+
+func (g *graph) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if r.URL.Path == "/token" {
+		if r.FormValue("grant_type") != "client_credentials" || r.FormValue("client_id") != "client" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		g.tokens++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"token_type":   "Bearer",
+			"access_token": fmt.Sprintf("token-%d", g.tokens),
+			"expires_in":   g.expiresIn,
+		})
+		return
+	}
+	if token := r.Header.Get("Authorization"); token != fmt.Sprintf("Bearer token-%d", g.tokens) || g.revoked[token] {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch r.URL.Path {
+	case "/v1.0/users/admin":
+		json.NewEncoder(w).Encode(map[string]string{"displayName": "Admin", "mail": "admin@example.com"})
+	case "/v1.0/users/admin/sendMail":
+		g.sends++
+		body, _ := ioutil.ReadAll(r.Body)
+		message, _ := base64.StdEncoding.DecodeString(string(body))
+		g.messages = append(g.messages, string(message))
+		if len(g.responses) > 0 {
+			next := g.responses[0]
+			g.responses = g.responses[1:]
+			if next.retryAfter != "" {
+				w.Header().Set("Retry-After", next.retryAfter)
+			}
+			w.WriteHeader(next.status)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (g *graph) counts() (int, int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.tokens, g.sends
+}
+
+func newTestClient(t *testing.T, g *graph) (*ADClient, *[]time.Duration) {
+	server := httptest.NewServer(g)
+	t.Cleanup(server.Close)
+	templates, err := mail.NewTemplates(&mail.TemplateConfig{})
+	require.NoError(t, err)
+	client, err := NewADClient(&Config{
+		ClientId:    "client",
+		AdminUserId: "admin",
+		TokenUrl:    server.URL + "/token",
+		GraphUrl:    server.URL + "/v1.0/",
+	}, templates)
+	require.NoError(t, err)
+	var sleeps []time.Duration
+	client.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	return client, &sleeps
+}
+
+func TestNewADClient(t *testing.T) {
+	client, _ := newTestClient(t, &graph{expiresIn: 3600})
+	assert.Equal(t, "admin@example.com", client.organizer.Email)
+	assert.Equal(t, "Admin", client.organizer.Name)
+}
+
+func TestTokenCaching(t *testing.T) {
+	g := &graph{expiresIn: 3600}
+	client, _ := newTestClient(t, g)
+	start := time.Now().Add(24 * time.Hour)
+	require.NoError(t, client.SendConfirmation(mail.Booking, &mail.EmailParams{
+		ID: "b1", Name: "Jane", Email: "jane@example.com", WorkspaceName: "W-001", FloorName: "West 2nd Avenue",
+		Start: start, End: start.Add(8 * time.Hour),
+	}))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, client.sendMIME([]byte("message")))
+		}()
+	}
+	wg.Wait()
+	tokens, sends := g.counts()
+	assert.Equal(t, 1, tokens, "the token is fetched once and shared")
+	assert.Equal(t, 11, sends)
+
+	client.mu.Lock()
+	client.expiresAt = time.Now().Add(tokenRefreshMargin / 2)
+	client.mu.Unlock()
+	require.NoError(t, client.sendMIME([]byte("message")))
+	tokens, _ = g.counts()
+	assert.Equal(t, 2, tokens, "tokens about to expire are replaced before they are used")
+
+	short := &graph{expiresIn: 60}
+	client, _ = newTestClient(t, short)
+	require.NoError(t, client.sendMIME([]byte("message")))
+	tokens, _ = short.counts()
+	assert.Equal(t, 2, tokens, "a token that expires within the margin is never reused")
+}
+
+func TestSendUpdate(t *testing.T) {
+	g := &graph{expiresIn: 3600}
+	client, _ := newTestClient(t, g)
+	start := time.Now().Add(24 * time.Hour)
+	params := &mail.EmailParams{
+		ID: "b1", Name: "Jane", Email: "jane@example.com", WorkspaceName: "W-001", FloorName: "West 2nd Avenue",
+		Start: start, End: start.Add(8 * time.Hour), Changed: time.Now(),
+	}
+	require.NoError(t, client.SendConfirmation(mail.Booking, params))
+	params.Start, params.End, params.Changed = start.Add(time.Hour), start.Add(9*time.Hour), params.Changed.Add(time.Minute)
+	require.NoError(t, client.SendUpdate(mail.Booking, params))
+
+	uid := regexp.MustCompile(`UID:(\S+)`)
+	sequence := regexp.MustCompile(`SEQUENCE:(\d+)`)
+	require.Len(t, g.messages, 2)
+	var uids []string
+	var sequences []int
+	for _, message := range g.messages {
+		assert.Contains(t, message, "method=REQUEST")
+		match := uid.FindStringSubmatch(message)
+		require.NotNil(t, match)
+		uids = append(uids, match[1])
+		match = sequence.FindStringSubmatch(message)
+		require.NotNil(t, match)
+		n, err := strconv.Atoi(match[1])
+		require.NoError(t, err)
+		sequences = append(sequences, n)
+	}
+	assert.Equal(t, "booking-b1@iwork", uids[0])
+	assert.Equal(t, uids[0], uids[1], "the update replaces the event of the confirmation")
+	assert.True(t, sequences[1] > sequences[0])
+}
+
+func TestUnauthorized(t *testing.T) {
+	g := &graph{expiresIn: 3600, revoked: map[string]bool{}}
+	client, _ := newTestClient(t, g)
+	g.mu.Lock()
+	g.revoked["Bearer token-1"] = true
+	g.mu.Unlock()
+	require.NoError(t, client.sendMIME([]byte("message")))
+	tokens, _ := g.counts()
+	assert.Equal(t, 2, tokens, "a rejected token is replaced and the request retried")
+
+	g.mu.Lock()
+	g.responses = []response{{status: http.StatusUnauthorized}, {status: http.StatusUnauthorized}}
+	g.mu.Unlock()
+	assert.Error(t, client.sendMIME([]byte("message")))
+	tokens, sends := g.counts()
+	assert.Equal(t, 3, tokens)
+	assert.Equal(t, 3, sends, "the request is only retried once")
+}
+
+func TestThrottling(t *testing.T) {
+	g := &graph{expiresIn: 3600}
+	client, sleeps := newTestClient(t, g)
+	g.responses = []response{{http.StatusTooManyRequests, "7"}, {http.StatusServiceUnavailable, ""}}
+	require.NoError(t, client.sendMIME([]byte("message")))
+	assert.Equal(t, []time.Duration{7 * time.Second, defaultRetryAfter}, *sleeps)
+
+	*sleeps = nil
+	g.responses = []response{{http.StatusTooManyRequests, "3600"}}
+	assert.Error(t, client.sendMIME([]byte("message")))
+	assert.Empty(t, *sleeps, "waits longer than maxRetryAfter aren't waited out")
+
+	g.responses = []response{{http.StatusTooManyRequests, "1"}, {http.StatusTooManyRequests, "1"}, {http.StatusTooManyRequests, "1"}, {http.StatusTooManyRequests, "1"}}
+	assert.Error(t, client.sendMIME([]byte("message")))
+	assert.Len(t, *sleeps, maxThrottleRetries)
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2021, time.March, 1, 17, 0, 0, 0, time.UTC)
+	assert.Equal(t, 30*time.Second, retryAfter("30", now))
+	assert.Equal(t, 90*time.Second, retryAfter("Mon, 01 Mar 2021 17:01:30 GMT", now))
+	assert.Equal(t, time.Duration(0), retryAfter("Mon, 01 Mar 2021 16:00:00 GMT", now))
+	assert.Equal(t, defaultRetryAfter, retryAfter("", now))
+}
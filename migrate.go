@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"go-api/db/migrations"
+	"log"
+	"strconv"
+)
+
+const migrateUsage = "usage: go-api migrate up | down [steps|all] | status | baseline <version>"
+
+// runMigrate handles `go-api migrate ...`
+func runMigrate(dbUrl string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(migrateUsage)
+	}
+	migrator, err := migrations.Open(dbUrl)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			log.Printf("applied %s", migration)
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 && args[1] == "all" {
+			steps = len(migrations.All)
+		} else if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf(migrateUsage)
+			}
+		}
+		reverted, err := migrator.Down(steps)
+		for _, migration := range reverted {
+			log.Printf("reverted %s", migration)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			if status.AppliedAt == nil {
+				fmt.Printf("%s\tpending\n", status.Migration)
+			} else {
+				fmt.Printf("%s\tapplied %s\n", status.Migration, status.AppliedAt.Format("2006-01-02 15:04:05"))
+			}
+		}
+		return nil
+	case "baseline":
+		if len(args) < 2 {
+			return fmt.Errorf(migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf(migrateUsage)
+		}
+		return migrator.Baseline(version)
+	}
+	return fmt.Errorf(migrateUsage)
+}
+
+// migrateOnStart brings the schema up to date before the server starts
+func migrateOnStart(dbUrl string) error {
+	migrator, err := migrations.Open(dbUrl)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+	applied, err := migrator.Up()
+	for _, migration := range applied {
+		log.Printf("applied %s", migration)
+	}
+	return err
+}
@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type UserAssignment struct {
 	ID          string `json:"id"`
 	Name        string `json:"name"`
@@ -30,3 +32,108 @@ type BulkCreateWorkspaceError struct {
 type DeleteFloor struct {
 	ForceDelete bool `json:"force_delete"`
 }
+
+// UpdateBookingInput holds the fields of a booking that can be changed; absent fields are left untouched
+type UpdateBookingInput struct {
+	WorkspaceID *string    `json:"workspace_id"`
+	StartDate   *time.Time `json:"start_time"`
+	EndDate     *time.Time `json:"end_time"`
+}
+
+// UpdateWebhookInput holds the fields of a webhook that can be changed; absent fields are left untouched. Making a
+// webhook active again forgets its failures
+type UpdateWebhookInput struct {
+	URL     *string   `json:"url"`
+	Secret  *string   `json:"secret"`
+	Events  *[]string `json:"events"`
+	FloorID *string   `json:"floor_id"`
+	Active  *bool     `json:"active"`
+}
+
+type BookingSeriesResult struct {
+	Series    *BookingSeries    `json:"series"`
+	Bookings  []*Booking        `json:"bookings"`
+	Conflicts []*SeriesConflict `json:"conflicts,omitempty"`
+}
+
+type OfferingSeriesResult struct {
+	Series    *OfferingSeries   `json:"series"`
+	Offerings []*Offering       `json:"offerings"`
+	Conflicts []*SeriesConflict `json:"conflicts,omitempty"`
+}
+
+type SeriesCancellationResult struct {
+	Cancelled []*Offering       `json:"cancelled"`
+	Skipped   []*SeriesConflict `json:"skipped"`
+}
+
+// ArchiveCounts counts archived rows of each kind
+type ArchiveCounts struct {
+	Offerings   int `json:"offerings"`
+	Bookings    int `json:"bookings"`
+	Assignments int `json:"assignments"`
+	Workspaces  int `json:"workspaces"`
+	Floors      int `json:"floors"`
+}
+
+const (
+	ArchiveArchived = "archived"
+	ArchiveDryRun   = "dry_run"
+	// ArchiveEmpty is a run that found nothing old enough to archive, and so stored no archive
+	ArchiveEmpty  = "empty"
+	ArchiveFailed = "failed"
+)
+
+// ArchiveSection is how many rows of one kind an archive run read, or why it could not read them
+type ArchiveSection struct {
+	Name  string `json:"name"`
+	Rows  int    `json:"rows"`
+	Error string `json:"error,omitempty"`
+}
+
+// ArchiveReport is what an archive run moved out of the database, or would have moved on a dry run
+type ArchiveReport struct {
+	Status string `json:"status"`
+	File   string `json:"file,omitempty"`
+	Format string `json:"format"`
+	// Checksum is the hex sha256 of the archive file, checked against the stored copy before anything is deleted
+	Checksum string            `json:"checksum,omitempty"`
+	Cutoff   time.Time         `json:"cutoff"`
+	DryRun   bool              `json:"dry_run"`
+	Sections []*ArchiveSection `json:"sections"`
+	// Error is why a failed run failed; failed runs leave the database as it was
+	Error string `json:"error,omitempty"`
+}
+
+// ArchivedRows are rows read back from archive files
+type ArchivedRows struct {
+	Offerings   []*Offering   `json:"offerings"`
+	Bookings    []*Booking    `json:"bookings"`
+	Assignments []*Assignment `json:"assignments"`
+	Workspaces  []*Workspace  `json:"workspaces"`
+	Floors      []*Floor      `json:"floors"`
+}
+
+// RestoreInput picks the archived rows to restore; empty fields match every row
+type RestoreInput struct {
+	IDs    []string   `json:"ids"`
+	UserID string     `json:"user_id"`
+	Start  *time.Time `json:"start_time"`
+	End    *time.Time `json:"end_time"`
+}
+
+// RestoreReport counts the archived rows a restore put back, and those it skipped because they are already in
+// the database or clash with a row that is
+type RestoreReport struct {
+	Restored ArchiveCounts `json:"restored"`
+	Skipped  ArchiveCounts `json:"skipped"`
+}
+
+// ArchivedBooking is a booking found in an archive file
+type ArchivedBooking struct {
+	Booking
+	File          string `json:"file"`
+	WorkspaceName string `json:"workspace_name"`
+	FloorID       string `json:"floor_id"`
+	FloorName     string `json:"floor_name"`
+}
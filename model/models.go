@@ -34,13 +34,28 @@ func (this *Workspace) Equal(other *Workspace) bool {
 }
 
 type Booking struct {
-	ID          string    `json:"id"`
-	WorkspaceID string    `json:"workspace_id"`
-	UserID      string    `json:"user_id"`
-	StartDate   time.Time `json:"start_time"`
-	EndDate     time.Time `json:"end_time"`
-	Cancelled   bool      `json:"cancelled"`
-	CreatedBy   string    `json:"created_by"`
+	ID          string     `json:"id"`
+	WorkspaceID string     `json:"workspace_id"`
+	UserID      string     `json:"user_id"`
+	StartDate   time.Time  `json:"start_time"`
+	EndDate     time.Time  `json:"end_time"`
+	Cancelled   bool       `json:"cancelled"`
+	CreatedBy   string     `json:"created_by"`
+	SeriesID    string     `json:"series_id,omitempty"`
+	CheckedInAt *time.Time `json:"checked_in_at,omitempty"`
+	NoShow      bool       `json:"no_show"`
+}
+
+// BookingDay is how often a booking that spans several days is checked in to: every day at the time it starts
+const BookingDay = 24 * time.Hour
+
+// DayStart is when the day of the booking t falls on starts, i.e. the booking's start time on that day. Times
+// before the booking fall on its first day
+func (this *Booking) DayStart(t time.Time) time.Time {
+	if !t.After(this.StartDate) {
+		return this.StartDate
+	}
+	return this.StartDate.Add(t.Sub(this.StartDate) / BookingDay * BookingDay)
 }
 
 func (this *Booking) Equal(other *Booking) bool {
@@ -49,6 +64,14 @@ func (this *Booking) Equal(other *Booking) bool {
 		this.EndDate == other.EndDate && this.Cancelled == other.Cancelled && this.CreatedBy == other.CreatedBy
 }
 
+// NoShowCount is how many bookings a user did not check in to
+type NoShowCount struct {
+	UserID     string `json:"user_id"`
+	UserName   string `json:"user_name"`
+	Department string `json:"department"`
+	NoShows    int    `json:"no_shows"`
+}
+
 type ExpandedBooking struct {
 	Booking
 	WorkspaceName string `json:"workspace_name"`
@@ -63,12 +86,37 @@ type User struct {
 	Department string `json:"department"`
 	IsAdmin    bool   `json:"is_admin"`
 	Email      string `json:"email"`
+	// Locale and TimeZone pick the language of the user's emails and the zone their times are shown in, e.g.
+	// "fr-CA" and "America/Toronto"; empty means the app's defaults
+	Locale   string `json:"locale"`
+	TimeZone string `json:"time_zone"`
+	// RemindersOptOut and DigestOptOut stop the reminders before the user's bookings and the weekly digest
+	RemindersOptOut bool `json:"reminders_opt_out"`
+	DigestOptOut    bool `json:"digest_opt_out"`
 }
 
 func (this *User) Equal(other *User) bool {
 	return this.ID == other.ID && this.Name == other.Name &&
 		this.Email == other.Email && this.Department == other.Department &&
-		this.IsAdmin == other.IsAdmin
+		this.IsAdmin == other.IsAdmin && this.Locale == other.Locale && this.TimeZone == other.TimeZone &&
+		this.RemindersOptOut == other.RemindersOptOut && this.DigestOptOut == other.DigestOptOut
+}
+
+// UserPreferences are the settings users change themselves, see User
+type UserPreferences struct {
+	Locale          string `json:"locale"`
+	TimeZone        string `json:"time_zone"`
+	RemindersOptOut bool   `json:"reminders_opt_out"`
+	DigestOptOut    bool   `json:"digest_opt_out"`
+}
+
+func (this *User) Preferences() *UserPreferences {
+	return &UserPreferences{
+		Locale:          this.Locale,
+		TimeZone:        this.TimeZone,
+		RemindersOptOut: this.RemindersOptOut,
+		DigestOptOut:    this.DigestOptOut,
+	}
 }
 
 type Floor struct {
@@ -91,6 +139,7 @@ type Offering struct {
 	EndDate     time.Time `json:"end_time"`
 	Cancelled   bool      `json:"cancelled"`
 	CreatedBy   string    `json:"created_by"`
+	SeriesID    string    `json:"series_id,omitempty"`
 }
 
 func (this *Offering) Equal(other *Offering) bool {
@@ -122,3 +171,418 @@ type Assignment struct {
 	StartDate   time.Time `json:"start_time"`
 	EndDate     time.Time `json:"end_time"`
 }
+
+const (
+	FrequencyDaily  = "daily"
+	FrequencyWeekly = "weekly"
+)
+
+// Recurrence describes how a series repeats, modelled on the iCalendar RRULE: FREQ, INTERVAL, BYDAY, UNTIL and COUNT
+type Recurrence struct {
+	Frequency string     `json:"frequency"`
+	Interval  int        `json:"interval"`
+	Weekdays  []string   `json:"weekdays"` // MO, TU, WE, TH, FR, SA, SU; weekly only
+	Until     *time.Time `json:"until,omitempty"`
+	Count     int        `json:"count,omitempty"`
+}
+
+func (a Recurrence) Value() (driver.Value, error) {
+	return json.Marshal(a)
+}
+
+func (a *Recurrence) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, &a)
+}
+
+// BookingSeries is a recurring booking; StartDate and EndDate are the times of its first occurrence
+type BookingSeries struct {
+	ID          string     `json:"id"`
+	WorkspaceID string     `json:"workspace_id"`
+	UserID      string     `json:"user_id"`
+	StartDate   time.Time  `json:"start_time"`
+	EndDate     time.Time  `json:"end_time"`
+	Recurrence  Recurrence `json:"recurrence"`
+	Cancelled   bool       `json:"cancelled"`
+	CreatedBy   string     `json:"created_by"`
+}
+
+// OfferingSeries is a recurring offering; StartDate and EndDate are the times of its first occurrence
+type OfferingSeries struct {
+	ID          string     `json:"id"`
+	WorkspaceID string     `json:"workspace_id"`
+	UserID      string     `json:"user_id"`
+	StartDate   time.Time  `json:"start_time"`
+	EndDate     time.Time  `json:"end_time"`
+	Recurrence  Recurrence `json:"recurrence"`
+	Cancelled   bool       `json:"cancelled"`
+	CreatedBy   string     `json:"created_by"`
+}
+
+// SeriesConflict is an occurrence of a series that could not be created or cancelled
+type SeriesConflict struct {
+	StartDate time.Time `json:"start_time"`
+	EndDate   time.Time `json:"end_time"`
+	Reason    string    `json:"reason"`
+	// Code is the broken booking policy rule, if that's why the occurrence failed
+	Code string `json:"code,omitempty"`
+}
+
+const (
+	WaitlistWaiting   = "waiting"
+	WaitlistHeld      = "held"
+	WaitlistBooked    = "booked"
+	WaitlistExpired   = "expired"
+	WaitlistCancelled = "cancelled"
+)
+
+// WaitlistEntry is a user waiting for any workspace on a floor whose properties contain Props. Once one frees up
+// the entry is either booked straight away (AutoBook) or the workspace is held for the user until HoldExpiresAt
+type WaitlistEntry struct {
+	ID              string     `json:"id"`
+	UserID          string     `json:"user_id"`
+	FloorID         string     `json:"floor_id"`
+	Props           Attrs      `json:"properties"`
+	StartDate       time.Time  `json:"start_time"`
+	EndDate         time.Time  `json:"end_time"`
+	AutoBook        bool       `json:"auto_book"`
+	Status          string     `json:"status"`
+	HoldWorkspaceID string     `json:"hold_workspace_id,omitempty"`
+	HoldExpiresAt   *time.Time `json:"hold_expires_at,omitempty"`
+	BookingID       string     `json:"booking_id,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// BookingPolicy limits what users can book; a limit of 0 is not enforced
+type BookingPolicy struct {
+	MaxAdvanceDays     int `json:"max_advance_days"`
+	MaxDurationHours   int `json:"max_duration_hours"`
+	MaxFutureBookings  int `json:"max_future_bookings"`
+	MaxDepartmentDaily int `json:"max_department_daily"`
+}
+
+// FloorBlackout is a period when nothing on a floor can be booked, e.g. while it is being renovated
+type FloorBlackout struct {
+	ID        string    `json:"id"`
+	FloorID   string    `json:"floor_id"`
+	StartDate time.Time `json:"start_time"`
+	EndDate   time.Time `json:"end_time"`
+	Reason    string    `json:"reason"`
+}
+
+const (
+	NotificationConfirmation = "confirmation"
+	NotificationCancellation = "cancellation"
+	NotificationUpdate       = "update"
+	NotificationReminder     = "reminder"
+	NotificationHold         = "hold"
+	NotificationDigest       = "digest"
+
+	// NotificationBooking, NotificationOffering and NotificationWaitlist match the type names of the mail package
+	NotificationBooking  = "booking"
+	NotificationOffering = "offering"
+	NotificationWaitlist = "waitlist"
+
+	// RoleCreator and RoleOwner are who hears about a booking besides the user it is for: the user who made it for
+	// them and the owner of the offering it is in. They match the roles of the mail package
+	RoleCreator = "creator"
+	RoleOwner   = "owner"
+)
+
+// Notification is an email about a booking, offering or waitlist hold, or a digest of the upcoming ones. Everything
+// needed to send it is copied from the rows it is about when it is queued, so it can be sent after those rows change
+// or are archived
+type Notification struct {
+	// Kind is NotificationConfirmation, NotificationCancellation, NotificationUpdate, NotificationReminder,
+	// NotificationHold or NotificationDigest
+	Kind string `json:"kind"`
+	// Subject is what the notification is about, NotificationBooking, NotificationOffering or NotificationWaitlist;
+	// empty for digests
+	Subject       string    `json:"subject"`
+	SubjectID     string    `json:"subject_id"`
+	UserID        string    `json:"user_id"`
+	Name          string    `json:"name"`
+	Email         string    `json:"email"`
+	WorkspaceName string    `json:"workspace_name"`
+	FloorID       string    `json:"floor_id,omitempty"`
+	FloorName     string    `json:"floor_name"`
+	FloorAddress  string    `json:"floor_address"`
+	StartDate     time.Time `json:"start_time"`
+	EndDate       time.Time `json:"end_time"`
+	Locale        string    `json:"locale,omitempty"`
+	TimeZone      string    `json:"time_zone,omitempty"`
+	// Items are the bookings and offerings listed in a digest, which covers StartDate to EndDate
+	Items []*DigestItem `json:"items,omitempty"`
+	// Role is why UserID hears about a booking that isn't theirs, RoleCreator or RoleOwner, and BookerName the
+	// name of the user it is for; Role is empty for the booker
+	Role       string `json:"role,omitempty"`
+	BookerName string `json:"booker_name,omitempty"`
+	// Series is set when the notification is about a whole booking or offering series, SubjectID, and Occurrences
+	// are the occurrences of it that were booked, offered or cancelled; StartDate and EndDate are the first one's
+	Series      bool          `json:"series,omitempty"`
+	Occurrences []*Occurrence `json:"occurrences,omitempty"`
+	// HoldExpiresAt is when the workspace held for a waitlisted user is released
+	HoldExpiresAt *time.Time `json:"hold_expires_at,omitempty"`
+}
+
+// Occurrence is a booking or offering of a series a notification is about
+type Occurrence struct {
+	ID        string    `json:"id"`
+	StartDate time.Time `json:"start_time"`
+}
+
+// AddOccurrence adds the occurrence with id starting at start to the series the notification is about
+func (n *Notification) AddOccurrence(id string, start time.Time) {
+	n.Occurrences = append(n.Occurrences, &Occurrence{ID: id, StartDate: start})
+}
+
+// BookingSeriesNotification is the notification of the given kind about occurrences, bookings of the series with id
+func BookingSeriesNotification(kind, id string, occurrences []*Booking) *Notification {
+	n := &Notification{
+		Kind:      kind,
+		Subject:   NotificationBooking,
+		SubjectID: id,
+		UserID:    occurrences[0].UserID,
+		StartDate: occurrences[0].StartDate,
+		EndDate:   occurrences[0].EndDate,
+		Series:    true,
+	}
+	for _, occurrence := range occurrences {
+		n.AddOccurrence(occurrence.ID, occurrence.StartDate)
+	}
+	return n
+}
+
+// OfferingSeriesNotification is the notification of the given kind about occurrences, offerings of the series with
+// id
+func OfferingSeriesNotification(kind, id string, occurrences []*Offering) *Notification {
+	n := &Notification{
+		Kind:      kind,
+		Subject:   NotificationOffering,
+		SubjectID: id,
+		UserID:    occurrences[0].UserID,
+		StartDate: occurrences[0].StartDate,
+		EndDate:   occurrences[0].EndDate,
+		Series:    true,
+	}
+	for _, occurrence := range occurrences {
+		n.AddOccurrence(occurrence.ID, occurrence.StartDate)
+	}
+	return n
+}
+
+// Party is a user who hears about a booking that isn't theirs, in the given Role
+type Party struct {
+	UserID string
+	Role   string
+}
+
+// InterestedParties is who hears about the booking of booker besides booker: creator when they made it for someone
+// else, then owner, the owner of the offering the booking is in. Nobody hears twice; empty ids are left out
+func InterestedParties(booker, creator, owner string) []*Party {
+	parties := make([]*Party, 0)
+	notified := map[string]bool{booker: true, "": true}
+	for _, party := range []*Party{{UserID: creator, Role: RoleCreator}, {UserID: owner, Role: RoleOwner}} {
+		if !notified[party.UserID] {
+			notified[party.UserID] = true
+			parties = append(parties, party)
+		}
+	}
+	return parties
+}
+
+// DigestItem is a booking or offering listed in a digest
+type DigestItem struct {
+	// Subject is NotificationBooking or NotificationOffering
+	Subject       string    `json:"subject"`
+	SubjectID     string    `json:"subject_id"`
+	WorkspaceName string    `json:"workspace_name"`
+	FloorName     string    `json:"floor_name"`
+	FloorAddress  string    `json:"floor_address"`
+	StartDate     time.Time `json:"start_time"`
+	EndDate       time.Time `json:"end_time"`
+}
+
+func (n Notification) Value() (driver.Value, error) {
+	return json.Marshal(n)
+}
+
+func (n *Notification) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, &n)
+}
+
+const (
+	OutboxPending   = "pending"
+	OutboxDelivered = "delivered"
+	OutboxDead      = "dead"
+)
+
+// OutboxMessage is a notification queued in the same transaction as the change it is about. Pending messages
+// are retried until they are delivered or have failed too often, when they are dead-lettered
+type OutboxMessage struct {
+	ID           string       `json:"id"`
+	Notification Notification `json:"notification"`
+	Status       string       `json:"status"`
+	Attempts     int          `json:"attempts"`
+	// NextAttemptAt is when a pending message is due to be sent
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+}
+
+// EmailTemplate overrides the content of the emails sent for Event in Locale. Subject and Text are text/template
+// templates and HTML is an html/template template, all executed against the email's parameters
+type EmailTemplate struct {
+	Event     string    `json:"event"`
+	Locale    string    `json:"locale"`
+	Subject   string    `json:"subject"`
+	Text      string    `json:"text"`
+	HTML      string    `json:"html"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const (
+	ChannelWebhook = "webhook"
+	ChannelSlack   = "slack"
+	ChannelTeams   = "teams"
+)
+
+// ChannelKinds are the kinds of channel notifications can be sent to besides email
+var ChannelKinds = []string{ChannelWebhook, ChannelSlack, ChannelTeams}
+
+// Channel is where the notifications of a user or of a floor are sent besides email: a webhook, which gets every
+// event as JSON signed with Secret, or a Slack or Teams incoming webhook, which gets a message. Exactly one of UserID
+// and FloorID is set
+type Channel struct {
+	ID      string `json:"id"`
+	UserID  string `json:"user_id,omitempty"`
+	FloorID string `json:"floor_id,omitempty"`
+	Kind    string `json:"kind"`
+	URL     string `json:"url"`
+	// Secret is never returned once the channel is created
+	Secret string `json:"secret,omitempty"`
+	// Events are the types of the events sent to the channel, e.g. "booking.created"; empty means all of them
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Wants tells whether events of the given type are sent to the channel
+func (this *Channel) Wants(eventType string) bool {
+	if len(this.Events) == 0 {
+		return true
+	}
+	for _, event := range this.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook is a subscription of another system, e.g. badge access, to the events of the app. Every event it wants is
+// delivered to URL as JSON signed with Secret, and retried until it is accepted or has failed too often. A webhook
+// that keeps failing is disabled until an admin makes it active again
+type Webhook struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Secret is never returned once the webhook is created
+	Secret string `json:"secret,omitempty"`
+	// Events are the types of the events delivered, e.g. "booking.created"; empty means all of them
+	Events []string `json:"events"`
+	// FloorID limits the events delivered to those about the floor; empty means every floor
+	FloorID string `json:"floor_id,omitempty"`
+	Active  bool   `json:"active"`
+	// FailingSince is when the deliveries to the webhook started failing, nil while they succeed
+	FailingSince *time.Time `json:"failing_since,omitempty"`
+	// DisabledAt is when the webhook was made inactive, by an admin or for failing too long
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Wants tells whether events of the given type about the floor with floorId are delivered to the webhook
+func (this *Webhook) Wants(eventType, floorId string) bool {
+	if this.FloorID != "" && this.FloorID != floorId {
+		return false
+	}
+	if len(this.Events) == 0 {
+		return true
+	}
+	for _, event := range this.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery is the state of a WebhookDelivery or ChannelDelivery, which are queued, claimed, retried and
+// dead-lettered alike. Status is OutboxPending, OutboxDelivered or OutboxDead, like the messages of the outbox
+type Delivery struct {
+	ID       string `json:"id"`
+	Event    string `json:"event"`
+	Status   string `json:"status"`
+	Attempts int    `json:"attempts"`
+	// NextAttemptAt is when a pending delivery is due to be sent
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	// ResponseStatus is the HTTP status of the last attempt, 0 if it got no response
+	ResponseStatus int        `json:"response_status,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// Delivered records the successful attempt
+func (this *Delivery) Delivered(attempt *DeliveryAttempt) {
+	at := attempt.At
+	this.Status = OutboxDelivered
+	this.Attempts++
+	this.ResponseStatus, this.LastError, this.DeliveredAt = attempt.ResponseStatus, "", &at
+}
+
+// Failed records the failed attempt; the delivery is retried at attempt.NextAttemptAt, or dead-lettered if it is nil
+func (this *Delivery) Failed(attempt *DeliveryAttempt) {
+	this.Attempts++
+	this.ResponseStatus, this.LastError = attempt.ResponseStatus, attempt.Error
+	if attempt.NextAttemptAt == nil {
+		this.Status = OutboxDead
+	} else {
+		this.NextAttemptAt = *attempt.NextAttemptAt
+	}
+}
+
+// WebhookDelivery is an event queued for a webhook and the outcome of the attempts to deliver it
+type WebhookDelivery struct {
+	Delivery
+	WebhookID string          `json:"webhook_id"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// ChannelDelivery is a message queued for a channel, e.g. a Slack message about a booking, and the outcome of the
+// attempts to send it
+type ChannelDelivery struct {
+	Delivery
+	ChannelID string `json:"channel_id"`
+	// Key identifies the event across the retries of its email; a channel gets one delivery per key. Empty for
+	// events that aren't retried
+	Key string `json:"key,omitempty"`
+	// Body is what is posted to the channel
+	Body json.RawMessage `json:"body"`
+}
+
+// DeliveryAttempt is the outcome of an attempt to deliver a WebhookDelivery or ChannelDelivery
+type DeliveryAttempt struct {
+	At time.Time
+	// ResponseStatus is the HTTP status of the response, 0 if there was none
+	ResponseStatus int
+	Error          string
+	// NextAttemptAt is when a failed delivery is retried; nil dead-letters it
+	NextAttemptAt *time.Time
+}
@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go-api/model"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// timeLayout is how chat messages show times
+const timeLayout = "Mon 02 Jan 2006 15:04 MST"
+
+// ChatSink posts a message about every event to a Slack or Teams incoming webhook; Format is model.ChannelSlack or
+// model.ChannelTeams
+type ChatSink struct {
+	URL    string
+	Format string
+	Client *http.Client
+}
+
+func (s *ChatSink) Send(event *Event) error {
+	body, err := s.Body(event)
+	if err != nil {
+		return err
+	}
+	req, err := s.Request(event.Type, body)
+	if err != nil {
+		return err
+	}
+	_, err = Post(s.Client, req)
+	return err
+}
+
+func (s *ChatSink) Body(event *Event) ([]byte, error) {
+	text := Text(event)
+	var payload interface{}
+	if s.Format == model.ChannelTeams {
+		payload = map[string]string{
+			"@type":    "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary":  text,
+			"text":     text,
+		}
+	} else {
+		// &, < and > are control characters in Slack messages
+		payload = map[string]string{"text": strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)}
+	}
+	return json.Marshal(payload)
+}
+
+func (s *ChatSink) Request(eventType string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// Text is a one line message about event, with times in the time zone of the user it is for
+func Text(event *Event) string {
+	params := event.Params
+	if params == nil {
+		return fmt.Sprintf("%s %s", event.Type, event.ID)
+	}
+	zone, err := time.LoadLocation(params.TimeZone)
+	if err != nil {
+		zone = time.UTC
+	}
+	date := func(t time.Time) string { return t.In(zone).Format(timeLayout) }
+	when := fmt.Sprintf("from %s to %s", date(params.Start), date(params.End))
+	if len(params.Occurrences) > 0 {
+		when = fmt.Sprintf("on %d dates from %s", len(params.Occurrences), date(params.Occurrences[0]))
+	}
+	workspace := params.WorkspaceName + " on " + params.FloorName
+	// the copies of a booking's events for its creator and the owner of its workspace are still about the booker
+	booker := params.Name
+	if params.Role != "" {
+		booker = params.BookerName
+	}
+	switch event.Type {
+	case BookingCreated:
+		return fmt.Sprintf("%s booked %s %s", booker, workspace, when)
+	case BookingUpdated:
+		return fmt.Sprintf("%s moved their booking of %s to %s", booker, workspace, strings.TrimPrefix(when, "from "))
+	case BookingCancelled:
+		return fmt.Sprintf("%s cancelled their booking of %s %s", booker, workspace, when)
+	case BookingReminder:
+		return fmt.Sprintf("Reminder: %s booked %s %s", booker, workspace, when)
+	case OfferingCreated:
+		return fmt.Sprintf("%s offered %s %s", params.Name, workspace, when)
+	case OfferingCancelled:
+		return fmt.Sprintf("%s cancelled their offering of %s %s", params.Name, workspace, when)
+	case OfferingReminder:
+		return fmt.Sprintf("Reminder: %s offered %s %s", params.Name, workspace, when)
+	case WaitlistHeld:
+		return fmt.Sprintf("%s is held for %s until %s", workspace, params.Name, date(params.HoldExpires))
+	case Digest:
+		return fmt.Sprintf("%s has %d bookings and offerings coming up %s", params.Name, len(params.Items), when)
+	case AssignmentChanged:
+		assignee := params.Name
+		if assignee == "" {
+			assignee = event.UserID
+		}
+		return fmt.Sprintf("%s is now assigned to %s", workspace, assignee)
+	case FloorDeleted:
+		return fmt.Sprintf("Floor %s was deleted", params.FloorName)
+	}
+	return fmt.Sprintf("%s: %s", event.Type, workspace)
+}
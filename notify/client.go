@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// Client sends the requests of webhook and chat sinks that don't have their own. Their URLs are picked by users, so
+// it only connects to public addresses: see PublicAddress
+var Client = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   dialPublic,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	},
+}
+
+// privateNetworks are the ranges of private networks, carrier-grade NAT and "this network", which aren't reachable
+// from the internet
+var privateNetworks = parseNetworks("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "0.0.0.0/8", "fc00::/7")
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// PublicAddress tells whether ip can be reached from the internet, i.e. it isn't a loopback, link-local, multicast,
+// unspecified or private network address. Cloud metadata services are on link-local addresses
+func PublicAddress(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// dialPublic refuses to connect to address unless it is public. Dialers call it with the address a host name was
+// resolved to, right before connecting, so a name that resolves to an internal address is refused too, also when it
+// is reached through a redirect
+func dialPublic(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !PublicAddress(ip) {
+		return fmt.Errorf("%s is not a public address", host)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package notify
+
+import (
+	"fmt"
+	"go-api/mail"
+	"go-api/model"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Types of events
+const (
+	BookingCreated    = "booking.created"
+	BookingUpdated    = "booking.updated"
+	BookingCancelled  = "booking.cancelled"
+	BookingReminder   = "booking.reminder"
+	BookingCheckedIn  = "booking.checked_in"
+	OfferingCreated   = "offering.created"
+	OfferingUpdated   = "offering.updated"
+	OfferingCancelled = "offering.cancelled"
+	OfferingReminder  = "offering.reminder"
+	WaitlistHeld      = "waitlist.held"
+	Digest            = "digest"
+	AssignmentChanged = "assignment.changed"
+	WorkspaceCreated  = "workspace.created"
+	WorkspaceUpdated  = "workspace.updated"
+	FloorCreated      = "floor.created"
+	FloorDeleted      = "floor.deleted"
+)
+
+// Events are the types of the events users are notified of, which channels can pick from
+var Events = []string{
+	BookingCreated, BookingUpdated, BookingCancelled, BookingReminder, OfferingCreated, OfferingCancelled,
+	OfferingReminder, WaitlistHeld, Digest, AssignmentChanged, FloorDeleted,
+}
+
+// Mutations are the types of the events about changes to bookings, offerings, assignments, workspaces and floors,
+// which webhooks can pick from
+var Mutations = []string{
+	BookingCreated, BookingUpdated, BookingCancelled, BookingCheckedIn, OfferingCreated, OfferingUpdated,
+	OfferingCancelled, AssignmentChanged, WorkspaceCreated, WorkspaceUpdated, FloorCreated, FloorDeleted,
+}
+
+// IsEvent tells whether eventType is one of Events
+func IsEvent(eventType string) bool {
+	return contains(Events, eventType)
+}
+
+// IsMutation tells whether eventType is one of Mutations
+func IsMutation(eventType string) bool {
+	return contains(Mutations, eventType)
+}
+
+func contains(events []string, eventType string) bool {
+	for _, event := range events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is something that happened that users hear about
+type Event struct {
+	Type string
+	// ID is the booking, offering, workspace or floor the event is about
+	ID   string
+	Time time.Time
+	// UserID is whose event it is: who is emailed about it and whose channels it is sent to. FloorID is the floor
+	// whose channels it is sent to. Either may be empty
+	UserID  string
+	FloorID string
+	// Series is set for events about a whole booking or offering series
+	Series bool
+	// Key identifies the event across retries, so that it is queued once for each channel; empty for events that
+	// aren't retried
+	Key string
+	// Params are the details of the event and, for the events that have one, of its email
+	Params *mail.EmailParams
+}
+
+// Sink is somewhere events are sent
+type Sink interface {
+	Send(event *Event) error
+}
+
+// ChannelSink is the sink of a channel. Sending to it is split in two, so that what is sent can be queued and the
+// request retried: Body is what is posted about an event, and Request the request posting it
+type ChannelSink interface {
+	Sink
+	Body(event *Event) ([]byte, error)
+	Request(eventType string, body []byte) (*http.Request, error)
+}
+
+// NewSink is the sink of a channel; its requests are sent with client, or Client if it is nil
+func NewSink(channel *model.Channel, client *http.Client) (ChannelSink, error) {
+	switch channel.Kind {
+	case model.ChannelWebhook:
+		return &WebhookSink{URL: channel.URL, Secret: channel.Secret, Client: client}, nil
+	case model.ChannelSlack, model.ChannelTeams:
+		return &ChatSink{URL: channel.URL, Format: channel.Kind, Client: client}, nil
+	}
+	return nil, fmt.Errorf("unknown channel kind %q", channel.Kind)
+}
+
+// Notifier queues events for the channels that want them, then sends them by email
+type Notifier struct {
+	Email Sink
+	// Channels finds the channels an event may be sent to
+	Channels func(event *Event) ([]*model.Channel, error)
+	// Queue queues body, what is posted to channel about an event of eventType, to be sent and retried on its own. A
+	// non-empty key is only queued once for a channel
+	Queue func(channel *model.Channel, eventType, key string, body []byte) error
+}
+
+// Notify queues event for every channel that wants it, then sends it by email, so that channels hear of events whose
+// email fails. Failures to find or queue the channels and failed emails are returned, so that the event is retried;
+// its Key keeps a retry from queueing it twice. A channel whose kind or message is broken is logged and skipped.
+// Channels are never sent to here, so a slow one can't hold up the emails
+func (n *Notifier) Notify(event *Event) error {
+	if err := n.queue(event); err != nil {
+		return err
+	}
+	return n.Email.Send(event)
+}
+
+func (n *Notifier) queue(event *Event) error {
+	channels, err := n.Channels(event)
+	if err != nil {
+		return fmt.Errorf("getting the channels of %s: %w", event.Type, err)
+	}
+	for _, channel := range channels {
+		if !channel.Wants(event.Type) {
+			continue
+		}
+		sink, err := NewSink(channel, nil)
+		var body []byte
+		if err == nil {
+			body, err = sink.Body(event)
+		}
+		if err != nil {
+			log.Printf("Notifier.Notify - error writing %s for channel %s %v", event.Type, channel.ID, err)
+			continue
+		}
+		if err = n.Queue(channel, event.Type, event.Key, body); err != nil {
+			return fmt.Errorf("queueing %s for channel %s: %w", event.Type, channel.ID, err)
+		}
+	}
+	return nil
+}
+
+// EmailSink emails events to the user they are for. Events without an email, such as assignment changes, are
+// skipped
+type EmailSink struct {
+	Client mail.EmailClient
+}
+
+func (s *EmailSink) Send(event *Event) error {
+	params := event.Params
+	if params == nil {
+		return nil
+	}
+	booking, offering := mail.Booking, mail.Offering
+	if event.Series {
+		booking, offering = mail.BookingSeries, mail.OfferingSeries
+	}
+	switch event.Type {
+	case BookingCreated:
+		return s.Client.SendConfirmation(booking, params)
+	case BookingUpdated:
+		return s.Client.SendUpdate(booking, params)
+	case BookingCancelled:
+		return s.Client.SendCancellation(booking, params)
+	case BookingReminder:
+		return s.Client.SendReminder(booking, params)
+	case OfferingCreated:
+		return s.Client.SendConfirmation(offering, params)
+	case OfferingCancelled:
+		return s.Client.SendCancellation(offering, params)
+	case OfferingReminder:
+		return s.Client.SendReminder(offering, params)
+	case WaitlistHeld:
+		return s.Client.SendHold(mail.Waitlist, params)
+	case Digest:
+		return s.Client.SendDigest(params)
+	}
+	return nil
+}
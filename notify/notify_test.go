@@ -0,0 +1,229 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-api/mail"
+	"go-api/model"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// request is a request received by the stand-in of a webhook
+type request struct {
+	header http.Header
+	body   []byte
+}
+
+func standIn(t *testing.T, status int) (*httptest.Server, chan *request) {
+	requests := make(chan *request, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		requests <- &request{header: r.Header, body: body}
+		w.WriteHeader(status)
+	}))
+	return server, requests
+}
+
+func sampleEvent() *Event {
+	start := time.Date(2021, time.March, 1, 17, 0, 0, 0, time.UTC)
+	return &Event{
+		Type:    BookingCreated,
+		ID:      "b1",
+		Time:    start.Add(-time.Hour),
+		UserID:  "u1",
+		FloorID: "f1",
+		Params: &mail.EmailParams{
+			Name:          "Jane <Doe>",
+			Email:         "jane@example.com",
+			WorkspaceName: "W-001",
+			FloorName:     "West 2nd Avenue",
+			Start:         start,
+			End:           start.Add(8 * time.Hour),
+			TimeZone:      "America/Vancouver",
+		},
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	server, requests := standIn(t, http.StatusNoContent)
+	defer server.Close()
+	sink := &WebhookSink{URL: server.URL, Secret: "s3cret", Client: server.Client()}
+	require.NoError(t, sink.Send(sampleEvent()))
+
+	received := <-requests
+	assert.Equal(t, BookingCreated, received.header.Get(EventHeader))
+	timestamp, err := strconv.ParseInt(received.header.Get(TimestampHeader), 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, Sign("s3cret", timestamp, received.body), received.header.Get(SignatureHeader))
+	assert.NotEqual(t, Sign("other", timestamp, received.body), received.header.Get(SignatureHeader))
+
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(received.body, &payload))
+	assert.Equal(t, "b1", payload["id"])
+	assert.Equal(t, "Jane <Doe>", payload["user_name"])
+	assert.Equal(t, "2021-03-01T17:00:00Z", payload["start_time"])
+	assert.NotContains(t, payload, "email", "emails are left out")
+
+	floorDeleted := &Event{Type: FloorDeleted, ID: "f1", Params: &mail.EmailParams{FloorName: "West 2nd Avenue"}}
+	require.NoError(t, sink.Send(floorDeleted))
+	payload = nil
+	require.NoError(t, json.Unmarshal((<-requests).body, &payload))
+	assert.NotContains(t, payload, "start_time", "events without times have none")
+
+	failing, _ := standIn(t, http.StatusInternalServerError)
+	defer failing.Close()
+	assert.Error(t, (&WebhookSink{URL: failing.URL, Client: failing.Client()}).Send(sampleEvent()))
+}
+
+func TestPost(t *testing.T) {
+	server, requests := standIn(t, http.StatusAccepted)
+	defer server.Close()
+	req, err := NewRequest(server.URL, "s3cret", OfferingUpdated, []byte(`{"type": "offering.updated"}`))
+	require.NoError(t, err)
+	status, err := Post(server.Client(), req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, status)
+	assert.Equal(t, OfferingUpdated, (<-requests).header.Get(EventHeader))
+
+	failing, _ := standIn(t, http.StatusGone)
+	defer failing.Close()
+	req, err = NewRequest(failing.URL, "s3cret", OfferingUpdated, []byte(`{}`))
+	require.NoError(t, err)
+	status, err = Post(failing.Client(), req)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusGone, status, "failures still report the status")
+}
+
+func TestChatSink(t *testing.T) {
+	server, requests := standIn(t, http.StatusOK)
+	defer server.Close()
+
+	require.NoError(t, (&ChatSink{URL: server.URL, Format: model.ChannelSlack, Client: server.Client()}).Send(sampleEvent()))
+	var slack map[string]string
+	require.NoError(t, json.Unmarshal((<-requests).body, &slack))
+	assert.Equal(t, "Jane &lt;Doe&gt; booked W-001 on West 2nd Avenue from Mon 01 Mar 2021 09:00 PST to Mon 01 Mar 2021 17:00 PST", slack["text"])
+
+	require.NoError(t, (&ChatSink{URL: server.URL, Format: model.ChannelTeams, Client: server.Client()}).Send(sampleEvent()))
+	var teams map[string]string
+	require.NoError(t, json.Unmarshal((<-requests).body, &teams))
+	assert.Equal(t, "MessageCard", teams["@type"])
+	assert.Contains(t, teams["text"], "Jane <Doe> booked W-001")
+}
+
+func TestText(t *testing.T) {
+	event := sampleEvent()
+	event.Type, event.Params.Role, event.Params.Name, event.Params.BookerName = BookingCancelled, mail.RoleOwner, "Alice", "Bob"
+	event.Params.AddOccurrence("b1", event.Params.Start)
+	event.Params.AddOccurrence("b2", event.Params.Start.AddDate(0, 0, 7))
+	assert.Equal(t, "Bob cancelled their booking of W-001 on West 2nd Avenue on 2 dates from Mon 01 Mar 2021 09:00 PST", Text(event),
+		"copies for owners are about the booker")
+	event = &Event{Type: AssignmentChanged, UserID: "u1", Params: &mail.EmailParams{WorkspaceName: "W-001", FloorName: "West 2nd Avenue"}}
+	assert.Equal(t, "W-001 on West 2nd Avenue is now assigned to u1", Text(event))
+}
+
+type emailStub struct {
+	mail.EmailClient
+	sent []string
+	err  error
+}
+
+func (s *emailStub) SendConfirmation(typeS string, params *mail.EmailParams) error {
+	s.sent = append(s.sent, "confirmation:"+typeS)
+	return s.err
+}
+
+func (s *emailStub) SendHold(typeS string, params *mail.EmailParams) error {
+	s.sent = append(s.sent, "hold:"+typeS)
+	return s.err
+}
+
+func TestNotifier(t *testing.T) {
+	email := &emailStub{}
+	channels := []*model.Channel{
+		{ID: "c1", Kind: model.ChannelSlack, URL: "https://hooks.slack.com/c1", Events: []string{BookingCreated}},
+		{ID: "c2", Kind: model.ChannelTeams, URL: "https://teams.example.com/c2", Events: []string{FloorDeleted}},
+		{ID: "c3", Kind: "carrier-pigeon", URL: "https://example.com/c3"},
+		{ID: "c4", Kind: model.ChannelWebhook, URL: "https://example.com/c4", Secret: "s3cret"},
+	}
+	queued := make([]string, 0)
+	var queueErr error
+	notifier := &Notifier{
+		Email:    &EmailSink{Client: email},
+		Channels: func(event *Event) ([]*model.Channel, error) { return channels, nil },
+		Queue: func(channel *model.Channel, eventType, key string, body []byte) error {
+			assert.True(t, json.Valid(body))
+			queued = append(queued, channel.ID+":"+eventType+":"+key)
+			return queueErr
+		},
+	}
+
+	series := sampleEvent()
+	series.Series = true
+	series.Key = "m1"
+	require.NoError(t, notifier.Notify(series))
+	assert.Equal(t, []string{"confirmation:booking series"}, email.sent)
+	assert.Equal(t, []string{"c1:" + BookingCreated + ":m1", "c4:" + BookingCreated + ":m1"}, queued,
+		"the slack channel and the webhook want the event, the unknown kind is skipped")
+
+	queued = queued[:0]
+	require.NoError(t, notifier.Notify(&Event{Type: AssignmentChanged, Params: &mail.EmailParams{}}))
+	assert.Len(t, email.sent, 1, "assignments aren't emailed")
+	assert.Equal(t, []string{"c4:" + AssignmentChanged + ":"}, queued)
+
+	queued = queued[:0]
+	email.err = errors.New("mailbox full")
+	assert.Error(t, notifier.Notify(&Event{Type: WaitlistHeld, Key: "m2", Params: &mail.EmailParams{}}))
+	assert.Equal(t, "hold:waitlist", email.sent[1])
+	assert.Equal(t, []string{"c4:" + WaitlistHeld + ":m2"}, queued, "channels hear of events whose email fails")
+
+	queued = queued[:0]
+	email.err = nil
+	queueErr = errors.New("connection refused")
+	assert.Error(t, notifier.Notify(&Event{Type: WaitlistHeld, Key: "m3", Params: &mail.EmailParams{}}), "retried")
+	assert.Len(t, email.sent, 2, "the email waits for the channels to be queued")
+}
+
+func TestClient(t *testing.T) {
+	server, requests := standIn(t, http.StatusOK)
+	defer server.Close()
+	req, err := NewRequest(server.URL, "s3cret", BookingCreated, []byte(`{}`))
+	require.NoError(t, err)
+	_, err = Post(nil, req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not a public address")
+	assert.Empty(t, requests, "user picked urls can't reach the app's own network")
+
+	assert.Error(t, (&ChatSink{URL: "http://localhost:" + strconv.Itoa(server.Listener.Addr().(*net.TCPAddr).Port)}).Send(sampleEvent()),
+		"host names are checked once they are resolved")
+	assert.Empty(t, requests)
+}
+
+func TestPublicAddress(t *testing.T) {
+	for address, public := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"172.32.0.1":      true,
+		"127.0.0.1":       false,
+		"::1":             false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"100.64.0.1":      false,
+		"169.254.169.254": false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"0.0.0.0":         false,
+		"224.0.0.1":       false,
+	} {
+		assert.Equal(t, public, PublicAddress(net.ParseIP(address)), address)
+	}
+}
@@ -0,0 +1,132 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers of the requests of webhooks
+const (
+	EventHeader     = "X-IWork-Event"
+	TimestampHeader = "X-IWork-Timestamp"
+	// SignatureHeader is "sha256=" and the hex HMAC-SHA256 of the timestamp, a dot and the body, see Sign
+	SignatureHeader = "X-IWork-Signature"
+	// DeliveryHeader is the id of the delivery of a webhook subscription, the same for every attempt, so that
+	// receivers can drop the events they already got
+	DeliveryHeader = "X-IWork-Delivery"
+)
+
+// Payload is the JSON body webhooks get for an event. Emails and locales are left out
+type Payload struct {
+	Type          string      `json:"type"`
+	ID            string      `json:"id"`
+	Time          time.Time   `json:"time"`
+	UserID        string      `json:"user_id,omitempty"`
+	FloorID       string      `json:"floor_id,omitempty"`
+	UserName      string      `json:"user_name,omitempty"`
+	WorkspaceName string      `json:"workspace_name,omitempty"`
+	FloorName     string      `json:"floor_name,omitempty"`
+	Start         *time.Time  `json:"start_time,omitempty"`
+	End           *time.Time  `json:"end_time,omitempty"`
+	Occurrences   []time.Time `json:"occurrences,omitempty"`
+	Role          string      `json:"role,omitempty"`
+	BookerName    string      `json:"booker_name,omitempty"`
+	// Series is set when ID is a booking or offering series and Occurrences are the times of its occurrences
+	Series bool `json:"series,omitempty"`
+}
+
+// NewPayload is the payload of event
+func NewPayload(event *Event) *Payload {
+	payload := &Payload{
+		Type:    event.Type,
+		ID:      event.ID,
+		Time:    event.Time,
+		UserID:  event.UserID,
+		FloorID: event.FloorID,
+		Series:  event.Series,
+	}
+	if params := event.Params; params != nil {
+		payload.UserName, payload.WorkspaceName, payload.FloorName = params.Name, params.WorkspaceName, params.FloorName
+		payload.Occurrences, payload.Role, payload.BookerName = params.Occurrences, params.Role, params.BookerName
+		if !params.Start.IsZero() {
+			payload.Start, payload.End = &params.Start, &params.End
+		}
+	}
+	return payload
+}
+
+// Sign is the signature of a webhook request with body sent at timestamp, in unix seconds. Receivers recompute it
+// with their secret and compare it to the SignatureHeader, and reject old timestamps to stop replays
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookSink posts the Payload of every event to URL, signed with Secret
+type WebhookSink struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+func (s *WebhookSink) Send(event *Event) error {
+	body, err := s.Body(event)
+	if err != nil {
+		return err
+	}
+	req, err := s.Request(event.Type, body)
+	if err != nil {
+		return err
+	}
+	_, err = Post(s.Client, req)
+	return err
+}
+
+func (s *WebhookSink) Body(event *Event) ([]byte, error) {
+	return json.Marshal(NewPayload(event))
+}
+
+// Request signs the request when it is made, so that the timestamp of a retried one is recent
+func (s *WebhookSink) Request(eventType string, body []byte) (*http.Request, error) {
+	return NewRequest(s.URL, s.Secret, eventType, body)
+}
+
+// NewRequest is a request posting body, the payload of an event of eventType, to url, signed with secret
+func NewRequest(url, secret, eventType string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(secret, timestamp, body))
+	return req, nil
+}
+
+// Post sends req with client, or Client if it is nil, and fails unless it gets a 2xx response. It returns the status
+// of the response, 0 if there was none
+func Post(client *http.Client, req *http.Request) (int, error) {
+	if client == nil {
+		client = Client
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("%s responded %s", req.URL.Host, resp.Status)
+	}
+	return resp.StatusCode, nil
+}
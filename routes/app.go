@@ -4,55 +4,165 @@ import (
 	"github.com/gomodule/redigo/redis"
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
+	"go-api/archive"
+	"go-api/auth"
+	"go-api/blob"
+	"go-api/cron"
 	"go-api/db"
+	"go-api/db/memory"
 	"go-api/db/postgres"
 	"go-api/mail"
 	"go-api/microsoft"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
 type App struct {
 	router *mux.Router
 	store  *db.DataStore
-	gDrive db.Drive
+	blobs  blob.Store
 	cache  *redis.Pool
 	email  mail.EmailClient
+	auth   auth.Authenticator
+	// templates render the content of every email
+	templates *mail.Templates
+	// feeds hands out the tokens of calendar feed URLs; nil if feeds can only be read when logged in
+	feeds *auth.FeedAuthenticator
+	// waitlistHold is how long a freed workspace is held for a waitlisted user
+	waitlistHold time.Duration
+	// checkInGrace is how long after a booking starts the desk is kept without a check-in
+	checkInGrace time.Duration
+	// archiveSchedule is when the archiver runs; nil if it only runs on request
+	archiveSchedule *cron.Schedule
+	// archiveRetention is how long bookings, offerings and assignments are kept after they end
+	archiveRetention time.Duration
+	// archiveDryRun makes the scheduled archiver only log what it would archive
+	archiveDryRun bool
+	// archiveFormat is the format archives are written in unless a run asks for another
+	archiveFormat string
+	// outboxMaxAttempts is how many times a notification is tried before it is dead-lettered
+	outboxMaxAttempts int
+	// webhookMaxAttempts is how many times a webhook delivery is tried before it is dead-lettered
+	webhookMaxAttempts int
+	// webhookDisableAfter is how long the deliveries to a webhook keep failing before it is disabled
+	webhookDisableAfter time.Duration
+	// reminders are sent before every booking; none if empty
+	reminders []*reminder
+	// digestSchedule is when the digests of upcoming bookings and offerings are sent; nil if they aren't
+	digestSchedule *cron.Schedule
+	// publicUrl is the URL the API is reached at, for links to it; empty links are relative
+	publicUrl string
+	// client sends the requests of webhooks and channels; nil uses notify.Client, which only connects to public
+	// addresses
+	client *http.Client
 }
 
 type AppConfig struct {
 	DbUrl          string
-	GDriveConfig   string
+	PublicUrl      string
+	BlobConfig     blob.Config
+	MsTenantId     string
 	MsClientId     string
 	MsScope        string
 	MsClientSecret string
-	AdminUserId    string
+	// MsTokenUrl and MsGraphUrl override microsoft.TokenUrl and microsoft.GraphUrl, e.g. for a national cloud
+	MsTokenUrl   string
+	MsGraphUrl   string
+	AdminUserId  string
+	WaitlistHold time.Duration
+	CheckInGrace time.Duration
+	// ArchiveSchedule is a cron expression for the archiver, e.g. "0 3 * * *"; empty disables it
+	ArchiveSchedule  string
+	ArchiveRetention time.Duration
+	ArchiveDryRun    bool
+	// ArchiveFormat is one of archive.Formats(); empty means archive.DefaultFormat
+	ArchiveFormat     string
+	OutboxMaxAttempts int
+	// WebhookMaxAttempts is how many times a webhook delivery is tried; 0 means DefaultWebhookMaxAttempts
+	WebhookMaxAttempts int
+	// WebhookDisableAfter is how long a failing webhook is retried before it is disabled; 0 means
+	// DefaultWebhookDisableAfter
+	WebhookDisableAfter time.Duration
+	// AppUrl is the web app, linked from emails
+	AppUrl string
+	// TemplateDir holds email templates that override the built in ones, see mail.DirSource
+	TemplateDir     string
+	DefaultLocale   string
+	DefaultTimeZone string
+	// CalendarFeedSecret signs the tokens of calendar feed URLs; empty disables them
+	CalendarFeedSecret string
+	// Reminders lists when reminders are sent before bookings, e.g. "18:00,30m" for the evening before and 30
+	// minutes before; empty disables them
+	Reminders string
+	// DigestSchedule is a cron expression for the digests, e.g. "0 8 * * mon"; empty disables them
+	DigestSchedule string
 }
 
 func NewApp(config *AppConfig) *App {
-	store, err := postgres.NewPostgresDataStore(config.DbUrl)
+	store, err := newDataStore(config.DbUrl)
 	if err != nil {
 		log.Println("Failed to connect to database")
 		log.Fatal(err)
 	}
-	driveClient, err := db.NewDriveClient(config.GDriveConfig)
+	blobs, err := blob.New(&config.BlobConfig)
 	if err != nil {
-		log.Println("Failed to connect to google drive")
+		log.Println("Failed to set up blob storage")
 		log.Fatal(err)
 	}
+	var archiveSchedule *cron.Schedule
+	if config.ArchiveSchedule != "" {
+		archiveSchedule, err = cron.Parse(config.ArchiveSchedule)
+		if err != nil {
+			log.Println("Failed to parse archive schedule")
+			log.Fatal(err)
+		}
+	}
+	reminders, err := parseReminders(config.Reminders)
+	if err != nil {
+		log.Println("Failed to parse reminders")
+		log.Fatal(err)
+	}
+	var digestSchedule *cron.Schedule
+	if config.DigestSchedule != "" {
+		digestSchedule, err = cron.Parse(config.DigestSchedule)
+		if err != nil {
+			log.Println("Failed to parse digest schedule")
+			log.Fatal(err)
+		}
+	}
+	if config.ArchiveFormat != "" {
+		if _, err = archive.Lookup(config.ArchiveFormat); err != nil {
+			log.Println("Failed to find archive format")
+			log.Fatal(err)
+		}
+	}
+	templates, err := mail.NewTemplates(&mail.TemplateConfig{
+		AppURL:          config.AppUrl,
+		DefaultLocale:   config.DefaultLocale,
+		DefaultTimeZone: config.DefaultTimeZone,
+		Dir:             config.TemplateDir,
+	}, storedTemplates{store})
+	if err != nil {
+		log.Println("Failed to set up email templates")
+		log.Fatal(err)
+	}
+	store.PolicyProvider.SetDefaultZone(templates.Zone(""))
 	redisUrl := os.Getenv("REDIS_URL")
 	if redisUrl == "" {
 		log.Println("Failed to connect to redis")
 		log.Fatal(err)
 	}
-	msClient, err := microsoft.NewADClient(
-		config.MsClientId,
-		config.MsScope,
-		config.MsClientSecret,
-		config.AdminUserId,
-	)
+	msClient, err := microsoft.NewADClient(&microsoft.Config{
+		ClientId:     config.MsClientId,
+		Scope:        config.MsScope,
+		ClientSecret: config.MsClientSecret,
+		AdminUserId:  config.AdminUserId,
+		TokenUrl:     config.MsTokenUrl,
+		GraphUrl:     config.MsGraphUrl,
+	}, templates)
 	if err != nil {
 		log.Println("Failed to create AD Client")
 		log.Fatal(err)
@@ -64,30 +174,90 @@ func NewApp(config *AppConfig) *App {
 			return redis.DialURL(redisUrl)
 		},
 	}
+	tenantId := config.MsTenantId
+	if tenantId == "" {
+		tenantId = microsoft.DefaultTenantId
+	}
+	authenticator := auth.Chain{
+		auth.NewAzureADAuthenticator(tenantId, config.MsClientId, store.UserProvider.GetOneUser),
+		auth.NewSessionAuthenticator(redisCache, store.UserProvider.GetOneUser),
+	}
+	var feeds *auth.FeedAuthenticator
+	if config.CalendarFeedSecret != "" {
+		feeds = auth.NewFeedAuthenticator(config.CalendarFeedSecret, store.UserProvider.GetOneUser)
+		authenticator = append(authenticator, feeds)
+	}
 	return &App{
 		router: mux.NewRouter().StrictSlash(true),
 		store:  store,
-		gDrive: driveClient,
+		blobs:  blobs,
 		email:  msClient,
 		cache:  redisCache,
+		auth:   authenticator,
+
+		templates: templates,
+		feeds:     feeds,
+
+		waitlistHold: config.WaitlistHold,
+		checkInGrace: config.CheckInGrace,
+
+		archiveSchedule:  archiveSchedule,
+		archiveRetention: config.ArchiveRetention,
+		archiveDryRun:    config.ArchiveDryRun,
+		archiveFormat:    config.ArchiveFormat,
+		publicUrl:        strings.TrimRight(config.PublicUrl, "/"),
+
+		outboxMaxAttempts:   config.OutboxMaxAttempts,
+		webhookMaxAttempts:  config.WebhookMaxAttempts,
+		webhookDisableAfter: config.WebhookDisableAfter,
+
+		reminders:      reminders,
+		digestSchedule: digestSchedule,
+	}
+}
+
+// newDataStore connects to postgres, or keeps everything in memory when dbUrl starts with memory.Scheme
+func newDataStore(dbUrl string) (*db.DataStore, error) {
+	if strings.HasPrefix(dbUrl, memory.Scheme) {
+		log.Println("Using the in-memory store, nothing will be persisted")
+		return memory.NewMemoryDataStore(), nil
 	}
+	return postgres.NewPostgresDataStore(dbUrl)
 }
 
 func (app *App) Setup(port string) error {
 	app.router.HandleFunc("/", app.index)
 	app.RegisterRoutes()
+	app.StartNoShowReleaser()
+	app.StartHoldSweeper()
+	app.StartArchiver()
+	app.StartOutbox()
+	app.StartWebhooks()
+	app.StartChannels()
+	app.StartReminders()
 	log.Println("App running at port:", port)
 	handler := cors.AllowAll().Handler(app.router)
 	return http.ListenAndServe(":"+port, handler)
 }
 
 func (app *App) RegisterRoutes() {
+	app.RegisterLoginRoutes()
 	app.RegisterUserRoutes()
 	app.RegisterFloorRoutes()
+	app.RegisterPolicyRoutes()
 	app.RegisterWorkspaceRoutes()
+	app.RegisterBookingSeriesRoutes()
+	app.RegisterCheckInRoutes()
 	app.RegisterBookingRoutes()
+	app.RegisterOfferingSeriesRoutes()
 	app.RegisterOfferingRoutes()
+	app.RegisterWaitlistRoutes()
 	app.RegisterArchiverRoutes()
+	app.RegisterOutboxRoutes()
+	app.RegisterTemplateRoutes()
+	app.RegisterCalendarRoutes()
+	app.RegisterChannelRoutes()
+	app.RegisterWebhookRoutes()
 }
 
 func (app *App) Close() {
@@ -4,10 +4,11 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"go-api/blob"
+	"go-api/db/migrations"
 	"go-api/db/postgres"
 	"go-api/mail"
 	"go-api/utils"
-	"google.golang.org/api/drive/v3"
 	"io"
 	"log"
 	"net/http"
@@ -48,8 +49,10 @@ type AppTestSuite struct {
 
 func (suite *AppTestSuite) SetupSuite() {
 	dbUrl := os.Getenv("TEST_DB_URL")
+	if err := migrations.Reset(dbUrl); err != nil {
+		suite.FailNow("failed to migrate test db", err)
+	}
 	fixtures := []string{
-		"../resources/tables.sql",
 		"../test-fixtures/floors.sql",
 		"../test-fixtures/users.sql",
 		"../test-fixtures/workspaces.sql",
@@ -66,23 +69,30 @@ func TestApp(t *testing.T) {
 }
 
 // MOCKS
-type mockDrive struct {
+type mockBlobStore struct {
 	mock.Mock
 }
 
-func (m *mockDrive) UploadArchiveDataFile(name string, content io.Reader) error {
-	args := m.Called(name)
+func (m *mockBlobStore) Put(key string, contentType string, content io.Reader) error {
+	args := m.Called(key, contentType)
 	return args.Error(0)
 }
 
-func (m *mockDrive) ListAllFiles() ([]*drive.File, error) {
-	args := m.Called()
-	return nil, args.Error(1)
+func (m *mockBlobStore) Get(key string) (*blob.Object, error) {
+	args := m.Called(key)
+	object, _ := args.Get(0).(*blob.Object)
+	return object, args.Error(1)
 }
 
-func (m *mockDrive) UploadFloorPlan(name string, content io.Reader) (string, error) {
-	args := m.Called(name)
-	return args.String(0), args.Error(1)
+func (m *mockBlobStore) List(prefix string) ([]*blob.Info, error) {
+	args := m.Called(prefix)
+	infos, _ := args.Get(0).([]*blob.Info)
+	return infos, args.Error(1)
+}
+
+func (m *mockBlobStore) Delete(key string) error {
+	args := m.Called(key)
+	return args.Error(0)
 }
 
 type mockEmail struct {
@@ -99,6 +109,26 @@ func (m *mockEmail) SendCancellation(typeS string, params *mail.EmailParams) err
 	return args.Error(0)
 }
 
+func (m *mockEmail) SendUpdate(typeS string, params *mail.EmailParams) error {
+	args := m.Called(typeS)
+	return args.Error(0)
+}
+
+func (m *mockEmail) SendHold(typeS string, params *mail.EmailParams) error {
+	args := m.Called(typeS)
+	return args.Error(0)
+}
+
+func (m *mockEmail) SendReminder(typeS string, params *mail.EmailParams) error {
+	args := m.Called(typeS)
+	return args.Error(0)
+}
+
+func (m *mockEmail) SendDigest(params *mail.EmailParams) error {
+	args := m.Called(params)
+	return args.Error(0)
+}
+
 func NewTestApp() *App {
 	dbUrl := os.Getenv("TEST_DB_URL")
 	store, err := postgres.NewPostgresDataStore(dbUrl)
@@ -109,7 +139,7 @@ func NewTestApp() *App {
 	return &App{
 		router: mux.NewRouter().StrictSlash(true),
 		store:  store,
-		gDrive: nil,
+		blobs:  nil,
 	}
 }
 
@@ -2,146 +2,408 @@ package routes
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go-api/archive"
+	"go-api/auth"
+	"go-api/blob"
+	"go-api/db"
+	"go-api/model"
+	"go-api/utils"
+	"io"
 	"log"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
+const (
+	// DefaultArchiveRetention is how long bookings, offerings and assignments stay in the database after they end
+	DefaultArchiveRetention = 90 * 24 * time.Hour
+	// archiverLock is the store lock held while archiving, so only one instance archives at a time
+	archiverLock = "archiver"
+)
+
+var ArchiverBusyError = errors.New("archiver is already running")
+
 func (app *App) RegisterArchiverRoutes() {
-	app.router.HandleFunc("/archiver", app.Archive).Methods("POST")
+	app.router.HandleFunc("/archiver", app.authorize(app.Archive, auth.AdminOnly)).Methods("POST")
+	app.router.HandleFunc("/archiver/restore", app.authorize(app.RestoreArchive, auth.AdminOnly)).Methods("POST")
+	app.router.HandleFunc("/archiver/bookings", app.authorize(app.SearchArchivedBookings, auth.AdminOnly)).Methods("GET")
 }
 
+// Archive archives now instead of waiting for the schedule. With dry_run=true it only reports what would be archived,
+// format picks the archive format for this run
 func (app *App) Archive(w http.ResponseWriter, r *http.Request) {
-	writer := new(bytes.Buffer)
+	format := app.archiveFormatName()
+	if param := r.URL.Query().Get("format"); param != "" {
+		if _, err := archive.Lookup(param); err != nil {
+			respondError(w, http.StatusBadRequest, db.Validation, err.Error())
+			return
+		}
+		format = param
+	}
+	dryRun := false
+	if param := r.URL.Query().Get("dry_run"); param != "" {
+		parsed, err := strconv.ParseBool(param)
+		if err != nil {
+			log.Printf("App.Archive - error converting string to boolean from query parameter %v", err)
+			respondError(w, http.StatusBadRequest, db.Validation, "dry_run must be true or false")
+			return
+		}
+		dryRun = parsed
+	}
+	report, err := app.runArchiver(time.Now(), dryRun, format)
+	if err == ArchiverBusyError {
+		respondError(w, http.StatusConflict, db.Conflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("App.Archive - error archiving %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	status := http.StatusOK
+	if report.Status == model.ArchiveFailed {
+		status = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
 
-	now := time.Now()
-	archiveDateTimestamp := fmt.Sprintf("%d", now.Unix())
-	archiveFileName := fmt.Sprintf("Test_%s.archive", archiveDateTimestamp)
-	writer.WriteString(fmt.Sprintf("%s\n", archiveFileName)) // Write file name
+func (app *App) retentionPeriod() time.Duration {
+	if app.archiveRetention == 0 {
+		return DefaultArchiveRetention
+	}
+	return app.archiveRetention
+}
 
-	if err := app.WriteOfferings(writer, now); err != nil {
-		WriteLine(writer, "-- error writing offerings")
-		log.Println("App.Archive.Offerings:", err)
+func (app *App) archiveFormatName() string {
+	if app.archiveFormat == "" {
+		return archive.DefaultFormat
 	}
+	return app.archiveFormat
+}
 
-	if err := app.WriteBookings(writer, now); err != nil {
-		WriteLine(writer, "-- error writing bookings")
-		log.Println("App.Archive.Bookings:", err)
+// StartArchiver archives on the app's cron schedule until the app stops. Every instance runs the schedule, the
+// archiver lock makes sure only one of them archives each time
+func (app *App) StartArchiver() {
+	if app.archiveSchedule == nil {
+		return
 	}
+	go func() {
+		for {
+			next := app.archiveSchedule.Next(time.Now())
+			if next.IsZero() {
+				log.Println("App.StartArchiver - archive schedule never runs")
+				return
+			}
+			time.Sleep(time.Until(next))
+			report, err := app.runArchiver(next, app.archiveDryRun, app.archiveFormatName())
+			if err == ArchiverBusyError {
+				log.Println("App.StartArchiver - another instance is archiving")
+				continue
+			}
+			if err != nil {
+				log.Printf("App.StartArchiver - error archiving %v", err)
+				continue
+			}
+			if report.Status == model.ArchiveFailed {
+				log.Printf("App.StartArchiver - archiving failed: %s", report.Error)
+				continue
+			}
+			log.Printf("App.StartArchiver - %s %s %+v", report.Status, report.File, report.Sections)
+		}
+	}()
+}
 
-	if err := app.WriteWorkspaceAssignments(writer, now); err != nil {
-		WriteLine(writer, "-- error writing assignments")
-		log.Println("App.Archive.Assignments:", err)
+// runArchiver moves the bookings, offerings and assignments that ended more than the retention before now, and the
+// deleted workspaces and floors nothing references anymore, into an archive file in the blob store. Rows are only
+// deleted, all in one transaction, once the stored file has been read back and matches its checksum; a run that
+// fails at any point leaves the database as it was. On a dry run nothing is stored or deleted
+func (app *App) runArchiver(now time.Time, dryRun bool, formatName string) (*model.ArchiveReport, error) {
+	format, err := archive.Lookup(formatName)
+	if err != nil {
+		return nil, err
 	}
+	release, acquired, err := app.store.LockProvider.TryLock(archiverLock)
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, ArchiverBusyError
+	}
+	defer release()
 
-	if err := app.WriteWorkspaces(writer); err != nil {
-		WriteLine(writer, "-- error writing workspaces")
-		log.Println("App.Archive.Workspaces:", err)
+	cutoff := now.Add(-app.retentionPeriod()).UTC()
+	report := &model.ArchiveReport{
+		Format:   formatName,
+		Cutoff:   cutoff,
+		DryRun:   dryRun,
+		Sections: make([]*model.ArchiveSection, 0),
+	}
+	rows := &model.ArchivedRows{}
+	rows.Offerings, err = app.store.OfferingProvider.GetExpiredOfferings(cutoff)
+	addArchiveSection(report, archive.OfferingsSection, len(rows.Offerings), err)
+	rows.Bookings, err = app.store.BookingProvider.GetExpiredBookings(cutoff)
+	addArchiveSection(report, archive.BookingsSection, len(rows.Bookings), err)
+	rows.Assignments, err = app.store.AssigneeProvider.GetExpiredAssignments(cutoff)
+	addArchiveSection(report, archive.AssignmentsSection, len(rows.Assignments), err)
+	rows.Workspaces, err = app.store.ArchiveProvider.GetArchivableWorkspaces(cutoff)
+	addArchiveSection(report, archive.WorkspacesSection, len(rows.Workspaces), err)
+	rows.Floors, err = app.store.ArchiveProvider.GetArchivableFloors(cutoff)
+	addArchiveSection(report, archive.FloorsSection, len(rows.Floors), err)
+	for _, section := range report.Sections {
+		if section.Error != "" {
+			return failArchive(report, "not every section could be read"), nil
+		}
+	}
+	if dryRun {
+		report.Status = model.ArchiveDryRun
+		return report, nil
+	}
+	if len(rows.Offerings)+len(rows.Bookings)+len(rows.Assignments)+len(rows.Workspaces)+len(rows.Floors) == 0 {
+		report.Status = model.ArchiveEmpty
+		return report, nil
 	}
 
-	if err := app.WriteFloors(writer); err != nil {
-		WriteLine(writer, "-- error writing floors")
-		log.Println("App.Archive.Floors:", err)
+	archiveFileName := fmt.Sprintf("archive-%s%s", now.UTC().Format("20060102T150405Z"), format.Extension())
+	key := blob.ArchivePrefix + archiveFileName
+	content := new(bytes.Buffer)
+	if err = format.Write(content, archiveFileName, rows); err != nil {
+		log.Printf("App.runArchiver - error writing archive %v", err)
+		return failArchive(report, "the archive could not be written"), nil
+	}
+	sum := sha256.Sum256(content.Bytes())
+	report.Checksum = hex.EncodeToString(sum[:])
+	if err = app.blobs.Put(key, format.ContentType(), bytes.NewReader(content.Bytes())); err != nil {
+		log.Printf("App.runArchiver - error storing archive %v", err)
+		app.deleteArchive(key)
+		return failArchive(report, "the archive could not be stored"), nil
+	}
+	if err = app.verifyArchive(key, report.Checksum); err != nil {
+		log.Printf("App.runArchiver - error verifying archive %v", err)
+		app.deleteArchive(key)
+		return failArchive(report, "the stored archive does not match its checksum"), nil
+	}
+	if err = app.store.ArchiveProvider.DeleteArchivedRows(rows, cutoff); err != nil {
+		log.Printf("App.runArchiver - error deleting archived rows %v", err)
+		// the rows are all still in the database, so the archive would only duplicate them
+		app.deleteArchive(key)
+		if domainErr := db.AsError(err); domainErr != nil {
+			return failArchive(report, domainErr.Message), nil
+		}
+		return failArchive(report, "the archived rows could not be deleted"), nil
 	}
+	report.Status = model.ArchiveArchived
+	report.File = archiveFileName
+	return report, nil
+}
 
-	if err := app.gDrive.UploadArchiveDataFile(archiveFileName, writer); err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusBadRequest)
-		return
+func addArchiveSection(report *model.ArchiveReport, name string, rows int, err error) {
+	section := &model.ArchiveSection{Name: name, Rows: rows}
+	if err != nil {
+		log.Printf("App.runArchiver - error reading %s %v", name, err)
+		section.Error = fmt.Sprintf("%s could not be read", strings.ToLower(name))
 	}
+	report.Sections = append(report.Sections, section)
 }
 
-func WriteLine(w *bytes.Buffer, header string) {
-	w.WriteString(fmt.Sprintf("%s\n", header))
+func failArchive(report *model.ArchiveReport, message string) *model.ArchiveReport {
+	report.Status = model.ArchiveFailed
+	report.Error = message
+	return report
 }
 
-func (app *App) WriteBookings(w *bytes.Buffer, now time.Time) error {
-	bookings, err := app.store.BookingProvider.GetExpiredBookings(now)
+// verifyArchive reads the archive stored at key back and checks it against checksum
+func (app *App) verifyArchive(key string, checksum string) error {
+	object, err := app.blobs.Get(key)
 	if err != nil {
 		return err
 	}
-	WriteLine(w, "== Bookings(id|~|workspace_id|~|user_id|~|created_by|~|start_date|~|end_date|~|cancelled)")
-	ids := make([]string, 0)
-	for _, b := range bookings {
-		WriteLine(w, fmt.Sprintf(
-			"%s|~|%s|~|%s|~|%s|~|%s|~|%s|~|%t",
-			b.ID, b.WorkspaceID, b.UserID, b.CreatedBy,
-			b.StartDate.Format(time.RFC3339), b.EndDate.Format(time.RFC3339), b.Cancelled,
-		))
-		ids = append(ids, b.ID)
-	}
-	return app.store.BookingProvider.DeleteBookings(ids)
+	defer object.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, object); err != nil {
+		return err
+	}
+	if stored := hex.EncodeToString(hash.Sum(nil)); stored != checksum {
+		return fmt.Errorf("stored archive %s has checksum %s, expected %s", key, stored, checksum)
+	}
+	return nil
+}
+
+// deleteArchive removes the archive of a failed run
+func (app *App) deleteArchive(key string) {
+	if err := app.blobs.Delete(key); err != nil {
+		log.Printf("App.runArchiver - error deleting archive %s of failed run %v", key, err)
+	}
 }
 
-func (app *App) WriteOfferings(w *bytes.Buffer, now time.Time) error {
-	offerings, err := app.store.OfferingProvider.GetExpiredOfferings(now)
+// RestoreArchive puts the archived rows picked by the request body back into the database, along with the archived
+// workspaces and floors they need. Rows already in the database are skipped, so restoring twice is harmless
+func (app *App) RestoreArchive(w http.ResponseWriter, r *http.Request) {
+	var input model.RestoreInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		log.Printf("App.RestoreArchive - error parsing body %v", err)
+		respondError(w, http.StatusBadRequest, db.Validation, "request body is not valid JSON")
+		return
+	}
+	filter := &archive.Filter{IDs: input.IDs, UserID: input.UserID}
+	if input.Start != nil {
+		filter.Start = *input.Start
+	}
+	if input.End != nil {
+		filter.End = *input.End
+	}
+	if len(filter.IDs) == 0 && filter.UserID == "" && filter.Start.IsZero() && filter.End.IsZero() {
+		respondError(w, http.StatusBadRequest, db.Validation, "pick the rows to restore by ids, user or time")
+		return
+	}
+
+	release, acquired, err := app.store.LockProvider.TryLock(archiverLock)
 	if err != nil {
-		return err
+		log.Printf("App.RestoreArchive - error taking the archiver lock %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if !acquired {
+		respondError(w, http.StatusConflict, db.Conflict, ArchiverBusyError.Error())
+		return
 	}
-	WriteLine(w, "== Offerings(id|~|workspace_id|~|user_id|~|created_by|~|start_date|~|end_date|~|cancelled)")
-	ids := make([]string, 0)
-	for _, o := range offerings {
-		WriteLine(w, fmt.Sprintf(
-			"%s|~|%s|~|%s|~|%s|~|%s|~|%s|~|%t",
-			o.ID, o.WorkspaceID, o.UserID, o.CreatedBy,
-			o.StartDate.Format(time.RFC3339), o.EndDate.Format(time.RFC3339), o.Cancelled,
-		))
-		ids = append(ids, o.ID)
-	}
-	return app.store.OfferingProvider.DeleteOfferings(ids)
+	defer release()
+
+	archives, err := app.readArchives()
+	if err != nil {
+		log.Printf("App.RestoreArchive - error reading archives %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	report, err := app.store.ArchiveProvider.RestoreArchivedRows(archive.Merge(archives...).Select(filter))
+	if err != nil {
+		log.Printf("App.RestoreArchive - error restoring rows %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
 }
 
-func (app *App) WriteFloors(w *bytes.Buffer) error {
-	floors, err := app.store.FloorProvider.GetDeletedFloors()
+// SearchArchivedBookings finds archived bookings by user_id and/or workspace_id overlapping start and end (unix
+// timestamps), e.g. to find where someone sat on a given day. Nothing is restored
+func (app *App) SearchArchivedBookings(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	filter := &archive.Filter{UserID: queryParams.Get("user_id")}
+	workspaceId := queryParams.Get("workspace_id")
+	if start := queryParams.Get("start"); start != "" {
+		startTime, err := utils.TimeStampToTime(start)
+		if err != nil {
+			log.Printf("App.SearchArchivedBookings - invalid start time param: %v", err)
+			respondError(w, http.StatusBadRequest, db.Validation, "start must be a unix timestamp")
+			return
+		}
+		filter.Start = startTime
+	}
+	if end := queryParams.Get("end"); end != "" {
+		endTime, err := utils.TimeStampToTime(end)
+		if err != nil {
+			log.Printf("App.SearchArchivedBookings - invalid end time param: %v", err)
+			respondError(w, http.StatusBadRequest, db.Validation, "end must be a unix timestamp")
+			return
+		}
+		filter.End = endTime
+	}
+
+	archives, err := app.readArchives()
 	if err != nil {
-		return err
+		log.Printf("App.SearchArchivedBookings - error reading archives %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	all := archive.Merge(archives...)
+	workspaces := make(map[string]*model.Workspace)
+	for _, workspace := range all.Workspaces {
+		workspaces[workspace.ID] = workspace
 	}
-	WriteLine(w, "== Floors(id|~|name|~|address|~|download_url)")
-	ids := make([]string, 0)
-	for _, f := range floors {
-		WriteLine(w, fmt.Sprintf(
-			"%s|~|%s|~|%s|~|%s",
-			f.ID, f.Name, f.Address, f.DownloadURL,
-		))
-		ids = append(ids, f.ID)
+	floors := make(map[string]*model.Floor)
+	for _, floor := range all.Floors {
+		floors[floor.ID] = floor
 	}
-	return app.store.FloorProvider.DeleteFloors(ids)
+	bookings := make([]*model.ArchivedBooking, 0)
+	for _, a := range archives {
+		for _, booking := range a.Select(filter).Bookings {
+			if workspaceId != "" && booking.WorkspaceID != workspaceId {
+				continue
+			}
+			found := &model.ArchivedBooking{Booking: *booking, File: a.Name}
+			if workspace := app.archivedWorkspace(workspaces, booking.WorkspaceID); workspace != nil {
+				found.WorkspaceName = workspace.Name
+				found.FloorID = workspace.Floor
+				if floor := app.archivedFloor(floors, workspace.Floor); floor != nil {
+					found.FloorName = floor.Name
+				}
+			}
+			bookings = append(bookings, found)
+		}
+	}
+	sort.Slice(bookings, func(i, j int) bool { return bookings[i].StartDate.Before(bookings[j].StartDate) })
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(bookings)
 }
 
-func (app *App) WriteWorkspaces(w *bytes.Buffer) error {
-	workspaces, err := app.store.WorkspaceProvider.GetDeletedWorkspaces()
+// archivedWorkspace finds a workspace among the archived ones or, if it hasn't been archived, in the store. Found
+// workspaces are added to archived so each is only looked up once
+func (app *App) archivedWorkspace(archived map[string]*model.Workspace, id string) *model.Workspace {
+	if workspace, ok := archived[id]; ok {
+		return workspace
+	}
+	workspace, err := app.store.WorkspaceProvider.GetOneWorkspace(id)
 	if err != nil {
-		return err
+		workspace = nil
 	}
-	WriteLine(w, "== Workspaces(id|~|name|~|floor_id|~|details|~|props)")
-	ids := make([]string, 0)
-	for _, workspace := range workspaces {
-		props, _ := json.Marshal(workspace.Props)
-		WriteLine(w, fmt.Sprintf(
-			"%s|~|%s|~|%s|~|%s|~|%s",
-			workspace.ID, workspace.Name, workspace.Floor, workspace.Details, string(props),
-		))
-		ids = append(ids, workspace.ID)
-	}
-	return app.store.WorkspaceProvider.DeleteWorkspaces(ids)
+	archived[id] = workspace
+	return workspace
 }
 
-func (app *App) WriteWorkspaceAssignments(w *bytes.Buffer, now time.Time) error {
-	assignments, err := app.store.AssigneeProvider.GetExpiredAssignments(now)
+// archivedFloor is archivedWorkspace for floors
+func (app *App) archivedFloor(archived map[string]*model.Floor, id string) *model.Floor {
+	if floor, ok := archived[id]; ok {
+		return floor
+	}
+	floor, err := app.store.FloorProvider.GetOneFloor(id)
 	if err != nil {
-		return err
+		floor = nil
+	}
+	archived[id] = floor
+	return floor
+}
+
+// readArchives reads every archive file in the blob store
+func (app *App) readArchives() ([]*archive.Archive, error) {
+	infos, err := app.blobs.List(blob.ArchivePrefix)
+	if err != nil {
+		return nil, err
+	}
+	archives := make([]*archive.Archive, 0, len(infos))
+	for _, info := range infos {
+		object, err := app.blobs.Get(info.Key)
+		if err != nil {
+			return nil, err
+		}
+		a, err := archive.Read(object)
+		object.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", info.Key, err)
+		}
+		a.Name = strings.TrimPrefix(info.Key, blob.ArchivePrefix)
+		archives = append(archives, a)
 	}
-	WriteLine(w, "== Assignments(id|~|workspace_id|~|user_id|~|start_time|~|end_time)")
-	ids := make([]string, 0)
-	for _, a := range assignments {
-		WriteLine(w, fmt.Sprintf(
-			"%s|~|%s|~|%s|~|%s|~|%s",
-			a.ID, a.WorkspaceID, a.UserID,
-			a.StartDate.Format(time.RFC3339), a.EndDate.Format(time.RFC3339),
-		))
-		ids = append(ids, a.ID)
-	}
-	return app.store.AssigneeProvider.DeleteAssignments(ids)
+	return archives, nil
 }
@@ -0,0 +1,176 @@
+package routes
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go-api/archive"
+	"go-api/blob"
+	"go-api/db/memory"
+	"go-api/model"
+	"go-api/utils"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archives")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	blobs, err := blob.NewLocalStore(dir)
+	require.NoError(t, err)
+	store := memory.NewMemoryDataStore()
+	app := &App{store: store, blobs: blobs, archiveRetention: 30 * 24 * time.Hour}
+
+	floorId, err := store.FloorProvider.CreateFloor(&model.Floor{Name: "Floor 1"})
+	require.NoError(t, err)
+	workspaceId, err := store.WorkspaceProvider.CreateWorkspace(&model.Workspace{Name: "W1", Floor: floorId, Props: model.Attrs{}})
+	require.NoError(t, err)
+	now := time.Now()
+	_, err = store.OfferingProvider.CreateDefaultOffering(&model.Offering{WorkspaceID: workspaceId, StartDate: now.AddDate(0, -6, 0)})
+	require.NoError(t, err)
+	for _, daysAgo := range []int{60, 45, 10} {
+		_, err = store.BookingProvider.CreateBooking(&model.Booking{
+			UserID:      utils.EmptyUserUUID,
+			WorkspaceID: workspaceId,
+			StartDate:   now.AddDate(0, 0, -daysAgo),
+			EndDate:     now.AddDate(0, 0, -daysAgo).Add(8 * time.Hour),
+			CreatedBy:   utils.EmptyUserUUID,
+		})
+		require.NoError(t, err)
+	}
+
+	runArchive := func(url string) (*model.ArchiveReport, int) {
+		rr := executeReq(t, &testRouteConfig{Method: http.MethodPost, Handler: app.Archive, URL: url})
+		var report model.ArchiveReport
+		_ = json.Unmarshal(rr.Body.Bytes(), &report)
+		return &report, rr.Code
+	}
+
+	report, status := runArchive("/archiver?dry_run=true")
+	assert.Equal(t, http.StatusOK, status, "status code")
+	assert.Equal(t, model.ArchiveDryRun, report.Status)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 2, sectionRows(report, "Bookings"), "bookings older than the retention")
+	assert.Empty(t, report.File, "dry runs don't store an archive")
+	bookings, _ := store.BookingProvider.GetAllBookings()
+	assert.Len(t, bookings, 3, "dry runs don't delete")
+	stored, _ := blobs.List(blob.ArchivePrefix)
+	assert.Empty(t, stored)
+
+	release, acquired, err := store.LockProvider.TryLock(archiverLock)
+	require.NoError(t, err)
+	require.True(t, acquired)
+	_, status = runArchive("/archiver")
+	assert.Equal(t, http.StatusConflict, status, "another archiver holds the lock")
+	release()
+
+	// a run that can't store its archive deletes nothing
+	failing := &mockBlobStore{}
+	failing.On("Put", mock.Anything, mock.Anything).Return(errors.New("bucket unavailable"))
+	failing.On("Delete", mock.Anything).Return(nil)
+	app.blobs = failing
+	report, status = runArchive("/archiver")
+	assert.Equal(t, http.StatusInternalServerError, status, "status code")
+	assert.Equal(t, model.ArchiveFailed, report.Status)
+	assert.NotEmpty(t, report.Error)
+	assert.Empty(t, report.File)
+	bookings, _ = store.BookingProvider.GetAllBookings()
+	assert.Len(t, bookings, 3, "failed runs don't delete")
+	app.blobs = blobs
+
+	report, status = runArchive("/archiver")
+	assert.Equal(t, http.StatusOK, status, "status code")
+	assert.Equal(t, model.ArchiveArchived, report.Status)
+	assert.False(t, report.DryRun)
+	assert.Equal(t, 2, sectionRows(report, "Bookings"))
+	assert.Empty(t, report.Error)
+	bookings, _ = store.BookingProvider.GetAllBookings()
+	assert.Len(t, bookings, 1, "archived bookings are deleted")
+	stored, _ = blobs.List(blob.ArchivePrefix)
+	if assert.Len(t, stored, 1) {
+		assert.Equal(t, blob.ArchivePrefix+report.File, stored[0].Key)
+		object, err := blobs.Get(stored[0].Key)
+		require.NoError(t, err)
+		content, _ := ioutil.ReadAll(object)
+		object.Close()
+		sum := sha256.Sum256(content)
+		assert.Equal(t, hex.EncodeToString(sum[:]), report.Checksum)
+		archived, err := archive.Read(bytes.NewReader(content))
+		require.NoError(t, err)
+		assert.Equal(t, archive.DefaultFormat, archived.Manifest.Format)
+		assert.Len(t, archived.Bookings, 2, "archived bookings")
+	}
+
+	// with nothing left to archive no archive is stored
+	empty, status := runArchive("/archiver")
+	assert.Equal(t, http.StatusOK, status, "status code")
+	assert.Equal(t, model.ArchiveEmpty, empty.Status)
+	assert.Empty(t, empty.File)
+	stored, _ = blobs.List(blob.ArchivePrefix)
+	assert.Len(t, stored, 1, "no empty archive is stored")
+
+	_, status = runArchive("/archiver?dry_run=maybe")
+	assert.Equal(t, http.StatusBadRequest, status, "status code")
+	_, status = runArchive("/archiver?format=xml")
+	assert.Equal(t, http.StatusBadRequest, status, "unknown format")
+
+	day := now.AddDate(0, 0, -45)
+	rr := executeReq(t, &testRouteConfig{
+		Method:  http.MethodGet,
+		Handler: app.SearchArchivedBookings,
+		URL: fmt.Sprintf("/archiver/bookings?user_id=%s&start=%d&end=%d",
+			utils.EmptyUserUUID, day.Add(-time.Hour).Unix(), day.Add(time.Hour).Unix()),
+	})
+	assert.Equal(t, http.StatusOK, rr.Code, "status code")
+	var found []*model.ArchivedBooking
+	_ = json.Unmarshal(rr.Body.Bytes(), &found)
+	if assert.Len(t, found, 1, "the booking 45 days ago") {
+		assert.Equal(t, "W1", found[0].WorkspaceName)
+		assert.Equal(t, "Floor 1", found[0].FloorName)
+		assert.Equal(t, report.File, found[0].File)
+	}
+
+	restore := func(body string) (*model.RestoreReport, int) {
+		rr := executeReq(t, &testRouteConfig{
+			Method:  http.MethodPost,
+			Body:    strings.NewReader(body),
+			Handler: app.RestoreArchive,
+			URL:     "/archiver/restore",
+		})
+		var report model.RestoreReport
+		_ = json.Unmarshal(rr.Body.Bytes(), &report)
+		return &report, rr.Code
+	}
+	restored, status := restore(fmt.Sprintf(`{"user_id": %q}`, utils.EmptyUserUUID))
+	assert.Equal(t, http.StatusOK, status, "status code")
+	assert.Equal(t, 2, restored.Restored.Bookings)
+	bookings, _ = store.BookingProvider.GetAllBookings()
+	assert.Len(t, bookings, 3, "archived bookings are back")
+	restored, status = restore(fmt.Sprintf(`{"user_id": %q}`, utils.EmptyUserUUID))
+	assert.Equal(t, http.StatusOK, status, "status code")
+	assert.Equal(t, 0, restored.Restored.Bookings)
+	assert.Equal(t, 2, restored.Skipped.Bookings, "restoring twice changes nothing")
+
+	_, status = restore(`{}`)
+	assert.Equal(t, http.StatusBadRequest, status, "restoring everything needs a filter")
+}
+
+func sectionRows(report *model.ArchiveReport, name string) int {
+	for _, section := range report.Sections {
+		if section.Name == name {
+			return section.Rows
+		}
+	}
+	return -1
+}
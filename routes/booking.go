@@ -3,29 +3,67 @@ package routes
 import (
 	"encoding/json"
 	"github.com/gorilla/mux"
+	"go-api/auth"
+	"go-api/db"
 	"go-api/mail"
 	"go-api/model"
+	"go-api/notify"
 	"go-api/utils"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"strconv"
-	"strings"
+	"time"
 )
 
 func (app *App) RegisterBookingRoutes() {
-	app.router.HandleFunc("/bookings", app.CreateBooking).Methods("POST")
-	app.router.HandleFunc("/bookings/{id}", app.GetOneBooking).Methods("GET").Queries("expand", "{expand}")
-	app.router.HandleFunc("/bookings/{id}", app.GetOneBooking).Methods("GET") // Handles when Query is empty
-	app.router.HandleFunc("/bookings/workspaces/{workspace_id}", app.GetBookingsByWorkspaceID).Methods("GET").Queries("expand", "{expand}")
-	app.router.HandleFunc("/bookings/workspaces/{workspace_id}", app.GetBookingsByWorkspaceID).Methods("GET") // Handles when Query is empty
-	app.router.HandleFunc("/bookings/users/{user_id}", app.GetBookingsByUserID).Methods("GET").Queries("expand", "{expand}")
-	app.router.HandleFunc("/bookings/users/{user_id}", app.GetBookingsByUserID).Methods("GET") // Handles when Query is empty
-	app.router.HandleFunc("/bookings", app.GetBookingsByDateRange).Methods("GET").Queries("start", "{start:[0-9]+}").Queries("end", "{end:[0-9]+}")
-	app.router.HandleFunc("/bookings", app.GetAllBookings).Methods("GET").Queries("expand", "{expand}")
-	app.router.HandleFunc("/bookings", app.GetAllBookings).Methods("GET") // Handles when Query is empty
-	//app.router.HandleFunc("/bookings/{id}", app.UpdateBooking).Methods("PATCH")
-	app.router.HandleFunc("/bookings/{id}", app.RemoveBooking).Methods("DELETE")
+	app.router.HandleFunc("/bookings", app.authorize(app.CreateBooking, auth.BodyOwnerOrAdmin("user_id"))).Methods("POST")
+	app.router.HandleFunc("/bookings/{id}", app.authorize(app.GetOneBooking, auth.OwnerOrAdmin("id", app.bookingOwners))).Methods("GET").Queries("expand", "{expand}")
+	app.router.HandleFunc("/bookings/{id}", app.authorize(app.GetOneBooking, auth.OwnerOrAdmin("id", app.bookingOwners))).Methods("GET") // Handles when Query is empty
+	app.router.HandleFunc("/bookings/workspaces/{workspace_id}", app.authorize(app.GetBookingsByWorkspaceID)).Methods("GET").Queries("expand", "{expand}")
+	app.router.HandleFunc("/bookings/workspaces/{workspace_id}", app.authorize(app.GetBookingsByWorkspaceID)).Methods("GET") // Handles when Query is empty
+	app.router.HandleFunc("/bookings/users/{user_id}", app.authorize(app.GetBookingsByUserID, auth.SelfOrAdmin("user_id"))).Methods("GET").Queries("expand", "{expand}")
+	app.router.HandleFunc("/bookings/users/{user_id}", app.authorize(app.GetBookingsByUserID, auth.SelfOrAdmin("user_id"))).Methods("GET") // Handles when Query is empty
+	app.router.HandleFunc("/bookings", app.authorize(app.GetBookingsByDateRange)).Methods("GET").Queries("start", "{start:[0-9]+}").Queries("end", "{end:[0-9]+}")
+	app.router.HandleFunc("/bookings", app.authorize(app.GetAllBookings)).Methods("GET").Queries("expand", "{expand}")
+	app.router.HandleFunc("/bookings", app.authorize(app.GetAllBookings)).Methods("GET") // Handles when Query is empty
+	app.router.HandleFunc("/bookings/{id}", app.authorize(app.UpdateBooking, auth.OwnerOrAdmin("id", app.bookingOwners))).Methods("PATCH")
+	app.router.HandleFunc("/bookings/{id}", app.authorize(app.RemoveBooking, auth.OwnerOrAdmin("id", app.bookingOwners))).Methods("DELETE")
+}
+
+func (app *App) bookingOwners(id string) ([]string, error) {
+	booking, err := app.store.BookingProvider.GetOneBooking(id)
+	if err != nil {
+		return nil, err
+	}
+	return []string{booking.UserID, booking.CreatedBy}, nil
+}
+
+// ownsBooking is whether the user on the request may see the booking: admins see every booking, other users the
+// ones they hold or made, like bookingOwners. Handlers only run without a user when called outside app.authorize
+func ownsBooking(r *http.Request, booking *model.Booking) bool {
+	user, ok := auth.UserFromContext(r.Context())
+	return !ok || user.IsAdmin || booking.UserID == user.ID || booking.CreatedBy == user.ID
+}
+
+func ownBookings(r *http.Request, bookings []*model.Booking) []*model.Booking {
+	own := make([]*model.Booking, 0, len(bookings))
+	for _, booking := range bookings {
+		if ownsBooking(r, booking) {
+			own = append(own, booking)
+		}
+	}
+	return own
+}
+
+func ownExpandedBookings(r *http.Request, bookings []*model.ExpandedBooking) []*model.ExpandedBooking {
+	own := make([]*model.ExpandedBooking, 0, len(bookings))
+	for _, booking := range bookings {
+		if ownsBooking(r, &booking.Booking) {
+			own = append(own, booking)
+		}
+	}
+	return own
 }
 
 func (app *App) CreateBooking(w http.ResponseWriter, r *http.Request) {
@@ -34,66 +72,44 @@ func (app *App) CreateBooking(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		log.Printf("App.CreateBooking - error reading request body %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "could not read the request body")
 		return
 	}
 	err = json.Unmarshal(reqBody, &newBooking)
 	if err != nil {
 		log.Printf("App.CreateBooking - error unmarshaling request body %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "request body is not valid JSON")
 		return
 	}
-	if newBooking.CreatedBy == "" {
+	if user, ok := auth.UserFromContext(r.Context()); ok {
+		newBooking.CreatedBy = user.ID
+	} else if newBooking.CreatedBy == "" {
 		newBooking.CreatedBy = newBooking.UserID
 	}
+	if !newBooking.EndDate.After(newBooking.StartDate) {
+		log.Printf("App.CreateBooking - end time must be after start time")
+		respondError(w, http.StatusBadRequest, db.Validation, "end time must be after start time")
+		return
+	}
 	id, err := app.store.BookingProvider.CreateBooking(&newBooking)
 	if err != nil {
 		log.Printf("App.CreateBooking - error creating booking %v", err)
-		if strings.Contains(err.Error(), "invalid") {
-			w.WriteHeader(http.StatusBadRequest)
-		} else {
-			w.WriteHeader(http.StatusInternalServerError)
-		}
+		respondStoreError(w, err, http.StatusInternalServerError)
 		return
 	}
 	newBooking.ID = id
-
-	user, err1 := app.store.UserProvider.GetOneUser(newBooking.UserID)
-	// todo check for user and send to creator as well
-	eBooking, err2 := app.store.BookingProvider.GetOneExpandedBooking(id)
-	floor, err3 := app.store.FloorProvider.GetOneFloor(eBooking.FloorID)
-	if err1 == nil && err2 == nil && err3 == nil {
-		err = app.email.SendConfirmation(
-			mail.Booking,
-			&mail.EmailParams{
-				Name:          user.Name,
-				Email:         user.Email,
-				WorkspaceName: eBooking.WorkspaceName,
-				FloorName:     eBooking.FloorName,
-				FloorAddress:  floor.Address,
-				Start:         eBooking.StartDate,
-				End:           eBooking.EndDate,
-			},
-		)
-		if err != nil {
-			log.Printf("Error sending email: %+v", err)
-		}
-	} else {
-		log.Printf(
-			"Error getting user: %+v; Error getting booking %+v; Error getting floor %+v\n",
-			err1, err2, err3,
-		)
-	}
+	// the store queued the confirmation email in the outbox along with the booking
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(newBooking)
+	app.publishBooking(notify.BookingCreated, id)
 }
 
 func (app *App) GetOneBooking(w http.ResponseWriter, r *http.Request) {
 	bookingID := mux.Vars(r)["id"]
 	if bookingID == "" {
 		log.Printf("App.GetOneBooking - empty booking id")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "booking id is required")
 		return
 	}
 	exp := r.FormValue("expand")
@@ -102,7 +118,7 @@ func (app *App) GetOneBooking(w http.ResponseWriter, r *http.Request) {
 		expand, err := strconv.ParseBool(exp)
 		if err != nil {
 			log.Printf("App.GetOneBooking - error converting string to boolean from query parameter %v", err)
-			w.WriteHeader(http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, db.Validation, "expand must be true or false")
 			return
 		}
 		expandBool = expand
@@ -111,7 +127,7 @@ func (app *App) GetOneBooking(w http.ResponseWriter, r *http.Request) {
 		expandedBooking, err := app.store.BookingProvider.GetOneExpandedBooking(bookingID)
 		if err != nil {
 			log.Printf("App.GetOneExpandedBooking - error getting expanded booking from provider %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -120,7 +136,7 @@ func (app *App) GetOneBooking(w http.ResponseWriter, r *http.Request) {
 		booking, err := app.store.BookingProvider.GetOneBooking(bookingID)
 		if err != nil {
 			log.Printf("App.GetOneBooking - error getting booking from provider %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -135,7 +151,7 @@ func (app *App) GetAllBookings(w http.ResponseWriter, r *http.Request) {
 		expand, err := strconv.ParseBool(exp)
 		if err != nil {
 			log.Printf("App.GetOneBooking - error converting string to boolean from query parameter %v", err)
-			w.WriteHeader(http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, db.Validation, "expand must be true or false")
 			return
 		}
 		expandBool = expand
@@ -144,19 +160,19 @@ func (app *App) GetAllBookings(w http.ResponseWriter, r *http.Request) {
 		expandedBookings, err := app.store.BookingProvider.GetAllExpandedBookings()
 		if err != nil {
 			log.Printf("App.GetAllExpandedBookings - error getting all expanded bookings from provider %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(expandedBookings)
+		json.NewEncoder(w).Encode(ownExpandedBookings(r, expandedBookings))
 	} else {
 		bookings, err := app.store.BookingProvider.GetAllBookings()
 		if err != nil {
 			log.Printf("App.GetAllBookings - error getting all bookings from provider %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
-		json.NewEncoder(w).Encode(bookings)
+		json.NewEncoder(w).Encode(ownBookings(r, bookings))
 	}
 }
 
@@ -165,7 +181,7 @@ func (app *App) GetBookingsByWorkspaceID(w http.ResponseWriter, r *http.Request)
 
 	if workspaceID == "" {
 		log.Printf("App.GetOneBooking - empty booking id")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "booking id is required")
 		return
 	}
 	exp := r.FormValue("expand")
@@ -174,7 +190,7 @@ func (app *App) GetBookingsByWorkspaceID(w http.ResponseWriter, r *http.Request)
 		expand, err := strconv.ParseBool(exp)
 		if err != nil {
 			log.Printf("App.GetOneBooking - error converting string to boolean from query parameter %v", err)
-			w.WriteHeader(http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, db.Validation, "expand must be true or false")
 			return
 		}
 		expandBool = expand
@@ -183,19 +199,19 @@ func (app *App) GetBookingsByWorkspaceID(w http.ResponseWriter, r *http.Request)
 		expandedBooking, err := app.store.BookingProvider.GetExpandedBookingsByWorkspaceID(workspaceID)
 		if err != nil {
 			log.Printf("App.GetExpandedBookingsByWorkspaceID - error getting expanded bookings by workspaceID from provider %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(expandedBooking)
+		json.NewEncoder(w).Encode(ownExpandedBookings(r, expandedBooking))
 	} else {
 		bookings, err := app.store.BookingProvider.GetBookingsByWorkspaceID(workspaceID)
 		if err != nil {
 			log.Printf("App.GetBookingsByWorkspaceID - error getting bookings by workspaceID from provider %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
-		json.NewEncoder(w).Encode(bookings)
+		json.NewEncoder(w).Encode(ownBookings(r, bookings))
 	}
 }
 
@@ -204,7 +220,7 @@ func (app *App) GetBookingsByUserID(w http.ResponseWriter, r *http.Request) {
 
 	if userID == "" {
 		log.Printf("App.GetOneBooking - empty booking id")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "booking id is required")
 		return
 	}
 	exp := r.FormValue("expand")
@@ -213,7 +229,7 @@ func (app *App) GetBookingsByUserID(w http.ResponseWriter, r *http.Request) {
 		expand, err := strconv.ParseBool(exp)
 		if err != nil {
 			log.Printf("App.GetOneBooking - error converting string to boolean from query parameter %v", err)
-			w.WriteHeader(http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, db.Validation, "expand must be true or false")
 			return
 		}
 		expandBool = expand
@@ -222,7 +238,7 @@ func (app *App) GetBookingsByUserID(w http.ResponseWriter, r *http.Request) {
 		expandedBooking, err := app.store.BookingProvider.GetExpandedBookingsByUserID(userID)
 		if err != nil {
 			log.Printf("App.GetExpandedBookingsByUserID - error getting expanded bookings by userID from provider %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -231,7 +247,7 @@ func (app *App) GetBookingsByUserID(w http.ResponseWriter, r *http.Request) {
 		bookings, err := app.store.BookingProvider.GetBookingsByUserID(userID)
 		if err != nil {
 			log.Printf("App.GetBookingsByUserID - error getting bookings by userID from provider %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(bookings)
@@ -246,12 +262,12 @@ func (app *App) GetBookingsByDateRange(w http.ResponseWriter, r *http.Request) {
 	endTime, errEnd := utils.TimeStampToTime(end)
 	if errStart != nil {
 		log.Printf("App.GetBookingsByDateRange - empty start time param: %v", errStart)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "start must be a unix timestamp")
 		return
 	}
 	if errEnd != nil {
 		log.Printf("App.GetBookingsByDateRange - empty end time param: %v", errEnd)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "end must be a unix timestamp")
 		return
 	}
 	exp := r.FormValue("expand")
@@ -260,7 +276,7 @@ func (app *App) GetBookingsByDateRange(w http.ResponseWriter, r *http.Request) {
 		expand, err := strconv.ParseBool(exp)
 		if err != nil {
 			log.Printf("App.GetOneBooking - error converting string to boolean from query parameter %v", err)
-			w.WriteHeader(http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, db.Validation, "expand must be true or false")
 			return
 		}
 		expandBool = expand
@@ -269,19 +285,19 @@ func (app *App) GetBookingsByDateRange(w http.ResponseWriter, r *http.Request) {
 		expandedBooking, err := app.store.BookingProvider.GetExpandedBookingsByDateRange(startTime, endTime)
 		if err != nil {
 			log.Printf("App.GetExpandedBookingsByDateRange - error getting expanded bookings by date range from provider %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(expandedBooking)
+		json.NewEncoder(w).Encode(ownExpandedBookings(r, expandedBooking))
 	} else {
 		bookings, err := app.store.BookingProvider.GetBookingsByDateRange(startTime, endTime)
 		if err != nil {
 			log.Printf("App.GetBookingsByDateRange - error getting bookings by date range from provider %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
-		json.NewEncoder(w).Encode(bookings)
+		json.NewEncoder(w).Encode(ownBookings(r, bookings))
 	}
 }
 
@@ -290,71 +306,116 @@ func (app *App) UpdateBooking(w http.ResponseWriter, r *http.Request) {
 
 	if bookingID == "" {
 		log.Printf("App.UpdateBooking - empty booking id")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "booking id is required")
 		return
 	}
-	var updatedBooking model.Booking
+	var input model.UpdateBookingInput
 	reqBody, err := ioutil.ReadAll(r.Body)
-	updatedBooking.ID = bookingID
 	if err != nil {
 		log.Printf("App.UpdateBooking - error reading request body %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "could not read the request body")
 		return
 	}
-	err = json.Unmarshal(reqBody, &updatedBooking)
+	err = json.Unmarshal(reqBody, &input)
 	if err != nil {
 		log.Printf("App.UpdateBooking - error unmarshaling request body %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "request body is not valid JSON")
+		return
+	}
+
+	updatedBooking, err := app.store.BookingProvider.GetOneBooking(bookingID)
+	if err != nil {
+		log.Printf("App.UpdateBooking - error getting booking from provider %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	if input.WorkspaceID != nil {
+		updatedBooking.WorkspaceID = *input.WorkspaceID
+	}
+	if input.StartDate != nil {
+		updatedBooking.StartDate = *input.StartDate
+	}
+	if input.EndDate != nil {
+		updatedBooking.EndDate = *input.EndDate
+	}
+	if !updatedBooking.EndDate.After(updatedBooking.StartDate) {
+		log.Printf("App.UpdateBooking - end time must be after start time")
+		respondError(w, http.StatusBadRequest, db.Validation, "end time must be after start time")
 		return
 	}
 
-	err = app.store.BookingProvider.UpdateBooking(bookingID, &updatedBooking)
+	err = app.store.BookingProvider.UpdateBooking(bookingID, updatedBooking)
 	if err != nil {
-		log.Printf("App.UpdateBooking - error getting all bookings from provider %v", err)
-		w.WriteHeader(http.StatusNotFound)
+		log.Printf("App.UpdateBooking - error updating booking %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
 		return
 	}
+
+	app.publishBooking(notify.BookingUpdated, bookingID)
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(updatedBooking)
 }
 
+// bookingEvent is the event of the given type about a booking, for the user it is for
+func (app *App) bookingEvent(eventType, bookingID string) (*notify.Event, error) {
+	eBooking, err := app.store.BookingProvider.GetOneExpandedBooking(bookingID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := app.store.UserProvider.GetOneUser(eBooking.UserID)
+	if err != nil {
+		return nil, err
+	}
+	floor, err := app.store.FloorProvider.GetOneFloor(eBooking.FloorID)
+	if err != nil {
+		return nil, err
+	}
+	return &notify.Event{
+		Type:    eventType,
+		ID:      bookingID,
+		Time:    time.Now(),
+		UserID:  user.ID,
+		FloorID: floor.ID,
+		Params: &mail.EmailParams{
+			ID:            bookingID,
+			Name:          user.Name,
+			Email:         user.Email,
+			WorkspaceName: eBooking.WorkspaceName,
+			FloorName:     eBooking.FloorName,
+			FloorAddress:  floor.Address,
+			Start:         eBooking.StartDate,
+			End:           eBooking.EndDate,
+			Locale:        user.Locale,
+			TimeZone:      user.TimeZone,
+		},
+	}, nil
+}
+
 func (app *App) RemoveBooking(w http.ResponseWriter, r *http.Request) {
 	bookingID := mux.Vars(r)["id"]
 
 	if bookingID == "" {
 		log.Printf("App.RemoveBooking - empty booking id")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "booking id is required")
 		return
 	}
 
 	err := app.store.BookingProvider.RemoveBooking(bookingID)
 	if err != nil {
 		log.Printf("App.RemoveBooking - error getting all bookings from provider %v", err)
-		w.WriteHeader(http.StatusNotFound)
+		respondStoreError(w, err, http.StatusNotFound)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
+	app.publishBooking(notify.BookingCancelled, bookingID)
 
-	// todo check for user and send to creator as well
-	eBooking, err1 := app.store.BookingProvider.GetOneExpandedBooking(bookingID)
-	if err1 != nil {
-		log.Printf("Erro getting booking %+v", err1)
-	}
-	user, err2 := app.store.UserProvider.GetOneUser(eBooking.UserID)
-	if err2 == nil {
-		_ = app.email.SendCancellation(
-			mail.Booking,
-			&mail.EmailParams{
-				Name:          user.Name,
-				Email:         user.Email,
-				WorkspaceName: eBooking.WorkspaceName,
-				FloorName:     eBooking.FloorName,
-				Start:         eBooking.StartDate,
-				End:           eBooking.EndDate,
-			},
-		)
-	} else {
-		log.Printf("Error getting user: %+v", err2)
+	// the store queued the cancellation email in the outbox along with the cancellation
+	booking, err := app.store.BookingProvider.GetOneBooking(bookingID)
+	if err != nil {
+		log.Printf("App.RemoveBooking - error getting booking %v", err)
+		return
 	}
+	app.processWaitlist(booking.WorkspaceID, booking.StartDate, booking.EndDate)
 }
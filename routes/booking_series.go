@@ -0,0 +1,192 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"go-api/auth"
+	"go-api/db"
+	"go-api/mail"
+	"go-api/model"
+	"go-api/notify"
+	"go-api/utils"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+)
+
+func (app *App) RegisterBookingSeriesRoutes() {
+	app.router.HandleFunc("/bookings/series", app.authorize(app.CreateBookingSeries, auth.BodyOwnerOrAdmin("user_id"))).Methods("POST")
+	app.router.HandleFunc("/bookings/series/{id}", app.GetBookingSeries).Methods("GET")
+	app.router.HandleFunc("/bookings/series/{id}", app.authorize(app.CancelBookingSeries, auth.OwnerOrAdmin("id", app.seriesOwners))).Methods("DELETE").Queries("from", "{from:[0-9]+}")
+	app.router.HandleFunc("/bookings/series/{id}", app.authorize(app.CancelBookingSeries, auth.OwnerOrAdmin("id", app.seriesOwners))).Methods("DELETE") // Handles when Query is empty
+}
+
+func (app *App) seriesOwners(id string) ([]string, error) {
+	series, err := app.store.BookingProvider.GetBookingSeries(id)
+	if err != nil {
+		return nil, err
+	}
+	return []string{series.UserID, series.CreatedBy}, nil
+}
+
+// userZone is the zone the series of the user with userID repeat in, so that occurrences keep their local time across
+// daylight saving changes: the user's own, or the default zone of the emails
+func (app *App) userZone(userID string) *time.Location {
+	var timeZone string
+	if user, err := app.store.UserProvider.GetOneUser(userID); err == nil {
+		timeZone = user.TimeZone
+	}
+	if app.templates != nil {
+		return app.templates.Zone(timeZone)
+	}
+	// apps without templates, e.g. in tests, fall back to the built in default
+	if zone, err := time.LoadLocation(timeZone); err == nil && timeZone != "" {
+		return zone
+	}
+	zone, err := time.LoadLocation(mail.DefaultTimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return zone
+}
+
+func (app *App) CreateBookingSeries(w http.ResponseWriter, r *http.Request) {
+	var series model.BookingSeries
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("App.CreateBookingSeries - error reading request body %v", err)
+		respondError(w, http.StatusBadRequest, db.Validation, "could not read the request body")
+		return
+	}
+	err = json.Unmarshal(reqBody, &series)
+	if err != nil {
+		log.Printf("App.CreateBookingSeries - error unmarshaling request body %v", err)
+		respondError(w, http.StatusBadRequest, db.Validation, "request body is not valid JSON")
+		return
+	}
+	if user, ok := auth.UserFromContext(r.Context()); ok {
+		series.CreatedBy = user.ID
+	} else if series.CreatedBy == "" {
+		series.CreatedBy = series.UserID
+	}
+	if !series.EndDate.After(series.StartDate) {
+		log.Printf("App.CreateBookingSeries - end time must be after start time")
+		respondError(w, http.StatusBadRequest, db.Validation, "end time must be after start time")
+		return
+	}
+
+	occurrences, err := utils.ExpandRecurrence(series.StartDate, series.EndDate, &series.Recurrence, app.userZone(series.UserID))
+	if err != nil {
+		log.Printf("App.CreateBookingSeries - %v", err)
+		respondError(w, http.StatusBadRequest, db.Validation, err.Error())
+		return
+	}
+	bookings := make([]*model.Booking, 0, len(occurrences))
+	for _, occurrence := range occurrences {
+		bookings = append(bookings, &model.Booking{
+			WorkspaceID: series.WorkspaceID,
+			UserID:      series.UserID,
+			StartDate:   occurrence.Start,
+			EndDate:     occurrence.End,
+		})
+	}
+
+	bookings, conflicts, err := app.store.BookingProvider.CreateBookingSeries(&series, bookings)
+	if err != nil {
+		log.Printf("App.CreateBookingSeries - error creating booking series %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	event, err := app.bookingEvent(notify.BookingCreated, bookings[0].ID)
+	if err == nil {
+		event.ID, event.Series = series.ID, true
+		for _, booking := range bookings {
+			event.Params.AddOccurrence(booking.ID, booking.StartDate)
+		}
+		app.publish(event)
+	} else {
+		log.Printf("App.CreateBookingSeries - error building event %+v", err)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&model.BookingSeriesResult{
+		Series:    &series,
+		Bookings:  bookings,
+		Conflicts: conflicts,
+	})
+}
+
+func (app *App) GetBookingSeries(w http.ResponseWriter, r *http.Request) {
+	seriesID := mux.Vars(r)["id"]
+	if seriesID == "" {
+		log.Printf("App.GetBookingSeries - empty series id")
+		respondError(w, http.StatusBadRequest, db.Validation, "series id is required")
+		return
+	}
+	series, err := app.store.BookingProvider.GetBookingSeries(seriesID)
+	if err != nil {
+		log.Printf("App.GetBookingSeries - error getting series from provider %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	bookings, err := app.store.BookingProvider.GetBookingsBySeriesID(seriesID)
+	if err != nil {
+		log.Printf("App.GetBookingSeries - error getting bookings by series from provider %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&model.BookingSeriesResult{
+		Series:   series,
+		Bookings: bookings,
+	})
+}
+
+// CancelBookingSeries cancels a whole series, or with ?from=<unix timestamp> only the occurrences
+// starting at or after that time; single occurrences are cancelled with DELETE /bookings/{id}
+func (app *App) CancelBookingSeries(w http.ResponseWriter, r *http.Request) {
+	seriesID := mux.Vars(r)["id"]
+	if seriesID == "" {
+		log.Printf("App.CancelBookingSeries - empty series id")
+		respondError(w, http.StatusBadRequest, db.Validation, "series id is required")
+		return
+	}
+	var from time.Time
+	if fromParam := r.FormValue("from"); fromParam != "" {
+		fromTime, err := utils.TimeStampToTime(fromParam)
+		if err != nil {
+			log.Printf("App.CancelBookingSeries - invalid from param: %v", err)
+			respondError(w, http.StatusBadRequest, db.Validation, "from must be a unix timestamp")
+			return
+		}
+		from = fromTime
+	}
+
+	cancelled, err := app.store.BookingProvider.CancelBookingSeries(seriesID, from)
+	if err != nil {
+		log.Printf("App.CancelBookingSeries - error cancelling series %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+
+	if len(cancelled) > 0 {
+		event, err := app.bookingEvent(notify.BookingCancelled, cancelled[0].ID)
+		if err == nil {
+			event.ID, event.Series = seriesID, true
+			for _, booking := range cancelled {
+				event.Params.AddOccurrence(booking.ID, booking.StartDate)
+			}
+			app.publish(event)
+		} else {
+			log.Printf("App.CancelBookingSeries - error building event %+v", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(cancelled)
+	for _, booking := range cancelled {
+		app.processWaitlist(booking.WorkspaceID, booking.StartDate, booking.EndDate)
+	}
+}
@@ -4,12 +4,22 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-api/auth"
+	"go-api/db"
+	"go-api/db/memory"
 	"go-api/mail"
 	"go-api/model"
+	"go-api/notify"
 	"go-api/utils"
 	"log"
 	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
 )
 
 func bookingEqualMinusID(this *model.Booking, other *model.Booking) bool { // To be used when testing Creation, as ID will not be known in advance.
@@ -345,29 +355,23 @@ func (suite *AppTestSuite) Test_CreateBooking() {
 	assert.Equal(t, newBooking, payload2, "The created booking is not the same as the sent request.")
 }
 
-var startBookingPatch, _ = utils.TimeStampToTime("7952342400")
-var endBookingPatch, _ = utils.TimeStampToTime("7952515199")
 var patchBooking = &model.Booking{
 	ID:          newBooking.ID, // Unknown at this point
-	UserID:      "8b5bb736-6a1d-3378-8e71-ab45fe8beb84",
-	WorkspaceID: "aad40cbb-4baf-3931-a5d2-6f98b414182a",
-	Cancelled:   true,
-	StartDate:   startBookingPatch,
-	EndDate:     endBookingPatch,
-	CreatedBy:   "e99a988a-1d41-3997-8d59-959a48ac24a0",
+	UserID:      newBooking.UserID,
+	WorkspaceID: newBooking.WorkspaceID,
+	Cancelled:   false,
+	StartDate:   date("2019-01-18T00:00:00Z"),
+	EndDate:     date("2019-01-19T12:00:00Z"),
+	CreatedBy:   newBooking.CreatedBy,
 }
 
 func (suite *AppTestSuite) Test_PatchBooking() {
 	t := suite.T()
 	// Assume ID exists since we just create it
-	// Change everything but the ID
+	// Move it later inside the same offering; it overlaps its old time which must not count as a conflict
 	requestBody, _ := json.Marshal(map[string]interface{}{
-		"workspace_id": patchBooking.WorkspaceID,
-		"user_id":      patchBooking.UserID,
-		"start_time":   patchBooking.StartDate,
-		"end_time":     patchBooking.EndDate,
-		"cancelled":    patchBooking.Cancelled,
-		"created_by":   patchBooking.CreatedBy,
+		"start_time": patchBooking.StartDate,
+		"end_time":   patchBooking.EndDate,
 	})
 	rr2 := executeReq(t, &testRouteConfig{
 		Method:  http.MethodPatch,
@@ -378,12 +382,50 @@ func (suite *AppTestSuite) Test_PatchBooking() {
 			"id": newBooking.ID,
 		},
 	})
-	// Response 200
+	assert.Equal(t, http.StatusOK, rr2.Code, "status code")
 	var payload2 *model.Booking
 	_ = json.Unmarshal(rr2.Body.Bytes(), &payload2)
 	// Just give it the ID since we want to use assert
-	patchBooking.ID = payload2.ID
-	assert.Equal(t, patchBooking, payload2, "The created booking is not the same as the sent request.")
+	patchBooking.ID = newBooking.ID
+	assert.Equal(t, patchBooking, payload2, "The patched booking is not the same as the sent request.")
+	assert.NotNil(t, queuedNotification(t, suite.app.store, model.NotificationUpdate, newBooking.ID), "update queued")
+}
+
+func (suite *AppTestSuite) Test_PatchBookingConflict() {
+	t := suite.T()
+	cases := map[string]map[string]interface{}{
+		// Booking6 already holds this workspace on the 22nd
+		"overlapping booking": {
+			"start_time": date("2019-01-22T00:00:00Z"),
+			"end_time":   date("2019-01-22T12:00:00Z"),
+		},
+		// The workspace isn't offered on the 20th
+		"outside offering": {
+			"start_time": date("2019-01-20T00:00:00Z"),
+			"end_time":   date("2019-01-20T12:00:00Z"),
+		},
+		"end before start": {
+			"start_time": date("2019-01-19T00:00:00Z"),
+			"end_time":   date("2019-01-18T12:00:00Z"),
+		},
+	}
+	for name, body := range cases {
+		requestBody, _ := json.Marshal(body)
+		rr := executeReq(t, &testRouteConfig{
+			Method:  http.MethodPatch,
+			Body:    bytes.NewBuffer(requestBody),
+			Handler: suite.app.UpdateBooking,
+			URL:     fmt.Sprintf("/bookings/%s", newBooking.ID),
+			URLParams: map[string]string{
+				"id": newBooking.ID,
+			},
+		})
+		expected := http.StatusBadRequest
+		if name == "overlapping booking" {
+			expected = http.StatusConflict
+		}
+		assert.Equal(t, expected, rr.Code, name)
+	}
 }
 
 func (suite *AppTestSuite) Test_ZDeleteBooking() {
@@ -418,3 +460,299 @@ func (suite *AppTestSuite) Test_ZDeleteBooking() {
 	})
 	assert.Equal(t, http.StatusOK, rr.Code, "status code") // todo: For now, Delete never fails
 }
+
+func (suite *AppTestSuite) Test_BookingSeries() {
+	t := suite.T()
+	mockEmail := new(mockEmail)
+	mockEmail.On("SendConfirmation", mail.BookingSeries).Return(nil)
+	mockEmail.On("SendCancellation", mail.BookingSeries).Return(nil)
+	suite.app.email = mockEmail
+
+	// Workspace 3 is offered from the 22nd until noon on the 27th, so the last occurrence conflicts
+	requestBody, _ := json.Marshal(map[string]interface{}{
+		"workspace_id": Booking5.WorkspaceID,
+		"user_id":      Booking5.UserID,
+		"start_time":   date("2019-01-22T09:00:00Z"),
+		"end_time":     date("2019-01-22T17:00:00Z"),
+		"recurrence": map[string]interface{}{
+			"frequency": model.FrequencyDaily,
+			"count":     6,
+		},
+	})
+	rr := executeReq(t, &testRouteConfig{
+		Method:  http.MethodPost,
+		Body:    bytes.NewBuffer(requestBody),
+		Handler: suite.app.CreateBookingSeries,
+		URL:     "/bookings/series",
+	})
+	assert.Equal(t, http.StatusCreated, rr.Code, "status code")
+	var created model.BookingSeriesResult
+	_ = json.Unmarshal(rr.Body.Bytes(), &created)
+	if !assert.Len(t, created.Bookings, 5, "bookings") {
+		return
+	}
+	assert.Len(t, created.Conflicts, 1, "conflicts")
+	assert.Equal(t, date("2019-01-27T09:00:00Z"), created.Conflicts[0].StartDate)
+	for _, booking := range created.Bookings {
+		assert.Equal(t, created.Series.ID, booking.SeriesID)
+	}
+
+	seriesID := created.Series.ID
+	rr = executeReq(t, &testRouteConfig{
+		Method:    http.MethodGet,
+		Handler:   suite.app.GetBookingSeries,
+		URL:       fmt.Sprintf("/bookings/series/%s", seriesID),
+		URLParams: map[string]string{"id": seriesID},
+	})
+	assert.Equal(t, http.StatusOK, rr.Code, "status code")
+	var fetched model.BookingSeriesResult
+	_ = json.Unmarshal(rr.Body.Bytes(), &fetched)
+	assert.Equal(t, created.Bookings, fetched.Bookings)
+
+	// This and following: the 25th and 26th
+	rr = executeReq(t, &testRouteConfig{
+		Method:    http.MethodDelete,
+		Handler:   suite.app.CancelBookingSeries,
+		URL:       fmt.Sprintf("/bookings/series/%s?from=1548374400", seriesID),
+		URLParams: map[string]string{"id": seriesID},
+	})
+	assert.Equal(t, http.StatusOK, rr.Code, "status code")
+	var cancelled []*model.Booking
+	_ = json.Unmarshal(rr.Body.Bytes(), &cancelled)
+	assert.Len(t, cancelled, 2, "cancelled from the 25th")
+
+	// The whole series
+	rr = executeReq(t, &testRouteConfig{
+		Method:    http.MethodDelete,
+		Handler:   suite.app.CancelBookingSeries,
+		URL:       fmt.Sprintf("/bookings/series/%s", seriesID),
+		URLParams: map[string]string{"id": seriesID},
+	})
+	assert.Equal(t, http.StatusOK, rr.Code, "status code")
+	_ = json.Unmarshal(rr.Body.Bytes(), &cancelled)
+	assert.Len(t, cancelled, 3, "remaining occurrences")
+
+	rr = executeReq(t, &testRouteConfig{
+		Method:    http.MethodDelete,
+		Handler:   suite.app.CancelBookingSeries,
+		URL:       fmt.Sprintf("/bookings/series/%s", seriesID),
+		URLParams: map[string]string{"id": seriesID},
+	})
+	assert.Equal(t, http.StatusConflict, rr.Code, "already cancelled")
+}
+
+// TestBookingNotifications checks that the notifications a booking's store queues reach the booker, the user who
+// made the booking for them and the owner of the offering it is in, once the outbox delivers them
+func TestBookingNotifications(t *testing.T) {
+	store := memory.NewMemoryDataStore()
+	email := &mockEmail{}
+	email.On("SendConfirmation", mail.Offering).Return(nil)
+	email.On("SendConfirmation", mail.Booking).Return(nil)
+	email.On("SendCancellation", mail.Booking).Return(nil)
+	app := &App{store: store, email: email}
+	owner, booker, creator := "00000000-0000-4000-a000-0000000000a0", "00000000-0000-4000-a000-0000000000b0", "00000000-0000-4000-a000-0000000000c0"
+	require.NoError(t, store.UserProvider.CreateUser(&model.User{ID: owner, Name: "Alice", Email: "alice@example.com", Locale: "fr"}))
+	require.NoError(t, store.UserProvider.CreateUser(&model.User{ID: booker, Name: "Bob", Email: "bob@example.com"}))
+	require.NoError(t, store.UserProvider.CreateUser(&model.User{ID: creator, Name: "Carol", Email: "carol@example.com"}))
+	floorId, err := store.FloorProvider.CreateFloor(&model.Floor{Name: "West 2nd Avenue"})
+	require.NoError(t, err)
+	workspaceId, err := store.WorkspaceProvider.CreateAssignWorkspace(&model.Workspace{Name: "W-001", Floor: floorId}, owner)
+	require.NoError(t, err)
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	_, err = store.OfferingProvider.CreateOffering(&model.Offering{
+		UserID: owner, WorkspaceID: workspaceId, StartDate: start, EndDate: start.AddDate(0, 0, 3), CreatedBy: owner,
+	})
+	require.NoError(t, err)
+	app.deliverOutbox(time.Now())
+	email.AssertCalled(t, "SendConfirmation", mail.Offering)
+	var mu sync.Mutex
+	received := make(map[string][]*notify.Payload)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload notify.Payload
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		defer mu.Unlock()
+		received[r.URL.Path] = append(received[r.URL.Path], &payload)
+	}))
+	defer server.Close()
+	app.client = server.Client()
+	for _, channel := range []*model.Channel{
+		{UserID: owner, Kind: model.ChannelWebhook, URL: server.URL + "/alice"},
+		{UserID: creator, Kind: model.ChannelWebhook, URL: server.URL + "/carol"},
+		{FloorID: floorId, Kind: model.ChannelWebhook, URL: server.URL + "/floor"},
+	} {
+		_, err = store.ChannelProvider.CreateChannel(channel)
+		require.NoError(t, err)
+	}
+
+	booking := &model.Booking{UserID: booker, WorkspaceID: workspaceId, StartDate: start, EndDate: start.Add(8 * time.Hour), CreatedBy: creator}
+	booking.ID, err = store.BookingProvider.CreateBooking(booking)
+	require.NoError(t, err)
+	app.deliverOutbox(time.Now())
+	email.AssertNumberOfCalls(t, "SendConfirmation", 4)
+	mu.Lock()
+	assert.Empty(t, received, "channels are sent to by their own worker")
+	mu.Unlock()
+	app.deliverChannels(time.Now())
+	mu.Lock()
+	if assert.Len(t, received["/carol"], 1) {
+		assert.Equal(t, mail.RoleCreator, received["/carol"][0].Role)
+		assert.Equal(t, "Bob", received["/carol"][0].BookerName)
+	}
+	if assert.Len(t, received["/alice"], 1) {
+		assert.Equal(t, mail.RoleOwner, received["/alice"][0].Role)
+		assert.Equal(t, owner, received["/alice"][0].UserID)
+	}
+	if assert.Len(t, received["/floor"], 1, "the floor only hears from the booker's event") {
+		assert.Equal(t, booker, received["/floor"][0].UserID)
+		assert.Empty(t, received["/floor"][0].Role)
+	}
+	mu.Unlock()
+
+	require.NoError(t, store.BookingProvider.RemoveBooking(booking.ID))
+	app.deliverOutbox(time.Now())
+	email.AssertNumberOfCalls(t, "SendCancellation", 3)
+	messages, err := store.OutboxProvider.GetOutboxMessages(model.OutboxPending)
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+}
+
+// TestCreateBookingTimes checks that bookings and series that don't end after they start are rejected before
+// reaching the store
+func TestCreateBookingTimes(t *testing.T) {
+	store := memory.NewMemoryDataStore()
+	app := &App{store: store}
+	user := "00000000-0000-4000-a000-0000000000b0"
+	require.NoError(t, store.UserProvider.CreateUser(&model.User{ID: user, Name: "Bob", Email: "bob@example.com"}))
+	floorId, err := store.FloorProvider.CreateFloor(&model.Floor{Name: "West 2nd Avenue"})
+	require.NoError(t, err)
+	workspaceId, err := store.WorkspaceProvider.CreateWorkspace(&model.Workspace{Name: "W-001", Floor: floorId})
+	require.NoError(t, err)
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+
+	for _, end := range []time.Time{start, start.Add(-time.Hour)} {
+		body, _ := json.Marshal(map[string]interface{}{
+			"workspace_id": workspaceId, "user_id": user, "start_time": start, "end_time": end,
+		})
+		rr := executeReq(t, &testRouteConfig{
+			Method:  http.MethodPost,
+			Body:    bytes.NewBuffer(body),
+			Handler: app.CreateBooking,
+			URL:     "/bookings",
+		})
+		assert.Equal(t, http.StatusBadRequest, rr.Code, "booking ending at %v", end)
+		assert.Contains(t, rr.Body.String(), db.Validation)
+
+		body, _ = json.Marshal(map[string]interface{}{
+			"workspace_id": workspaceId, "user_id": user, "start_time": start, "end_time": end,
+			"recurrence": map[string]interface{}{"frequency": model.FrequencyDaily, "count": 2},
+		})
+		rr = executeReq(t, &testRouteConfig{
+			Method:  http.MethodPost,
+			Body:    bytes.NewBuffer(body),
+			Handler: app.CreateBookingSeries,
+			URL:     "/bookings/series",
+		})
+		assert.Equal(t, http.StatusBadRequest, rr.Code, "series ending at %v", end)
+	}
+	bookings, err := store.BookingProvider.GetBookingsByUserID(user)
+	require.NoError(t, err)
+	assert.Empty(t, bookings)
+}
+
+// TestBookingSeriesTimeZone checks that occurrences keep the local time of the user they are for across a daylight
+// saving change, and that users without a zone get the default one
+func TestBookingSeriesTimeZone(t *testing.T) {
+	store := memory.NewMemoryDataStore()
+	app := &App{store: store}
+	paris, vancouver := "00000000-0000-4000-a000-0000000000b0", "00000000-0000-4000-a000-0000000000c0"
+	require.NoError(t, store.UserProvider.CreateUser(&model.User{ID: paris, Name: "Bob", Email: "bob@example.com", TimeZone: "Europe/Paris"}))
+	require.NoError(t, store.UserProvider.CreateUser(&model.User{ID: vancouver, Name: "Carol", Email: "carol@example.com"}))
+	floorId, err := store.FloorProvider.CreateFloor(&model.Floor{Name: "West 2nd Avenue"})
+	require.NoError(t, err)
+
+	for user, zone := range map[string]string{paris: "Europe/Paris", vancouver: mail.DefaultTimeZone} {
+		workspaceId, err := store.WorkspaceProvider.CreateAssignWorkspace(&model.Workspace{Name: "W-" + zone, Floor: floorId}, "")
+		require.NoError(t, err)
+		location, err := time.LoadLocation(zone)
+		require.NoError(t, err)
+		// daylight saving time starts in both zones in the month after
+		start := time.Date(2030, time.February, 25, 9, 0, 0, 0, location)
+		body, _ := json.Marshal(map[string]interface{}{
+			"workspace_id": workspaceId, "user_id": user, "start_time": start, "end_time": start.Add(8 * time.Hour),
+			"recurrence": map[string]interface{}{"frequency": model.FrequencyWeekly, "count": 6},
+		})
+		rr := executeReq(t, &testRouteConfig{
+			Method:  http.MethodPost,
+			Body:    bytes.NewBuffer(body),
+			Handler: app.CreateBookingSeries,
+			URL:     "/bookings/series",
+		})
+		require.Equal(t, http.StatusCreated, rr.Code, zone)
+		var created model.BookingSeriesResult
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+		if assert.Len(t, created.Bookings, 6, zone) {
+			for _, booking := range created.Bookings {
+				assert.Equal(t, 9, booking.StartDate.In(location).Hour(), zone)
+			}
+			assert.NotEqual(t, created.Bookings[0].StartDate.UTC().Hour(), created.Bookings[5].StartDate.UTC().Hour(), zone)
+		}
+	}
+}
+
+// TestBookingVisibility checks that users only read their own bookings, and admins every booking
+func TestBookingVisibility(t *testing.T) {
+	store := memory.NewMemoryDataStore()
+	app := &App{store: store, router: mux.NewRouter()}
+	app.RegisterBookingRoutes()
+	bob := &model.User{ID: "00000000-0000-4000-a000-0000000000b0", Name: "Bob", Email: "bob@example.com"}
+	carol := &model.User{ID: "00000000-0000-4000-a000-0000000000c0", Name: "Carol", Email: "carol@example.com"}
+	admin := &model.User{ID: "00000000-0000-4000-a000-0000000000d0", Name: "Dave", Email: "dave@example.com", IsAdmin: true}
+	for _, user := range []*model.User{bob, carol, admin} {
+		require.NoError(t, store.UserProvider.CreateUser(user))
+	}
+	floorId, err := store.FloorProvider.CreateFloor(&model.Floor{Name: "West 2nd Avenue"})
+	require.NoError(t, err)
+	workspaceId, err := store.WorkspaceProvider.CreateAssignWorkspace(&model.Workspace{Name: "W-001", Floor: floorId}, "")
+	require.NoError(t, err)
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	bookingIds := make(map[string]string)
+	for i, user := range []*model.User{bob, carol} {
+		bookingStart := start.Add(time.Duration(i) * 24 * time.Hour)
+		bookingIds[user.ID], err = store.BookingProvider.CreateBooking(&model.Booking{
+			UserID: user.ID, WorkspaceID: workspaceId, StartDate: bookingStart, EndDate: bookingStart.Add(8 * time.Hour), CreatedBy: user.ID,
+		})
+		require.NoError(t, err)
+	}
+
+	get := func(user *model.User, url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		rr := httptest.NewRecorder()
+		app.router.ServeHTTP(rr, req.WithContext(auth.WithUser(req.Context(), user)))
+		return rr
+	}
+	for _, url := range []string{
+		"/bookings",
+		"/bookings?expand=true",
+		"/bookings/workspaces/" + workspaceId,
+		"/bookings/workspaces/" + workspaceId + "?expand=true",
+		fmt.Sprintf("/bookings?start=%d&end=%d", start.Add(-time.Hour).Unix(), start.AddDate(0, 0, 3).Unix()),
+	} {
+		var bookings []*model.Booking
+		rr := get(bob, url)
+		require.Equal(t, http.StatusOK, rr.Code, url)
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &bookings), url)
+		if assert.Len(t, bookings, 1, url) {
+			assert.Equal(t, bob.ID, bookings[0].UserID, url)
+		}
+		rr = get(admin, url)
+		require.Equal(t, http.StatusOK, rr.Code, url)
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &bookings), url)
+		assert.Len(t, bookings, 2, url)
+	}
+
+	assert.Equal(t, http.StatusOK, get(bob, "/bookings/"+bookingIds[bob.ID]).Code)
+	assert.Equal(t, http.StatusForbidden, get(bob, "/bookings/"+bookingIds[carol.ID]).Code)
+	assert.Equal(t, http.StatusForbidden, get(bob, "/bookings/"+bookingIds[carol.ID]+"?expand=true").Code)
+	assert.Equal(t, http.StatusOK, get(admin, "/bookings/"+bookingIds[carol.ID]).Code)
+}
@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"go-api/auth"
+	"go-api/db"
+	"go-api/ical"
+	"go-api/mail"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// feedHistory is how long bookings and offerings stay in calendar feeds after they end
+const feedHistory = 30 * 24 * time.Hour
+
+func (app *App) RegisterCalendarRoutes() {
+	app.router.HandleFunc("/users/{id}/calendar", app.authorize(app.GetCalendarFeedURL, auth.OwnerOrAdmin("id", userOwners))).Methods("GET")
+	app.router.HandleFunc("/users/{id}"+auth.FeedSuffix, app.authorize(app.GetCalendarFeed, auth.OwnerOrAdmin("id", userOwners))).Methods("GET")
+}
+
+// GetCalendarFeedURL returns the URL calendar apps subscribe to for the user's feed, which carries its own token
+func (app *App) GetCalendarFeedURL(w http.ResponseWriter, r *http.Request) {
+	if app.feeds == nil {
+		respondError(w, http.StatusNotFound, db.NotFound, "calendar feeds are not enabled")
+		return
+	}
+	id := mux.Vars(r)["id"]
+	if _, err := app.store.UserProvider.GetOneUser(id); err != nil {
+		log.Printf("App.GetCalendarFeedURL - error getting user from provider %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	feedUrl := fmt.Sprintf("%s/users/%s%s?token=%s", app.publicUrl, url.PathEscape(id), auth.FeedSuffix, app.feeds.Token(id))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"url": feedUrl})
+}
+
+// GetCalendarFeed returns the user's bookings and offerings that ended at most feedHistory ago as an iCalendar feed.
+// Open ended offerings have no end and are left out
+func (app *App) GetCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	user, err := app.store.UserProvider.GetOneUser(id)
+	if err != nil {
+		log.Printf("App.GetCalendarFeed - error getting user from provider %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	bookings, err := app.store.BookingProvider.GetExpandedBookingsByUserID(id)
+	if err != nil {
+		log.Printf("App.GetCalendarFeed - error getting bookings from provider %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	offerings, err := app.store.OfferingProvider.GetExpandedOfferingsByUserID(id)
+	if err != nil {
+		log.Printf("App.GetCalendarFeed - error getting offerings from provider %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	addresses, err := app.floorAddresses()
+	if err != nil {
+		log.Printf("App.GetCalendarFeed - error getting floors from provider %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	since := now.Add(-feedHistory)
+	calendar := &ical.Calendar{Method: ical.MethodPublish, Name: "IWork - " + user.Name, Stamp: now}
+	event := func(kind, id, workspaceName, floorID, floorName string, start, end time.Time) *ical.Event {
+		location := workspaceName + ", " + floorName
+		if addresses[floorID] != "" {
+			location += ", " + addresses[floorID]
+		}
+		return &ical.Event{
+			UID:      ical.UID(kind, id),
+			Status:   ical.StatusConfirmed,
+			Summary:  mail.Summary(kind, workspaceName, floorName),
+			Location: location,
+			Start:    start,
+			End:      end,
+			Free:     kind == mail.Offering,
+		}
+	}
+	for _, b := range bookings {
+		if !b.Cancelled && b.EndDate.After(since) {
+			calendar.Events = append(calendar.Events, event(mail.Booking, b.ID, b.WorkspaceName, b.FloorID, b.FloorName, b.StartDate, b.EndDate))
+		}
+	}
+	for _, o := range offerings {
+		if !o.Cancelled && o.EndDate.After(since) {
+			calendar.Events = append(calendar.Events, event(mail.Offering, o.ID, o.WorkspaceName, o.FloorID, o.FloorName, o.StartDate, o.EndDate))
+		}
+	}
+	w.Header().Set("Content-Type", calendar.MediaType())
+	w.Header().Set("Content-Disposition", `inline; filename="calendar.ics"`)
+	w.WriteHeader(http.StatusOK)
+	if err = calendar.Encode(w); err != nil {
+		log.Printf("App.GetCalendarFeed - error writing feed %v", err)
+	}
+}
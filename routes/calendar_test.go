@@ -0,0 +1,83 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-api/auth"
+	"go-api/db/memory"
+	"go-api/model"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendarFeed(t *testing.T) {
+	store := memory.NewMemoryDataStore()
+	app := &App{store: store}
+	owner, booker := "00000000-0000-4000-a000-0000000000a0", "00000000-0000-4000-a000-0000000000b0"
+	require.NoError(t, store.UserProvider.CreateUser(&model.User{ID: owner, Name: "Alice"}))
+	require.NoError(t, store.UserProvider.CreateUser(&model.User{ID: booker, Name: "Bob"}))
+	floorId, err := store.FloorProvider.CreateFloor(&model.Floor{Name: "West 2nd Avenue", Address: "151 W 2nd Ave"})
+	require.NoError(t, err)
+	workspaceId, err := store.WorkspaceProvider.CreateAssignWorkspace(&model.Workspace{Name: "W-001", Floor: floorId}, owner)
+	require.NoError(t, err)
+
+	start := time.Now().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	offeringId, err := store.OfferingProvider.CreateOffering(&model.Offering{
+		UserID: owner, WorkspaceID: workspaceId, StartDate: start, EndDate: start.AddDate(0, 0, 2), CreatedBy: owner,
+	})
+	require.NoError(t, err)
+	bookingId, err := store.BookingProvider.CreateBooking(&model.Booking{
+		UserID: booker, WorkspaceID: workspaceId, StartDate: start, EndDate: start.Add(8 * time.Hour), CreatedBy: booker,
+	})
+	require.NoError(t, err)
+	cancelledId, err := store.BookingProvider.CreateBooking(&model.Booking{
+		UserID: booker, WorkspaceID: workspaceId, StartDate: start.AddDate(0, 0, 1), EndDate: start.AddDate(0, 0, 1).Add(8 * time.Hour), CreatedBy: booker,
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.BookingProvider.RemoveBooking(cancelledId))
+
+	feed := func(id string) string {
+		rr := executeReq(t, &testRouteConfig{
+			Method:    http.MethodGet,
+			URL:       "/users/" + id + auth.FeedSuffix,
+			URLParams: map[string]string{"id": id},
+			Handler:   app.GetCalendarFeed,
+		})
+		require.Equal(t, http.StatusOK, rr.Code, "status code")
+		assert.Equal(t, "text/calendar; charset=utf-8; method=PUBLISH", rr.Header().Get("Content-Type"))
+		return strings.Replace(rr.Body.String(), "\r\n ", "", -1)
+	}
+	content := feed(booker)
+	assert.Contains(t, content, "X-WR-CALNAME:IWork - Bob\r\n")
+	assert.Contains(t, content, "UID:booking-"+bookingId+"@iwork\r\n")
+	assert.Contains(t, content, `LOCATION:W-001\, West 2nd Avenue\, 151 W 2nd Ave`+"\r\n")
+	assert.NotContains(t, content, cancelledId, "cancelled bookings are left out")
+	assert.NotContains(t, content, offeringId)
+	content = feed(owner)
+	assert.Contains(t, content, "UID:offering-"+offeringId+"@iwork\r\n")
+	assert.Contains(t, content, "TRANSP:TRANSPARENT\r\n")
+
+	rr := executeReq(t, &testRouteConfig{
+		Method:    http.MethodGet,
+		URL:       "/users/" + booker + "/calendar",
+		URLParams: map[string]string{"id": booker},
+		Handler:   app.GetCalendarFeedURL,
+	})
+	assert.Equal(t, http.StatusNotFound, rr.Code, "feeds are disabled without a secret")
+
+	app.feeds = auth.NewFeedAuthenticator("secret", store.UserProvider.GetOneUser)
+	app.publicUrl = "https://api.example.com"
+	rr = executeReq(t, &testRouteConfig{
+		Method:    http.MethodGet,
+		URL:       "/users/" + booker + "/calendar",
+		URLParams: map[string]string{"id": booker},
+		Handler:   app.GetCalendarFeedURL,
+	})
+	assert.Equal(t, http.StatusOK, rr.Code, "status code")
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, "https://api.example.com/users/"+booker+"/calendar.ics?token="+app.feeds.Token(booker), body["url"])
+}
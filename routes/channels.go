@@ -0,0 +1,200 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"go-api/auth"
+	"go-api/db"
+	"go-api/model"
+	"go-api/notify"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// channelBatch is small enough that a batch of requests timing out is sent well within deliveryLease
+const channelBatch = 20
+
+func (app *App) RegisterChannelRoutes() {
+	app.router.HandleFunc("/users/{id}/channels", app.authorize(app.GetUserChannels, auth.OwnerOrAdmin("id", userOwners))).Methods("GET")
+	app.router.HandleFunc("/users/{id}/channels", app.authorize(app.CreateUserChannel, auth.OwnerOrAdmin("id", userOwners))).Methods("POST")
+	app.router.HandleFunc("/users/{id}/channels/{channelId}", app.authorize(app.RemoveUserChannel, auth.OwnerOrAdmin("id", userOwners))).Methods("DELETE")
+	app.router.HandleFunc("/floors/{id}/channels", app.authorize(app.GetFloorChannels, auth.AdminOnly)).Methods("GET")
+	app.router.HandleFunc("/floors/{id}/channels", app.authorize(app.CreateFloorChannel, auth.AdminOnly)).Methods("POST")
+	app.router.HandleFunc("/floors/{id}/channels/{channelId}", app.authorize(app.RemoveFloorChannel, auth.AdminOnly)).Methods("DELETE")
+}
+
+func (app *App) GetUserChannels(w http.ResponseWriter, r *http.Request) {
+	app.getChannels(w, mux.Vars(r)["id"], "")
+}
+
+func (app *App) GetFloorChannels(w http.ResponseWriter, r *http.Request) {
+	app.getChannels(w, "", mux.Vars(r)["id"])
+}
+
+// CreateUserChannel adds a channel the user's notifications are sent to besides their email
+func (app *App) CreateUserChannel(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, err := app.store.UserProvider.GetOneUser(id); err != nil {
+		log.Printf("App.CreateUserChannel - error getting user from provider %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	app.createChannel(w, r, &model.Channel{UserID: id})
+}
+
+// CreateFloorChannel adds a channel the notifications about the floor's workspaces are sent to
+func (app *App) CreateFloorChannel(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, err := app.store.FloorProvider.GetOneFloor(id); err != nil {
+		log.Printf("App.CreateFloorChannel - error getting floor from provider %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	app.createChannel(w, r, &model.Channel{FloorID: id})
+}
+
+func (app *App) RemoveUserChannel(w http.ResponseWriter, r *http.Request) {
+	app.removeChannel(w, mux.Vars(r)["channelId"], mux.Vars(r)["id"], "")
+}
+
+func (app *App) RemoveFloorChannel(w http.ResponseWriter, r *http.Request) {
+	app.removeChannel(w, mux.Vars(r)["channelId"], "", mux.Vars(r)["id"])
+}
+
+// getChannels lists the channels of a user or a floor, without their secrets
+func (app *App) getChannels(w http.ResponseWriter, userId, floorId string) {
+	channels, err := app.store.ChannelProvider.GetChannels(userId, floorId)
+	if err != nil {
+		log.Printf("App.getChannels - error getting channels from provider %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	for _, channel := range channels {
+		channel.Secret = ""
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(channels)
+}
+
+// createChannel reads the kind, url, secret and events of a channel of the user or floor of owner and stores it
+func (app *App) createChannel(w http.ResponseWriter, r *http.Request, owner *model.Channel) {
+	var channel model.Channel
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("App.createChannel - error reading request body %v", err)
+		respondError(w, http.StatusBadRequest, db.Validation, "could not read the request body")
+		return
+	}
+	if err = json.Unmarshal(reqBody, &channel); err != nil {
+		log.Printf("App.createChannel - error unmarshaling request body %v", err)
+		respondError(w, http.StatusBadRequest, db.Validation, "request body is not valid JSON")
+		return
+	}
+	channel.UserID, channel.FloorID = owner.UserID, owner.FloorID
+	if err = validateChannel(&channel); err != nil {
+		respondError(w, http.StatusBadRequest, db.Validation, err.Error())
+		return
+	}
+	if _, err = app.store.ChannelProvider.CreateChannel(&channel); err != nil {
+		log.Printf("App.createChannel - error creating channel %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	channel.Secret = ""
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&channel)
+}
+
+// removeChannel removes a channel if it belongs to the user with userId or the floor with floorId
+func (app *App) removeChannel(w http.ResponseWriter, id, userId, floorId string) {
+	channel, err := app.store.ChannelProvider.GetOneChannel(id)
+	if err == nil && (channel.UserID != userId || channel.FloorID != floorId) {
+		err = db.NotFoundError
+	}
+	if err == nil {
+		err = app.store.ChannelProvider.RemoveChannel(id)
+	}
+	if err != nil {
+		log.Printf("App.removeChannel - error removing channel %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// validateChannel checks the kind, url and events of a channel. Webhooks need a secret to sign their requests
+func validateChannel(channel *model.Channel) error {
+	known := false
+	for _, kind := range model.ChannelKinds {
+		known = known || channel.Kind == kind
+	}
+	if !known {
+		return fmt.Errorf("unknown channel kind %q", channel.Kind)
+	}
+	u, err := url.Parse(channel.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %q", channel.URL)
+	}
+	if channel.Kind == model.ChannelWebhook && channel.Secret == "" {
+		return fmt.Errorf("webhooks need a secret")
+	}
+	for _, event := range channel.Events {
+		if !notify.IsEvent(event) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	return nil
+}
+
+// queueChannelDelivery queues body, what is posted to channel about an event of eventType, for StartChannels to send
+func (app *App) queueChannelDelivery(channel *model.Channel, eventType, key string, body []byte) error {
+	return app.store.ChannelProvider.QueueChannelDelivery(channel.ID, eventType, key, body)
+}
+
+// StartChannels sends the messages queued for channels until the app stops
+func (app *App) StartChannels() {
+	go func() {
+		ticker := time.NewTicker(deliveryInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			app.deliverChannels(now)
+		}
+	}()
+}
+
+// deliverChannels sends the deliveries due at now, and retries and forgets them like deliverWebhooks
+func (app *App) deliverChannels(now time.Time) {
+	if err := app.store.ChannelProvider.DeleteChannelDeliveriesBefore(now.Add(-deliveryRetention)); err != nil {
+		log.Printf("App.deliverChannels - error deleting old deliveries %v", err)
+	}
+	deliveries, err := app.store.ChannelProvider.ClaimChannelDeliveries(now, channelBatch, deliveryLease)
+	if err != nil {
+		log.Printf("App.deliverChannels - error claiming deliveries %v", err)
+		return
+	}
+	for _, delivery := range deliveries {
+		// a channel that can't be read is tried again once the lease of its delivery is up
+		channel, err := app.store.ChannelProvider.GetOneChannel(delivery.ChannelID)
+		if err != nil {
+			log.Printf("App.deliverChannels - error getting channel %s %v", delivery.ChannelID, err)
+			continue
+		}
+		app.recordAttempt("deliverChannels", &delivery.Delivery, app.sendChannelDelivery(channel, delivery),
+			app.store.ChannelProvider.MarkChannelDelivered, app.store.ChannelProvider.MarkChannelFailed)
+	}
+}
+
+// sendChannelDelivery posts a delivery to its channel and returns the outcome of the attempt
+func (app *App) sendChannelDelivery(channel *model.Channel, delivery *model.ChannelDelivery) *model.DeliveryAttempt {
+	sink, err := notify.NewSink(channel, app.client)
+	if err != nil {
+		return app.postDelivery(nil, err)
+	}
+	return app.postDelivery(sink.Request(delivery.Event, delivery.Body))
+}
@@ -0,0 +1,84 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-api/db/memory"
+	"go-api/model"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestChannels(t *testing.T) {
+	store := memory.NewMemoryDataStore()
+	app := &App{store: store}
+	userId := "00000000-0000-4000-a000-0000000000a0"
+	require.NoError(t, store.UserProvider.CreateUser(&model.User{ID: userId, Name: "Alice", Email: "alice@example.com"}))
+	floorId, err := store.FloorProvider.CreateFloor(&model.Floor{Name: "West 2nd Avenue"})
+	require.NoError(t, err)
+
+	create := func(handler func(http.ResponseWriter, *http.Request), id, body string) (int, *model.Channel) {
+		response := executeReq(t, &testRouteConfig{
+			Method:    "POST",
+			URL:       "/channels",
+			Body:      strings.NewReader(body),
+			URLParams: map[string]string{"id": id},
+			Handler:   handler,
+		})
+		var channel model.Channel
+		json.NewDecoder(response.Body).Decode(&channel)
+		return response.Code, &channel
+	}
+	for _, body := range []string{
+		`{"kind": "fax", "url": "https://example.com"}`,
+		`{"kind": "slack", "url": "ftp://example.com"}`,
+		`{"kind": "webhook", "url": "https://example.com"}`,
+		`{"kind": "teams", "url": "https://example.com", "events": ["booking.exploded"]}`,
+	} {
+		code, _ := create(app.CreateUserChannel, userId, body)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+	}
+	code, _ := create(app.CreateUserChannel, "00000000-0000-4000-a000-0000000000ff", `{"kind": "slack", "url": "https://example.com"}`)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, webhook := create(app.CreateUserChannel, userId,
+		`{"kind": "webhook", "url": "https://example.com/hook", "secret": "s3cret", "events": ["booking.created"], "floor_id": "`+floorId+`"}`)
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, userId, webhook.UserID)
+	assert.Empty(t, webhook.FloorID, "a channel only belongs to the user or floor it is created for")
+	assert.Empty(t, webhook.Secret, "secrets are never returned")
+	stored, err := store.ChannelProvider.GetOneChannel(webhook.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", stored.Secret)
+	code, slack := create(app.CreateFloorChannel, floorId, `{"kind": "slack", "url": "https://hooks.slack.com/services/T/B/X"}`)
+	require.Equal(t, http.StatusCreated, code)
+
+	response := executeReq(t, &testRouteConfig{
+		Method:    "GET",
+		URL:       "/users/" + userId + "/channels",
+		URLParams: map[string]string{"id": userId},
+		Handler:   app.GetUserChannels,
+	})
+	require.Equal(t, http.StatusOK, response.Code)
+	var channels []*model.Channel
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&channels))
+	if assert.Len(t, channels, 1) {
+		assert.Equal(t, webhook.ID, channels[0].ID)
+		assert.Empty(t, channels[0].Secret)
+	}
+
+	remove := func(handler func(http.ResponseWriter, *http.Request), id, channelId string) int {
+		return executeReq(t, &testRouteConfig{
+			Method:    "DELETE",
+			URL:       "/channels/" + channelId,
+			URLParams: map[string]string{"id": id, "channelId": channelId},
+			Handler:   handler,
+		}).Code
+	}
+	assert.Equal(t, http.StatusNotFound, remove(app.RemoveUserChannel, userId, slack.ID), "the floor's channel isn't the user's")
+	assert.Equal(t, http.StatusOK, remove(app.RemoveFloorChannel, floorId, slack.ID))
+	assert.Equal(t, http.StatusOK, remove(app.RemoveUserChannel, userId, webhook.ID))
+	assert.Equal(t, http.StatusNotFound, remove(app.RemoveUserChannel, userId, webhook.ID))
+}
@@ -0,0 +1,117 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"go-api/auth"
+	"go-api/db"
+	"go-api/notify"
+	"go-api/utils"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultCheckInGrace is how long after a booking starts the user has to check in before the desk is released
+	DefaultCheckInGrace = 15 * time.Minute
+	// noShowInterval is how often the releaser looks for bookings nobody checked in to
+	noShowInterval = time.Minute
+)
+
+func (app *App) RegisterCheckInRoutes() {
+	app.router.HandleFunc("/bookings/noshows", app.authorize(app.GetNoShowCounts, auth.AdminOnly)).Methods("GET").Queries("since", "{since:[0-9]+}")
+	app.router.HandleFunc("/bookings/noshows", app.authorize(app.GetNoShowCounts, auth.AdminOnly)).Methods("GET") // Handles when Query is empty
+	app.router.HandleFunc("/bookings/{id}/checkin", app.authorize(app.CheckInBooking, auth.OwnerOrAdmin("id", app.bookingOwners))).Methods("POST")
+}
+
+func (app *App) CheckInBooking(w http.ResponseWriter, r *http.Request) {
+	bookingID := mux.Vars(r)["id"]
+	if bookingID == "" {
+		log.Printf("App.CheckInBooking - empty booking id")
+		respondError(w, http.StatusBadRequest, db.Validation, "booking id is required")
+		return
+	}
+	booking, err := app.store.BookingProvider.CheckInBooking(bookingID, time.Now(), app.gracePeriod())
+	if err != nil {
+		log.Printf("App.CheckInBooking - error checking in %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(booking)
+	app.publishBooking(notify.BookingCheckedIn, bookingID)
+}
+
+// GetNoShowCounts lists users with no-shows, optionally only those since ?since=<unix timestamp>
+// and with at least ?min=<count> of them
+func (app *App) GetNoShowCounts(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if sinceParam := r.FormValue("since"); sinceParam != "" {
+		sinceTime, err := utils.TimeStampToTime(sinceParam)
+		if err != nil {
+			log.Printf("App.GetNoShowCounts - invalid since param: %v", err)
+			respondError(w, http.StatusBadRequest, db.Validation, "since must be a unix timestamp")
+			return
+		}
+		since = sinceTime
+	}
+	min := 1
+	if minParam := r.FormValue("min"); minParam != "" {
+		count, err := strconv.Atoi(minParam)
+		if err != nil || count < 1 {
+			log.Printf("App.GetNoShowCounts - invalid min param: %s", minParam)
+			respondError(w, http.StatusBadRequest, db.Validation, "min must be a positive number")
+			return
+		}
+		min = count
+	}
+	counts, err := app.store.BookingProvider.GetNoShowCounts(since, min)
+	if err != nil {
+		log.Printf("App.GetNoShowCounts - error getting no-shows from provider %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(counts)
+}
+
+func (app *App) gracePeriod() time.Duration {
+	if app.checkInGrace == 0 {
+		return DefaultCheckInGrace
+	}
+	return app.checkInGrace
+}
+
+// StartNoShowReleaser releases bookings that weren't checked in to in time until the app stops
+func (app *App) StartNoShowReleaser() {
+	go func() {
+		ticker := time.NewTicker(noShowInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			app.releaseNoShows(now)
+		}
+	}()
+}
+
+// releaseNoShows releases the bookings whose check-in window closed before now, which the store lets the users know
+// of, and offers the freed desks to the waitlist
+func (app *App) releaseNoShows(now time.Time) {
+	released, err := app.store.BookingProvider.ReleaseNoShows(now, app.gracePeriod())
+	if err != nil {
+		log.Printf("App.releaseNoShows - error releasing bookings %v", err)
+		return
+	}
+	for _, booking := range released {
+		event, err := app.bookingEvent(notify.BookingCancelled, booking.ID)
+		if err == nil {
+			// a booking checked in to on an earlier day is only released from the day that was missed
+			event.Params.Start, event.Params.End = booking.StartDate, booking.EndDate
+			app.publish(event)
+		} else {
+			log.Printf("App.releaseNoShows - error building event %+v", err)
+		}
+		app.processWaitlist(booking.WorkspaceID, booking.StartDate, booking.EndDate)
+	}
+}
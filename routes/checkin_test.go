@@ -0,0 +1,95 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"go-api/model"
+	"net/http"
+	"time"
+)
+
+func (suite *AppTestSuite) Test_CheckIn() {
+	t := suite.T()
+	store := suite.app.store
+
+	offeringID, err := store.OfferingProvider.CreateOffering(&model.Offering{
+		UserID:      Offering5.UserID,
+		WorkspaceID: Offering5.WorkspaceID,
+		StartDate:   date("2019-03-05T08:00:00Z"),
+		EndDate:     date("2019-03-05T18:00:00Z"),
+		CreatedBy:   Offering5.UserID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	morning, err := store.BookingProvider.CreateBooking(&model.Booking{
+		UserID:      barryID,
+		WorkspaceID: Offering5.WorkspaceID,
+		StartDate:   date("2019-03-05T09:00:00Z"),
+		EndDate:     date("2019-03-05T12:00:00Z"),
+		CreatedBy:   barryID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	afternoon, err := store.BookingProvider.CreateBooking(&model.Booking{
+		UserID:      bruceID,
+		WorkspaceID: Offering5.WorkspaceID,
+		StartDate:   date("2019-03-05T13:00:00Z"),
+		EndDate:     date("2019-03-05T17:00:00Z"),
+		CreatedBy:   bruceID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The check-in window closed long ago for the handler's clock
+	rr := executeReq(t, &testRouteConfig{
+		Method:    http.MethodPost,
+		Handler:   suite.app.CheckInBooking,
+		URL:       fmt.Sprintf("/bookings/%s/checkin", morning),
+		URLParams: map[string]string{"id": morning},
+	})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "status code")
+
+	grace := 15 * time.Minute
+	_, err = store.BookingProvider.CheckInBooking(morning, date("2019-03-05T08:30:00Z"), grace)
+	assert.Error(t, err, "too early")
+	checkedIn, err := store.BookingProvider.CheckInBooking(morning, date("2019-03-05T09:05:00Z"), grace)
+	if assert.NoError(t, err) && assert.NotNil(t, checkedIn.CheckedInAt) {
+		assert.True(t, date("2019-03-05T09:05:00Z").Equal(*checkedIn.CheckedInAt))
+	}
+	_, err = store.BookingProvider.CheckInBooking(morning, date("2019-03-05T09:10:00Z"), grace)
+	assert.Error(t, err, "already checked in")
+
+	// Bruce never shows up; his booking is released once the grace period is over
+	released, err := store.BookingProvider.ReleaseNoShows(date("2019-03-05T13:15:00Z"), grace)
+	assert.NoError(t, err)
+	assert.Empty(t, released, "still within grace")
+	released, err = store.BookingProvider.ReleaseNoShows(date("2019-03-05T13:25:00Z"), grace)
+	assert.NoError(t, err)
+	if assert.Len(t, released, 1) {
+		assert.Equal(t, afternoon, released[0].ID)
+		assert.True(t, released[0].Cancelled)
+		assert.True(t, released[0].NoShow)
+	}
+	_, err = store.BookingProvider.CheckInBooking(afternoon, date("2019-03-05T13:20:00Z"), grace)
+	assert.Error(t, err, "released bookings can't be checked in")
+
+	rr = executeReq(t, &testRouteConfig{
+		Method:  http.MethodGet,
+		Handler: suite.app.GetNoShowCounts,
+		URL:     fmt.Sprintf("/bookings/noshows?since=%d", date("2019-03-01T00:00:00Z").Unix()),
+	})
+	assert.Equal(t, http.StatusOK, rr.Code, "status code")
+	var counts []*model.NoShowCount
+	_ = json.Unmarshal(rr.Body.Bytes(), &counts)
+	if assert.Len(t, counts, 1) {
+		assert.Equal(t, bruceID, counts[0].UserID)
+		assert.Equal(t, 1, counts[0].NoShows)
+	}
+
+	assert.NoError(t, store.BookingProvider.RemoveBooking(morning))
+	assert.NoError(t, store.OfferingProvider.RemoveOffering(offeringID))
+}
@@ -0,0 +1,55 @@
+package routes
+
+import (
+	"go-api/model"
+	"go-api/notify"
+	"log"
+	"net/http"
+	"time"
+)
+
+// The channel and webhook workers drain their deliveries alike: they claim the ones due in batches, leased so that no
+// other instance sends them meanwhile, retry the failed ones with the backoff of the outbox until they have been tried
+// maxWebhookAttempts times, and forget the delivered and dead ones after deliveryRetention
+const (
+	// deliveryInterval is how often the workers look for deliveries that are due
+	deliveryInterval = 10 * time.Second
+	// deliveryLease keeps other instances from sending a claimed delivery while this one is sending it
+	deliveryLease = 5 * time.Minute
+	// deliveryRetention is how long delivered and dead deliveries are kept
+	deliveryRetention = 30 * 24 * time.Hour
+)
+
+// postDelivery posts req, a delivery to a channel or webhook, and returns the outcome of the attempt; err is why req
+// could not be built
+func (app *App) postDelivery(req *http.Request, err error) *model.DeliveryAttempt {
+	attempt := &model.DeliveryAttempt{}
+	if err == nil {
+		attempt.ResponseStatus, err = notify.Post(app.client, req)
+	}
+	attempt.At = time.Now()
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	return attempt
+}
+
+// recordAttempt records the attempt to send delivery with delivered or failed. A failed attempt is retried with the
+// backoff of the outbox until the delivery has been tried maxWebhookAttempts times, then it is dead-lettered
+func (app *App) recordAttempt(worker string, delivery *model.Delivery, attempt *model.DeliveryAttempt,
+	delivered, failed func(id string, attempt *model.DeliveryAttempt) error) {
+	if attempt.Error == "" {
+		if err := delivered(delivery.ID, attempt); err != nil {
+			log.Printf("App.%s - error marking %s delivered %v", worker, delivery.ID, err)
+		}
+		return
+	}
+	log.Printf("App.%s - error sending %s, attempt %d %s", worker, delivery.ID, delivery.Attempts+1, attempt.Error)
+	if delivery.Attempts+1 < app.maxWebhookAttempts() {
+		retry := attempt.At.Add(outboxRetryDelay(delivery.Attempts + 1))
+		attempt.NextAttemptAt = &retry
+	}
+	if err := failed(delivery.ID, attempt); err != nil {
+		log.Printf("App.%s - error recording failure of %s %v", worker, delivery.ID, err)
+	}
+}
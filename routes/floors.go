@@ -5,26 +5,30 @@ import (
 	"fmt"
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/gorilla/mux"
+	"github.com/segmentio/ksuid"
+	"go-api/auth"
+	"go-api/blob"
+	"go-api/db"
+	"go-api/mail"
 	"go-api/model"
+	"go-api/notify"
 	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"strings"
+	"strconv"
+	"time"
 )
 
-func buildDriveDirectLink(id string) string {
-	return fmt.Sprintf(`https://drive.google.com/uc?export=download&id=%s`, id)
-}
-
 const MaxFileSize = 6 << 20 // 6 MB
 
 func (app *App) RegisterFloorRoutes() {
-	app.router.HandleFunc("/floors", app.CreateFloor).Methods("POST")
+	app.router.HandleFunc("/floors", app.authorize(app.CreateFloor, auth.AdminOnly)).Methods("POST")
 	app.router.HandleFunc("/floors/{id}", app.GetOneFloor).Methods("GET")
 	app.router.HandleFunc("/floors", app.GetAllFloors).Methods("GET")
 	//app.router.HandleFunc("/floors/{id}", app.UpdateFloor).Methods("PATCH")
-	app.router.HandleFunc("/floors/{id}", app.DeleteFloor).Methods("DELETE")
+	app.router.HandleFunc("/floors/{id}", app.authorize(app.DeleteFloor, auth.AdminOnly)).Methods("DELETE")
+	app.router.HandleFunc("/floor-plans/{name}", app.GetFloorPlan).Methods("GET")
 }
 
 var acceptedImages = map[string]bool{
@@ -38,75 +42,122 @@ func (app *App) CreateFloor(w http.ResponseWriter, r *http.Request) {
 	parseErr := r.ParseMultipartForm(MaxFileSize)
 	if parseErr != nil {
 		log.Println("App.CreateFloor - failed to parse message")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "expecting a multipart form of at most 6MB")
 		return
 	}
 
 	if r.MultipartForm == nil || r.MultipartForm.File == nil {
 		log.Println("App.CreateFloor - expecting multipart form file")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "expecting a multipart form file")
 		return
 	}
 
 	imageFile, _, err := r.FormFile("image")
 	if err != nil {
 		log.Println("App.CreateFloor - image is absent: " + err.Error())
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "image is required")
 		return
 	}
 
 	mime, errMime := mimetype.DetectReader(imageFile)
 	if errMime != nil {
 		log.Println("App.CreateFloor - Error handling mime: " + errMime.Error())
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "could not read the image")
 		return
 	}
 	if !acceptedImages[mime.String()] {
 		log.Println("App.CreateFloor - The image must be of type jpg, jpeg or png: Mime was of type " + mime.String())
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "the image must be of type jpg, jpeg or png")
 		return
 	}
 	// MIME Reads part of the file, rewind to the start
 	_, err = imageFile.Seek(0, io.SeekStart)
 	if err != nil {
 		log.Println("App.CreateFloor - Something went wrong with seeking back to the front")
-		w.WriteHeader(http.StatusInternalServerError)
+		respondStoreError(w, err, http.StatusInternalServerError)
 		return
 	}
 
 	name := r.FormValue("name")
 	if name == "" {
 		log.Println("App.CreateFloor - name is absent")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "name is required")
 		return
 	}
 
 	address := r.FormValue("address")
 	if address == "" {
 		log.Println("App.CreateFloor - address is absent")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "address is required")
 		return
 	}
 
-	id, parseErr := app.gDrive.UploadFloorPlan(name, imageFile)
-	if parseErr != nil {
-		log.Println("App.CreateFloor - Failed to upload image to Google Drive")
-		w.WriteHeader(http.StatusInternalServerError)
+	planId, err := ksuid.NewRandom()
+	if err != nil {
+		log.Printf("App.CreateFloor - couldnt gen ksuid, %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	planName := planId.String() + mime.Extension()
+	if err = app.blobs.Put(blob.FloorPlanPrefix+planName, mime.String(), imageFile); err != nil {
+		log.Printf("App.CreateFloor - Failed to store floor plan %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
 		return
 	}
 	newFloor.Name = name
-	newFloor.DownloadURL = buildDriveDirectLink(id)
+	newFloor.DownloadURL = app.floorPlanURL(planName)
 	newFloor.Address = address
-	id, err = app.store.FloorProvider.CreateFloor(&newFloor)
+	id, err := app.store.FloorProvider.CreateFloor(&newFloor)
 	if err != nil {
 		log.Printf("App.CreateBooking - error creating booking %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondStoreError(w, err, http.StatusInternalServerError)
 		return
 	}
 	newFloor.ID = id
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(newFloor)
+	app.publish(&notify.Event{
+		Type:    notify.FloorCreated,
+		ID:      id,
+		Time:    time.Now(),
+		FloorID: id,
+		Params:  &mail.EmailParams{FloorName: newFloor.Name, FloorAddress: newFloor.Address},
+	})
+}
+
+// floorPlanURL is where GetFloorPlan serves the floor plan stored as name
+func (app *App) floorPlanURL(name string) string {
+	return fmt.Sprintf("%s/floor-plans/%s", app.publicUrl, name)
+}
+
+// GetFloorPlan serves a floor plan image. Floor plans are never changed once stored, so they can be cached for good
+func (app *App) GetFloorPlan(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	plan, err := app.blobs.Get(blob.FloorPlanPrefix + name)
+	if err == blob.NotFoundError {
+		respondError(w, http.StatusNotFound, db.NotFound, "floor plan not found")
+		return
+	}
+	if err == blob.InvalidKeyError {
+		respondError(w, http.StatusBadRequest, db.Validation, "invalid floor plan name")
+		return
+	}
+	if err != nil {
+		log.Printf("App.GetFloorPlan - error getting floor plan %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	defer plan.Close()
+	w.Header().Set("Content-Type", plan.ContentType)
+	if plan.Size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(plan.Size, 10))
+	}
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.WriteHeader(http.StatusOK)
+	if _, err = io.Copy(w, plan); err != nil {
+		log.Printf("App.GetFloorPlan - error sending floor plan %v", err)
+	}
 }
 
 func (app *App) GetOneFloor(w http.ResponseWriter, r *http.Request) {
@@ -114,14 +165,14 @@ func (app *App) GetOneFloor(w http.ResponseWriter, r *http.Request) {
 
 	if floorID == "" {
 		log.Printf("App.GetOneFloor - empty floor id")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "floor id is required")
 		return
 	}
 
 	floor, err := app.store.FloorProvider.GetOneFloor(floorID)
 	if err != nil {
 		log.Printf("App.GetOneFloor - error getting floor from provider %v", err)
-		w.WriteHeader(http.StatusNotFound)
+		respondStoreError(w, err, http.StatusNotFound)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -132,7 +183,7 @@ func (app *App) GetAllFloors(w http.ResponseWriter, r *http.Request) {
 	floors, err := app.store.FloorProvider.GetAllFloors()
 	if err != nil {
 		log.Printf("App.GetAllFloors - error getting all floors from provider %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondStoreError(w, err, http.StatusInternalServerError)
 		return
 	}
 	json.NewEncoder(w).Encode(floors)
@@ -174,7 +225,7 @@ func (app *App) DeleteFloor(w http.ResponseWriter, r *http.Request) {
 
 	if floorID == "" {
 		log.Printf("App.DeleteFloor - empty floor id")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "floor id is required")
 		return
 	}
 
@@ -182,26 +233,36 @@ func (app *App) DeleteFloor(w http.ResponseWriter, r *http.Request) {
 	reqBody, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		log.Printf("App.DeleteFloor - error reading request body %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "could not read the request body")
 		return
 	}
 	err = json.Unmarshal(reqBody, &deleteFloor)
 	if err != nil {
 		log.Printf("App.DeleteFloor - error unmarshaling request body %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "request body is not valid JSON")
 		return
 	}
 
+	floor, err := app.store.FloorProvider.GetOneFloor(floorID)
+	if err != nil {
+		log.Printf("App.DeleteFloor - error getting floor from provider %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
 	err = app.store.FloorProvider.RemoveFloor(floorID, deleteFloor.ForceDelete)
 	if err != nil {
-		if strings.Contains(err.Error(), "invalid operation") {
-			log.Printf("App.DeleteFloor - %v", err)
-			w.WriteHeader(http.StatusForbidden)
-		} else {
-			log.Printf("App.DeleteFloor - error getting all floors from provider %v", err)
-			w.WriteHeader(http.StatusNotFound)
-		}
+		log.Printf("App.DeleteFloor - error removing floor %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
+	event := &notify.Event{
+		Type:    notify.FloorDeleted,
+		ID:      floorID,
+		Time:    time.Now(),
+		FloorID: floorID,
+		Params:  &mail.EmailParams{FloorName: floor.Name, FloorAddress: floor.Address},
+	}
+	app.publish(event)
+	app.notify(event)
 }
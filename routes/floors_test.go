@@ -6,11 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go-api/blob"
 	"go-api/model"
 	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 	"os"
+	"strings"
+	"testing"
 )
 
 var MainFloor = &model.Floor{
@@ -74,10 +78,9 @@ func (suite *AppTestSuite) TestGetAllFloors() {
 func (suite *AppTestSuite) Test_CreateFloors() {
 	floorName := "test-floor"
 	floorAddress := "test-address"
-	fileId := "test-file-id"
-	mockDrive := new(mockDrive)
-	mockDrive.On("UploadFloorPlan", floorName).Return(fileId, nil)
-	suite.app.gDrive = mockDrive
+	blobs := new(mockBlobStore)
+	blobs.On("Put", mock.MatchedBy(isFloorPlanKey), "image/jpeg").Return(nil)
+	suite.app.blobs = blobs
 
 	t := suite.T()
 	body := new(bytes.Buffer)
@@ -109,18 +112,17 @@ func (suite *AppTestSuite) Test_CreateFloors() {
 
 	var payload *model.Floor
 	_ = json.Unmarshal(rr.Body.Bytes(), &payload)
-	driveLink := fmt.Sprintf(`https://drive.google.com/uc?export=download&id=%s`, fileId)
-	assert.Equal(t, driveLink, payload.DownloadURL, "wrong drive url")
+	assert.True(t, strings.HasPrefix(payload.DownloadURL, "/floor-plans/"), "wrong floor plan url")
+	assert.True(t, strings.HasSuffix(payload.DownloadURL, ".jpg"), "wrong floor plan url")
+	blobs.AssertExpectations(t)
 	assert.Equal(t, floorName, payload.Name, "wrong floor name")
 	assert.Equal(t, floorAddress, payload.Address, "wrong floor address")
 }
 
 func (suite *AppTestSuite) Test_CreateFloorsEmptyName() {
-	floorName := "test-floor"
-	fileId := "test-file-id"
-	mockDrive := new(mockDrive)
-	mockDrive.On("UploadFloorPlan", floorName).Return(fileId, nil)
-	suite.app.gDrive = mockDrive
+	blobs := new(mockBlobStore)
+	blobs.On("Put", mock.MatchedBy(isFloorPlanKey), "image/jpeg").Return(nil)
+	suite.app.blobs = blobs
 
 	t := suite.T()
 	body := new(bytes.Buffer)
@@ -152,10 +154,9 @@ func (suite *AppTestSuite) Test_CreateFloorsEmptyName() {
 func (suite *AppTestSuite) Test_CreateFloorsEmptyFile() {
 	floorName := "test-floor"
 	floorAddress := "test-address"
-	fileId := "test-file-id"
-	mockDrive := new(mockDrive)
-	mockDrive.On("UploadFloorPlan", floorName).Return(fileId, nil)
-	suite.app.gDrive = mockDrive
+	blobs := new(mockBlobStore)
+	blobs.On("Put", mock.MatchedBy(isFloorPlanKey), "image/jpeg").Return(nil)
+	suite.app.blobs = blobs
 
 	t := suite.T()
 	body := new(bytes.Buffer)
@@ -181,10 +182,10 @@ func (suite *AppTestSuite) Test_CreateFloorsEmptyFile() {
 func (suite *AppTestSuite) Test_CreateFloorsFailToUpload() {
 	floorName := "test-floor"
 	floorAddress := "test-address"
-	mockDrive := new(mockDrive)
-	mockDrive.On("UploadFloorPlan", floorName).
-		Return("", errors.New("some error"))
-	suite.app.gDrive = mockDrive
+	blobs := new(mockBlobStore)
+	blobs.On("Put", mock.MatchedBy(isFloorPlanKey), "image/jpeg").
+		Return(errors.New("some error"))
+	suite.app.blobs = blobs
 
 	t := suite.T()
 	body := new(bytes.Buffer)
@@ -215,3 +216,50 @@ func (suite *AppTestSuite) Test_CreateFloorsFailToUpload() {
 	}
 
 }
+
+func isFloorPlanKey(key string) bool {
+	return strings.HasPrefix(key, blob.FloorPlanPrefix)
+}
+
+func TestGetFloorPlan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "floor-plans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := blob.NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := []byte("not really a png")
+	if err = store.Put(blob.FloorPlanPrefix+"main.png", "image/png", bytes.NewReader(plan)); err != nil {
+		t.Fatal(err)
+	}
+	app := &App{blobs: store}
+
+	rr := executeReq(t, &testRouteConfig{
+		Method:    http.MethodGet,
+		Handler:   app.GetFloorPlan,
+		URL:       "/floor-plans/main.png",
+		URLParams: map[string]string{"name": "main.png"},
+	})
+	assert.Equal(t, http.StatusOK, rr.Code, "status code")
+	assert.Equal(t, "image/png", rr.Header().Get("Content-Type"), "content type")
+	assert.Equal(t, plan, rr.Body.Bytes(), "floor plan")
+
+	rr = executeReq(t, &testRouteConfig{
+		Method:    http.MethodGet,
+		Handler:   app.GetFloorPlan,
+		URL:       "/floor-plans/other.png",
+		URLParams: map[string]string{"name": "other.png"},
+	})
+	assert.Equal(t, http.StatusNotFound, rr.Code, "status code")
+
+	rr = executeReq(t, &testRouteConfig{
+		Method:    http.MethodGet,
+		Handler:   app.GetFloorPlan,
+		URL:       "/floor-plans/..",
+		URLParams: map[string]string{"name": ".."},
+	})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "status code")
+}
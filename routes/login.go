@@ -1,77 +1,64 @@
 package routes
 
-// DEPRECATED
-
 import (
-	"encoding/json"
 	"github.com/segmentio/ksuid"
+	"go-api/auth"
 	"log"
 	"net/http"
 	"time"
 )
 
-const SessionTimeout = 86400 // 1 day in seconds
-const CookieSessionToken = "session_token"
-
-type loginBody struct {
-	Token  string `json:"auth_token"`
-	UserId string `json:"user_id"`
-}
-
 func (app *App) RegisterLoginRoutes() {
 	app.router.HandleFunc("/login", app.Login).Methods("POST")
 	app.router.HandleFunc("/logout", app.Logout).Methods("POST")
-	//app.router.Use(app.authCheckMiddleware)
+	app.router.Use(app.authCheckMiddleware)
 }
 
+// Login exchanges an already authenticated request (bearer token) for a session cookie
 func (app *App) Login(w http.ResponseWriter, r *http.Request) {
-	var loginBody loginBody
-
-	err := json.NewDecoder(r.Body).Decode(&loginBody)
-	if err != nil {
-		log.Printf("App.Login: couldnt parse body, %+v\n", err)
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
-
-	user, err := app.store.UserProvider.GetOneUser(loginBody.UserId)
-	if err != nil {
-		log.Printf("App.Login: couldnt parse body, %+v\n", err)
-		w.WriteHeader(http.StatusUnauthorized)
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		log.Println("App.Login: no authenticated user")
+		respondError(w, http.StatusUnauthorized, "unauthenticated", "authentication required")
 		return
 	}
 
 	sessionToken, err := ksuid.NewRandom()
 	if err != nil {
 		log.Printf("App.Login: couldnt gen ksuid, %+v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondStoreError(w, err, http.StatusInternalServerError)
 		return
 	}
 	conn := app.cache.Get()
 	defer conn.Close()
-	_, err = conn.Do("SETEX", sessionToken.String(), SessionTimeout, user.ID)
+	_, err = conn.Do("SETEX", sessionToken.String(), auth.SessionTimeout, user.ID)
 	if err != nil {
 		log.Printf("App.Login: couldnt store session-key, %+v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondStoreError(w, err, http.StatusInternalServerError)
 		return
 	}
 
 	http.SetCookie(w, &http.Cookie{
-		Name:    CookieSessionToken,
-		Value:   sessionToken.String(),
-		Expires: time.Now().Add(SessionTimeout * time.Second),
+		Name:     auth.CookieSessionToken,
+		Value:    sessionToken.String(),
+		Expires:  time.Now().Add(auth.SessionTimeout * time.Second),
+		HttpOnly: true,
 	})
 	w.WriteHeader(http.StatusAccepted)
 }
 
 func (app *App) Logout(w http.ResponseWriter, r *http.Request) {
-	c, _ := r.Cookie("session_token")
+	c, err := r.Cookie(auth.CookieSessionToken)
+	if err != nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 	conn := app.cache.Get()
 	defer conn.Close()
-	_, err := conn.Do("DEL", c.Value)
+	_, err = conn.Do("DEL", c.Value)
 	if err != nil {
 		log.Printf("App.Logout: couldnt delete session-key, %+v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondStoreError(w, err, http.StatusInternalServerError)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -79,37 +66,39 @@ func (app *App) Logout(w http.ResponseWriter, r *http.Request) {
 
 func (app *App) authCheckMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.RequestURI == "/login" { // short-circuit for /login route
+		if r.URL.Path == "/" { // short-circuit for the health check
 			next.ServeHTTP(w, r)
 			return
 		}
-		c, err := r.Cookie(CookieSessionToken)
+		user, err := app.auth.Authenticate(r)
 		if err != nil {
-			if err == http.ErrNoCookie {
-				log.Printf("App.authCheckMiddleware: no cookie, %+v\n", err)
-				w.WriteHeader(http.StatusUnauthorized)
+			if err == auth.NoCredentialsError || err == auth.InvalidCredentialsError {
+				log.Printf("App.authCheckMiddleware: %s %s, %+v\n", r.Method, r.URL.Path, err)
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				respondError(w, http.StatusUnauthorized, "unauthenticated", "authentication required")
 				return
 			}
 			log.Printf("App.authCheckMiddleware: error - , %+v\n", err)
-			w.WriteHeader(http.StatusBadRequest)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
-		sessionToken := c.Value
-		conn := app.cache.Get()
-		defer conn.Close()
-		response, err := conn.Do("GET", sessionToken)
-		if err != nil {
-			log.Printf("App.authCheckMiddleware: couldnt get session-token, %+v\n", err)
-			w.WriteHeader(http.StatusInternalServerError)
+		next.ServeHTTP(w, r.WithContext(auth.WithUser(r.Context(), user)))
+	})
+}
+
+// authorize wraps a handler so it only runs when every policy allows the authenticated user
+func (app *App) authorize(handler http.HandlerFunc, policies ...auth.Policy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := auth.UserFromContext(r.Context())
+		if !ok {
+			respondError(w, http.StatusUnauthorized, "unauthenticated", "authentication required")
 			return
 		}
-		if response == nil {
-			log.Printf("App.authCheckMiddleware: unknown session-token, %+v\n", err)
-			w.WriteHeader(http.StatusUnauthorized)
+		if err := auth.Authorize(user, r, policies...); err != nil {
+			log.Printf("App.authorize: user %s denied %s %s\n", user.ID, r.Method, r.URL.Path)
+			respondError(w, http.StatusForbidden, "forbidden", "you are not allowed to perform this action")
 			return
 		}
-		// TODO logic for auto-refresh (security concern???)
-		// If TTL is < 3600s then create new sessions token and attach cookie
-		next.ServeHTTP(w, r)
-	})
+		handler(w, r)
+	}
 }
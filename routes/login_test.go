@@ -0,0 +1,83 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go-api/auth"
+	"go-api/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type stubAuthenticator struct {
+	user *model.User
+	err  error
+}
+
+func (s stubAuthenticator) Authenticate(r *http.Request) (*model.User, error) {
+	return s.user, s.err
+}
+
+func newAuthTestApp(authenticator auth.Authenticator) *App {
+	app := &App{
+		router: mux.NewRouter().StrictSlash(true),
+		auth:   authenticator,
+	}
+	app.router.HandleFunc("/", app.index)
+	app.router.HandleFunc("/whoami", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := auth.UserFromContext(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(user.ID))
+	})
+	app.router.Use(app.authCheckMiddleware)
+	return app
+}
+
+func TestAuthCheckMiddleware(t *testing.T) {
+	user := &model.User{ID: "e99a988a-1d41-3997-8d59-959a48ac24a0"}
+
+	app := newAuthTestApp(stubAuthenticator{err: auth.NoCredentialsError})
+	rr := httptest.NewRecorder()
+	app.router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/whoami", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "missing credentials")
+
+	rr = httptest.NewRecorder()
+	app.router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rr.Code, "index should not require credentials")
+
+	app = newAuthTestApp(stubAuthenticator{err: auth.InvalidCredentialsError})
+	rr = httptest.NewRecorder()
+	app.router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/whoami", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "invalid credentials")
+
+	app = newAuthTestApp(stubAuthenticator{user: user})
+	rr = httptest.NewRecorder()
+	app.router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/whoami", nil))
+	assert.Equal(t, http.StatusOK, rr.Code, "authenticated")
+	assert.Equal(t, user.ID, rr.Body.String(), "user should be on the request context")
+}
+
+func TestAuthorize(t *testing.T) {
+	app := &App{}
+	handler := app.authorize(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}, auth.AdminOnly)
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodPost, "/users", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "no user on context")
+
+	req := httptest.NewRequest(http.MethodPost, "/users", nil)
+	rr = httptest.NewRecorder()
+	handler(rr, req.WithContext(auth.WithUser(req.Context(), &model.User{ID: "barry"})))
+	assert.Equal(t, http.StatusForbidden, rr.Code, "non admin")
+	assert.JSONEq(t, `{"code":"forbidden","message":"you are not allowed to perform this action"}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	handler(rr, req.WithContext(auth.WithUser(req.Context(), &model.User{ID: "bruce", IsAdmin: true})))
+	assert.Equal(t, http.StatusOK, rr.Code, "admin")
+}
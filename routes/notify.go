@@ -0,0 +1,100 @@
+package routes
+
+import (
+	"fmt"
+	"go-api/mail"
+	"go-api/model"
+	"go-api/notify"
+	"log"
+	"time"
+)
+
+// notifier emails events with the app's email client, then queues them for the channels of their user and floor
+func (app *App) notifier() *notify.Notifier {
+	return &notify.Notifier{
+		Email:    &notify.EmailSink{Client: app.email},
+		Channels: app.eventChannels,
+		Queue:    app.queueChannelDelivery,
+	}
+}
+
+// notify sends event by email and queues it for its channels, logging a failed email
+func (app *App) notify(event *notify.Event) {
+	if err := app.notifier().Notify(event); err != nil {
+		log.Printf("Error sending email: %+v", err)
+	}
+}
+
+// eventChannels are the channels of the user and of the floor of event. The copies of a booking's events for its
+// creator and the owner of its workspace only go to their own channels; the floor's heard from the booker's
+func (app *App) eventChannels(event *notify.Event) ([]*model.Channel, error) {
+	floorID := event.FloorID
+	if event.Params != nil && event.Params.Role != "" {
+		floorID = ""
+	}
+	return app.store.ChannelProvider.GetChannels(event.UserID, floorID)
+}
+
+// notificationEvent is the event of a notification delivered by the outbox, which queued it at queued
+func notificationEvent(n *model.Notification, queued time.Time) (*notify.Event, error) {
+	var eventType string
+	switch {
+	case n.Kind == model.NotificationDigest:
+		eventType = notify.Digest
+	case n.Kind == model.NotificationHold && n.Subject == model.NotificationWaitlist:
+		eventType = notify.WaitlistHeld
+	case n.Subject != model.NotificationBooking && n.Subject != model.NotificationOffering:
+		return nil, fmt.Errorf("unknown notification subject %q", n.Subject)
+	case n.Kind == model.NotificationConfirmation:
+		eventType = n.Subject + ".created"
+	case n.Kind == model.NotificationCancellation:
+		eventType = n.Subject + ".cancelled"
+	case n.Kind == model.NotificationUpdate:
+		eventType = n.Subject + ".updated"
+	case n.Kind == model.NotificationReminder:
+		eventType = n.Subject + ".reminder"
+	default:
+		return nil, fmt.Errorf("unknown notification kind %q", n.Kind)
+	}
+	params := &mail.EmailParams{
+		ID:            n.SubjectID,
+		Name:          n.Name,
+		Email:         n.Email,
+		WorkspaceName: n.WorkspaceName,
+		FloorName:     n.FloorName,
+		FloorAddress:  n.FloorAddress,
+		Start:         n.StartDate,
+		End:           n.EndDate,
+		Changed:       queued,
+		Locale:        n.Locale,
+		TimeZone:      n.TimeZone,
+		Role:          n.Role,
+		BookerName:    n.BookerName,
+	}
+	if n.HoldExpiresAt != nil {
+		params.HoldExpires = *n.HoldExpiresAt
+	}
+	for _, occurrence := range n.Occurrences {
+		params.AddOccurrence(occurrence.ID, occurrence.StartDate)
+	}
+	for _, item := range n.Items {
+		params.Items = append(params.Items, &mail.DigestItem{
+			ID:            item.SubjectID,
+			Type:          item.Subject,
+			WorkspaceName: item.WorkspaceName,
+			FloorName:     item.FloorName,
+			FloorAddress:  item.FloorAddress,
+			Start:         item.StartDate,
+			End:           item.EndDate,
+		})
+	}
+	return &notify.Event{
+		Type:    eventType,
+		ID:      n.SubjectID,
+		Time:    time.Now(),
+		UserID:  n.UserID,
+		FloorID: n.FloorID,
+		Series:  n.Series,
+		Params:  params,
+	}, nil
+}
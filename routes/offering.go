@@ -3,18 +3,21 @@ package routes
 import (
 	"encoding/json"
 	"github.com/gorilla/mux"
+	"go-api/auth"
+	"go-api/db"
 	"go-api/mail"
 	"go-api/model"
+	"go-api/notify"
 	"go-api/utils"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"strconv"
-	"strings"
+	"time"
 )
 
 func (app *App) RegisterOfferingRoutes() {
-	app.router.HandleFunc("/offerings", app.CreateOffering).Methods("POST")
+	app.router.HandleFunc("/offerings", app.authorize(app.CreateOffering, auth.BodyOwnerOrAdmin("user_id"))).Methods("POST")
 	app.router.HandleFunc("/offerings/{id}", app.GetOneOffering).Methods("GET").Queries("expand", "{expand}")
 	app.router.HandleFunc("/offerings/{id}", app.GetOneOffering).Methods("GET")
 	app.router.HandleFunc("/offerings/workspaces/{workspace_id}", app.GetOfferingsByWorkspaceID).Methods("GET").Queries("expand", "{expand}")
@@ -26,7 +29,15 @@ func (app *App) RegisterOfferingRoutes() {
 	app.router.HandleFunc("/offerings", app.GetAllOfferings).Methods("GET").Queries("expand", "{expand}")
 	app.router.HandleFunc("/offerings", app.GetAllOfferings).Methods("GET")
 	//app.router.HandleFunc("/offerings/{id}", app.UpdateOffering).Methods("PATCH")
-	app.router.HandleFunc("/offerings/{id}", app.RemoveOffering).Methods("DELETE")
+	app.router.HandleFunc("/offerings/{id}", app.authorize(app.RemoveOffering, auth.OwnerOrAdmin("id", app.offeringOwners))).Methods("DELETE")
+}
+
+func (app *App) offeringOwners(id string) ([]string, error) {
+	offering, err := app.store.OfferingProvider.GetOneOffering(id)
+	if err != nil {
+		return nil, err
+	}
+	return []string{offering.UserID, offering.CreatedBy}, nil
 }
 
 func (app *App) CreateOffering(w http.ResponseWriter, r *http.Request) {
@@ -34,52 +45,34 @@ func (app *App) CreateOffering(w http.ResponseWriter, r *http.Request) {
 	reqBody, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		log.Printf("App.CreateOffering - error reading request body %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "could not read the request body")
 		return
 	}
 
 	err = json.Unmarshal(reqBody, &newOffering)
 	if err != nil {
 		log.Printf("App.CreateOffering - error unmarshaling request body %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "request body is not valid JSON")
 		return
 	}
-	if newOffering.CreatedBy == "" {
+	if user, ok := auth.UserFromContext(r.Context()); ok {
+		newOffering.CreatedBy = user.ID
+	} else if newOffering.CreatedBy == "" {
 		newOffering.CreatedBy = newOffering.UserID
 	}
 	id, err := app.store.OfferingProvider.CreateOffering(&newOffering)
 	if err != nil {
 		log.Printf("App.CreateOffering - error creating offering %v", err)
-		if strings.Contains(err.Error(), "invalid") {
-			w.WriteHeader(http.StatusBadRequest)
-		} else {
-			w.WriteHeader(http.StatusInternalServerError)
-		}
+		respondStoreError(w, err, http.StatusInternalServerError)
 		return
 	}
 	newOffering.ID = id
-
-	user, err1 := app.store.UserProvider.GetOneUser(newOffering.UserID)
-	// todo check for user and send to creator as well
-	eOffering, err2 := app.store.OfferingProvider.GetOneExpandedOffering(id)
-	if err1 == nil && err2 == nil {
-		_ = app.email.SendConfirmation(
-			mail.Booking,
-			&mail.EmailParams{
-				Name:          user.Name,
-				Email:         user.Email,
-				WorkspaceName: eOffering.WorkspaceName,
-				FloorName:     eOffering.FloorName,
-				Start:         eOffering.StartDate,
-				End:           eOffering.EndDate,
-			},
-		)
-	} else {
-		log.Printf("Error getting user: %+v; Error getting offering %+v", err1, err2)
-	}
+	// the store queued the confirmation email in the outbox along with the offering
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(newOffering)
+	app.publishOffering(notify.OfferingCreated, id)
+	app.processWaitlist(newOffering.WorkspaceID, newOffering.StartDate, newOffering.EndDate)
 }
 
 func (app *App) GetOneOffering(w http.ResponseWriter, r *http.Request) {
@@ -87,7 +80,7 @@ func (app *App) GetOneOffering(w http.ResponseWriter, r *http.Request) {
 
 	if offeringID == "" {
 		log.Printf("App.GetOneOffering - empty offering id")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "offering id is required")
 		return
 	}
 
@@ -97,7 +90,7 @@ func (app *App) GetOneOffering(w http.ResponseWriter, r *http.Request) {
 		expand, err := strconv.ParseBool(exp)
 		if err != nil {
 			log.Printf("App.GetOneOffering - error converting string to boolean from query parameter %v", err)
-			w.WriteHeader(http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, db.Validation, "expand must be true or false")
 			return
 		}
 		expandBool = expand
@@ -106,7 +99,7 @@ func (app *App) GetOneOffering(w http.ResponseWriter, r *http.Request) {
 		expandedOffering, err := app.store.OfferingProvider.GetOneExpandedOffering(offeringID)
 		if err != nil {
 			log.Printf("App.GetOneExpandedOffering - error getting expanded booking from provider %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -115,7 +108,7 @@ func (app *App) GetOneOffering(w http.ResponseWriter, r *http.Request) {
 		offering, err := app.store.OfferingProvider.GetOneOffering(offeringID)
 		if err != nil {
 			log.Printf("App.GetOneOffering - error getting offering from provider %v", err)
-			w.WriteHeader(http.StatusNotFound)
+			respondStoreError(w, err, http.StatusNotFound)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -130,7 +123,7 @@ func (app *App) GetAllOfferings(w http.ResponseWriter, r *http.Request) {
 		expand, err := strconv.ParseBool(exp)
 		if err != nil {
 			log.Printf("App.GetAllOfferings - error converting string to boolean from query parameter %v", err)
-			w.WriteHeader(http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, db.Validation, "expand must be true or false")
 			return
 		}
 		expandBool = expand
@@ -139,7 +132,7 @@ func (app *App) GetAllOfferings(w http.ResponseWriter, r *http.Request) {
 		expandedOfferings, err := app.store.OfferingProvider.GetAllExpandedOfferings()
 		if err != nil {
 			log.Printf("App.GetAllExpandedOfferings - error getting all expanded offerings from provider %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(expandedOfferings)
@@ -147,7 +140,7 @@ func (app *App) GetAllOfferings(w http.ResponseWriter, r *http.Request) {
 		offerings, err := app.store.OfferingProvider.GetAllOfferings()
 		if err != nil {
 			log.Printf("App.GetAllOfferings - error getting all offerings from provider %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(offerings)
@@ -158,7 +151,7 @@ func (app *App) GetOfferingsByWorkspaceID(w http.ResponseWriter, r *http.Request
 	workspaceID := mux.Vars(r)["workspace_id"]
 	if workspaceID == "" {
 		log.Printf("App.GetOneOffering - empty offering id")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "offering id is required")
 		return
 	}
 	exp := r.FormValue("expand")
@@ -167,7 +160,7 @@ func (app *App) GetOfferingsByWorkspaceID(w http.ResponseWriter, r *http.Request
 		expand, err := strconv.ParseBool(exp)
 		if err != nil {
 			log.Printf("App.GetOneBooking - error converting string to boolean from query parameter %v", err)
-			w.WriteHeader(http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, db.Validation, "expand must be true or false")
 			return
 		}
 		expandBool = expand
@@ -176,7 +169,7 @@ func (app *App) GetOfferingsByWorkspaceID(w http.ResponseWriter, r *http.Request
 		expandedOfferings, err := app.store.OfferingProvider.GetExpandedOfferingsByWorkspaceID(workspaceID)
 		if err != nil {
 			log.Printf("App.GetExpandedOfferingsByWorkspaceID - error getting expanded offerings by workspaceID from provider %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(expandedOfferings)
@@ -184,7 +177,7 @@ func (app *App) GetOfferingsByWorkspaceID(w http.ResponseWriter, r *http.Request
 		offerings, err := app.store.OfferingProvider.GetOfferingsByWorkspaceID(workspaceID)
 		if err != nil {
 			log.Printf("App.GetOfferingsByWorkspaceID - error getting offerings by workspaceID from provider %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(offerings)
@@ -196,7 +189,7 @@ func (app *App) GetOfferingsByUserID(w http.ResponseWriter, r *http.Request) {
 
 	if userID == "" {
 		log.Printf("App.GetOneOffering - empty offering id")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "offering id is required")
 		return
 	}
 	exp := r.FormValue("expand")
@@ -205,7 +198,7 @@ func (app *App) GetOfferingsByUserID(w http.ResponseWriter, r *http.Request) {
 		expand, err := strconv.ParseBool(exp)
 		if err != nil {
 			log.Printf("App.GetOneBooking - error converting string to boolean from query parameter %v", err)
-			w.WriteHeader(http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, db.Validation, "expand must be true or false")
 			return
 		}
 		expandBool = expand
@@ -214,7 +207,7 @@ func (app *App) GetOfferingsByUserID(w http.ResponseWriter, r *http.Request) {
 		expandedOfferings, err := app.store.OfferingProvider.GetExpandedOfferingsByUserID(userID)
 		if err != nil {
 			log.Printf("App.GetExpandedOfferingsByUserID - error getting expanded offerings by userID from provider %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(expandedOfferings)
@@ -222,7 +215,7 @@ func (app *App) GetOfferingsByUserID(w http.ResponseWriter, r *http.Request) {
 		offerings, err := app.store.OfferingProvider.GetOfferingsByUserID(userID)
 		if err != nil {
 			log.Printf("App.GetOfferingsByUserID - error getting offerings by userID from provider %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(offerings)
@@ -237,12 +230,12 @@ func (app *App) GetOfferingsByDateRange(w http.ResponseWriter, r *http.Request)
 	endTime, errEnd := utils.TimeStampToTime(end)
 	if errStart != nil {
 		log.Printf("App.GetOfferingsByDateRange - empty start time param: %v", errStart)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "start must be a unix timestamp")
 		return
 	}
 	if errEnd != nil {
 		log.Printf("App.GetOfferingsByDateRange - empty end time param: %v", errEnd)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "end must be a unix timestamp")
 		return
 	}
 	exp := r.FormValue("expand")
@@ -251,7 +244,7 @@ func (app *App) GetOfferingsByDateRange(w http.ResponseWriter, r *http.Request)
 		expand, err := strconv.ParseBool(exp)
 		if err != nil {
 			log.Printf("App.GetOneBooking - error converting string to boolean from query parameter %v", err)
-			w.WriteHeader(http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, db.Validation, "expand must be true or false")
 			return
 		}
 		expandBool = expand
@@ -260,7 +253,7 @@ func (app *App) GetOfferingsByDateRange(w http.ResponseWriter, r *http.Request)
 		expandedOfferings, err := app.store.OfferingProvider.GetExpandedOfferingsByDateRange(startTime, endTime)
 		if err != nil {
 			log.Printf("App.GetExpandedOfferingsByDateRange - error getting expanded offerings by date range from provider %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(expandedOfferings)
@@ -268,7 +261,7 @@ func (app *App) GetOfferingsByDateRange(w http.ResponseWriter, r *http.Request)
 		offerings, err := app.store.OfferingProvider.GetOfferingsByDateRange(startTime, endTime)
 		if err != nil {
 			log.Printf("App.GetOfferingsByDateRange - error getting offerings by date range from provider %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(offerings)
@@ -280,7 +273,7 @@ func (app *App) UpdateOffering(w http.ResponseWriter, r *http.Request) {
 
 	if offeringID == "" {
 		log.Printf("App.UpdateOffering - empty offering id")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "offering id is required")
 		return
 	}
 	var updatedOffering model.Offering
@@ -288,24 +281,25 @@ func (app *App) UpdateOffering(w http.ResponseWriter, r *http.Request) {
 	updatedOffering.ID = offeringID
 	if err != nil {
 		log.Printf("App.UpdateOffering - error reading request body %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "could not read the request body")
 		return
 	}
 	err = json.Unmarshal(reqBody, &updatedOffering)
 	if err != nil {
 		log.Printf("App.UpdateOffering - error unmarshaling request body %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "request body is not valid JSON")
 		return
 	}
 
 	err = app.store.OfferingProvider.UpdateOffering(offeringID, &updatedOffering)
 	if err != nil {
 		log.Printf("App.UpdateOffering - error getting all offerings from provider %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondStoreError(w, err, http.StatusBadRequest)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(updatedOffering)
+	app.publishOffering(notify.OfferingUpdated, offeringID)
 }
 
 func (app *App) RemoveOffering(w http.ResponseWriter, r *http.Request) {
@@ -313,40 +307,46 @@ func (app *App) RemoveOffering(w http.ResponseWriter, r *http.Request) {
 
 	if offeringID == "" {
 		log.Printf("App.RemoveOffering - empty offering id")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "offering id is required")
 		return
 	}
 	err := app.store.OfferingProvider.RemoveOffering(offeringID)
 	if err != nil {
 		log.Printf("App.RemoveOffering - error getting all offerings from provider %v", err)
-		if strings.Contains(err.Error(), "invalid") {
-			w.WriteHeader(http.StatusBadRequest)
-		} else {
-			w.WriteHeader(http.StatusNotFound)
-		}
+		respondStoreError(w, err, http.StatusNotFound)
 		return
 	}
+	// the store queued the cancellation email in the outbox along with the cancellation
 	w.WriteHeader(http.StatusOK)
+	app.publishOffering(notify.OfferingCancelled, offeringID)
+}
 
-	// todo check for user and send to creator as well
-	eOffering, err1 := app.store.OfferingProvider.GetOneExpandedOffering(offeringID)
-	if err1 != nil {
-		log.Printf("Erro getting booking %+v", err1)
+// offeringEvent is the event of the given type about an offering, for the user who offered the workspace
+func (app *App) offeringEvent(eventType, offeringID string) (*notify.Event, error) {
+	eOffering, err := app.store.OfferingProvider.GetOneExpandedOffering(offeringID)
+	if err != nil {
+		return nil, err
 	}
-	user, err2 := app.store.UserProvider.GetOneUser(eOffering.UserID)
-	if err2 == nil {
-		_ = app.email.SendCancellation(
-			mail.Offering,
-			&mail.EmailParams{
-				Name:          user.Name,
-				Email:         user.Email,
-				WorkspaceName: eOffering.WorkspaceName,
-				FloorName:     eOffering.FloorName,
-				Start:         eOffering.StartDate,
-				End:           eOffering.EndDate,
-			},
-		)
-	} else {
-		log.Printf("Error getting user: %+v", err2)
+	user, err := app.store.UserProvider.GetOneUser(eOffering.UserID)
+	if err != nil {
+		return nil, err
 	}
+	return &notify.Event{
+		Type:    eventType,
+		ID:      offeringID,
+		Time:    time.Now(),
+		UserID:  user.ID,
+		FloorID: eOffering.FloorID,
+		Params: &mail.EmailParams{
+			ID:            offeringID,
+			Name:          user.Name,
+			Email:         user.Email,
+			WorkspaceName: eOffering.WorkspaceName,
+			FloorName:     eOffering.FloorName,
+			Start:         eOffering.StartDate,
+			End:           eOffering.EndDate,
+			Locale:        user.Locale,
+			TimeZone:      user.TimeZone,
+		},
+	}, nil
 }
@@ -0,0 +1,168 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"go-api/auth"
+	"go-api/db"
+	"go-api/model"
+	"go-api/notify"
+	"go-api/utils"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+)
+
+func (app *App) RegisterOfferingSeriesRoutes() {
+	app.router.HandleFunc("/offerings/series", app.authorize(app.CreateOfferingSeries, auth.BodyOwnerOrAdmin("user_id"))).Methods("POST")
+	app.router.HandleFunc("/offerings/series/{id}", app.GetOfferingSeries).Methods("GET")
+	app.router.HandleFunc("/offerings/series/{id}", app.authorize(app.CancelOfferingSeries, auth.OwnerOrAdmin("id", app.offeringSeriesOwners))).Methods("DELETE").Queries("from", "{from:[0-9]+}")
+	app.router.HandleFunc("/offerings/series/{id}", app.authorize(app.CancelOfferingSeries, auth.OwnerOrAdmin("id", app.offeringSeriesOwners))).Methods("DELETE") // Handles when Query is empty
+}
+
+func (app *App) offeringSeriesOwners(id string) ([]string, error) {
+	series, err := app.store.OfferingProvider.GetOfferingSeries(id)
+	if err != nil {
+		return nil, err
+	}
+	return []string{series.UserID, series.CreatedBy}, nil
+}
+
+func (app *App) CreateOfferingSeries(w http.ResponseWriter, r *http.Request) {
+	var series model.OfferingSeries
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("App.CreateOfferingSeries - error reading request body %v", err)
+		respondError(w, http.StatusBadRequest, db.Validation, "could not read the request body")
+		return
+	}
+	err = json.Unmarshal(reqBody, &series)
+	if err != nil {
+		log.Printf("App.CreateOfferingSeries - error unmarshaling request body %v", err)
+		respondError(w, http.StatusBadRequest, db.Validation, "request body is not valid JSON")
+		return
+	}
+	if user, ok := auth.UserFromContext(r.Context()); ok {
+		series.CreatedBy = user.ID
+	} else if series.CreatedBy == "" {
+		series.CreatedBy = series.UserID
+	}
+
+	occurrences, err := utils.ExpandRecurrence(series.StartDate, series.EndDate, &series.Recurrence, app.userZone(series.UserID))
+	if err != nil {
+		log.Printf("App.CreateOfferingSeries - %v", err)
+		respondError(w, http.StatusBadRequest, db.Validation, err.Error())
+		return
+	}
+	offerings := make([]*model.Offering, 0, len(occurrences))
+	for _, occurrence := range occurrences {
+		offerings = append(offerings, &model.Offering{
+			WorkspaceID: series.WorkspaceID,
+			UserID:      series.UserID,
+			StartDate:   occurrence.Start,
+			EndDate:     occurrence.End,
+		})
+	}
+
+	offerings, conflicts, err := app.store.OfferingProvider.CreateOfferingSeries(&series, offerings)
+	if err != nil {
+		log.Printf("App.CreateOfferingSeries - error creating offering series %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	event, err := app.offeringEvent(notify.OfferingCreated, offerings[0].ID)
+	if err == nil {
+		event.ID, event.Series = series.ID, true
+		for _, offering := range offerings {
+			event.Params.AddOccurrence(offering.ID, offering.StartDate)
+		}
+		app.publish(event)
+	} else {
+		log.Printf("App.CreateOfferingSeries - error building event %+v", err)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&model.OfferingSeriesResult{
+		Series:    &series,
+		Offerings: offerings,
+		Conflicts: conflicts,
+	})
+	for _, offering := range offerings {
+		app.processWaitlist(offering.WorkspaceID, offering.StartDate, offering.EndDate)
+	}
+}
+
+func (app *App) GetOfferingSeries(w http.ResponseWriter, r *http.Request) {
+	seriesID := mux.Vars(r)["id"]
+	if seriesID == "" {
+		log.Printf("App.GetOfferingSeries - empty series id")
+		respondError(w, http.StatusBadRequest, db.Validation, "series id is required")
+		return
+	}
+	series, err := app.store.OfferingProvider.GetOfferingSeries(seriesID)
+	if err != nil {
+		log.Printf("App.GetOfferingSeries - error getting series from provider %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	offerings, err := app.store.OfferingProvider.GetOfferingsBySeriesID(seriesID)
+	if err != nil {
+		log.Printf("App.GetOfferingSeries - error getting offerings by series from provider %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&model.OfferingSeriesResult{
+		Series:    series,
+		Offerings: offerings,
+	})
+}
+
+// CancelOfferingSeries withdraws a whole series, or with ?from=<unix timestamp> only the occurrences starting at
+// or after that time. Occurrences that have been booked are left in place and reported as skipped
+func (app *App) CancelOfferingSeries(w http.ResponseWriter, r *http.Request) {
+	seriesID := mux.Vars(r)["id"]
+	if seriesID == "" {
+		log.Printf("App.CancelOfferingSeries - empty series id")
+		respondError(w, http.StatusBadRequest, db.Validation, "series id is required")
+		return
+	}
+	var from time.Time
+	if fromParam := r.FormValue("from"); fromParam != "" {
+		fromTime, err := utils.TimeStampToTime(fromParam)
+		if err != nil {
+			log.Printf("App.CancelOfferingSeries - invalid from param: %v", err)
+			respondError(w, http.StatusBadRequest, db.Validation, "from must be a unix timestamp")
+			return
+		}
+		from = fromTime
+	}
+
+	cancelled, skipped, err := app.store.OfferingProvider.CancelOfferingSeries(seriesID, from)
+	if err != nil {
+		log.Printf("App.CancelOfferingSeries - error cancelling series %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+
+	if len(cancelled) > 0 {
+		event, err := app.offeringEvent(notify.OfferingCancelled, cancelled[0].ID)
+		if err == nil {
+			event.ID, event.Series = seriesID, true
+			for _, offering := range cancelled {
+				event.Params.AddOccurrence(offering.ID, offering.StartDate)
+			}
+			app.publish(event)
+		} else {
+			log.Printf("App.CancelOfferingSeries - error building event %+v", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&model.SeriesCancellationResult{
+		Cancelled: cancelled,
+		Skipped:   skipped,
+	})
+}
@@ -5,11 +5,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-api/db/memory"
 	"go-api/mail"
 	"go-api/model"
 	"go-api/utils"
 	"log"
 	"net/http"
+	"testing"
+	"time"
 )
 
 func offeringEqualMinusID(this *model.Offering, other *model.Offering) bool { // To be used when testing Creation, as ID will not be known in advance.
@@ -410,3 +414,158 @@ func (suite *AppTestSuite) Test_ZDeleteOffering() { // Z to make it be performed
 	})
 	assert.Equal(t, http.StatusOK, rr2.Code, "status code") // todo: For now, Delete never fails
 }
+
+func (suite *AppTestSuite) Test_OfferingSeries() {
+	t := suite.T()
+	mockEmail := new(mockEmail)
+	mockEmail.On("SendConfirmation", mail.OfferingSeries).Return(nil)
+	mockEmail.On("SendCancellation", mail.OfferingSeries).Return(nil)
+	suite.app.email = mockEmail
+
+	// The second Friday is already offered
+	existing := &model.Offering{
+		UserID:      Offering5.UserID,
+		WorkspaceID: Offering5.WorkspaceID,
+		StartDate:   date("2021-04-09T16:00:00Z"),
+		EndDate:     date("2021-04-10T00:00:00Z"),
+		CreatedBy:   Offering5.UserID,
+	}
+	existingID, err := suite.app.store.OfferingProvider.CreateOffering(existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requestBody, _ := json.Marshal(map[string]interface{}{
+		"workspace_id": Offering5.WorkspaceID,
+		"user_id":      Offering5.UserID,
+		"start_time":   date("2021-04-02T16:00:00Z"),
+		"end_time":     date("2021-04-03T00:00:00Z"),
+		"recurrence": map[string]interface{}{
+			"frequency": model.FrequencyWeekly,
+			"weekdays":  []string{"FR"},
+			"count":     3,
+		},
+	})
+	rr := executeReq(t, &testRouteConfig{
+		Method:  http.MethodPost,
+		Body:    bytes.NewBuffer(requestBody),
+		Handler: suite.app.CreateOfferingSeries,
+		URL:     "/offerings/series",
+	})
+	assert.Equal(t, http.StatusCreated, rr.Code, "status code")
+	var created model.OfferingSeriesResult
+	_ = json.Unmarshal(rr.Body.Bytes(), &created)
+	if !assert.Len(t, created.Offerings, 2, "offerings") {
+		return
+	}
+	assert.Len(t, created.Conflicts, 1, "conflicts")
+	seriesID := created.Series.ID
+
+	rr = executeReq(t, &testRouteConfig{
+		Method:    http.MethodGet,
+		Handler:   suite.app.GetOfferingSeries,
+		URL:       fmt.Sprintf("/offerings/series/%s", seriesID),
+		URLParams: map[string]string{"id": seriesID},
+	})
+	assert.Equal(t, http.StatusOK, rr.Code, "status code")
+	var fetched model.OfferingSeriesResult
+	_ = json.Unmarshal(rr.Body.Bytes(), &fetched)
+	assert.Equal(t, created.Offerings, fetched.Offerings)
+
+	// Someone books the last occurrence, so it can't be withdrawn
+	bookingID, err := suite.app.store.BookingProvider.CreateBooking(&model.Booking{
+		UserID:      Booking1.UserID,
+		WorkspaceID: Offering5.WorkspaceID,
+		StartDate:   date("2021-04-16T17:00:00Z"),
+		EndDate:     date("2021-04-16T23:00:00Z"),
+		CreatedBy:   Booking1.UserID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel := func() (int, *model.SeriesCancellationResult) {
+		rr := executeReq(t, &testRouteConfig{
+			Method:    http.MethodDelete,
+			Handler:   suite.app.CancelOfferingSeries,
+			URL:       fmt.Sprintf("/offerings/series/%s", seriesID),
+			URLParams: map[string]string{"id": seriesID},
+		})
+		var result model.SeriesCancellationResult
+		_ = json.Unmarshal(rr.Body.Bytes(), &result)
+		return rr.Code, &result
+	}
+	code, result := cancel()
+	assert.Equal(t, http.StatusOK, code, "status code")
+	assert.Len(t, result.Cancelled, 1, "unbooked occurrence")
+	assert.Len(t, result.Skipped, 1, "booked occurrence")
+
+	// Once the booking is gone the rest of the series can be withdrawn
+	_ = suite.app.store.BookingProvider.RemoveBooking(bookingID)
+	code, result = cancel()
+	assert.Equal(t, http.StatusOK, code, "status code")
+	assert.Len(t, result.Cancelled, 1, "previously booked occurrence")
+	assert.Len(t, result.Skipped, 0)
+
+	code, _ = cancel()
+	assert.Equal(t, http.StatusConflict, code, "already cancelled")
+
+	_ = suite.app.store.OfferingProvider.RemoveOffering(existingID)
+}
+
+func TestOfferingSeriesTimeZone(t *testing.T) {
+	store := memory.NewMemoryDataStore()
+	app := &App{store: store}
+	userId := "00000000-0000-4000-a000-0000000000b0"
+	require.NoError(t, store.UserProvider.CreateUser(&model.User{ID: userId, Name: "Bob", Email: "bob@example.com", TimeZone: "Europe/Paris"}))
+	floorId, err := store.FloorProvider.CreateFloor(&model.Floor{Name: "West 2nd Avenue"})
+	require.NoError(t, err)
+	workspaceId, err := store.WorkspaceProvider.CreateAssignWorkspace(&model.Workspace{Name: "W1", Floor: floorId}, userId)
+	require.NoError(t, err)
+	location, err := time.LoadLocation("Europe/Paris")
+	require.NoError(t, err)
+
+	// daylight saving time starts in Paris three weeks after it does in Vancouver
+	start := time.Date(2030, time.February, 25, 9, 0, 0, 0, location)
+	body, _ := json.Marshal(map[string]interface{}{
+		"workspace_id": workspaceId, "user_id": userId, "start_time": start, "end_time": start.Add(8 * time.Hour),
+		"recurrence": map[string]interface{}{"frequency": model.FrequencyWeekly, "count": 6},
+	})
+	rr := executeReq(t, &testRouteConfig{
+		Method:  http.MethodPost,
+		Body:    bytes.NewBuffer(body),
+		Handler: app.CreateOfferingSeries,
+		URL:     "/offerings/series",
+	})
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var created model.OfferingSeriesResult
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	if assert.Len(t, created.Offerings, 6) {
+		for _, offering := range created.Offerings {
+			assert.Equal(t, 9, offering.StartDate.In(location).Hour())
+			assert.Equal(t, 17, offering.EndDate.In(location).Hour())
+		}
+	}
+}
+
+func TestOfferingSeriesRecurrenceLimits(t *testing.T) {
+	store := memory.NewMemoryDataStore()
+	app := &App{store: store}
+	start := time.Date(2030, time.February, 25, 9, 0, 0, 0, time.UTC)
+	until := start.AddDate(3, 0, 0)
+	for name, recurrence := range map[string]map[string]interface{}{
+		"interval": {"frequency": model.FrequencyDaily, "interval": 2000000000, "count": 2},
+		"until":    {"frequency": model.FrequencyWeekly, "until": until},
+	} {
+		body, _ := json.Marshal(map[string]interface{}{
+			"workspace_id": "w1", "user_id": "u1", "start_time": start, "end_time": start.Add(8 * time.Hour),
+			"recurrence": recurrence,
+		})
+		rr := executeReq(t, &testRouteConfig{
+			Method:  http.MethodPost,
+			Body:    bytes.NewBuffer(body),
+			Handler: app.CreateOfferingSeries,
+			URL:     "/offerings/series",
+		})
+		assert.Equal(t, http.StatusBadRequest, rr.Code, name)
+	}
+}
@@ -0,0 +1,147 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"go-api/auth"
+	"go-api/db"
+	"go-api/model"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultOutboxMaxAttempts is how many times a notification is tried before it is dead-lettered
+	DefaultOutboxMaxAttempts = 8
+	// outboxInterval is how often the outbox worker looks for notifications that are due
+	outboxInterval = 10 * time.Second
+	outboxBatch    = 50
+	// outboxLease keeps other instances from sending a claimed notification while this one is sending it
+	outboxLease = 5 * time.Minute
+	// outboxBackoff is the wait after the first failed attempt; it doubles with every attempt up to outboxMaxBackoff
+	outboxBackoff    = 30 * time.Second
+	outboxMaxBackoff = 6 * time.Hour
+)
+
+func (app *App) RegisterOutboxRoutes() {
+	app.router.HandleFunc("/outbox", app.authorize(app.GetOutboxMessages, auth.AdminOnly)).Methods("GET")
+	app.router.HandleFunc("/outbox/{id}", app.authorize(app.GetOneOutboxMessage, auth.AdminOnly)).Methods("GET")
+	app.router.HandleFunc("/outbox/{id}/replay", app.authorize(app.ReplayOutboxMessage, auth.AdminOnly)).Methods("POST")
+}
+
+// GetOutboxMessages lists the queued notifications, newest first, optionally only those with the given status
+func (app *App) GetOutboxMessages(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", model.OutboxPending, model.OutboxDelivered, model.OutboxDead:
+	default:
+		respondError(w, http.StatusBadRequest, db.Validation, fmt.Sprintf("unknown status %q", status))
+		return
+	}
+	messages, err := app.store.OutboxProvider.GetOutboxMessages(status)
+	if err != nil {
+		log.Printf("App.GetOutboxMessages - error getting messages from provider %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(messages)
+}
+
+func (app *App) GetOneOutboxMessage(w http.ResponseWriter, r *http.Request) {
+	message, err := app.store.OutboxProvider.GetOneOutboxMessage(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("App.GetOneOutboxMessage - error getting message from provider %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(message)
+}
+
+// ReplayOutboxMessage queues a dead-lettered notification again, with a fresh set of attempts
+func (app *App) ReplayOutboxMessage(w http.ResponseWriter, r *http.Request) {
+	message, err := app.store.OutboxProvider.ReplayOutboxMessage(mux.Vars(r)["id"], time.Now())
+	if err != nil {
+		log.Printf("App.ReplayOutboxMessage - error replaying message %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(message)
+}
+
+func (app *App) maxOutboxAttempts() int {
+	if app.outboxMaxAttempts == 0 {
+		return DefaultOutboxMaxAttempts
+	}
+	return app.outboxMaxAttempts
+}
+
+// StartOutbox delivers the notifications queued in the outbox until the app stops
+func (app *App) StartOutbox() {
+	go func() {
+		ticker := time.NewTicker(outboxInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			app.deliverOutbox(now)
+		}
+	}()
+}
+
+// deliverOutbox sends the notifications due at now. Failed ones are retried with exponential backoff until they
+// have been tried maxOutboxAttempts times, then they are dead-lettered until an admin replays them
+func (app *App) deliverOutbox(now time.Time) {
+	messages, err := app.store.OutboxProvider.ClaimOutboxMessages(now, outboxBatch, outboxLease)
+	if err != nil {
+		log.Printf("App.deliverOutbox - error claiming messages %v", err)
+		return
+	}
+	for _, message := range messages {
+		err = app.sendNotification(message)
+		if err == nil {
+			err = app.store.OutboxProvider.MarkOutboxDelivered(message.ID, time.Now())
+			if err != nil {
+				log.Printf("App.deliverOutbox - error marking %s delivered %v", message.ID, err)
+			}
+			continue
+		}
+		log.Printf("App.deliverOutbox - error sending %s, attempt %d %v", message.ID, message.Attempts+1, err)
+		var next *time.Time
+		if message.Attempts+1 < app.maxOutboxAttempts() {
+			retry := time.Now().Add(outboxRetryDelay(message.Attempts + 1))
+			next = &retry
+		}
+		if err = app.store.OutboxProvider.MarkOutboxFailed(message.ID, err.Error(), next); err != nil {
+			log.Printf("App.deliverOutbox - error recording failure of %s %v", message.ID, err)
+		}
+	}
+}
+
+// outboxRetryDelay is how long to wait after a notification failed for the attempts-th time
+func outboxRetryDelay(attempts int) time.Duration {
+	delay := outboxBackoff
+	for i := 1; i < attempts && delay < outboxMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > outboxMaxBackoff {
+		return outboxMaxBackoff
+	}
+	return delay
+}
+
+// sendNotification sends the notification of an outbox message as its event. The event is keyed by the message, so
+// that its channels hear of it once however often the message is retried
+func (app *App) sendNotification(message *model.OutboxMessage) error {
+	event, err := notificationEvent(&message.Notification, message.CreatedAt)
+	if err != nil {
+		return err
+	}
+	event.Key = message.ID
+	return app.notifier().Notify(event)
+}
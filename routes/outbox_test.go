@@ -0,0 +1,97 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-api/db"
+	"go-api/db/memory"
+	"go-api/mail"
+	"go-api/model"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestOutbox(t *testing.T) {
+	store := memory.NewMemoryDataStore()
+	email := new(mockEmail)
+	app := &App{store: store, email: email, outboxMaxAttempts: 2}
+
+	require.NoError(t, store.UserProvider.CreateUser(&model.User{ID: "00000000-0000-4000-a000-0000000000b0", Name: "Bob", Email: "bob@example.com"}))
+	floorId, err := store.FloorProvider.CreateFloor(&model.Floor{Name: "Floor 1", Address: "1 Main St"})
+	require.NoError(t, err)
+	workspaceId, err := store.WorkspaceProvider.CreateWorkspace(&model.Workspace{Name: "W1", Floor: floorId, Props: model.Attrs{}})
+	require.NoError(t, err)
+	now := time.Now()
+	_, err = store.OfferingProvider.CreateDefaultOffering(&model.Offering{WorkspaceID: workspaceId, StartDate: now.AddDate(0, 0, -1)})
+	require.NoError(t, err)
+	_, err = store.BookingProvider.CreateBooking(&model.Booking{
+		UserID:      "00000000-0000-4000-a000-0000000000b0",
+		WorkspaceID: workspaceId,
+		StartDate:   now.AddDate(0, 0, 1),
+		EndDate:     now.AddDate(0, 0, 1).Add(8 * time.Hour),
+		CreatedBy:   "00000000-0000-4000-a000-0000000000b0",
+	})
+	require.NoError(t, err)
+
+	email.On("SendConfirmation", mail.Booking).Return(errors.New("mailbox unavailable")).Twice()
+	app.deliverOutbox(time.Now())
+	messages, _ := store.OutboxProvider.GetOutboxMessages("")
+	require.Len(t, messages, 1)
+	assert.Equal(t, model.OutboxPending, messages[0].Status)
+	assert.Equal(t, 1, messages[0].Attempts)
+	assert.Equal(t, "mailbox unavailable", messages[0].LastError)
+	assert.True(t, messages[0].NextAttemptAt.After(time.Now()), "retried later")
+	assert.Equal(t, "W1", messages[0].Notification.WorkspaceName)
+
+	app.deliverOutbox(messages[0].NextAttemptAt)
+	messages, _ = store.OutboxProvider.GetOutboxMessages(model.OutboxDead)
+	require.Len(t, messages, 1, "dead-lettered after the last attempt")
+
+	rr := executeReq(t, &testRouteConfig{Method: http.MethodGet, URL: "/outbox?status=dead", Handler: app.GetOutboxMessages})
+	assert.Equal(t, http.StatusOK, rr.Code, "status code")
+	var listed []*model.OutboxMessage
+	_ = json.Unmarshal(rr.Body.Bytes(), &listed)
+	assert.Len(t, listed, 1)
+	rr = executeReq(t, &testRouteConfig{Method: http.MethodGet, URL: "/outbox?status=lost", Handler: app.GetOutboxMessages})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "status code")
+
+	replay := func() int {
+		return executeReq(t, &testRouteConfig{
+			Method:    http.MethodPost,
+			URL:       "/outbox/" + messages[0].ID + "/replay",
+			URLParams: map[string]string{"id": messages[0].ID},
+			Handler:   app.ReplayOutboxMessage,
+		}).Code
+	}
+	assert.Equal(t, http.StatusOK, replay(), "status code")
+	assert.Equal(t, http.StatusConflict, replay(), "only dead messages can be replayed")
+
+	email.On("SendConfirmation", mail.Booking).Return(nil).Once()
+	app.deliverOutbox(time.Now())
+	message, err := store.OutboxProvider.GetOneOutboxMessage(messages[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.OutboxDelivered, message.Status)
+	assert.NotNil(t, message.DeliveredAt)
+	email.AssertNumberOfCalls(t, "SendConfirmation", 3)
+}
+
+// queuedNotification is the pending notification of the given kind about subjectID in the outbox of store, if any
+func queuedNotification(t *testing.T, store *db.DataStore, kind, subjectID string) *model.Notification {
+	messages, err := store.OutboxProvider.GetOutboxMessages(model.OutboxPending)
+	require.NoError(t, err)
+	for _, message := range messages {
+		if message.Notification.Kind == kind && message.Notification.SubjectID == subjectID {
+			return &message.Notification
+		}
+	}
+	return nil
+}
+
+func TestOutboxRetryDelay(t *testing.T) {
+	assert.Equal(t, outboxBackoff, outboxRetryDelay(1))
+	assert.Equal(t, 4*outboxBackoff, outboxRetryDelay(3))
+	assert.Equal(t, outboxMaxBackoff, outboxRetryDelay(30))
+}
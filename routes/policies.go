@@ -0,0 +1,135 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"go-api/auth"
+	"go-api/db"
+	"go-api/model"
+	"io/ioutil"
+	"log"
+	"net/http"
+)
+
+func (app *App) RegisterPolicyRoutes() {
+	app.router.HandleFunc("/policies", app.GetBookingPolicy).Methods("GET")
+	app.router.HandleFunc("/policies", app.authorize(app.UpdateBookingPolicy, auth.AdminOnly)).Methods("PUT")
+	app.router.HandleFunc("/floors/{id}/blackouts", app.GetFloorBlackouts).Methods("GET")
+	app.router.HandleFunc("/floors/{id}/blackouts", app.authorize(app.CreateFloorBlackout, auth.AdminOnly)).Methods("POST")
+	app.router.HandleFunc("/floors/{id}/blackouts/{blackout_id}", app.authorize(app.RemoveFloorBlackout, auth.AdminOnly)).Methods("DELETE")
+}
+
+func (app *App) GetBookingPolicy(w http.ResponseWriter, r *http.Request) {
+	policy, err := app.store.PolicyProvider.GetBookingPolicy()
+	if err != nil {
+		log.Printf("App.GetBookingPolicy - error getting policy from provider %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(policy)
+}
+
+func (app *App) UpdateBookingPolicy(w http.ResponseWriter, r *http.Request) {
+	var policy model.BookingPolicy
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("App.UpdateBookingPolicy - error reading request body %v", err)
+		respondError(w, http.StatusBadRequest, db.Validation, "could not read the request body")
+		return
+	}
+	err = json.Unmarshal(reqBody, &policy)
+	if err != nil {
+		log.Printf("App.UpdateBookingPolicy - error unmarshaling request body %v", err)
+		respondError(w, http.StatusBadRequest, db.Validation, "request body is not valid JSON")
+		return
+	}
+	if policy.MaxAdvanceDays < 0 || policy.MaxDurationHours < 0 || policy.MaxFutureBookings < 0 || policy.MaxDepartmentDaily < 0 {
+		log.Printf("App.UpdateBookingPolicy - negative limit")
+		respondError(w, http.StatusBadRequest, db.Validation, "limits can't be negative")
+		return
+	}
+	err = app.store.PolicyProvider.UpdateBookingPolicy(&policy)
+	if err != nil {
+		log.Printf("App.UpdateBookingPolicy - error updating policy %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&policy)
+}
+
+func (app *App) GetFloorBlackouts(w http.ResponseWriter, r *http.Request) {
+	floorID := mux.Vars(r)["id"]
+	if floorID == "" {
+		log.Printf("App.GetFloorBlackouts - empty floor id")
+		respondError(w, http.StatusBadRequest, db.Validation, "floor id is required")
+		return
+	}
+	blackouts, err := app.store.PolicyProvider.GetFloorBlackouts(floorID)
+	if err != nil {
+		log.Printf("App.GetFloorBlackouts - error getting blackouts from provider %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(blackouts)
+}
+
+func (app *App) CreateFloorBlackout(w http.ResponseWriter, r *http.Request) {
+	floorID := mux.Vars(r)["id"]
+	if floorID == "" {
+		log.Printf("App.CreateFloorBlackout - empty floor id")
+		respondError(w, http.StatusBadRequest, db.Validation, "floor id is required")
+		return
+	}
+	var blackout model.FloorBlackout
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("App.CreateFloorBlackout - error reading request body %v", err)
+		respondError(w, http.StatusBadRequest, db.Validation, "could not read the request body")
+		return
+	}
+	err = json.Unmarshal(reqBody, &blackout)
+	if err != nil {
+		log.Printf("App.CreateFloorBlackout - error unmarshaling request body %v", err)
+		respondError(w, http.StatusBadRequest, db.Validation, "request body is not valid JSON")
+		return
+	}
+	if !blackout.EndDate.After(blackout.StartDate) {
+		log.Printf("App.CreateFloorBlackout - end time must be after start time")
+		respondError(w, http.StatusBadRequest, db.Validation, "end time must be after start time")
+		return
+	}
+	if _, err = app.store.FloorProvider.GetOneFloor(floorID); err != nil {
+		log.Printf("App.CreateFloorBlackout - error getting floor from provider %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	blackout.FloorID = floorID
+	id, err := app.store.PolicyProvider.CreateFloorBlackout(&blackout)
+	if err != nil {
+		log.Printf("App.CreateFloorBlackout - error creating blackout %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	blackout.ID = id
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&blackout)
+}
+
+func (app *App) RemoveFloorBlackout(w http.ResponseWriter, r *http.Request) {
+	blackoutID := mux.Vars(r)["blackout_id"]
+	if blackoutID == "" {
+		log.Printf("App.RemoveFloorBlackout - empty blackout id")
+		respondError(w, http.StatusBadRequest, db.Validation, "blackout id is required")
+		return
+	}
+	err := app.store.PolicyProvider.RemoveFloorBlackout(blackoutID)
+	if err != nil {
+		log.Printf("App.RemoveFloorBlackout - error removing blackout %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,92 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"go-api/mail"
+	"go-api/model"
+	"net/http"
+)
+
+func (suite *AppTestSuite) setBookingPolicy(policy *model.BookingPolicy) {
+	requestBody, _ := json.Marshal(policy)
+	rr := executeReq(suite.T(), &testRouteConfig{
+		Method:  http.MethodPut,
+		Body:    bytes.NewBuffer(requestBody),
+		Handler: suite.app.UpdateBookingPolicy,
+		URL:     "/policies",
+	})
+	assert.Equal(suite.T(), http.StatusOK, rr.Code, "status code")
+}
+
+func (suite *AppTestSuite) Test_BookingPolicy() {
+	t := suite.T()
+	mockEmail := new(mockEmail)
+	mockEmail.On("SendConfirmation", mail.Booking).Return(nil)
+	suite.app.email = mockEmail
+
+	book := func(start, end string) (int, map[string]interface{}) {
+		requestBody, _ := json.Marshal(map[string]string{
+			"user_id":      barryID,
+			"workspace_id": Offering5.WorkspaceID,
+			"start_time":   start,
+			"end_time":     end,
+		})
+		rr := executeReq(t, &testRouteConfig{
+			Method:  http.MethodPost,
+			Body:    bytes.NewBuffer(requestBody),
+			Handler: suite.app.CreateBooking,
+			URL:     "/bookings",
+		})
+		var body map[string]interface{}
+		_ = json.Unmarshal(rr.Body.Bytes(), &body)
+		return rr.Code, body
+	}
+
+	suite.setBookingPolicy(&model.BookingPolicy{MaxDurationHours: 2})
+	code, body := book("2019-01-23T10:00:00Z", "2019-01-23T14:00:00Z")
+	assert.Equal(t, http.StatusBadRequest, code, "status code")
+	assert.Equal(t, "max_duration", body["code"])
+
+	// Barry already has bookings on the 23rd
+	suite.setBookingPolicy(&model.BookingPolicy{MaxDepartmentDaily: 1})
+	code, body = book("2019-01-23T10:00:00Z", "2019-01-23T12:00:00Z")
+	assert.Equal(t, http.StatusBadRequest, code, "status code")
+	assert.Equal(t, "max_department_daily", body["code"])
+	suite.setBookingPolicy(&model.BookingPolicy{})
+
+	requestBody, _ := json.Marshal(map[string]string{
+		"start_time": "2019-01-23T00:00:00Z",
+		"end_time":   "2019-01-24T00:00:00Z",
+		"reason":     "Carpet cleaning",
+	})
+	rr := executeReq(t, &testRouteConfig{
+		Method:    http.MethodPost,
+		Body:      bytes.NewBuffer(requestBody),
+		Handler:   suite.app.CreateFloorBlackout,
+		URL:       fmt.Sprintf("/floors/%s/blackouts", MainFloor.ID),
+		URLParams: map[string]string{"id": MainFloor.ID},
+	})
+	assert.Equal(t, http.StatusCreated, rr.Code, "status code")
+	var blackout model.FloorBlackout
+	_ = json.Unmarshal(rr.Body.Bytes(), &blackout)
+
+	code, body = book("2019-01-23T10:00:00Z", "2019-01-23T12:00:00Z")
+	assert.Equal(t, http.StatusBadRequest, code, "status code")
+	assert.Equal(t, "floor_blackout", body["code"])
+
+	rr = executeReq(t, &testRouteConfig{
+		Method:    http.MethodDelete,
+		Handler:   suite.app.RemoveFloorBlackout,
+		URL:       fmt.Sprintf("/floors/%s/blackouts/%s", MainFloor.ID, blackout.ID),
+		URLParams: map[string]string{"id": MainFloor.ID, "blackout_id": blackout.ID},
+	})
+	assert.Equal(t, http.StatusOK, rr.Code, "status code")
+
+	code, body = book("2019-01-23T10:00:00Z", "2019-01-23T12:00:00Z")
+	if assert.Equal(t, http.StatusCreated, code, "status code") {
+		assert.NoError(t, suite.app.store.BookingProvider.RemoveBooking(body["id"].(string)))
+	}
+}
@@ -0,0 +1,257 @@
+package routes
+
+import (
+	"fmt"
+	"go-api/model"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// reminderInterval is how often the scheduler looks for reminders that are due
+	reminderInterval = time.Minute
+	// reminderLateness is how late a reminder can still be sent, e.g. after a restart; later ones are skipped, as is
+	// the evening reminder of a booking made the next morning
+	reminderLateness = time.Hour
+	// reminderRetention is how long the keys of queued reminders are kept, longer than any reminder is sent early
+	reminderRetention = 30 * 24 * time.Hour
+	// digestPeriod is how far ahead digests look
+	digestPeriod = 7 * 24 * time.Hour
+)
+
+// reminder is when a reminder is sent before a booking: before it starts, or at a time of day on the day before it
+// in the user's time zone
+type reminder struct {
+	// spec is how the reminder was configured, which tells its queued reminders apart
+	spec         string
+	before       time.Duration
+	dayBefore    bool
+	hour, minute int
+}
+
+// parseReminders parses a comma separated list of reminders, each a duration before the booking starts such as
+// "30m", or a time on the day before such as "18:00"
+func parseReminders(specs string) ([]*reminder, error) {
+	reminders := make([]*reminder, 0)
+	for _, spec := range strings.Split(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		if parts := strings.Split(spec, ":"); len(parts) == 2 {
+			hour, hourErr := strconv.Atoi(parts[0])
+			minute, minuteErr := strconv.Atoi(parts[1])
+			if hourErr != nil || minuteErr != nil || hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+				return nil, fmt.Errorf("invalid reminder time %q", spec)
+			}
+			reminders = append(reminders, &reminder{spec: spec, dayBefore: true, hour: hour, minute: minute})
+			continue
+		}
+		before, err := time.ParseDuration(spec)
+		if err != nil || before <= 0 {
+			return nil, fmt.Errorf("invalid reminder %q", spec)
+		}
+		reminders = append(reminders, &reminder{spec: spec, before: before})
+	}
+	return reminders, nil
+}
+
+// due is when the reminder of a booking starting at start is sent
+func (r *reminder) due(start time.Time, zone *time.Location) time.Time {
+	if !r.dayBefore {
+		return start.Add(-r.before)
+	}
+	year, month, day := start.In(zone).Date()
+	return time.Date(year, month, day-1, r.hour, r.minute, 0, 0, zone)
+}
+
+// reminderHorizon is how far ahead of now the bookings that may have a reminder due start
+func (app *App) reminderHorizon() time.Duration {
+	horizon := time.Duration(0)
+	for _, r := range app.reminders {
+		before := r.before
+		if r.dayBefore {
+			before = 48 * time.Hour
+		}
+		if before > horizon {
+			horizon = before
+		}
+	}
+	return horizon
+}
+
+// StartReminders queues the reminders before bookings, then the digests on the app's cron schedule, until the app
+// stops. Every instance runs them; the keys of queued reminders make sure each is only sent once
+func (app *App) StartReminders() {
+	if len(app.reminders) > 0 {
+		go func() {
+			ticker := time.NewTicker(reminderInterval)
+			defer ticker.Stop()
+			for now := range ticker.C {
+				app.queueReminders(now)
+			}
+		}()
+	}
+	if app.digestSchedule != nil {
+		go func() {
+			for {
+				next := app.digestSchedule.Next(time.Now())
+				if next.IsZero() {
+					log.Println("App.StartReminders - digest schedule never runs")
+					return
+				}
+				time.Sleep(time.Until(next))
+				app.queueDigests(next)
+			}
+		}()
+	}
+}
+
+// queueReminders queues the reminders due at now, at most reminderLateness late, of the bookings that haven't
+// started yet. A booking that is moved gets its reminders again
+func (app *App) queueReminders(now time.Time) {
+	if err := app.store.ReminderProvider.DeleteRemindersBefore(now.Add(-reminderRetention)); err != nil {
+		log.Printf("App.queueReminders - error deleting old reminders %v", err)
+	}
+	bookings, err := app.store.BookingProvider.GetExpandedBookingsByDateRange(now, now.Add(app.reminderHorizon()))
+	if err != nil {
+		log.Printf("App.queueReminders - error getting bookings from provider %v", err)
+		return
+	}
+	addresses, err := app.floorAddresses()
+	if err != nil {
+		log.Printf("App.queueReminders - error getting floors from provider %v", err)
+		return
+	}
+	users := make(map[string]*model.User)
+	for _, booking := range bookings {
+		if booking.Cancelled || !booking.StartDate.After(now) {
+			continue
+		}
+		user, ok := users[booking.UserID]
+		if !ok {
+			if user, err = app.store.UserProvider.GetOneUser(booking.UserID); err != nil {
+				log.Printf("App.queueReminders - error getting user from provider %v", err)
+			}
+			users[booking.UserID] = user
+		}
+		if user == nil || user.RemindersOptOut {
+			continue
+		}
+		zone := app.templates.Zone(user.TimeZone)
+		for _, r := range app.reminders {
+			due := r.due(booking.StartDate, zone)
+			if due.After(now) || now.Sub(due) > reminderLateness {
+				continue
+			}
+			key := fmt.Sprintf("reminder:%s:%s:%d", booking.ID, r.spec, booking.StartDate.Unix())
+			_, err = app.store.ReminderProvider.QueueReminder(key, &model.Notification{
+				Kind:          model.NotificationReminder,
+				Subject:       model.NotificationBooking,
+				SubjectID:     booking.ID,
+				UserID:        booking.UserID,
+				WorkspaceName: booking.WorkspaceName,
+				FloorID:       booking.FloorID,
+				FloorName:     booking.FloorName,
+				FloorAddress:  addresses[booking.FloorID],
+				StartDate:     booking.StartDate,
+				EndDate:       booking.EndDate,
+			})
+			if err != nil {
+				log.Printf("App.queueReminders - error queueing reminder %s %v", key, err)
+			}
+		}
+	}
+}
+
+// queueDigests queues a digest of the bookings and offerings starting in the digestPeriod after now for every user
+// who has any and hasn't opted out
+func (app *App) queueDigests(now time.Time) {
+	users, err := app.store.UserProvider.GetAllUsers()
+	if err != nil {
+		log.Printf("App.queueDigests - error getting users from provider %v", err)
+		return
+	}
+	addresses, err := app.floorAddresses()
+	if err != nil {
+		log.Printf("App.queueDigests - error getting floors from provider %v", err)
+		return
+	}
+	end := now.Add(digestPeriod)
+	upcoming := func(cancelled bool, start time.Time) bool {
+		return !cancelled && !start.Before(now) && start.Before(end)
+	}
+	for _, user := range users {
+		if user.DigestOptOut {
+			continue
+		}
+		bookings, err := app.store.BookingProvider.GetExpandedBookingsByUserID(user.ID)
+		if err != nil {
+			log.Printf("App.queueDigests - error getting bookings from provider %v", err)
+			continue
+		}
+		offerings, err := app.store.OfferingProvider.GetExpandedOfferingsByUserID(user.ID)
+		if err != nil {
+			log.Printf("App.queueDigests - error getting offerings from provider %v", err)
+			continue
+		}
+		items := make([]*model.DigestItem, 0)
+		for _, b := range bookings {
+			if upcoming(b.Cancelled, b.StartDate) {
+				items = append(items, &model.DigestItem{
+					Subject:       model.NotificationBooking,
+					SubjectID:     b.ID,
+					WorkspaceName: b.WorkspaceName,
+					FloorName:     b.FloorName,
+					FloorAddress:  addresses[b.FloorID],
+					StartDate:     b.StartDate,
+					EndDate:       b.EndDate,
+				})
+			}
+		}
+		for _, o := range offerings {
+			if upcoming(o.Cancelled, o.StartDate) {
+				items = append(items, &model.DigestItem{
+					Subject:       model.NotificationOffering,
+					SubjectID:     o.ID,
+					WorkspaceName: o.WorkspaceName,
+					FloorName:     o.FloorName,
+					FloorAddress:  addresses[o.FloorID],
+					StartDate:     o.StartDate,
+					EndDate:       o.EndDate,
+				})
+			}
+		}
+		if len(items) == 0 {
+			continue
+		}
+		sort.SliceStable(items, func(i, j int) bool { return items[i].StartDate.Before(items[j].StartDate) })
+		key := fmt.Sprintf("digest:%s:%d", user.ID, now.Unix())
+		_, err = app.store.ReminderProvider.QueueReminder(key, &model.Notification{
+			Kind:      model.NotificationDigest,
+			UserID:    user.ID,
+			StartDate: now,
+			EndDate:   end,
+			Items:     items,
+		})
+		if err != nil {
+			log.Printf("App.queueDigests - error queueing digest %s %v", key, err)
+		}
+	}
+}
+
+// floorAddresses maps the id of every floor to its address
+func (app *App) floorAddresses() (map[string]string, error) {
+	floors, err := app.store.FloorProvider.GetAllFloors()
+	if err != nil {
+		return nil, err
+	}
+	addresses := make(map[string]string)
+	for _, floor := range floors {
+		addresses[floor.ID] = floor.Address
+	}
+	return addresses, nil
+}
@@ -0,0 +1,118 @@
+package routes
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go-api/db/memory"
+	"go-api/mail"
+	"go-api/model"
+	"testing"
+	"time"
+)
+
+func TestParseReminders(t *testing.T) {
+	reminders, err := parseReminders("18:00, 30m")
+	require.NoError(t, err)
+	require.Len(t, reminders, 2)
+	zone, err := time.LoadLocation("America/Vancouver")
+	require.NoError(t, err)
+	start := time.Date(2021, time.March, 15, 9, 0, 0, 0, zone)
+	assert.Equal(t, time.Date(2021, time.March, 14, 18, 0, 0, 0, zone), reminders[0].due(start, zone))
+	assert.Equal(t, start.Add(-30*time.Minute), reminders[1].due(start, zone))
+
+	for _, specs := range []string{"24:00", "18:60", "soon", "-5m", "0s"} {
+		_, err = parseReminders(specs)
+		assert.Error(t, err, specs)
+	}
+	reminders, err = parseReminders("")
+	require.NoError(t, err)
+	assert.Empty(t, reminders)
+}
+
+func TestReminders(t *testing.T) {
+	store := memory.NewMemoryDataStore()
+	templates, err := mail.NewTemplates(&mail.TemplateConfig{DefaultTimeZone: "America/Vancouver"})
+	require.NoError(t, err)
+	reminders, err := parseReminders("18:00,30m")
+	require.NoError(t, err)
+	app := &App{store: store, templates: templates, reminders: reminders}
+
+	owner, booker := "00000000-0000-4000-a000-0000000000a0", "00000000-0000-4000-a000-0000000000b0"
+	require.NoError(t, store.UserProvider.CreateUser(&model.User{ID: owner, Name: "Alice", Email: "alice@example.com"}))
+	require.NoError(t, store.UserProvider.CreateUser(&model.User{ID: booker, Name: "Bob", Email: "bob@example.com"}))
+	floorId, err := store.FloorProvider.CreateFloor(&model.Floor{Name: "West 2nd Avenue", Address: "151 W 2nd Ave"})
+	require.NoError(t, err)
+	workspaceId, err := store.WorkspaceProvider.CreateAssignWorkspace(&model.Workspace{Name: "W-001", Floor: floorId}, owner)
+	require.NoError(t, err)
+	zone := templates.Zone("")
+	year, month, day := time.Now().In(zone).Date()
+	start := time.Date(year, month, day+2, 9, 0, 0, 0, zone)
+	offeringId, err := store.OfferingProvider.CreateOffering(&model.Offering{
+		UserID: owner, WorkspaceID: workspaceId, StartDate: start, EndDate: start.AddDate(0, 0, 3), CreatedBy: owner,
+	})
+	require.NoError(t, err)
+	bookingId, err := store.BookingProvider.CreateBooking(&model.Booking{
+		UserID: booker, WorkspaceID: workspaceId, StartDate: start, EndDate: start.Add(8 * time.Hour), CreatedBy: booker,
+	})
+	require.NoError(t, err)
+
+	queued := func(kind string) []*model.Notification {
+		messages, err := store.OutboxProvider.GetOutboxMessages(model.OutboxPending)
+		require.NoError(t, err)
+		notifications := make([]*model.Notification, 0)
+		for _, message := range messages {
+			if message.Notification.Kind == kind {
+				notification := message.Notification
+				notifications = append(notifications, &notification)
+			}
+		}
+		return notifications
+	}
+
+	evening := time.Date(year, month, day+1, 18, 0, 0, 0, zone)
+	app.queueReminders(evening.Add(-time.Minute))
+	assert.Empty(t, queued(model.NotificationReminder), "not due yet")
+	app.queueReminders(evening.Add(time.Minute))
+	app.queueReminders(evening.Add(2 * time.Minute))
+	if reminders := queued(model.NotificationReminder); assert.Len(t, reminders, 1, "sent once") {
+		assert.Equal(t, bookingId, reminders[0].SubjectID)
+		assert.Equal(t, "bob@example.com", reminders[0].Email)
+		assert.Equal(t, "151 W 2nd Ave", reminders[0].FloorAddress)
+	}
+	app.queueReminders(start.Add(-30*time.Minute - reminderLateness - time.Minute))
+	assert.Len(t, queued(model.NotificationReminder), 1)
+	require.NoError(t, store.UserProvider.UpdateUserPreferences(booker, &model.UserPreferences{RemindersOptOut: true}))
+	app.queueReminders(start.Add(-29 * time.Minute))
+	assert.Len(t, queued(model.NotificationReminder), 1, "Bob opted out")
+	require.NoError(t, store.UserProvider.UpdateUserPreferences(booker, &model.UserPreferences{}))
+	app.queueReminders(start.Add(-29 * time.Minute))
+	assert.Len(t, queued(model.NotificationReminder), 2)
+	app.queueReminders(start.Add(time.Minute))
+	assert.Len(t, queued(model.NotificationReminder), 2, "nothing once the booking started")
+
+	monday := start.AddDate(0, 0, -1)
+	app.queueDigests(monday)
+	app.queueDigests(monday)
+	digests := queued(model.NotificationDigest)
+	require.Len(t, digests, 2, "one per user with something coming up")
+	for _, digest := range digests {
+		require.Len(t, digest.Items, 1)
+		assert.Equal(t, monday, digest.StartDate)
+		if digest.UserID == owner {
+			assert.Equal(t, offeringId, digest.Items[0].SubjectID)
+			assert.Equal(t, model.NotificationOffering, digest.Items[0].Subject)
+		} else {
+			assert.Equal(t, bookingId, digest.Items[0].SubjectID)
+		}
+	}
+
+	email := new(mockEmail)
+	email.On("SendDigest", mock.Anything).Return(nil)
+	app.email = email
+	require.NoError(t, app.sendNotification(&model.OutboxMessage{Notification: *digests[0], CreatedAt: time.Now()}))
+	params := email.Calls[0].Arguments.Get(0).(*mail.EmailParams)
+	if assert.Len(t, params.Items, 1) {
+		assert.Equal(t, "W-001", params.Items[0].WorkspaceName)
+	}
+}
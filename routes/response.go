@@ -0,0 +1,49 @@
+package routes
+
+import (
+	"encoding/json"
+	"go-api/db"
+	"net/http"
+)
+
+type errorResponse struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// statusByKind is the status code each kind of domain error is reported with
+var statusByKind = map[string]int{
+	db.NotFound:   http.StatusNotFound,
+	db.Conflict:   http.StatusConflict,
+	db.Validation: http.StatusBadRequest,
+	db.Forbidden:  http.StatusForbidden,
+}
+
+func respondError(w http.ResponseWriter, status int, code string, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&errorResponse{Code: code, Message: message})
+}
+
+// respondStoreError reports an error returned by the store. Domain errors are sent with the status of their kind
+// and their own code, message and details; anything else is sent with status and without the error's text
+func respondStoreError(w http.ResponseWriter, err error, status int) {
+	if domainErr := db.AsError(err); domainErr != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusByKind[domainErr.Kind])
+		json.NewEncoder(w).Encode(&errorResponse{
+			Code:    domainErr.Code,
+			Message: domainErr.Message,
+			Details: domainErr.Details,
+		})
+		return
+	}
+	code := "internal_error"
+	for kind, kindStatus := range statusByKind {
+		if kindStatus == status {
+			code = kind
+		}
+	}
+	respondError(w, status, code, http.StatusText(status))
+}
@@ -0,0 +1,133 @@
+package routes
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-api/db"
+	"go-api/db/memory"
+	"go-api/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRespondStoreError(t *testing.T) {
+	seriesErr := db.NewConflictError("no occurrence of the series is available")
+	seriesErr.Details = []*model.SeriesConflict{{Reason: "workspace is not offered", Code: db.Validation}}
+
+	cases := map[string]struct {
+		err      error
+		fallback int
+		status   int
+		body     string
+	}{
+		"not found": {
+			err:      db.NotFoundError,
+			fallback: http.StatusInternalServerError,
+			status:   http.StatusNotFound,
+			body:     `{"code":"not_found","message":"not found"}`,
+		},
+		"missing row": {
+			err:      sql.ErrNoRows,
+			fallback: http.StatusInternalServerError,
+			status:   http.StatusNotFound,
+			body:     `{"code":"not_found","message":"not found"}`,
+		},
+		"wrapped conflict": {
+			err:      fmt.Errorf("creating booking: %w", db.NewConflictError("workspace already booked for this duration")),
+			fallback: http.StatusInternalServerError,
+			status:   http.StatusConflict,
+			body:     `{"code":"conflict","message":"workspace already booked for this duration"}`,
+		},
+		"policy": {
+			err:      db.NewPolicyError(db.PolicyMaxDuration, "bookings can be at most %d hours long", 8),
+			fallback: http.StatusInternalServerError,
+			status:   http.StatusBadRequest,
+			body:     `{"code":"max_duration","message":"bookings can be at most 8 hours long"}`,
+		},
+		"forbidden": {
+			err:      db.NewForbiddenError("cannot remove offerings by default user"),
+			fallback: http.StatusNotFound,
+			status:   http.StatusForbidden,
+			body:     `{"code":"forbidden","message":"cannot remove offerings by default user"}`,
+		},
+		"details": {
+			err:      seriesErr,
+			fallback: http.StatusInternalServerError,
+			status:   http.StatusConflict,
+			body: `{"code":"conflict","message":"no occurrence of the series is available","details":[
+				{"start_time":"0001-01-01T00:00:00Z","end_time":"0001-01-01T00:00:00Z","reason":"workspace is not offered","code":"validation"}]}`,
+		},
+		"other error": {
+			err:      errors.New("pq: invalid input syntax for type uuid"),
+			fallback: http.StatusNotFound,
+			status:   http.StatusNotFound,
+			body:     `{"code":"not_found","message":"Not Found"}`,
+		},
+		"internal error": {
+			err:      errors.New("pq: connection refused"),
+			fallback: http.StatusInternalServerError,
+			status:   http.StatusInternalServerError,
+			body:     `{"code":"internal_error","message":"Internal Server Error"}`,
+		},
+	}
+	for name, c := range cases {
+		rr := httptest.NewRecorder()
+		respondStoreError(rr, c.err, c.fallback)
+		assert.Equal(t, c.status, rr.Code, name)
+		assert.JSONEq(t, c.body, rr.Body.String(), name)
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	store := memory.NewMemoryDataStore()
+	app := &App{store: store}
+	userId := "00000000-0000-4000-a000-0000000000b0"
+	require.NoError(t, store.UserProvider.CreateUser(&model.User{ID: userId, Name: "Bob", Email: "bob@example.com"}))
+	floorId, err := store.FloorProvider.CreateFloor(&model.Floor{Name: "West 2nd Avenue"})
+	require.NoError(t, err)
+	workspaceId, err := store.WorkspaceProvider.CreateAssignWorkspace(&model.Workspace{Name: "W1", Floor: floorId}, "")
+	require.NoError(t, err)
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	bookingId, err := store.BookingProvider.CreateBooking(&model.Booking{WorkspaceID: workspaceId, UserID: userId, CreatedBy: userId, StartDate: start, EndDate: start.Add(time.Hour)})
+	require.NoError(t, err)
+	reversed, _ := json.Marshal(map[string]interface{}{"start_time": start, "end_time": start.Add(-time.Hour)})
+	cases := map[string]struct {
+		config *testRouteConfig
+		status int
+		body   string
+	}{
+		"invalid body": {
+			config: &testRouteConfig{Method: http.MethodPost, URL: "/bookings", Body: strings.NewReader("{"), Handler: app.CreateBooking},
+			status: http.StatusBadRequest,
+			body:   `{"code":"validation","message":"request body is not valid JSON"}`,
+		},
+		"reversed update": {
+			config: &testRouteConfig{Method: http.MethodPatch, URL: "/bookings/" + bookingId, Body: bytes.NewReader(reversed), Handler: app.UpdateBooking, URLParams: map[string]string{"id": bookingId}},
+			status: http.StatusBadRequest,
+			body:   `{"code":"validation","message":"end time must be after start time"}`,
+		},
+		"invalid query": {
+			config: &testRouteConfig{Method: http.MethodGet, URL: "/offerings/series/s1?from=soon", Handler: app.CancelOfferingSeries, URLParams: map[string]string{"id": "s1"}},
+			status: http.StatusBadRequest,
+			body:   `{"code":"validation","message":"from must be a unix timestamp"}`,
+		},
+		"missing series": {
+			config: &testRouteConfig{Method: http.MethodGet, URL: "/bookings/series/s1", Handler: app.GetBookingSeries, URLParams: map[string]string{"id": "s1"}},
+			status: http.StatusNotFound,
+			body:   `{"code":"not_found","message":"not found"}`,
+		},
+	}
+	for name, c := range cases {
+		rr := executeReq(t, c.config)
+		assert.Equal(t, c.status, rr.Code, name)
+		assert.JSONEq(t, c.body, rr.Body.String(), name)
+	}
+}
@@ -0,0 +1,161 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"go-api/auth"
+	"go-api/db"
+	"go-api/mail"
+	"go-api/model"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+)
+
+func (app *App) RegisterTemplateRoutes() {
+	app.router.HandleFunc("/templates", app.authorize(app.GetEmailTemplates, auth.AdminOnly)).Methods("GET")
+	app.router.HandleFunc("/templates/{event}/preview", app.authorize(app.PreviewEmailTemplate, auth.AdminOnly)).Methods("GET", "POST")
+	app.router.HandleFunc("/templates/{event}/{locale}", app.authorize(app.UpsertEmailTemplate, auth.AdminOnly)).Methods("PUT")
+	app.router.HandleFunc("/templates/{event}/{locale}", app.authorize(app.RemoveEmailTemplate, auth.AdminOnly)).Methods("DELETE")
+}
+
+// storedTemplates are the templates written through the API; they override those on disk and the built in ones
+type storedTemplates struct {
+	store *db.DataStore
+}
+
+func (s storedTemplates) Template(event, locale string) (*mail.Template, error) {
+	template, err := s.store.TemplateProvider.GetEmailTemplate(event, locale)
+	if db.IsKind(err, db.NotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &mail.Template{Subject: template.Subject, Text: template.Text, HTML: template.HTML}, nil
+}
+
+// GetEmailTemplates lists the stored templates, not the ones on disk or built in
+func (app *App) GetEmailTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := app.store.TemplateProvider.GetEmailTemplates()
+	if err != nil {
+		log.Printf("App.GetEmailTemplates - error getting templates from provider %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(templates)
+}
+
+// UpsertEmailTemplate stores the template of an event in a locale, which is used from the next email on
+func (app *App) UpsertEmailTemplate(w http.ResponseWriter, r *http.Request) {
+	event, locale := mux.Vars(r)["event"], mux.Vars(r)["locale"]
+	if !mail.IsEvent(event) || !mail.IsLocale(locale) {
+		respondError(w, http.StatusBadRequest, db.Validation, fmt.Sprintf("unknown event %q or locale %q", event, locale))
+		return
+	}
+	template, ok := readTemplate(w, r, "App.UpsertEmailTemplate")
+	if !ok {
+		return
+	}
+	stored := &model.EmailTemplate{Event: event, Locale: locale, Subject: template.Subject, Text: template.Text, HTML: template.HTML}
+	if err := app.store.TemplateProvider.UpsertEmailTemplate(stored); err != nil {
+		log.Printf("App.UpsertEmailTemplate - error storing template %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stored)
+}
+
+// RemoveEmailTemplate removes a stored template, so that the one on disk or the built in one is used again
+func (app *App) RemoveEmailTemplate(w http.ResponseWriter, r *http.Request) {
+	err := app.store.TemplateProvider.RemoveEmailTemplate(mux.Vars(r)["event"], mux.Vars(r)["locale"])
+	if err != nil {
+		log.Printf("App.RemoveEmailTemplate - error removing template %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// PreviewEmailTemplate renders the template of an event against mail.SampleParams, in the locale and time zone
+// given as query parameters. A POST renders the template in its body instead, to try it before storing it
+func (app *App) PreviewEmailTemplate(w http.ResponseWriter, r *http.Request) {
+	event := mux.Vars(r)["event"]
+	if !mail.IsEvent(event) {
+		respondError(w, http.StatusBadRequest, db.Validation, fmt.Sprintf("unknown event %q", event))
+		return
+	}
+	params := mail.SampleParams()
+	params.Locale = r.URL.Query().Get("locale")
+	params.TimeZone = r.URL.Query().Get("time_zone")
+	if err := validatePreferences(params.Locale, params.TimeZone); err != nil {
+		respondError(w, http.StatusBadRequest, db.Validation, err.Error())
+		return
+	}
+	typeS := r.URL.Query().Get("type")
+	if typeS == "" {
+		typeS = previewType(event)
+	}
+
+	var message *mail.Message
+	var err error
+	if r.Method == http.MethodPost {
+		template, ok := readTemplate(w, r, "App.PreviewEmailTemplate")
+		if !ok {
+			return
+		}
+		message, err = app.templates.RenderTemplate(template, typeS, params)
+	} else {
+		message, err = app.templates.Render(event, typeS, params)
+	}
+	if err != nil {
+		log.Printf("App.PreviewEmailTemplate - error rendering %s %v", event, err)
+		respondError(w, http.StatusUnprocessableEntity, db.Validation, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(message)
+}
+
+// readTemplate reads and parses the template in the request body, responding with a 400 if it isn't valid
+func readTemplate(w http.ResponseWriter, r *http.Request, caller string) (*mail.Template, bool) {
+	var template mail.Template
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("%s - error reading request body %v", caller, err)
+		respondError(w, http.StatusBadRequest, db.Validation, "could not read the request body")
+		return nil, false
+	}
+	if err = json.Unmarshal(reqBody, &template); err != nil {
+		log.Printf("%s - error unmarshaling request body %v", caller, err)
+		respondError(w, http.StatusBadRequest, db.Validation, "request body is not valid JSON")
+		return nil, false
+	}
+	if err = mail.Validate(&template); err != nil {
+		respondError(w, http.StatusBadRequest, db.Validation, err.Error())
+		return nil, false
+	}
+	return &template, true
+}
+
+// previewType is what a preview of event is about unless the request says otherwise
+func previewType(event string) string {
+	switch {
+	case strings.HasPrefix(event, "offering"):
+		return mail.Offering
+	case strings.HasPrefix(event, "series"):
+		return mail.BookingSeries
+	case event == mail.WaitlistHeld:
+		return mail.Waitlist
+	case event == mail.Digest:
+		return mail.DigestType
+	}
+	return mail.Booking
+}
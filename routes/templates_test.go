@@ -0,0 +1,97 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-api/db/memory"
+	"go-api/mail"
+	"go-api/model"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestEmailTemplates(t *testing.T) {
+	store := memory.NewMemoryDataStore()
+	templates, err := mail.NewTemplates(&mail.TemplateConfig{AppURL: "https://iwork.example.com"}, storedTemplates{store})
+	require.NoError(t, err)
+	app := &App{store: store, templates: templates}
+
+	preview := func(url string, body string) (int, *mail.Message) {
+		method := http.MethodGet
+		if body != "" {
+			method = http.MethodPost
+		}
+		rr := executeReq(t, &testRouteConfig{
+			Method:    method,
+			URL:       url,
+			Body:      strings.NewReader(body),
+			URLParams: map[string]string{"event": mail.BookingConfirmed},
+			Handler:   app.PreviewEmailTemplate,
+		})
+		var message mail.Message
+		_ = json.Unmarshal(rr.Body.Bytes(), &message)
+		return rr.Code, &message
+	}
+	code, message := preview("/templates/booking_confirmed/preview?locale=fr&time_zone=Europe/Paris", "")
+	assert.Equal(t, http.StatusOK, code, "status code")
+	assert.Equal(t, "Réservation de W-001 à West 2nd Avenue", message.Subject)
+	assert.Contains(t, message.Text, "du 01/03/2021 18:00")
+	code, _ = preview("/templates/booking_confirmed/preview?time_zone=Mars/Olympus", "")
+	assert.Equal(t, http.StatusBadRequest, code, "status code")
+	code, message = preview("/templates/booking_confirmed/preview", `{"subject": "Draft {{.WorkspaceName}}", "text": "-"}`)
+	assert.Equal(t, http.StatusOK, code, "status code")
+	assert.Equal(t, "Draft W-001", message.Subject, "drafts are previewed without storing them")
+
+	upsert := func(locale string, body string) int {
+		return executeReq(t, &testRouteConfig{
+			Method:    http.MethodPut,
+			URL:       "/templates/booking_confirmed/" + locale,
+			Body:      strings.NewReader(body),
+			URLParams: map[string]string{"event": mail.BookingConfirmed, "locale": locale},
+			Handler:   app.UpsertEmailTemplate,
+		}).Code
+	}
+	assert.Equal(t, http.StatusBadRequest, upsert("fr", `{"subject": "{{.WorkspaceName", "text": "-"}`), "templates must parse")
+	assert.Equal(t, http.StatusBadRequest, upsert("../../etc", `{"subject": "-", "text": "-"}`))
+	assert.Equal(t, http.StatusOK, upsert("fr", `{"subject": "Poste {{.WorkspaceName}} réservé", "text": "{{date .Start}}"}`))
+	code, message = preview("/templates/booking_confirmed/preview?locale=fr-CA", "")
+	assert.Equal(t, http.StatusOK, code, "status code")
+	assert.Equal(t, "Poste W-001 réservé", message.Subject, "stored templates override the built in ones")
+
+	rr := executeReq(t, &testRouteConfig{Method: http.MethodGet, URL: "/templates", Handler: app.GetEmailTemplates})
+	var stored []*model.EmailTemplate
+	_ = json.Unmarshal(rr.Body.Bytes(), &stored)
+	assert.Len(t, stored, 1)
+}
+
+func TestUpdateUserPreferences(t *testing.T) {
+	store := memory.NewMemoryDataStore()
+	app := &App{store: store}
+	require.NoError(t, store.UserProvider.CreateUser(&model.User{ID: "00000000-0000-4000-a000-0000000000b0", Name: "Bob"}))
+
+	update := func(body string) int {
+		return executeReq(t, &testRouteConfig{
+			Method:    http.MethodPut,
+			URL:       "/users/00000000-0000-4000-a000-0000000000b0/preferences",
+			Body:      strings.NewReader(body),
+			URLParams: map[string]string{"id": "00000000-0000-4000-a000-0000000000b0"},
+			Handler:   app.UpdateUserPreferences,
+		}).Code
+	}
+	assert.Equal(t, http.StatusBadRequest, update(`{"locale": "fr_CA"}`))
+	assert.Equal(t, http.StatusBadRequest, update(`{"time_zone": "Mars/Olympus"}`))
+	assert.Equal(t, http.StatusOK, update(`{"locale": "fr-CA", "time_zone": "America/Toronto"}`))
+	user, err := store.UserProvider.GetOneUser("00000000-0000-4000-a000-0000000000b0")
+	require.NoError(t, err)
+	assert.Equal(t, "fr-CA", user.Locale)
+	assert.Equal(t, "America/Toronto", user.TimeZone)
+
+	assert.Equal(t, http.StatusOK, update(`{"reminders_opt_out": true}`))
+	user, err = store.UserProvider.GetOneUser("00000000-0000-4000-a000-0000000000b0")
+	require.NoError(t, err)
+	assert.True(t, user.RemindersOptOut)
+	assert.False(t, user.DigestOptOut)
+	assert.Equal(t, "fr-CA", user.Locale, "preferences left out keep their values")
+}
@@ -3,23 +3,30 @@ package routes
 import (
 	"encoding/csv"
 	"encoding/json"
+	"fmt"
 	"github.com/gorilla/mux"
+	"go-api/auth"
+	"go-api/db"
+	"go-api/mail"
 	"go-api/model"
 	"go-api/utils"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func (app *App) RegisterUserRoutes() {
 	app.router.HandleFunc("/users/assigned", app.GetAllAssignedUsers).Methods("GET").Queries("start", "{start:[0-9]+}").Queries("end", "{end:[0-9]+}")
 	app.router.HandleFunc("/users/assigned", app.GetAssignedUsersByTime).Methods("GET").Queries("now", "{now:[0-9]+}")
-	app.router.HandleFunc("/users", app.CreateUsers).Methods("POST")
+	app.router.HandleFunc("/users", app.authorize(app.CreateUsers, auth.AdminOnly)).Methods("POST")
 	app.router.HandleFunc("/users/{id}", app.GetOneUser).Methods("GET")
 	//app.router.HandleFunc("/users/workspaces/{workspace_id}", app.GetUsersByWorkspaceID).Methods("GET")
 	app.router.HandleFunc("/users", app.GetAllUsers).Methods("GET")
+	app.router.HandleFunc("/users/{id}/preferences", app.authorize(app.UpdateUserPreferences, auth.OwnerOrAdmin("id", userOwners))).Methods("PUT")
 	//app.router.HandleFunc("/users/{id}", app.UpdateUser).Methods("PATCH")
 	//app.router.HandleFunc("/users/{id}", app.RemoveUser).Methods("DELETE")
 }
@@ -29,20 +36,20 @@ func (app *App) CreateUsers(w http.ResponseWriter, r *http.Request) {
 	parseErr := r.ParseMultipartForm(MaxFileSize)
 	if parseErr != nil {
 		log.Println("App.CreateUsers - failed to parse message")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "expecting a multipart form of at most 6MB")
 		return
 	}
 
 	if r.MultipartForm == nil || r.MultipartForm.File == nil {
 		log.Println("App.CreateUsers - expecting multipart form file")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "expecting a multipart form file")
 		return
 	}
 
 	usersFile, _, err := r.FormFile("users")
 	if err != nil {
 		log.Println("App.CreateUsers - users file is absent: " + err.Error())
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "users file is required")
 		return
 	}
 	csvFile := csv.NewReader(usersFile) // email, name, id, department, isAdmin
@@ -56,7 +63,7 @@ func (app *App) CreateUsers(w http.ResponseWriter, r *http.Request) {
 		}
 		if err != nil || len(record) != 5 {
 			log.Println("App.CreateUsers - failed to parse csv file")
-			w.WriteHeader(http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, db.Validation, "every row of the users file needs email, name, id, department and is_admin")
 			return
 		}
 		b, err := strconv.ParseBool(record[4])
@@ -91,25 +98,85 @@ func (app *App) GetOneUser(w http.ResponseWriter, r *http.Request) {
 
 	if userID == "" {
 		log.Printf("App.GetOneUser - empty user id")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "user id is required")
 		return
 	}
 
 	user, err := app.store.UserProvider.GetOneUser(userID)
 	if err != nil {
 		log.Printf("App.GetOneUser - error getting user from provider %v", err)
-		w.WriteHeader(http.StatusNotFound)
+		respondStoreError(w, err, http.StatusNotFound)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(user)
 }
 
+// userOwners makes users the owners of themselves, for auth.OwnerOrAdmin
+func userOwners(id string) ([]string, error) {
+	return []string{id}, nil
+}
+
+// UpdateUserPreferences sets the locale and time zone of the user's emails, where empty values mean the app's
+// defaults, and which reminders they get. Preferences left out of the body keep their values
+func (app *App) UpdateUserPreferences(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	user, err := app.store.UserProvider.GetOneUser(id)
+	if err != nil {
+		log.Printf("App.UpdateUserPreferences - error getting user from provider %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	preferences := user.Preferences()
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("App.UpdateUserPreferences - error reading request body %v", err)
+		respondError(w, http.StatusBadRequest, db.Validation, "could not read the request body")
+		return
+	}
+	if err = json.Unmarshal(reqBody, preferences); err != nil {
+		log.Printf("App.UpdateUserPreferences - error unmarshaling request body %v", err)
+		respondError(w, http.StatusBadRequest, db.Validation, "request body is not valid JSON")
+		return
+	}
+	if err = validatePreferences(preferences.Locale, preferences.TimeZone); err != nil {
+		respondError(w, http.StatusBadRequest, db.Validation, err.Error())
+		return
+	}
+	if err = app.store.UserProvider.UpdateUserPreferences(id, preferences); err != nil {
+		log.Printf("App.UpdateUserPreferences - error updating user %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	user, err = app.store.UserProvider.GetOneUser(id)
+	if err != nil {
+		log.Printf("App.UpdateUserPreferences - error getting user from provider %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
+
+// validatePreferences checks a locale and an IANA time zone such as "America/Toronto"; either can be empty
+func validatePreferences(locale, timeZone string) error {
+	if locale != "" && !mail.IsLocale(locale) {
+		return fmt.Errorf("invalid locale %q", locale)
+	}
+	if timeZone != "" {
+		if _, err := time.LoadLocation(timeZone); err != nil {
+			return fmt.Errorf("unknown time zone %q", timeZone)
+		}
+	}
+	return nil
+}
+
 func (app *App) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 	users, err := app.store.UserProvider.GetAllUsers()
 	if err != nil {
 		log.Printf("App.GetAllUsers - error getting all users from provider %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondStoreError(w, err, http.StatusInternalServerError)
 		return
 	}
 	json.NewEncoder(w).Encode(users)
@@ -123,18 +190,18 @@ func (app *App) GetAllAssignedUsers(w http.ResponseWriter, r *http.Request) {
 	endTime, errEnd := utils.TimeStampToTime(end)
 	if errStart != nil {
 		log.Printf("App.GetAllAssignedUsers - empty start time param: %v", errStart)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "start must be a unix timestamp")
 		return
 	}
 	if errEnd != nil {
 		log.Printf("App.GetAllAssignedUsers - empty end time param: %v", errEnd)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "end must be a unix timestamp")
 		return
 	}
 	users, err := app.store.UserProvider.GetAssignedUsers(startTime, endTime)
 	if err != nil {
 		log.Printf("App.GetAllAssignedUsers - error getting all users from provider %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondStoreError(w, err, http.StatusInternalServerError)
 		return
 	}
 	json.NewEncoder(w).Encode(users)
@@ -146,13 +213,13 @@ func (app *App) GetAssignedUsersByTime(w http.ResponseWriter, r *http.Request) {
 	timestampTime, err := utils.TimeStampToTime(ts) // Unix Timestamp
 	if err != nil {
 		log.Printf("App.GetAssignedUsersAtTime - empty start time param: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "start must be a unix timestamp")
 		return
 	}
 	users, err := app.store.UserProvider.GetAssignedUsersByTime(timestampTime)
 	if err != nil {
 		log.Printf("App.GetAssignedUsersAtTime - error getting all users from provider %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondStoreError(w, err, http.StatusInternalServerError)
 		return
 	}
 	json.NewEncoder(w).Encode(users)
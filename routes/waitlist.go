@@ -0,0 +1,189 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"go-api/auth"
+	"go-api/db"
+	"go-api/model"
+	"go-api/notify"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultWaitlistHold is how long a freed workspace is held for a waitlisted user when AppConfig.WaitlistHold is not set
+	DefaultWaitlistHold = 30 * time.Minute
+	// holdSweepInterval is how often holds nobody claimed in time are passed on
+	holdSweepInterval = time.Minute
+)
+
+func (app *App) RegisterWaitlistRoutes() {
+	app.router.HandleFunc("/waitlist", app.authorize(app.CreateWaitlistEntry, auth.BodyOwnerOrAdmin("user_id"))).Methods("POST")
+	app.router.HandleFunc("/waitlist/users/{user_id}", app.authorize(app.GetWaitlistByUserID, auth.SelfOrAdmin("user_id"))).Methods("GET")
+	app.router.HandleFunc("/waitlist/{id}", app.authorize(app.CancelWaitlistEntry, auth.OwnerOrAdmin("id", app.waitlistOwners))).Methods("DELETE")
+	app.router.HandleFunc("/waitlist/{id}/claim", app.authorize(app.ClaimWaitlistHold, auth.OwnerOrAdmin("id", app.waitlistOwners))).Methods("POST")
+}
+
+func (app *App) waitlistOwners(id string) ([]string, error) {
+	entry, err := app.store.WaitlistProvider.GetOneWaitlistEntry(id)
+	if err != nil {
+		return nil, err
+	}
+	return []string{entry.UserID}, nil
+}
+
+func (app *App) CreateWaitlistEntry(w http.ResponseWriter, r *http.Request) {
+	var entry model.WaitlistEntry
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("App.CreateWaitlistEntry - error reading request body %v", err)
+		respondError(w, http.StatusBadRequest, db.Validation, "could not read the request body")
+		return
+	}
+	err = json.Unmarshal(reqBody, &entry)
+	if err != nil {
+		log.Printf("App.CreateWaitlistEntry - error unmarshaling request body %v", err)
+		respondError(w, http.StatusBadRequest, db.Validation, "request body is not valid JSON")
+		return
+	}
+	if entry.UserID == "" || entry.FloorID == "" || !entry.EndDate.After(entry.StartDate) {
+		log.Printf("App.CreateWaitlistEntry - user, floor and a time range are required")
+		respondError(w, http.StatusBadRequest, db.Validation, "user, floor and a time range are required")
+		return
+	}
+	id, err := app.store.WaitlistProvider.CreateWaitlistEntry(&entry)
+	if err != nil {
+		log.Printf("App.CreateWaitlistEntry - error creating waitlist entry %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	created, err := app.store.WaitlistProvider.GetOneWaitlistEntry(id)
+	if err != nil {
+		log.Printf("App.CreateWaitlistEntry - error getting waitlist entry %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func (app *App) GetWaitlistByUserID(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["user_id"]
+	if userID == "" {
+		log.Printf("App.GetWaitlistByUserID - empty user id")
+		respondError(w, http.StatusBadRequest, db.Validation, "user id is required")
+		return
+	}
+	entries, err := app.store.WaitlistProvider.GetWaitlistByUserID(userID)
+	if err != nil {
+		log.Printf("App.GetWaitlistByUserID - error getting waitlist from provider %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(entries)
+}
+
+func (app *App) CancelWaitlistEntry(w http.ResponseWriter, r *http.Request) {
+	entryID := mux.Vars(r)["id"]
+	if entryID == "" {
+		log.Printf("App.CancelWaitlistEntry - empty waitlist id")
+		respondError(w, http.StatusBadRequest, db.Validation, "waitlist id is required")
+		return
+	}
+	entry, err := app.store.WaitlistProvider.CancelWaitlistEntry(entryID)
+	if err != nil {
+		log.Printf("App.CancelWaitlistEntry - error cancelling waitlist entry %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+
+	// Give up the hold to whoever is next
+	if entry.Status == model.WaitlistHeld {
+		app.processWaitlist(entry.HoldWorkspaceID, entry.StartDate, entry.EndDate)
+	}
+}
+
+func (app *App) ClaimWaitlistHold(w http.ResponseWriter, r *http.Request) {
+	entryID := mux.Vars(r)["id"]
+	if entryID == "" {
+		log.Printf("App.ClaimWaitlistHold - empty waitlist id")
+		respondError(w, http.StatusBadRequest, db.Validation, "waitlist id is required")
+		return
+	}
+	entry, err := app.store.WaitlistProvider.GetOneWaitlistEntry(entryID)
+	if err != nil {
+		log.Printf("App.ClaimWaitlistHold - error getting waitlist entry %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	bookingID, err := app.store.WaitlistProvider.ClaimWaitlistHold(entryID)
+	if err != nil {
+		log.Printf("App.ClaimWaitlistHold - error claiming hold %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		if err == db.HoldExpiredError {
+			app.processWaitlist(entry.HoldWorkspaceID, entry.StartDate, entry.EndDate)
+		}
+		return
+	}
+	booking, err := app.store.BookingProvider.GetOneBooking(bookingID)
+	if err != nil {
+		log.Printf("App.ClaimWaitlistHold - error getting booking %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	app.publishBooking(notify.BookingCreated, bookingID)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(booking)
+}
+
+// processWaitlist hands a workspace that became free between start and end to waitlisted users, one entry at a
+// time, until nobody else on the waitlist can use it. Errors are logged since the caller's own change already succeeded
+func (app *App) processWaitlist(workspaceID string, start, end time.Time) {
+	hold := app.waitlistHold
+	if hold == 0 {
+		hold = DefaultWaitlistHold
+	}
+	for {
+		entry, err := app.store.WaitlistProvider.ProcessWaitlist(workspaceID, start, end, time.Now().Add(hold))
+		if err != nil {
+			log.Printf("App.processWaitlist - error processing waitlist for workspace %s: %v", workspaceID, err)
+			return
+		}
+		if entry == nil {
+			return
+		}
+		if entry.Status == model.WaitlistBooked {
+			app.publishBooking(notify.BookingCreated, entry.BookingID)
+		}
+	}
+}
+
+// StartHoldSweeper passes the holds nobody claimed in time on to whoever is next on the waitlist until the app stops
+func (app *App) StartHoldSweeper() {
+	go func() {
+		ticker := time.NewTicker(holdSweepInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			app.expireWaitlistHolds(now)
+		}
+	}()
+}
+
+// expireWaitlistHolds expires the holds that ran out by now and offers the workspace each of them held to the
+// waitlist again
+func (app *App) expireWaitlistHolds(now time.Time) {
+	expired, err := app.store.WaitlistProvider.ExpireWaitlistHolds(now)
+	if err != nil {
+		log.Printf("App.expireWaitlistHolds - error expiring holds %v", err)
+		return
+	}
+	for _, entry := range expired {
+		app.processWaitlist(entry.HoldWorkspaceID, entry.StartDate, entry.EndDate)
+	}
+}
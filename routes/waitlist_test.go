@@ -0,0 +1,190 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-api/db/memory"
+	"go-api/mail"
+	"go-api/model"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const (
+	barryID = "e99a988a-1d41-3997-8d59-959a48ac24a0"
+	bruceID = "8b5bb736-6a1d-3378-8e71-ab45fe8beb84"
+	clarkID = "32ea2fb1-7124-304a-b9c3-eb445578103e"
+)
+
+func (suite *AppTestSuite) joinWaitlist(userID string, autoBook bool) *model.WaitlistEntry {
+	t := suite.T()
+	requestBody, _ := json.Marshal(map[string]interface{}{
+		"user_id":    userID,
+		"floor_id":   MainFloor.ID,
+		"start_time": date("2019-01-23T10:00:00Z"),
+		"end_time":   date("2019-01-23T12:00:00Z"),
+		"auto_book":  autoBook,
+	})
+	rr := executeReq(t, &testRouteConfig{
+		Method:  http.MethodPost,
+		Body:    bytes.NewBuffer(requestBody),
+		Handler: suite.app.CreateWaitlistEntry,
+		URL:     "/waitlist",
+	})
+	assert.Equal(t, http.StatusCreated, rr.Code, "status code")
+	var entry model.WaitlistEntry
+	_ = json.Unmarshal(rr.Body.Bytes(), &entry)
+	assert.Equal(t, model.WaitlistWaiting, entry.Status)
+	return &entry
+}
+
+func (suite *AppTestSuite) waitlistOf(userID string) []*model.WaitlistEntry {
+	rr := executeReq(suite.T(), &testRouteConfig{
+		Method:    http.MethodGet,
+		Handler:   suite.app.GetWaitlistByUserID,
+		URL:       fmt.Sprintf("/waitlist/users/%s", userID),
+		URLParams: map[string]string{"user_id": userID},
+	})
+	var entries []*model.WaitlistEntry
+	_ = json.Unmarshal(rr.Body.Bytes(), &entries)
+	return entries
+}
+
+func (suite *AppTestSuite) Test_Waitlist() {
+	t := suite.T()
+	mockEmail := new(mockEmail)
+	mockEmail.On("SendHold", mail.Waitlist).Return(nil)
+	mockEmail.On("SendConfirmation", mail.Booking).Return(nil)
+	mockEmail.On("SendCancellation", mail.Booking).Return(nil)
+	suite.app.email = mockEmail
+
+	// Workspace 5 is offered on the 23rd; barry takes it
+	slot := &model.Booking{
+		UserID:      barryID,
+		WorkspaceID: Offering5.WorkspaceID,
+		StartDate:   date("2019-01-23T10:00:00Z"),
+		EndDate:     date("2019-01-23T12:00:00Z"),
+		CreatedBy:   barryID,
+	}
+	barrysBooking, err := suite.app.store.BookingProvider.CreateBooking(slot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bruce := suite.joinWaitlist(bruceID, false)
+	clark := suite.joinWaitlist(clarkID, true)
+
+	// Barry cancels: bruce joined first and gets a hold
+	rr := executeReq(t, &testRouteConfig{
+		Method:    http.MethodDelete,
+		Handler:   suite.app.RemoveBooking,
+		URL:       fmt.Sprintf("/bookings/%s", barrysBooking),
+		URLParams: map[string]string{"id": barrysBooking},
+	})
+	assert.Equal(t, http.StatusOK, rr.Code, "status code")
+	entries := suite.waitlistOf(bruceID)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, model.WaitlistHeld, entries[0].Status)
+		assert.Equal(t, Offering5.WorkspaceID, entries[0].HoldWorkspaceID)
+		assert.NotNil(t, entries[0].HoldExpiresAt)
+		assert.NotNil(t, queuedNotification(t, suite.app.store, model.NotificationHold, entries[0].ID), "hold queued")
+	}
+
+	// Nobody else can book the held workspace
+	_, err = suite.app.store.BookingProvider.CreateBooking(slot)
+	assert.Error(t, err, "held workspace")
+
+	// Bruce gives up his hold, clark is next and gets booked straight away
+	rr = executeReq(t, &testRouteConfig{
+		Method:    http.MethodDelete,
+		Handler:   suite.app.CancelWaitlistEntry,
+		URL:       fmt.Sprintf("/waitlist/%s", bruce.ID),
+		URLParams: map[string]string{"id": bruce.ID},
+	})
+	assert.Equal(t, http.StatusOK, rr.Code, "status code")
+	entries = suite.waitlistOf(clarkID)
+	if !assert.Len(t, entries, 1) || !assert.Equal(t, model.WaitlistBooked, entries[0].Status) {
+		return
+	}
+	clarksBooking, err := suite.app.store.BookingProvider.GetOneBooking(entries[0].BookingID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, clarkID, clarksBooking.UserID)
+		assert.Equal(t, Offering5.WorkspaceID, clarksBooking.WorkspaceID)
+	}
+	assert.NoError(t, suite.app.store.BookingProvider.RemoveBooking(clarksBooking.ID))
+
+	rr = executeReq(t, &testRouteConfig{
+		Method:    http.MethodDelete,
+		Handler:   suite.app.CancelWaitlistEntry,
+		URL:       fmt.Sprintf("/waitlist/%s", clark.ID),
+		URLParams: map[string]string{"id": clark.ID},
+	})
+	assert.Equal(t, http.StatusConflict, rr.Code, "booked entries can't be cancelled")
+
+	// Bruce tries again and claims the hold himself
+	bruce = suite.joinWaitlist(bruceID, false)
+	suite.app.processWaitlist(Offering5.WorkspaceID, slot.StartDate, slot.EndDate)
+	rr = executeReq(t, &testRouteConfig{
+		Method:    http.MethodPost,
+		Handler:   suite.app.ClaimWaitlistHold,
+		URL:       fmt.Sprintf("/waitlist/%s/claim", bruce.ID),
+		URLParams: map[string]string{"id": bruce.ID},
+	})
+	assert.Equal(t, http.StatusCreated, rr.Code, "status code")
+	var claimed model.Booking
+	_ = json.Unmarshal(rr.Body.Bytes(), &claimed)
+	assert.Equal(t, bruceID, claimed.UserID)
+	assert.NoError(t, suite.app.store.BookingProvider.RemoveBooking(claimed.ID))
+
+	rr = executeReq(t, &testRouteConfig{
+		Method:    http.MethodPost,
+		Handler:   suite.app.ClaimWaitlistHold,
+		URL:       fmt.Sprintf("/waitlist/%s/claim", bruce.ID),
+		URLParams: map[string]string{"id": bruce.ID},
+	})
+	assert.Equal(t, http.StatusConflict, rr.Code, "already claimed")
+}
+
+func TestExpireWaitlistHolds(t *testing.T) {
+	store := memory.NewMemoryDataStore()
+	mockEmail := new(mockEmail)
+	mockEmail.On("SendHold", mail.Waitlist).Return(nil)
+	app := &App{store: store, email: mockEmail}
+	floorID, err := store.FloorProvider.CreateFloor(&model.Floor{Name: "West 2nd Avenue"})
+	require.NoError(t, err)
+	workspaceID, err := store.WorkspaceProvider.CreateWorkspace(&model.Workspace{Name: "W-001", Floor: floorID, Props: model.Attrs{}})
+	require.NoError(t, err)
+	now := time.Now()
+	_, err = store.OfferingProvider.CreateDefaultOffering(&model.Offering{WorkspaceID: workspaceID, StartDate: now})
+	require.NoError(t, err)
+	start, end := now.Add(24*time.Hour), now.Add(32*time.Hour)
+	var entries []string
+	for _, user := range []*model.User{{ID: barryID, Name: "Barry"}, {ID: bruceID, Name: "Bruce"}} {
+		require.NoError(t, store.UserProvider.CreateUser(user))
+		id, err := store.WaitlistProvider.CreateWaitlistEntry(&model.WaitlistEntry{
+			UserID: user.ID, FloorID: floorID, StartDate: start, EndDate: end,
+		})
+		require.NoError(t, err)
+		entries = append(entries, id)
+	}
+	held, err := store.WaitlistProvider.ProcessWaitlist(workspaceID, start, end, now.Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, entries[0], held.ID)
+
+	app.expireWaitlistHolds(now)
+	app.deliverOutbox(time.Now())
+	mockEmail.AssertNumberOfCalls(t, "SendHold", 1)
+	app.expireWaitlistHolds(now.Add(time.Minute))
+	first, err := store.WaitlistProvider.GetOneWaitlistEntry(entries[0])
+	require.NoError(t, err)
+	assert.Equal(t, model.WaitlistExpired, first.Status)
+	next, err := store.WaitlistProvider.GetOneWaitlistEntry(entries[1])
+	require.NoError(t, err)
+	assert.Equal(t, model.WaitlistHeld, next.Status, "the hold is passed on without anything being cancelled")
+	assert.Equal(t, workspaceID, next.HoldWorkspaceID)
+	app.deliverOutbox(time.Now())
+	mockEmail.AssertNumberOfCalls(t, "SendHold", 2)
+}
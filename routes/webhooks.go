@@ -0,0 +1,341 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"go-api/auth"
+	"go-api/db"
+	"go-api/mail"
+	"go-api/model"
+	"go-api/notify"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// DefaultWebhookMaxAttempts is how many times a delivery is tried, with the backoff of the outbox, before it is
+	// dead-lettered; the last retry is about 14 hours after the first attempt
+	DefaultWebhookMaxAttempts = 12
+	// DefaultWebhookDisableAfter is how long the deliveries to a webhook keep failing before it is disabled
+	DefaultWebhookDisableAfter = 24 * time.Hour
+	// webhookBatch is how many deliveries the webhook worker claims at a time
+	webhookBatch = 50
+)
+
+func (app *App) RegisterWebhookRoutes() {
+	app.router.HandleFunc("/webhooks", app.authorize(app.GetWebhooks, auth.AdminOnly)).Methods("GET")
+	app.router.HandleFunc("/webhooks", app.authorize(app.CreateWebhook, auth.AdminOnly)).Methods("POST")
+	app.router.HandleFunc("/webhooks/{id}", app.authorize(app.GetOneWebhook, auth.AdminOnly)).Methods("GET")
+	app.router.HandleFunc("/webhooks/{id}", app.authorize(app.UpdateWebhook, auth.AdminOnly)).Methods("PATCH")
+	app.router.HandleFunc("/webhooks/{id}", app.authorize(app.RemoveWebhook, auth.AdminOnly)).Methods("DELETE")
+	app.router.HandleFunc("/webhooks/{id}/deliveries", app.authorize(app.GetWebhookDeliveries, auth.AdminOnly)).Methods("GET")
+}
+
+// GetWebhooks lists the webhooks, without their secrets
+func (app *App) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := app.store.WebhookProvider.GetWebhooks()
+	if err != nil {
+		log.Printf("App.GetWebhooks - error getting webhooks from provider %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	for _, webhook := range webhooks {
+		webhook.Secret = ""
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(webhooks)
+}
+
+// CreateWebhook subscribes a URL to the events it picks, or every event, optionally only those about one floor
+func (app *App) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var input model.Webhook
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("App.CreateWebhook - error reading request body %v", err)
+		respondError(w, http.StatusBadRequest, db.Validation, "could not read the request body")
+		return
+	}
+	if err = json.Unmarshal(reqBody, &input); err != nil {
+		log.Printf("App.CreateWebhook - error unmarshaling request body %v", err)
+		respondError(w, http.StatusBadRequest, db.Validation, "request body is not valid JSON")
+		return
+	}
+	webhook := &model.Webhook{URL: input.URL, Secret: input.Secret, Events: input.Events, FloorID: input.FloorID, Active: true}
+	if err = validateWebhook(webhook); err != nil {
+		respondError(w, http.StatusBadRequest, db.Validation, err.Error())
+		return
+	}
+	if _, err = app.store.WebhookProvider.CreateWebhook(webhook); err != nil {
+		log.Printf("App.CreateWebhook - error creating webhook %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	webhook.Secret = ""
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+func (app *App) GetOneWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, err := app.store.WebhookProvider.GetOneWebhook(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("App.GetOneWebhook - error getting webhook from provider %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	webhook.Secret = ""
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// UpdateWebhook changes the url, secret, events or floor of a webhook, or disables it or makes it active again.
+// Nothing is queued for a disabled webhook; the deliveries it had pending are sent once it is active again
+func (app *App) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var input model.UpdateWebhookInput
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("App.UpdateWebhook - error reading request body %v", err)
+		respondError(w, http.StatusBadRequest, db.Validation, "could not read the request body")
+		return
+	}
+	if err = json.Unmarshal(reqBody, &input); err != nil {
+		log.Printf("App.UpdateWebhook - error unmarshaling request body %v", err)
+		respondError(w, http.StatusBadRequest, db.Validation, "request body is not valid JSON")
+		return
+	}
+	webhook, err := app.store.WebhookProvider.GetOneWebhook(id)
+	if err != nil {
+		log.Printf("App.UpdateWebhook - error getting webhook from provider %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	if input.URL != nil {
+		webhook.URL = *input.URL
+	}
+	if input.Secret != nil {
+		webhook.Secret = *input.Secret
+	}
+	if input.Events != nil {
+		webhook.Events = *input.Events
+	}
+	if input.FloorID != nil {
+		webhook.FloorID = *input.FloorID
+	}
+	if input.Active != nil && *input.Active != webhook.Active {
+		webhook.Active = *input.Active
+		if webhook.Active {
+			webhook.FailingSince, webhook.DisabledAt = nil, nil
+		} else {
+			now := time.Now()
+			webhook.DisabledAt = &now
+		}
+	}
+	if err = validateWebhook(webhook); err != nil {
+		respondError(w, http.StatusBadRequest, db.Validation, err.Error())
+		return
+	}
+	if err = app.store.WebhookProvider.UpdateWebhook(id, webhook); err != nil {
+		log.Printf("App.UpdateWebhook - error updating webhook %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	webhook.Secret = ""
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// RemoveWebhook unsubscribes a webhook; the deliveries queued for it are dropped
+func (app *App) RemoveWebhook(w http.ResponseWriter, r *http.Request) {
+	if err := app.store.WebhookProvider.RemoveWebhook(mux.Vars(r)["id"]); err != nil {
+		log.Printf("App.RemoveWebhook - error removing webhook %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetWebhookDeliveries is the delivery log of a webhook, newest first, optionally only the deliveries with the given
+// status
+func (app *App) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", model.OutboxPending, model.OutboxDelivered, model.OutboxDead:
+	default:
+		respondError(w, http.StatusBadRequest, db.Validation, fmt.Sprintf("unknown status %q", status))
+		return
+	}
+	if _, err := app.store.WebhookProvider.GetOneWebhook(id); err != nil {
+		log.Printf("App.GetWebhookDeliveries - error getting webhook from provider %v", err)
+		respondStoreError(w, err, http.StatusNotFound)
+		return
+	}
+	deliveries, err := app.store.WebhookProvider.GetWebhookDeliveries(id, status)
+	if err != nil {
+		log.Printf("App.GetWebhookDeliveries - error getting deliveries from provider %v", err)
+		respondStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// validateWebhook checks the url, secret and events of a webhook
+func validateWebhook(webhook *model.Webhook) error {
+	u, err := url.Parse(webhook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %q", webhook.URL)
+	}
+	if webhook.Secret == "" {
+		return fmt.Errorf("webhooks need a secret")
+	}
+	for _, event := range webhook.Events {
+		if !notify.IsMutation(event) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	return nil
+}
+
+// publish queues the payload of event for the webhooks that want it, for StartWebhooks to deliver. Errors are
+// logged since the change the event is about already succeeded
+func (app *App) publish(event *notify.Event) {
+	body, err := json.Marshal(notify.NewPayload(event))
+	if err == nil {
+		_, err = app.store.WebhookProvider.QueueWebhookDeliveries(event.Type, event.FloorID, body)
+	}
+	if err != nil {
+		log.Printf("App.publish - error queueing %s of %s %v", event.Type, event.ID, err)
+	}
+}
+
+// publishBooking publishes the event of the given type about the booking with bookingID
+func (app *App) publishBooking(eventType, bookingID string) {
+	event, err := app.bookingEvent(eventType, bookingID)
+	if err != nil {
+		log.Printf("App.publishBooking - error building %s of %s %v", eventType, bookingID, err)
+		return
+	}
+	app.publish(event)
+}
+
+// publishOffering publishes the event of the given type about the offering with offeringID
+func (app *App) publishOffering(eventType, offeringID string) {
+	event, err := app.offeringEvent(eventType, offeringID)
+	if err != nil {
+		log.Printf("App.publishOffering - error building %s of %s %v", eventType, offeringID, err)
+		return
+	}
+	app.publish(event)
+}
+
+// workspaceEvent is the event of the given type about the workspace with workspaceID
+func (app *App) workspaceEvent(eventType, workspaceID string) (*notify.Event, error) {
+	workspace, err := app.store.WorkspaceProvider.GetOneWorkspace(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	floor, err := app.store.FloorProvider.GetOneFloor(workspace.Floor)
+	if err != nil {
+		return nil, err
+	}
+	return &notify.Event{
+		Type:    eventType,
+		ID:      workspace.ID,
+		Time:    time.Now(),
+		FloorID: floor.ID,
+		Params:  &mail.EmailParams{WorkspaceName: workspace.Name, FloorName: floor.Name, FloorAddress: floor.Address},
+	}, nil
+}
+
+// publishWorkspace publishes the event of the given type about the workspace with workspaceID
+func (app *App) publishWorkspace(eventType, workspaceID string) {
+	event, err := app.workspaceEvent(eventType, workspaceID)
+	if err != nil {
+		log.Printf("App.publishWorkspace - error building %s of %s %v", eventType, workspaceID, err)
+		return
+	}
+	app.publish(event)
+}
+
+func (app *App) maxWebhookAttempts() int {
+	if app.webhookMaxAttempts == 0 {
+		return DefaultWebhookMaxAttempts
+	}
+	return app.webhookMaxAttempts
+}
+
+func (app *App) webhookFailureLimit() time.Duration {
+	if app.webhookDisableAfter == 0 {
+		return DefaultWebhookDisableAfter
+	}
+	return app.webhookDisableAfter
+}
+
+// StartWebhooks delivers the events queued for webhooks until the app stops
+func (app *App) StartWebhooks() {
+	go func() {
+		ticker := time.NewTicker(deliveryInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			app.deliverWebhooks(now)
+		}
+	}()
+}
+
+// deliverWebhooks sends the deliveries due at now; failed ones are retried as recordAttempt says. A webhook whose
+// deliveries have been failing for longer than webhookFailureLimit is disabled, and its deliveries wait until an admin
+// makes it active again
+func (app *App) deliverWebhooks(now time.Time) {
+	if err := app.store.WebhookProvider.DeleteWebhookDeliveriesBefore(now.Add(-deliveryRetention)); err != nil {
+		log.Printf("App.deliverWebhooks - error deleting old deliveries %v", err)
+	}
+	deliveries, err := app.store.WebhookProvider.ClaimWebhookDeliveries(now, webhookBatch, deliveryLease)
+	if err != nil {
+		log.Printf("App.deliverWebhooks - error claiming deliveries %v", err)
+		return
+	}
+	webhooks := make(map[string]*model.Webhook)
+	for _, delivery := range deliveries {
+		webhook, ok := webhooks[delivery.WebhookID]
+		if !ok {
+			// a webhook that can't be read is tried again once the lease of its deliveries is up
+			if webhook, err = app.store.WebhookProvider.GetOneWebhook(delivery.WebhookID); err != nil {
+				log.Printf("App.deliverWebhooks - error getting webhook %s %v", delivery.WebhookID, err)
+				continue
+			}
+			webhooks[delivery.WebhookID] = webhook
+		}
+		if !webhook.Active {
+			continue
+		}
+		failed := func(id string, attempt *model.DeliveryAttempt) error {
+			disabled, err := app.store.WebhookProvider.MarkWebhookFailed(id, attempt, attempt.At.Add(-app.webhookFailureLimit()))
+			if disabled {
+				log.Printf("App.deliverWebhooks - disabled webhook %s, it has been failing for over %v", webhook.ID, app.webhookFailureLimit())
+				webhook.Active = false
+			}
+			return err
+		}
+		app.recordAttempt("deliverWebhooks", &delivery.Delivery, app.sendWebhookDelivery(webhook, delivery),
+			app.store.WebhookProvider.MarkWebhookDelivered, failed)
+	}
+}
+
+// sendWebhookDelivery posts a delivery to its webhook and returns the outcome of the attempt
+func (app *App) sendWebhookDelivery(webhook *model.Webhook, delivery *model.WebhookDelivery) *model.DeliveryAttempt {
+	req, err := notify.NewRequest(webhook.URL, webhook.Secret, delivery.Event, delivery.Payload)
+	if err == nil {
+		req.Header.Set(notify.DeliveryHeader, delivery.ID)
+	}
+	return app.postDelivery(req, err)
+}
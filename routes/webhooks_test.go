@@ -0,0 +1,205 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-api/db/memory"
+	"go-api/model"
+	"go-api/notify"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhooks(t *testing.T) {
+	store := memory.NewMemoryDataStore()
+	app := &App{store: store, webhookDisableAfter: time.Nanosecond}
+	owner, booker := "00000000-0000-4000-a000-0000000000a0", "00000000-0000-4000-a000-0000000000b0"
+	require.NoError(t, store.UserProvider.CreateUser(&model.User{ID: owner, Name: "Alice", Email: "alice@example.com"}))
+	require.NoError(t, store.UserProvider.CreateUser(&model.User{ID: booker, Name: "Bob", Email: "bob@example.com"}))
+	floorId, err := store.FloorProvider.CreateFloor(&model.Floor{Name: "West 2nd Avenue"})
+	require.NoError(t, err)
+	otherFloorId, err := store.FloorProvider.CreateFloor(&model.Floor{Name: "Main Street"})
+	require.NoError(t, err)
+	workspaceId, err := store.WorkspaceProvider.CreateAssignWorkspace(&model.Workspace{Name: "W-001", Floor: floorId}, owner)
+	require.NoError(t, err)
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	_, err = store.OfferingProvider.CreateOffering(&model.Offering{
+		UserID: owner, WorkspaceID: workspaceId, StartDate: start, EndDate: start.AddDate(0, 0, 3), CreatedBy: owner,
+	})
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	status := http.StatusOK
+	requests := make(chan *http.Request, 10)
+	bodies := make(chan []byte, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+		mu.Lock()
+		defer mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	// the stand-in is on loopback, which notify.Client refuses to connect to
+	app.client = server.Client()
+	respond := func(code int) {
+		mu.Lock()
+		defer mu.Unlock()
+		status = code
+	}
+
+	create := func(body string) (int, *model.Webhook) {
+		response := executeReq(t, &testRouteConfig{
+			Method:  "POST",
+			URL:     "/webhooks",
+			Body:    strings.NewReader(body),
+			Handler: app.CreateWebhook,
+		})
+		var webhook model.Webhook
+		json.NewDecoder(response.Body).Decode(&webhook)
+		return response.Code, &webhook
+	}
+	for _, body := range []string{
+		`{"url": "ftp://example.com", "secret": "s3cret"}`,
+		`{"url": "https://example.com"}`,
+		`{"url": "https://example.com", "secret": "s3cret", "events": ["digest"]}`,
+	} {
+		code, _ := create(body)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+	}
+	code, _ := create(`{"url": "https://example.com", "secret": "s3cret", "floor_id": "00000000-0000-4000-a000-0000000000ff"}`)
+	assert.Equal(t, http.StatusNotFound, code)
+	code, webhook := create(`{"url": "` + server.URL + `", "secret": "s3cret", "events": ["booking.created", "booking.cancelled"], "floor_id": "` + floorId + `"}`)
+	require.Equal(t, http.StatusCreated, code)
+	assert.True(t, webhook.Active)
+	assert.Empty(t, webhook.Secret, "secrets are never returned")
+	code, other := create(`{"url": "` + server.URL + `/other", "secret": "s3cret", "floor_id": "` + otherFloorId + `"}`)
+	require.Equal(t, http.StatusCreated, code)
+
+	deliveries := func(id string) []*model.WebhookDelivery {
+		response := executeReq(t, &testRouteConfig{
+			Method:    "GET",
+			URL:       "/webhooks/" + id + "/deliveries",
+			URLParams: map[string]string{"id": id},
+			Handler:   app.GetWebhookDeliveries,
+		})
+		require.Equal(t, http.StatusOK, response.Code)
+		var deliveries []*model.WebhookDelivery
+		require.NoError(t, json.NewDecoder(response.Body).Decode(&deliveries))
+		return deliveries
+	}
+	getWebhook := func(id string) (int, *model.Webhook) {
+		response := executeReq(t, &testRouteConfig{
+			Method:    "GET",
+			URL:       "/webhooks/" + id,
+			URLParams: map[string]string{"id": id},
+			Handler:   app.GetOneWebhook,
+		})
+		var webhook model.Webhook
+		json.NewDecoder(response.Body).Decode(&webhook)
+		return response.Code, &webhook
+	}
+
+	body, err := json.Marshal(&model.Booking{UserID: booker, WorkspaceID: workspaceId, StartDate: start, EndDate: start.Add(8 * time.Hour)})
+	require.NoError(t, err)
+	response := executeReq(t, &testRouteConfig{Method: "POST", URL: "/bookings", Body: bytes.NewReader(body), Handler: app.CreateBooking})
+	require.Equal(t, http.StatusCreated, response.Code)
+	var booking model.Booking
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&booking))
+
+	app.deliverWebhooks(time.Now().Add(time.Second))
+	require.Len(t, requests, 1, "the webhook of the other floor doesn't get the booking")
+	received, receivedBody := <-requests, <-bodies
+	assert.Equal(t, notify.BookingCreated, received.Header.Get(notify.EventHeader))
+	timestamp, err := strconv.ParseInt(received.Header.Get(notify.TimestampHeader), 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, notify.Sign("s3cret", timestamp, receivedBody), received.Header.Get(notify.SignatureHeader))
+	var payload notify.Payload
+	require.NoError(t, json.Unmarshal(receivedBody, &payload))
+	assert.Equal(t, booking.ID, payload.ID)
+	assert.Equal(t, booker, payload.UserID)
+	assert.Equal(t, floorId, payload.FloorID)
+	assert.Equal(t, "W-001", payload.WorkspaceName)
+	logged := deliveries(webhook.ID)
+	if assert.Len(t, logged, 1) {
+		assert.Equal(t, received.Header.Get(notify.DeliveryHeader), logged[0].ID)
+		assert.Equal(t, model.OutboxDelivered, logged[0].Status)
+		assert.Equal(t, http.StatusOK, logged[0].ResponseStatus)
+	}
+	assert.Empty(t, deliveries(other.ID))
+
+	respond(http.StatusServiceUnavailable)
+	response = executeReq(t, &testRouteConfig{
+		Method:    "DELETE",
+		URL:       "/bookings/" + booking.ID,
+		URLParams: map[string]string{"id": booking.ID},
+		Handler:   app.RemoveBooking,
+	})
+	require.Equal(t, http.StatusOK, response.Code)
+	app.deliverWebhooks(time.Now().Add(time.Second))
+	require.Len(t, requests, 1)
+	assert.Equal(t, notify.BookingCancelled, (<-requests).Header.Get(notify.EventHeader))
+	<-bodies
+	logged = deliveries(webhook.ID)
+	if assert.Len(t, logged, 2) {
+		assert.Equal(t, model.OutboxPending, logged[0].Status)
+		assert.Equal(t, 1, logged[0].Attempts)
+		assert.Equal(t, http.StatusServiceUnavailable, logged[0].ResponseStatus)
+	}
+	_, failing := getWebhook(webhook.ID)
+	assert.True(t, failing.Active)
+	assert.NotNil(t, failing.FailingSince)
+
+	app.deliverWebhooks(time.Now().Add(time.Hour))
+	require.Len(t, requests, 1)
+	<-requests
+	<-bodies
+	_, disabled := getWebhook(webhook.ID)
+	assert.False(t, disabled.Active, "the webhook kept failing for longer than allowed")
+	assert.NotNil(t, disabled.DisabledAt)
+	app.deliverWebhooks(time.Now().Add(2 * time.Hour))
+	assert.Empty(t, requests, "disabled webhooks get nothing")
+
+	respond(http.StatusNoContent)
+	response = executeReq(t, &testRouteConfig{
+		Method:    "PATCH",
+		URL:       "/webhooks/" + webhook.ID,
+		Body:      strings.NewReader(`{"active": true}`),
+		URLParams: map[string]string{"id": webhook.ID},
+		Handler:   app.UpdateWebhook,
+	})
+	require.Equal(t, http.StatusOK, response.Code)
+	var enabled model.Webhook
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&enabled))
+	assert.True(t, enabled.Active)
+	assert.Nil(t, enabled.FailingSince)
+	assert.Nil(t, enabled.DisabledAt)
+	app.deliverWebhooks(time.Now().Add(24 * time.Hour))
+	require.Len(t, requests, 1, "the pending delivery is sent once the webhook is active again")
+	assert.Equal(t, notify.BookingCancelled, (<-requests).Header.Get(notify.EventHeader))
+	<-bodies
+	logged = deliveries(webhook.ID)
+	if assert.Len(t, logged, 2) {
+		assert.Equal(t, model.OutboxDelivered, logged[0].Status)
+		assert.Equal(t, 3, logged[0].Attempts)
+	}
+
+	response = executeReq(t, &testRouteConfig{
+		Method:    "DELETE",
+		URL:       "/webhooks/" + webhook.ID,
+		URLParams: map[string]string{"id": webhook.ID},
+		Handler:   app.RemoveWebhook,
+	})
+	assert.Equal(t, http.StatusOK, response.Code)
+	code, _ = getWebhook(webhook.ID)
+	assert.Equal(t, http.StatusNotFound, code)
+}
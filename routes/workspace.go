@@ -4,7 +4,11 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"github.com/gorilla/mux"
+	"go-api/auth"
+	"go-api/db"
+	"go-api/mail"
 	"go-api/model"
+	"go-api/notify"
 	"go-api/utils"
 	"io"
 	"io/ioutil"
@@ -32,16 +36,16 @@ func (app *App) RegisterWorkspaceRoutes() {
 		Methods("GET").
 		Queries("start", "{start:[0-9]+}").
 		Queries("end", "{end:[0-9]+}")
-	app.router.HandleFunc("/bulk/workspaces", app.BulkCreateWorkspaces).Methods("POST")
-	app.router.HandleFunc("/workspaces", app.CreateWorkspace).Methods("POST")
+	app.router.HandleFunc("/bulk/workspaces", app.authorize(app.BulkCreateWorkspaces, auth.AdminOnly)).Methods("POST")
+	app.router.HandleFunc("/workspaces", app.authorize(app.CreateWorkspace, auth.AdminOnly)).Methods("POST")
 	app.router.HandleFunc("/workspaces/{id}", app.GetOneWorkspace).Methods("GET")
 	app.router.HandleFunc("/workspaces", app.GetAllWorkspacesByFloorId).Methods("GET").
 		Queries("floor", "{floor}")
 	app.router.HandleFunc("/workspaces", app.GetAllWorkspaces)
-	app.router.HandleFunc("/workspaces/{id}/props", app.UpdateWorkspaceProps).Methods("PATCH")
-	app.router.HandleFunc("/workspaces/{id}", app.UpdateWorkspace).Methods("PATCH")
+	app.router.HandleFunc("/workspaces/{id}/props", app.authorize(app.UpdateWorkspaceProps, auth.AdminOnly)).Methods("PATCH")
+	app.router.HandleFunc("/workspaces/{id}", app.authorize(app.UpdateWorkspace, auth.AdminOnly)).Methods("PATCH")
 	//app.router.HandleFunc("/workspaces/{id}", app.DeleteWorkspace).Methods("DELETE")
-	app.router.HandleFunc("/assignments", app.CreateAssignments).Methods("POST")
+	app.router.HandleFunc("/assignments", app.authorize(app.CreateAssignments, auth.AdminOnly)).Methods("POST")
 	//app.router.HandleFunc("/workspaces/store/available", app.GetAvailabilityYesterday).Methods("GET")
 }
 
@@ -50,26 +54,27 @@ func (app *App) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
 	reqBody, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		log.Printf("App.CreateWorkspace - error reading request body %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "could not read the request body")
 		return
 	}
 
 	err = json.Unmarshal(reqBody, &newWorkspace)
 	if err != nil {
 		log.Printf("App.CreateWorkspace - error unmarshaling request body %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "request body is not valid JSON")
 		return
 	}
 	id, err := app.store.WorkspaceProvider.CreateWorkspace(&newWorkspace)
 	if err != nil {
 		log.Printf("App.CreateWorkspace - error creating workspace %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondStoreError(w, err, http.StatusInternalServerError)
 		return
 	}
 	newWorkspace.ID = id
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(newWorkspace)
+	app.publishWorkspace(notify.WorkspaceCreated, id)
 }
 
 func (app *App) GetOneWorkspace(w http.ResponseWriter, r *http.Request) {
@@ -77,14 +82,14 @@ func (app *App) GetOneWorkspace(w http.ResponseWriter, r *http.Request) {
 
 	if workspaceID == "" {
 		log.Printf("App.GetOneWorkspace - empty workspace id")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "workspace id is required")
 		return
 	}
 
 	workspace, err := app.store.WorkspaceProvider.GetOneWorkspace(workspaceID)
 	if err != nil {
 		log.Printf("App.GetOneWorkspace - error getting workspace from provider %v", err)
-		w.WriteHeader(http.StatusNotFound)
+		respondStoreError(w, err, http.StatusNotFound)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -95,7 +100,7 @@ func (app *App) GetAllWorkspaces(w http.ResponseWriter, r *http.Request) {
 	workspaces, err := app.store.WorkspaceProvider.GetAllWorkspaces()
 	if err != nil {
 		log.Printf("App.GetAllWorkspaces - error getting all workspaces from provider %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondStoreError(w, err, http.StatusInternalServerError)
 		return
 	}
 	json.NewEncoder(w).Encode(workspaces)
@@ -107,7 +112,7 @@ func (app *App) GetAllWorkspacesByFloorId(w http.ResponseWriter, r *http.Request
 	workspaces, err := app.store.WorkspaceProvider.GetAllWorkspacesByFloor(floorId)
 	if err != nil {
 		log.Printf("App.GetAllWorkspacesByFloorId - error getting all workspaces by floor id from provider %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondStoreError(w, err, http.StatusBadRequest)
 		return
 	}
 	json.NewEncoder(w).Encode(workspaces)
@@ -118,7 +123,7 @@ func (app *App) UpdateWorkspace(w http.ResponseWriter, r *http.Request) {
 
 	if workspaceID == "" {
 		log.Printf("App.UpdateWorkspace - empty workspace id")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "workspace id is required")
 		return
 	}
 
@@ -126,28 +131,25 @@ func (app *App) UpdateWorkspace(w http.ResponseWriter, r *http.Request) {
 	reqBody, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		log.Printf("App.UpdateWorkspace - error reading request body %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "could not read the request body")
 		return
 	}
 	err = json.Unmarshal(reqBody, &updatedWorkspace)
 	if err != nil {
 		log.Printf("App.UpdateWorkspace - error unmarshaling request body %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "request body is not valid JSON")
 		return
 	}
 
 	err = app.store.WorkspaceProvider.UpdateWorkspace(workspaceID, &updatedWorkspace)
 	if err != nil {
 		log.Printf("App.UpdateWorkspace - error updating workspace from provider %v", err)
-		if strings.Contains(err.Error(), "workspace name already exists") {
-			w.WriteHeader(http.StatusBadRequest)
-		} else {
-			w.WriteHeader(http.StatusNotFound)
-		}
+		respondStoreError(w, err, http.StatusNotFound)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(updatedWorkspace)
+	app.publishWorkspace(notify.WorkspaceUpdated, workspaceID)
 }
 
 func (app *App) UpdateWorkspaceProps(w http.ResponseWriter, r *http.Request) {
@@ -155,7 +157,7 @@ func (app *App) UpdateWorkspaceProps(w http.ResponseWriter, r *http.Request) {
 
 	if workspaceID == "" {
 		log.Printf("App.UpdateWorkspaceMetadata - empty workspace id")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "workspace id is required")
 		return
 	}
 
@@ -163,23 +165,24 @@ func (app *App) UpdateWorkspaceProps(w http.ResponseWriter, r *http.Request) {
 	reqBody, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		log.Printf("App.UpdateWorkspaceMetadata - error reading request body %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "could not read the request body")
 		return
 	}
 	err = json.Unmarshal(reqBody, &updatedProperties)
 	if err != nil {
 		log.Printf("App.UpdateWorkspaceMetadata - error unmarshaling request body %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "request body is not valid JSON")
 		return
 	}
 
 	err = app.store.WorkspaceProvider.UpdateWorkspaceMetadata(workspaceID, &updatedProperties)
 	if err != nil {
 		log.Printf("App.UpdateWorkspaceMetadata - error updating workspace from provider %v", err)
-		w.WriteHeader(http.StatusNotFound)
+		respondStoreError(w, err, http.StatusNotFound)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
+	app.publishWorkspace(notify.WorkspaceUpdated, workspaceID)
 }
 
 //func (app *App) DeleteWorkspace(w http.ResponseWriter, r *http.Request) {
@@ -207,25 +210,25 @@ func (app *App) GetAvailability(w http.ResponseWriter, r *http.Request) {
 	floorId := queryParams["floor"][0]
 	if floorId == "" {
 		log.Printf("App.GetAvailability - empty floor id param")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "floor id is required")
 		return
 	}
 	startTime, errStart := utils.TimeStampToTime(start) // Unix Timestamp
 	endTime, errEnd := utils.TimeStampToTime(end)
 	if errStart != nil {
 		log.Printf("App.GetAvailability - empty start time param: %v", errStart)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "start must be a unix timestamp")
 		return
 	}
 	if errEnd != nil {
 		log.Printf("App.GetAvailability - empty end time param: %v", errEnd)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "end must be a unix timestamp")
 		return
 	}
 	workspaceIds, err := app.store.WorkspaceProvider.FindAvailability(floorId, startTime, endTime)
 	if err != nil {
 		log.Printf("App.FindAvailability - error getting ids from provider %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondStoreError(w, err, http.StatusInternalServerError)
 		return
 	}
 	json.NewEncoder(w).Encode(workspaceIds)
@@ -239,18 +242,18 @@ func (app *App) GetAllFloorsAvailability(w http.ResponseWriter, r *http.Request)
 	endTime, errEnd := utils.TimeStampToTime(end)
 	if errStart != nil {
 		log.Printf("App.GetAvailability - empty start time param: %v", errStart)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "start must be a unix timestamp")
 		return
 	}
 	if errEnd != nil {
 		log.Printf("App.GetAvailability - empty end time param: %v", errEnd)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "end must be a unix timestamp")
 		return
 	}
 	floorIDs, err := app.store.FloorProvider.GetAllFloorIDs()
 	if err != nil {
 		log.Printf("App.GetAllFloorsAvailability - error getting floor_id's from provider %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondStoreError(w, err, http.StatusInternalServerError)
 		return
 	}
 	allWorkspaceIDs := make([]string, 0)
@@ -258,7 +261,7 @@ func (app *App) GetAllFloorsAvailability(w http.ResponseWriter, r *http.Request)
 		workspaceIDs, err := app.store.WorkspaceProvider.FindAvailability(f, startTime, endTime)
 		if err != nil {
 			log.Printf("App.GetAllFloorsAvailability - error getting ids from provider %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
 		allWorkspaceIDs = append(allWorkspaceIDs, workspaceIDs...)
@@ -280,23 +283,23 @@ func (app *App) GetBulkCountAvailability(w http.ResponseWriter, r *http.Request)
 	endTime, errEnd := utils.TimeStampToTime(end)
 	if errStart != nil {
 		log.Printf("App.GetBulkAvailability - empty start time param: %v", errStart)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "start must be a unix timestamp")
 		return
 	}
 	if errEnd != nil {
 		log.Printf("App.GetBulkAvailability - empty end time param: %v", errEnd)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "end must be a unix timestamp")
 		return
 	}
 	if endTime.Before(startTime) {
 		log.Printf("App.GetBulkAvailability - end time before start time: %v", errStart)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "end time must be after start time")
 		return
 	}
 	loc, err := time.LoadLocation("America/Vancouver")
 	if err != nil {
 		log.Printf("App.GetBulkAvailability - location failed with: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondStoreError(w, err, http.StatusInternalServerError)
 		return
 	}
 	// Truncate start time to beginning of the day
@@ -310,7 +313,7 @@ func (app *App) GetBulkCountAvailability(w http.ResponseWriter, r *http.Request)
 		workspacesDict, err := app.getBulkCountAvailabilities(s, e)
 		if err != nil {
 			log.Printf("App.GetBulkCountAvailability - error getting BulkAvailabilities %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
 		// Create dict id with d
@@ -329,17 +332,17 @@ func (app *App) GetBulkAvailability(w http.ResponseWriter, r *http.Request) {
 	endTime, errEnd := utils.TimeStampToTime(end)
 	if errStart != nil {
 		log.Printf("App.GetBulkAvailability - empty start time param: %v", errStart)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "start must be a unix timestamp")
 		return
 	}
 	if errEnd != nil {
 		log.Printf("App.GetBulkAvailability - empty end time param: %v", errEnd)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "end must be a unix timestamp")
 		return
 	}
 	if endTime.Before(startTime) {
 		log.Printf("App.GetBulkAvailability - end time before start time: %v", errStart)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "end time must be after start time")
 		return
 	}
 	// Truncate start time to beginning of the day
@@ -353,7 +356,7 @@ func (app *App) GetBulkAvailability(w http.ResponseWriter, r *http.Request) {
 		workspacesDict, err := app.getBulkAvailabilities(s, e)
 		if err != nil {
 			log.Printf("App.GetBulkAvailability - error getting BulkAvailabilities %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			respondStoreError(w, err, http.StatusInternalServerError)
 			return
 		}
 		// Create dict id with d
@@ -456,26 +459,26 @@ func (app *App) CreateAssignments(w http.ResponseWriter, r *http.Request) {
 	parseErr := r.ParseMultipartForm(MaxFileSize)
 	if parseErr != nil {
 		log.Println("App.CreateAssignments - failed to parse message")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "expecting a multipart form of at most 6MB")
 		return
 	}
 
 	if r.MultipartForm == nil || r.MultipartForm.File == nil {
 		log.Println("App.CreateAssignments - expecting multipart form file")
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "expecting a multipart form file")
 		return
 	}
 
 	assignmentsFile, _, err := r.FormFile("assignments")
 	if err != nil {
 		log.Println("App.CreateAssignments - users file is absent: " + err.Error())
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "assignments file is required")
 		return
 	}
 	floors, err := app.store.FloorProvider.GetAllFloors()
 	if err != nil {
 		log.Println("App.CreateAssignments - failed to get all floors: " + err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
+		respondStoreError(w, err, http.StatusInternalServerError)
 		return
 	}
 	floorMap := make(map[string]string)
@@ -484,6 +487,7 @@ func (app *App) CreateAssignments(w http.ResponseWriter, r *http.Request) {
 	}
 	csvFile := csv.NewReader(assignmentsFile) // workspaceName, FloorName, UserId
 	workspaces := make([]*model.Workspace, 0)
+	events := make([]*notify.Event, 0)
 	_, _ = csvFile.Read() // skip first row
 	for {
 		// Read each record from csv
@@ -493,7 +497,7 @@ func (app *App) CreateAssignments(w http.ResponseWriter, r *http.Request) {
 		}
 		if err != nil || len(record) != 3 {
 			log.Println("App.CreateAssignments - failed to parse csv file")
-			w.WriteHeader(http.StatusBadRequest)
+			respondError(w, http.StatusBadRequest, db.Validation, "every row of the assignments file needs workspace, floor and user id")
 			return
 		}
 		workspaceName := strings.TrimSpace(record[0])
@@ -507,6 +511,8 @@ func (app *App) CreateAssignments(w http.ResponseWriter, r *http.Request) {
 		id, err := app.store.WorkspaceProvider.CreateAssignWorkspace(workspace, userId)
 		if err != nil {
 			log.Printf("App.CreateAssignments - failed to create workspace-assignment for w:%s u:%s err:%s ", workspace, userId, err.Error())
+		} else {
+			events = append(events, app.assignmentEvent(id, workspace, floorName, userId))
 		}
 		workspace.ID = id
 		workspaces = append(workspaces, workspace)
@@ -514,6 +520,26 @@ func (app *App) CreateAssignments(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(workspaces)
+	for _, event := range events {
+		app.publish(event)
+		app.notify(event)
+	}
+}
+
+// assignmentEvent is the event of the workspace with id being assigned to the user with userId
+func (app *App) assignmentEvent(id string, workspace *model.Workspace, floorName, userId string) *notify.Event {
+	params := &mail.EmailParams{WorkspaceName: workspace.Name, FloorName: floorName}
+	if user, err := app.store.UserProvider.GetOneUser(userId); err == nil {
+		params.Name = user.Name
+	}
+	return &notify.Event{
+		Type:    notify.AssignmentChanged,
+		ID:      id,
+		Time:    time.Now(),
+		UserID:  userId,
+		FloorID: workspace.Floor,
+		Params:  params,
+	}
 }
 
 func (app *App) BulkCreateWorkspaces(w http.ResponseWriter, r *http.Request) {
@@ -521,18 +547,19 @@ func (app *App) BulkCreateWorkspaces(w http.ResponseWriter, r *http.Request) {
 	reqBody, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		log.Printf("App.BulkCreateWorkspaces - error reading request body %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "could not read the request body")
 		return
 	}
 
 	err = json.Unmarshal(reqBody, &input)
 	if err != nil {
 		log.Printf("App.BulkCreateWorkspaces - error unmarshaling request body %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondError(w, http.StatusBadRequest, db.Validation, "request body is not valid JSON")
 		return
 	}
 	errors := make([]*model.BulkCreateWorkspaceError, 0)
 	createdWorkspaces := make([]*model.CreateWorkspaceInput, 0)
+	newWorkspaces := make(map[string]bool)
 	for _, ws := range input.Workspaces {
 		workspace := &model.Workspace{
 			ID:      ws.WorkspaceId,
@@ -588,6 +615,7 @@ func (app *App) BulkCreateWorkspaces(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 		}
+		newWorkspaces[workspaceID] = ws.WorkspaceId == ""
 		ws.WorkspaceId = workspaceID
 		createdWorkspaces = append(createdWorkspaces, ws)
 	}
@@ -604,4 +632,20 @@ func (app *App) BulkCreateWorkspaces(w http.ResponseWriter, r *http.Request) {
 		errors,
 	}
 	json.NewEncoder(w).Encode(ret)
+
+	var floorName string
+	if floor, err := app.store.FloorProvider.GetOneFloor(input.FloorId); err == nil {
+		floorName = floor.Name
+	}
+	for _, ws := range createdWorkspaces {
+		eventType := notify.WorkspaceUpdated
+		if newWorkspaces[ws.WorkspaceId] {
+			eventType = notify.WorkspaceCreated
+		}
+		app.publishWorkspace(eventType, ws.WorkspaceId)
+		if ws.UserId != "" {
+			workspace := &model.Workspace{Name: ws.WorkspaceName, Floor: input.FloorId}
+			app.publish(app.assignmentEvent(ws.WorkspaceId, workspace, floorName, ws.UserId))
+		}
+	}
 }
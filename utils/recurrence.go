@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"errors"
+	"go-api/model"
+	"time"
+)
+
+const MaxOccurrences = 366
+
+// MaxDailyInterval and MaxWeeklyInterval are the longest gaps between the days or weeks of a series
+const (
+	MaxDailyInterval  = 366
+	MaxWeeklyInterval = 52
+)
+
+// MaxSeriesYears is how far after its first occurrence a series may run
+const MaxSeriesYears = 2
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+type Occurrence struct {
+	Start time.Time
+	End   time.Time
+}
+
+func ValidateRecurrence(rule *model.Recurrence) error {
+	if rule.Frequency != model.FrequencyDaily && rule.Frequency != model.FrequencyWeekly {
+		return errors.New("invalid recurrence: frequency must be daily or weekly")
+	}
+	if rule.Interval < 0 {
+		return errors.New("invalid recurrence: interval must be positive")
+	}
+	if rule.Frequency == model.FrequencyDaily && rule.Interval > MaxDailyInterval ||
+		rule.Frequency == model.FrequencyWeekly && rule.Interval > MaxWeeklyInterval {
+		return errors.New("invalid recurrence: interval out of range")
+	}
+	if rule.Until == nil && rule.Count == 0 {
+		return errors.New("invalid recurrence: either until or count is required")
+	}
+	if rule.Count < 0 || rule.Count > MaxOccurrences {
+		return errors.New("invalid recurrence: count out of range")
+	}
+	if len(rule.Weekdays) > 0 && rule.Frequency != model.FrequencyWeekly {
+		return errors.New("invalid recurrence: weekdays only apply to weekly series")
+	}
+	for _, day := range rule.Weekdays {
+		if _, ok := weekdays[day]; !ok {
+			return errors.New("invalid recurrence: unknown weekday " + day)
+		}
+	}
+	return nil
+}
+
+// ExpandRecurrence lists the occurrences of a series whose first occurrence runs from start to end.
+// Days are counted in loc so occurrences keep their wall clock time across daylight saving changes.
+func ExpandRecurrence(start, end time.Time, rule *model.Recurrence, loc *time.Location) ([]*Occurrence, error) {
+	if err := ValidateRecurrence(rule); err != nil {
+		return nil, err
+	}
+	if !end.After(start) {
+		return nil, errors.New("invalid recurrence: end time must be after start time")
+	}
+	interval := rule.Interval
+	if interval == 0 {
+		interval = 1
+	}
+	days := make(map[time.Weekday]bool)
+	for _, day := range rule.Weekdays {
+		days[weekdays[day]] = true
+	}
+	if len(days) == 0 {
+		days[start.In(loc).Weekday()] = true
+	}
+
+	localStart := start.In(loc)
+	last := localStart.AddDate(MaxSeriesYears, 0, 0)
+	if rule.Until != nil && rule.Until.After(last) {
+		return nil, errors.New("invalid recurrence: until is more than 2 years after the first occurrence")
+	}
+	duration := end.Sub(start)
+	occurrences := make([]*Occurrence, 0)
+	// add adds the occurrence starting at candidate and reports whether the series goes on after it
+	add := func(candidate time.Time) (bool, error) {
+		if rule.Until != nil && candidate.After(*rule.Until) || rule.Count > 0 && len(occurrences) >= rule.Count {
+			return false, nil
+		}
+		if len(occurrences) == MaxOccurrences {
+			return false, errors.New("invalid recurrence: series has too many occurrences")
+		}
+		if candidate.After(last) {
+			return false, errors.New("invalid recurrence: series runs more than 2 years")
+		}
+		occurrences = append(occurrences, &Occurrence{
+			Start: candidate.UTC(),
+			End:   candidate.Add(duration).UTC(),
+		})
+		return true, nil
+	}
+
+	more := true
+	var err error
+	switch rule.Frequency {
+	case model.FrequencyDaily:
+		for day := 0; more && err == nil; day += interval {
+			more, err = add(localStart.AddDate(0, 0, day))
+		}
+	case model.FrequencyWeekly:
+		// Weekly intervals are counted from the Monday of the first week
+		mondayOffset := (int(localStart.Weekday()) + 6) % 7
+		for week := 0; more && err == nil; week += interval {
+			for day := 0; day < 7 && more && err == nil; day++ {
+				candidate := localStart.AddDate(0, 0, 7*week+day-mondayOffset)
+				if candidate.Before(localStart) || !days[candidate.Weekday()] {
+					continue
+				}
+				more, err = add(candidate)
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return occurrences, nil
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go-api/model"
+	"testing"
+	"time"
+)
+
+func starts(occurrences []*Occurrence) []string {
+	result := make([]string, 0, len(occurrences))
+	for _, occurrence := range occurrences {
+		result = append(result, occurrence.Start.Format(time.RFC3339))
+	}
+	return result
+}
+
+func TestExpandRecurrence(t *testing.T) {
+	loc, err := time.LoadLocation("America/Vancouver")
+	if err != nil {
+		t.Skip("timezone data unavailable")
+	}
+	// Monday 4 March 2019, 09:00 in Vancouver
+	start := time.Date(2019, 3, 4, 9, 0, 0, 0, loc)
+	end := start.Add(8 * time.Hour)
+	until := time.Date(2019, 3, 15, 23, 59, 59, 0, loc)
+
+	occurrences, err := ExpandRecurrence(start, end, &model.Recurrence{Frequency: model.FrequencyDaily, Interval: 2, Count: 3}, loc)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2019-03-04T17:00:00Z", "2019-03-06T17:00:00Z", "2019-03-08T17:00:00Z"}, starts(occurrences))
+
+	occurrences, err = ExpandRecurrence(start, end, &model.Recurrence{Frequency: model.FrequencyWeekly, Weekdays: []string{"MO", "TH"}, Until: &until}, loc)
+	assert.NoError(t, err)
+	// Daylight saving starts on the 10th; occurrences keep their 09:00 wall clock time
+	assert.Equal(t, []string{
+		"2019-03-04T17:00:00Z", "2019-03-07T17:00:00Z", "2019-03-11T16:00:00Z", "2019-03-14T16:00:00Z",
+	}, starts(occurrences))
+	assert.Equal(t, 8*time.Hour, occurrences[2].End.Sub(occurrences[2].Start))
+
+	occurrences, err = ExpandRecurrence(start, end, &model.Recurrence{Frequency: model.FrequencyWeekly, Interval: 2, Count: 2}, loc)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2019-03-04T17:00:00Z", "2019-03-18T16:00:00Z"}, starts(occurrences))
+
+	// Thursday 7 March; the first week is counted from Monday the 4th
+	thursday := start.AddDate(0, 0, 3)
+	occurrences, err = ExpandRecurrence(thursday, thursday.Add(time.Hour), &model.Recurrence{Frequency: model.FrequencyWeekly, Interval: 2, Weekdays: []string{"MO", "TH"}, Count: 3}, loc)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2019-03-07T17:00:00Z", "2019-03-18T16:00:00Z", "2019-03-21T16:00:00Z"}, starts(occurrences))
+
+	occurrences, err = ExpandRecurrence(start, end, &model.Recurrence{Frequency: model.FrequencyDaily, Interval: MaxDailyInterval, Count: 2}, loc)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2019-03-04T17:00:00Z", "2020-03-04T17:00:00Z"}, starts(occurrences))
+}
+
+func TestExpandRecurrenceInvalid(t *testing.T) {
+	start := time.Date(2019, 3, 4, 9, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	until := start.AddDate(5, 0, 0)
+	cases := map[string]*model.Recurrence{
+		"unknown frequency":  {Frequency: "monthly", Count: 2},
+		"no end":             {Frequency: model.FrequencyDaily},
+		"unknown weekday":    {Frequency: model.FrequencyWeekly, Weekdays: []string{"XX"}, Count: 2},
+		"weekdays for daily": {Frequency: model.FrequencyDaily, Weekdays: []string{"MO"}, Count: 2},
+		"too many":           {Frequency: model.FrequencyDaily, Until: &until},
+		"daily interval":     {Frequency: model.FrequencyDaily, Interval: 2000000000, Count: 2},
+		"weekly interval":    {Frequency: model.FrequencyWeekly, Interval: MaxWeeklyInterval + 1, Count: 2},
+		"until too late":     {Frequency: model.FrequencyWeekly, Interval: MaxWeeklyInterval, Until: &until},
+		"too long":           {Frequency: model.FrequencyDaily, Interval: MaxDailyInterval, Count: 3},
+	}
+	for name, rule := range cases {
+		_, err := ExpandRecurrence(start, end, rule, time.UTC)
+		assert.Error(t, err, name)
+	}
+	_, err := ExpandRecurrence(end, start, &model.Recurrence{Frequency: model.FrequencyDaily, Count: 2}, time.UTC)
+	assert.Error(t, err, "end before start")
+}